@@ -0,0 +1,26 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/dsha256/dispatcher/internal/config"
+	"github.com/dsha256/dispatcher/internal/listener"
+)
+
+// listenAndServe opens the configured listener and serves on it, HTTPS when the server has a TLS
+// configuration, HTTP otherwise.
+func listenAndServe(logger *slog.Logger, srv *http.Server, cfg config.Server) error {
+	ln, err := listener.Listen(context.Background(), cfg.Listener, cfg.Port)
+	if err != nil {
+		return err
+	}
+	logger.Info("Server starting", "network", ln.Addr().Network(), "addr", ln.Addr().String(), "tls", srv.TLSConfig != nil)
+
+	if srv.TLSConfig != nil {
+		return srv.ServeTLS(ln, "", "")
+	}
+
+	return srv.Serve(ln)
+}
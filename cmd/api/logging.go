@@ -0,0 +1,41 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/dsha256/dispatcher/internal/logging"
+)
+
+// toggleLogLevelOnSIGUSR1 switches the log level between debug and the configured level on every SIGUSR1,
+// until the returned function is called.
+func toggleLogLevelOnSIGUSR1(logger *slog.Logger, level *logging.Level) func() {
+	return onSignal(syscall.SIGUSR1, func() {
+		logger.Warn("Log level toggled by SIGUSR1", "level", level.Toggle())
+	})
+}
+
+// onSignal calls fn on every signal sig until the returned function is called.
+func onSignal(sig os.Signal, fn func()) func() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, sig)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-signals:
+				fn()
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"os"
@@ -11,49 +12,69 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/dsha256/dispatcher/internal/app"
+	"github.com/dsha256/dispatcher/internal/clock"
 	"github.com/dsha256/dispatcher/internal/config"
-	"github.com/dsha256/dispatcher/internal/dispatcher"
-	"github.com/dsha256/dispatcher/internal/handler"
+	"github.com/dsha256/dispatcher/internal/logbuffer"
+	"github.com/dsha256/dispatcher/internal/logging"
+)
+
+const (
+	// recentLogLines is the number of log lines kept in memory for support bundles.
+	recentLogLines = 1000
+	// configPath is the configuration file, reloaded while the service runs.
+	configPath = "./config.yaml"
 )
 
 func main() {
-	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
-		Level: slog.LevelDebug,
-	}))
-	slog.SetDefault(logger)
+	if len(os.Args) > 1 && os.Args[1] == "support-bundle" {
+		if err := runSupportBundle(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, "support-bundle:", err)
+			os.Exit(1)
+		}
+
+		return
+	}
+
+	cfg, err := config.GetConfigFromFile(configPath)
+	if err != nil {
+		slog.Error("Failed to load config file", "error", err)
+		os.Exit(1)
+	}
 
-	cfg, err := config.GetConfigFromFile("./config.yaml")
+	logs := logbuffer.New(recentLogLines)
+	logger, logLevel, err := logging.New(io.MultiWriter(os.Stdout, logs), cfg.Log)
 	if err != nil {
-		logger.Error("Failed to load config file", "error", err)
+		slog.Error("Invalid log configuration", "error", err)
 		os.Exit(1)
 	}
+	slog.SetDefault(logger)
+	stopLogSignals := toggleLogLevelOnSIGUSR1(logger, logLevel)
 
 	logger.Info("Starting dispatcher service")
 
-	newDispatcher := dispatcher.New()
-
-	newHandler := handler.New(logger, newDispatcher)
-
-	srv := &http.Server{
-		Addr: fmt.Sprintf(":%d", cfg.Server.Port),
-		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			logger.Info("Received request",
-				"method", r.Method,
-				"path", r.URL.Path,
-				"remote_addr", r.RemoteAddr,
-			)
-			mux := http.NewServeMux()
-			newHandler.RegisterRoutes(mux)
-			mux.ServeHTTP(w, r)
-		}),
-		ReadTimeout:       cfg.Server.ReadTimeout,
-		WriteTimeout:      cfg.Server.WriteTimeout,
-		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+	service, err := app.New(logger, clock.Real{}, cfg, logLevel, logs)
+	if err != nil {
+		logger.Error("Failed to start the service", "error", err)
+		os.Exit(1)
+	}
+	service.Publish()
+
+	stopReload := (&reloader{
+		logger: logger, current: cfg, bundler: service.Bundler, logLevel: logLevel, limits: service.Limits,
+		timeouts: service.Timeouts, shedder: service.Shedder, handler: service.Handler, path: configPath,
+	}).watch(cfg.Reload.WatchInterval)
+
+	stopNATS := service.ServeNATS()
+
+	srv, stopTLSReload, err := newServer(logger, cfg.Server, service.Routes)
+	if err != nil {
+		logger.Error("Invalid TLS configuration", "error", err)
+		os.Exit(1)
 	}
 
 	go func() {
-		logger.Info("Server starting", "port", cfg.Server.Port)
-		if err = srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		if err = listenAndServe(logger, srv, cfg.Server); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			logger.Error("Server failed", "error", err)
 			os.Exit(1)
 		}
@@ -71,6 +92,11 @@ func main() {
 	if err = srv.Shutdown(ctx); err != nil {
 		logger.Error("Server forced to shutdown", "error", err)
 	}
+	stopNATS()
+	service.Close(ctx)
+	stopReload()
+	stopLogSignals()
+	stopTLSReload()
 
 	logger.Info("Server exited properly")
 }
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/config"
+	"github.com/dsha256/dispatcher/internal/handler"
+	"github.com/dsha256/dispatcher/internal/limits"
+	"github.com/dsha256/dispatcher/internal/logging"
+	"github.com/dsha256/dispatcher/internal/middleware"
+	"github.com/dsha256/dispatcher/internal/shedding"
+	"github.com/dsha256/dispatcher/internal/support"
+)
+
+// reloader applies the reloadable settings of the configuration file to the running service: the log level,
+// the request limits, the route timeouts, the load shedding cap and maintenance mode. Changes to other settings
+// are logged and left for a restart.
+type reloader struct {
+	logger   *slog.Logger
+	current  *config.Config
+	bundler  *support.Bundler
+	logLevel *logging.Level
+	limits   *limits.Checker
+	timeouts *middleware.ReloadableTimeouts
+	// shedder is nil when load shedding is disabled.
+	shedder *shedding.Shedder
+	handler *handler.Handler
+	path    string
+	mu      sync.Mutex
+}
+
+// watch reloads the configuration on every SIGHUP and, when the interval is set, whenever the modification
+// time of the file changes, until the returned function is called.
+func (r *reloader) watch(interval time.Duration) func() {
+	stopSignals := onSignal(syscall.SIGHUP, r.reload)
+	if interval <= 0 {
+		return stopSignals
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		modified := modTime(r.path)
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if latest := modTime(r.path); !latest.Equal(modified) {
+					modified = latest
+					r.reload()
+				}
+			}
+		}
+	}()
+
+	return func() {
+		stopSignals()
+		close(done)
+	}
+}
+
+// modTime returns the modification time of the file, zero when it cannot be read.
+func modTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return info.ModTime()
+}
+
+// reload reads the configuration file and applies the reloadable settings it changes. A file that cannot be
+// read or parsed, or an invalid setting, keeps the current settings.
+func (r *reloader) reload() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	next, err := config.GetConfigFromFile(r.path)
+	if err != nil {
+		r.logger.Error("Failed to reload the configuration, keeping the current one", "error", err, "path", r.path)
+
+		return
+	}
+	reloaded, restart := config.Reloaded(r.current, next)
+	if len(restart) > 0 {
+		r.logger.Error("Configuration changes need a restart and were not applied", "settings", restart, "path", r.path)
+	}
+
+	if reloaded.Limits != r.current.Limits {
+		if err = r.limits.SetLimits(reloaded.Limits); err != nil {
+			r.logger.Error("Invalid reloaded limits, keeping the current ones", "error", err)
+			reloaded.Limits = r.current.Limits
+		}
+	}
+	if r.shedder != nil && reloaded.Shedding.MaxConcurrent != r.current.Shedding.MaxConcurrent {
+		if err = r.shedder.SetLimit(reloaded.Shedding.MaxConcurrent); err != nil {
+			r.logger.Error("Invalid reloaded load shedding cap, keeping the current one", "error", err)
+			reloaded.Shedding.MaxConcurrent = r.current.Shedding.MaxConcurrent
+		}
+	}
+	if reloaded.Log.Level != r.current.Log.Level {
+		r.logLevel.Configure(reloaded.Log.Level)
+	}
+	if reloaded.Maintenance != r.current.Maintenance {
+		r.handler.SetMaintenance(reloaded.Maintenance)
+	}
+	r.timeouts.Set(reloaded.Server.Timeouts)
+
+	r.current = reloaded
+	r.bundler.SetConfig(reloaded)
+	r.logger.Info("Configuration reloaded", "path", r.path)
+}
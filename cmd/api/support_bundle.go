@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+// runSupportBundle downloads a support bundle from a running dispatcher instance and stores it on disk.
+//
+// Usage:
+//
+//	dispatcher support-bundle [-addr http://localhost:3000] [-out bundle.tar.gz] [-token admin-token]
+func runSupportBundle(args []string) error {
+	fs := flag.NewFlagSet("support-bundle", flag.ContinueOnError)
+	addr := fs.String("addr", "http://localhost:3000", "base URL of the running dispatcher instance")
+	out := fs.String("out", fmt.Sprintf("dispatcher-support-%s.tar.gz", time.Now().UTC().Format("20060102T150405Z")), "output file")
+	token := fs.String("token", os.Getenv("DISPATCHER_ADMIN_TOKEN"), "admin token of the instance")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, *addr+"/api/v1/admin/support-bundle", nil)
+	if err != nil {
+		return err
+	}
+	if *token != "" {
+		req.Header.Set("Authorization", "Bearer "+*token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	f, err := os.OpenFile(*out, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err = io.Copy(f, resp.Body); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(os.Stdout, "support bundle written to", *out)
+
+	return nil
+}
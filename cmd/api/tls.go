@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"syscall"
+
+	"github.com/dsha256/dispatcher/internal/config"
+	"github.com/dsha256/dispatcher/internal/tlsconfig"
+)
+
+// newServer returns the server of the routes, terminating TLS when enabled, and the function stopping the
+// reloads of its certificates.
+func newServer(logger *slog.Logger, cfg config.Server, routes http.Handler) (*http.Server, func(), error) {
+	srv := &http.Server{
+		Addr:              fmt.Sprintf(":%d", cfg.Port),
+		Handler:           routes,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+	}
+	stopTLSReload, err := configureTLS(logger, cfg.TLS, srv)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return srv, stopTLSReload, nil
+}
+
+// configureTLS sets the server up to terminate TLS when enabled, reloading the certificate and client CAs
+// on every SIGHUP until the returned function is called.
+func configureTLS(logger *slog.Logger, cfg tlsconfig.Config, srv *http.Server) (func(), error) {
+	if !cfg.Enabled {
+		return func() {}, nil
+	}
+
+	reloader, err := tlsconfig.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	srv.TLSConfig = reloader.TLSConfig()
+	logger.Info("Terminating TLS", "cert_file", cfg.CertFile, "mutual", cfg.ClientCAFile != "")
+
+	return onSignal(syscall.SIGHUP, func() {
+		if err := reloader.Reload(); err != nil {
+			logger.Error("Failed to reload TLS certificates, keeping the previous ones", "error", err)
+
+			return
+		}
+		logger.Info("TLS certificates reloaded", "cert_file", cfg.CertFile)
+	}), nil
+}
@@ -0,0 +1,246 @@
+// Command dispatcher-cli reconstructs itineraries offline, without a running service, e.g. to sanity-check ticket dumps.
+//
+// Usage:
+//
+//	dispatcher-cli [-in tickets.json|-] [-input json|csv] [-columns from,to,price,departs_at]
+//	               [-output json|text|table|summary|markdown] [-strategy default]
+//	               [-tie-break smallest_first|largest_first|input_order] [-allow-duplicates]
+//
+// Tickets are read from the file, or from stdin when it is "-" (the default). JSON input is either an array of
+// tickets or an object with a "tickets" array, as sent to the API; CSV columns follow -columns unless the
+// first row is a header naming them. The summary and markdown outputs render the itinerary as the API does for
+// Accept: text/plain and text/markdown, to paste into tickets and chats.
+//
+// Exit codes: 0 on success, 1 when the tickets do not form a valid itinerary, 2 on usage or input errors.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dsha256/dispatcher/internal/airports"
+	"github.com/dsha256/dispatcher/internal/dispatcher"
+	"github.com/dsha256/dispatcher/internal/export"
+	"github.com/dsha256/dispatcher/internal/ticketcsv"
+	"github.com/dsha256/dispatcher/pkg/itinerary"
+)
+
+const (
+	exitOK      = 0
+	exitInvalid = 1
+	exitUsage   = 2
+)
+
+var errUnknownFormat = errors.New("unknown format")
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("dispatcher-cli", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	in := fs.String("in", "-", `tickets file, "-" for stdin`)
+	input := fs.String("input", "", "input format: json or csv (default: from the file extension, else json)")
+	columns := fs.String("columns", "from,to,price,departs_at", "CSV column mapping, - skips a column")
+	output := fs.String("output", "text", "output format: json, text, table, summary or markdown")
+	strategy := fs.String("strategy", string(itinerary.StrategyDefault), "strategy when several orderings are valid: default or chronological")
+	tieBreak := fs.String("tie-break", string(itinerary.TieBreakSmallestFirst),
+		"destination taken first when an airport has several: smallest_first, largest_first or input_order")
+	allowDuplicates := fs.Bool("allow-duplicates", false, "accept repeated identical tickets")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	switch *output {
+	case "json", "text", "table", "summary", "markdown":
+	default:
+		fmt.Fprintf(stderr, "dispatcher-cli: %s: output %q\n", errUnknownFormat, *output)
+
+		return exitUsage
+	}
+
+	mapping, err := ticketcsv.ParseMapping(*columns)
+	if err != nil {
+		fmt.Fprintln(stderr, "dispatcher-cli:", err)
+
+		return exitUsage
+	}
+
+	tickets, err := readTickets(*in, *input, mapping, stdin)
+	if err != nil {
+		fmt.Fprintln(stderr, "dispatcher-cli:", err)
+
+		return exitUsage
+	}
+
+	opts := []itinerary.Option{
+		itinerary.WithStrategy(itinerary.Strategy(*strategy)),
+		itinerary.WithTieBreak(itinerary.TieBreak(*tieBreak)),
+	}
+	if *allowDuplicates {
+		opts = append(opts, itinerary.WithDuplicates())
+	}
+	result, err := itinerary.Reconstruct(context.Background(), tickets, opts...)
+	if err != nil {
+		fmt.Fprintln(stderr, "dispatcher-cli:", err)
+		var detailed interface{ Details() any }
+		if errors.As(err, &detailed) {
+			details, _ := json.MarshalIndent(detailed.Details(), "", "  ")
+			fmt.Fprintln(stderr, string(details))
+		}
+		if errors.Is(err, itinerary.ErrUnknownStrategy) || errors.Is(err, itinerary.ErrUnknownTieBreak) {
+			return exitUsage
+		}
+
+		return exitInvalid
+	}
+
+	if err = writeResult(stdout, *output, tickets, result); err != nil {
+		fmt.Fprintln(stderr, "dispatcher-cli:", err)
+
+		return exitUsage
+	}
+
+	return exitOK
+}
+
+func readTickets(path, format string, mapping ticketcsv.Mapping, stdin io.Reader) ([]itinerary.Ticket, error) {
+	r := stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	if format == "" {
+		format = "json"
+		if strings.EqualFold(filepath.Ext(path), ".csv") {
+			format = "csv"
+		}
+	}
+
+	switch format {
+	case "csv":
+		tickets, err := ticketcsv.Read(r, mapping)
+		if err != nil {
+			return nil, err
+		}
+
+		return fromCSV(tickets), nil
+	case "json":
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		var tickets []itinerary.Ticket
+		if trimmed := strings.TrimSpace(string(data)); strings.HasPrefix(trimmed, "{") {
+			var req struct {
+				Tickets []itinerary.Ticket `json:"tickets"`
+			}
+			err = json.Unmarshal(data, &req)
+			tickets = req.Tickets
+		} else {
+			err = json.Unmarshal(data, &tickets)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid JSON input: %w", err)
+		}
+
+		return tickets, nil
+	default:
+		return nil, fmt.Errorf("%w: input %q", errUnknownFormat, format)
+	}
+}
+
+func writeResult(w io.Writer, format string, tickets []itinerary.Ticket, result *itinerary.Result) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+
+		return enc.Encode(struct {
+			AlgorithmVersion string          `json:"algorithm_version"`
+			LinearPath       []string        `json:"linear_path"`
+			Legs             []itinerary.Leg `json:"legs"`
+		}{
+			AlgorithmVersion: result.AlgorithmVersion,
+			LinearPath:       result.Path,
+			Legs:             result.Legs,
+		})
+	case "text":
+		_, err := fmt.Fprintln(w, strings.Join(result.Path, " -> "))
+
+		return err
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "#\tFROM\tTO\tTICKET")
+		for i, leg := range result.Legs {
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%d\n", i+1, leg.From, leg.To, leg.TicketIndex)
+		}
+
+		return tw.Flush()
+	case "summary":
+		_, err := w.Write(export.Text(exportItinerary(tickets, result), airports.Default()))
+
+		return err
+	case "markdown":
+		_, err := w.Write(export.Markdown(exportItinerary(tickets, result), airports.Default()))
+
+		return err
+	default:
+		return fmt.Errorf("%w: output %q", errUnknownFormat, format)
+	}
+}
+
+// fromCSV converts the tickets read from CSV to those of the itinerary API.
+func fromCSV(tickets []dispatcher.Ticket) []itinerary.Ticket {
+	converted := make([]itinerary.Ticket, 0, len(tickets))
+	for _, t := range tickets {
+		converted = append(converted, itinerary.Ticket{
+			DepartsAt: t.DepartsAt,
+			ArrivesAt: t.ArrivesAt,
+			Price:     t.Price,
+			From:      t.From,
+			To:        t.To,
+			FlightNo:  t.FlightNo,
+			Currency:  t.Currency,
+		})
+	}
+
+	return converted
+}
+
+// exportItinerary returns the result with the flight numbers, times and prices of its tickets, to render.
+func exportItinerary(tickets []itinerary.Ticket, result *itinerary.Result) export.Itinerary {
+	exported := export.Itinerary{Path: result.Path, Legs: make([]export.Leg, 0, len(result.Legs))}
+	total, priced := 0.0, false
+	for _, leg := range result.Legs {
+		ticket := tickets[leg.TicketIndex]
+		exported.Legs = append(exported.Legs, export.Leg{
+			DepartsAt: ticket.DepartsAt,
+			ArrivesAt: ticket.ArrivesAt,
+			From:      leg.From,
+			To:        leg.To,
+			FlightNo:  ticket.FlightNo,
+		})
+		if ticket.Price != nil {
+			total, priced = total+*ticket.Price, true
+		}
+	}
+	if priced {
+		exported.TotalPrice = &total
+	}
+
+	return exported
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	csvFile := filepath.Join(t.TempDir(), "tickets.csv")
+	if err := os.WriteFile(csvFile, []byte("from,to\nLAX,DXB\nJFK,LAX\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write tickets: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		stdin    string
+		want     string
+		args     []string
+		exitCode int
+	}{
+		{
+			name:  "JSON pairs from stdin",
+			stdin: `[["LAX", "DXB"], ["JFK", "LAX"]]`,
+			want:  "JFK -> LAX -> DXB\n",
+		},
+		{
+			name:  "JSON request body",
+			stdin: `{"tickets": [{"from": "LAX", "to": "DXB"}, ["JFK", "LAX"]]}`,
+			want:  "JFK -> LAX -> DXB\n",
+		},
+		{
+			name: "CSV file",
+			args: []string{"-in", csvFile},
+			want: "JFK -> LAX -> DXB\n",
+		},
+		{
+			name:  "Table output",
+			args:  []string{"-output", "table"},
+			stdin: `[["LAX", "DXB"], ["JFK", "LAX"]]`,
+			want:  "#  FROM  TO   TICKET\n1  JFK   LAX  1\n2  LAX   DXB  0\n",
+		},
+		{
+			name:  "Summary output",
+			args:  []string{"-output", "summary"},
+			stdin: `[["LAX", "DXB"], {"from": "JFK", "to": "LAX", "flight_no": "AA1", "price": 200}]`,
+			want:  "JFK → LAX → DXB, 2 legs, 1 stop in Los Angeles\n\n1. JFK → LAX (AA1)\n2. LAX → DXB\n\nTotal price: 200.00\n",
+		},
+		{
+			name:  "Markdown output",
+			args:  []string{"-output", "markdown"},
+			stdin: `[["JFK", "LAX"]]`,
+			want: "**JFK → LAX**, 1 leg, nonstop\n\n| # | Flight | From | To | Departs | Arrives | Layover |\n" +
+				"|---|---|---|---|---|---|---|\n| 1 |  | JFK | LAX |  |  |  |\n",
+		},
+		{
+			name:  "Largest first",
+			args:  []string{"-tie-break", "largest_first"},
+			stdin: `[["JFK", "ATL"], ["JFK", "SFO"], ["SFO", "ATL"], ["ATL", "JFK"]]`,
+			want:  "JFK -> SFO -> ATL -> JFK -> ATL\n",
+		},
+		{
+			name:     "Unknown tie-break",
+			args:     []string{"-tie-break", "random"},
+			stdin:    `[["JFK", "LAX"]]`,
+			exitCode: exitUsage,
+		},
+		{
+			name:     "Invalid itinerary",
+			stdin:    `[["JFK", "LAX"], ["SFO", "LAX"]]`,
+			exitCode: exitInvalid,
+		},
+		{
+			name:     "Malformed input",
+			stdin:    `[["JFK", "LAX"]`,
+			exitCode: exitUsage,
+		},
+		{
+			name:     "Unknown output",
+			args:     []string{"-output", "yaml"},
+			stdin:    `[["JFK", "LAX"]]`,
+			exitCode: exitUsage,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var stdout, stderr bytes.Buffer
+			if code := run(tt.args, strings.NewReader(tt.stdin), &stdout, &stderr); code != tt.exitCode {
+				t.Fatalf("run() = %d; want %d, stderr: %s", code, tt.exitCode, stderr.String())
+			}
+			if got := stdout.String(); got != tt.want {
+				t.Errorf("run() output = %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
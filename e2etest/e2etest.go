@@ -0,0 +1,160 @@
+// Package e2etest starts a fully wired dispatcher server for black-box tests.
+//
+// The server runs on an ephemeral local port with in-memory dependencies, a fake clock and
+// captured logs, so integrations can be tested without Docker or any external service. Itinerary
+// storage, the result cache, idempotency keys and jobs are enabled on their memory backends:
+//
+//	func TestMyIntegration(t *testing.T) {
+//		srv := e2etest.Start(t)
+//		resp := srv.PostJSON(t, "/api/v1/dispatcher/itinerary", map[string]any{
+//			"tickets": [][]string{{"JFK", "LAX"}},
+//		})
+//		defer resp.Body.Close()
+//		...
+//	}
+package e2etest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/app"
+	"github.com/dsha256/dispatcher/internal/clock"
+	"github.com/dsha256/dispatcher/internal/config"
+	"github.com/dsha256/dispatcher/internal/idempotency"
+	"github.com/dsha256/dispatcher/internal/jobs"
+	"github.com/dsha256/dispatcher/internal/logbuffer"
+	"github.com/dsha256/dispatcher/internal/logging"
+)
+
+const capturedLogLines = 10_000
+
+// Server is a running dispatcher instance bound to a single test.
+type Server struct {
+	// Clock drives every time-dependent component of the server.
+	Clock *clock.Fake
+	// Config is the configuration the server was started with.
+	Config *config.Config
+	// URL is the base URL of the server, e.g. http://127.0.0.1:54321.
+	URL string
+
+	httpServer *httptest.Server
+	logs       *logbuffer.Buffer
+}
+
+// Option adjusts the configuration the server starts with, e.g. to enable a feature or disable a backend.
+type Option func(cfg *config.Config)
+
+// Start spins up the full server, wired like the service's binary with its middlewares, and registers its
+// shutdown with t.Cleanup. The options apply in order to the default test configuration. The fake clock
+// starts at 2025-01-01T00:00:00Z.
+func Start(t testing.TB, opts ...Option) *Server {
+	t.Helper()
+
+	cfg := &config.Config{
+		Log: logging.Config{Level: slog.LevelDebug},
+		Server: config.Server{
+			ReadTimeout:       5 * time.Second,
+			ReadHeaderTimeout: 5 * time.Second,
+			WriteTimeout:      10 * time.Second,
+		},
+		Storage:     config.Storage{Enabled: true, Backend: config.StorageBackendMemory},
+		Cache:       config.Cache{Enabled: true, Backend: config.CacheBackendMemory, TTL: 5 * time.Minute, MaxEntries: 10_000},
+		Idempotency: idempotency.Config{Enabled: true},
+		Jobs:        jobs.Config{Enabled: true, Backend: jobs.BackendMemory},
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	clk := clock.NewFake(time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC))
+	logs := logbuffer.New(capturedLogLines)
+	logger, logLevel, err := logging.New(logs, cfg.Log)
+	if err != nil {
+		t.Fatalf("e2etest: creating logger: %v", err)
+	}
+
+	service, err := app.New(logger, clk, cfg, logLevel, logs)
+	if err != nil {
+		t.Fatalf("e2etest: wiring server: %v", err)
+	}
+
+	httpServer := httptest.NewUnstartedServer(service.Routes)
+	httpServer.Config.ReadTimeout = cfg.Server.ReadTimeout
+	httpServer.Config.ReadHeaderTimeout = cfg.Server.ReadHeaderTimeout
+	httpServer.Config.WriteTimeout = cfg.Server.WriteTimeout
+	httpServer.Start()
+
+	srv := &Server{
+		Clock:      clk,
+		Config:     cfg,
+		URL:        httpServer.URL,
+		httpServer: httpServer,
+		logs:       logs,
+	}
+
+	t.Cleanup(func() {
+		httpServer.Close()
+		service.Close(context.Background())
+		if t.Failed() {
+			for _, line := range logs.Lines() {
+				t.Log(line)
+			}
+		}
+	})
+
+	return srv
+}
+
+// Client returns an HTTP client configured for the server.
+func (s *Server) Client() *http.Client {
+	return s.httpServer.Client()
+}
+
+// Logs returns the log lines the server produced so far.
+// They are also printed automatically when the test fails.
+func (s *Server) Logs() []string {
+	return s.logs.Lines()
+}
+
+// Do sends a request with the given method, path and raw body to the server.
+// The caller is responsible for closing the response body.
+func (s *Server) Do(t testing.TB, method, path string, body io.Reader) *http.Response {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+
+	req, err := http.NewRequestWithContext(ctx, method, s.URL+path, body)
+	if err != nil {
+		t.Fatalf("e2etest: creating request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.Client().Do(req)
+	if err != nil {
+		t.Fatalf("e2etest: sending request: %v", err)
+	}
+
+	return resp
+}
+
+// PostJSON marshals body and posts it to path.
+// The caller is responsible for closing the response body.
+func (s *Server) PostJSON(t testing.TB, path string, body any) *http.Response {
+	t.Helper()
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("e2etest: marshaling request body: %v", err)
+	}
+
+	return s.Do(t, http.MethodPost, path, bytes.NewReader(payload))
+}
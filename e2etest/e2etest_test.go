@@ -0,0 +1,112 @@
+package e2etest_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dsha256/dispatcher/e2etest"
+	"github.com/dsha256/dispatcher/internal/config"
+)
+
+func TestStart(t *testing.T) {
+	t.Parallel()
+
+	srv := e2etest.Start(t)
+
+	resp := srv.PostJSON(t, "/api/v1/dispatcher/itinerary", map[string]any{
+		"tickets": [][]string{{"LAX", "DXB"}, {"JFK", "LAX"}},
+	})
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	var body struct {
+		Data struct {
+			LinearPath []string `json:"linear_path"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode response body: %v", err)
+	}
+
+	if want := []string{"JFK", "LAX", "DXB"}; !reflect.DeepEqual(body.Data.LinearPath, want) {
+		t.Errorf("Expected linear_path %v, got %v", want, body.Data.LinearPath)
+	}
+
+	found := false
+	for _, line := range srv.Logs() {
+		if strings.Contains(line, "Request completed") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected captured logs to contain the completed request, got %v", srv.Logs())
+	}
+}
+
+func TestStartWrapsRoutes(t *testing.T) {
+	t.Parallel()
+
+	srv := e2etest.Start(t)
+
+	// The v2 routes are only served through the versioning middleware.
+	resp := srv.PostJSON(t, "/api/v2/dispatcher/itinerary", map[string]any{
+		"tickets": [][]string{{"JFK", "LAX"}},
+	})
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+}
+
+func TestStartEnablesMemoryBackends(t *testing.T) {
+	t.Parallel()
+
+	srv := e2etest.Start(t)
+
+	for _, want := range []string{"miss", "hit"} {
+		resp := srv.PostJSON(t, "/api/v1/dispatcher/itinerary", map[string]any{
+			"tickets": [][]string{{"JFK", "LAX"}},
+		})
+		resp.Body.Close()
+
+		if got := resp.Header.Get("X-Dispatcher-Cache"); got != want {
+			t.Errorf("Expected cache outcome %q, got %q", want, got)
+		}
+	}
+}
+
+func TestStartOptionsAndClock(t *testing.T) {
+	t.Parallel()
+
+	srv := e2etest.Start(t, func(cfg *config.Config) {
+		cfg.Admin.Token = "admin-token"
+	})
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, srv.URL+"/api/v1/admin/support-bundle", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer admin-token")
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	// The bundle is named after the fake clock, not the system time.
+	want := `attachment; filename="dispatcher-support-20250101T000000Z.tar.gz"`
+	if got := resp.Header.Get("Content-Disposition"); got != want {
+		t.Errorf("Expected Content-Disposition %q, got %q", want, got)
+	}
+}
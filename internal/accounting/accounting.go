@@ -0,0 +1,114 @@
+// Package accounting measures the approximate resources consumed by a unit of work and
+// aggregates them per tenant for cost attribution.
+package accounting
+
+import (
+	"runtime/metrics"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	metricAllocBytes   = "/gc/heap/allocs:bytes"
+	metricAllocObjects = "/gc/heap/allocs:objects"
+)
+
+// Usage is the approximate resources consumed by a unit of work.
+//
+// CPUTime and allocations are process-wide deltas, of getrusage and runtime/metrics, so they include
+// concurrent work: they attribute costs approximately, without pinning the work to an OS thread.
+type Usage struct {
+	AllocBytes   uint64        `json:"alloc_bytes"`
+	AllocObjects uint64        `json:"alloc_objects"`
+	CPUTime      time.Duration `json:"cpu_time_ns"`
+	WallTime     time.Duration `json:"wall_time_ns"`
+}
+
+// Measure runs fn and returns the resources it consumed.
+func Measure(fn func()) Usage {
+	samples := []metrics.Sample{{Name: metricAllocBytes}, {Name: metricAllocObjects}}
+	metrics.Read(samples)
+	allocBytes, allocObjects := samples[0].Value.Uint64(), samples[1].Value.Uint64()
+
+	cpuStart := processCPUTime()
+	start := time.Now()
+
+	fn()
+
+	wall := time.Since(start)
+	cpu := processCPUTime() - cpuStart
+	metrics.Read(samples)
+
+	return Usage{
+		AllocBytes:   samples[0].Value.Uint64() - allocBytes,
+		AllocObjects: samples[1].Value.Uint64() - allocObjects,
+		CPUTime:      cpu,
+		WallTime:     wall,
+	}
+}
+
+// Totals are the aggregated usage of a tenant.
+type Totals struct {
+	Tenant       string        `json:"tenant"`
+	Requests     uint64        `json:"requests"`
+	AllocBytes   uint64        `json:"alloc_bytes"`
+	AllocObjects uint64        `json:"alloc_objects"`
+	CPUTime      time.Duration `json:"cpu_time_ns"`
+	WallTime     time.Duration `json:"wall_time_ns"`
+}
+
+const (
+	// MaxTenants caps the tenants a Tracker keeps totals of; the usage of tenants beyond it is added to the
+	// totals of OtherTenants. Tenants may come from an unauthenticated header, so their number is unbounded.
+	MaxTenants = 1000
+	// OtherTenants is the tenant the usage of the tenants beyond MaxTenants is recorded under.
+	OtherTenants = "_other"
+)
+
+// Tracker aggregates usage per tenant. It is safe for concurrent use.
+type Tracker struct {
+	totals map[string]*Totals
+	mu     sync.Mutex
+}
+
+func NewTracker() *Tracker {
+	return &Tracker{totals: make(map[string]*Totals)}
+}
+
+// Record adds the usage of a single request to the tenant totals.
+func (t *Tracker) Record(tenant string, usage Usage) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	totals, ok := t.totals[tenant]
+	if !ok && len(t.totals) >= MaxTenants {
+		tenant = OtherTenants
+		totals, ok = t.totals[tenant]
+	}
+	if !ok {
+		totals = &Totals{Tenant: tenant}
+		t.totals[tenant] = totals
+	}
+	totals.Requests++
+	totals.AllocBytes += usage.AllocBytes
+	totals.AllocObjects += usage.AllocObjects
+	totals.CPUTime += usage.CPUTime
+	totals.WallTime += usage.WallTime
+}
+
+// Snapshot returns the totals of every tenant sorted by tenant.
+func (t *Tracker) Snapshot() []Totals {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	out := make([]Totals, 0, len(t.totals))
+	for _, totals := range t.totals {
+		out = append(out, *totals)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Tenant < out[j].Tenant
+	})
+
+	return out
+}
@@ -0,0 +1,67 @@
+package accounting_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/accounting"
+)
+
+func TestMeasure(t *testing.T) {
+	t.Parallel()
+
+	// Large allocations bypass per-P caches, so they show up in runtime/metrics right away.
+	var sink [][]byte
+	usage := accounting.Measure(func() {
+		for range 4 {
+			sink = append(sink, make([]byte, 1<<20))
+		}
+	})
+	_ = sink
+
+	if usage.AllocBytes < 4<<20 {
+		t.Errorf("Measure() AllocBytes = %d; want at least %d", usage.AllocBytes, 4<<20)
+	}
+	if usage.WallTime <= 0 {
+		t.Errorf("Measure() WallTime = %v; want a positive duration", usage.WallTime)
+	}
+}
+
+func TestTracker(t *testing.T) {
+	t.Parallel()
+
+	tracker := accounting.NewTracker()
+	tracker.Record("b", accounting.Usage{AllocBytes: 10, CPUTime: time.Millisecond})
+	tracker.Record("a", accounting.Usage{AllocBytes: 1})
+	tracker.Record("b", accounting.Usage{AllocBytes: 5, CPUTime: time.Millisecond})
+
+	snapshot := tracker.Snapshot()
+	if len(snapshot) != 2 || snapshot[0].Tenant != "a" {
+		t.Fatalf("Snapshot() = %+v; want tenants a and b in order", snapshot)
+	}
+	if b := snapshot[1]; b.Requests != 2 || b.AllocBytes != 15 || b.CPUTime != 2*time.Millisecond {
+		t.Errorf("Snapshot() tenant b = %+v; want 2 requests, 15 bytes and 2ms", b)
+	}
+}
+
+func TestTrackerMaxTenants(t *testing.T) {
+	t.Parallel()
+
+	tracker := accounting.NewTracker()
+	for i := range accounting.MaxTenants + 10 {
+		tracker.Record(fmt.Sprintf("tenant-%04d", i), accounting.Usage{AllocBytes: 1})
+	}
+	tracker.Record("tenant-0000", accounting.Usage{AllocBytes: 1})
+
+	snapshot := tracker.Snapshot()
+	if len(snapshot) != accounting.MaxTenants+1 {
+		t.Fatalf("Snapshot() has %d tenants; want %d", len(snapshot), accounting.MaxTenants+1)
+	}
+	if first := snapshot[0]; first.Tenant != accounting.OtherTenants || first.Requests != 10 {
+		t.Errorf("Snapshot() first = %+v; want the 10 requests beyond the cap under %s", first, accounting.OtherTenants)
+	}
+	if known := snapshot[1]; known.Tenant != "tenant-0000" || known.Requests != 2 {
+		t.Errorf("Snapshot() second = %+v; want tenant-0000 with 2 requests", known)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package accounting
+
+import "time"
+
+// processCPUTime is not measured on platforms without getrusage.
+func processCPUTime() time.Duration {
+	return 0
+}
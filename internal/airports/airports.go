@@ -0,0 +1,176 @@
+package airports
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	// The timezone database is embedded so the timezones of the dataset load on hosts without one.
+	_ "time/tzdata"
+
+	"github.com/dsha256/dispatcher/internal/geo"
+)
+
+//go:embed airports.csv
+var dataset []byte
+
+var ErrInvalidDataset = errors.New("invalid airports dataset")
+
+// Airport is a single entry of the reference dataset.
+type Airport struct {
+	location *time.Location
+	IATA     string `json:"iata"`
+	ICAO     string `json:"icao"`
+	Name     string `json:"name"`
+	City     string `json:"city"`
+	Country  string `json:"country"`
+	// Timezone is the IANA timezone of the airport, e.g. "America/New_York", empty when the dataset has none.
+	Timezone  string  `json:"timezone,omitempty"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// Point returns the coordinates of the airport.
+func (a Airport) Point() geo.Point {
+	return geo.Point{Latitude: a.Latitude, Longitude: a.Longitude}
+}
+
+// Location returns the timezone of the airport, false when it is unknown.
+func (a Airport) Location() (*time.Location, bool) {
+	return a.location, a.location != nil
+}
+
+// Directory looks airports up by their IATA or ICAO code.
+type Directory struct {
+	byIATA map[string]Airport
+	byICAO map[string]Airport
+	// count is the number of airports, whichever codes they have.
+	count int
+}
+
+// Default returns the directory backed by the embedded dataset, a sample of major airports; services needing
+// every airport load a complete dataset with Open.
+func Default() *Directory {
+	directory, err := Load(bytes.NewReader(dataset))
+	if err != nil {
+		// The dataset is embedded at build time, so this is a programming error.
+		panic(err)
+	}
+
+	return directory
+}
+
+// Open loads the CSV dataset of the file at path, in the format of Load, e.g. an export of a complete
+// reference dataset.
+func Open(path string) (*Directory, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening airports dataset: %w", err)
+	}
+	defer f.Close()
+
+	directory, err := Load(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return directory, nil
+}
+
+// Load parses a CSV dataset with the header iata,icao,name,city,country,latitude,longitude and an optional
+// timezone column of IANA timezone names. Every record has the columns of the header, and an IATA or ICAO code,
+// or both, that no other record has.
+func Load(r io.Reader) (*Directory, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 0
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidDataset, err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%w: missing header", ErrInvalidDataset)
+	}
+	if columns := len(records[0]); columns != 7 && columns != 8 {
+		return nil, fmt.Errorf("%w: %d columns, want 7 or 8", ErrInvalidDataset, columns)
+	}
+
+	d := &Directory{
+		byIATA: make(map[string]Airport, len(records)-1),
+		byICAO: make(map[string]Airport, len(records)-1),
+	}
+	for line, record := range records[1:] {
+		lat, err := strconv.ParseFloat(record[5], 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: latitude: %w", ErrInvalidDataset, line+2, err)
+		}
+		lon, err := strconv.ParseFloat(record[6], 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: longitude: %w", ErrInvalidDataset, line+2, err)
+		}
+
+		airport := Airport{
+			IATA:      record[0],
+			ICAO:      record[1],
+			Name:      record[2],
+			City:      record[3],
+			Country:   record[4],
+			Latitude:  lat,
+			Longitude: lon,
+		}
+		if len(record) > 7 && record[7] != "" {
+			if airport.location, err = time.LoadLocation(record[7]); err != nil {
+				return nil, fmt.Errorf("%w: line %d: timezone: %w", ErrInvalidDataset, line+2, err)
+			}
+			airport.Timezone = record[7]
+		}
+		if airport.IATA == "" && airport.ICAO == "" {
+			return nil, fmt.Errorf("%w: line %d: no IATA or ICAO code", ErrInvalidDataset, line+2)
+		}
+		if _, ok := d.byIATA[airport.IATA]; ok {
+			return nil, fmt.Errorf("%w: line %d: duplicate IATA code %s", ErrInvalidDataset, line+2, airport.IATA)
+		}
+		if _, ok := d.byICAO[airport.ICAO]; ok {
+			return nil, fmt.Errorf("%w: line %d: duplicate ICAO code %s", ErrInvalidDataset, line+2, airport.ICAO)
+		}
+		if airport.IATA != "" {
+			d.byIATA[airport.IATA] = airport
+		}
+		if airport.ICAO != "" {
+			d.byICAO[airport.ICAO] = airport
+		}
+		d.count++
+	}
+
+	return d, nil
+}
+
+// Lookup finds an airport by its 3-letter IATA or 4-letter ICAO code, case-insensitively.
+func (d *Directory) Lookup(code string) (Airport, bool) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+
+	if airport, ok := d.byIATA[code]; ok {
+		return airport, true
+	}
+	airport, ok := d.byICAO[code]
+
+	return airport, ok
+}
+
+// Known reports whether the code is a known IATA or ICAO code.
+func (d *Directory) Known(code string) bool {
+	_, ok := d.Lookup(code)
+
+	return ok
+}
+
+// Len returns the number of airports in the directory, including those with only an IATA or an ICAO code.
+func (d *Directory) Len() int {
+	return d.count
+}
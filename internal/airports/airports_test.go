@@ -0,0 +1,130 @@
+package airports_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dsha256/dispatcher/internal/airports"
+)
+
+func TestDirectoryLookup(t *testing.T) {
+	t.Parallel()
+
+	directory := airports.Default()
+
+	tests := []struct {
+		code     string
+		expected string
+		known    bool
+	}{
+		{code: "JFK", expected: "JFK", known: true},
+		{code: "KJFK", expected: "JFK", known: true},
+		{code: " lhr ", expected: "LHR", known: true},
+		{code: "XXX", known: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			t.Parallel()
+
+			airport, ok := directory.Lookup(tt.code)
+			if ok != tt.known {
+				t.Fatalf("Lookup(%q) known = %v; want %v", tt.code, ok, tt.known)
+			}
+			if ok && airport.IATA != tt.expected {
+				t.Errorf("Lookup(%q) = %s; want %s", tt.code, airport.IATA, tt.expected)
+			}
+		})
+	}
+}
+
+func TestAirportLocation(t *testing.T) {
+	t.Parallel()
+
+	airport, _ := airports.Default().Lookup("DXB")
+	if location, ok := airport.Location(); !ok || location.String() != "Asia/Dubai" || airport.Timezone != "Asia/Dubai" {
+		t.Errorf("Location() = %v, %v; want Asia/Dubai", location, ok)
+	}
+
+	directory, err := airports.Load(strings.NewReader("iata,icao,name,city,country,latitude,longitude\nJFK,KJFK,JFK,New York,US,40.6413,-73.7781\n"))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if airport, _ = directory.Lookup("JFK"); airport.Timezone != "" {
+		t.Errorf("Timezone = %q; want none without the column", airport.Timezone)
+	}
+	if _, ok := airport.Location(); ok {
+		t.Error("Location() known without the column")
+	}
+}
+
+func TestLoadInvalidDataset(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		dataset string
+	}{
+		{name: "Latitude", dataset: "iata,icao,name,city,country,latitude,longitude\nJFK,KJFK,JFK,New York,US,north,-73.7781\n"},
+		{name: "Timezone", dataset: "iata,icao,name,city,country,latitude,longitude,timezone\nJFK,KJFK,JFK,New York,US,40.6413,-73.7781,America/Gotham\n"},
+		{name: "Columns", dataset: "iata,icao,name\nJFK,KJFK,JFK\n"},
+		{name: "No code", dataset: "iata,icao,name,city,country,latitude,longitude\n,,Nowhere,Nowhere,US,0,0\n"},
+		{
+			name:    "Duplicate code",
+			dataset: "iata,icao,name,city,country,latitude,longitude\nJFK,KJFK,JFK,New York,US,40.6413,-73.7781\nJFK,,JFK,New York,US,40.6413,-73.7781\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := airports.Load(strings.NewReader(tt.dataset))
+			if !errors.Is(err, airports.ErrInvalidDataset) {
+				t.Errorf("Load() error = %v; want %v", err, airports.ErrInvalidDataset)
+			}
+		})
+	}
+}
+
+func TestDirectoryLen(t *testing.T) {
+	t.Parallel()
+
+	directory, err := airports.Load(strings.NewReader("iata,icao,name,city,country,latitude,longitude\n" +
+		"JFK,KJFK,John F. Kennedy,New York,US,40.6413,-73.7781\n" +
+		"XIY,,Xi'an Xianyang,Xi'an,CN,34.4471,108.7516\n" +
+		",EGLW,London Heliport,London,GB,51.4700,-0.1790\n"))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got := directory.Len(); got != 3 {
+		t.Errorf("Len() = %d; want 3, counting the airports with a single code", got)
+	}
+	if !directory.Known("XIY") || !directory.Known("EGLW") {
+		t.Error("Expected the airports with a single code to be known")
+	}
+}
+
+func TestOpen(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "airports.csv")
+	dataset := "iata,icao,name,city,country,latitude,longitude,timezone\nXIY,ZLXY,Xi'an Xianyang,Xi'an,CN,34.4471,108.7516,Asia/Shanghai\n"
+	if err := os.WriteFile(path, []byte(dataset), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	directory, err := airports.Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if airport, ok := directory.Lookup("ZLXY"); !ok || airport.Timezone != "Asia/Shanghai" {
+		t.Errorf("Lookup(ZLXY) = %+v, %v; want Xi'an Xianyang", airport, ok)
+	}
+	if _, err = airports.Open(filepath.Join(t.TempDir(), "missing.csv")); err == nil {
+		t.Error("Expected an error for a missing dataset")
+	}
+}
@@ -0,0 +1,57 @@
+package app
+
+import (
+	"github.com/dsha256/dispatcher/internal/config"
+	"github.com/dsha256/dispatcher/internal/handler"
+	"github.com/dsha256/dispatcher/internal/inspector"
+	"github.com/dsha256/dispatcher/internal/logging"
+	"github.com/dsha256/dispatcher/internal/middleware"
+	"github.com/dsha256/dispatcher/internal/reporting"
+	"github.com/dsha256/dispatcher/internal/shedding"
+)
+
+// withOperations returns the handler options with the controls and insights operators use: the request
+// inspector, the log level, the panic count, the load shedding limit, the debug routes and the admin tokens
+// authenticating their reads and changes. It also returns the shedder, whose cap is reloadable.
+func withOperations(
+	cfg *config.Config, logLevel *logging.Level, recovery *middleware.Recovery, opts []handler.Option,
+) ([]handler.Option, *shedding.Shedder, error) {
+	shedder, err := shedding.New(cfg.Shedding)
+	if err != nil {
+		return opts, nil, err
+	}
+
+	var requestInspector *inspector.Inspector
+	if cfg.Inspector.Enabled {
+		requestInspector = inspector.New(cfg.Inspector.Buffer)
+	}
+
+	opts = append(opts,
+		handler.WithInspector(requestInspector),
+		handler.WithLogLevel(logLevel),
+		handler.WithRecovery(recovery),
+		handler.WithShedding(shedder),
+		handler.WithAdminToken(cfg.Admin.Token),
+		handler.WithAdminReadToken(cfg.Admin.ReadToken),
+		handler.WithMaintenance(cfg.Maintenance),
+	)
+	if cfg.Debug.Enabled {
+		opts = append(opts, handler.WithDebug())
+	}
+
+	return opts, shedder, nil
+}
+
+// newRecovery returns the Recovery reporting panics to the configured error tracker, and publishes its panic
+// count as the "panics" expvar variable.
+func (a *App) newRecovery() (*middleware.Recovery, error) {
+	reporter, err := reporting.New(a.cfg.Recovery)
+	if err != nil {
+		return nil, err
+	}
+
+	recovery := middleware.NewRecovery(reporter)
+	a.publish("panics", func() any { return recovery.Panics() })
+
+	return recovery, nil
+}
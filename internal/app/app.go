@@ -0,0 +1,250 @@
+// Package app wires the dispatcher service from its configuration: the dispatcher, the handler with the
+// dependencies the configuration enables, and the middlewares wrapping its routes. The service's binary serves
+// the routes of an App running on the system clock; black-box tests serve those of one running on a fake clock.
+package app
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/dsha256/dispatcher/internal/accounting"
+	"github.com/dsha256/dispatcher/internal/audit"
+	"github.com/dsha256/dispatcher/internal/blackout"
+	"github.com/dsha256/dispatcher/internal/clock"
+	"github.com/dsha256/dispatcher/internal/config"
+	"github.com/dsha256/dispatcher/internal/degradation"
+	"github.com/dsha256/dispatcher/internal/emissions"
+	"github.com/dsha256/dispatcher/internal/handler"
+	"github.com/dsha256/dispatcher/internal/idempotency"
+	"github.com/dsha256/dispatcher/internal/jobs"
+	"github.com/dsha256/dispatcher/internal/limits"
+	"github.com/dsha256/dispatcher/internal/logbuffer"
+	"github.com/dsha256/dispatcher/internal/logging"
+	"github.com/dsha256/dispatcher/internal/messages"
+	"github.com/dsha256/dispatcher/internal/middleware"
+	"github.com/dsha256/dispatcher/internal/mirror"
+	"github.com/dsha256/dispatcher/internal/nats"
+	"github.com/dsha256/dispatcher/internal/shedding"
+	"github.com/dsha256/dispatcher/internal/store/postgres"
+	"github.com/dsha256/dispatcher/internal/store/redis"
+	"github.com/dsha256/dispatcher/internal/support"
+	"github.com/dsha256/dispatcher/internal/ticketcsv"
+)
+
+// App is the wired service. The reloadable components are exported for the configuration reloads.
+type App struct {
+	// Routes are the handler's routes wrapped in the middlewares applying to every request.
+	Routes   http.Handler
+	Handler  *handler.Handler
+	Bundler  *support.Bundler
+	Limits   *limits.Checker
+	Timeouts *middleware.ReloadableTimeouts
+	// Shedder is nil when load shedding is disabled.
+	Shedder *shedding.Shedder
+
+	logger *slog.Logger
+	clock  clock.Clock
+	cfg    *config.Config
+	// vars are the expvar variables published by Publish.
+	vars map[string]expvar.Func
+
+	natsClient    *nats.Client
+	redisClient   *redis.Client
+	postgresStore *postgres.Store
+	jobStore      *jobs.Store
+	auditTrail    *audit.Trail
+}
+
+// New wires the service configured by cfg, timing everything with clk. The logger writes at logLevel, and
+// logs keeps its recent lines for the support bundles. It fails when a setting is invalid or a dependency
+// cannot be opened, closing those already opened.
+func New(
+	logger *slog.Logger, clk clock.Clock, cfg *config.Config, logLevel *logging.Level, logs *logbuffer.Buffer,
+) (*App, error) {
+	a := &App{logger: logger, clock: clk, cfg: cfg, vars: make(map[string]expvar.Func)}
+	if err := a.wire(logLevel, logs); err != nil {
+		a.Close(context.Background())
+
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// wire builds the components of the service in the order they depend on each other.
+func (a *App) wire(logLevel *logging.Level, logs *logbuffer.Buffer) error {
+	cfg := a.cfg
+	newDispatcher, airportDirectory, err := configureDispatcher(cfg)
+	if err != nil {
+		return fmt.Errorf("invalid dispatcher configuration: %w", err)
+	}
+
+	a.Bundler = support.NewBundler(a.clock, cfg, logs)
+
+	var emissionsCalculator *emissions.Calculator
+	if cfg.Emissions.Enabled {
+		if emissionsCalculator, err = emissions.NewCalculator(cfg.Emissions.Coefficients()); err != nil {
+			return fmt.Errorf("invalid emissions configuration: %w", err)
+		}
+	}
+
+	var ladder *degradation.Ladder
+	if cfg.Degradation.Enabled {
+		if ladder, err = degradation.NewLadder(a.logger, cfg.Degradation.Capacity, cfg.Degradation.Rungs()); err != nil {
+			return fmt.Errorf("invalid degradation configuration: %w", err)
+		}
+	}
+
+	if a.Limits, err = limits.NewChecker(cfg.Limits); err != nil {
+		return fmt.Errorf("invalid limits configuration: %w", err)
+	}
+
+	csvMapping := ticketcsv.DefaultMapping()
+	if cfg.CSV.Columns != "" {
+		if csvMapping, err = ticketcsv.ParseMapping(cfg.CSV.Columns); err != nil {
+			return fmt.Errorf("invalid CSV configuration: %w", err)
+		}
+	}
+
+	if err = cfg.Pricing.ExchangeRates.Validate(); err != nil {
+		return fmt.Errorf("invalid pricing configuration: %w", err)
+	}
+
+	catalog, err := messages.New(cfg.Messages)
+	if err != nil {
+		return fmt.Errorf("invalid messages configuration: %w", err)
+	}
+	translator, err := newTranslator(cfg.I18n)
+	if err != nil {
+		return fmt.Errorf("invalid i18n configuration in %q: %w", cfg.I18n.Dir, err)
+	}
+
+	recovery, err := a.newRecovery()
+	if err != nil {
+		return fmt.Errorf("invalid recovery configuration for tracker %q: %w", cfg.Recovery.Tracker, err)
+	}
+
+	idempotencyKeys, err := idempotency.New(a.clock, cfg.Idempotency)
+	if err != nil {
+		return fmt.Errorf("invalid idempotency configuration: %w", err)
+	}
+
+	var handlerOpts []handler.Option
+	handlerOpts, a.Shedder, err = withOperations(cfg, logLevel, recovery, []handler.Option{
+		handler.WithDegradation(ladder),
+		handler.WithLimits(a.Limits),
+		handler.WithCSVMapping(csvMapping),
+		handler.WithExchangeRates(cfg.Pricing.ExchangeRates),
+		handler.WithMessages(catalog),
+		handler.WithTranslator(translator),
+		handler.WithClock(a.clock),
+		handler.WithAccessLog(cfg.AccessLog),
+		handler.WithTenants(cfg.Tenants),
+		handler.WithIdempotency(idempotencyKeys),
+		handler.WithBlackouts(blackout.NewStore(blackout.WithLimits(cfg.Blackout.MaxCalendars, cfg.Blackout.MaxDates))),
+		handler.WithAirports(airportDirectory),
+		handler.WithEmissions(emissionsCalculator),
+		handler.WithAccounting(accounting.NewTracker()),
+	})
+	if err != nil {
+		return fmt.Errorf("invalid load shedding configuration: %w", err)
+	}
+
+	handlerOpts = a.withStrategies(handlerOpts)
+	if a.redisClient, handlerOpts, err = a.newCache(handlerOpts); err != nil {
+		return fmt.Errorf("invalid cache configuration for backend %q: %w", cfg.Cache.Backend, err)
+	}
+	if a.jobStore, handlerOpts, err = a.withIngestion(a.redisClient, handlerOpts); err != nil {
+		return fmt.Errorf("invalid ingestion configuration: %w", err)
+	}
+	if a.postgresStore, handlerOpts, err = a.openItineraryStore(handlerOpts); err != nil {
+		return fmt.Errorf("failed to open itinerary storage with backend %q: %w", cfg.Storage.Backend, err)
+	}
+	if a.auditTrail, handlerOpts, err = a.newAuditTrail(handlerOpts); err != nil {
+		return fmt.Errorf("invalid audit configuration for sink %q: %w", cfg.Audit.Sink, err)
+	}
+	if a.natsClient, handlerOpts, err = a.newMessaging(handlerOpts); err != nil {
+		return fmt.Errorf("invalid messaging configuration: %w", err)
+	}
+
+	a.Handler = handler.New(a.logger, newDispatcher, a.Bundler, handlerOpts...)
+
+	mux := http.NewServeMux()
+	a.Handler.RegisterRoutes(mux)
+	a.Timeouts = middleware.NewReloadableTimeouts(cfg.Server.Timeouts)
+	a.Routes = a.wrapRoutes(ladder, a.newCanary(), mux)
+
+	return nil
+}
+
+// Publish publishes the stats of the components as expvar variables. It may be called once per process: expvar
+// variables cannot be replaced.
+func (a *App) Publish() {
+	for name, value := range a.vars {
+		expvar.Publish(name, value)
+	}
+}
+
+// publish adds the variable to those published by Publish.
+func (a *App) publish(name string, value func() any) {
+	a.vars[name] = value
+}
+
+// Close waits for the running jobs and the queued audit records until ctx is done, and closes the
+// connections to Redis and Postgres.
+func (a *App) Close(ctx context.Context) {
+	_ = a.jobStore.Close(ctx)
+	_ = a.auditTrail.Close()
+	if a.redisClient != nil {
+		_ = a.redisClient.Close()
+	}
+	if a.postgresStore != nil {
+		_ = a.postgresStore.Close()
+	}
+}
+
+// newCanary returns the mirror of requests to the canary, disabled without a canary URL.
+func (a *App) newCanary() *mirror.Mirror {
+	cfg := a.cfg.Mirror
+	canary := mirror.New(a.logger, mirror.Config{
+		CanaryURL:   cfg.CanaryURL,
+		Paths:       cfg.Paths,
+		Percentage:  cfg.Percentage,
+		Timeout:     cfg.Timeout,
+		MaxInFlight: cfg.MaxInFlight,
+	})
+	if canary.Enabled() {
+		a.logger.Info("Mirroring requests to canary", "canary_url", cfg.CanaryURL, "percentage", cfg.Percentage)
+	}
+
+	return canary
+}
+
+// wrapRoutes wraps the routes in the middlewares applying to every request, outermost first: CORS, shedding
+// by the degradation ladder, compression, response encodings, output formatting, API versioning, timeouts,
+// request limits, signature verification and mirroring. Versioning comes before timeouts, so v2 requests get the
+// timeouts of their v1 routes, and after encodings and formatting, which encode and reshape the converted JSON
+// responses before they are compressed. Signatures are verified over the decompressed body, once it is capped by
+// the limits, and before the handler authenticates tenants and checks idempotency keys, so requests with an
+// invalid signature are neither stored nor replayed.
+func (a *App) wrapRoutes(ladder *degradation.Ladder, canary *mirror.Mirror, mux http.Handler) http.Handler {
+	cfg := a.cfg
+	chain := middleware.NewChain(func(next http.Handler) http.Handler { return middleware.CORSMiddleware(cfg.CORS, next) })
+	if ladder != nil {
+		chain.Use(ladder.Middleware)
+	}
+
+	return chain.Use(
+		func(next http.Handler) http.Handler { return middleware.CompressionMiddleware(cfg.Compression, next) },
+		middleware.EncodingMiddleware,
+		middleware.FormatMiddleware,
+		func(next http.Handler) http.Handler { return middleware.VersionMiddleware(cfg.Versioning, next) },
+		func(next http.Handler) http.Handler { return middleware.TimeoutMiddleware(a.Timeouts, next) },
+		a.Limits.Middleware,
+		middleware.NewSignatures(cfg.Signing, a.clock).Middleware,
+		canary.Middleware,
+	).Then(mux)
+}
@@ -0,0 +1,82 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/dsha256/dispatcher/internal/audit"
+	"github.com/dsha256/dispatcher/internal/handler"
+	"github.com/dsha256/dispatcher/internal/store/postgres"
+)
+
+// defaultAuditFile is the file of the file audit sink when none is configured.
+const defaultAuditFile = "audit.jsonl"
+
+// newAuditTrail returns the audit trail writing to the configured sink, nil when auditing is disabled, and
+// the handler options recording the reconstruction requests in it. The trail's chain is named after the host
+// unless a node is configured, and the records it drops are published as the "audit_dropped" expvar variable.
+func (a *App) newAuditTrail(opts []handler.Option) (*audit.Trail, []handler.Option, error) {
+	if !a.cfg.Audit.Enabled {
+		return nil, opts, nil
+	}
+
+	auditCfg := a.cfg.Audit
+	if auditCfg.Node == "" {
+		auditCfg.Node, _ = os.Hostname()
+	}
+
+	var sink audit.Sink
+	switch auditCfg.Sink {
+	case "", audit.SinkFile:
+		if auditCfg.File == "" {
+			auditCfg.File = defaultAuditFile
+		}
+		file, err := audit.OpenFile(auditCfg.File)
+		if err != nil {
+			return nil, opts, err
+		}
+		sink = file
+	case audit.SinkPostgres:
+		postgresStore, err := postgres.Open(a.cfg.Postgres)
+		if err != nil {
+			return nil, opts, err
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), migrationTimeout)
+		defer cancel()
+
+		if err = postgresStore.Migrate(ctx); err != nil {
+			_ = postgresStore.Close()
+
+			return nil, opts, err
+		}
+		sink = postgres.NewAudit(postgresStore)
+	case audit.SinkKafka:
+		kafka, err := audit.NewKafkaSink(&http.Client{}, auditCfg.Kafka)
+		if err != nil {
+			return nil, opts, err
+		}
+		sink = kafka
+	default:
+		return nil, opts, fmt.Errorf("%w %q", audit.ErrUnknownSink, auditCfg.Sink)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), migrationTimeout)
+	defer cancel()
+
+	trail, err := audit.New(ctx, a.logger, a.clock, sink, auditCfg)
+	if err != nil {
+		if closer, ok := sink.(io.Closer); ok {
+			_ = closer.Close()
+		}
+
+		return nil, opts, err
+	}
+
+	a.publish("audit_dropped", func() any { return trail.Dropped() })
+
+	return trail, append(opts, handler.WithAuditTrail(trail)), nil
+}
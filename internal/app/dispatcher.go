@@ -0,0 +1,76 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/dsha256/dispatcher/internal/airports"
+	"github.com/dsha256/dispatcher/internal/config"
+	"github.com/dsha256/dispatcher/internal/dispatcher"
+	"github.com/dsha256/dispatcher/internal/handler"
+	"github.com/dsha256/dispatcher/internal/shadow"
+)
+
+// configureDispatcher returns the dispatcher configured by cfg and the airports directory it validates airport
+// codes against. It fails when the airports dataset cannot be loaded, the configured defaults or shadow strategy
+// are unknown, or the aliases conflict.
+func configureDispatcher(cfg *config.Config) (*dispatcher.Dispatcher, *airports.Directory, error) {
+	directory := airports.Default()
+	if cfg.Airports.Dataset != "" {
+		var err error
+		if directory, err = airports.Open(cfg.Airports.Dataset); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	d := dispatcher.New(
+		dispatcher.WithMinLayover(cfg.Dispatcher.MinLayover),
+		dispatcher.WithDuplicateTolerance(cfg.Dispatcher.AllowDuplicates),
+		dispatcher.WithAirportValidation(directory.Known, cfg.Airports.Strict),
+		dispatcher.WithMaxTickets(cfg.Limits.MaxTickets),
+		dispatcher.WithDefaultStrategy(dispatcher.Strategy(cfg.Dispatcher.DefaultStrategy)),
+		dispatcher.WithDefaultTieBreak(dispatcher.TieBreak(cfg.Dispatcher.TieBreak)),
+		dispatcher.WithCodeNormalization(cfg.Dispatcher.NormalizeCodes, cfg.Dispatcher.Aliases),
+	)
+	if _, err := d.Reconstructor(""); err != nil {
+		return nil, nil, fmt.Errorf("default_strategy %q: %w", cfg.Dispatcher.DefaultStrategy, err)
+	}
+	if _, err := d.TieBreak(""); err != nil {
+		return nil, nil, fmt.Errorf("tie_break %q: %w", cfg.Dispatcher.TieBreak, err)
+	}
+	if cfg.Shadow.Enabled && cfg.Shadow.Strategy != "" {
+		if _, err := d.Reconstructor(dispatcher.Strategy(cfg.Shadow.Strategy)); err != nil {
+			return nil, nil, fmt.Errorf("shadow strategy %q: %w", cfg.Shadow.Strategy, err)
+		}
+	}
+	if err := dispatcher.CheckAliases(cfg.Dispatcher.Aliases); err != nil {
+		return nil, nil, fmt.Errorf("aliases: %w", err)
+	}
+
+	return d, directory, nil
+}
+
+// withStrategies returns the handler options with the shadow comparison of reconstructions and, when enabled,
+// the selection of strategies by header.
+func (a *App) withStrategies(opts []handler.Option) []handler.Option {
+	opts = append(opts, handler.WithShadow(a.newShadow()))
+	if selection := a.cfg.Dispatcher.StrategyHeader; selection.Enabled {
+		opts = append(opts, handler.WithStrategySelection(handler.StrategySelection{
+			Strategies: selection.Strategies,
+			Tenants:    selection.Tenants,
+			Clients:    selection.Clients,
+		}))
+	}
+
+	return opts
+}
+
+// newShadow returns the shadow comparing reconstructions with those of the configured strategy, nil when
+// disabled, and publishes its counts as the "shadow" expvar variable.
+func (a *App) newShadow() *shadow.Shadow {
+	s := shadow.New(a.logger, a.cfg.Shadow)
+	if s != nil {
+		a.publish("shadow", func() any { return s.Stats() })
+	}
+
+	return s
+}
@@ -0,0 +1,20 @@
+package app
+
+import (
+	"github.com/dsha256/dispatcher/internal/config"
+	"github.com/dsha256/dispatcher/internal/i18n"
+)
+
+// newTranslator loads the embedded translations and those of the configured directory, if any; it returns nil
+// when translation is disabled.
+func newTranslator(cfg config.I18n) (*i18n.Translator, error) {
+	if !cfg.Enabled {
+		return nil, nil //nolint:nilnil // Translation is disabled.
+	}
+	loaders := []i18n.Loader{i18n.Embedded()}
+	if cfg.Dir != "" {
+		loaders = append(loaders, i18n.Dir(cfg.Dir))
+	}
+
+	return i18n.New(loaders...)
+}
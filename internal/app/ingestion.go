@@ -0,0 +1,43 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dsha256/dispatcher/internal/handler"
+	"github.com/dsha256/dispatcher/internal/jobs"
+	"github.com/dsha256/dispatcher/internal/remote"
+	"github.com/dsha256/dispatcher/internal/store/redis"
+)
+
+var errUnknownJobsBackend = errors.New("unknown jobs backend")
+
+// withIngestion returns the job store, nil when jobs are disabled, and the handler options with the ways of
+// sending tickets besides the request body: uploaded files, reconstructed as jobs when large, and files
+// referenced by URL. The Redis client is the jobs' backend when it is configured as such.
+func (a *App) withIngestion(redisClient *redis.Client, opts []handler.Option) (*jobs.Store, []handler.Option, error) {
+	var jobOpts []jobs.Option
+	switch a.cfg.Jobs.Backend {
+	case "", jobs.BackendMemory:
+	case jobs.BackendRedis:
+		if redisClient != nil {
+			jobOpts = append(jobOpts, jobs.WithBackend(redisClient))
+		}
+	default:
+		return nil, opts, fmt.Errorf("%w %q", errUnknownJobsBackend, a.cfg.Jobs.Backend)
+	}
+
+	jobStore, err := jobs.New(a.clock, a.cfg.Jobs, jobOpts...)
+	if err != nil {
+		return nil, opts, err
+	}
+	sources, err := remote.New(a.clock, a.cfg.Sources)
+	if err != nil {
+		return nil, opts, err
+	}
+
+	return jobStore, append(opts,
+		handler.WithUploads(handler.Upload{MaxBytes: a.cfg.Upload.MaxBytes, AsyncBytes: a.cfg.Upload.AsyncBytes}, jobStore),
+		handler.WithSources(sources),
+	), nil
+}
@@ -0,0 +1,130 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/breaker"
+	"github.com/dsha256/dispatcher/internal/cache"
+	"github.com/dsha256/dispatcher/internal/config"
+	"github.com/dsha256/dispatcher/internal/dispatcher"
+	"github.com/dsha256/dispatcher/internal/handler"
+	"github.com/dsha256/dispatcher/internal/health"
+	"github.com/dsha256/dispatcher/internal/jobs"
+	"github.com/dsha256/dispatcher/internal/store"
+	"github.com/dsha256/dispatcher/internal/store/postgres"
+	"github.com/dsha256/dispatcher/internal/store/redis"
+)
+
+var (
+	errUnknownStorageBackend = errors.New("unknown storage backend")
+	errUnknownCacheBackend   = errors.New("unknown cache backend")
+)
+
+// migrationTimeout bounds applying the schema migrations at startup.
+const migrationTimeout = 30 * time.Second
+
+// openItineraryStore opens the itinerary store of the configured backend, when storage is enabled, migrates
+// its schema, and returns the handler options with the store and its readiness check. The postgres store is
+// also returned on its own, to be closed on shutdown, when it is the backend.
+func (a *App) openItineraryStore(opts []handler.Option) (*postgres.Store, []handler.Option, error) {
+	if !a.cfg.Storage.Enabled {
+		return nil, opts, nil
+	}
+
+	switch a.cfg.Storage.Backend {
+	case "", config.StorageBackendMemory:
+		return nil, append(opts, handler.WithItineraryStore(store.NewMemory(a.clock))), nil
+	case config.StorageBackendPostgres:
+		postgresStore, err := postgres.Open(a.cfg.Postgres)
+		if err != nil {
+			return nil, opts, err
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), migrationTimeout)
+		defer cancel()
+
+		if err = postgresStore.Migrate(ctx); err != nil {
+			_ = postgresStore.Close()
+
+			return nil, opts, err
+		}
+
+		postgresBreaker := a.newBreaker("postgres", a.cfg.CircuitBreaker)
+
+		return postgresStore, append(opts,
+			handler.WithItineraryStore(breaker.Itineraries(postgresBreaker, postgresStore)),
+			handler.WithReadinessCheck("postgres", postgresStore.Ping, dependencyCheck(postgresBreaker)...),
+		), nil
+	default:
+		return nil, opts, fmt.Errorf("%w %q", errUnknownStorageBackend, a.cfg.Storage.Backend)
+	}
+}
+
+// newCache returns the Redis client, nil unless the cache or the jobs use Redis, and the handler options with the
+// client's readiness check and the result cache, when caching is enabled.
+func (a *App) newCache(opts []handler.Option) (*redis.Client, []handler.Option, error) {
+	redisClient, redisBreaker, opts, err := a.openRedis(opts)
+	if err != nil || !a.cfg.Cache.Enabled {
+		return redisClient, opts, err
+	}
+
+	var cacheOpts []cache.Option[*dispatcher.Result]
+	switch a.cfg.Cache.Backend {
+	case "", config.CacheBackendMemory:
+	case config.CacheBackendRedis:
+		cacheOpts = append(cacheOpts, cache.WithStore[*dispatcher.Result](breaker.Cache(redisBreaker, redisClient)))
+	default:
+		_ = redisClient.Close()
+
+		return nil, opts, fmt.Errorf("%w %q", errUnknownCacheBackend, a.cfg.Cache.Backend)
+	}
+
+	return redisClient, append(opts, handler.WithCache(cache.New(a.clock, a.cfg.Cache.TTL, a.cfg.Cache.MaxEntries, cacheOpts...))), nil
+}
+
+// openRedis returns the Redis client and its circuit breaker, nil unless the cache or the jobs use Redis, and
+// the handler options with the client's readiness check.
+func (a *App) openRedis(opts []handler.Option) (*redis.Client, *breaker.Breaker, []handler.Option, error) {
+	cacheRedis := a.cfg.Cache.Enabled && a.cfg.Cache.Backend == config.CacheBackendRedis
+	jobsRedis := a.cfg.Jobs.Enabled && a.cfg.Jobs.Backend == jobs.BackendRedis
+	if !cacheRedis && !jobsRedis {
+		return nil, nil, opts, nil
+	}
+
+	redisClient, err := redis.New(a.cfg.Redis)
+	if err != nil {
+		return nil, nil, opts, err
+	}
+	redisBreaker := a.newBreaker("redis", a.cfg.CircuitBreaker)
+	var checkOpts []health.Option
+	if !jobsRedis {
+		// Jobs are not behind the breaker: the service does not degrade without Redis when they use it.
+		checkOpts = dependencyCheck(redisBreaker)
+	}
+
+	return redisClient, redisBreaker, append(opts, handler.WithReadinessCheck("redis", redisClient.Ping, checkOpts...)), nil
+}
+
+// newBreaker returns the circuit breaker of the named dependency, nil when breakers are disabled, and
+// publishes its stats as the "breaker_<name>" expvar variable.
+func (a *App) newBreaker(name string, cfg breaker.Config) *breaker.Breaker {
+	b := breaker.New(a.logger, a.clock, name, cfg)
+	if b != nil {
+		a.publish("breaker_"+name, func() any { return b.Stats() })
+	}
+
+	return b
+}
+
+// dependencyCheck returns the options of the readiness check of a dependency: behind a circuit breaker, the
+// service degrades without it, so its failure does not make the service unready.
+func dependencyCheck(b *breaker.Breaker) []health.Option {
+	if b == nil {
+		return nil
+	}
+
+	return []health.Option{health.NonCritical()}
+}
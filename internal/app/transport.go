@@ -0,0 +1,78 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/dsha256/dispatcher/internal/config"
+	"github.com/dsha256/dispatcher/internal/events"
+	"github.com/dsha256/dispatcher/internal/handler"
+	"github.com/dsha256/dispatcher/internal/health"
+	"github.com/dsha256/dispatcher/internal/nats"
+)
+
+var errUnknownEventPublisher = errors.New("unknown event publisher")
+
+// natsPath is the endpoint answering the requests received over NATS.
+const natsPath = "/api/v1/dispatcher/itinerary"
+
+// newMessaging returns the NATS client, nil unless requests are answered or events published over NATS, and
+// the handler options with its readiness check and the event publisher.
+func (a *App) newMessaging(opts []handler.Option) (*nats.Client, []handler.Option, error) {
+	var client *nats.Client
+	if a.cfg.NATS.Enabled || (a.cfg.Events.Enabled && a.cfg.Events.Publisher == config.EventPublisherNATS) {
+		var err error
+		if client, err = nats.New(a.logger, a.cfg.NATS.Config); err != nil {
+			return nil, opts, err
+		}
+		// Events are best-effort, so NATS only carrying them does not make the service unready.
+		var checkOpts []health.Option
+		if !a.cfg.NATS.Enabled {
+			checkOpts = append(checkOpts, health.NonCritical())
+		}
+		opts = append(opts, handler.WithReadinessCheck("nats", client.Ping, checkOpts...))
+	}
+
+	if a.cfg.Events.Enabled {
+		switch a.cfg.Events.Publisher {
+		case "", config.EventPublisherLog:
+			opts = append(opts, handler.WithEventPublisher(events.NewLog(a.logger)))
+		case config.EventPublisherNATS:
+			opts = append(opts, handler.WithEventPublisher(events.NewNATS(client, a.cfg.Events.Subject)))
+		default:
+			return nil, opts, fmt.Errorf("%w %q", errUnknownEventPublisher, a.cfg.Events.Publisher)
+		}
+	}
+
+	return client, opts, nil
+}
+
+// ServeNATS connects the NATS client, answering the requests received on the configured subject with the
+// routes, like HTTP requests to natsPath, when NATS is enabled, until the returned function is called; it
+// returns once the requests in flight are answered.
+func (a *App) ServeNATS() func() {
+	client, cfg := a.natsClient, a.cfg.NATS
+	if client == nil {
+		return func() {}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if cfg.Enabled {
+		if err := client.Subscribe(cfg.Subject, cfg.Queue, nats.Bridge(client, a.Routes, natsPath)); err != nil {
+			a.logger.Error("Failed to subscribe to nats", "error", err, "subject", cfg.Subject)
+		}
+		a.logger.Info("Answering requests over nats", "addr", cfg.Addr, "subject", cfg.Subject, "queue", cfg.Queue)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		client.Run(ctx)
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
@@ -0,0 +1,390 @@
+// Package audit keeps a tamper-evident trail of reconstruction requests for compliance, apart from the logs:
+// who asked (tenant, client and address), for which ticket set, how large it was, how it was answered and how
+// long it took. Records never carry tickets.
+//
+// Each node chains its records: a record carries the hash of the previous one and its own HMAC-SHA256 hash, so
+// a record altered, removed or inserted breaks the chain (see Verify). Records the trail loses, dropped from a
+// full queue or rejected by the sink, are reported by a gap record, and pruning only removes the records before
+// a checkpoint record, which then starts the chain. Records are written to a Sink, e.g. a file, Postgres or
+// Kafka.
+package audit
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/clock"
+)
+
+var (
+	ErrTampered    = errors.New("audit trail tampered with")
+	ErrUnknownSink = errors.New("unknown audit sink")
+	ErrMissingKey  = errors.New("audit key is required")
+)
+
+// Kinds of records.
+const (
+	// KindRequest records an audited request.
+	KindRequest = ""
+	// KindGap reports the records lost since the previous record, in Lost.
+	KindGap = "gap"
+	// KindCheckpoint marks where pruning may cut the chain: the records before it are pruned once it is older
+	// than the retention.
+	KindCheckpoint = "checkpoint"
+)
+
+// Sinks.
+const (
+	SinkFile     = "file"
+	SinkPostgres = "postgres"
+	SinkKafka    = "kafka"
+)
+
+const (
+	defaultBuffer = 1024
+	// queueTimeout bounds how long a request waits for room in a full queue before its record is dropped.
+	queueTimeout = time.Second
+	// writeTimeout bounds the write of a record to the sink; a failed write is attempted writeAttempts times,
+	// retryDelay apart.
+	writeTimeout  = 5 * time.Second
+	writeAttempts = 3
+	retryDelay    = 100 * time.Millisecond
+	// pruneInterval is how often checkpoints are written and records older than the retention are pruned.
+	pruneInterval = time.Hour
+)
+
+type Config struct {
+	// Sink is where records are written: "file" (the default), "postgres" through the postgres section, or
+	// "kafka" through a Kafka REST proxy.
+	Sink string `json:"sink" yaml:"sink"`
+	// File is the JSON Lines file of the file sink, "audit.jsonl" when empty.
+	File  string `json:"file"  yaml:"file"`
+	Kafka Kafka  `json:"kafka" yaml:"kafka"`
+	// Key signs the chain with HMAC-SHA256, so that only its holders can rebuild it. It is required.
+	Key string `json:"-" yaml:"key"`
+	// Node names the chain of this replica, the host name when empty.
+	Node string `json:"node" yaml:"node"`
+	// Retention is how long records are kept before they are pruned, up to the latest checkpoint older than
+	// it; 0 keeps them. Kafka topics have their own.
+	Retention time.Duration `json:"retention" yaml:"retention"`
+	// Buffer is the number of records queued for the sink, 1024 when 0; requests wait up to a second for room
+	// beyond it, then their records are dropped and reported by a gap record.
+	Buffer  int  `json:"buffer"  yaml:"buffer"`
+	Enabled bool `json:"enabled" yaml:"enabled"`
+}
+
+// Record is an audited request, or a gap or checkpoint record of the chain, by Kind.
+type Record struct {
+	At   time.Time `json:"at"`
+	Node string    `json:"node"`
+	// Kind is KindRequest, KindGap or KindCheckpoint.
+	Kind string `json:"kind,omitempty"`
+	// PrevHash is the hash of the previous record of the node, empty for its first one.
+	PrevHash  string `json:"prev_hash"`
+	Hash      string `json:"hash"`
+	RequestID string `json:"request_id,omitempty"`
+	// Tenant is the authenticated tenant, empty for unauthenticated requests; ClaimedTenant is the X-Tenant-Id
+	// of those.
+	Tenant        string `json:"tenant"`
+	ClaimedTenant string `json:"claimed_tenant,omitempty"`
+	// ClientID is the client of a request whose signature is verified.
+	ClientID   string `json:"client_id,omitempty"`
+	RemoteAddr string `json:"remote_addr"`
+	Method     string `json:"method"`
+	Path       string `json:"path"`
+	// TicketsHash is the fingerprint of the ticket set, empty for requests rejected before it was known.
+	TicketsHash string `json:"tickets_hash,omitempty"`
+	// Code is the error code of a failed request, e.g. "CYCLE".
+	Code string `json:"code,omitempty"`
+	// Seq numbers the records of the node from 1.
+	Seq uint64 `json:"seq"`
+	// Lost is the number of records a gap record reports lost.
+	Lost      uint64        `json:"lost,omitempty"`
+	BodyBytes int64         `json:"body_bytes"`
+	Duration  time.Duration `json:"duration_ns"`
+	Status    int           `json:"status"`
+}
+
+// Sink stores records. Implementations are safe for concurrent use.
+type Sink interface {
+	Write(ctx context.Context, record Record) error
+}
+
+// Tail is a Sink that finds the latest record of a node, so its chain continues across restarts.
+type Tail interface {
+	Last(ctx context.Context, node string) (Record, bool, error)
+}
+
+// Pruner is a Sink that deletes the records of each node from before its latest checkpoint older than a time,
+// and returns how many it deleted.
+type Pruner interface {
+	Prune(ctx context.Context, before time.Time) (int, error)
+}
+
+// Trail chains the records of a node and writes them to the sink in the background, in order. It is safe for
+// concurrent use; a nil Trail records nothing.
+type Trail struct {
+	sink   Sink
+	logger *slog.Logger
+	clock  clock.Clock
+	queue  chan Record
+	// done is closed once the queue is drained; stop stops pruning.
+	done chan struct{}
+	stop chan struct{}
+	node string
+	last string
+	key  []byte
+	seq  uint64
+	// lost counts the records the sink rejected, and reported the records dropped, that a gap record reported;
+	// only write uses them.
+	lost      uint64
+	reported  uint64
+	retention time.Duration
+	// dropped counts the records dropped from the full queue.
+	dropped atomic.Uint64
+	// checkpoints writes checkpoint records, for the sink to be pruned.
+	checkpoints bool
+}
+
+// New returns the trail of the node writing to the sink, continuing the node's chain when the sink is a Tail.
+func New(ctx context.Context, logger *slog.Logger, clk clock.Clock, sink Sink, cfg Config) (*Trail, error) {
+	if cfg.Key == "" {
+		return nil, ErrMissingKey
+	}
+	if cfg.Buffer <= 0 {
+		cfg.Buffer = defaultBuffer
+	}
+	t := &Trail{
+		sink:      sink,
+		logger:    logger,
+		clock:     clk,
+		queue:     make(chan Record, cfg.Buffer),
+		done:      make(chan struct{}),
+		stop:      make(chan struct{}),
+		key:       []byte(cfg.Key),
+		node:      cfg.Node,
+		retention: cfg.Retention,
+	}
+	if tail, ok := sink.(Tail); ok {
+		last, found, err := tail.Last(ctx, cfg.Node)
+		if err != nil {
+			return nil, fmt.Errorf("reading the latest audit record: %w", err)
+		}
+		if found {
+			t.last, t.seq = last.Hash, last.Seq
+		}
+	}
+
+	pruner, ok := sink.(Pruner)
+	t.checkpoints = ok && t.retention > 0
+	go t.write()
+	if t.checkpoints {
+		go t.prune(pruner)
+	} else {
+		close(t.stop)
+	}
+
+	return t, nil
+}
+
+// Record queues the record for the sink. While the queue is full, it waits for room up to a second, or until
+// the context is done, e.g. the request's; the record is then dropped, and reported by a gap record.
+func (t *Trail) Record(ctx context.Context, record Record) {
+	if t == nil {
+		return
+	}
+	select {
+	case t.queue <- record:
+		return
+	default:
+	}
+
+	timer := time.NewTimer(queueTimeout)
+	defer timer.Stop()
+	select {
+	case t.queue <- record:
+	case <-ctx.Done():
+		t.dropped.Add(1)
+	case <-timer.C:
+		t.dropped.Add(1)
+	}
+}
+
+// Dropped returns the number of records dropped from the full queue.
+func (t *Trail) Dropped() uint64 {
+	if t == nil {
+		return 0
+	}
+
+	return t.dropped.Load()
+}
+
+// Close writes the queued records and closes the sink when it is an io.Closer. No record may be recorded after.
+func (t *Trail) Close() error {
+	if t == nil {
+		return nil
+	}
+	close(t.queue)
+	<-t.done
+	select {
+	case <-t.stop:
+	default:
+		close(t.stop)
+	}
+	if closer, ok := t.sink.(io.Closer); ok {
+		return closer.Close()
+	}
+
+	return nil
+}
+
+// write chains and writes the queued records until the queue is closed, preceded by a gap record when records
+// were lost, and a checkpoint record every pruneInterval when the sink is pruned.
+func (t *Trail) write() {
+	defer close(t.done)
+
+	var checkpoints <-chan time.Time
+	if t.checkpoints {
+		ticker := time.NewTicker(pruneInterval)
+		defer ticker.Stop()
+		checkpoints = ticker.C
+		t.append(Record{Kind: KindCheckpoint})
+	}
+
+	for {
+		select {
+		case record, ok := <-t.queue:
+			t.reportGap()
+			if !ok {
+				return
+			}
+			t.append(record)
+		case <-checkpoints:
+			t.append(Record{Kind: KindCheckpoint})
+		}
+	}
+}
+
+// reportGap writes a gap record when records were lost since the previous one.
+func (t *Trail) reportGap() {
+	dropped := t.dropped.Load()
+	lost := t.lost + dropped - t.reported
+	if lost == 0 {
+		return
+	}
+	if t.append(Record{Kind: KindGap, Lost: lost}) {
+		t.lost, t.reported = 0, dropped
+	}
+}
+
+// append chains the record and writes it to the sink, attempting it writeAttempts times. A record the sink
+// fails to take is logged and counted as lost, for the next gap record, and false is returned.
+func (t *Trail) append(record Record) bool {
+	record.At = t.clock.Now().UTC().Truncate(time.Microsecond)
+	record.Node, record.Seq, record.PrevHash = t.node, t.seq+1, t.last
+	record.Hash = Hash(record, t.key)
+
+	var err error
+	for attempt := range writeAttempts {
+		if attempt > 0 {
+			time.Sleep(retryDelay << (attempt - 1))
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+		err = t.sink.Write(ctx, record)
+		cancel()
+		if err == nil || t.written(record) {
+			t.last, t.seq = record.Hash, record.Seq
+
+			return true
+		}
+	}
+
+	t.logger.Error("Failed to write audit record", "error", err, "kind", record.Kind, "request_id", record.RequestID,
+		"tenant", record.Tenant, "path", record.Path, "status", record.Status)
+	t.lost++
+
+	return false
+}
+
+// written reports whether a record whose write failed was written nonetheless, e.g. when the sink timed out
+// after storing it, so the chain continues from it rather than forking. Only a Tail can tell.
+func (t *Trail) written(record Record) bool {
+	tail, ok := t.sink.(Tail)
+	if !ok {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+	defer cancel()
+	last, found, err := tail.Last(ctx, t.node)
+
+	return err == nil && found && last.Seq == record.Seq && last.Hash == record.Hash
+}
+
+// prune deletes the records older than the retention, up to the latest checkpoint before them, right away
+// and then every pruneInterval.
+func (t *Trail) prune(pruner Pruner) {
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), pruneInterval)
+		pruned, err := pruner.Prune(ctx, t.clock.Now().Add(-t.retention))
+		cancel()
+		if err != nil {
+			t.logger.Error("Failed to prune audit records", "error", err)
+		} else if pruned > 0 {
+			t.logger.Info("Pruned audit records", "records", pruned, "retention", t.retention)
+		}
+
+		select {
+		case <-t.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Hash returns the HMAC-SHA256 of the record without its Hash, with the key.
+func Hash(record Record, key []byte) string {
+	record.Hash = ""
+	data, _ := json.Marshal(record)
+
+	h := hmac.New(sha256.New, key)
+	h.Write(data)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Verify checks that the records are an unbroken chain of a node, in order from its first record or, once
+// older records are pruned, from a checkpoint record. It returns an error wrapping ErrTampered for the first
+// record that was altered or does not follow the previous one, and for a chain cut anywhere but at a
+// checkpoint. Gap records are part of the chain: they report records lost, not tampering.
+func Verify(records []Record, key []byte) error {
+	for i, record := range records {
+		if Hash(record, key) != record.Hash {
+			return fmt.Errorf("%w: record %d of %s does not match its hash", ErrTampered, record.Seq, record.Node)
+		}
+		if i == 0 {
+			if (record.Seq != 1 || record.PrevHash != "") && record.Kind != KindCheckpoint {
+				return fmt.Errorf("%w: record %d of %s starts the chain but is not a checkpoint", ErrTampered, record.Seq, record.Node)
+			}
+
+			continue
+		}
+		previous := records[i-1]
+		if record.Node != previous.Node || record.Seq != previous.Seq+1 || record.PrevHash != previous.Hash {
+			return fmt.Errorf("%w: record %d of %s does not follow record %d", ErrTampered, record.Seq, record.Node, previous.Seq)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,287 @@
+package audit_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/audit"
+	"github.com/dsha256/dispatcher/internal/clock"
+)
+
+func TestTrail(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	clk := clock.NewFake(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	key := []byte("secret")
+	cfg := audit.Config{Node: "node-a", Key: string(key)}
+
+	// record writes the records through a new trail on the file, as after a restart.
+	record := func(records ...audit.Record) {
+		t.Helper()
+
+		sink, err := audit.OpenFile(path)
+		if err != nil {
+			t.Fatalf("OpenFile() error = %v", err)
+		}
+		trail, err := audit.New(context.Background(), slog.New(slog.DiscardHandler), clk, sink, cfg)
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		for _, record := range records {
+			trail.Record(context.Background(), record)
+		}
+		if err = trail.Close(); err != nil {
+			t.Fatalf("Close() error = %v", err)
+		}
+	}
+
+	record(
+		audit.Record{Tenant: "acme", Path: "/itinerary", Status: http.StatusOK, TicketsHash: "abc"},
+		audit.Record{Tenant: "acme", Path: "/itinerary", Status: http.StatusUnprocessableEntity, Code: "CYCLE"},
+	)
+	clk.Advance(time.Minute)
+	record(audit.Record{Tenant: "globex", Path: "/itinerary/stream", Status: http.StatusOK})
+
+	records, err := audit.ReadFile(path)
+	if err != nil || len(records) != 3 {
+		t.Fatalf("ReadFile() = %+v, %v, want 3 records", records, err)
+	}
+	for i, record := range records {
+		if record.Node != "node-a" || record.Seq != uint64(i+1) {
+			t.Errorf("record %d is %d of %q, want %d of node-a", i, record.Seq, record.Node, i+1)
+		}
+	}
+	if records[0].PrevHash != "" || records[2].PrevHash != records[1].Hash {
+		t.Errorf("records = %+v, want a chain continued after the restart", records)
+	}
+	if err = audit.Verify(records, key); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+	if err = audit.Verify(records, []byte("other")); !errors.Is(err, audit.ErrTampered) {
+		t.Errorf("Verify() with another key error = %v, want %v", err, audit.ErrTampered)
+	}
+}
+
+// memorySink keeps the records written, rejecting those of the path /fail and holding those of the path
+// /block until release is closed.
+type memorySink struct {
+	release chan struct{}
+	records []audit.Record
+	mu      sync.Mutex
+}
+
+func (m *memorySink) Write(_ context.Context, record audit.Record) error {
+	switch record.Path {
+	case "/fail":
+		return errors.New("sink unavailable")
+	case "/block":
+		<-m.release
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.records = append(m.records, record)
+
+	return nil
+}
+
+func TestTrailReportsLostRecords(t *testing.T) {
+	t.Parallel()
+
+	key := []byte("secret")
+	sink := &memorySink{release: make(chan struct{})}
+	trail, err := audit.New(context.Background(), slog.New(slog.DiscardHandler), clock.Real{}, sink, audit.Config{Node: "node-a", Key: string(key), Buffer: 1})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	// The writer holds the first record while the second fills the queue, so the third is dropped once its
+	// request is done, and reported before the second, which the sink rejects and is reported in turn.
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	trail.Record(context.Background(), audit.Record{Path: "/block"})
+	trail.Record(context.Background(), audit.Record{Path: "/fail"})
+	trail.Record(canceled, audit.Record{Path: "/dropped"})
+	close(sink.release)
+	trail.Record(context.Background(), audit.Record{Path: "/itinerary"})
+	if err = trail.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	kinds := []string{}
+	for _, record := range sink.records {
+		kinds = append(kinds, record.Kind+record.Path+fmt.Sprint(record.Lost))
+	}
+	if want := []string{"/block0", "gap1", "gap1", "/itinerary0"}; fmt.Sprint(kinds) != fmt.Sprint(want) {
+		t.Errorf("records = %v, want %v", kinds, want)
+	}
+	if trail.Dropped() != 1 {
+		t.Errorf("Dropped() = %d, want 1", trail.Dropped())
+	}
+	if err = audit.Verify(sink.records, key); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+
+	if _, err = audit.New(context.Background(), slog.New(slog.DiscardHandler), clock.Real{}, sink, audit.Config{Node: "node-a"}); !errors.Is(err, audit.ErrMissingKey) {
+		t.Errorf("New() without a key error = %v, want %v", err, audit.ErrMissingKey)
+	}
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	t.Parallel()
+
+	key := []byte("secret")
+	// chain returns a chain of four records, the second one a checkpoint.
+	chain := func() []audit.Record {
+		var records []audit.Record
+		previous := ""
+		for seq := range uint64(4) {
+			record := audit.Record{Node: "node-a", Seq: seq + 1, PrevHash: previous, Tenant: "acme", Status: http.StatusOK}
+			if seq == 1 {
+				record = audit.Record{Node: "node-a", Seq: seq + 1, PrevHash: previous, Kind: audit.KindCheckpoint}
+			}
+			record.Hash = audit.Hash(record, key)
+			previous = record.Hash
+			records = append(records, record)
+		}
+
+		return records
+	}
+
+	tests := []struct {
+		tamper func([]audit.Record) []audit.Record
+		name   string
+	}{
+		{
+			name: "altered",
+			tamper: func(records []audit.Record) []audit.Record {
+				records[1].Tenant = "globex"
+
+				return records
+			},
+		},
+		{
+			name: "rehashed",
+			tamper: func(records []audit.Record) []audit.Record {
+				records[2].Tenant = "globex"
+				records[2].Hash = audit.Hash(records[2], nil)
+
+				return records
+			},
+		},
+		{
+			name: "removed",
+			tamper: func(records []audit.Record) []audit.Record {
+				return append(records[:1], records[2])
+			},
+		},
+		{
+			name: "truncated",
+			tamper: func(records []audit.Record) []audit.Record {
+				return records[2:]
+			},
+		},
+		{
+			name: "reordered",
+			tamper: func(records []audit.Record) []audit.Record {
+				records[1], records[2] = records[2], records[1]
+
+				return records
+			},
+		},
+	}
+
+	if err := audit.Verify(chain()[1:], key); err != nil {
+		t.Errorf("Verify() of a chain pruned at a checkpoint error = %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if err := audit.Verify(tt.tamper(chain()), key); !errors.Is(err, audit.ErrTampered) {
+				t.Errorf("Verify() error = %v, want %v", err, audit.ErrTampered)
+			}
+		})
+	}
+}
+
+func TestFilePrune(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	sink, err := audit.OpenFile(path)
+	if err != nil {
+		t.Fatalf("OpenFile() error = %v", err)
+	}
+	defer sink.Close()
+
+	ctx := context.Background()
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	// A request and a checkpoint a day, the last day without a checkpoint.
+	for seq := range uint64(5) {
+		record := audit.Record{At: start.AddDate(0, 0, int(seq/2)), Node: "node-a", Seq: seq + 1} //nolint:gosec // Small.
+		if seq%2 == 1 {
+			record.Kind = audit.KindCheckpoint
+		}
+		if err = sink.Write(ctx, record); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+
+	// The records before the checkpoint of the second day are pruned, not the older ones after it.
+	if pruned, err := sink.Prune(ctx, start.AddDate(0, 0, 2)); err != nil || pruned != 3 {
+		t.Errorf("Prune() = %d, %v, want 3", pruned, err)
+	}
+	// The sink keeps appending to the pruned file.
+	if err = sink.Write(ctx, audit.Record{At: start.AddDate(0, 0, 3), Node: "node-a", Seq: 6}); err != nil {
+		t.Fatalf("Write() after Prune() error = %v", err)
+	}
+
+	records, err := audit.ReadFile(path)
+	if err != nil || len(records) != 3 || records[0].Seq != 4 || records[0].Kind != audit.KindCheckpoint || records[2].Seq != 6 {
+		t.Errorf("ReadFile() = %+v, %v, want records 4 to 6 from the checkpoint", records, err)
+	}
+	if last, found, err := sink.Last(ctx, "node-a"); err != nil || !found || last.Seq != 6 {
+		t.Errorf("Last() = %+v, %v, %v, want record 6", last, found, err)
+	}
+}
+
+func TestKafkaSink(t *testing.T) {
+	t.Parallel()
+
+	received := make(chan string, 1)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r.Method + " " + r.URL.Path + " " + r.Header.Get("Content-Type") + " " + string(body)
+	}))
+	t.Cleanup(proxy.Close)
+
+	if _, err := audit.NewKafkaSink(proxy.Client(), audit.Kafka{URL: proxy.URL}); !errors.Is(err, audit.ErrMissingKafkaTopic) {
+		t.Errorf("NewKafkaSink() without topic error = %v, want %v", err, audit.ErrMissingKafkaTopic)
+	}
+	sink, err := audit.NewKafkaSink(proxy.Client(), audit.Kafka{URL: proxy.URL, Topic: "dispatcher-audit"})
+	if err != nil {
+		t.Fatalf("NewKafkaSink() error = %v", err)
+	}
+
+	record := audit.Record{Node: "node-a", Seq: 1, Hash: "abc", Tenant: "acme", Status: http.StatusOK}
+	if err = sink.Write(context.Background(), record); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	value, _ := json.Marshal(record)
+	want := "POST /topics/dispatcher-audit application/vnd.kafka.json.v2+json " +
+		`{"records":[{"key":"node-a","value":` + string(value) + `}]}`
+	if got := <-received; got != want {
+		t.Errorf("proxy received %q, want %q", got, want)
+	}
+}
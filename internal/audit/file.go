@@ -0,0 +1,174 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// File appends records to a JSON Lines file, one record per line. It is safe for concurrent use.
+type File struct {
+	file *os.File
+	path string
+	mu   sync.Mutex
+}
+
+// OpenFile opens the file for appending, creating it when missing.
+func OpenFile(path string) (*File, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("opening audit file: %w", err)
+	}
+
+	return &File{file: file, path: path}, nil
+}
+
+// Write appends the record and syncs the file, so an acknowledged record survives a crash.
+func (f *File) Write(_ context.Context, record Record) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, err = f.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+
+	return f.file.Sync()
+}
+
+// Last returns the latest record of the node in the file.
+func (f *File) Last(_ context.Context, node string) (Record, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var last Record
+	found := false
+	err := f.scan(func(record Record, _ []byte) error {
+		if record.Node == node {
+			last, found = record, true
+		}
+
+		return nil
+	})
+
+	return last, found, err
+}
+
+// Prune rewrites the file without the records of each node from before its latest checkpoint older than the
+// time, replacing it atomically.
+func (f *File) Prune(_ context.Context, before time.Time) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// cuts are the sequence numbers of the checkpoints the chains are pruned up to, by node.
+	cuts := map[string]uint64{}
+	err := f.scan(func(record Record, _ []byte) error {
+		if record.Kind == KindCheckpoint && record.At.Before(before) {
+			cuts[record.Node] = max(cuts[record.Node], record.Seq)
+		}
+
+		return nil
+	})
+	if err != nil || len(cuts) == 0 {
+		return 0, err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	pruned := 0
+	writer := bufio.NewWriter(tmp)
+	err = f.scan(func(record Record, line []byte) error {
+		if record.Seq < cuts[record.Node] {
+			pruned++
+
+			return nil
+		}
+		_, err := writer.Write(append(line, '\n'))
+
+		return err
+	})
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil || pruned == 0 {
+		return 0, err
+	}
+
+	if err = os.Rename(tmp.Name(), f.path); err != nil {
+		return 0, err
+	}
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return pruned, fmt.Errorf("reopening audit file: %w", err)
+	}
+	_ = f.file.Close()
+	f.file = file
+
+	return pruned, nil
+}
+
+// ReadFile returns the records of a file written by the file sink, in order, e.g. to Verify them.
+func ReadFile(path string) ([]Record, error) {
+	var records []Record
+	err := (&File{path: path}).scan(func(record Record, _ []byte) error {
+		records = append(records, record)
+
+		return nil
+	})
+
+	return records, err
+}
+
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.file.Close()
+}
+
+// scan calls fn with every record of the file and its line, in order. f.mu must be held, if the file is open.
+func (f *File) scan(fn func(record Record, line []byte) error) error {
+	file, err := os.Open(f.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var record Record
+		if err = json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return fmt.Errorf("%w: unreadable line: %w", ErrTampered, err)
+		}
+		if err = fn(record, scanner.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,69 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+var ErrMissingKafkaTopic = errors.New("audit kafka url and topic are required")
+
+// kafkaContentType is the content type of JSON records in the v2 API of the Kafka REST proxy.
+const kafkaContentType = "application/vnd.kafka.json.v2+json"
+
+// Kafka produces records to a topic through a Kafka REST proxy, e.g. the Confluent REST Proxy.
+type Kafka struct {
+	// URL is the base URL of the REST proxy, e.g. "http://kafka-rest:8082".
+	URL   string `json:"url"   yaml:"url"`
+	Topic string `json:"topic" yaml:"topic"`
+}
+
+// KafkaSink writes records to a Kafka topic keyed by node, so the records of a node stay in order on one
+// partition. Retention is the topic's.
+type KafkaSink struct {
+	client   *http.Client
+	endpoint string
+}
+
+func NewKafkaSink(client *http.Client, cfg Kafka) (*KafkaSink, error) {
+	if cfg.URL == "" || cfg.Topic == "" {
+		return nil, ErrMissingKafkaTopic
+	}
+
+	return &KafkaSink{client: client, endpoint: cfg.URL + "/topics/" + url.PathEscape(cfg.Topic)}, nil
+}
+
+func (k *KafkaSink) Write(ctx context.Context, record Record) error {
+	type message struct {
+		Key   string `json:"key"`
+		Value Record `json:"value"`
+	}
+	body, err := json.Marshal(map[string][]message{"records": {{Key: record.Node, Value: record}}})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, k.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", kafkaContentType)
+
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("producing audit record: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("producing audit record: unexpected status %s", resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,175 @@
+package blackout
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DateLayout is the layout of blackout dates, e.g. 2025-12-25.
+const DateLayout = time.DateOnly
+
+const (
+	// DefaultMaxCalendars is the number of calendars a tenant may keep by default.
+	DefaultMaxCalendars = 64
+	// DefaultMaxDates is the number of dates a calendar may hold by default, about ten years of holidays.
+	DefaultMaxDates = 1024
+	// MaxNameLength is the length of the longest calendar name, in bytes.
+	MaxNameLength = 128
+)
+
+var (
+	ErrCalendarNameRequired = errors.New("calendar name is required")
+	ErrCalendarNameTooLong  = errors.New("calendar name is too long")
+	ErrCalendarNotFound     = errors.New("calendar not found")
+	ErrInvalidDate          = errors.New("invalid blackout date")
+	ErrTooManyCalendars     = errors.New("too many blackout calendars")
+	ErrTooManyDates         = errors.New("too many blackout dates")
+)
+
+// Calendar is a named set of dates on which departures are blacked out,
+// e.g. national holidays or charter restrictions.
+type Calendar struct {
+	Name  string   `json:"name"`
+	Dates []string `json:"dates"`
+}
+
+// Match is a blacked-out date hit by a departure.
+type Match struct {
+	Calendar string `json:"calendar"`
+	Date     string `json:"date"`
+}
+
+// Store keeps blackout calendars per tenant in memory, bounding the calendars of each tenant and the dates of each
+// calendar. It is safe for concurrent use.
+type Store struct {
+	// calendars maps tenant -> calendar name -> set of dates.
+	calendars    map[string]map[string]map[string]struct{}
+	maxCalendars int
+	maxDates     int
+	mu           sync.RWMutex
+}
+
+// Option configures a Store.
+type Option func(*Store)
+
+// WithLimits bounds the calendars a tenant may keep and the dates a calendar may hold, instead of
+// DefaultMaxCalendars and DefaultMaxDates. A limit of 0 keeps its default.
+func WithLimits(calendars, dates int) Option {
+	return func(s *Store) {
+		if calendars > 0 {
+			s.maxCalendars = calendars
+		}
+		if dates > 0 {
+			s.maxDates = dates
+		}
+	}
+}
+
+func NewStore(opts ...Option) *Store {
+	s := &Store{
+		calendars:    make(map[string]map[string]map[string]struct{}),
+		maxCalendars: DefaultMaxCalendars,
+		maxDates:     DefaultMaxDates,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// Put creates or replaces a calendar of the tenant. It fails with ErrTooManyDates when the calendar holds more
+// dates than allowed, and ErrTooManyCalendars when it is a new calendar of a tenant keeping as many as allowed.
+func (s *Store) Put(tenant string, calendar Calendar) error {
+	if calendar.Name == "" {
+		return ErrCalendarNameRequired
+	}
+	if len(calendar.Name) > MaxNameLength {
+		return fmt.Errorf("%w: at most %d bytes", ErrCalendarNameTooLong, MaxNameLength)
+	}
+	if len(calendar.Dates) > s.maxDates {
+		return fmt.Errorf("%w: %d dates, at most %d", ErrTooManyDates, len(calendar.Dates), s.maxDates)
+	}
+
+	dates := make(map[string]struct{}, len(calendar.Dates))
+	for _, date := range calendar.Dates {
+		if _, err := time.Parse(DateLayout, date); err != nil {
+			return fmt.Errorf("%w %q: expected YYYY-MM-DD", ErrInvalidDate, date)
+		}
+		dates[date] = struct{}{}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.calendars[tenant] == nil {
+		s.calendars[tenant] = make(map[string]map[string]struct{})
+	}
+	if _, ok := s.calendars[tenant][calendar.Name]; !ok && len(s.calendars[tenant]) >= s.maxCalendars {
+		return fmt.Errorf("%w: at most %d per tenant", ErrTooManyCalendars, s.maxCalendars)
+	}
+	s.calendars[tenant][calendar.Name] = dates
+
+	return nil
+}
+
+// Delete removes a calendar of the tenant.
+func (s *Store) Delete(tenant, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.calendars[tenant][name]; !ok {
+		return ErrCalendarNotFound
+	}
+	delete(s.calendars[tenant], name)
+	if len(s.calendars[tenant]) == 0 {
+		delete(s.calendars, tenant)
+	}
+
+	return nil
+}
+
+// List returns the calendars of the tenant sorted by name.
+func (s *Store) List(tenant string) []Calendar {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	calendars := make([]Calendar, 0, len(s.calendars[tenant]))
+	for name, dates := range s.calendars[tenant] {
+		calendar := Calendar{Name: name, Dates: make([]string, 0, len(dates))}
+		for date := range dates {
+			calendar.Dates = append(calendar.Dates, date)
+		}
+		sort.Strings(calendar.Dates)
+		calendars = append(calendars, calendar)
+	}
+	sort.Slice(calendars, func(i, j int) bool {
+		return calendars[i].Name < calendars[j].Name
+	})
+
+	return calendars
+}
+
+// Check returns every calendar of the tenant that blacks out the date of departure.
+// The date is taken in the departure's own time zone, i.e. the local date at the origin.
+func (s *Store) Check(tenant string, departure time.Time) []Match {
+	date := departure.Format(DateLayout)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	matches := []Match{}
+	for name, dates := range s.calendars[tenant] {
+		if _, ok := dates[date]; ok {
+			matches = append(matches, Match{Calendar: name, Date: date})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].Calendar < matches[j].Calendar
+	})
+
+	return matches
+}
@@ -0,0 +1,57 @@
+package blackout_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/blackout"
+)
+
+func TestStorePutLimits(t *testing.T) {
+	t.Parallel()
+
+	store := blackout.NewStore(blackout.WithLimits(1, 2))
+	tests := []struct {
+		wantErr  error
+		name     string
+		tenant   string
+		calendar blackout.Calendar
+	}{
+		{name: "first calendar", tenant: "acme", calendar: blackout.Calendar{Name: "christmas", Dates: []string{"2025-12-25"}}},
+		{name: "replaced calendar", tenant: "acme", calendar: blackout.Calendar{Name: "christmas", Dates: []string{"2025-12-24", "2025-12-25"}}},
+		{
+			name: "one calendar too many", tenant: "acme", calendar: blackout.Calendar{Name: "easter", Dates: []string{"2025-04-20"}},
+			wantErr: blackout.ErrTooManyCalendars,
+		},
+		{name: "another tenant", tenant: "globex", calendar: blackout.Calendar{Name: "easter", Dates: []string{"2025-04-20"}}},
+		{
+			name: "too many dates", tenant: "initech", calendar: blackout.Calendar{Name: "holidays", Dates: []string{"2025-01-01", "2025-07-04", "2025-12-25"}},
+			wantErr: blackout.ErrTooManyDates,
+		},
+		{
+			name: "name too long", tenant: "initech", calendar: blackout.Calendar{Name: string(make([]byte, blackout.MaxNameLength+1))},
+			wantErr: blackout.ErrCalendarNameTooLong,
+		},
+		{name: "invalid date", tenant: "initech", calendar: blackout.Calendar{Name: "holidays", Dates: []string{"25-12-2025"}}, wantErr: blackout.ErrInvalidDate},
+	}
+	for _, tt := range tests {
+		if err := store.Put(tt.tenant, tt.calendar); !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: Put() error = %v, want %v", tt.name, err, tt.wantErr)
+		}
+	}
+
+	if err := store.Delete("acme", "christmas"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err := store.Put("acme", blackout.Calendar{Name: "easter", Dates: []string{"2025-04-20"}}); err != nil {
+		t.Errorf("Expected a calendar to be accepted once another was deleted, got %v", err)
+	}
+	departure := time.Date(2025, 4, 20, 8, 0, 0, 0, time.UTC)
+	if matches := store.Check("acme", departure); len(matches) != 1 || matches[0].Calendar != "easter" {
+		t.Errorf("Check() = %v, want a match of easter", matches)
+	}
+	if matches := store.Check("initech", departure); len(matches) != 0 {
+		t.Errorf("Check() = %v, want no match for a tenant without calendars", matches)
+	}
+}
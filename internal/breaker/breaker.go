@@ -0,0 +1,185 @@
+// Package breaker wraps the calls to a dependency, e.g. Redis or Postgres, in a circuit breaker: after
+// consecutive failures, calls fail right away for a while instead of waiting on the dependency, then a single
+// probe tests whether it recovered. Callers treat ErrOpen like any failure of the dependency, so a degraded
+// dependency degrades the features relying on it instead of slowing every request down.
+package breaker
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/clock"
+)
+
+// ErrOpen is returned instead of calling a dependency whose breaker is open.
+var ErrOpen = errors.New("circuit breaker open")
+
+// State is the state of a breaker.
+type State string
+
+const (
+	// StateClosed lets calls through.
+	StateClosed State = "closed"
+	// StateOpen fails calls right away.
+	StateOpen State = "open"
+	// StateHalfOpen lets a single probe through, whose outcome closes or opens the breaker again.
+	StateHalfOpen State = "half_open"
+)
+
+const (
+	defaultFailures = 5
+	defaultOpenFor  = 30 * time.Second
+)
+
+type Config struct {
+	// Failures is the number of consecutive failures opening a breaker, 5 when 0.
+	Failures int `json:"failures" yaml:"failures"`
+	// OpenFor is how long an open breaker fails calls before letting a probe through, 30s when 0.
+	OpenFor time.Duration `json:"open_for" yaml:"open_for"`
+	Enabled bool          `json:"enabled"  yaml:"enabled"`
+}
+
+// Stats are the state and counters of a breaker since it was created.
+type Stats struct {
+	State State `json:"state"`
+	// ConsecutiveFailures are the failures since the last success.
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+	Successes           uint64 `json:"successes"`
+	Failures            uint64 `json:"failures"`
+	// Rejected are the calls failed with ErrOpen.
+	Rejected uint64 `json:"rejected"`
+	// Opened is the number of times the breaker opened.
+	Opened uint64 `json:"opened"`
+}
+
+// Breaker is the circuit breaker of a dependency. It is safe for concurrent use; a nil Breaker lets every
+// call through.
+type Breaker struct {
+	clock    clock.Clock
+	logger   *slog.Logger
+	openedAt time.Time
+	name     string
+	stats    Stats
+	cfg      Config
+	mu       sync.Mutex
+}
+
+// New returns the breaker of the named dependency, nil when breakers are disabled. State changes are logged.
+func New(logger *slog.Logger, clk clock.Clock, name string, cfg Config) *Breaker {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.Failures <= 0 {
+		cfg.Failures = defaultFailures
+	}
+	if cfg.OpenFor <= 0 {
+		cfg.OpenFor = defaultOpenFor
+	}
+
+	return &Breaker{clock: clk, logger: logger, name: name, stats: Stats{State: StateClosed}, cfg: cfg}
+}
+
+// Do calls fn unless the breaker is open, and records its outcome: any error is a failure.
+func (b *Breaker) Do(fn func() error) error {
+	return b.do(fn, func(err error) bool { return err != nil })
+}
+
+// do calls fn unless the breaker is open, and records its outcome, a failure when failed reports so. Calls
+// the caller gave up on, failing with context.Canceled, have no outcome.
+func (b *Breaker) do(fn func() error, failed func(error) bool) error {
+	if b == nil {
+		return fn()
+	}
+	if err := b.allow(); err != nil {
+		return err
+	}
+
+	err := fn()
+	if errors.Is(err, context.Canceled) {
+		b.abandon()
+
+		return err
+	}
+	b.record(failed(err))
+
+	return err
+}
+
+// allow reports whether a call may go through, moving an open breaker to half-open once OpenFor passed.
+func (b *Breaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.stats.State {
+	case StateOpen:
+		if b.clock.Now().Sub(b.openedAt) >= b.cfg.OpenFor {
+			b.transition(StateHalfOpen)
+
+			return nil
+		}
+	case StateHalfOpen:
+		// The probe is in flight.
+	default:
+		return nil
+	}
+	b.stats.Rejected++
+
+	return ErrOpen
+}
+
+// record counts the outcome of a call: a failure opens a half-open breaker, or a closed one once the
+// failures are consecutive enough; a success closes the breaker.
+func (b *Breaker) record(failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !failed {
+		b.stats.Successes++
+		b.stats.ConsecutiveFailures = 0
+		if b.stats.State == StateHalfOpen {
+			b.transition(StateClosed)
+		}
+
+		return
+	}
+
+	b.stats.Failures++
+	b.stats.ConsecutiveFailures++
+	if b.stats.State == StateHalfOpen || (b.stats.State == StateClosed && b.stats.ConsecutiveFailures >= b.cfg.Failures) {
+		b.openedAt = b.clock.Now()
+		b.stats.Opened++
+		b.transition(StateOpen)
+	}
+}
+
+// abandon reopens a half-open breaker whose probe had no outcome, so the next call probes again.
+func (b *Breaker) abandon() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.stats.State == StateHalfOpen {
+		b.transition(StateOpen)
+	}
+}
+
+// transition moves the breaker to the state; b.mu must be held.
+func (b *Breaker) transition(state State) {
+	b.logger.Warn("Circuit breaker state changed", "breaker", b.name, "from", b.stats.State, "to", state,
+		"consecutive_failures", b.stats.ConsecutiveFailures)
+	b.stats.State = state
+}
+
+// Stats returns the state and counters of the breaker.
+func (b *Breaker) Stats() Stats {
+	if b == nil {
+		return Stats{State: StateClosed}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.stats
+}
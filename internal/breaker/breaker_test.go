@@ -0,0 +1,134 @@
+package breaker_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/breaker"
+	"github.com/dsha256/dispatcher/internal/clock"
+	"github.com/dsha256/dispatcher/internal/store"
+)
+
+var errDown = errors.New("connection refused")
+
+func newBreaker(clk clock.Clock) *breaker.Breaker {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	return breaker.New(logger, clk, "redis", breaker.Config{Failures: 2, OpenFor: time.Minute, Enabled: true})
+}
+
+func TestBreaker(t *testing.T) {
+	t.Parallel()
+
+	clk := clock.NewFake(time.Now())
+	b := newBreaker(clk)
+	calls := 0
+	fail := func() error {
+		calls++
+
+		return errDown
+	}
+	succeed := func() error {
+		calls++
+
+		return nil
+	}
+
+	_ = b.Do(fail)
+	if got := b.Stats().State; got != breaker.StateClosed {
+		t.Fatalf("Expected the breaker closed after a failure, got %s", got)
+	}
+	_ = b.Do(fail)
+	if got := b.Stats().State; got != breaker.StateOpen {
+		t.Fatalf("Expected the breaker open after 2 consecutive failures, got %s", got)
+	}
+
+	if err := b.Do(succeed); !errors.Is(err, breaker.ErrOpen) || calls != 2 {
+		t.Fatalf("Expected an open breaker to fail calls without calling, got %v after %d calls", err, calls)
+	}
+
+	clk.Advance(time.Minute)
+	if err := b.Do(fail); !errors.Is(err, errDown) || b.Stats().State != breaker.StateOpen {
+		t.Fatalf("Expected a failed probe to open the breaker again, got %v and %s", err, b.Stats().State)
+	}
+
+	clk.Advance(time.Minute)
+	if err := b.Do(succeed); err != nil || b.Stats().State != breaker.StateClosed {
+		t.Fatalf("Expected a successful probe to close the breaker, got %v and %s", err, b.Stats().State)
+	}
+
+	stats := b.Stats()
+	want := breaker.Stats{State: breaker.StateClosed, Successes: 1, Failures: 3, Rejected: 1, Opened: 2}
+	if stats != want {
+		t.Errorf("Expected stats %+v, got %+v", want, stats)
+	}
+}
+
+func TestBreakerHalfOpen(t *testing.T) {
+	t.Parallel()
+
+	clk := clock.NewFake(time.Now())
+	b := newBreaker(clk)
+	_ = b.Do(func() error { return errDown })
+	_ = b.Do(func() error { return errDown })
+	clk.Advance(time.Minute)
+
+	probing, release := make(chan struct{}), make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- b.Do(func() error {
+			close(probing)
+			<-release
+
+			return context.Canceled
+		})
+	}()
+	<-probing
+
+	if err := b.Do(func() error { return nil }); !errors.Is(err, breaker.ErrOpen) {
+		t.Errorf("Expected calls during the probe to be rejected, got %v", err)
+	}
+	close(release)
+	<-done
+
+	if got := b.Stats().State; got != breaker.StateOpen {
+		t.Fatalf("Expected an abandoned probe to reopen the breaker, got %s", got)
+	}
+	if err := b.Do(func() error { return nil }); err != nil || b.Stats().State != breaker.StateClosed {
+		t.Errorf("Expected the next call to probe again, got %v and %s", err, b.Stats().State)
+	}
+}
+
+func TestItineraries(t *testing.T) {
+	t.Parallel()
+
+	b := newBreaker(clock.NewFake(time.Now()))
+	itineraries := breaker.Itineraries(b, store.NewMemory(clock.NewFake(time.Now())))
+
+	for range 3 {
+		if _, err := itineraries.Get(context.Background(), "acme", "missing"); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("Expected ErrNotFound, got %v", err)
+		}
+	}
+	if got := b.Stats(); got.State != breaker.StateClosed || got.Failures != 0 {
+		t.Errorf("Expected missing itineraries not to count as failures, got %+v", got)
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	t.Parallel()
+
+	b := breaker.New(slog.Default(), clock.Real{}, "redis", breaker.Config{})
+	if b != nil {
+		t.Fatal("Expected no breaker when disabled")
+	}
+	for range 10 {
+		if err := b.Do(func() error { return errDown }); !errors.Is(err, errDown) {
+			t.Fatalf("Expected a nil breaker to call through, got %v", err)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/cache"
+	"github.com/dsha256/dispatcher/internal/store"
+)
+
+// Cache wraps the cache store in the breaker. The cache treats a failing store as empty, so an open breaker
+// turns the shared cache off rather than failing lookups. A nil breaker returns the store as is.
+func Cache(b *Breaker, s cache.Store) cache.Store {
+	if b == nil {
+		return s
+	}
+
+	return &cacheStore{breaker: b, store: s}
+}
+
+type cacheStore struct {
+	breaker *Breaker
+	store   cache.Store
+}
+
+func (c *cacheStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	var value []byte
+	var ok bool
+	err := c.breaker.Do(func() error {
+		var err error
+		value, ok, err = c.store.Get(ctx, key)
+
+		return err
+	})
+
+	return value, ok, err
+}
+
+func (c *cacheStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return c.breaker.Do(func() error {
+		return c.store.Set(ctx, key, value, ttl)
+	})
+}
+
+// Itineraries wraps the itinerary store in the breaker; store.ErrNotFound is not a failure. Saving is
+// best-effort, so an open breaker leaves reconstructions unsaved rather than failing them. A nil breaker
+// returns the store as is.
+func Itineraries(b *Breaker, s store.Itineraries) store.Itineraries {
+	if b == nil {
+		return s
+	}
+
+	return &itineraries{breaker: b, store: s}
+}
+
+type itineraries struct {
+	breaker *Breaker
+	store   store.Itineraries
+}
+
+// failed reports whether the error of an itinerary store call is a failure of the store.
+func failed(err error) bool {
+	return err != nil && !errors.Is(err, store.ErrNotFound)
+}
+
+func (i *itineraries) Save(ctx context.Context, itinerary *store.Itinerary) error {
+	return i.breaker.do(func() error {
+		return i.store.Save(ctx, itinerary)
+	}, failed)
+}
+
+func (i *itineraries) Get(ctx context.Context, tenant, id string) (*store.Itinerary, error) {
+	return i.get(func() (*store.Itinerary, error) { return i.store.Get(ctx, tenant, id) })
+}
+
+func (i *itineraries) GetByHash(ctx context.Context, tenant, hash string) (*store.Itinerary, error) {
+	return i.get(func() (*store.Itinerary, error) { return i.store.GetByHash(ctx, tenant, hash) })
+}
+
+func (i *itineraries) get(fn func() (*store.Itinerary, error)) (*store.Itinerary, error) {
+	var itinerary *store.Itinerary
+	err := i.breaker.do(func() error {
+		var err error
+		itinerary, err = fn()
+
+		return err
+	}, failed)
+
+	return itinerary, err
+}
+
+func (i *itineraries) List(ctx context.Context, tenant string, page store.Page) ([]store.Itinerary, error) {
+	var list []store.Itinerary
+	err := i.breaker.do(func() error {
+		var err error
+		list, err = i.store.List(ctx, tenant, page)
+
+		return err
+	}, failed)
+
+	return list, err
+}
+
+func (i *itineraries) Delete(ctx context.Context, tenant, id string) error {
+	return i.breaker.do(func() error {
+		return i.store.Delete(ctx, tenant, id)
+	}, failed)
+}
@@ -0,0 +1,336 @@
+// Package cache keeps recently computed results for a while, so identical requests are answered
+// without computing them again, and coalesces concurrent identical requests into one computation.
+package cache
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/clock"
+)
+
+// ErrComputePanicked is returned to the calls waiting for a computation that panicked.
+var ErrComputePanicked = errors.New("cache: computation panicked")
+
+// Outcomes of a lookup, as reported to clients.
+const (
+	// OutcomeHit is a result served from the cache.
+	OutcomeHit = "hit"
+	// OutcomeMiss is a result computed and stored.
+	OutcomeMiss = "miss"
+	// OutcomeShared is a result computed for a concurrent identical request.
+	OutcomeShared = "shared"
+	// OutcomeBypass is a result computed on request without reading or storing the cache.
+	OutcomeBypass = "bypass"
+)
+
+// Stats are the counters of a cache since it was created. Hits include StoreHits, the values read
+// from the store; StoreErrors counts the failed store reads and writes.
+type Stats struct {
+	Hits        uint64  `json:"hits"`
+	Misses      uint64  `json:"misses"`
+	Shared      uint64  `json:"shared"`
+	Bypassed    uint64  `json:"bypassed"`
+	StoreHits   uint64  `json:"store_hits"`
+	StoreErrors uint64  `json:"store_errors"`
+	Entries     int     `json:"entries"`
+	MaxEntries  int     `json:"max_entries"`
+	TTLSeconds  float64 `json:"ttl_seconds"`
+	Enabled     bool    `json:"enabled"`
+}
+
+// Store shares cached values between replicas, e.g. Redis. The cache keeps recently used values in
+// memory in front of it and stores values JSON-encoded.
+type Store interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+// Option configures a Cache.
+type Option[V any] func(*Cache[V])
+
+// WithStore reads the values missing from memory from the store and writes computed values to it.
+// A failing store is counted in Stats.StoreErrors and otherwise treated as empty, so it never fails a lookup.
+func WithStore[V any](store Store) Option[V] {
+	return func(c *Cache[V]) {
+		c.store = store
+	}
+}
+
+// Cache maps keys to values for ttl, keeping at most maxEntries and evicting the least recently
+// used ones first. Errors are never cached. It is safe for concurrent use; a nil Cache computes
+// every value.
+type Cache[V any] struct {
+	clock clock.Clock
+	// store is nil when values are only kept in memory.
+	store   Store
+	entries map[string]*list.Element
+	// recent orders the entries, most recently used first.
+	recent *list.List
+	// calls are the computations in flight by key.
+	calls      map[string]*call[V]
+	stats      Stats
+	ttl        time.Duration
+	maxEntries int
+	mu         sync.Mutex
+}
+
+type entry[V any] struct {
+	expires time.Time
+	value   V
+	key     string
+}
+
+// call is a computation in flight; done is closed once value and err are set.
+type call[V any] struct {
+	err   error
+	done  chan struct{}
+	value V
+}
+
+// New returns a cache keeping values for ttl, at most maxEntries of them in memory.
+func New[V any](clk clock.Clock, ttl time.Duration, maxEntries int, opts ...Option[V]) *Cache[V] {
+	c := &Cache[V]{
+		clock:      clk,
+		entries:    make(map[string]*list.Element),
+		recent:     list.New(),
+		calls:      make(map[string]*call[V]),
+		ttl:        ttl,
+		maxEntries: max(maxEntries, 1),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// Do returns the value of key from the cache, or computes it with compute and stores it. Concurrent
+// calls for the same key wait for the first one's computation rather than computing the value again.
+// When that computation stops because its own context is done, a waiting call whose ctx is still live
+// computes the value itself. Do also returns the outcome of the lookup, empty for a nil Cache.
+func (c *Cache[V]) Do(ctx context.Context, key string, compute func() (V, error)) (V, string, error) {
+	if c == nil {
+		value, err := compute()
+
+		return value, "", err
+	}
+
+	for {
+		c.mu.Lock()
+		if value, ok := c.lookup(key); ok {
+			c.stats.Hits++
+			c.mu.Unlock()
+
+			return value, OutcomeHit, nil
+		}
+
+		if inFlight, ok := c.calls[key]; ok {
+			c.stats.Shared++
+			c.mu.Unlock()
+
+			select {
+			case <-inFlight.done:
+			case <-ctx.Done():
+				var zero V
+
+				return zero, OutcomeShared, ctx.Err()
+			}
+			if isContextError(inFlight.err) && ctx.Err() == nil {
+				continue
+			}
+
+			return inFlight.value, OutcomeShared, inFlight.err
+		}
+
+		own := &call[V]{done: make(chan struct{})}
+		c.calls[key] = own
+		c.mu.Unlock()
+
+		outcome := c.run(ctx, key, own, compute)
+
+		return own.value, outcome, own.err
+	}
+}
+
+// run reads the value of the call from the store or computes it, keeps it unless it failed and releases
+// the waiting calls, even when compute panics.
+func (c *Cache[V]) run(ctx context.Context, key string, own *call[V], compute func() (V, error)) string {
+	completed, fromStore := false, false
+	defer func() {
+		if !completed {
+			own.err = ErrComputePanicked
+		}
+
+		c.mu.Lock()
+		delete(c.calls, key)
+		switch {
+		case fromStore:
+			c.stats.Hits++
+			c.stats.StoreHits++
+		case completed:
+			c.stats.Misses++
+		}
+		if own.err == nil {
+			c.keep(key, own.value)
+		}
+		c.mu.Unlock()
+		close(own.done)
+	}()
+
+	if own.value, fromStore = c.load(ctx, key); fromStore {
+		completed = true
+
+		return OutcomeHit
+	}
+
+	own.value, own.err = compute()
+	completed = true
+	if own.err == nil {
+		c.save(ctx, key, own.value)
+	}
+
+	return OutcomeMiss
+}
+
+// load reads the value of key from the store, if any.
+func (c *Cache[V]) load(ctx context.Context, key string) (V, bool) {
+	var value V
+	if c.store == nil {
+		return value, false
+	}
+
+	encoded, ok, err := c.store.Get(ctx, key)
+	if err == nil && ok {
+		err = json.Unmarshal(encoded, &value)
+	}
+	if err != nil {
+		c.storeFailed()
+
+		return value, false
+	}
+
+	return value, ok
+}
+
+// save writes the value of key to the store, if any.
+func (c *Cache[V]) save(ctx context.Context, key string, value V) {
+	if c.store == nil {
+		return
+	}
+
+	encoded, err := json.Marshal(value)
+	if err == nil {
+		err = c.store.Set(ctx, key, encoded, c.ttl)
+	}
+	if err != nil {
+		c.storeFailed()
+	}
+}
+
+func (c *Cache[V]) storeFailed() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stats.StoreErrors++
+}
+
+// Bypass computes the value without reading or storing the cache.
+func (c *Cache[V]) Bypass(compute func() (V, error)) (V, string, error) {
+	if c == nil {
+		value, err := compute()
+
+		return value, "", err
+	}
+
+	c.mu.Lock()
+	c.stats.Bypassed++
+	c.mu.Unlock()
+
+	value, err := compute()
+
+	return value, OutcomeBypass, err
+}
+
+// Stats returns the counters of the cache. A nil Cache reports itself disabled.
+func (c *Cache[V]) Stats() Stats {
+	if c == nil {
+		return Stats{}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Entries = len(c.entries)
+	stats.MaxEntries = c.maxEntries
+	stats.TTLSeconds = c.ttl.Seconds()
+	stats.Enabled = true
+
+	return stats
+}
+
+// Flush drops every value kept in memory and returns how many there were. Values in the store are kept,
+// since other replicas share them; they expire with their TTL. A nil Cache has nothing to flush.
+func (c *Cache[V]) Flush() int {
+	if c == nil {
+		return 0
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	flushed := len(c.entries)
+	c.entries = make(map[string]*list.Element)
+	c.recent.Init()
+
+	return flushed
+}
+
+// lookup returns the live value of key, dropping it when it expired. c.mu must be held.
+func (c *Cache[V]) lookup(key string) (V, bool) {
+	element, ok := c.entries[key]
+	if !ok {
+		var zero V
+
+		return zero, false
+	}
+
+	stored, _ := element.Value.(*entry[V])
+	if !c.clock.Now().Before(stored.expires) {
+		c.remove(element)
+
+		var zero V
+
+		return zero, false
+	}
+	c.recent.MoveToFront(element)
+
+	return stored.value, true
+}
+
+// keep adds the value, evicting the least recently used entries above maxEntries. c.mu must be held.
+func (c *Cache[V]) keep(key string, value V) {
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+	c.entries[key] = c.recent.PushFront(&entry[V]{key: key, value: value, expires: c.clock.Now().Add(c.ttl)})
+
+	for len(c.entries) > c.maxEntries {
+		c.remove(c.recent.Back())
+	}
+}
+
+func (c *Cache[V]) remove(element *list.Element) {
+	stored, _ := element.Value.(*entry[V])
+	delete(c.entries, stored.key)
+	c.recent.Remove(element)
+}
+
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
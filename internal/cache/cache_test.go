@@ -0,0 +1,259 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/cache"
+	"github.com/dsha256/dispatcher/internal/clock"
+)
+
+var errCompute = errors.New("compute failed")
+
+func TestCacheDo(t *testing.T) {
+	t.Parallel()
+
+	clk := clock.NewFake(time.Date(2025, 5, 1, 8, 0, 0, 0, time.UTC))
+	c := cache.New[int](clk, time.Minute, 2)
+	ctx := context.Background()
+
+	computed := 0
+	compute := func(value int) func() (int, error) {
+		return func() (int, error) {
+			computed++
+
+			return value, nil
+		}
+	}
+
+	steps := []struct {
+		compute func() (int, error)
+		advance time.Duration
+		key     string
+		outcome string
+		value   int
+	}{
+		{key: "a", compute: compute(1), outcome: cache.OutcomeMiss, value: 1},
+		{key: "a", compute: compute(2), outcome: cache.OutcomeHit, value: 1},
+		{key: "b", compute: compute(3), outcome: cache.OutcomeMiss, value: 3},
+		// "a" was used last, so adding "c" evicts "b".
+		{key: "a", compute: compute(4), outcome: cache.OutcomeHit, value: 1},
+		{key: "c", compute: compute(5), outcome: cache.OutcomeMiss, value: 5},
+		{key: "b", compute: compute(6), outcome: cache.OutcomeMiss, value: 6},
+		{key: "b", advance: time.Minute, compute: compute(7), outcome: cache.OutcomeMiss, value: 7},
+		{key: "errors", compute: func() (int, error) { return 0, errCompute }, outcome: cache.OutcomeMiss},
+		{key: "errors", compute: compute(8), outcome: cache.OutcomeMiss, value: 8},
+	}
+	for i, step := range steps {
+		clk.Advance(step.advance)
+		value, outcome, _ := c.Do(ctx, step.key, step.compute)
+		if value != step.value || outcome != step.outcome {
+			t.Errorf("step %d: Do(%q) = %d, %q, want %d, %q", i, step.key, value, outcome, step.value, step.outcome)
+		}
+	}
+
+	stats := c.Stats()
+	if stats.Hits != 2 || stats.Misses != 7 || computed != 6 || stats.Entries != 2 || !stats.Enabled {
+		t.Errorf("Stats() = %+v with %d computations", stats, computed)
+	}
+}
+
+func TestCacheDoCoalesces(t *testing.T) {
+	t.Parallel()
+
+	c := cache.New[int](clock.Real{}, time.Minute, 10)
+
+	var computations atomic.Int32
+	release := make(chan struct{})
+	compute := func() (int, error) {
+		computations.Add(1)
+		<-release
+
+		return 42, nil
+	}
+
+	const callers = 8
+	var wg sync.WaitGroup
+	outcomes := make(chan string, callers)
+	for range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			value, outcome, err := c.Do(context.Background(), "key", compute)
+			if value != 42 || err != nil {
+				t.Errorf("Do() = %d, %v, want 42", value, err)
+			}
+			outcomes <- outcome
+		}()
+	}
+
+	// Wait for every caller to be waiting on the single computation before releasing it.
+	for c.Stats().Shared < callers-1 {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+	close(outcomes)
+
+	if n := computations.Load(); n != 1 {
+		t.Errorf("Expected a single computation, got %d", n)
+	}
+	counts := map[string]int{}
+	for outcome := range outcomes {
+		counts[outcome]++
+	}
+	if counts[cache.OutcomeMiss] != 1 || counts[cache.OutcomeShared] != callers-1 {
+		t.Errorf("Unexpected outcomes %v", counts)
+	}
+}
+
+func TestCacheDoLeaderCanceled(t *testing.T) {
+	t.Parallel()
+
+	c := cache.New[int](clock.Real{}, time.Minute, 10)
+
+	started, release := make(chan struct{}), make(chan struct{})
+	leader := make(chan error, 1)
+	go func() {
+		_, _, err := c.Do(context.Background(), "key", func() (int, error) {
+			close(started)
+			<-release
+
+			return 0, context.Canceled
+		})
+		leader <- err
+	}()
+	<-started
+
+	follower := make(chan int, 1)
+	go func() {
+		value, _, err := c.Do(context.Background(), "key", func() (int, error) {
+			return 7, nil
+		})
+		if err != nil {
+			t.Errorf("Do() error = %v", err)
+		}
+		follower <- value
+	}()
+	for c.Stats().Shared < 1 {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+
+	if err := <-leader; !errors.Is(err, context.Canceled) {
+		t.Errorf("Leader error = %v, want %v", err, context.Canceled)
+	}
+	if value := <-follower; value != 7 {
+		t.Errorf("Follower = %d, want its own computation 7", value)
+	}
+}
+
+func TestCacheBypass(t *testing.T) {
+	t.Parallel()
+
+	c := cache.New[int](clock.Real{}, time.Minute, 10)
+	ctx := context.Background()
+
+	_, _, _ = c.Do(ctx, "key", func() (int, error) { return 1, nil })
+	value, outcome, _ := c.Bypass(func() (int, error) { return 2, nil })
+	if value != 2 || outcome != cache.OutcomeBypass {
+		t.Errorf("Bypass() = %d, %q, want 2, %q", value, outcome, cache.OutcomeBypass)
+	}
+	if value, _, _ = c.Do(ctx, "key", func() (int, error) { return 3, nil }); value != 1 {
+		t.Errorf("Do() after Bypass() = %d, want the cached 1", value)
+	}
+
+	var disabled *cache.Cache[int]
+	value, outcome, _ = disabled.Do(ctx, "key", func() (int, error) { return 4, nil })
+	if value != 4 || outcome != "" || disabled.Stats().Enabled {
+		t.Errorf("nil Cache Do() = %d, %q, want 4 without an outcome", value, outcome)
+	}
+}
+
+func TestCacheFlush(t *testing.T) {
+	t.Parallel()
+
+	c := cache.New[int](clock.Real{}, time.Minute, 10)
+	ctx := context.Background()
+
+	_, _, _ = c.Do(ctx, "a", func() (int, error) { return 1, nil })
+	_, _, _ = c.Do(ctx, "b", func() (int, error) { return 2, nil })
+	if flushed := c.Flush(); flushed != 2 {
+		t.Errorf("Flush() = %d, want 2", flushed)
+	}
+	if value, outcome, _ := c.Do(ctx, "a", func() (int, error) { return 3, nil }); value != 3 || outcome != cache.OutcomeMiss {
+		t.Errorf("Do() after Flush() = %d, %q, want 3, %q", value, outcome, cache.OutcomeMiss)
+	}
+
+	var disabled *cache.Cache[int]
+	if flushed := disabled.Flush(); flushed != 0 {
+		t.Errorf("nil Cache Flush() = %d, want 0", flushed)
+	}
+}
+
+// mapStore is a Store shared by caches, failing every call while failing is set.
+type mapStore struct {
+	values  map[string][]byte
+	mu      sync.Mutex
+	failing bool
+}
+
+func (s *mapStore) Get(_ context.Context, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.failing {
+		return nil, false, errCompute
+	}
+	value, ok := s.values[key]
+
+	return value, ok, nil
+}
+
+func (s *mapStore) Set(_ context.Context, key string, value []byte, _ time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.failing {
+		return errCompute
+	}
+	s.values[key] = value
+
+	return nil
+}
+
+func TestCacheWithStore(t *testing.T) {
+	t.Parallel()
+
+	store := &mapStore{values: map[string][]byte{}}
+	replica1 := cache.New(clock.Real{}, time.Minute, 10, cache.WithStore[[]string](store))
+	replica2 := cache.New(clock.Real{}, time.Minute, 10, cache.WithStore[[]string](store))
+	ctx := context.Background()
+
+	path := []string{"JFK", "LAX"}
+	if _, outcome, _ := replica1.Do(ctx, "key", func() ([]string, error) { return path, nil }); outcome != cache.OutcomeMiss {
+		t.Errorf("replica1 Do() outcome = %q, want %q", outcome, cache.OutcomeMiss)
+	}
+
+	value, outcome, err := replica2.Do(ctx, "key", func() ([]string, error) { return nil, errCompute })
+	if err != nil || outcome != cache.OutcomeHit || len(value) != 2 || value[1] != "LAX" {
+		t.Errorf("replica2 Do() = %v, %q, %v, want the value of replica1", value, outcome, err)
+	}
+	if stats := replica2.Stats(); stats.Hits != 1 || stats.StoreHits != 1 || stats.Misses != 0 {
+		t.Errorf("replica2 Stats() = %+v", stats)
+	}
+
+	store.failing = true
+	value, outcome, err = replica2.Do(ctx, "other", func() ([]string, error) { return path, nil })
+	if err != nil || outcome != cache.OutcomeMiss || len(value) != 2 {
+		t.Errorf("Do() with a failing store = %v, %q, %v, want the computed value", value, outcome, err)
+	}
+	if stats := replica2.Stats(); stats.StoreErrors != 2 {
+		t.Errorf("Expected a failed read and write, got %+v", stats)
+	}
+}
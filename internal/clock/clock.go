@@ -0,0 +1,51 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock abstracts time so that components depending on it can be driven deterministically in tests.
+type Clock interface {
+	Now() time.Time
+}
+
+// Real is the Clock backed by the system time.
+type Real struct{}
+
+func (Real) Now() time.Time {
+	return time.Now()
+}
+
+// Fake is a manually driven Clock. It is safe for concurrent use.
+type Fake struct {
+	now time.Time
+	mu  sync.RWMutex
+}
+
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return f.now
+}
+
+// Set moves the clock to the given time.
+func (f *Fake) Set(now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.now = now
+}
+
+// Advance moves the clock forward by d.
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.now = f.now.Add(d)
+}
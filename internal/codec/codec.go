@@ -0,0 +1,140 @@
+// Package codec converts JSON documents to and from other encodings: MessagePack and protobuf, for callers
+// that want to cut serialization cost, and XML, for legacy callers that only speak XML. The handler keeps
+// speaking JSON: request bodies are decoded into JSON before the handler reads them, and JSON responses are
+// encoded once written, so every endpoint taking or returning JSON supports the encodings as is. XML is
+// only an Encoder; the handler decodes XML requests into its model structs itself.
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrMalformed   = errors.New("malformed document")
+	ErrUnsupported = errors.New("unsupported value")
+)
+
+// Media types of the encodings.
+const (
+	MediaTypeMsgPack  = "application/msgpack"
+	MediaTypeProtobuf = "application/x-protobuf"
+	MediaTypeXML      = "application/xml"
+)
+
+// maxDepth is the nesting depth past which documents are rejected, so decoding cannot exhaust the stack.
+const maxDepth = 1000
+
+// Encoder is an encoding JSON documents are sent in.
+type Encoder interface {
+	// Name is the short name of the encoding, e.g. "msgpack", suffixing the ETags of responses in it.
+	Name() string
+	// MediaType is the media type of the encoding, sent in Content-Type.
+	MediaType() string
+	// Encode encodes the JSON document.
+	Encode(document []byte) ([]byte, error)
+}
+
+// Codec is an Encoder that also decodes, for request bodies.
+type Codec interface {
+	Encoder
+	// Decode decodes data into a JSON document.
+	Decode(data []byte) ([]byte, error)
+}
+
+// Lookup returns the codec of the media type, including the aliases clients commonly send, e.g.
+// application/x-msgpack; false for JSON and any other media type.
+func Lookup(mediaType string) (Codec, bool) {
+	switch strings.ToLower(mediaType) {
+	case MediaTypeMsgPack, "application/x-msgpack", "application/vnd.msgpack":
+		return MsgPack{}, true
+	case MediaTypeProtobuf, "application/protobuf", "application/vnd.google.protobuf":
+		return Protobuf{}, true
+	default:
+		return nil, false
+	}
+}
+
+// Negotiate returns the encoder of the media type the Accept header prefers, by quality then order: a
+// Codec, or XML for application/xml and text/xml; nil when it prefers JSON or any other media type, which
+// are answered with JSON.
+func Negotiate(accept string) Encoder {
+	var best Encoder
+	bestQuality := 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			if quality, err = strconv.ParseFloat(q, 64); err != nil {
+				continue
+			}
+		}
+		if quality > bestQuality {
+			best, bestQuality = encoder(mediaType), quality
+		}
+	}
+
+	return best
+}
+
+// encoder returns the encoder of the media type, nil for JSON and any other media type.
+func encoder(mediaType string) Encoder {
+	if c, ok := Lookup(mediaType); ok {
+		return c
+	}
+	switch strings.ToLower(mediaType) {
+	case MediaTypeXML, "text/xml":
+		return XML{}
+	default:
+		return nil
+	}
+}
+
+// parseJSON decodes the JSON document into nil, bool, json.Number, string, []any and map[string]any
+// values, keeping numbers as written.
+func parseJSON(document []byte) (any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(document))
+	decoder.UseNumber()
+
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMalformed, err)
+	}
+
+	return value, nil
+}
+
+// number returns the JSON number of the float, as encoding/json writes it.
+func number(f float64) (json.Number, error) {
+	b, err := json.Marshal(f)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v is not a JSON number", ErrUnsupported, f)
+	}
+
+	return json.Number(b), nil
+}
+
+// sortedKeys returns the keys of the object in order, so encodings of the same document are the same.
+func sortedKeys(object map[string]any) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	return keys
+}
+
+// unexpectedValue reports a value parseJSON does not return.
+func unexpectedValue(value any) error {
+	return fmt.Errorf("%w: %T", ErrUnsupported, value)
+}
@@ -0,0 +1,203 @@
+package codec_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dsha256/dispatcher/internal/codec"
+)
+
+func TestRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	documents := []string{
+		`null`,
+		`{"data":{"legs":[{"from":"JFK","to":"LAX"}],"linear_path":["JFK","LAX"],"total_price":199.5},"msg":"ok"}`,
+		`[0,-1,-32,-33,127,128,255,256,65535,65536,4294967296,-129,-32769,-2147483649,9223372036854775807]`,
+		`{"big":18446744073709551615,"float":0.1,"small":1e-7,"huge":1e+300}`,
+		`{"empty":{},"list":[],"nested":[[[]]],"ok":true,"no":false,"nothing":null,"text":"` + string(bytes.Repeat([]byte("x"), 70000)) + `"}`,
+	}
+	for _, c := range []codec.Codec{codec.MsgPack{}, codec.Protobuf{}} {
+		for _, document := range documents {
+			encoded, err := c.Encode([]byte(document))
+			if err != nil {
+				t.Fatalf("%s: failed to encode %.80s: %v", c.Name(), document, err)
+			}
+			decoded, err := c.Decode(encoded)
+			if err != nil {
+				t.Fatalf("%s: failed to decode %.80s: %v", c.Name(), document, err)
+			}
+
+			want := canonical(t, document)
+			if c.Name() == "protobuf" && document == documents[2] {
+				// Doubles hold integers exactly up to 2^53 only.
+				want = canonical(t, `[0,-1,-32,-33,127,128,255,256,65535,65536,4294967296,-129,-32769,-2147483649,9223372036854776000]`)
+			}
+			if c.Name() == "protobuf" && document == documents[3] {
+				want = canonical(t, `{"big":18446744073709552000,"float":0.1,"small":1e-7,"huge":1e+300}`)
+			}
+			if got := canonical(t, string(decoded)); got != want {
+				t.Errorf("%s: expected %.200s, got %.200s", c.Name(), want, got)
+			}
+		}
+	}
+}
+
+// canonical re-encodes the JSON document through MessagePack, so documents compare regardless of key order.
+func canonical(t *testing.T, document string) string {
+	t.Helper()
+
+	decoded, err := codec.MsgPack{}.Decode(mustEncode(t, document))
+	if err != nil {
+		t.Fatalf("Failed to canonicalize %.80s: %v", document, err)
+	}
+
+	return string(decoded)
+}
+
+func mustEncode(t *testing.T, document string) []byte {
+	t.Helper()
+
+	encoded, err := codec.MsgPack{}.Encode([]byte(document))
+	if err != nil {
+		t.Fatalf("Failed to encode %.80s: %v", document, err)
+	}
+
+	return encoded
+}
+
+func TestMsgPackEncoding(t *testing.T) {
+	t.Parallel()
+
+	encoded, err := codec.MsgPack{}.Encode([]byte(`{"to":"LAX","from":"JFK","n":-1,"ok":true}`))
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+	// A fixmap of 4 entries in key order.
+	want := "84a466726f6da34a464ba16effa26f6bc3a2746fa34c4158"
+	if got := hex.EncodeToString(encoded); got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+}
+
+func TestProtobufEncoding(t *testing.T) {
+	t.Parallel()
+
+	encoded, err := codec.Protobuf{}.Encode([]byte(`{"to":"LAX"}`))
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+	// Value{struct_value: Struct{fields: {"to": Value{string_value: "LAX"}}}}.
+	want := "2a0d0a0b0a02746f12051a034c4158"
+	if got := hex.EncodeToString(encoded); got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	t.Parallel()
+
+	deep := append(bytes.Repeat([]byte{0x91}, 2000), 0xc0)
+	tests := []struct {
+		wantErr error
+		codec   codec.Codec
+		name    string
+		data    string
+	}{
+		{name: "MessagePack truncated string", codec: codec.MsgPack{}, data: "a34142", wantErr: codec.ErrMalformed},
+		{name: "MessagePack trailing bytes", codec: codec.MsgPack{}, data: "c0c0", wantErr: codec.ErrMalformed},
+		{name: "MessagePack binary", codec: codec.MsgPack{}, data: "c40100", wantErr: codec.ErrUnsupported},
+		{name: "MessagePack integer key", codec: codec.MsgPack{}, data: "8101c0", wantErr: codec.ErrUnsupported},
+		{name: "MessagePack huge array", codec: codec.MsgPack{}, data: "ddffffffff", wantErr: codec.ErrMalformed},
+		{name: "MessagePack too deep", codec: codec.MsgPack{}, data: hex.EncodeToString(deep), wantErr: codec.ErrUnsupported},
+		{name: "MessagePack NaN", codec: codec.MsgPack{}, data: "cb7ff8000000000001", wantErr: codec.ErrUnsupported},
+		{name: "Protobuf truncated", codec: codec.Protobuf{}, data: "1a05414243", wantErr: codec.ErrMalformed},
+		{name: "Protobuf wrong wire type", codec: codec.Protobuf{}, data: "1001", wantErr: codec.ErrMalformed},
+		{name: "Protobuf invalid UTF-8", codec: codec.Protobuf{}, data: "1a01ff", wantErr: codec.ErrUnsupported},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			data, err := hex.DecodeString(tt.data)
+			if err != nil {
+				t.Fatalf("Invalid test data: %v", err)
+			}
+			if _, err = tt.codec.Decode(data); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		want   codec.Encoder
+		accept string
+	}{
+		{accept: "", want: nil},
+		{accept: "application/json", want: nil},
+		{accept: "application/msgpack", want: codec.MsgPack{}},
+		{accept: "application/x-protobuf", want: codec.Protobuf{}},
+		{accept: "application/json, application/msgpack", want: nil},
+		{accept: "application/json;q=0.5, application/x-msgpack", want: codec.MsgPack{}},
+		{accept: "application/protobuf;q=0.9, */*;q=0.1", want: codec.Protobuf{}},
+		{accept: "application/msgpack;q=0", want: nil},
+		{accept: "text/xml", want: codec.XML{}},
+	}
+	for _, tt := range tests {
+		if got := codec.Negotiate(tt.accept); got != tt.want {
+			t.Errorf("Negotiate(%q): expected %v, got %v", tt.accept, tt.want, got)
+		}
+	}
+}
+
+func TestXMLEncoding(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		document string
+		want     string
+	}{
+		{
+			name:     "Envelope",
+			document: `{"data":{"linear_path":["JFK","LAX"],"total_price":199.5,"id":null},"msg":"ok","ok":true}`,
+			want: `<response><data><linear_path><item>JFK</item><item>LAX</item></linear_path>` +
+				`<total_price>199.5</total_price><id/></data><msg>ok</msg><ok>true</ok></response>`,
+		},
+		{
+			name:     "Keys that are not XML names",
+			document: `{"metadata":{"fare class":"Y","1st":"<&>","xmlns":1}}`,
+			want: `<response><metadata><entry key="fare class">Y</entry><entry key="1st">&lt;&amp;&gt;</entry>` +
+				`<entry key="xmlns">1</entry></metadata></response>`,
+		},
+		{
+			name:     "Array",
+			document: `[[],{}]`,
+			want:     `<response><item></item><item></item></response>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			encoded, err := codec.XML{}.Encode([]byte(tt.document))
+			if err != nil {
+				t.Fatalf("Failed to encode: %v", err)
+			}
+			if got := strings.TrimPrefix(string(encoded), xml.Header); got != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+			if err = xml.Unmarshal(encoded, new(struct{})); err != nil {
+				t.Errorf("Expected well-formed XML, got %v", err)
+			}
+		})
+	}
+}
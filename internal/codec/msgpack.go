@@ -0,0 +1,324 @@
+package codec
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"unicode/utf8"
+)
+
+// MsgPack is the MessagePack encoding, https://msgpack.org. Whole numbers fitting 64 bits are integers,
+// other numbers float64; decoding also takes float32, and rejects binary and extension values, which JSON
+// has no counterpart for.
+type MsgPack struct{}
+
+func (MsgPack) Name() string {
+	return "msgpack"
+}
+
+func (MsgPack) MediaType() string {
+	return MediaTypeMsgPack
+}
+
+func (MsgPack) Encode(document []byte) ([]byte, error) {
+	value, err := parseJSON(document)
+	if err != nil {
+		return nil, err
+	}
+
+	return appendMsgPack(make([]byte, 0, len(document)), value)
+}
+
+func (MsgPack) Decode(data []byte) ([]byte, error) {
+	d := &msgpackDecoder{data: data}
+	value, err := d.value(0)
+	if err != nil {
+		return nil, err
+	}
+	if d.pos != len(d.data) {
+		return nil, fmt.Errorf("%w: %d trailing bytes", ErrMalformed, len(d.data)-d.pos)
+	}
+
+	return json.Marshal(value)
+}
+
+func appendMsgPack(buf []byte, value any) ([]byte, error) {
+	switch v := value.(type) {
+	case nil:
+		return append(buf, 0xc0), nil
+	case bool:
+		if v {
+			return append(buf, 0xc3), nil
+		}
+
+		return append(buf, 0xc2), nil
+	case json.Number:
+		return appendMsgPackNumber(buf, v)
+	case string:
+		return append(appendMsgPackHeader(buf, len(v), 0xa0, 32, 0xd9, 0xda, 0xdb), v...), nil
+	case []any:
+		buf = appendMsgPackHeader(buf, len(v), 0x90, 16, 0, 0xdc, 0xdd)
+		for _, item := range v {
+			var err error
+			if buf, err = appendMsgPack(buf, item); err != nil {
+				return nil, err
+			}
+		}
+
+		return buf, nil
+	case map[string]any:
+		buf = appendMsgPackHeader(buf, len(v), 0x80, 16, 0, 0xde, 0xdf)
+		for _, key := range sortedKeys(v) {
+			buf = append(appendMsgPackHeader(buf, len(key), 0xa0, 32, 0xd9, 0xda, 0xdb), key...)
+			var err error
+			if buf, err = appendMsgPack(buf, v[key]); err != nil {
+				return nil, err
+			}
+		}
+
+		return buf, nil
+	default:
+		return nil, unexpectedValue(value)
+	}
+}
+
+// appendMsgPackNumber appends the number as the smallest integer holding it, or as a float64.
+func appendMsgPackNumber(buf []byte, n json.Number) ([]byte, error) {
+	if i, err := n.Int64(); err == nil {
+		return appendMsgPackInt(buf, i), nil
+	}
+	if u, err := strconv.ParseUint(n.String(), 10, 64); err == nil {
+		return binary.BigEndian.AppendUint64(append(buf, 0xcf), u), nil
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return nil, fmt.Errorf("%w: number %s", ErrUnsupported, n)
+	}
+
+	return binary.BigEndian.AppendUint64(append(buf, 0xcb), math.Float64bits(f)), nil
+}
+
+func appendMsgPackInt(buf []byte, i int64) []byte {
+	switch {
+	case i >= 0 && i <= math.MaxInt8, i < 0 && i >= -32:
+		return append(buf, byte(i))
+	case i >= 0 && i <= math.MaxUint8:
+		return append(buf, 0xcc, byte(i))
+	case i >= 0 && i <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(buf, 0xcd), uint16(i))
+	case i >= 0 && i <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(buf, 0xce), uint32(i))
+	case i >= 0:
+		return binary.BigEndian.AppendUint64(append(buf, 0xcf), uint64(i))
+	case i >= math.MinInt8:
+		return append(buf, 0xd0, byte(i))
+	case i >= math.MinInt16:
+		return binary.BigEndian.AppendUint16(append(buf, 0xd1), uint16(i))
+	case i >= math.MinInt32:
+		return binary.BigEndian.AppendUint32(append(buf, 0xd2), uint32(i))
+	default:
+		return binary.BigEndian.AppendUint64(append(buf, 0xd3), uint64(i))
+	}
+}
+
+// appendMsgPackHeader appends the header of a string, array or map of n elements: the fix format below
+// fixLimit, then the 8-bit (for strings only, 0 otherwise), 16-bit and 32-bit formats.
+func appendMsgPackHeader(buf []byte, n int, fix byte, fixLimit int, format8, format16, format32 byte) []byte {
+	switch {
+	case n < fixLimit:
+		return append(buf, fix|byte(n))
+	case format8 != 0 && n <= math.MaxUint8:
+		return append(buf, format8, byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(buf, format16), uint16(n))
+	default:
+		return binary.BigEndian.AppendUint32(append(buf, format32), uint32(n)) //nolint:gosec // JSON documents stay below 4 GiB.
+	}
+}
+
+// msgpackDecoder decodes a MessagePack value into the values parseJSON returns.
+type msgpackDecoder struct {
+	data []byte
+	pos  int
+}
+
+//nolint:cyclop,gocyclo // One case per format family.
+func (d *msgpackDecoder) value(depth int) (any, error) {
+	if depth > maxDepth {
+		return nil, fmt.Errorf("%w: nested deeper than %d", ErrUnsupported, maxDepth)
+	}
+	format, err := d.byte()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case format <= 0x7f:
+		return json.Number(strconv.Itoa(int(format))), nil
+	case format >= 0xe0:
+		return json.Number(strconv.Itoa(int(int8(format)))), nil
+	case format&0xf0 == 0x80:
+		return d.object(int(format&0x0f), depth)
+	case format&0xf0 == 0x90:
+		return d.array(int(format&0x0f), depth)
+	case format&0xe0 == 0xa0:
+		return d.string(int(format & 0x1f))
+	}
+
+	switch format {
+	case 0xc0:
+		return nil, nil //nolint:nilnil // JSON null.
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xca:
+		b, err := d.take(4)
+		if err != nil {
+			return nil, err
+		}
+
+		return number(float64(math.Float32frombits(binary.BigEndian.Uint32(b))))
+	case 0xcb:
+		b, err := d.take(8)
+		if err != nil {
+			return nil, err
+		}
+
+		return number(math.Float64frombits(binary.BigEndian.Uint64(b)))
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		u, err := d.uint(1 << (format - 0xcc))
+		if err != nil {
+			return nil, err
+		}
+
+		return json.Number(strconv.FormatUint(u, 10)), nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (format - 0xd0)
+		u, err := d.uint(size)
+		if err != nil {
+			return nil, err
+		}
+		// Sign-extend the size-byte integer.
+		shift := 64 - 8*size
+
+		return json.Number(strconv.FormatInt(int64(u<<shift)>>shift, 10)), nil //nolint:gosec // Two's complement.
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.length(1 << (format - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+
+		return d.string(n)
+	case 0xdc, 0xdd:
+		n, err := d.length(2 << (format - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+
+		return d.array(n, depth)
+	case 0xde, 0xdf:
+		n, err := d.length(2 << (format - 0xde))
+		if err != nil {
+			return nil, err
+		}
+
+		return d.object(n, depth)
+	default:
+		return nil, fmt.Errorf("%w: MessagePack format 0x%02x, e.g. binary or extension", ErrUnsupported, format)
+	}
+}
+
+func (d *msgpackDecoder) object(n, depth int) (any, error) {
+	if n > len(d.data)-d.pos {
+		return nil, fmt.Errorf("%w: map of %d entries past the end", ErrMalformed, n)
+	}
+	object := make(map[string]any, n)
+	for range n {
+		key, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		name, ok := key.(string)
+		if !ok {
+			return nil, fmt.Errorf("%w: map key %v, want a string", ErrUnsupported, key)
+		}
+		if object[name], err = d.value(depth + 1); err != nil {
+			return nil, err
+		}
+	}
+
+	return object, nil
+}
+
+func (d *msgpackDecoder) array(n, depth int) (any, error) {
+	if n > len(d.data)-d.pos {
+		return nil, fmt.Errorf("%w: array of %d items past the end", ErrMalformed, n)
+	}
+	array := make([]any, n)
+	for i := range array {
+		var err error
+		if array[i], err = d.value(depth + 1); err != nil {
+			return nil, err
+		}
+	}
+
+	return array, nil
+}
+
+func (d *msgpackDecoder) string(n int) (any, error) {
+	b, err := d.take(n)
+	if err != nil {
+		return nil, err
+	}
+	if !utf8.Valid(b) {
+		return nil, fmt.Errorf("%w: string is not UTF-8", ErrUnsupported)
+	}
+
+	return string(b), nil
+}
+
+// length reads a big-endian length of size bytes.
+func (d *msgpackDecoder) length(size int) (int, error) {
+	u, err := d.uint(size)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(u), nil //nolint:gosec // At most 32 bits.
+}
+
+// uint reads a big-endian unsigned integer of size bytes.
+func (d *msgpackDecoder) uint(size int) (uint64, error) {
+	b, err := d.take(size)
+	if err != nil {
+		return 0, err
+	}
+	var u uint64
+	for _, c := range b {
+		u = u<<8 | uint64(c)
+	}
+
+	return u, nil
+}
+
+func (d *msgpackDecoder) byte() (byte, error) {
+	b, err := d.take(1)
+	if err != nil {
+		return 0, err
+	}
+
+	return b[0], nil
+}
+
+func (d *msgpackDecoder) take(n int) ([]byte, error) {
+	if n > len(d.data)-d.pos {
+		return nil, fmt.Errorf("%w: unexpected end of MessagePack data", ErrMalformed)
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+
+	return b, nil
+}
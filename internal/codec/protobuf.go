@@ -0,0 +1,299 @@
+package codec
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"unicode/utf8"
+)
+
+// Protobuf is the protobuf encoding of documents as the well-known google.protobuf.Value message
+// (google/protobuf/struct.proto), which every protobuf library ships with: objects are Structs, arrays
+// ListValues, and numbers doubles, exact up to 2^53. Fields of Structs are encoded in key order.
+type Protobuf struct{}
+
+// Field numbers of google.protobuf.Value, Struct and ListValue, and of the entries of Struct.fields.
+const (
+	valueNull   = 1
+	valueNumber = 2
+	valueString = 3
+	valueBool   = 4
+	valueStruct = 5
+	valueList   = 6
+
+	structFields = 1
+	listValues   = 1
+	entryKey     = 1
+	entryValue   = 2
+)
+
+// Wire types of protobuf fields.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+func (Protobuf) Name() string {
+	return "protobuf"
+}
+
+func (Protobuf) MediaType() string {
+	return MediaTypeProtobuf
+}
+
+func (Protobuf) Encode(document []byte) ([]byte, error) {
+	value, err := parseJSON(document)
+	if err != nil {
+		return nil, err
+	}
+
+	return appendProtoValue(make([]byte, 0, len(document)), value)
+}
+
+func (Protobuf) Decode(data []byte) ([]byte, error) {
+	value, err := decodeProtoValue(data, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(value)
+}
+
+// appendProtoValue appends the fields of the google.protobuf.Value of the value.
+func appendProtoValue(buf []byte, value any) ([]byte, error) {
+	switch v := value.(type) {
+	case nil:
+		return append(appendTag(buf, valueNull, wireVarint), 0), nil
+	case bool:
+		b := byte(0)
+		if v {
+			b = 1
+		}
+
+		return append(appendTag(buf, valueBool, wireVarint), b), nil
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return nil, fmt.Errorf("%w: number %s", ErrUnsupported, v)
+		}
+
+		return binary.LittleEndian.AppendUint64(appendTag(buf, valueNumber, wireFixed64), math.Float64bits(f)), nil
+	case string:
+		return appendBytesField(buf, valueString, []byte(v)), nil
+	case []any:
+		var list []byte
+		for _, item := range v {
+			encoded, err := appendProtoValue(nil, item)
+			if err != nil {
+				return nil, err
+			}
+			list = appendBytesField(list, listValues, encoded)
+		}
+
+		return appendBytesField(buf, valueList, list), nil
+	case map[string]any:
+		var fields []byte
+		for _, key := range sortedKeys(v) {
+			encoded, err := appendProtoValue(nil, v[key])
+			if err != nil {
+				return nil, err
+			}
+			entry := appendBytesField(appendBytesField(nil, entryKey, []byte(key)), entryValue, encoded)
+			fields = appendBytesField(fields, structFields, entry)
+		}
+
+		return appendBytesField(buf, valueStruct, fields), nil
+	default:
+		return nil, unexpectedValue(value)
+	}
+}
+
+func appendTag(buf []byte, field, wireType int) []byte {
+	return binary.AppendUvarint(buf, uint64(field<<3|wireType)) //nolint:gosec // Small constants.
+}
+
+func appendBytesField(buf []byte, field int, b []byte) []byte {
+	buf = binary.AppendUvarint(appendTag(buf, field, wireBytes), uint64(len(b)))
+
+	return append(buf, b...)
+}
+
+// protoField is a field of a protobuf message: the value of varint and fixed fields, the bytes of
+// length-delimited ones.
+type protoField struct {
+	bytes    []byte
+	number   int
+	wireType int
+	value    uint64
+}
+
+// protoFields decodes the fields of a protobuf message, in order.
+func protoFields(data []byte) ([]protoField, error) {
+	var fields []protoField
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		if n <= 0 || tag>>3 == 0 || tag>>3 > math.MaxInt32 {
+			return nil, fmt.Errorf("%w: invalid protobuf field tag", ErrMalformed)
+		}
+		data = data[n:]
+
+		field := protoField{number: int(tag >> 3), wireType: int(tag & 0x7)} //nolint:gosec // Checked above.
+		switch field.wireType {
+		case wireVarint:
+			if field.value, n = binary.Uvarint(data); n <= 0 {
+				return nil, fmt.Errorf("%w: invalid protobuf varint", ErrMalformed)
+			}
+		case wireFixed64:
+			if n = 8; len(data) < n {
+				return nil, fmt.Errorf("%w: truncated protobuf field", ErrMalformed)
+			}
+			field.value = binary.LittleEndian.Uint64(data)
+		case wireFixed32:
+			if n = 4; len(data) < n {
+				return nil, fmt.Errorf("%w: truncated protobuf field", ErrMalformed)
+			}
+			field.value = uint64(binary.LittleEndian.Uint32(data))
+		case wireBytes:
+			length, m := binary.Uvarint(data)
+			if m <= 0 || length > uint64(len(data)-m) {
+				return nil, fmt.Errorf("%w: truncated protobuf field", ErrMalformed)
+			}
+			field.bytes = data[m : m+int(length)] //nolint:gosec // Checked above.
+			n = m + int(length)                   //nolint:gosec // Checked above.
+		default:
+			return nil, fmt.Errorf("%w: protobuf wire type %d", ErrUnsupported, field.wireType)
+		}
+		data = data[n:]
+		fields = append(fields, field)
+	}
+
+	return fields, nil
+}
+
+// decodeProtoValue decodes a google.protobuf.Value into the values parseJSON returns. As in protobuf, the
+// last of the oneof fields wins and unknown fields are skipped; a Value without any is null.
+func decodeProtoValue(data []byte, depth int) (any, error) {
+	if depth > maxDepth {
+		return nil, fmt.Errorf("%w: nested deeper than %d", ErrUnsupported, maxDepth)
+	}
+	fields, err := protoFields(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var value any
+	for _, field := range fields {
+		if field.number < valueNull || field.number > valueList {
+			continue
+		}
+		if want := valueWireType(field.number); field.wireType != want {
+			return nil, fmt.Errorf("%w: google.protobuf.Value field %d has wire type %d, want %d",
+				ErrMalformed, field.number, field.wireType, want)
+		}
+
+		switch field.number {
+		case valueNull:
+			value = nil
+		case valueNumber:
+			if value, err = number(math.Float64frombits(field.value)); err != nil {
+				return nil, err
+			}
+		case valueString:
+			if !utf8.Valid(field.bytes) {
+				return nil, fmt.Errorf("%w: string is not UTF-8", ErrUnsupported)
+			}
+			value = string(field.bytes)
+		case valueBool:
+			value = field.value != 0
+		case valueStruct:
+			if value, err = decodeProtoStruct(field.bytes, depth); err != nil {
+				return nil, err
+			}
+		case valueList:
+			if value, err = decodeProtoList(field.bytes, depth); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return value, nil
+}
+
+// valueWireType is the wire type of the field of google.protobuf.Value.
+func valueWireType(field int) int {
+	switch field {
+	case valueNull, valueBool:
+		return wireVarint
+	case valueNumber:
+		return wireFixed64
+	default:
+		return wireBytes
+	}
+}
+
+func decodeProtoStruct(data []byte, depth int) (map[string]any, error) {
+	fields, err := protoFields(data)
+	if err != nil {
+		return nil, err
+	}
+
+	object := make(map[string]any, len(fields))
+	for _, field := range fields {
+		if field.number != structFields {
+			continue
+		}
+		if field.wireType != wireBytes {
+			return nil, fmt.Errorf("%w: google.protobuf.Struct entry has wire type %d", ErrMalformed, field.wireType)
+		}
+		entry, err := protoFields(field.bytes)
+		if err != nil {
+			return nil, err
+		}
+
+		var key string
+		var value any
+		for _, f := range entry {
+			switch {
+			case f.number == entryKey && f.wireType == wireBytes:
+				if !utf8.Valid(f.bytes) {
+					return nil, fmt.Errorf("%w: key is not UTF-8", ErrUnsupported)
+				}
+				key = string(f.bytes)
+			case f.number == entryValue && f.wireType == wireBytes:
+				if value, err = decodeProtoValue(f.bytes, depth+1); err != nil {
+					return nil, err
+				}
+			}
+		}
+		object[key] = value
+	}
+
+	return object, nil
+}
+
+func decodeProtoList(data []byte, depth int) ([]any, error) {
+	fields, err := protoFields(data)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]any, 0, len(fields))
+	for _, field := range fields {
+		if field.number != listValues {
+			continue
+		}
+		if field.wireType != wireBytes {
+			return nil, fmt.Errorf("%w: google.protobuf.ListValue item has wire type %d", ErrMalformed, field.wireType)
+		}
+		item, err := decodeProtoValue(field.bytes, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, item)
+	}
+
+	return list, nil
+}
@@ -0,0 +1,140 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// XML encodes JSON documents as XML, in the document's order, under a <response> root: object members are
+// elements named after their key, or <entry key="…"> when the key is not an XML name, array items are <item>
+// elements, null is an empty element and other values are text.
+//
+//	{"data": {"linear_path": ["JFK", "LAX"]}, "msg": "ok"}
+//
+// is encoded as
+//
+//	<response><data><linear_path><item>JFK</item><item>LAX</item></linear_path></data><msg>ok</msg></response>
+type XML struct{}
+
+// xmlRoot is the root element of XML documents.
+const xmlRoot = "response"
+
+func (XML) Name() string {
+	return "xml"
+}
+
+func (XML) MediaType() string {
+	return MediaTypeXML
+}
+
+func (XML) Encode(document []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(document))
+	decoder.UseNumber()
+
+	var buf bytes.Buffer
+	buf.Grow(2 * len(document))
+	buf.WriteString(xml.Header)
+	if err := encodeXMLElement(&buf, decoder, xmlRoot); err != nil {
+		return nil, err
+	}
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: data after the JSON document", ErrMalformed)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// encodeXMLElement writes the next JSON value of the decoder as the element opened by start, its name and
+// attributes.
+func encodeXMLElement(buf *bytes.Buffer, decoder *json.Decoder, start string) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrMalformed, err)
+	}
+	name, _, _ := strings.Cut(start, " ")
+
+	switch v := token.(type) {
+	case nil:
+		buf.WriteString("<" + start + "/>")
+
+		return nil
+	case json.Delim:
+		buf.WriteString("<" + start + ">")
+		for decoder.More() {
+			child := "item"
+			if v == '{' {
+				if child, err = xmlMemberStart(decoder); err != nil {
+					return err
+				}
+			}
+			if err = encodeXMLElement(buf, decoder, child); err != nil {
+				return err
+			}
+		}
+		// The closing delimiter.
+		if _, err = decoder.Token(); err != nil {
+			return fmt.Errorf("%w: %w", ErrMalformed, err)
+		}
+	case string:
+		buf.WriteString("<" + start + ">")
+		if err = xml.EscapeText(buf, []byte(v)); err != nil {
+			return err
+		}
+	case json.Number:
+		buf.WriteString("<" + start + ">" + v.String())
+	case bool:
+		buf.WriteString("<" + start + ">" + strconv.FormatBool(v))
+	default:
+		return unexpectedValue(token)
+	}
+	buf.WriteString("</" + name + ">")
+
+	return nil
+}
+
+// xmlMemberStart reads the key of the next object member and returns the start of its element.
+func xmlMemberStart(decoder *json.Decoder) (string, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrMalformed, err)
+	}
+	key, ok := token.(string)
+	if !ok {
+		return "", unexpectedValue(token)
+	}
+	if isXMLName(key) {
+		return key, nil
+	}
+
+	var attr strings.Builder
+	if err = xml.EscapeText(&attr, []byte(key)); err != nil {
+		return "", err
+	}
+
+	return `entry key="` + attr.String() + `"`, nil
+}
+
+// isXMLName reports whether the key is a valid XML element name that does not start with "xml", which
+// names are reserved; colons are left out, as they separate namespaces.
+func isXMLName(key string) bool {
+	if key == "" || strings.HasPrefix(strings.ToLower(key), "xml") {
+		return false
+	}
+	for i, c := range key {
+		switch {
+		case c == '_' || unicode.IsLetter(c):
+		case i > 0 && (c == '-' || c == '.' || unicode.IsDigit(c)):
+		default:
+			return false
+		}
+	}
+
+	return true
+}
@@ -5,10 +5,94 @@ import (
 	"time"
 
 	"gopkg.in/yaml.v3"
+
+	"github.com/dsha256/dispatcher/internal/audit"
+	"github.com/dsha256/dispatcher/internal/breaker"
+	"github.com/dsha256/dispatcher/internal/degradation"
+	"github.com/dsha256/dispatcher/internal/dispatcher"
+	"github.com/dsha256/dispatcher/internal/emissions"
+	"github.com/dsha256/dispatcher/internal/idempotency"
+	"github.com/dsha256/dispatcher/internal/jobs"
+	"github.com/dsha256/dispatcher/internal/limits"
+	"github.com/dsha256/dispatcher/internal/listener"
+	"github.com/dsha256/dispatcher/internal/logging"
+	"github.com/dsha256/dispatcher/internal/middleware"
+	"github.com/dsha256/dispatcher/internal/nats"
+	"github.com/dsha256/dispatcher/internal/pricing"
+	"github.com/dsha256/dispatcher/internal/remote"
+	"github.com/dsha256/dispatcher/internal/reporting"
+	"github.com/dsha256/dispatcher/internal/shadow"
+	"github.com/dsha256/dispatcher/internal/shedding"
+	"github.com/dsha256/dispatcher/internal/store/postgres"
+	"github.com/dsha256/dispatcher/internal/store/redis"
+	"github.com/dsha256/dispatcher/internal/tlsconfig"
 )
 
 type Config struct {
-	Server Server `json:"server" yaml:"server"`
+	Server     Server     `json:"server"     yaml:"server"`
+	Dispatcher Dispatcher `json:"dispatcher" yaml:"dispatcher"`
+	Airports   Airports   `json:"airports"   yaml:"airports"`
+	// Blackout bounds the blackout calendars of each tenant.
+	Blackout Blackout `json:"blackout" yaml:"blackout"`
+	Mirror   Mirror   `json:"mirror"   yaml:"mirror"`
+	// Shadow also runs reconstructions through an alternate strategy in the background, reporting divergences.
+	Shadow      shadow.Config `json:"shadow"     yaml:"shadow"`
+	Emissions   Emissions     `json:"emissions"   yaml:"emissions"`
+	Degradation Degradation   `json:"degradation" yaml:"degradation"`
+	// Limits are the soft and hard ticket limits; 0 disables a limit.
+	Limits limits.Limits `json:"limits" yaml:"limits"`
+	CSV    CSV           `json:"csv"    yaml:"csv"`
+	// Pricing converts the totals of the ticket prices per currency to a grand total.
+	Pricing Pricing `json:"pricing" yaml:"pricing"`
+	// Upload caps the files of the upload endpoint and sets the size above which they are reconstructed as jobs.
+	Upload Upload `json:"upload" yaml:"upload"`
+	// Jobs runs the reconstructions of large uploaded files in the background.
+	Jobs jobs.Config `json:"jobs" yaml:"jobs"`
+	// Sources lets requests reference their ticket file by URL, on S3, Cloud Storage or an http(s) server.
+	Sources remote.Config `json:"sources" yaml:"sources"`
+	// Messages override the default success messages by key, e.g. "service.live".
+	Messages map[string]string `json:"messages" yaml:"messages"`
+	// I18n translates the success and error messages into the language of the Accept-Language header.
+	I18n      I18n            `json:"i18n"      yaml:"i18n"`
+	Inspector Inspector       `json:"inspector" yaml:"inspector"`
+	Cache     Cache           `json:"cache"     yaml:"cache"`
+	Redis     redis.Config    `json:"redis"     yaml:"redis"`
+	Storage   Storage         `json:"storage"   yaml:"storage"`
+	Postgres  postgres.Config `json:"postgres"  yaml:"postgres"`
+	// CircuitBreaker wraps Redis and Postgres in circuit breakers, so their failures degrade caching and
+	// storage instead of slowing reconstructions down.
+	CircuitBreaker breaker.Config `json:"circuit_breaker" yaml:"circuit_breaker"`
+	NATS           NATS           `json:"nats"      yaml:"nats"`
+	Events         Events         `json:"events"    yaml:"events"`
+	// Compression negotiates gzip responses and accepts gzip request bodies.
+	Compression middleware.Compression `json:"compression" yaml:"compression"`
+	// CORS lets browser-based tools call the service directly.
+	CORS middleware.CORS `json:"cors" yaml:"cors"`
+	// Versioning announces the deprecation of API v1 in favor of v2.
+	Versioning middleware.Versioning `json:"versioning" yaml:"versioning"`
+	// Log is the format and startup level of the logs; the level can be changed at runtime.
+	Log logging.Config `json:"log" yaml:"log"`
+	// AccessLog is the format and sampling of the access log.
+	AccessLog middleware.AccessLog `json:"access_log" yaml:"access_log"`
+	// Admin authenticates the admin endpoints changing the service or exposing its internals.
+	Admin middleware.Admin `json:"admin" yaml:"admin"`
+	Debug Debug            `json:"debug" yaml:"debug"`
+	// Signing verifies the HMAC signatures of requests, with per-client shared secrets.
+	Signing middleware.Signing `json:"signing" yaml:"signing"`
+	// Tenants authenticates the tenant of client requests, by API key or signing client.
+	Tenants middleware.Tenants `json:"tenants" yaml:"tenants"`
+	// Shedding caps the reconstructions running at once.
+	Shedding shedding.Config `json:"shedding" yaml:"shedding"`
+	// Idempotency replays the responses of POST requests retried with the same Idempotency-Key.
+	Idempotency idempotency.Config `json:"idempotency" yaml:"idempotency"`
+	// Recovery is the error tracker panics are reported to.
+	Recovery reporting.Config `json:"recovery" yaml:"recovery"`
+	// Audit keeps a tamper-evident trail of the reconstruction requests, apart from the logs.
+	Audit audit.Config `json:"audit" yaml:"audit"`
+	// Reload applies the reloadable settings of the configuration file while the service runs.
+	Reload Reload `json:"reload" yaml:"reload"`
+	// Maintenance closes the client endpoints with 503 Service Unavailable, like PUT /api/v1/admin/maintenance.
+	Maintenance bool `json:"maintenance" yaml:"maintenance"`
 }
 
 type Server struct {
@@ -16,6 +100,202 @@ type Server struct {
 	ReadTimeout       time.Duration `json:"read_timeout"        yaml:"read_timeout"`
 	ReadHeaderTimeout time.Duration `json:"read_header_timeout" yaml:"read_header_timeout"`
 	WriteTimeout      time.Duration `json:"write_timeout"       yaml:"write_timeout"`
+	// Listener selects the socket: TCP on Port, a Unix socket, or the socket of systemd socket activation.
+	Listener listener.Config `json:"listener" yaml:"listener"`
+	// Timeouts bound handlers per route; WriteTimeout must outlast the longest for its 504 to be sent.
+	Timeouts middleware.Timeouts `json:"timeouts" yaml:"timeouts"`
+	// TLS terminates TLS, optionally verifying client certificates.
+	TLS tlsconfig.Config `json:"tls" yaml:"tls"`
+}
+
+// Reload re-reads the configuration file on SIGHUP, and when it changes if WatchInterval is set.
+type Reload struct {
+	// WatchInterval is how often the modification time of the file is checked; 0 only reloads on SIGHUP.
+	WatchInterval time.Duration `json:"watch_interval" yaml:"watch_interval"`
+}
+
+// Debug serves the runtime debug endpoints, pprof profiles, expvar variables and the build information, to
+// admin requests.
+type Debug struct {
+	Enabled bool `json:"enabled" yaml:"enabled"`
+}
+
+type Dispatcher struct {
+	// Aliases replace airport codes in every request, on top of the request's own aliases.
+	Aliases dispatcher.Aliases `json:"aliases" yaml:"aliases"`
+	// MinLayover is the minimum time between an arrival and the next departure
+	// enforced when tickets carry departure/arrival times.
+	MinLayover time.Duration `json:"min_layover" yaml:"min_layover"`
+	// DefaultStrategy is the strategy of requests not naming one, "default" when empty.
+	DefaultStrategy string `json:"default_strategy" yaml:"default_strategy"`
+	// TieBreak is the tie-break policy of requests not naming one, "smallest_first" when empty.
+	TieBreak string `json:"tie_break" yaml:"tie_break"`
+	// AllowDuplicates accepts repeated identical tickets, unless a request opts out.
+	AllowDuplicates bool `json:"allow_duplicates" yaml:"allow_duplicates"`
+	// StrategyHeader lets allow-listed clients select the strategy of their requests with X-Dispatcher-Strategy.
+	StrategyHeader StrategyHeader `json:"strategy_header" yaml:"strategy_header"`
+	// NormalizeCodes uppercases and trims airport codes, unless a request opts out.
+	NormalizeCodes bool `json:"normalize_codes" yaml:"normalize_codes"`
+}
+
+// StrategyHeader honors the X-Dispatcher-Strategy header of the requests of the listed tenants and clients, and
+// refuses the gated strategies to the others, e.g. to canary a strategy before making it the default.
+type StrategyHeader struct {
+	// Strategies are the gated strategies, which only the listed tenants and clients may run.
+	Strategies []string `json:"strategies" yaml:"strategies"`
+	// Tenants are the allowed tenants, authenticated by API key or signing client (see Config.Tenants).
+	Tenants []string `json:"tenants" yaml:"tenants"`
+	// Clients are the allowed clients, authenticated by request signing.
+	Clients []string `json:"clients" yaml:"clients"`
+	Enabled bool     `json:"enabled" yaml:"enabled"`
+}
+
+type Airports struct {
+	// Dataset is the path of a CSV airports dataset replacing the embedded one, a sample of major airports, e.g.
+	// a complete export in the same columns; the embedded one when empty.
+	Dataset string `json:"dataset" yaml:"dataset"`
+	// Strict rejects tickets whose codes are missing from the airports dataset, unless a request opts out.
+	Strict bool `json:"strict" yaml:"strict"`
+}
+
+// Blackout caps the blackout calendars a tenant may keep and the dates each may hold; 0 keeps the defaults, 64
+// calendars of 1024 dates.
+type Blackout struct {
+	MaxCalendars int `json:"max_calendars" yaml:"max_calendars"`
+	MaxDates     int `json:"max_dates"     yaml:"max_dates"`
+}
+
+type Mirror struct {
+	// CanaryURL is the base URL requests are mirrored to; mirroring is disabled when empty.
+	CanaryURL   string        `json:"canary_url"    yaml:"canary_url"`
+	Paths       []string      `json:"paths"         yaml:"paths"`
+	Percentage  float64       `json:"percentage"    yaml:"percentage"`
+	Timeout     time.Duration `json:"timeout"       yaml:"timeout"`
+	MaxInFlight int           `json:"max_in_flight" yaml:"max_in_flight"`
+}
+
+type Emissions struct {
+	// Bands override the default emission factors when set.
+	Bands          []emissions.Band `json:"bands"           yaml:"bands"`
+	DistanceUplift float64          `json:"distance_uplift" yaml:"distance_uplift"`
+	Enabled        bool             `json:"enabled"         yaml:"enabled"`
+}
+
+// Coefficients returns the configured emission coefficients, falling back to the defaults.
+func (e Emissions) Coefficients() emissions.Coefficients {
+	coefficients := emissions.DefaultCoefficients()
+	if len(e.Bands) > 0 {
+		coefficients.Bands = e.Bands
+	}
+	if e.DistanceUplift != 0 {
+		coefficients.DistanceUplift = e.DistanceUplift
+	}
+
+	return coefficients
+}
+
+type CSV struct {
+	// Columns is the default column mapping of text/csv request bodies, e.g. "from,to,price,departs_at".
+	Columns string `json:"columns" yaml:"columns"`
+}
+
+type I18n struct {
+	// Dir holds translations overriding or adding to the embedded ones, one <language>.json file per
+	// language; only the embedded translations are used when empty.
+	Dir     string `json:"dir"     yaml:"dir"`
+	Enabled bool   `json:"enabled" yaml:"enabled"`
+}
+
+type Pricing struct {
+	// ExchangeRates are the default exchange rates, e.g. {currency: USD, rates: {EUR: 1.08}}; requests may carry
+	// their own. Without rates prices are only totaled per currency.
+	ExchangeRates *pricing.Rates `json:"exchange_rates" yaml:"exchange_rates"`
+}
+
+type Upload struct {
+	// MaxBytes caps the size of uploaded files, 32 MiB when 0.
+	MaxBytes int64 `json:"max_bytes" yaml:"max_bytes"`
+	// AsyncBytes is the size above which uploaded files are reconstructed as jobs when jobs are enabled,
+	// 1 MiB when 0.
+	AsyncBytes int64 `json:"async_bytes" yaml:"async_bytes"`
+}
+
+type Inspector struct {
+	// Buffer is the number of events buffered per feed subscriber before events are dropped.
+	Buffer  int  `json:"buffer"  yaml:"buffer"`
+	Enabled bool `json:"enabled" yaml:"enabled"`
+}
+
+// Cache backends.
+const (
+	CacheBackendMemory = "memory"
+	CacheBackendRedis  = "redis"
+)
+
+type Cache struct {
+	// Backend is where cached reconstructions are kept: "memory" (the default) per replica, or "redis"
+	// to share them between replicas, with the most recently used ones also kept in memory.
+	Backend string `json:"backend" yaml:"backend"`
+	// TTL is how long a reconstruction is served from the cache.
+	TTL time.Duration `json:"ttl" yaml:"ttl"`
+	// MaxEntries bounds the number of cached reconstructions; the least recently used are evicted first.
+	MaxEntries int  `json:"max_entries" yaml:"max_entries"`
+	Enabled    bool `json:"enabled"     yaml:"enabled"`
+}
+
+// Storage backends.
+const (
+	StorageBackendMemory   = "memory"
+	StorageBackendPostgres = "postgres"
+)
+
+type Storage struct {
+	// Backend is where reconstructed itineraries are saved: "memory" (the default) per replica and lost
+	// on restart, or "postgres" through the postgres section.
+	Backend string `json:"backend" yaml:"backend"`
+	Enabled bool   `json:"enabled" yaml:"enabled"`
+}
+
+// NATS answers reconstruction requests received over NATS, alongside HTTP.
+type NATS struct {
+	// Subject receives requests in the schema of POST /api/v1/dispatcher/itinerary, answered with its
+	// response envelope.
+	Subject string `json:"subject" yaml:"subject"`
+	// Queue is the queue group of the subscription, so replicas sharing it each answer a share of the requests.
+	Queue       string `json:"queue"   yaml:"queue"`
+	nats.Config `yaml:",inline"`
+	Enabled     bool `json:"enabled" yaml:"enabled"`
+}
+
+// Event publishers.
+const (
+	EventPublisherLog  = "log"
+	EventPublisherNATS = "nats"
+)
+
+type Events struct {
+	// Publisher is where domain events go: "log" (the default) as log records, or "nats" as JSON on
+	// Subject, through the connection of the nats section.
+	Publisher string `json:"publisher" yaml:"publisher"`
+	Subject   string `json:"subject"   yaml:"subject"`
+	Enabled   bool   `json:"enabled"   yaml:"enabled"`
+}
+
+type Degradation struct {
+	// Ladder overrides the default degradation steps; each activates once the load
+	// (in-flight requests / capacity) reaches its at_load threshold.
+	Ladder   []degradation.Rung `json:"ladder"   yaml:"ladder"`
+	Capacity int                `json:"capacity" yaml:"capacity"`
+	Enabled  bool               `json:"enabled"  yaml:"enabled"`
+}
+
+// Rungs returns the configured ladder, falling back to the defaults.
+func (d Degradation) Rungs() []degradation.Rung {
+	if len(d.Ladder) > 0 {
+		return d.Ladder
+	}
+
+	return degradation.DefaultRungs()
 }
 
 func GetConfigFromFile(path string) (*Config, error) {
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// reloadDepth is the depth of the settings Reloaded reports, e.g. 2 for "server.port".
+const reloadDepth = 2
+
+// copyReloadable copies the settings applied while the service runs from src to dst: the log level, the
+// request limits, the route timeouts, the load shedding cap and maintenance mode.
+func copyReloadable(dst, src *Config) {
+	dst.Log.Level = src.Log.Level
+	dst.Limits = src.Limits
+	dst.Server.Timeouts = src.Server.Timeouts
+	dst.Shedding.MaxConcurrent = src.Shedding.MaxConcurrent
+	dst.Maintenance = src.Maintenance
+}
+
+// Reloaded returns the configuration the service runs with once the reloadable settings of next are applied to
+// current, and the other settings next changes by their YAML path, e.g. "server.port", which only take effect
+// after a restart and are not applied.
+func Reloaded(current, next *Config) (*Config, []string) {
+	reloaded := *current
+	copyReloadable(&reloaded, next)
+
+	restart := *next
+	copyReloadable(&restart, current)
+
+	return &reloaded, changedSettings("", reflect.ValueOf(*current), reflect.ValueOf(restart), reloadDepth)
+}
+
+// changedSettings returns the paths of the fields of the structs a and b that differ, descending into nested
+// structs up to depth levels.
+func changedSettings(prefix string, a, b reflect.Value, depth int) []string {
+	var changed []string
+	for i := range a.NumField() {
+		field := a.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, inline := settingName(field)
+		path := name
+		if prefix != "" {
+			path = prefix + "." + name
+		}
+
+		fa, fb := a.Field(i), b.Field(i)
+		switch {
+		case inline && fa.Kind() == reflect.Struct:
+			changed = append(changed, changedSettings(prefix, fa, fb, depth)...)
+		case depth > 1 && fa.Kind() == reflect.Struct && fa.Type() != reflect.TypeFor[time.Time]():
+			changed = append(changed, changedSettings(path, fa, fb, depth-1)...)
+		case !reflect.DeepEqual(fa.Interface(), fb.Interface()):
+			changed = append(changed, path)
+		}
+	}
+
+	return changed
+}
+
+// settingName returns the YAML name of the field, and whether it is inlined in its parent.
+func settingName(field reflect.StructField) (string, bool) {
+	name, options, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+	if options == "inline" {
+		return "", true
+	}
+	if name == "" {
+		name = strings.ToLower(field.Name)
+	}
+
+	return name, false
+}
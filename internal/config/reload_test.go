@@ -0,0 +1,68 @@
+package config_test
+
+import (
+	"log/slog"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/config"
+	"github.com/dsha256/dispatcher/internal/limits"
+)
+
+func TestReloaded(t *testing.T) {
+	t.Parallel()
+
+	current := &config.Config{Server: config.Server{Port: 3000}}
+	current.Log.Format = "json"
+
+	tests := []struct {
+		edit        func(*config.Config)
+		check       func(*config.Config) bool
+		name        string
+		wantRestart []string
+	}{
+		{
+			name: "Reloadable settings",
+			edit: func(cfg *config.Config) {
+				cfg.Log.Level = slog.LevelDebug
+				cfg.Limits = limits.Limits{MaxTickets: 100}
+				cfg.Server.Timeouts.Default = 5 * time.Second
+				cfg.Maintenance = true
+			},
+			check: func(cfg *config.Config) bool {
+				return cfg.Log.Level == slog.LevelDebug && cfg.Limits.MaxTickets == 100 &&
+					cfg.Server.Timeouts.Default == 5*time.Second && cfg.Maintenance
+			},
+		},
+		{
+			name: "Settings needing a restart",
+			edit: func(cfg *config.Config) {
+				cfg.Server.Port = 8080
+				cfg.Log.Format = "text"
+				cfg.Cache.Enabled = true
+				cfg.NATS.Addr = "nats:4222"
+			},
+			check: func(cfg *config.Config) bool {
+				return cfg.Server.Port == 3000 && cfg.Log.Format == "json" && !cfg.Cache.Enabled
+			},
+			wantRestart: []string{"server.port", "cache.enabled", "nats.addr", "log.format"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			next := *current
+			tt.edit(&next)
+			reloaded, restart := config.Reloaded(current, &next)
+			if !tt.check(reloaded) {
+				t.Errorf("Reloaded() = %+v; want only the reloadable settings applied", reloaded)
+			}
+			if !reflect.DeepEqual(restart, tt.wantRestart) {
+				t.Errorf("Reloaded() restart = %v; want %v", restart, tt.wantRestart)
+			}
+		})
+	}
+}
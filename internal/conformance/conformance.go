@@ -0,0 +1,127 @@
+// Package conformance holds the language-agnostic input/output vectors of the reconstruction algorithm.
+//
+// The vectors are the golden tests of the dispatcher: their expected outputs are generated from the Go
+// implementation, the reference, and published as-is so reimplementations in other languages can verify parity.
+// To add a vector, add its name and tickets to vectors.json and run go generate: the expected linear path or
+// error is filled in. The package's tests fail when the file is not current.
+package conformance
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/dsha256/dispatcher/internal/dispatcher"
+)
+
+//go:generate go run ./gen
+
+var ErrInvalidCorpus = errors.New("invalid conformance corpus")
+
+//go:embed vectors.json
+var vectors []byte
+
+// Corpus is the set of conformance vectors for an algorithm version.
+type Corpus struct {
+	AlgorithmVersion string   `json:"algorithm_version"`
+	Description      string   `json:"description"`
+	Vectors          []Vector `json:"vectors"`
+}
+
+// Vector is a single input with either the expected linear path or the expected error message.
+type Vector struct {
+	Name       string     `json:"name"`
+	Error      string     `json:"error,omitempty"`
+	Tickets    [][]string `json:"tickets"`
+	LinearPath []string   `json:"linear_path,omitempty"`
+}
+
+// Raw returns the embedded corpus exactly as published.
+func Raw() []byte {
+	return vectors
+}
+
+// Load parses the embedded corpus.
+func Load() (*Corpus, error) {
+	var corpus Corpus
+	if err := json.Unmarshal(vectors, &corpus); err != nil {
+		return nil, err
+	}
+
+	return &corpus, nil
+}
+
+// Generate fills in the expected output of every vector, the linear path or the error message, with that of the
+// reference implementation: the dispatcher's, pinned to the corpus's algorithm version.
+func (c *Corpus) Generate(ctx context.Context) error {
+	d := dispatcher.New()
+	for i := range c.Vectors {
+		vector := &c.Vectors[i]
+		tickets := make([]dispatcher.Ticket, 0, len(vector.Tickets))
+		for _, pair := range vector.Tickets {
+			tickets = append(tickets, dispatcher.TicketFromPair(pair))
+		}
+
+		result, err := d.Reconstruct(ctx, &dispatcher.Request{AlgorithmVersion: c.AlgorithmVersion, Tickets: tickets})
+		switch {
+		case errors.Is(err, dispatcher.ErrUnsupportedAlgorithmVersion):
+			return err
+		case err != nil:
+			vector.LinearPath, vector.Error = nil, err.Error()
+		default:
+			vector.LinearPath, vector.Error = result.Path, ""
+		}
+	}
+
+	return nil
+}
+
+// Format encodes the corpus as it is published: indented, with the arrays of a vector on a single line.
+func (c *Corpus) Format() ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "{\n  \"algorithm_version\": %s,\n  \"description\": %s,\n  \"vectors\": [",
+		quote(c.AlgorithmVersion), quote(c.Description))
+	for i, vector := range c.Vectors {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		tickets := make([]string, 0, len(vector.Tickets))
+		for _, ticket := range vector.Tickets {
+			tickets = append(tickets, list(ticket))
+		}
+		fmt.Fprintf(&buf, "\n    {\n      \"name\": %s,\n      \"tickets\": [%s],\n", quote(vector.Name), strings.Join(tickets, ", "))
+		if vector.Error != "" {
+			fmt.Fprintf(&buf, "      \"error\": %s\n    }", quote(vector.Error))
+		} else {
+			fmt.Fprintf(&buf, "      \"linear_path\": %s\n    }", list(vector.LinearPath))
+		}
+	}
+	buf.WriteString("\n  ]\n}\n")
+
+	if !json.Valid(buf.Bytes()) {
+		return nil, ErrInvalidCorpus
+	}
+
+	return buf.Bytes(), nil
+}
+
+// quote returns s as a JSON string.
+func quote(s string) string {
+	quoted, _ := json.Marshal(s) //nolint:errchkjson // Strings always encode.
+
+	return string(quoted)
+}
+
+// list returns the codes as a JSON array on a single line.
+func list(codes []string) string {
+	quoted := make([]string, 0, len(codes))
+	for _, code := range codes {
+		quoted = append(quoted, quote(code))
+	}
+
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
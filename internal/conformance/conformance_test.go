@@ -0,0 +1,76 @@
+package conformance_test
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/dsha256/dispatcher/internal/conformance"
+	"github.com/dsha256/dispatcher/internal/dispatcher"
+)
+
+func TestVectors(t *testing.T) {
+	t.Parallel()
+
+	corpus, err := conformance.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if !slices.Contains(dispatcher.SupportedAlgorithmVersions(), corpus.AlgorithmVersion) {
+		t.Fatalf("corpus is for algorithm version %q, which is no longer supported", corpus.AlgorithmVersion)
+	}
+	// Requests pinned to the corpus version get its paths whatever the server defaults.
+	d := dispatcher.New(dispatcher.WithDefaultTieBreak(dispatcher.TieBreakLargestFirst))
+
+	for _, vector := range corpus.Vectors {
+		t.Run(vector.Name, func(t *testing.T) {
+			t.Parallel()
+
+			tickets := make([]dispatcher.Ticket, len(vector.Tickets))
+			for i, pair := range vector.Tickets {
+				tickets[i] = dispatcher.TicketFromPair(pair)
+			}
+			result, err := d.Reconstruct(context.Background(), &dispatcher.Request{
+				AlgorithmVersion: corpus.AlgorithmVersion,
+				Tickets:          tickets,
+			})
+			if vector.Error != "" {
+				if err == nil || err.Error() != vector.Error {
+					t.Errorf("Reconstruct(%v) error = %v; want %q", vector.Tickets, err, vector.Error)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Reconstruct(%v) error = %v", vector.Tickets, err)
+			}
+			if !reflect.DeepEqual(result.Path, vector.LinearPath) {
+				t.Errorf("Reconstruct(%v) = %v; want %v", vector.Tickets, result.Path, vector.LinearPath)
+			}
+		})
+	}
+}
+
+func TestVectorsCurrent(t *testing.T) {
+	t.Parallel()
+
+	corpus, err := conformance.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if err = corpus.Generate(context.Background()); err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	generated, err := corpus.Format()
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+
+	if !bytes.Equal(generated, conformance.Raw()) {
+		t.Errorf("vectors.json is not current, run go generate ./internal/conformance; want:\n%s", generated)
+	}
+}
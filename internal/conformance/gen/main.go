@@ -0,0 +1,36 @@
+// Command gen regenerates vectors.json, run by go generate in the conformance package: it keeps the name and
+// tickets of every vector and fills in their expected outputs with those of the reference implementation.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/dsha256/dispatcher/internal/conformance"
+)
+
+const vectorsFile = "vectors.json"
+
+func main() {
+	if err := generate(); err != nil {
+		fmt.Fprintln(os.Stderr, "conformance:", err)
+		os.Exit(1)
+	}
+}
+
+func generate() error {
+	corpus, err := conformance.Load()
+	if err != nil {
+		return err
+	}
+	if err = corpus.Generate(context.Background()); err != nil {
+		return err
+	}
+	data, err := corpus.Format()
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(vectorsFile, data, 0o644) //nolint:gosec // The corpus is published.
+}
@@ -0,0 +1,211 @@
+// Package degradation implements an ordered degradation ladder: as load grows, optional work is
+// switched off step by step so overload behavior is predictable rather than ad hoc.
+package degradation
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/dsha256/dispatcher/internal/responder"
+	"github.com/dsha256/dispatcher/pkg/apierror"
+)
+
+var (
+	ErrInvalidLadder = errors.New("invalid degradation ladder")
+	ErrShed          = errors.New("service degraded, low priority traffic is shed")
+	ErrBatchRejected = errors.New("service degraded, batch requests are rejected")
+)
+
+// Step is a single degradation measure.
+type Step string
+
+const (
+	// StepDisableEnrichment skips airport enrichment, distances and emissions.
+	StepDisableEnrichment Step = "disable_enrichment"
+	// StepRejectBatch rejects the batch endpoints, reconstructing many itineraries per request, see
+	// BatchMiddleware.
+	StepRejectBatch Step = "reject_batch"
+	// StepShedLowPriority rejects requests sent with PriorityHeader set to "low".
+	StepShedLowPriority Step = "shed_low_priority"
+)
+
+// PriorityHeader marks the priority of a request; "low" priority traffic is shed first.
+const PriorityHeader = "X-Priority"
+
+// Rung activates its step once the load reaches AtLoad.
+type Rung struct {
+	Step   Step    `json:"step"    yaml:"step"`
+	AtLoad float64 `json:"at_load" yaml:"at_load"`
+}
+
+// DefaultRungs is the recommended ladder.
+func DefaultRungs() []Rung {
+	return []Rung{
+		{Step: StepDisableEnrichment, AtLoad: 0.6},
+		{Step: StepRejectBatch, AtLoad: 0.8},
+		{Step: StepShedLowPriority, AtLoad: 0.9},
+	}
+}
+
+// Status is the current state of the ladder, reported by the readiness endpoint.
+type Status struct {
+	Active   []Step  `json:"active_steps"`
+	Load     float64 `json:"load"`
+	InFlight int64   `json:"in_flight"`
+	Capacity int64   `json:"capacity"`
+	Level    int     `json:"level"`
+}
+
+// Ladder tracks the load as the ratio of in-flight requests to capacity and
+// reports which steps are active. It is safe for concurrent use.
+type Ladder struct {
+	logger    *slog.Logger
+	rungs     []Rung
+	capacity  int64
+	inFlight  atomic.Int64
+	lastLevel atomic.Int64
+}
+
+// NewLadder validates the rungs: steps must be known and unique, and thresholds non-decreasing,
+// so that the ladder is always climbed in the configured order.
+func NewLadder(logger *slog.Logger, capacity int, rungs []Rung) (*Ladder, error) {
+	if capacity <= 0 {
+		return nil, fmt.Errorf("%w: capacity must be positive", ErrInvalidLadder)
+	}
+
+	seen := make(map[Step]struct{}, len(rungs))
+	for i, rung := range rungs {
+		switch rung.Step {
+		case StepDisableEnrichment, StepRejectBatch, StepShedLowPriority:
+		default:
+			return nil, fmt.Errorf("%w: unknown step %q", ErrInvalidLadder, rung.Step)
+		}
+		if _, ok := seen[rung.Step]; ok {
+			return nil, fmt.Errorf("%w: duplicate step %q", ErrInvalidLadder, rung.Step)
+		}
+		seen[rung.Step] = struct{}{}
+		if i > 0 && rung.AtLoad < rungs[i-1].AtLoad {
+			return nil, fmt.Errorf("%w: step %q triggers before %q", ErrInvalidLadder, rung.Step, rungs[i-1].Step)
+		}
+	}
+
+	return &Ladder{
+		logger:   logger,
+		rungs:    append([]Rung(nil), rungs...),
+		capacity: int64(capacity),
+	}, nil
+}
+
+// Load returns the current load, 1 meaning the capacity is reached.
+func (l *Ladder) Load() float64 {
+	if l == nil {
+		return 0
+	}
+
+	return float64(l.inFlight.Load()) / float64(l.capacity)
+}
+
+// Level returns the number of active rungs.
+func (l *Ladder) Level() int {
+	if l == nil {
+		return 0
+	}
+
+	load := l.Load()
+	level := 0
+	for _, rung := range l.rungs {
+		if load < rung.AtLoad {
+			break
+		}
+		level++
+	}
+
+	return level
+}
+
+// Active reports whether a step is currently active. A nil ladder never degrades.
+func (l *Ladder) Active(step Step) bool {
+	if l == nil {
+		return false
+	}
+
+	level := l.Level()
+	for _, rung := range l.rungs[:level] {
+		if rung.Step == step {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Status returns a snapshot of the ladder.
+func (l *Ladder) Status() Status {
+	if l == nil {
+		return Status{Active: []Step{}}
+	}
+
+	level := l.Level()
+	active := make([]Step, 0, level)
+	for _, rung := range l.rungs[:level] {
+		active = append(active, rung.Step)
+	}
+
+	return Status{
+		Active:   active,
+		Load:     l.Load(),
+		InFlight: l.inFlight.Load(),
+		Capacity: l.capacity,
+		Level:    level,
+	}
+}
+
+// Middleware counts in-flight requests to derive the load and sheds low-priority traffic when that step is active,
+// with 503 Service Unavailable, the UNAVAILABLE code and Retry-After.
+func (l *Ladder) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		l.inFlight.Add(1)
+		defer l.inFlight.Add(-1)
+		l.logLevelChange()
+
+		if r.Header.Get(PriorityHeader) == "low" && l.Active(StepShedLowPriority) {
+			w.Header().Set("Retry-After", "1")
+			w.Header().Set(responder.ErrorCodeHeader, string(apierror.CodeUnavailable))
+			responder.WriteError(w, http.StatusServiceUnavailable, ErrShed)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// BatchMiddleware rejects the requests of a batch endpoint while the reject_batch step is active, with 503 Service
+// Unavailable, the UNAVAILABLE code and Retry-After. It lets every request through on a nil ladder.
+func (l *Ladder) BatchMiddleware(next http.Handler) http.Handler {
+	if l == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if l.Active(StepRejectBatch) {
+			w.Header().Set("Retry-After", "1")
+			w.Header().Set(responder.ErrorCodeHeader, string(apierror.CodeUnavailable))
+			responder.WriteError(w, http.StatusServiceUnavailable, ErrBatchRejected)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (l *Ladder) logLevelChange() {
+	level := int64(l.Level())
+	if previous := l.lastLevel.Swap(level); previous != level {
+		l.logger.Warn("Degradation level changed", "from", previous, "to", level, "status", l.Status())
+	}
+}
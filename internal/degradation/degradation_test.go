@@ -0,0 +1,189 @@
+package degradation_test
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/dsha256/dispatcher/internal/degradation"
+	"github.com/dsha256/dispatcher/internal/responder"
+)
+
+func TestNewLadderValidation(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		capacity int
+		rungs    []degradation.Rung
+		wantErr  bool
+	}{
+		{name: "defaults", capacity: 10, rungs: degradation.DefaultRungs()},
+		{name: "empty ladder", capacity: 10},
+		{name: "zero capacity", capacity: 0, rungs: degradation.DefaultRungs(), wantErr: true},
+		{name: "unknown step", capacity: 10, rungs: []degradation.Rung{{Step: "panic", AtLoad: 0.5}}, wantErr: true},
+		{
+			name:     "duplicate step",
+			capacity: 10,
+			rungs: []degradation.Rung{
+				{Step: degradation.StepDisableEnrichment, AtLoad: 0.5},
+				{Step: degradation.StepDisableEnrichment, AtLoad: 0.6},
+			},
+			wantErr: true,
+		},
+		{
+			name:     "out of order",
+			capacity: 10,
+			rungs: []degradation.Rung{
+				{Step: degradation.StepShedLowPriority, AtLoad: 0.9},
+				{Step: degradation.StepDisableEnrichment, AtLoad: 0.5},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := degradation.NewLadder(slog.New(slog.NewTextHandler(io.Discard, nil)), tt.capacity, tt.rungs)
+			if got := errors.Is(err, degradation.ErrInvalidLadder); got != tt.wantErr {
+				t.Errorf("NewLadder() error = %v; want invalid ladder: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLadderClimbsWithLoad(t *testing.T) {
+	t.Parallel()
+
+	ladder, err := degradation.NewLadder(slog.New(slog.NewTextHandler(io.Discard, nil)), 2, []degradation.Rung{
+		{Step: degradation.StepDisableEnrichment, AtLoad: 0.5},
+		{Step: degradation.StepShedLowPriority, AtLoad: 1},
+	})
+	if err != nil {
+		t.Fatalf("NewLadder() error = %v", err)
+	}
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	routes := ladder.Middleware(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			entered <- struct{}{}
+			<-release
+		}
+	}))
+
+	if status := ladder.Status(); status.Level != 0 || len(status.Active) != 0 {
+		t.Fatalf("idle Status() = %+v; want level 0", status)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		routes.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/slow", nil))
+	}()
+	<-entered
+
+	if !ladder.Active(degradation.StepDisableEnrichment) {
+		t.Error("enrichment should be disabled at load 0.5")
+	}
+	if ladder.Active(degradation.StepShedLowPriority) {
+		t.Error("low priority traffic should not be shed at load 0.5")
+	}
+
+	low := httptest.NewRequest(http.MethodGet, "/fast", nil)
+	low.Header.Set(degradation.PriorityHeader, "low")
+	rec := httptest.NewRecorder()
+	routes.ServeHTTP(rec, low)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("low priority request at full load got %d; want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+	if got := rec.Header().Get(responder.ErrorCodeHeader); got != "UNAVAILABLE" {
+		t.Errorf("low priority request at full load got error code %q; want UNAVAILABLE", got)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("low priority request at full load got Content-Type %q; want application/json", got)
+	}
+
+	rec = httptest.NewRecorder()
+	routes.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/fast", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("normal priority request at full load got %d; want %d", rec.Code, http.StatusOK)
+	}
+
+	close(release)
+	<-done
+
+	status := ladder.Status()
+	if status.Level != 0 || status.InFlight != 0 || !slices.Equal(status.Active, []degradation.Step{}) {
+		t.Errorf("Status() after load drops = %+v; want level 0", status)
+	}
+}
+
+func TestLadderRejectsBatches(t *testing.T) {
+	t.Parallel()
+
+	ladder, err := degradation.NewLadder(slog.New(slog.NewTextHandler(io.Discard, nil)), 2, []degradation.Rung{
+		{Step: degradation.StepRejectBatch, AtLoad: 0.5},
+	})
+	if err != nil {
+		t.Fatalf("NewLadder() error = %v", err)
+	}
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	routes := ladder.Middleware(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			entered <- struct{}{}
+			<-release
+		}
+	}))
+	batch := ladder.BatchMiddleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	rec := httptest.NewRecorder()
+	batch.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/batch", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("batch request when idle got %d; want %d", rec.Code, http.StatusOK)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		routes.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/slow", nil))
+	}()
+	<-entered
+
+	rec = httptest.NewRecorder()
+	batch.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/batch", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("batch request at load 0.5 got %d; want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+	if got := rec.Header().Get(responder.ErrorCodeHeader); got != "UNAVAILABLE" {
+		t.Errorf("batch request at load 0.5 got error code %q; want UNAVAILABLE", got)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("batch request at load 0.5 got no Retry-After")
+	}
+
+	close(release)
+	<-done
+}
+
+func TestNilLadderNeverDegrades(t *testing.T) {
+	t.Parallel()
+
+	var ladder *degradation.Ladder
+	if ladder.Active(degradation.StepDisableEnrichment) || ladder.Status().Level != 0 {
+		t.Error("nil ladder should never degrade")
+	}
+	rec := httptest.NewRecorder()
+	ladder.BatchMiddleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/batch", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("nil ladder rejected a batch request with %d", rec.Code)
+	}
+}
@@ -0,0 +1,194 @@
+package dispatcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInfeasibleConnection = errors.New("infeasible connection")
+	ErrUntimedTickets       = errors.New("every ticket needs a departure time")
+)
+
+// InfeasibleConnection describes two consecutive legs that cannot be flown one after another.
+type InfeasibleConnection struct {
+	ReadyAt         time.Time `json:"ready_at"`
+	DepartsAt       time.Time `json:"departs_at"`
+	Reason          string    `json:"reason"`
+	Airport         string    `json:"airport"`
+	Layover         string    `json:"layover"`
+	RequiredLayover string    `json:"required_layover"`
+	FromTicketIndex int       `json:"from_ticket_index"`
+	ToTicketIndex   int       `json:"to_ticket_index"`
+}
+
+// ConnectionError lists every infeasible connection of a time-aware reconstruction.
+// It unwraps to ErrInfeasibleConnection.
+type ConnectionError struct {
+	Connections []InfeasibleConnection `json:"connections"`
+}
+
+func (e *ConnectionError) Error() string {
+	reasons := make([]string, 0, len(e.Connections))
+	for _, c := range e.Connections {
+		reasons = append(reasons, c.Reason)
+	}
+
+	return fmt.Sprintf("%s: %s", ErrInfeasibleConnection, strings.Join(reasons, "; "))
+}
+
+func (e *ConnectionError) Unwrap() error {
+	return ErrInfeasibleConnection
+}
+
+func (e *ConnectionError) Details() any {
+	return e
+}
+
+// Timed reports whether every ticket carries a departure time,
+// which is what enables time-aware reconstruction.
+func Timed(tickets []Ticket) bool {
+	if len(tickets) == 0 {
+		return false
+	}
+	for i := range tickets {
+		if tickets[i].DepartsAt == nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ReconstructChronological reconstructs the itinerary of timed tickets so that every connection
+// respects chronology and leaves at least minLayover between arrival and the next departure.
+//
+// A chronologically valid itinerary is necessarily ordered by departure time, so the tickets are
+// sorted by departure and the resulting chain is checked connection by connection. When a ticket has
+// no arrival time, its departure time is used as the earliest moment the traveller is ready.
+//
+// Possible errors are the ones of ReconstructItinerary and a *ConnectionError listing every
+// connection that breaks the airport chain, chronology or the minimum layover.
+func ReconstructChronological(tickets []Ticket, minLayover time.Duration) ([]string, []Leg, error) {
+	return reconstructChronological(context.Background(), tickets, minLayover, false, nil)
+}
+
+// Chronological returns the Reconstructor of StrategyChronological, which fails with ErrUntimedTickets unless
+// every ticket has a departure time. The dispatcher running it supplies its minimum layover and the
+// request's surface transfers, which it stitches between consecutive tickets.
+func Chronological() Reconstructor {
+	return chronological{}
+}
+
+type chronological struct {
+	transfers  []SurfaceTransfer
+	minLayover time.Duration
+}
+
+func (c chronological) Reconstruct(ctx context.Context, tickets []Ticket, allowDuplicates bool) ([]string, []Leg, error) {
+	if !Timed(tickets) {
+		return nil, nil, ErrUntimedTickets
+	}
+
+	return reconstructChronological(ctx, tickets, c.minLayover, allowDuplicates, c.transfers)
+}
+
+func reconstructChronological(
+	ctx context.Context, tickets []Ticket, minLayover time.Duration, allowDuplicates bool, transfers []SurfaceTransfer,
+) ([]string, []Leg, error) {
+	order := make([]int, len(tickets))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return tickets[order[i]].DepartsAt.Before(*tickets[order[j]].DepartsAt)
+	})
+
+	// A permitted gap between consecutive tickets is stitched with a surface transfer, validated as a ticket.
+	permitted := make(map[SurfaceTransfer]bool, len(transfers))
+	for _, transfer := range transfers {
+		permitted[transfer] = true
+	}
+	surface := make([]bool, len(order))
+	pairs := Pairs(tickets)
+	for i := 1; i < len(order); i++ {
+		prev, next := &tickets[order[i-1]], &tickets[order[i]]
+		if prev.To != next.From && permitted[SurfaceTransfer{From: prev.To, To: next.From}] {
+			surface[i] = true
+			pairs = append(pairs, []string{prev.To, next.From})
+		}
+	}
+
+	ws := newWorkspace(len(pairs))
+	err := validateTickets(ws, pairs, allowDuplicates)
+	ws.release()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	connErr := &ConnectionError{Connections: []InfeasibleConnection{}}
+	for i := 1; i < len(order); i++ {
+		prev, next := &tickets[order[i-1]], &tickets[order[i]]
+		if c, ok := checkConnection(prev, next, minLayover, surface[i]); !ok {
+			c.FromTicketIndex, c.ToTicketIndex = order[i-1], order[i]
+			connErr.Connections = append(connErr.Connections, c)
+		}
+	}
+	if len(connErr.Connections) > 0 {
+		return nil, nil, connErr
+	}
+
+	path := make([]string, 0, len(pairs)+1)
+	legs := make([]Leg, 0, len(pairs))
+	for i, idx := range order {
+		if i == 0 {
+			path = append(path, tickets[idx].From)
+		} else if surface[i] {
+			path = append(path, tickets[idx].From)
+			legs = append(legs, surfaceTransferLeg(&tickets[order[i-1]], &tickets[idx]))
+		}
+		path = append(path, tickets[idx].To)
+		legs = append(legs, Leg{From: tickets[idx].From, To: tickets[idx].To, TicketIndex: idx})
+	}
+
+	return path, legs, nil
+}
+
+// checkConnection checks that next can be flown after prev. A surface transfer connects different airports,
+// but still needs the layover.
+func checkConnection(prev, next *Ticket, minLayover time.Duration, surface bool) (InfeasibleConnection, bool) {
+	readyAt := *prev.DepartsAt
+	if prev.ArrivesAt != nil {
+		readyAt = *prev.ArrivesAt
+	}
+	layover := next.DepartsAt.Sub(readyAt)
+
+	c := InfeasibleConnection{
+		ReadyAt:         readyAt,
+		DepartsAt:       *next.DepartsAt,
+		Airport:         prev.To,
+		Layover:         layover.String(),
+		RequiredLayover: minLayover.String(),
+	}
+
+	switch {
+	case prev.To != next.From && !surface:
+		c.Reason = fmt.Sprintf("%s -> %s arrives at %s but the next departure in time is %s -> %s", prev.From, prev.To, prev.To, next.From, next.To)
+	case layover < 0:
+		c.Reason = fmt.Sprintf("%s -> %s departs at %s before %s -> %s arrives at %s",
+			next.From, next.To, next.DepartsAt.Format(time.RFC3339), prev.From, prev.To, readyAt.Format(time.RFC3339))
+	case layover < minLayover:
+		c.Reason = fmt.Sprintf("layover at %s is %s, shorter than the required %s", prev.To, layover, minLayover)
+	default:
+		return c, true
+	}
+
+	return c, false
+}
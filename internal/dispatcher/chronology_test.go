@@ -0,0 +1,101 @@
+package dispatcher_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/dispatcher"
+)
+
+func TestReconstructChronological(t *testing.T) {
+	t.Parallel()
+
+	at := func(hour int) *time.Time {
+		ts := time.Date(2025, time.May, 1, hour, 0, 0, 0, time.UTC)
+
+		return &ts
+	}
+
+	tests := []struct {
+		err        error
+		name       string
+		tickets    []dispatcher.Ticket
+		expected   []string
+		infeasible [][2]int
+		minLayover time.Duration
+	}{
+		{
+			name: "Chronology picks the loop first",
+			tickets: []dispatcher.Ticket{
+				{From: "JFK", To: "SFO", DepartsAt: at(10), ArrivesAt: at(13)},
+				{From: "JFK", To: "ATL", DepartsAt: at(1), ArrivesAt: at(3)},
+				{From: "SFO", To: "ATL", DepartsAt: at(15), ArrivesAt: at(20)},
+				{From: "ATL", To: "JFK", DepartsAt: at(5), ArrivesAt: at(7)},
+			},
+			minLayover: time.Hour,
+			expected:   []string{"JFK", "ATL", "JFK", "SFO", "ATL"},
+		},
+		{
+			name: "Chronology picks the direct leg first",
+			tickets: []dispatcher.Ticket{
+				{From: "JFK", To: "SFO", DepartsAt: at(1), ArrivesAt: at(3)},
+				{From: "JFK", To: "ATL", DepartsAt: at(12), ArrivesAt: at(14)},
+				{From: "SFO", To: "ATL", DepartsAt: at(5), ArrivesAt: at(8)},
+				{From: "ATL", To: "JFK", DepartsAt: at(9), ArrivesAt: at(11)},
+			},
+			expected: []string{"JFK", "SFO", "ATL", "JFK", "ATL"},
+		},
+		{
+			name: "Layover too short",
+			tickets: []dispatcher.Ticket{
+				{From: "JFK", To: "LAX", DepartsAt: at(1), ArrivesAt: at(6)},
+				{From: "LAX", To: "DXB", DepartsAt: at(6)},
+			},
+			minLayover: 30 * time.Minute,
+			err:        dispatcher.ErrInfeasibleConnection,
+			infeasible: [][2]int{{0, 1}},
+		},
+		{
+			name: "Departure order breaks the chain",
+			tickets: []dispatcher.Ticket{
+				{From: "JFK", To: "LAX", DepartsAt: at(10)},
+				{From: "LAX", To: "DXB", DepartsAt: at(2)},
+			},
+			err:        dispatcher.ErrInfeasibleConnection,
+			infeasible: [][2]int{{1, 0}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			path, _, err := dispatcher.ReconstructChronological(tt.tickets, tt.minLayover)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("ReconstructChronological() error = %v; want %v", err, tt.err)
+			}
+
+			if tt.err == nil {
+				if !reflect.DeepEqual(path, tt.expected) {
+					t.Errorf("ReconstructChronological() = %v; want %v", path, tt.expected)
+				}
+
+				return
+			}
+
+			var connErr *dispatcher.ConnectionError
+			if !errors.As(err, &connErr) {
+				t.Fatalf("ReconstructChronological() error = %T; want *ConnectionError", err)
+			}
+			infeasible := [][2]int{}
+			for _, c := range connErr.Connections {
+				infeasible = append(infeasible, [2]int{c.FromTicketIndex, c.ToTicketIndex})
+			}
+			if !reflect.DeepEqual(infeasible, tt.infeasible) {
+				t.Errorf("infeasible connections = %v; want %v", infeasible, tt.infeasible)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package dispatcher
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrConstraintViolated = errors.New("constraint violated")
+
+// Constraint names used in ConstraintError.
+const (
+	ConstraintAvoidAirports     = "avoid_airports"
+	ConstraintRequiredWaypoints = "required_waypoints"
+	ConstraintMaxStops          = "max_stops"
+)
+
+// Constraints are optional rules a reconstructed itinerary must satisfy.
+//
+// Every valid itinerary uses all tickets, so the set of visited airports and the number of stops are
+// the same for all orderings: when a constraint cannot be met by one ordering it cannot be met by any.
+type Constraints struct {
+	// MaxStops is the maximum number of intermediate airports, i.e. excluding origin and final destination.
+	MaxStops *int `json:"max_stops,omitempty" xml:"max_stops,omitempty"`
+	// AvoidAirports must not appear anywhere in the itinerary.
+	AvoidAirports []string `json:"avoid_airports,omitempty" xml:"avoid_airports>airport,omitempty"`
+	// RequiredWaypoints must appear somewhere in the itinerary.
+	RequiredWaypoints []string `json:"required_waypoints,omitempty" xml:"required_waypoints>airport,omitempty"`
+}
+
+// ConstraintError names the violated constraint and the airports involved.
+// It unwraps to ErrConstraintViolated.
+type ConstraintError struct {
+	Constraint string   `json:"constraint"`
+	Detail     string   `json:"detail"`
+	Airports   []string `json:"airports,omitempty"`
+	Indexes    []int    `json:"indexes,omitempty"`
+}
+
+func (e *ConstraintError) Error() string {
+	return fmt.Sprintf("%s: %s: %s", ErrConstraintViolated, e.Constraint, e.Detail)
+}
+
+func (e *ConstraintError) Unwrap() error {
+	return ErrConstraintViolated
+}
+
+func (e *ConstraintError) Details() any {
+	return e
+}
+
+// CheckTickets rejects tickets touching avoided airports before any path is computed.
+// A nil receiver accepts everything.
+func (c *Constraints) CheckTickets(tickets []Ticket) error {
+	if c == nil || len(c.AvoidAirports) == 0 {
+		return nil
+	}
+
+	avoid := toSet(c.AvoidAirports)
+	hit := make(map[string]struct{})
+	var airports []string
+	var indexes []int
+	for i := range tickets {
+		touched := false
+		for _, airport := range []string{tickets[i].From, tickets[i].To} {
+			if _, ok := avoid[airport]; !ok {
+				continue
+			}
+			touched = true
+			if _, ok := hit[airport]; !ok {
+				hit[airport] = struct{}{}
+				airports = append(airports, airport)
+			}
+		}
+		if touched {
+			indexes = append(indexes, i)
+		}
+	}
+
+	if len(indexes) == 0 {
+		return nil
+	}
+
+	return &ConstraintError{
+		Constraint: ConstraintAvoidAirports,
+		Detail:     fmt.Sprintf("the itinerary cannot avoid %s", strings.Join(airports, ", ")),
+		Airports:   airports,
+		Indexes:    indexes,
+	}
+}
+
+// CheckPath verifies the required waypoints and the maximum number of stops of a reconstructed path.
+// A nil receiver accepts everything.
+func (c *Constraints) CheckPath(path []string) error {
+	if c == nil || len(path) == 0 {
+		return nil
+	}
+
+	visited := toSet(path)
+	var missing []string
+	for _, airport := range c.RequiredWaypoints {
+		if _, ok := visited[airport]; !ok {
+			missing = append(missing, airport)
+		}
+	}
+	if len(missing) > 0 {
+		return &ConstraintError{
+			Constraint: ConstraintRequiredWaypoints,
+			Detail:     fmt.Sprintf("the itinerary does not visit %s", strings.Join(missing, ", ")),
+			Airports:   missing,
+		}
+	}
+
+	if stops := len(path) - 2; c.MaxStops != nil && stops > *c.MaxStops {
+		return &ConstraintError{
+			Constraint: ConstraintMaxStops,
+			Detail:     fmt.Sprintf("the itinerary has %d stops, more than the allowed %d", stops, *c.MaxStops),
+			Airports:   path[1 : len(path)-1],
+		}
+	}
+
+	return nil
+}
+
+func toSet(values []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(values))
+	for _, v := range values {
+		set[v] = struct{}{}
+	}
+
+	return set
+}
@@ -3,23 +3,196 @@ package dispatcher
 import (
 	"context"
 	"errors"
-	"sort"
+	"fmt"
+	"slices"
+	"time"
 )
 
 var (
 	ErrMultipleSameDestination = errors.New("multiple same destination")
 	ErrCycleInItinerary        = errors.New("cycle in itinerary")
 	ErrDifferentStartingPoints = errors.New("different starting points")
+	ErrTooManyTickets          = errors.New("too many tickets")
 )
 
-type Dispatcher struct{}
+// LimitError is returned for requests with more tickets than WithMaxTickets allows. It unwraps to
+// ErrTooManyTickets.
+type LimitError struct {
+	Tickets    int `json:"tickets"`
+	MaxTickets int `json:"max_tickets"`
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s: %d tickets, the limit is %d", ErrTooManyTickets, e.Tickets, e.MaxTickets)
+}
+
+func (e *LimitError) Unwrap() error {
+	return ErrTooManyTickets
+}
+
+func (e *LimitError) Details() any {
+	return e
+}
+
+type Dispatcher struct {
+	knownAirport func(code string) bool
+	// strategies are the registered reconstructors by name, defaultStrategy the one of requests not naming one.
+	strategies map[Strategy]Reconstructor
+	// aliases are applied to the airport codes of every request.
+	aliases         Aliases
+	defaultStrategy Strategy
+	// defaultTieBreak is the tie-break policy of requests not naming one.
+	defaultTieBreak TieBreak
+	minLayover      time.Duration
+	// maxTickets caps the size of a request's graph; 0 leaves it unbounded.
+	maxTickets      int
+	strictByDefault bool
+	allowDuplicates bool
+	normalizeCodes  bool
+}
+
+// Option configures a Dispatcher.
+type Option func(*Dispatcher)
+
+// WithMinLayover sets the minimum time between an arrival and the next departure
+// enforced by time-aware reconstruction.
+func WithMinLayover(d time.Duration) Option {
+	return func(dispatcher *Dispatcher) {
+		dispatcher.minLayover = d
+	}
+}
+
+// WithAirportValidation enables strict airport validation against the known codes.
+// When strictByDefault is false, it only applies to requests opting in.
+func WithAirportValidation(known func(code string) bool, strictByDefault bool) Option {
+	return func(dispatcher *Dispatcher) {
+		dispatcher.knownAirport = known
+		dispatcher.strictByDefault = strictByDefault
+	}
+}
+
+// WithDuplicateTolerance sets whether requests accept repeated identical tickets by default.
+func WithDuplicateTolerance(allow bool) Option {
+	return func(dispatcher *Dispatcher) {
+		dispatcher.allowDuplicates = allow
+	}
+}
+
+// WithMaxTickets rejects requests with more than max tickets with a *LimitError, so no caller
+// can have an arbitrarily large graph built. 0 disables the guard.
+func WithMaxTickets(maxTickets int) Option {
+	return func(dispatcher *Dispatcher) {
+		dispatcher.maxTickets = maxTickets
+	}
+}
+
+func New(opts ...Option) *Dispatcher {
+	d := &Dispatcher{
+		strategies:      builtinStrategies(),
+		defaultStrategy: StrategyDefault,
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
 
-func New() *Dispatcher {
-	return &Dispatcher{}
+	return d
 }
 
-func (d *Dispatcher) ReconstructItinerary(_ context.Context, tickets *[][]string) ([]string, error) {
-	return ReconstructItinerary(*tickets)
+// reconstructV1 is version "1" of the reconstruction algorithm, which later versions share with other server
+// defaults (see atVersion). The output of the built-in strategies must never change.
+//
+// When every ticket has a departure time the reconstruction is time-aware: the reconstructor registered as
+// StrategyChronological runs, and with the built-in one the chronology fully determines the order
+// (see ReconstructChronological). Otherwise the reconstructor of the request strategy
+// decides between the valid orderings, the default being the lexicographically smallest one; the ordering
+// of the request (tie-break policy and preferred hubs) decides which destination built-in reconstructors
+// take first.
+//
+// Permitted surface transfers stitch the gaps of open-jaw trips: between consecutive tickets in time-aware
+// reconstruction, and where the tickets would otherwise have several starting points in the others.
+//
+// With allowDuplicates, identical tickets are distinct edges of the graph and every leg
+// carries its multiplicity and occurrence (see AnnotateMultiplicity).
+func (d *Dispatcher) reconstructV1(
+	ctx context.Context, tickets []Ticket, strategy Strategy, order ordering, transfers []SurfaceTransfer, allowDuplicates bool,
+) ([]string, []Leg, error) {
+	reconstructor, err := d.reconstructorFor(strategy, tickets)
+	if err != nil {
+		return nil, nil, err
+	}
+	reconstructor = withOrdering(reconstructor, order)
+
+	var (
+		path []string
+		legs []Leg
+	)
+	if c, ok := reconstructor.(chronological); ok {
+		c.minLayover, c.transfers = d.minLayover, transfers
+		path, legs, err = c.Reconstruct(ctx, tickets, allowDuplicates)
+	} else {
+		path, legs, err = reconstructor.Reconstruct(ctx, stitchSurfaceTransfers(tickets, transfers), allowDuplicates)
+		markSurfaceTransfers(legs, len(tickets))
+	}
+	if err != nil || !allowDuplicates {
+		return path, legs, err
+	}
+
+	return path, AnnotateMultiplicity(legs), nil
+}
+
+// ReconstructItinerary is ReconstructItinerary aborting with the context error once ctx is done.
+func (d *Dispatcher) ReconstructItinerary(ctx context.Context, tickets *[][]string) ([]string, error) {
+	if err := d.checkTicketCount(len(*tickets)); err != nil {
+		return nil, err
+	}
+
+	return reconstructItinerary(ctx, *tickets, false, ordering{})
+}
+
+// ReconstructLegs is ReconstructLegs aborting with the context error once ctx is done.
+func (d *Dispatcher) ReconstructLegs(ctx context.Context, tickets *[][]string) ([]string, []Leg, error) {
+	if err := d.checkTicketCount(len(*tickets)); err != nil {
+		return nil, nil, err
+	}
+
+	return reconstructLegs(ctx, *tickets, false, ordering{})
+}
+
+// Validate validates the tickets of the request as Reconstruct sees them, with the options of the request
+// and its algorithm version: airport codes normalized, the permitted surface transfers stitched in, duplicate
+// tickets left unreported when they are allowed, and airport codes checked when strict airport validation
+// applies. It returns the report with the tickets validated, as pairs, which the report's indexes refer to:
+// the request's, followed by the surface transfers stitched in. It fails when the options themselves are
+// invalid, e.g. with ErrConflictingAlias.
+func (d *Dispatcher) Validate(_ context.Context, req *Request) (*ValidationReport, [][]string, error) {
+	if err := d.checkTicketCount(len(req.Tickets)); err != nil {
+		return nil, nil, err
+	}
+	d, err := d.atVersion(req.AlgorithmVersion)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = checkSurfaceTransfers(req.SurfaceTransfers); err != nil {
+		return nil, nil, err
+	}
+	tickets, _, err := d.normalizeTickets(req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pairs := Pairs(stitchSurfaceTransfers(tickets, req.SurfaceTransfers))
+	report := ValidateTickets(pairs)
+	if d.duplicatesAllowed(req.AllowDuplicates) {
+		report.Issues = slices.DeleteFunc(report.Issues, func(issue ValidationIssue) bool {
+			return issue.Kind == IssueDuplicateTicket
+		})
+		report.Valid = len(report.Issues) == 0
+	}
+	if d.strictAirports(req.StrictAirports) {
+		report.CheckAirports(pairs, d.knownAirport)
+	}
+
+	return report, pairs, nil
 }
 
 // ReconstructItinerary reconstructs a valid flight itinerary from a list of airline tickets.
@@ -32,38 +205,56 @@ func (d *Dispatcher) ReconstructItinerary(_ context.Context, tickets *[][]string
 //   - []string: The reconstructed itinerary as a sequence of airports
 //   - error: Error if the itinerary is invalid
 //
-// Possible errors:
+// Possible errors (wrapped in a *ValidationError carrying the full ValidationReport):
+//   - ErrMalformedTicket: When a ticket is not a non-empty [source, destination] pair
 //   - ErrMultipleSameDestination: When there are multiple tickets with the same source and destination
 //   - ErrCycleInItinerary: When the itinerary forms a cycle
 //   - ErrDifferentStartingPoints: When there are multiple valid starting points or invalid graph structure
+//   - ErrDisconnectedItinerary: When some tickets are not reachable from the rest of the itinerary
 //
 // Algorithm modifications from classical Hierholzer's:
 // 1. Ensures no duplicate edges (tickets) are allowed
 // 2. Prevents cycles in the final path
 // 3. Validates proper start/end points before path finding
-// 4. Uses lexicographically larger destinations first (reversed sort).
+// 4. Takes the lexicographically smallest destination first (destinations are sorted in reverse and taken
+// from the end), returning the lexicographically smallest itinerary (see TieBreakSmallestFirst).
 func ReconstructItinerary(tickets [][]string) ([]string, error) {
+	return reconstructItinerary(context.Background(), tickets, false, ordering{})
+}
+
+// reconstructItinerary is ReconstructItinerary, optionally accepting duplicate tickets as parallel edges, with
+// the ordering deciding between destinations. The graph build and the traversal return the context error
+// once ctx is done.
+func reconstructItinerary(ctx context.Context, tickets [][]string, allowDuplicates bool, order ordering) ([]string, error) {
 	if len(tickets) == 0 {
 		return []string{}, nil
 	}
 
-	if _, err := validateTickets(tickets); err != nil {
+	ws := newWorkspace(len(tickets))
+	defer ws.release()
+
+	if err := validateTickets(ws, tickets, allowDuplicates); err != nil {
 		return nil, err
 	}
 
-	graph, outDegree, inDegree := buildGraph(tickets)
+	graph := &ws.graph
+	if err := graph.build(ctx, &ws.interned, order); err != nil {
+		return nil, err
+	}
 
-	start, err := findStartingPoint(outDegree, inDegree)
+	start, err := graph.startingPoint()
 	if err != nil {
 		return nil, err
 	}
 
-	startCandidates := []string{start}
-	if err := validateEndPoints(startCandidates, outDegree, inDegree); err != nil {
+	if err = graph.validateEndPoints(); err != nil {
 		return nil, err
 	}
 
-	result := findPath(start, graph)
+	result, err := findPath(ctx, graph, start)
+	if err != nil {
+		return nil, err
+	}
 
 	if len(result) >= 2 && result[0] == result[len(result)-1] {
 		return nil, ErrCycleInItinerary
@@ -72,88 +263,140 @@ func ReconstructItinerary(tickets [][]string) ([]string, error) {
 	return result, nil
 }
 
-// validateTickets checks for duplicate tickets and returns a map of ticket counts.
-func validateTickets(tickets [][]string) (map[[2]string]int, error) {
-	ticketCount := make(map[[2]string]int)
-	for _, ticket := range tickets {
-		key := [2]string{ticket[0], ticket[1]}
-		ticketCount[key]++
-		if ticketCount[key] > 1 {
-			return nil, ErrMultipleSameDestination
+// ticketGraph is the adjacency list of the tickets over interned airport IDs.
+type ticketGraph struct {
+	// codes maps airport IDs back to airport codes.
+	codes []string
+	// destinations holds the destinations of every airport in the reverse order of the ordering,
+	// so the traversal takes the preferred one from the end.
+	destinations [][]int
+	outDegree    []int
+	inDegree     []int
+	// backing is the array shared by the destinations of all airports, stack the traversal stack.
+	backing []int
+	stack   []int
+}
+
+// build creates the adjacency list and degrees from the interned tickets, reusing the buffers of g.
+func (g *ticketGraph) build(ctx context.Context, tickets *internedTickets, order ordering) error {
+	airports := tickets.airports()
+	g.codes = tickets.codes
+	g.destinations = resize(g.destinations, airports)
+	g.outDegree = resize(g.outDegree, airports)
+	g.inDegree = resize(g.inDegree, airports)
+
+	for _, edge := range tickets.edges {
+		g.outDegree[edge[0]]++
+		g.inDegree[edge[1]]++
+	}
+
+	g.backing = resize(g.backing, len(tickets.edges))
+	offset := 0
+	for airport, degree := range g.outDegree {
+		g.destinations[airport] = g.backing[offset : offset : offset+degree]
+		offset += degree
+	}
+
+	for i, edge := range tickets.edges {
+		if err := checkContext(ctx, i); err != nil {
+			return err
 		}
+		g.destinations[edge[0]] = append(g.destinations[edge[0]], edge[1])
 	}
 
-	return ticketCount, nil
-}
+	for _, dests := range g.destinations {
+		if len(dests) > 1 {
+			order.arrange(dests, g.codes)
+		}
+	}
 
-// buildGraph creates adjacency list and degree maps from tickets.
-func buildGraph(tickets [][]string) (map[string][]string, map[string]int, map[string]int) {
-	graph := make(map[string][]string)
-	outDegree := make(map[string]int)
-	inDegree := make(map[string]int)
+	return nil
+}
 
-	for _, ticket := range tickets {
-		src, dst := ticket[0], ticket[1]
-		graph[src] = append(graph[src], dst)
-		outDegree[src]++
-		inDegree[dst]++
+// startingPoint determines the valid starting airport.
+func (g *ticketGraph) startingPoint() (int, error) {
+	start, starts := 0, 0
+	for airport := range g.outDegree {
+		switch g.outDegree[airport] - g.inDegree[airport] {
+		case -1, 0:
+		case 1:
+			start = airport
+			starts++
+		default:
+			return 0, ErrDifferentStartingPoints
+		}
 	}
 
-	for src := range graph {
-		sort.Slice(graph[src], func(i, j int) bool {
-			return graph[src][i] > graph[src][j]
-		})
+	// With every airport balanced there is no unique starting point.
+	if starts != 1 {
+		return 0, ErrDifferentStartingPoints
 	}
 
-	return graph, outDegree, inDegree
+	return start, nil
 }
 
-// findStartingPoint determines the valid starting airport.
-func findStartingPoint(outDegree, inDegree map[string]int) (string, error) {
-	startCandidates := []string{}
-	validStart := true
-
-	for node := range outDegree {
-		diff := outDegree[node] - inDegree[node]
-		if diff == 1 {
-			startCandidates = append(startCandidates, node)
-		} else if diff < -1 || diff > 1 {
-			validStart = false
+// Leg is a single step of the reconstructed itinerary.
+// TicketIndex points back to the ticket in the original input the step was made with.
+//
+// Multiplicity and Occurrence are only set when duplicate tickets are tolerated: Multiplicity is the number
+// of identical tickets for the segment and Occurrence the 1-based order in which this one is flown.
+type Leg struct {
+	From         string `json:"from"`
+	To           string `json:"to"`
+	TicketIndex  int    `json:"ticket_index"`
+	Multiplicity int    `json:"multiplicity,omitempty"`
+	Occurrence   int    `json:"occurrence,omitempty"`
+	// SurfaceTransfer marks a permitted gap of an open-jaw trip (see SurfaceTransfer), made without a ticket:
+	// its TicketIndex is -1.
+	SurfaceTransfer bool `json:"surface_transfer,omitempty"`
+}
 
-			break
-		}
-	}
+// ReconstructLegs works like ReconstructItinerary and additionally maps each step of the path
+// back to the ticket it was made with.
+func ReconstructLegs(tickets [][]string) ([]string, []Leg, error) {
+	return reconstructLegs(context.Background(), tickets, false, ordering{})
+}
 
-	if !validStart || len(startCandidates) > 1 {
-		return "", ErrDifferentStartingPoints
+func reconstructLegs(ctx context.Context, tickets [][]string, allowDuplicates bool, order ordering) ([]string, []Leg, error) {
+	path, err := reconstructItinerary(ctx, tickets, allowDuplicates, order)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	if len(startCandidates) == 1 {
-		return startCandidates[0], nil
+	// Repeated identical tickets are matched to the path in input order.
+	indexes := make(map[[2]string][]int, len(tickets))
+	for i, ticket := range tickets {
+		key := [2]string{ticket[0], ticket[1]}
+		indexes[key] = append(indexes[key], i)
 	}
 
-	// Check if all nodes are balanced
-	for node := range outDegree {
-		if outDegree[node] != inDegree[node] {
-			return "", ErrDifferentStartingPoints
-		}
+	legs := make([]Leg, 0, len(tickets))
+	for i := 1; i < len(path); i++ {
+		key := [2]string{path[i-1], path[i]}
+		legs = append(legs, Leg{
+			From:        path[i-1],
+			To:          path[i],
+			TicketIndex: indexes[key][0],
+		})
+		indexes[key] = indexes[key][1:]
 	}
 
-	return "", ErrDifferentStartingPoints
+	return path, legs, nil
 }
 
-// validateEndPoints ensures the graph has valid end points.
-func validateEndPoints(startCandidates []string, outDegree, inDegree map[string]int) error {
-	endCandidates := 0
-	for node := range inDegree {
-		diff := inDegree[node] - outDegree[node]
-		if diff == 1 {
-			endCandidates++
-		} else if diff < -1 || diff > 1 {
+// validateEndPoints ensures the graph has a single valid end point.
+func (g *ticketGraph) validateEndPoints() error {
+	ends := 0
+	for airport := range g.inDegree {
+		switch g.inDegree[airport] - g.outDegree[airport] {
+		case -1, 0:
+		case 1:
+			ends++
+		default:
 			return ErrDifferentStartingPoints
 		}
 	}
-	if len(startCandidates) == 1 && endCandidates != 1 {
+	if ends != 1 {
 		return ErrDifferentStartingPoints
 	}
 
@@ -161,26 +404,72 @@ func validateEndPoints(startCandidates []string, outDegree, inDegree map[string]
 }
 
 // findPath uses modified Hierholzer's algorithm to find the path.
-func findPath(start string, graph map[string][]string) []string {
-	var result []string
-	stack := []string{start}
+func findPath(ctx context.Context, graph *ticketGraph, start int) ([]string, error) {
+	// The path takes every ticket, so it has one airport more than there are tickets.
+	result := make([]string, 0, len(graph.backing)+1)
+	err := walkPath(ctx, graph, start, func(airport string) error {
+		result = append(result, airport)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
-	for len(stack) > 0 {
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+
+	return result, nil
+}
+
+// walkPath runs Hierholzer's traversal from start, consuming the graph, and calls visit with each airport
+// as the traversal unwinds, i.e. in reverse path order. It stops at the first error returned by visit
+// and returns the context error once ctx is done.
+func walkPath(ctx context.Context, graph *ticketGraph, start int, visit func(airport string) error) error {
+	stack := append(graph.stack[:0], start)
+	defer func() {
+		graph.stack = stack[:0]
+	}()
+
+	for step := 0; len(stack) > 0; step++ {
+		if err := checkContext(ctx, step); err != nil {
+			return err
+		}
 		curr := stack[len(stack)-1]
 
-		if dests, exists := graph[curr]; exists && len(dests) > 0 {
-			nextDest := dests[len(dests)-1]
-			graph[curr] = dests[:len(dests)-1]
-			stack = append(stack, nextDest)
+		if dests := graph.destinations[curr]; len(dests) > 0 {
+			graph.destinations[curr] = dests[:len(dests)-1]
+			stack = append(stack, dests[len(dests)-1])
 		} else {
-			result = append(result, curr)
+			if err := visit(graph.codes[curr]); err != nil {
+				return err
+			}
 			stack = stack[:len(stack)-1]
 		}
 	}
 
-	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
-		result[i], result[j] = result[j], result[i]
+	return nil
+}
+
+// contextCheckInterval is the number of loop iterations between two context checks, so long
+// reconstructions abort soon after a client disconnects without paying for a check on every step.
+const contextCheckInterval = 1024
+
+// checkContext returns the context error on every contextCheckInterval-th iteration once ctx is done.
+func checkContext(ctx context.Context, iteration int) error {
+	if iteration%contextCheckInterval != 0 {
+		return nil
+	}
+
+	return ctx.Err()
+}
+
+// checkTicketCount enforces WithMaxTickets.
+func (d *Dispatcher) checkTicketCount(tickets int) error {
+	if d.maxTickets > 0 && tickets > d.maxTickets {
+		return &LimitError{Tickets: tickets, MaxTickets: d.maxTickets}
 	}
 
-	return result
+	return nil
 }
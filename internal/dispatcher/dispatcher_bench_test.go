@@ -0,0 +1,104 @@
+package dispatcher_test
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/dsha256/dispatcher/internal/dispatcher"
+)
+
+// linearTickets returns the shuffled tickets of a path through n+1 distinct airports.
+func linearTickets(n int) [][]string {
+	tickets := make([][]string, 0, n)
+	for i := range n {
+		tickets = append(tickets, []string{fmt.Sprintf("A%06d", i), fmt.Sprintf("A%06d", i+1)})
+	}
+	rand.New(rand.NewSource(1)).Shuffle(len(tickets), func(i, j int) {
+		tickets[i], tickets[j] = tickets[j], tickets[i]
+	})
+
+	return tickets
+}
+
+// hubTickets returns the shuffled tickets of a path returning to a few hubs between every other airport,
+// so the traversal has many outgoing tickets to choose from at each hub.
+func hubTickets(n int) [][]string {
+	const hubs = 16
+
+	tickets := make([][]string, 0, n)
+	for i := 0; len(tickets) < n; i++ {
+		hub, spoke := fmt.Sprintf("H%02d", i%hubs), fmt.Sprintf("S%06d", i)
+		next := fmt.Sprintf("H%02d", (i+1)%hubs)
+		tickets = append(tickets, []string{hub, spoke}, []string{spoke, next})
+	}
+	// End away from the hubs, so the path does not close into a loop.
+	tickets = tickets[:n]
+	tickets[n-1] = []string{tickets[n-1][0], "END"}
+	rand.New(rand.NewSource(1)).Shuffle(len(tickets), func(i, j int) {
+		tickets[i], tickets[j] = tickets[j], tickets[i]
+	})
+
+	return tickets
+}
+
+func BenchmarkReconstructItinerary(b *testing.B) {
+	benchmarks := []struct {
+		tickets func(n int) [][]string
+		name    string
+		n       int
+	}{
+		{name: "Linear 1k", tickets: linearTickets, n: 1_000},
+		{name: "Linear 100k", tickets: linearTickets, n: 100_000},
+		{name: "Hubs 100k", tickets: hubTickets, n: 100_000},
+	}
+
+	for _, bm := range benchmarks {
+		tickets := bm.tickets(bm.n)
+		if _, err := dispatcher.ReconstructItinerary(tickets); err != nil {
+			b.Fatalf("%s: ReconstructItinerary() error = %v", bm.name, err)
+		}
+
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				if _, err := dispatcher.ReconstructItinerary(tickets); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkReconstructItineraryParallel reconstructs from every available CPU at once, like sustained traffic.
+func BenchmarkReconstructItineraryParallel(b *testing.B) {
+	for _, n := range []int{100, 10_000} {
+		tickets := linearTickets(n)
+
+		b.Run(fmt.Sprintf("Linear %d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if _, err := dispatcher.ReconstructItinerary(tickets); err != nil {
+						b.Error(err)
+
+						return
+					}
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkCanonicalize(b *testing.B) {
+	pairs := linearTickets(100_000)
+	tickets := make([]dispatcher.Ticket, len(pairs))
+	for i, pair := range pairs {
+		tickets[i] = dispatcher.TicketFromPair(pair)
+	}
+
+	b.ReportAllocs()
+	for b.Loop() {
+		dispatcher.Canonicalize(tickets)
+	}
+}
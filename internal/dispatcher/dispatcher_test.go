@@ -1,8 +1,13 @@
 package dispatcher_test
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"reflect"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/dsha256/dispatcher/internal/dispatcher"
 )
@@ -99,3 +104,108 @@ func TestReconstructItinerary(t *testing.T) {
 		})
 	}
 }
+
+// TestReconstructItineraryConcurrent interleaves large, small and invalid ticket sets from many goroutines,
+// so pooled buffers are reused across sets of every size and must not leak from one reconstruction to the next.
+func TestReconstructItineraryConcurrent(t *testing.T) {
+	t.Parallel()
+
+	large := make([][]string, 0, 500)
+	expectedLarge := []string{"A000"}
+	for i := range 500 {
+		large = append(large, []string{fmt.Sprintf("A%03d", i), fmt.Sprintf("A%03d", i+1)})
+		expectedLarge = append(expectedLarge, fmt.Sprintf("A%03d", i+1))
+	}
+
+	tests := []struct {
+		err      error
+		tickets  [][]string
+		expected []string
+	}{
+		{tickets: large, expected: expectedLarge},
+		{tickets: [][]string{{"LAX", "DXB"}, {"JFK", "LAX"}}, expected: []string{"JFK", "LAX", "DXB"}},
+		{tickets: [][]string{{"JFK", "SFO"}, {"JFK", "ATL"}, {"SFO", "ATL"}, {"ATL", "JFK"}}, expected: []string{"JFK", "ATL", "JFK", "SFO", "ATL"}},
+		{tickets: [][]string{{"SFO", "LAX"}, {"LAX", "SFO"}}, err: dispatcher.ErrDifferentStartingPoints},
+		{tickets: [][]string{{"JFK", "LAX"}, {"JFK", "LAX"}}, err: dispatcher.ErrMultipleSameDestination},
+	}
+
+	var wg sync.WaitGroup
+	for worker := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := range 50 {
+				tt := tests[(worker+i)%len(tests)]
+				result, err := dispatcher.ReconstructItinerary(tt.tickets)
+				if !errors.Is(err, tt.err) {
+					t.Errorf("ReconstructItinerary() error = %v, want %v", err, tt.err)
+
+					return
+				}
+				if tt.err == nil && !reflect.DeepEqual(result, tt.expected) {
+					t.Errorf("ReconstructItinerary() = %v, want %v", result, tt.expected)
+
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestReconstructContextDone(t *testing.T) {
+	t.Parallel()
+
+	tickets := []dispatcher.Ticket{{From: "JFK", To: "LAX"}, {From: "LAX", To: "DXB"}}
+	d := dispatcher.New()
+
+	tests := []struct {
+		err     error
+		name    string
+		expired bool
+	}{
+		{name: "Canceled", err: context.Canceled},
+		{name: "Deadline exceeded", expired: true, err: context.DeadlineExceeded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			if tt.expired {
+				ctx, cancel = context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+				defer cancel()
+			}
+
+			if _, err := d.Reconstruct(ctx, &dispatcher.Request{Tickets: tickets}); !errors.Is(err, tt.err) {
+				t.Errorf("Reconstruct() error = %v; want %v", err, tt.err)
+			}
+
+			pairs := dispatcher.Pairs(tickets)
+			if _, err := d.ReconstructItinerary(ctx, &pairs); !errors.Is(err, tt.err) {
+				t.Errorf("ReconstructItinerary() error = %v; want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestDispatcherMaxTickets(t *testing.T) {
+	t.Parallel()
+
+	d := dispatcher.New(dispatcher.WithMaxTickets(2))
+	tickets := []dispatcher.Ticket{{From: "JFK", To: "LAX"}, {From: "LAX", To: "DXB"}, {From: "DXB", To: "SFO"}}
+
+	if _, err := d.Reconstruct(context.Background(), &dispatcher.Request{Tickets: tickets}); !errors.Is(err, dispatcher.ErrTooManyTickets) {
+		t.Errorf("Reconstruct() error = %v; want %v", err, dispatcher.ErrTooManyTickets)
+	}
+	err := d.StreamItinerary(context.Background(), &dispatcher.Request{Tickets: tickets}, func(int, string) error { return nil })
+	if !errors.Is(err, dispatcher.ErrTooManyTickets) {
+		t.Errorf("StreamItinerary() error = %v; want %v", err, dispatcher.ErrTooManyTickets)
+	}
+	if _, err = d.Reconstruct(context.Background(), &dispatcher.Request{Tickets: tickets[:2]}); err != nil {
+		t.Errorf("Reconstruct() error = %v; want nil at the limit", err)
+	}
+}
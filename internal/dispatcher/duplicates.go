@@ -0,0 +1,42 @@
+package dispatcher
+
+// validateTickets validates the tickets, interning them into ws, and returns a *ValidationError when
+// they are invalid. With allowDuplicates, repeated identical tickets are not an issue on their own.
+func validateTickets(ws *workspace, tickets [][]string, allowDuplicates bool) error {
+	report := validate(ws, tickets)
+	if allowDuplicates {
+		issues := make([]ValidationIssue, 0, len(report.Issues))
+		for _, issue := range report.Issues {
+			if issue.Kind != IssueDuplicateTicket {
+				issues = append(issues, issue)
+			}
+		}
+		report.Issues = issues
+		report.Valid = len(issues) == 0
+	}
+	if !report.Valid {
+		return &ValidationError{Err: report.Err(), Report: report}
+	}
+
+	return nil
+}
+
+// AnnotateMultiplicity sets the multiplicity of every leg, the number of legs flying the same segment,
+// and its occurrence, the 1-based position among them in path order. This lets billing match every
+// physical ticket of a repeated segment to its position in the path.
+func AnnotateMultiplicity(legs []Leg) []Leg {
+	counts := make(map[[2]string]int, len(legs))
+	for _, leg := range legs {
+		counts[[2]string{leg.From, leg.To}]++
+	}
+
+	seen := make(map[[2]string]int, len(legs))
+	for i := range legs {
+		key := [2]string{legs[i].From, legs[i].To}
+		seen[key]++
+		legs[i].Multiplicity = counts[key]
+		legs[i].Occurrence = seen[key]
+	}
+
+	return legs
+}
@@ -0,0 +1,107 @@
+package dispatcher_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/dsha256/dispatcher/internal/dispatcher"
+)
+
+func TestReconstructWithDuplicateTolerance(t *testing.T) {
+	t.Parallel()
+
+	allow, deny := true, false
+	tickets := []dispatcher.Ticket{
+		{From: "JFK", To: "LAX"},
+		{From: "LAX", To: "JFK"},
+		{From: "JFK", To: "LAX"},
+		{From: "LAX", To: "SFO"},
+	}
+
+	tests := []struct {
+		override *bool
+		err      error
+		name     string
+		version  string
+		legs     []dispatcher.Leg
+		byConfig bool
+	}{
+		{
+			name:     "Rejected by default",
+			err:      dispatcher.ErrMultipleSameDestination,
+			override: nil,
+		},
+		{
+			name:     "Allowed by the request",
+			override: &allow,
+			legs: []dispatcher.Leg{
+				{From: "JFK", To: "LAX", TicketIndex: 0, Multiplicity: 2, Occurrence: 1},
+				{From: "LAX", To: "JFK", TicketIndex: 1, Multiplicity: 1, Occurrence: 1},
+				{From: "JFK", To: "LAX", TicketIndex: 2, Multiplicity: 2, Occurrence: 2},
+				{From: "LAX", To: "SFO", TicketIndex: 3, Multiplicity: 1, Occurrence: 1},
+			},
+		},
+		{
+			name:     "Allowed by configuration",
+			byConfig: true,
+			legs: []dispatcher.Leg{
+				{From: "JFK", To: "LAX", TicketIndex: 0, Multiplicity: 2, Occurrence: 1},
+				{From: "LAX", To: "JFK", TicketIndex: 1, Multiplicity: 1, Occurrence: 1},
+				{From: "JFK", To: "LAX", TicketIndex: 2, Multiplicity: 2, Occurrence: 2},
+				{From: "LAX", To: "SFO", TicketIndex: 3, Multiplicity: 1, Occurrence: 1},
+			},
+		},
+		{
+			name:     "Configuration ignored by version 1",
+			byConfig: true,
+			version:  "1",
+			err:      dispatcher.ErrMultipleSameDestination,
+		},
+		{
+			name:     "Allowed by the request with version 1",
+			byConfig: true,
+			override: &allow,
+			version:  "1",
+			legs: []dispatcher.Leg{
+				{From: "JFK", To: "LAX", TicketIndex: 0, Multiplicity: 2, Occurrence: 1},
+				{From: "LAX", To: "JFK", TicketIndex: 1, Multiplicity: 1, Occurrence: 1},
+				{From: "JFK", To: "LAX", TicketIndex: 2, Multiplicity: 2, Occurrence: 2},
+				{From: "LAX", To: "SFO", TicketIndex: 3, Multiplicity: 1, Occurrence: 1},
+			},
+		},
+		{
+			name:     "Request opts out",
+			byConfig: true,
+			override: &deny,
+			err:      dispatcher.ErrMultipleSameDestination,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			d := dispatcher.New(dispatcher.WithDuplicateTolerance(tt.byConfig))
+			result, err := d.Reconstruct(context.Background(), &dispatcher.Request{
+				AllowDuplicates:  tt.override,
+				AlgorithmVersion: tt.version,
+				Tickets:          tickets,
+			})
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Reconstruct() error = %v; want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+
+			if want := []string{"JFK", "LAX", "JFK", "LAX", "SFO"}; !reflect.DeepEqual(result.Path, want) {
+				t.Errorf("Reconstruct() path = %v; want %v", result.Path, want)
+			}
+			if !reflect.DeepEqual(result.Legs, tt.legs) {
+				t.Errorf("Reconstruct() legs = %+v; want %+v", result.Legs, tt.legs)
+			}
+		})
+	}
+}
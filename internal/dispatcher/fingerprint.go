@@ -0,0 +1,112 @@
+package dispatcher
+
+import (
+	"bytes"
+	"cmp"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// CanonicalTickets is a ticket set in its canonical order, which does not depend on the order the
+// tickets were given in.
+type CanonicalTickets struct {
+	// Fingerprint is the hex SHA-256 of the tickets in canonical order: the same tickets in any order
+	// share a fingerprint. Tickets are compared on all their fields, so the same flight at another
+	// price is another ticket.
+	Fingerprint string
+	// Order lists the indexes of the tickets in canonical order. Identical tickets keep their relative order.
+	Order []int
+}
+
+// Canonicalize sorts the tickets into their canonical order and fingerprints them.
+func Canonicalize(tickets []Ticket) CanonicalTickets {
+	// The encodings share one buffer, sized for tickets without metadata.
+	buf := make([]byte, 0, len(tickets)*canonicalTicketSize)
+	encoded := make([]canonicalTicket, len(tickets))
+	for i := range tickets {
+		start := len(buf)
+		buf = appendCanonical(buf, &tickets[i])
+		encoded[i] = canonicalTicket{encoding: buf[start:len(buf):len(buf)], index: i}
+	}
+
+	// Breaking ties by index sorts stably, faster than a stable sort.
+	slices.SortFunc(encoded, func(a, b canonicalTicket) int {
+		if c := bytes.Compare(a.encoding, b.encoding); c != 0 {
+			return c
+		}
+
+		return cmp.Compare(a.index, b.index)
+	})
+
+	hash := sha256.New()
+	order := make([]int, len(encoded))
+	for i, ticket := range encoded {
+		hash.Write(ticket.encoding)
+		order[i] = ticket.index
+	}
+
+	return CanonicalTickets{Fingerprint: hex.EncodeToString(hash.Sum(nil)), Order: order}
+}
+
+// canonicalTicketSize is the size of the encoding of a ticket between IATA codes without metadata.
+const canonicalTicketSize = 16
+
+type canonicalTicket struct {
+	encoding []byte
+	index    int
+}
+
+// Fingerprint returns the fingerprint of the ticket set, see CanonicalTickets.
+func Fingerprint(tickets []Ticket) string {
+	return Canonicalize(tickets).Fingerprint
+}
+
+// appendCanonical appends the encoding of every field of the ticket, each prefixed by its length, so
+// distinct tickets never share an encoding.
+func appendCanonical(buf []byte, t *Ticket) []byte {
+	buf = appendField(buf, t.From)
+	buf = appendField(buf, t.To)
+	buf = appendField(buf, t.FlightNo)
+
+	// The currency shares the field of the price, so tickets without one keep their encoding.
+	price := ""
+	if t.Price != nil {
+		price = strconv.FormatFloat(*t.Price, 'g', -1, 64)
+	}
+	if t.Currency != "" {
+		price += " " + t.Currency
+	}
+	buf = appendField(buf, price)
+	buf = appendField(buf, formatTime(t.DepartsAt))
+	buf = appendField(buf, formatTime(t.ArrivesAt))
+
+	// Maps are encoded with sorted keys. Metadata decoded from JSON always encodes.
+	var metadata []byte
+	if len(t.Metadata) > 0 {
+		if encoded, err := json.Marshal(t.Metadata); err == nil {
+			metadata = encoded
+		}
+	}
+
+	return appendField(buf, string(metadata))
+}
+
+func appendField(buf []byte, field string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(field)))
+
+	return append(buf, field...)
+}
+
+// formatTime keeps the offset of the time, since blackout dates are evaluated in it.
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+
+	return t.Format(time.RFC3339Nano)
+}
@@ -0,0 +1,88 @@
+package dispatcher_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/dsha256/dispatcher/internal/dispatcher"
+)
+
+func TestFingerprint(t *testing.T) {
+	t.Parallel()
+
+	const base = `[["JFK", "LAX"], ["LAX", "DXB"], {"from": "DXB", "to": "SFO", "price": 120, "metadata": {"cabin": "Y", "fare": "basic"}}]`
+
+	tests := []struct {
+		name    string
+		tickets string
+		same    bool
+	}{
+		{
+			name:    "Other order",
+			tickets: `[{"from": "DXB", "to": "SFO", "price": 120, "metadata": {"cabin": "Y", "fare": "basic"}}, ["LAX", "DXB"], ["JFK", "LAX"]]`,
+			same:    true,
+		},
+		{
+			name:    "Tickets as objects, metadata keys reordered",
+			tickets: `[{"from": "JFK", "to": "LAX"}, {"to": "DXB", "from": "LAX"}, {"metadata": {"fare": "basic", "cabin": "Y"}, "price": 120.0, "from": "DXB", "to": "SFO"}]`,
+			same:    true,
+		},
+		{
+			name:    "Other price",
+			tickets: `[["JFK", "LAX"], ["LAX", "DXB"], {"from": "DXB", "to": "SFO", "price": 121, "metadata": {"cabin": "Y", "fare": "basic"}}]`,
+		},
+		{
+			name:    "Other currency",
+			tickets: `[["JFK", "LAX"], ["LAX", "DXB"], {"from": "DXB", "to": "SFO", "price": 120, "currency": "EUR", "metadata": {"cabin": "Y", "fare": "basic"}}]`,
+		},
+		{
+			name:    "Airports moved between fields",
+			tickets: `[["JFK", "LAX"], ["LAX", "DXB"], {"from": "DXB", "to": "SFO", "flight_no": "120"}]`,
+		},
+		{
+			name:    "Duplicated ticket",
+			tickets: `[["JFK", "LAX"], ["JFK", "LAX"], ["LAX", "DXB"], {"from": "DXB", "to": "SFO", "price": 120, "metadata": {"cabin": "Y", "fare": "basic"}}]`,
+		},
+	}
+
+	want := dispatcher.Fingerprint(decodeTickets(t, base))
+	if len(want) != 64 {
+		t.Fatalf("Fingerprint() = %q, want a hex SHA-256", want)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := dispatcher.Fingerprint(decodeTickets(t, tt.tickets)); (got == want) != tt.same {
+				t.Errorf("Fingerprint() = %q, base %q, want same = %v", got, want, tt.same)
+			}
+		})
+	}
+}
+
+func TestCanonicalizeOrder(t *testing.T) {
+	t.Parallel()
+
+	first := dispatcher.Canonicalize(decodeTickets(t, `[["LAX", "DXB"], ["JFK", "LAX"], ["JFK", "LAX"]]`))
+	second := dispatcher.Canonicalize(decodeTickets(t, `[["JFK", "LAX"], ["LAX", "DXB"], ["JFK", "LAX"]]`))
+
+	// Identical tickets keep their relative order.
+	if fmt.Sprint(first.Order) != "[1 2 0]" || fmt.Sprint(second.Order) != "[0 2 1]" {
+		t.Errorf("Canonicalize() orders = %v and %v, want [1 2 0] and [0 2 1]", first.Order, second.Order)
+	}
+	if first.Fingerprint != second.Fingerprint {
+		t.Errorf("Canonicalize() fingerprints differ: %q and %q", first.Fingerprint, second.Fingerprint)
+	}
+}
+
+func decodeTickets(t *testing.T, raw string) []dispatcher.Ticket {
+	t.Helper()
+
+	var tickets []dispatcher.Ticket
+	if err := json.Unmarshal([]byte(raw), &tickets); err != nil {
+		t.Fatalf("Failed to decode tickets: %v", err)
+	}
+
+	return tickets
+}
@@ -0,0 +1,300 @@
+package dispatcher
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// NodeRole marks how an airport takes part in the itinerary, as seen by validation.
+type NodeRole string
+
+const (
+	NodeRoleStart      NodeRole = "start"
+	NodeRoleEnd        NodeRole = "end"
+	NodeRoleUnbalanced NodeRole = "unbalanced"
+)
+
+// GraphNode is an airport of the ticket graph.
+type GraphNode struct {
+	Role       NodeRole `json:"role,omitempty"`
+	Airport    string   `json:"airport"`
+	Departures int      `json:"departures"`
+	Arrivals   int      `json:"arrivals"`
+}
+
+// GraphEdge is a single ticket. Duplicate and Disconnected flag the tickets behind validation issues.
+type GraphEdge struct {
+	From         string `json:"from"`
+	To           string `json:"to"`
+	TicketIndex  int    `json:"ticket_index"`
+	Duplicate    bool   `json:"duplicate,omitempty"`
+	Disconnected bool   `json:"disconnected,omitempty"`
+}
+
+// Graph is the ticket graph annotated with the findings of a validation report,
+// ready to be serialized for visualization.
+type Graph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// NewGraph builds the graph of the well-formed tickets. Nodes are ordered by first appearance
+// and edges by ticket index. The report, typically from ValidateTickets, may be nil.
+func NewGraph(tickets [][]string, report *ValidationReport) *Graph {
+	builder := NewGraphBuilder()
+	for _, ticket := range tickets {
+		builder.Add(ticket)
+	}
+
+	return builder.Build(report)
+}
+
+// GraphBuilder builds a Graph one ticket at a time.
+type GraphBuilder struct {
+	graph   *Graph
+	nodes   map[string]int
+	tickets int
+}
+
+func NewGraphBuilder() *GraphBuilder {
+	return &GraphBuilder{
+		graph: &Graph{Nodes: []GraphNode{}, Edges: []GraphEdge{}},
+		nodes: make(map[string]int),
+	}
+}
+
+// Add adds the next ticket; its index is the number of tickets added before it. Malformed tickets are counted but skipped.
+func (b *GraphBuilder) Add(ticket []string) {
+	index := b.tickets
+	b.tickets++
+	if len(ticket) != 2 || ticket[0] == "" || ticket[1] == "" {
+		return
+	}
+
+	b.node(ticket[0]).Departures++
+	b.node(ticket[1]).Arrivals++
+	b.graph.Edges = append(b.graph.Edges, GraphEdge{From: ticket[0], To: ticket[1], TicketIndex: index})
+}
+
+// Build annotates the graph with the findings of the report, which may be nil, and returns it.
+func (b *GraphBuilder) Build(report *ValidationReport) *Graph {
+	if report == nil {
+		return b.graph
+	}
+
+	duplicates, disconnected := make(map[int]bool), make(map[int]bool)
+	for _, issue := range report.Issues {
+		for _, i := range issue.Indexes {
+			switch issue.Kind {
+			case IssueDuplicateTicket:
+				duplicates[i] = true
+			case IssueDisconnected:
+				disconnected[i] = true
+			default:
+			}
+		}
+	}
+	for i := range b.graph.Edges {
+		edge := &b.graph.Edges[i]
+		edge.Duplicate = duplicates[edge.TicketIndex]
+		edge.Disconnected = disconnected[edge.TicketIndex]
+	}
+
+	for _, airport := range report.StartCandidates {
+		b.node(airport).Role = NodeRoleStart
+	}
+	for _, airport := range report.EndCandidates {
+		b.node(airport).Role = NodeRoleEnd
+	}
+	for _, degree := range report.Unbalanced {
+		b.node(degree.Airport).Role = NodeRoleUnbalanced
+	}
+
+	return b.graph
+}
+
+func (b *GraphBuilder) node(airport string) *GraphNode {
+	i, ok := b.nodes[airport]
+	if !ok {
+		i = len(b.graph.Nodes)
+		b.nodes[airport] = i
+		b.graph.Nodes = append(b.graph.Nodes, GraphNode{Airport: airport})
+	}
+
+	return &b.graph.Nodes[i]
+}
+
+// DOT serializes the graph in the GraphViz DOT language. Start airports are green, end airports blue
+// and unbalanced airports red; duplicate tickets are red and disconnected tickets dashed.
+func (g *Graph) DOT() string {
+	var b strings.Builder
+	b.WriteString("digraph itinerary {\n")
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=box];\n")
+	for _, node := range g.Nodes {
+		attrs := []string{fmt.Sprintf("label=%s", dotQuote(fmt.Sprintf("%s\nout %d / in %d", node.Airport, node.Departures, node.Arrivals)))}
+		if color, ok := node.Role.color(); ok {
+			attrs = append(attrs, "color="+color)
+		}
+		fmt.Fprintf(&b, "  %s [%s];\n", dotQuote(node.Airport), strings.Join(attrs, ", "))
+	}
+	for _, edge := range g.Edges {
+		attrs := []string{fmt.Sprintf("label=%s", dotQuote(fmt.Sprintf("#%d", edge.TicketIndex)))}
+		if edge.Duplicate {
+			attrs = append(attrs, "color=red")
+		}
+		if edge.Disconnected {
+			attrs = append(attrs, "style=dashed")
+		}
+		fmt.Fprintf(&b, "  %s -> %s [%s];\n", dotQuote(edge.From), dotQuote(edge.To), strings.Join(attrs, ", "))
+	}
+	b.WriteString("}\n")
+
+	return b.String()
+}
+
+// Mermaid serializes the graph as a Mermaid flowchart with the same highlighting as DOT.
+func (g *Graph) Mermaid() string {
+	ids := make(map[string]string, len(g.Nodes))
+
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+	for i, node := range g.Nodes {
+		id := fmt.Sprintf("n%d", i)
+		ids[node.Airport] = id
+		fmt.Fprintf(&b, "  %s[\"%s<br/>out %d / in %d\"]\n", id, mermaidEscape(node.Airport), node.Departures, node.Arrivals)
+	}
+	for i, edge := range g.Edges {
+		arrow := "-->"
+		if edge.Disconnected {
+			arrow = "-.->"
+		}
+		fmt.Fprintf(&b, "  %s %s|\"#%d\"| %s\n", ids[edge.From], arrow, edge.TicketIndex, ids[edge.To])
+		if edge.Duplicate {
+			fmt.Fprintf(&b, "  linkStyle %d stroke:red\n", i)
+		}
+	}
+	for i, node := range g.Nodes {
+		if color, ok := node.Role.color(); ok {
+			fmt.Fprintf(&b, "  style n%d stroke:%s\n", i, color)
+		}
+	}
+
+	return b.String()
+}
+
+func (r NodeRole) color() (string, bool) {
+	switch r {
+	case NodeRoleStart:
+		return "green", true
+	case NodeRoleEnd:
+		return "blue", true
+	case NodeRoleUnbalanced:
+		return "red", true
+	default:
+		return "", false
+	}
+}
+
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+
+	return `"` + s + `"`
+}
+
+func mermaidEscape(s string) string {
+	return strings.ReplaceAll(s, `"`, "#quot;")
+}
+
+// GraphStats summarizes the shape of the ticket graph.
+type GraphStats struct {
+	// Components are the weakly connected components, each sorted, largest first.
+	Components [][]string `json:"components"`
+	// Degrees lists the departures (out-degree) and arrivals (in-degree) of every airport in node order.
+	Degrees   []AirportDegree `json:"degrees"`
+	NodeCount int             `json:"node_count"`
+	EdgeCount int             `json:"edge_count"`
+	// Connected is true when every ticket belongs to a single component.
+	Connected bool `json:"connected"`
+	// EulerianPath is true when a single path can use every ticket exactly once.
+	EulerianPath bool `json:"eulerian_path"`
+	// EulerianCircuit is true when that path can also end where it starts, which leaves no unique starting point.
+	EulerianCircuit bool `json:"eulerian_circuit"`
+}
+
+// Stats computes node and edge counts, degrees, connected components and Eulerian feasibility.
+func (g *Graph) Stats() GraphStats {
+	stats := GraphStats{
+		Components: [][]string{},
+		Degrees:    make([]AirportDegree, 0, len(g.Nodes)),
+		NodeCount:  len(g.Nodes),
+		EdgeCount:  len(g.Edges),
+	}
+
+	starts, ends, balanced := 0, 0, true
+	for _, node := range g.Nodes {
+		stats.Degrees = append(stats.Degrees, AirportDegree{
+			Airport:    node.Airport,
+			Departures: node.Departures,
+			Arrivals:   node.Arrivals,
+		})
+		switch node.Departures - node.Arrivals {
+		case 0:
+		case 1:
+			starts++
+		case -1:
+			ends++
+		default:
+			balanced = false
+		}
+	}
+
+	stats.Components = g.components()
+	stats.Connected = len(stats.Components) <= 1
+	if stats.Connected && balanced && len(g.Edges) > 0 {
+		stats.EulerianCircuit = starts == 0 && ends == 0
+		stats.EulerianPath = stats.EulerianCircuit || (starts == 1 && ends == 1)
+	}
+
+	return stats
+}
+
+func (g *Graph) components() [][]string {
+	adjacent := make(map[string][]string, len(g.Nodes))
+	for _, edge := range g.Edges {
+		adjacent[edge.From] = append(adjacent[edge.From], edge.To)
+		adjacent[edge.To] = append(adjacent[edge.To], edge.From)
+	}
+
+	visited := make(map[string]bool, len(g.Nodes))
+	components := [][]string{}
+	for _, node := range g.Nodes {
+		if visited[node.Airport] {
+			continue
+		}
+		visited[node.Airport] = true
+		component := []string{}
+		stack := []string{node.Airport}
+		for len(stack) > 0 {
+			airport := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			component = append(component, airport)
+			for _, next := range adjacent[airport] {
+				if !visited[next] {
+					visited[next] = true
+					stack = append(stack, next)
+				}
+			}
+		}
+		sort.Strings(component)
+		components = append(components, component)
+	}
+	sort.SliceStable(components, func(i, j int) bool {
+		return len(components[i]) > len(components[j])
+	})
+
+	return components
+}
@@ -0,0 +1,182 @@
+package dispatcher_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dsha256/dispatcher/internal/dispatcher"
+)
+
+func TestGraphDOT(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		want    string
+		tickets [][]string
+	}{
+		{
+			name:    "Valid itinerary",
+			tickets: [][]string{{"JFK", "LAX"}, {"LAX", "DXB"}},
+			want: `digraph itinerary {
+  rankdir=LR;
+  node [shape=box];
+  "JFK" [label="JFK\nout 1 / in 0", color=green];
+  "LAX" [label="LAX\nout 1 / in 1"];
+  "DXB" [label="DXB\nout 0 / in 1", color=blue];
+  "JFK" -> "LAX" [label="#0"];
+  "LAX" -> "DXB" [label="#1"];
+}
+`,
+		},
+		{
+			name:    "Duplicate and malformed tickets",
+			tickets: [][]string{{"JFK", "SFO"}, {"JFK"}, {"JFK", "SFO"}},
+			want: `digraph itinerary {
+  rankdir=LR;
+  node [shape=box];
+  "JFK" [label="JFK\nout 2 / in 0", color=red];
+  "SFO" [label="SFO\nout 0 / in 2", color=red];
+  "JFK" -> "SFO" [label="#0", color=red];
+  "JFK" -> "SFO" [label="#2", color=red];
+}
+`,
+		},
+		{
+			name:    "Disconnected tickets",
+			tickets: [][]string{{"JFK", "LAX"}, {"LAX", "DXB"}, {"SFO", "SJC"}},
+			want: `digraph itinerary {
+  rankdir=LR;
+  node [shape=box];
+  "JFK" [label="JFK\nout 1 / in 0", color=red];
+  "LAX" [label="LAX\nout 1 / in 1"];
+  "DXB" [label="DXB\nout 0 / in 1", color=red];
+  "SFO" [label="SFO\nout 1 / in 0", color=red];
+  "SJC" [label="SJC\nout 0 / in 1", color=red];
+  "JFK" -> "LAX" [label="#0"];
+  "LAX" -> "DXB" [label="#1"];
+  "SFO" -> "SJC" [label="#2", style=dashed];
+}
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			graph := dispatcher.NewGraph(tt.tickets, dispatcher.ValidateTickets(tt.tickets))
+			if got := graph.DOT(); got != tt.want {
+				t.Errorf("DOT() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGraphMermaid(t *testing.T) {
+	t.Parallel()
+
+	tickets := [][]string{{"JFK", "SFO"}, {"JFK", "SFO"}, {"SFO", "JFK"}}
+	graph := dispatcher.NewGraph(tickets, dispatcher.ValidateTickets(tickets))
+
+	want := `flowchart LR
+  n0["JFK<br/>out 2 / in 1"]
+  n1["SFO<br/>out 1 / in 2"]
+  n0 -->|"#0"| n1
+  linkStyle 0 stroke:red
+  n0 -->|"#1"| n1
+  linkStyle 1 stroke:red
+  n1 -->|"#2"| n0
+  style n0 stroke:green
+  style n1 stroke:blue
+`
+	if got := graph.Mermaid(); got != want {
+		t.Errorf("Mermaid() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestGraphStats(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name            string
+		tickets         [][]string
+		components      [][]string
+		nodes           int
+		edges           int
+		eulerianPath    bool
+		eulerianCircuit bool
+	}{
+		{
+			name:         "Valid itinerary",
+			tickets:      [][]string{{"LAX", "DXB"}, {"JFK", "LAX"}, {"DXB", "SFO"}},
+			components:   [][]string{{"DXB", "JFK", "LAX", "SFO"}},
+			nodes:        4,
+			edges:        3,
+			eulerianPath: true,
+		},
+		{
+			name:            "Round trip",
+			tickets:         [][]string{{"JFK", "LAX"}, {"LAX", "JFK"}},
+			components:      [][]string{{"JFK", "LAX"}},
+			nodes:           2,
+			edges:           2,
+			eulerianPath:    true,
+			eulerianCircuit: true,
+		},
+		{
+			name:       "Different starting points",
+			tickets:    [][]string{{"JFK", "SFO"}, {"LAX", "SFO"}},
+			components: [][]string{{"JFK", "LAX", "SFO"}},
+			nodes:      3,
+			edges:      2,
+		},
+		{
+			name:       "Disconnected",
+			tickets:    [][]string{{"SFO", "SJC"}, {"JFK", "LAX"}, {"LAX", "DXB"}},
+			components: [][]string{{"DXB", "JFK", "LAX"}, {"SFO", "SJC"}},
+			nodes:      5,
+			edges:      3,
+		},
+		{
+			name:       "Empty",
+			tickets:    [][]string{},
+			components: [][]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			stats := dispatcher.NewGraph(tt.tickets, nil).Stats()
+			if stats.NodeCount != tt.nodes || stats.EdgeCount != tt.edges {
+				t.Errorf("Stats() counts = %d nodes, %d edges; want %d, %d", stats.NodeCount, stats.EdgeCount, tt.nodes, tt.edges)
+			}
+			if !reflect.DeepEqual(stats.Components, tt.components) {
+				t.Errorf("Stats().Components = %v; want %v", stats.Components, tt.components)
+			}
+			if stats.EulerianPath != tt.eulerianPath || stats.EulerianCircuit != tt.eulerianCircuit {
+				t.Errorf("Stats() eulerian path/circuit = %v/%v; want %v/%v", stats.EulerianPath, stats.EulerianCircuit, tt.eulerianPath, tt.eulerianCircuit)
+			}
+			if len(stats.Degrees) != tt.nodes {
+				t.Errorf("Stats().Degrees = %v; want %d entries", stats.Degrees, tt.nodes)
+			}
+		})
+	}
+}
+
+func TestGraphBuilder(t *testing.T) {
+	t.Parallel()
+
+	tickets := [][]string{{"JFK", "SFO"}, {"JFK"}, {"JFK", "SFO"}}
+	builder := dispatcher.NewGraphBuilder()
+	for _, ticket := range tickets {
+		builder.Add(ticket)
+	}
+
+	want := dispatcher.NewGraph(tickets, dispatcher.ValidateTickets(tickets))
+	if got := builder.Build(dispatcher.ValidateTickets(tickets)); !reflect.DeepEqual(got, want) {
+		t.Errorf("Build() = %+v; want %+v", got, want)
+	}
+}
@@ -0,0 +1,50 @@
+package dispatcher
+
+import "sort"
+
+// HubWeights bias the choice between destinations towards preferred hub airports, e.g. {"DXB": 1} routes
+// through Dubai whenever several valid orderings allow it: an airport with several destinations takes the
+// heaviest first. Airports missing weigh 0, so negative weights avoid an airport; destinations of equal
+// weight are taken in the order of the tie-break policy.
+type HubWeights map[string]float64
+
+// ordering decides which destination the built-in reconstructors take first. The zero ordering takes the
+// lexicographically smallest one.
+type ordering struct {
+	hubs     HubWeights
+	tieBreak TieBreak
+}
+
+// ordering returns the ordering of the request, failing with ErrUnknownTieBreak when its policy is unknown.
+func (d *Dispatcher) ordering(req *Request) (ordering, error) {
+	tieBreak, err := d.TieBreak(req.TieBreak)
+	if err != nil {
+		return ordering{}, err
+	}
+
+	return ordering{hubs: req.PreferredHubs, tieBreak: tieBreak}, nil
+}
+
+// arrange orders the destination IDs of an airport, given in input order, for Hierholzer's traversal, which
+// takes them from the end: by the tie-break policy, then by weight, keeping the policy's order between
+// destinations of equal weight.
+func (o ordering) arrange(dests []int, codes []string) {
+	o.tieBreak.arrange(dests)
+	if len(o.hubs) > 0 {
+		sort.SliceStable(dests, func(i, j int) bool {
+			return o.hubs[codes[dests[i]]] < o.hubs[codes[dests[j]]]
+		})
+	}
+}
+
+// withOrdering returns the built-in reconstructor with the ordering. Other reconstructors are returned as
+// they are, as they order the tickets themselves.
+func withOrdering(reconstructor Reconstructor, order ordering) Reconstructor {
+	if r, ok := reconstructor.(lexicographic); ok {
+		r.order = order
+
+		return r
+	}
+
+	return reconstructor
+}
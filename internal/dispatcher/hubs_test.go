@@ -0,0 +1,73 @@
+package dispatcher_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/dsha256/dispatcher/internal/dispatcher"
+)
+
+func TestDispatcherPreferredHubs(t *testing.T) {
+	t.Parallel()
+
+	tickets := []dispatcher.Ticket{
+		{From: "JFK", To: "ATL"}, {From: "JFK", To: "DXB"}, {From: "JFK", To: "SFO"}, {From: "ATL", To: "JFK"}, {From: "DXB", To: "JFK"},
+	}
+
+	tests := []struct {
+		hubs     dispatcher.HubWeights
+		name     string
+		tieBreak dispatcher.TieBreak
+		expected []string
+	}{
+		{
+			name:     "No preference",
+			expected: []string{"JFK", "ATL", "JFK", "DXB", "JFK", "SFO"},
+		},
+		{
+			name:     "Preferred hub",
+			hubs:     dispatcher.HubWeights{"DXB": 1},
+			expected: []string{"JFK", "DXB", "JFK", "ATL", "JFK", "SFO"},
+		},
+		{
+			name:     "Avoided hub",
+			hubs:     dispatcher.HubWeights{"ATL": -1},
+			expected: []string{"JFK", "DXB", "JFK", "ATL", "JFK", "SFO"},
+		},
+		{
+			name:     "Heaviest first",
+			hubs:     dispatcher.HubWeights{"ATL": 1, "DXB": 2},
+			expected: []string{"JFK", "DXB", "JFK", "ATL", "JFK", "SFO"},
+		},
+		{
+			name:     "Preference without a valid ordering",
+			hubs:     dispatcher.HubWeights{"SFO": 5},
+			expected: []string{"JFK", "ATL", "JFK", "DXB", "JFK", "SFO"},
+		},
+		{
+			name:     "Equal weights by tie-break",
+			hubs:     dispatcher.HubWeights{"ATL": 1, "DXB": 1},
+			tieBreak: dispatcher.TieBreakLargestFirst,
+			expected: []string{"JFK", "DXB", "JFK", "ATL", "JFK", "SFO"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := dispatcher.New().Reconstruct(context.Background(), &dispatcher.Request{
+				Tickets:       tickets,
+				TieBreak:      tt.tieBreak,
+				PreferredHubs: tt.hubs,
+			})
+			if err != nil {
+				t.Fatalf("Reconstruct() error = %v", err)
+			}
+			if !reflect.DeepEqual(result.Path, tt.expected) {
+				t.Errorf("Reconstruct() path = %v, want %v", result.Path, tt.expected)
+			}
+		})
+	}
+}
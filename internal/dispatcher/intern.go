@@ -0,0 +1,62 @@
+package dispatcher
+
+import "sort"
+
+// internedTickets are well-formed tickets with their airport codes replaced by dense integer IDs,
+// so validation and traversal index slices instead of hashing strings on every step.
+type internedTickets struct {
+	// codes maps IDs back to airport codes. IDs are assigned in lexicographic order of the codes,
+	// so comparing two IDs compares their codes.
+	codes []string
+	// edges are the [source, destination] IDs of the tickets: edges[k] is tickets[indexes[k]].
+	edges   [][2]int
+	indexes []int
+	// seen, order and rank are scratch buffers of intern.
+	seen  []string
+	order []int
+	rank  []int
+}
+
+// intern interns the airports of the tickets at the indexes, which must all be well-formed, into t,
+// reusing its buffers. ids must be empty.
+func (t *internedTickets) intern(ids map[string]int, tickets [][]string, indexes []int) {
+	t.indexes = indexes
+	t.edges = resize(t.edges, len(indexes))
+	t.seen = t.seen[:0]
+	for k, i := range indexes {
+		for end, code := range tickets[i] {
+			id, ok := ids[code]
+			if !ok {
+				id = len(t.seen)
+				ids[code] = id
+				t.seen = append(t.seen, code)
+			}
+			t.edges[k][end] = id
+		}
+	}
+
+	// Renumber the airports in lexicographic order of their codes.
+	airports := len(t.seen)
+	t.order = resize(t.order, airports)
+	for id := range t.order {
+		t.order[id] = id
+	}
+	sort.Slice(t.order, func(a, b int) bool {
+		return t.seen[t.order[a]] < t.seen[t.order[b]]
+	})
+
+	t.rank = resize(t.rank, airports)
+	t.codes = resize(t.codes, airports)
+	for id, previous := range t.order {
+		t.rank[previous] = id
+		t.codes[id] = t.seen[previous]
+	}
+	for k, edge := range t.edges {
+		t.edges[k] = [2]int{t.rank[edge[0]], t.rank[edge[1]]}
+	}
+}
+
+// airports returns the number of distinct airports.
+func (t *internedTickets) airports() int {
+	return len(t.codes)
+}
@@ -0,0 +1,148 @@
+package dispatcher
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var ErrConflictingAlias = errors.New("conflicting alias")
+
+// Reasons of a CodeChange.
+const (
+	// ChangeFormat is a code uppercased or stripped of surrounding whitespace.
+	ChangeFormat = "format"
+	// ChangeAlias is a code replaced by the code it is an alias of.
+	ChangeAlias = "alias"
+)
+
+// Aliases map a code to the codes it replaces before the graph is built, e.g. {"NYC": ["JFK", "LGA", "EWR"]}
+// so that flying into one New York airport and out of another connects, or {"DXB": ["DBX"]} for a legacy code.
+// Aliases are applied once: the code an alias maps to is not looked up again.
+type Aliases map[string][]string
+
+// CodeChange is an airport code of a ticket rewritten by normalization.
+type CodeChange struct {
+	// Field is "from" or "to".
+	Field      string `json:"field"`
+	Original   string `json:"original"`
+	Normalized string `json:"normalized"`
+	// Reason is ChangeAlias when the code was replaced by an alias, ChangeFormat when it was only reformatted.
+	Reason      string `json:"reason"`
+	TicketIndex int    `json:"ticket_index"`
+}
+
+// WithCodeNormalization sets whether requests uppercase and trim airport codes by default, and the aliases
+// applied to every request, before the request's own.
+func WithCodeNormalization(normalize bool, aliases Aliases) Option {
+	return func(dispatcher *Dispatcher) {
+		dispatcher.normalizeCodes = normalize
+		dispatcher.aliases = aliases
+	}
+}
+
+// CheckAliases fails with ErrConflictingAlias when a code is listed under several codes.
+func CheckAliases(aliases Aliases) error {
+	_, err := aliasLookup(aliases, false)
+
+	return err
+}
+
+// normalizeTickets rewrites the airport codes of the request tickets: uppercased and trimmed when the request
+// normalizes codes, then replaced by the code they are an alias of. It returns the tickets unchanged when
+// there is nothing to do, and otherwise a copy along with every change made.
+func (d *Dispatcher) normalizeTickets(req *Request) ([]Ticket, []CodeChange, error) {
+	format := d.normalizeCodes
+	if req.NormalizeCodes != nil {
+		format = *req.NormalizeCodes
+	}
+	if !format && len(d.aliases) == 0 && len(req.Aliases) == 0 {
+		return req.Tickets, nil, nil
+	}
+
+	aliases := make(Aliases, len(d.aliases)+len(req.Aliases))
+	for code, replaced := range d.aliases {
+		aliases[code] = replaced
+	}
+	for code, replaced := range req.Aliases {
+		aliases[code] = replaced
+	}
+	lookup, err := aliasLookup(aliases, format)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var changes []CodeChange
+	normalize := func(index int, field, code string) string {
+		normalized := code
+		if format {
+			normalized = formatCode(code)
+		}
+		reason := ChangeFormat
+		if alias, ok := lookup[normalized]; ok {
+			normalized, reason = alias, ChangeAlias
+		}
+		if normalized != code {
+			changes = append(changes, CodeChange{
+				TicketIndex: index,
+				Field:       field,
+				Original:    code,
+				Normalized:  normalized,
+				Reason:      reason,
+			})
+		}
+
+		return normalized
+	}
+
+	tickets := make([]Ticket, len(req.Tickets))
+	for i, ticket := range req.Tickets {
+		// Malformed pairs are left for validation to report as they are.
+		if ticket.pair != nil && len(ticket.pair) != 2 {
+			tickets[i] = ticket
+
+			continue
+		}
+		ticket.From = normalize(i, "from", ticket.From)
+		ticket.To = normalize(i, "to", ticket.To)
+		ticket.pair = nil
+		tickets[i] = ticket
+	}
+
+	return tickets, changes, nil
+}
+
+// aliasLookup inverts the aliases into a map from every replaced code to the code replacing it, formatting
+// the codes with format. It fails with ErrConflictingAlias when a code is listed under several codes.
+func aliasLookup(aliases Aliases, format bool) (map[string]string, error) {
+	codes := make([]string, 0, len(aliases))
+	for code := range aliases {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	lookup := make(map[string]string)
+	for _, code := range codes {
+		target := code
+		if format {
+			target = formatCode(code)
+		}
+		for _, replaced := range aliases[code] {
+			if format {
+				replaced = formatCode(replaced)
+			}
+			if previous, ok := lookup[replaced]; ok && previous != target {
+				return nil, fmt.Errorf("%w: %s is an alias of both %s and %s", ErrConflictingAlias, replaced, previous, target)
+			}
+			lookup[replaced] = target
+		}
+	}
+
+	return lookup, nil
+}
+
+// formatCode uppercases the code and trims its surrounding whitespace.
+func formatCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
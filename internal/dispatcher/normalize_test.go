@@ -0,0 +1,153 @@
+package dispatcher_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/dsha256/dispatcher/internal/dispatcher"
+)
+
+func TestDispatcherCodeNormalization(t *testing.T) {
+	t.Parallel()
+
+	normalize, keep := true, false
+	metro := dispatcher.Aliases{"NYC": {"JFK", "LGA", "EWR"}}
+
+	tests := []struct {
+		err       error
+		normalize *bool
+		aliases   dispatcher.Aliases
+		name      string
+		version   string
+		tickets   [][]string
+		opts      []dispatcher.Option
+		expected  []string
+		changes   []dispatcher.CodeChange
+	}{
+		{
+			name:     "Disabled",
+			tickets:  [][]string{{"LAX", "DXB"}, {"JFK", "LAX"}},
+			expected: []string{"JFK", "LAX", "DXB"},
+		},
+		{
+			name:      "Case and whitespace",
+			normalize: &normalize,
+			tickets:   [][]string{{"lax", "DXB "}, {"JFK", "LAX"}},
+			expected:  []string{"JFK", "LAX", "DXB"},
+			changes: []dispatcher.CodeChange{
+				{TicketIndex: 0, Field: "from", Original: "lax", Normalized: "LAX", Reason: dispatcher.ChangeFormat},
+				{TicketIndex: 0, Field: "to", Original: "DXB ", Normalized: "DXB", Reason: dispatcher.ChangeFormat},
+			},
+		},
+		{
+			name:     "Metro alias",
+			aliases:  metro,
+			tickets:  [][]string{{"JFK", "LHR"}, {"SFO", "EWR"}},
+			expected: []string{"SFO", "NYC", "LHR"},
+			changes: []dispatcher.CodeChange{
+				{TicketIndex: 0, Field: "from", Original: "JFK", Normalized: "NYC", Reason: dispatcher.ChangeAlias},
+				{TicketIndex: 1, Field: "to", Original: "EWR", Normalized: "NYC", Reason: dispatcher.ChangeAlias},
+			},
+		},
+		{
+			name:      "Alias after formatting",
+			normalize: &normalize,
+			aliases:   dispatcher.Aliases{"dxb": {"dbx"}},
+			tickets:   [][]string{{"JFK", " dbx"}},
+			expected:  []string{"JFK", "DXB"},
+			changes: []dispatcher.CodeChange{
+				{TicketIndex: 0, Field: "to", Original: " dbx", Normalized: "DXB", Reason: dispatcher.ChangeAlias},
+			},
+		},
+		{
+			name:     "Configured",
+			opts:     []dispatcher.Option{dispatcher.WithCodeNormalization(true, metro)},
+			tickets:  [][]string{{"jfk", "LAX"}},
+			expected: []string{"NYC", "LAX"},
+			changes: []dispatcher.CodeChange{
+				{TicketIndex: 0, Field: "from", Original: "jfk", Normalized: "NYC", Reason: dispatcher.ChangeAlias},
+			},
+		},
+		{
+			name:     "Configured ignored by version 1",
+			opts:     []dispatcher.Option{dispatcher.WithCodeNormalization(true, metro)},
+			version:  "1",
+			tickets:  [][]string{{"jfk", "LAX"}},
+			expected: []string{"jfk", "LAX"},
+		},
+		{
+			name:      "Request opting in with version 1",
+			opts:      []dispatcher.Option{dispatcher.WithCodeNormalization(true, metro)},
+			normalize: &normalize,
+			version:   "1",
+			tickets:   [][]string{{"jfk", "LAX"}},
+			expected:  []string{"JFK", "LAX"},
+			changes: []dispatcher.CodeChange{
+				{TicketIndex: 0, Field: "from", Original: "jfk", Normalized: "JFK", Reason: dispatcher.ChangeFormat},
+			},
+		},
+		{
+			name:      "Request opting out",
+			opts:      []dispatcher.Option{dispatcher.WithCodeNormalization(true, nil)},
+			normalize: &keep,
+			tickets:   [][]string{{"jfk", "LAX"}},
+			expected:  []string{"jfk", "LAX"},
+		},
+		{
+			name:    "Conflicting aliases",
+			opts:    []dispatcher.Option{dispatcher.WithCodeNormalization(false, metro)},
+			aliases: dispatcher.Aliases{"QNY": {"JFK"}},
+			tickets: [][]string{{"JFK", "LAX"}},
+			err:     dispatcher.ErrConflictingAlias,
+		},
+		{
+			name:      "Malformed ticket left to validation",
+			normalize: &normalize,
+			tickets:   [][]string{{"jfk", "LAX", "DXB"}},
+			err:       dispatcher.ErrMalformedTicket,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			tickets := make([]dispatcher.Ticket, 0, len(tt.tickets))
+			for _, pair := range tt.tickets {
+				tickets = append(tickets, dispatcher.TicketFromPair(pair))
+			}
+			result, err := dispatcher.New(tt.opts...).Reconstruct(context.Background(), &dispatcher.Request{
+				Tickets:          tickets,
+				NormalizeCodes:   tt.normalize,
+				Aliases:          tt.aliases,
+				AlgorithmVersion: tt.version,
+			})
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Reconstruct() error = %v, want %v", err, tt.err)
+			}
+			if tt.err != nil {
+				return
+			}
+			if !reflect.DeepEqual(result.Path, tt.expected) {
+				t.Errorf("Reconstruct() path = %v, want %v", result.Path, tt.expected)
+			}
+			if !reflect.DeepEqual(result.Normalization, tt.changes) {
+				t.Errorf("Reconstruct() normalization = %+v, want %+v", result.Normalization, tt.changes)
+			}
+		})
+	}
+}
+
+func TestCheckAliases(t *testing.T) {
+	t.Parallel()
+
+	if err := dispatcher.CheckAliases(dispatcher.Aliases{"NYC": {"JFK", "LGA"}, "WAS": {"IAD", "DCA"}}); err != nil {
+		t.Errorf("CheckAliases() error = %v", err)
+	}
+	err := dispatcher.CheckAliases(dispatcher.Aliases{"NYC": {"JFK"}, "QNY": {"JFK"}})
+	if !errors.Is(err, dispatcher.ErrConflictingAlias) || err.Error() != "conflicting alias: JFK is an alias of both NYC and QNY" {
+		t.Errorf("CheckAliases() error = %v, want %v", err, dispatcher.ErrConflictingAlias)
+	}
+}
@@ -0,0 +1,89 @@
+package dispatcher
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrInvalidSurfaceTransfer = errors.New("invalid surface transfer")
+
+// SurfaceTransfer is a gap an open-jaw trip is allowed to have: the traveller arrives at From and continues
+// from To by other means, e.g. {From: "LAX", To: "SFO"} for a trip flying into Los Angeles and out of
+// San Francisco. The reconstruction stitches the segments on both sides of the gap with a leg marked as a
+// surface transfer instead of failing with ErrDifferentStartingPoints.
+type SurfaceTransfer struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// checkSurfaceTransfers fails with ErrInvalidSurfaceTransfer when a transfer misses a code or goes nowhere.
+func checkSurfaceTransfers(transfers []SurfaceTransfer) error {
+	for i, transfer := range transfers {
+		if transfer.From == "" || transfer.To == "" {
+			return fmt.Errorf("%w: transfer %d needs both airports", ErrInvalidSurfaceTransfer, i)
+		}
+		if transfer.From == transfer.To {
+			return fmt.Errorf("%w: transfer %d goes from %s to itself", ErrInvalidSurfaceTransfer, i, transfer.From)
+		}
+	}
+
+	return nil
+}
+
+// stitchSurfaceTransfers appends a ticket for every permitted transfer the tickets need to form a single
+// itinerary, and returns the tickets as they are when they need none. A transfer is needed while the tickets
+// have several starting points and it goes from an airport with more arrivals than departures to one with
+// more departures than arrivals; transfers are considered in the order they are given. Tickets that already
+// form an itinerary, e.g. a round trip flying into LAX and back from SFO, are left alone: without departure
+// times nothing tells the gap apart from a valid ordering.
+func stitchSurfaceTransfers(tickets []Ticket, permitted []SurfaceTransfer) []Ticket {
+	if len(permitted) == 0 {
+		return tickets
+	}
+
+	balance := make(map[string]int)
+	for i := range tickets {
+		balance[tickets[i].From]++
+		balance[tickets[i].To]--
+	}
+	starts := 0
+	for _, b := range balance {
+		if b > 0 {
+			starts += b
+		}
+	}
+
+	stitched := tickets
+	for _, transfer := range permitted {
+		if starts <= 1 {
+			break
+		}
+		if balance[transfer.From] < 0 && balance[transfer.To] > 0 {
+			balance[transfer.From]++
+			balance[transfer.To]--
+			starts--
+			if len(stitched) == len(tickets) {
+				stitched = append(make([]Ticket, 0, len(tickets)+len(permitted)), tickets...)
+			}
+			stitched = append(stitched, Ticket{From: transfer.From, To: transfer.To})
+		}
+	}
+
+	return stitched
+}
+
+// markSurfaceTransfers marks the legs made with the tickets appended by stitchSurfaceTransfers, which come
+// after the first n, as surface transfers without a ticket.
+func markSurfaceTransfers(legs []Leg, n int) {
+	for i := range legs {
+		if legs[i].TicketIndex >= n {
+			legs[i].TicketIndex = -1
+			legs[i].SurfaceTransfer = true
+		}
+	}
+}
+
+// surfaceTransferLeg is the leg stitching two consecutive tickets across a permitted gap.
+func surfaceTransferLeg(prev, next *Ticket) Leg {
+	return Leg{From: prev.To, To: next.From, TicketIndex: -1, SurfaceTransfer: true}
+}
@@ -0,0 +1,147 @@
+package dispatcher_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/dispatcher"
+)
+
+func TestDispatcherSurfaceTransfers(t *testing.T) {
+	t.Parallel()
+
+	day := func(d int) *time.Time {
+		at := time.Date(2026, 5, d, 9, 0, 0, 0, time.UTC)
+
+		return &at
+	}
+	price := 120.0
+	laxToSFO := []dispatcher.SurfaceTransfer{{From: "LAX", To: "SFO"}}
+
+	tests := []struct {
+		err       error
+		name      string
+		strategy  dispatcher.Strategy
+		tickets   []dispatcher.Ticket
+		transfers []dispatcher.SurfaceTransfer
+		expected  []string
+		legs      []int
+	}{
+		{
+			name:      "Open jaw",
+			tickets:   []dispatcher.Ticket{{From: "SFO", To: "BOS"}, {From: "JFK", To: "LAX"}},
+			transfers: laxToSFO,
+			expected:  []string{"JFK", "LAX", "SFO", "BOS"},
+			legs:      []int{1, -1, 0},
+		},
+		{
+			name:    "Gap not declared",
+			tickets: []dispatcher.Ticket{{From: "SFO", To: "BOS"}, {From: "JFK", To: "LAX"}},
+			err:     dispatcher.ErrDifferentStartingPoints,
+		},
+		{
+			name:      "Other gap declared",
+			tickets:   []dispatcher.Ticket{{From: "SFO", To: "BOS"}, {From: "JFK", To: "LAX"}},
+			transfers: []dispatcher.SurfaceTransfer{{From: "SFO", To: "LAX"}},
+			err:       dispatcher.ErrDifferentStartingPoints,
+		},
+		{
+			name:    "Several gaps",
+			tickets: []dispatcher.Ticket{{From: "JFK", To: "LAX"}, {From: "SFO", To: "ORD"}, {From: "MDW", To: "BOS"}},
+			transfers: []dispatcher.SurfaceTransfer{
+				{From: "ORD", To: "MDW"}, {From: "LAX", To: "SFO"},
+			},
+			expected: []string{"JFK", "LAX", "SFO", "ORD", "MDW", "BOS"},
+			legs:     []int{0, -1, 1, -1, 2},
+		},
+		{
+			name:      "Gap not needed",
+			tickets:   []dispatcher.Ticket{{From: "JFK", To: "LAX"}, {From: "SFO", To: "JFK"}},
+			transfers: laxToSFO,
+			expected:  []string{"SFO", "JFK", "LAX"},
+			legs:      []int{1, 0},
+		},
+		{
+			name:      "Registered strategy",
+			strategy:  wrapped,
+			tickets:   []dispatcher.Ticket{{From: "JFK", To: "LAX", Price: &price}, {From: "SFO", To: "BOS", Price: &price}},
+			transfers: laxToSFO,
+			expected:  []string{"JFK", "LAX", "SFO", "BOS"},
+			legs:      []int{0, -1, 1},
+		},
+		{
+			name:      "Timed",
+			tickets:   []dispatcher.Ticket{{From: "SFO", To: "BOS", DepartsAt: day(9)}, {From: "JFK", To: "LAX", DepartsAt: day(2)}},
+			transfers: laxToSFO,
+			expected:  []string{"JFK", "LAX", "SFO", "BOS"},
+			legs:      []int{1, -1, 0},
+		},
+		{
+			name:      "Timed gap in the wrong order",
+			tickets:   []dispatcher.Ticket{{From: "SFO", To: "BOS", DepartsAt: day(2)}, {From: "JFK", To: "LAX", DepartsAt: day(9)}},
+			transfers: laxToSFO,
+			err:       dispatcher.ErrDifferentStartingPoints,
+		},
+		{
+			name:      "Transfer without a destination",
+			tickets:   []dispatcher.Ticket{{From: "JFK", To: "LAX"}},
+			transfers: []dispatcher.SurfaceTransfer{{From: "LAX"}},
+			err:       dispatcher.ErrInvalidSurfaceTransfer,
+		},
+		{
+			name:      "Transfer going nowhere",
+			tickets:   []dispatcher.Ticket{{From: "JFK", To: "LAX"}},
+			transfers: []dispatcher.SurfaceTransfer{{From: "LAX", To: "LAX"}},
+			err:       dispatcher.ErrInvalidSurfaceTransfer,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := dispatcher.New(withWrapped()).Reconstruct(context.Background(), &dispatcher.Request{
+				Tickets:          tt.tickets,
+				Strategy:         tt.strategy,
+				SurfaceTransfers: tt.transfers,
+			})
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Reconstruct() error = %v, want %v", err, tt.err)
+			}
+			if tt.err != nil {
+				return
+			}
+			if !reflect.DeepEqual(result.Path, tt.expected) {
+				t.Errorf("Reconstruct() path = %v, want %v", result.Path, tt.expected)
+			}
+			legs := make([]int, 0, len(result.Legs))
+			for i, leg := range result.Legs {
+				legs = append(legs, leg.TicketIndex)
+				if leg.SurfaceTransfer != (leg.TicketIndex == -1) {
+					t.Errorf("Leg %d %+v: surface transfer and ticket index disagree", i, leg)
+				}
+				if leg.From != result.Path[i] || leg.To != result.Path[i+1] {
+					t.Errorf("Leg %d goes %s -> %s, want %s -> %s", i, leg.From, leg.To, result.Path[i], result.Path[i+1])
+				}
+			}
+			if !reflect.DeepEqual(legs, tt.legs) {
+				t.Errorf("Reconstruct() legs = %v, want %v", legs, tt.legs)
+			}
+		})
+	}
+}
+
+func TestStreamItinerarySurfaceTransfers(t *testing.T) {
+	t.Parallel()
+
+	err := dispatcher.New().StreamItinerary(context.Background(), &dispatcher.Request{
+		Tickets:          []dispatcher.Ticket{{From: "JFK", To: "LAX"}, {From: "SFO", To: "BOS"}},
+		SurfaceTransfers: []dispatcher.SurfaceTransfer{{From: "LAX", To: "SFO"}},
+	}, func(int, string) error { return nil })
+	if !errors.Is(err, dispatcher.ErrStreamingUnsupported) {
+		t.Errorf("StreamItinerary() error = %v, want %v", err, dispatcher.ErrStreamingUnsupported)
+	}
+}
@@ -0,0 +1,63 @@
+package dispatcher
+
+import "sync"
+
+// workspacePool recycles workspaces between reconstructions.
+var workspacePool = sync.Pool{ //nolint:gochecknoglobals // Shared by every reconstruction of the process.
+	New: func() any {
+		return &workspace{}
+	},
+}
+
+// workspace holds the scratch buffers of validating and reconstructing one ticket set: the interning
+// and duplicate maps, the interned tickets and the graph. Workspaces are pooled and their buffers
+// resized rather than reallocated, so sustained traffic doesn't churn the garbage collector.
+// Nothing returned to callers may point into a workspace, since it is reused once released.
+type workspace struct {
+	ids      map[string]int
+	counts   map[[2]int]int
+	interned internedTickets
+	graph    ticketGraph
+	// tickets is the number of tickets the maps were last prepared for.
+	tickets int
+}
+
+// newWorkspace returns a workspace prepared for the number of tickets. Release it once done.
+func newWorkspace(tickets int) *workspace {
+	ws, ok := workspacePool.Get().(*workspace)
+	if !ok {
+		ws = &workspace{}
+	}
+
+	// Clearing a map costs as much as its capacity, so maps grown by a much larger ticket set
+	// are replaced rather than cleared.
+	if ws.ids == nil || ws.tickets > 4*tickets+workspaceSlack {
+		ws.ids = make(map[string]int, tickets+1)
+		ws.counts = make(map[[2]int]int, tickets)
+	} else {
+		clear(ws.ids)
+		clear(ws.counts)
+	}
+	ws.tickets = tickets
+
+	return ws
+}
+
+// workspaceSlack keeps small ticket sets from replacing the maps of slightly smaller ones.
+const workspaceSlack = 64
+
+// release returns the workspace to the pool.
+func (ws *workspace) release() {
+	workspacePool.Put(ws)
+}
+
+// resize returns s with length n and zeroed elements, reusing its backing array when it is large enough.
+func resize[T any](s []T, n int) []T {
+	if cap(s) < n {
+		return make([]T, n)
+	}
+	s = s[:n]
+	clear(s)
+
+	return s
+}
@@ -0,0 +1,132 @@
+package dispatcher
+
+import "fmt"
+
+// RepairAction is the kind of change a repair suggests.
+type RepairAction string
+
+const (
+	RepairAdd    RepairAction = "add"
+	RepairRemove RepairAction = "remove"
+)
+
+// Repair is a single suggested change to a ticket set.
+type Repair struct {
+	// TicketIndex is the index of the ticket to remove; nil for additions.
+	TicketIndex *int         `json:"ticket_index,omitempty"`
+	Action      RepairAction `json:"action"`
+	Reason      string       `json:"reason"`
+	Ticket      []string     `json:"ticket"`
+}
+
+// RepairPlan is a set of changes that makes a ticket set reconstructable.
+type RepairPlan struct {
+	Repairs []Repair `json:"repairs"`
+	// Complete reports whether applying the repairs yields a valid ticket set.
+	Complete bool `json:"complete"`
+}
+
+// SuggestRepairs computes a small set of tickets to add or remove so that the tickets form a single path.
+// Removals come first: malformed tickets, extra copies of duplicate tickets, and one ticket of every
+// closed loop (a component where every airport is balanced). The remaining components are then chained
+// with added tickets from airports with surplus arrivals to airports with surplus departures, which
+// also balances the degrees. The plan is minimal for the usual failures (a missing or duplicated ticket,
+// a round trip, split itineraries) but not in general; Complete reports whether the repaired set validates,
+// which fails in rare cases such as an added ticket duplicating an existing one.
+func SuggestRepairs(tickets [][]string) *RepairPlan {
+	plan := &RepairPlan{Repairs: []Repair{}}
+	remove := func(i int, reason string) {
+		index := i
+		plan.Repairs = append(plan.Repairs, Repair{TicketIndex: &index, Action: RepairRemove, Reason: reason, Ticket: tickets[i]})
+	}
+
+	kept := make([]int, 0, len(tickets))
+	seen := make(map[[2]string]bool, len(tickets))
+	for i, ticket := range tickets {
+		if len(ticket) != 2 || ticket[0] == "" || ticket[1] == "" {
+			remove(i, "malformed ticket")
+
+			continue
+		}
+		key := [2]string{ticket[0], ticket[1]}
+		if seen[key] {
+			remove(i, fmt.Sprintf("duplicate of ticket %s -> %s", ticket[0], ticket[1]))
+
+			continue
+		}
+		seen[key] = true
+		kept = append(kept, i)
+	}
+
+	keptTickets := ticketsAt(tickets, kept)
+	graph := NewGraph(keptTickets, nil)
+	var surplus, deficit []string
+	for _, component := range graph.Stats().Components {
+		members := toSet(component)
+		balanced := true
+		for _, node := range graph.Nodes {
+			if _, ok := members[node.Airport]; !ok {
+				continue
+			}
+			for n := node.Departures; n < node.Arrivals; n++ {
+				deficit = append(deficit, node.Airport)
+				balanced = false
+			}
+			for n := node.Arrivals; n < node.Departures; n++ {
+				surplus = append(surplus, node.Airport)
+				balanced = false
+			}
+		}
+		if !balanced {
+			continue
+		}
+
+		// A closed loop has no start; dropping its last ticket opens it without disconnecting it.
+		last := -1
+		for j, ticket := range keptTickets {
+			if _, ok := members[ticket[0]]; ok {
+				last = j
+			}
+		}
+		remove(kept[last], "closes a loop, so the itinerary has no unique starting point")
+		surplus = append(surplus, keptTickets[last][1])
+		deficit = append(deficit, keptTickets[last][0])
+	}
+
+	// Per component, surplus and deficit units are equal in number, so linking the i-th deficit to the
+	// (i+1)-th surplus chains every component into one path from surplus[0] to deficit[len-1].
+	for i := 0; i+1 < len(surplus); i++ {
+		plan.Repairs = append(plan.Repairs, Repair{
+			Action: RepairAdd,
+			Reason: fmt.Sprintf("connects %s to %s", deficit[i], surplus[i+1]),
+			Ticket: []string{deficit[i], surplus[i+1]},
+		})
+	}
+	plan.Complete = ValidateTickets(plan.Apply(tickets)).Valid
+
+	return plan
+}
+
+// Apply returns the tickets with the repairs applied: removed tickets are dropped and added tickets appended.
+func (p *RepairPlan) Apply(tickets [][]string) [][]string {
+	removed := make(map[int]bool, len(p.Repairs))
+	for _, repair := range p.Repairs {
+		if repair.Action == RepairRemove && repair.TicketIndex != nil {
+			removed[*repair.TicketIndex] = true
+		}
+	}
+
+	repaired := make([][]string, 0, len(tickets)+len(p.Repairs))
+	for i, ticket := range tickets {
+		if !removed[i] {
+			repaired = append(repaired, ticket)
+		}
+	}
+	for _, repair := range p.Repairs {
+		if repair.Action == RepairAdd {
+			repaired = append(repaired, repair.Ticket)
+		}
+	}
+
+	return repaired
+}
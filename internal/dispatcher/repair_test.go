@@ -0,0 +1,89 @@
+package dispatcher_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/dsha256/dispatcher/internal/dispatcher"
+)
+
+func TestSuggestRepairs(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		tickets [][]string
+		want    []string
+	}{
+		{
+			name:    "Valid itinerary",
+			tickets: [][]string{{"JFK", "LAX"}, {"LAX", "DXB"}},
+			want:    []string{},
+		},
+		{
+			name:    "Duplicate ticket",
+			tickets: [][]string{{"JFK", "SFO"}, {"SFO", "LAX"}, {"JFK", "SFO"}},
+			want:    []string{"remove #2 JFK->SFO"},
+		},
+		{
+			name:    "Malformed ticket",
+			tickets: [][]string{{"JFK"}, {"JFK", "SFO"}},
+			want:    []string{"remove #0 JFK"},
+		},
+		{
+			name:    "Missing ticket",
+			tickets: [][]string{{"JFK", "LAX"}, {"DXB", "SFO"}},
+			want:    []string{"add LAX->DXB"},
+		},
+		{
+			name:    "Round trip",
+			tickets: [][]string{{"JFK", "LAX"}, {"LAX", "JFK"}},
+			want:    []string{"remove #1 LAX->JFK"},
+		},
+		{
+			name:    "Different starting points",
+			tickets: [][]string{{"JFK", "SFO"}, {"LAX", "SFO"}},
+			want:    []string{"add SFO->LAX"},
+		},
+		{
+			name:    "Loop and a separate trip",
+			tickets: [][]string{{"JFK", "LAX"}, {"LAX", "DXB"}, {"SFO", "SJC"}, {"SJC", "SFO"}},
+			want:    []string{"remove #3 SJC->SFO", "add DXB->SFO"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			plan := dispatcher.SuggestRepairs(tt.tickets)
+			got := []string{}
+			for _, repair := range plan.Repairs {
+				switch repair.Action {
+				case dispatcher.RepairRemove:
+					got = append(got, fmt.Sprintf("remove #%d %s", *repair.TicketIndex, joinTicket(repair.Ticket)))
+				case dispatcher.RepairAdd:
+					got = append(got, fmt.Sprintf("add %s", joinTicket(repair.Ticket)))
+				}
+			}
+
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("SuggestRepairs() = %v; want %v", got, tt.want)
+			}
+			if !plan.Complete {
+				t.Errorf("SuggestRepairs() plan is incomplete")
+			}
+			if _, err := dispatcher.ReconstructItinerary(plan.Apply(tt.tickets)); err != nil {
+				t.Errorf("repaired tickets do not reconstruct: %v", err)
+			}
+		})
+	}
+}
+
+func joinTicket(ticket []string) string {
+	if len(ticket) != 2 {
+		return fmt.Sprint(ticket[0])
+	}
+
+	return ticket[0] + "->" + ticket[1]
+}
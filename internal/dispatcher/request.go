@@ -0,0 +1,117 @@
+package dispatcher
+
+import "context"
+
+// Request is a reconstruction request for tickets carrying metadata.
+type Request struct {
+	// Constraints are optional rules the itinerary must satisfy.
+	Constraints *Constraints
+	// StrictAirports overrides the dispatcher default for rejecting tickets with unknown airport codes.
+	StrictAirports *bool
+	// AllowDuplicates overrides the dispatcher default for accepting repeated identical tickets.
+	AllowDuplicates *bool
+	// Strategy picks between several valid orderings, StrategyDefault when empty.
+	Strategy Strategy
+	// TieBreak overrides the dispatcher default policy deciding which destination is taken first.
+	TieBreak TieBreak
+	// PreferredHubs bias the choice between destinations towards airports with a higher weight.
+	PreferredHubs HubWeights
+	// NormalizeCodes overrides the dispatcher default for uppercasing and trimming airport codes.
+	NormalizeCodes *bool
+	// Aliases are applied to the airport codes on top of the dispatcher's (see Aliases).
+	Aliases Aliases
+	// SurfaceTransfers are the gaps of an open-jaw trip the itinerary may have (see SurfaceTransfer).
+	SurfaceTransfers []SurfaceTransfer
+	// AlgorithmVersion pins the algorithm, AlgorithmVersion when empty.
+	AlgorithmVersion string
+	Tickets          []Ticket
+}
+
+// Result is the reconstructed itinerary.
+// Normalization lists the airport codes rewritten before reconstruction; the path and legs use the rewritten codes.
+type Result struct {
+	AlgorithmVersion string
+	Path             []string
+	Legs             []Leg
+	Normalization    []CodeChange
+}
+
+// Reconstruct reconstructs the itinerary of the request with the pinned algorithm version
+// and checks it against the request constraints. Airport codes are normalized first (see Aliases).
+func (d *Dispatcher) Reconstruct(ctx context.Context, req *Request) (*Result, error) {
+	if err := d.checkTicketCount(len(req.Tickets)); err != nil {
+		return nil, err
+	}
+
+	version := req.AlgorithmVersion
+	if version == "" {
+		version = AlgorithmVersion
+	}
+	d, err := d.atVersion(version)
+	if err != nil {
+		return nil, err
+	}
+
+	tickets, changes, err := d.normalizeTickets(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.strictAirports(req.StrictAirports) {
+		report := newValidationReport()
+		report.CheckAirports(Pairs(tickets), d.knownAirport)
+		if !report.Valid {
+			return nil, &ValidationError{Err: report.Err(), Report: report}
+		}
+	}
+
+	if err = checkSurfaceTransfers(req.SurfaceTransfers); err != nil {
+		return nil, err
+	}
+
+	if err = req.Constraints.CheckTickets(tickets); err != nil {
+		return nil, err
+	}
+
+	order, err := d.ordering(req)
+	if err != nil {
+		return nil, err
+	}
+
+	path, legs, err := d.reconstructV1(ctx, tickets, req.Strategy, order, req.SurfaceTransfers, d.duplicatesAllowed(req.AllowDuplicates))
+	if err != nil {
+		return nil, err
+	}
+
+	if err = req.Constraints.CheckPath(path); err != nil {
+		return nil, err
+	}
+
+	return &Result{
+		AlgorithmVersion: version,
+		Path:             path,
+		Legs:             legs,
+		Normalization:    changes,
+	}, nil
+}
+
+// strictAirports reports whether airport codes are validated, given the request override.
+func (d *Dispatcher) strictAirports(override *bool) bool {
+	if d.knownAirport == nil {
+		return false
+	}
+	if override != nil {
+		return *override
+	}
+
+	return d.strictByDefault
+}
+
+// duplicatesAllowed reports whether repeated identical tickets are accepted, given the request override.
+func (d *Dispatcher) duplicatesAllowed(override *bool) bool {
+	if override != nil {
+		return *override
+	}
+
+	return d.allowDuplicates
+}
@@ -0,0 +1,132 @@
+package dispatcher
+
+import (
+	"context"
+	"errors"
+	"sort"
+)
+
+var ErrUnknownStrategy = errors.New("unknown strategy")
+
+// Strategy selects how the itinerary is chosen when several valid orderings exist.
+type Strategy string
+
+const (
+	// StrategyDefault picks the lexicographically smallest itinerary.
+	StrategyDefault Strategy = "default"
+	// StrategyChronological orders timed tickets by departure (see ReconstructChronological). Requests whose
+	// tickets all have a departure time run it whatever their strategy, since the chronology fully determines
+	// the order.
+	StrategyChronological Strategy = "chronological"
+)
+
+// Reconstructor is the algorithm behind a Strategy. It receives tickets that have already passed
+// the request checks (ticket limit, airport codes, ticket constraints) and returns the path with the
+// legs mapping every step back to a ticket; the path is then checked against the path constraints.
+// With allowDuplicates, repeated identical tickets are distinct tickets of the itinerary.
+type Reconstructor interface {
+	Reconstruct(ctx context.Context, tickets []Ticket, allowDuplicates bool) ([]string, []Leg, error)
+}
+
+// ReconstructorFunc adapts a function to a Reconstructor.
+type ReconstructorFunc func(ctx context.Context, tickets []Ticket, allowDuplicates bool) ([]string, []Leg, error)
+
+func (f ReconstructorFunc) Reconstruct(ctx context.Context, tickets []Ticket, allowDuplicates bool) ([]string, []Leg, error) {
+	return f(ctx, tickets, allowDuplicates)
+}
+
+// Lexicographic returns the Reconstructor of StrategyDefault, picking the lexicographically smallest
+// itinerary (see ReconstructLegs), or the first one in the order of the request's tie-break policy and
+// preferred hubs. It is the only one whose path can be streamed.
+func Lexicographic() Reconstructor {
+	return lexicographic{}
+}
+
+type lexicographic struct {
+	order ordering
+}
+
+func (l lexicographic) Reconstruct(ctx context.Context, tickets []Ticket, allowDuplicates bool) ([]string, []Leg, error) {
+	return reconstructLegs(ctx, Pairs(tickets), allowDuplicates, l.order)
+}
+
+// builtinStrategies returns the strategies every dispatcher starts with.
+func builtinStrategies() map[Strategy]Reconstructor {
+	return map[Strategy]Reconstructor{
+		StrategyDefault:       Lexicographic(),
+		StrategyChronological: Chronological(),
+	}
+}
+
+// WithStrategy registers the reconstructor of a strategy, replacing the built-in one of the same name,
+// so requests can select it by name.
+func WithStrategy(strategy Strategy, reconstructor Reconstructor) Option {
+	return func(dispatcher *Dispatcher) {
+		dispatcher.strategies[strategy] = reconstructor
+	}
+}
+
+// WithDefaultStrategy sets the strategy of requests not naming one, StrategyDefault unless set.
+// An empty strategy keeps the current default.
+func WithDefaultStrategy(strategy Strategy) Option {
+	return func(dispatcher *Dispatcher) {
+		if strategy != "" {
+			dispatcher.defaultStrategy = strategy
+		}
+	}
+}
+
+// DefaultStrategy returns the strategy of requests not naming one.
+func (d *Dispatcher) DefaultStrategy() Strategy {
+	return d.defaultStrategy
+}
+
+// StrategyOf returns the strategy the request runs with: its own, or the default strategy of its algorithm
+// version (see atVersion), the server's when the version is unknown.
+func (d *Dispatcher) StrategyOf(req *Request) Strategy {
+	if req.Strategy != "" {
+		return req.Strategy
+	}
+	if versioned, err := d.atVersion(req.AlgorithmVersion); err == nil {
+		return versioned.defaultStrategy
+	}
+
+	return d.defaultStrategy
+}
+
+// reconstructorFor returns the reconstructor of the strategy for the tickets: of StrategyChronological when
+// they are timed, otherwise as Reconstructor does.
+func (d *Dispatcher) reconstructorFor(strategy Strategy, tickets []Ticket) (Reconstructor, error) {
+	if Timed(tickets) {
+		strategy = StrategyChronological
+	}
+
+	return d.Reconstructor(strategy)
+}
+
+// Reconstructor returns the reconstructor of the strategy, or of the default strategy when empty.
+// It fails with ErrUnknownStrategy when the strategy is not registered.
+func (d *Dispatcher) Reconstructor(strategy Strategy) (Reconstructor, error) {
+	if strategy == "" {
+		strategy = d.defaultStrategy
+	}
+	reconstructor, ok := d.strategies[strategy]
+	if !ok {
+		return nil, ErrUnknownStrategy
+	}
+
+	return reconstructor, nil
+}
+
+// Strategies returns the names of the registered strategies in lexicographic order.
+func (d *Dispatcher) Strategies() []Strategy {
+	strategies := make([]Strategy, 0, len(d.strategies))
+	for strategy := range d.strategies {
+		strategies = append(strategies, strategy)
+	}
+	sort.Slice(strategies, func(i, j int) bool {
+		return strategies[i] < strategies[j]
+	})
+
+	return strategies
+}
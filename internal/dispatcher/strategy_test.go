@@ -0,0 +1,233 @@
+package dispatcher_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/dispatcher"
+)
+
+// wrapped is a strategy registered by withWrapped, taking the default itinerary through a ReconstructorFunc,
+// so the dispatcher does not know it is lexicographic.
+const wrapped dispatcher.Strategy = "wrapped"
+
+func withWrapped() dispatcher.Option {
+	return dispatcher.WithStrategy(wrapped, dispatcher.ReconstructorFunc(dispatcher.Lexicographic().Reconstruct))
+}
+
+func TestDispatcherStrategies(t *testing.T) {
+	t.Parallel()
+
+	tickets := []dispatcher.Ticket{{From: "JFK", To: "SFO"}, {From: "JFK", To: "ATL"}, {From: "SFO", To: "ATL"}, {From: "ATL", To: "JFK"}}
+
+	// sfoFirst takes the largest destination first, flying JFK -> SFO before JFK -> ATL.
+	sfoFirst := dispatcher.ReconstructorFunc(func(ctx context.Context, tickets []dispatcher.Ticket, _ bool) ([]string, []dispatcher.Leg, error) {
+		result, err := dispatcher.New().Reconstruct(ctx, &dispatcher.Request{Tickets: tickets, TieBreak: dispatcher.TieBreakLargestFirst})
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return result.Path, result.Legs, nil
+	})
+	// reversed flies the tickets in input order, whatever they connect to.
+	reversed := dispatcher.ReconstructorFunc(func(_ context.Context, tickets []dispatcher.Ticket, _ bool) ([]string, []dispatcher.Leg, error) {
+		path := []string{tickets[0].From}
+		legs := []dispatcher.Leg{}
+		for i, ticket := range tickets {
+			path = append(path, ticket.To)
+			legs = append(legs, dispatcher.Leg{From: ticket.From, To: ticket.To, TicketIndex: i})
+		}
+
+		return path, legs, nil
+	})
+
+	tests := []struct {
+		err      error
+		name     string
+		strategy dispatcher.Strategy
+		version  string
+		opts     []dispatcher.Option
+		expected []string
+	}{
+		{
+			name:     "Built-in default",
+			expected: []string{"JFK", "ATL", "JFK", "SFO", "ATL"},
+		},
+		{
+			name:     "Registered strategy",
+			opts:     []dispatcher.Option{dispatcher.WithStrategy("sfo_first", sfoFirst)},
+			strategy: "sfo_first",
+			expected: []string{"JFK", "SFO", "ATL", "JFK", "ATL"},
+		},
+		{
+			name: "Configured default strategy",
+			opts: []dispatcher.Option{
+				dispatcher.WithStrategy("sfo_first", sfoFirst),
+				dispatcher.WithDefaultStrategy("sfo_first"),
+			},
+			expected: []string{"JFK", "SFO", "ATL", "JFK", "ATL"},
+		},
+		{
+			name: "Configured default strategy ignored by version 1",
+			opts: []dispatcher.Option{
+				dispatcher.WithStrategy("sfo_first", sfoFirst),
+				dispatcher.WithDefaultStrategy("sfo_first"),
+			},
+			version:  "1",
+			expected: []string{"JFK", "ATL", "JFK", "SFO", "ATL"},
+		},
+		{
+			name:     "Request strategy over configured default",
+			opts:     []dispatcher.Option{dispatcher.WithStrategy("sfo_first", sfoFirst), dispatcher.WithDefaultStrategy("sfo_first")},
+			strategy: dispatcher.StrategyDefault,
+			expected: []string{"JFK", "ATL", "JFK", "SFO", "ATL"},
+		},
+		{
+			name:     "Replaced built-in",
+			opts:     []dispatcher.Option{dispatcher.WithStrategy(dispatcher.StrategyDefault, reversed)},
+			expected: []string{"JFK", "SFO", "ATL", "ATL", "JFK"},
+		},
+		{
+			name:     "Unknown strategy",
+			strategy: "fastest",
+			err:      dispatcher.ErrUnknownStrategy,
+		},
+		{
+			name: "Unknown default strategy",
+			opts: []dispatcher.Option{dispatcher.WithDefaultStrategy("fastest")},
+			err:  dispatcher.ErrUnknownStrategy,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := dispatcher.New(tt.opts...).Reconstruct(context.Background(), &dispatcher.Request{
+				Tickets:          tickets,
+				Strategy:         tt.strategy,
+				AlgorithmVersion: tt.version,
+			})
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Reconstruct() error = %v, want %v", err, tt.err)
+			}
+			if tt.err != nil {
+				return
+			}
+			if !reflect.DeepEqual(result.Path, tt.expected) {
+				t.Errorf("Reconstruct() path = %v, want %v", result.Path, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDispatcherStrategiesStreaming(t *testing.T) {
+	t.Parallel()
+
+	req := &dispatcher.Request{Tickets: []dispatcher.Ticket{{From: "JFK", To: "LAX"}}}
+	emit := func(int, string) error { return nil }
+
+	d := dispatcher.New(dispatcher.WithStrategy(dispatcher.StrategyDefault, dispatcher.ReconstructorFunc(dispatcher.Lexicographic().Reconstruct)))
+	if err := d.StreamItinerary(context.Background(), req, emit); !errors.Is(err, dispatcher.ErrStreamingUnsupported) {
+		t.Errorf("StreamItinerary() with a replaced default error = %v, want %v", err, dispatcher.ErrStreamingUnsupported)
+	}
+
+	d = dispatcher.New(dispatcher.WithStrategy("plain", dispatcher.Lexicographic()), dispatcher.WithDefaultStrategy("plain"))
+	if err := d.StreamItinerary(context.Background(), req, emit); err != nil {
+		t.Errorf("StreamItinerary() with a lexicographic default error = %v", err)
+	}
+
+	if got := d.DefaultStrategy(); got != "plain" {
+		t.Errorf("DefaultStrategy() = %q, want plain", got)
+	}
+	if got := d.StrategyOf(&dispatcher.Request{AlgorithmVersion: "1"}); got != dispatcher.StrategyDefault {
+		t.Errorf("StrategyOf(version 1) = %q, want %q", got, dispatcher.StrategyDefault)
+	}
+
+	expected := []dispatcher.Strategy{dispatcher.StrategyChronological, dispatcher.StrategyDefault, "plain"}
+	if strategies := d.Strategies(); !reflect.DeepEqual(strategies, expected) {
+		t.Errorf("Strategies() = %v, want %v", strategies, expected)
+	}
+}
+
+func TestDispatcherChronologicalStrategy(t *testing.T) {
+	t.Parallel()
+
+	at := func(hour int) *time.Time {
+		departure := time.Date(2026, 10, 17, hour, 0, 0, 0, time.UTC)
+
+		return &departure
+	}
+	timed := []dispatcher.Ticket{
+		{From: "JFK", To: "ATL", DepartsAt: at(8)},
+		{From: "ATL", To: "JFK", DepartsAt: at(12)},
+		{From: "JFK", To: "SFO", DepartsAt: at(16)},
+	}
+	untimed := []dispatcher.Ticket{{From: "JFK", To: "ATL"}, {From: "ATL", To: "JFK"}, {From: "JFK", To: "SFO"}}
+	// lastFirst flies the tickets from the last to the first, whatever they connect to.
+	lastFirst := dispatcher.ReconstructorFunc(func(_ context.Context, tickets []dispatcher.Ticket, _ bool) ([]string, []dispatcher.Leg, error) {
+		path := []string{tickets[len(tickets)-1].From}
+		for i := len(tickets) - 1; i >= 0; i-- {
+			path = append(path, tickets[i].To)
+		}
+
+		return path, nil, nil
+	})
+
+	tests := []struct {
+		err      error
+		name     string
+		strategy dispatcher.Strategy
+		opts     []dispatcher.Option
+		tickets  []dispatcher.Ticket
+		expected []string
+	}{
+		{
+			name:     "Timed tickets",
+			tickets:  timed,
+			expected: []string{"JFK", "ATL", "JFK", "SFO"},
+		},
+		{
+			name:     "Timed tickets whatever the strategy",
+			opts:     []dispatcher.Option{dispatcher.WithStrategy("last_first", lastFirst)},
+			strategy: "last_first",
+			tickets:  timed,
+			expected: []string{"JFK", "ATL", "JFK", "SFO"},
+		},
+		{
+			name:     "Replaced built-in",
+			opts:     []dispatcher.Option{dispatcher.WithStrategy(dispatcher.StrategyChronological, lastFirst)},
+			tickets:  timed,
+			expected: []string{"JFK", "SFO", "JFK", "ATL"},
+		},
+		{
+			name:     "Untimed tickets",
+			strategy: dispatcher.StrategyChronological,
+			tickets:  untimed,
+			err:      dispatcher.ErrUntimedTickets,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := dispatcher.New(tt.opts...).Reconstruct(context.Background(), &dispatcher.Request{
+				Tickets:  tt.tickets,
+				Strategy: tt.strategy,
+			})
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Reconstruct() error = %v, want %v", err, tt.err)
+			}
+			if tt.err != nil {
+				return
+			}
+			if !reflect.DeepEqual(result.Path, tt.expected) {
+				t.Errorf("Reconstruct() path = %v, want %v", result.Path, tt.expected)
+			}
+		})
+	}
+}
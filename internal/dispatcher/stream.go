@@ -0,0 +1,93 @@
+package dispatcher
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrStreamingUnsupported is returned when a request needs the whole path before it can be checked or chosen.
+var ErrStreamingUnsupported = errors.New("streaming is only supported for the lexicographic strategy without constraints, departure times or surface transfers")
+
+// StreamItinerary reconstructs the linear path of the request like Reconstruct, but hands every airport
+// to emit as Hierholzer's traversal unwinds instead of collecting the path first. Airports therefore
+// arrive last stop first, each with its index in the linear path, so the caller never has to hold the
+// whole path. The path is the one Reconstruct returns, airport codes normalized alike but without reporting
+// the changes; requests whose result depends on the whole path (a strategy other than Lexicographic,
+// constraints, time-aware reconstruction, surface transfers) fail with ErrStreamingUnsupported.
+//
+// All validation happens before the first call to emit. StreamItinerary stops at the first error returned by emit.
+func (d *Dispatcher) StreamItinerary(ctx context.Context, req *Request, emit func(index int, airport string) error) error {
+	if err := d.checkTicketCount(len(req.Tickets)); err != nil {
+		return err
+	}
+	d, err := d.atVersion(req.AlgorithmVersion)
+	if err != nil {
+		return err
+	}
+	reconstructor, err := d.reconstructorFor(req.Strategy, req.Tickets)
+	if err != nil {
+		return err
+	}
+	if _, lexical := reconstructor.(lexicographic); !lexical || req.Constraints != nil || len(req.SurfaceTransfers) > 0 {
+		return ErrStreamingUnsupported
+	}
+	order, err := d.ordering(req)
+	if err != nil {
+		return err
+	}
+
+	normalized, _, err := d.normalizeTickets(req)
+	if err != nil {
+		return err
+	}
+
+	tickets := Pairs(normalized)
+	if d.strictAirports(req.StrictAirports) {
+		report := newValidationReport()
+		report.CheckAirports(tickets, d.knownAirport)
+		if !report.Valid {
+			return &ValidationError{Err: report.Err(), Report: report}
+		}
+	}
+
+	return streamItinerary(ctx, tickets, d.duplicatesAllowed(req.AllowDuplicates), order, emit)
+}
+
+// streamItinerary is reconstructItinerary with the path handed to emit in unwinding order.
+func streamItinerary(
+	ctx context.Context, tickets [][]string, allowDuplicates bool, order ordering, emit func(index int, airport string) error,
+) error {
+	if len(tickets) == 0 {
+		return nil
+	}
+
+	ws := newWorkspace(len(tickets))
+	defer ws.release()
+
+	if err := validateTickets(ws, tickets, allowDuplicates); err != nil {
+		return err
+	}
+
+	graph := &ws.graph
+	if err := graph.build(ctx, &ws.interned, order); err != nil {
+		return err
+	}
+
+	start, err := graph.startingPoint()
+	if err != nil {
+		return err
+	}
+	if err = graph.validateEndPoints(); err != nil {
+		return err
+	}
+
+	// Validation guarantees a connected path using every ticket, so it has one airport more than there are tickets.
+	index := len(tickets)
+
+	return walkPath(ctx, graph, start, func(airport string) error {
+		err := emit(index, airport)
+		index--
+
+		return err
+	})
+}
@@ -0,0 +1,110 @@
+package dispatcher_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/dispatcher"
+)
+
+func TestStreamItinerary(t *testing.T) {
+	t.Parallel()
+
+	departs := time.Date(2025, 5, 1, 8, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		err     error
+		req     *dispatcher.Request
+		name    string
+		tickets [][]string
+	}{
+		{
+			name:    "Linear path",
+			tickets: [][]string{{"LAX", "DXB"}, {"JFK", "LAX"}, {"SFO", "SJC"}, {"DXB", "SFO"}},
+		},
+		{
+			name:    "Revisited airport",
+			tickets: [][]string{{"JFK", "SFO"}, {"JFK", "ATL"}, {"SFO", "ATL"}, {"ATL", "JFK"}, {"ATL", "SFO"}},
+		},
+		{
+			name:    "No tickets",
+			tickets: [][]string{},
+		},
+		{
+			name:    "Invalid tickets",
+			tickets: [][]string{{"JFK", "LAX"}, {"JFK", "LAX"}},
+			err:     dispatcher.ErrMultipleSameDestination,
+		},
+		{
+			name: "Non-default strategy",
+			req:  &dispatcher.Request{Strategy: wrapped},
+			err:  dispatcher.ErrStreamingUnsupported,
+		},
+		{
+			name: "Timed tickets",
+			req:  &dispatcher.Request{Tickets: []dispatcher.Ticket{{From: "JFK", To: "LAX", DepartsAt: &departs}}},
+			err:  dispatcher.ErrStreamingUnsupported,
+		},
+	}
+
+	d := dispatcher.New(withWrapped())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := tt.req
+			if req == nil {
+				req = &dispatcher.Request{}
+				for _, pair := range tt.tickets {
+					req.Tickets = append(req.Tickets, dispatcher.TicketFromPair(pair))
+				}
+			}
+
+			path := make([]string, len(req.Tickets)+1)
+			lastIndex := len(path)
+			err := d.StreamItinerary(context.Background(), req, func(index int, airport string) error {
+				if index >= lastIndex {
+					t.Errorf("index %d emitted after %d; want decreasing indexes", index, lastIndex)
+				}
+				lastIndex = index
+				path[index] = airport
+
+				return nil
+			})
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("StreamItinerary() error = %v; want %v", err, tt.err)
+			}
+			if tt.err != nil || len(req.Tickets) == 0 {
+				return
+			}
+
+			want, err := dispatcher.ReconstructItinerary(dispatcher.Pairs(req.Tickets))
+			if err != nil {
+				t.Fatalf("ReconstructItinerary() error = %v", err)
+			}
+			if !reflect.DeepEqual(path, want) {
+				t.Errorf("streamed path = %v; want %v", path, want)
+			}
+		})
+	}
+}
+
+func TestStreamItineraryStopsOnEmitError(t *testing.T) {
+	t.Parallel()
+
+	errStop := errors.New("stop")
+	req := &dispatcher.Request{Tickets: []dispatcher.Ticket{{From: "JFK", To: "LAX"}, {From: "LAX", To: "DXB"}}}
+
+	calls := 0
+	err := dispatcher.New().StreamItinerary(context.Background(), req, func(int, string) error {
+		calls++
+
+		return errStop
+	})
+	if !errors.Is(err, errStop) || calls != 1 {
+		t.Errorf("StreamItinerary() = %v after %d calls; want %v after 1 call", err, calls, errStop)
+	}
+}
@@ -0,0 +1,169 @@
+package dispatcher
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+var ErrTrailingData = errors.New("unexpected data after the JSON value")
+
+// Ticket is a single flight ticket with optional metadata.
+// In JSON it can be written either as a ["Source", "Destination"] pair or as an object.
+// Currency is the ISO 4217 code of the price, e.g. "USD".
+type Ticket struct {
+	DepartsAt *time.Time     `json:"departs_at,omitempty" xml:"departs_at,omitempty"`
+	ArrivesAt *time.Time     `json:"arrives_at,omitempty" xml:"arrives_at,omitempty"`
+	Price     *float64       `json:"price,omitempty"      xml:"price,omitempty"`
+	Metadata  map[string]any `json:"metadata,omitempty"   xml:"-"`
+	From      string         `json:"from"                 xml:"from"`
+	To        string         `json:"to"                   xml:"to"`
+	FlightNo  string         `json:"flight_no,omitempty"  xml:"flight_no,omitempty"`
+	Currency  string         `json:"currency,omitempty"   xml:"currency,omitempty"`
+	// pair keeps the original array form so malformed pairs still reach validation untouched.
+	pair []string
+}
+
+// UnmarshalJSON decodes a ticket of either form. Objects are decoded strictly: fields a Ticket does not have are
+// rejected, arbitrary data belonging in metadata, and so is data after the ticket.
+func (t *Ticket) UnmarshalJSON(data []byte) error {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		var pair []string
+		if err := json.Unmarshal(trimmed, &pair); err != nil {
+			return err
+		}
+
+		*t = TicketFromPair(pair)
+
+		return nil
+	}
+
+	type plain Ticket
+	var p plain
+	if err := DecodeStrict(data, &p); err != nil {
+		return err
+	}
+	*t = Ticket(p)
+
+	return nil
+}
+
+// DecodeStrict decodes the JSON value of data into v like json.Unmarshal, but rejects the fields v does not
+// have, and fails with ErrTrailingData when data holds more than one value.
+func DecodeStrict(data []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return ErrTrailingData
+	}
+
+	return nil
+}
+
+// TicketFromPair creates a ticket from a [source, destination] pair. The pair is kept as is,
+// so a malformed pair is reported by validation instead of being silently truncated.
+func TicketFromPair(pair []string) Ticket {
+	t := Ticket{pair: pair}
+	if len(pair) > 0 {
+		t.From = pair[0]
+	}
+	if len(pair) > 1 {
+		t.To = pair[1]
+	}
+
+	return t
+}
+
+// Pair returns the ticket as a [source, destination] pair.
+func (t *Ticket) Pair() []string {
+	if t.pair != nil {
+		return t.pair
+	}
+
+	return []string{t.From, t.To}
+}
+
+// ShapeProblem describes why the ticket is not exactly two non-empty airport codes, e.g. "has 3 elements",
+// and returns "" for a well-formed ticket.
+func (t *Ticket) ShapeProblem() string {
+	switch {
+	case t.pair == nil || len(t.pair) == 2:
+	case len(t.pair) == 1:
+		return "has 1 element, want [source, destination]"
+	default:
+		return fmt.Sprintf("has %d elements, want [source, destination]", len(t.pair))
+	}
+
+	switch {
+	case t.From == "" && t.To == "":
+		return "source and destination are empty"
+	case t.From == "":
+		return "source is empty"
+	case t.To == "":
+		return "destination is empty"
+	default:
+		return ""
+	}
+}
+
+// Pairs converts tickets to the [source, destination] pairs the algorithms operate on.
+func Pairs(tickets []Ticket) [][]string {
+	pairs := make([][]string, 0, len(tickets))
+	for i := range tickets {
+		pairs = append(pairs, tickets[i].Pair())
+	}
+
+	return pairs
+}
+
+// TotalPrice sums the ticket prices of the legs; tickets without a price and surface transfers count as free.
+// Every valid itinerary uses each ticket exactly once, so it is the same for all orderings of the tickets.
+func TotalPrice(tickets []Ticket, legs []Leg) float64 {
+	total := 0.0
+	for _, leg := range legs {
+		if leg.TicketIndex < 0 {
+			continue
+		}
+		if price := tickets[leg.TicketIndex].Price; price != nil {
+			total += *price
+		}
+	}
+
+	return total
+}
+
+// TicketDecoder decodes a stream of newline-delimited JSON (NDJSON) tickets, one ticket per line in
+// either form, as the stream is read, so the raw stream never has to be buffered as a whole.
+type TicketDecoder struct {
+	dec   *json.Decoder
+	count int
+}
+
+func NewTicketDecoder(r io.Reader) *TicketDecoder {
+	return &TicketDecoder{dec: json.NewDecoder(r)}
+}
+
+// Next returns the next ticket, or io.EOF once the stream is exhausted. A ticket that cannot be
+// decoded or is not two non-empty airport codes is reported as ErrMalformedTicket with its position.
+func (d *TicketDecoder) Next() (Ticket, error) {
+	var ticket Ticket
+	if err := d.dec.Decode(&ticket); err != nil {
+		if errors.Is(err, io.EOF) {
+			return ticket, io.EOF
+		}
+
+		return ticket, fmt.Errorf("%w: ticket %d: %w", ErrMalformedTicket, d.count, err)
+	}
+	if problem := ticket.ShapeProblem(); problem != "" {
+		return ticket, fmt.Errorf("%w: ticket %d %s", ErrMalformedTicket, d.count, problem)
+	}
+	d.count++
+
+	return ticket, nil
+}
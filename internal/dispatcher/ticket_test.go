@@ -0,0 +1,169 @@
+package dispatcher_test
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/dsha256/dispatcher/internal/dispatcher"
+)
+
+func TestTicketDecoder(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		err   error
+		name  string
+		input string
+		want  [][]string
+	}{
+		{
+			name:  "Both ticket forms",
+			input: "[\"JFK\", \"LAX\"]\n{\"from\": \"LAX\", \"to\": \"DXB\", \"price\": 10}\n\n",
+			want:  [][]string{{"JFK", "LAX"}, {"LAX", "DXB"}},
+		},
+		{
+			name:  "Empty stream",
+			input: "",
+			want:  [][]string{},
+		},
+		{
+			name:  "Malformed line",
+			input: "[\"JFK\", \"LAX\"]\n[\"LAX\", \n",
+			want:  [][]string{{"JFK", "LAX"}},
+			err:   dispatcher.ErrMalformedTicket,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			decoder := dispatcher.NewTicketDecoder(strings.NewReader(tt.input))
+			got := [][]string{}
+			var err error
+			for {
+				var ticket dispatcher.Ticket
+				if ticket, err = decoder.Next(); err != nil {
+					break
+				}
+				got = append(got, ticket.Pair())
+			}
+
+			if errors.Is(err, io.EOF) {
+				err = nil
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Next() error = %v; want %v", err, tt.err)
+			}
+			if strings.Join(flatten(got), ",") != strings.Join(flatten(tt.want), ",") {
+				t.Errorf("decoded %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeStrict(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		input   string
+		unknown bool
+		err     error
+	}{
+		{name: "Pair", input: `["JFK", "LAX"]`},
+		{name: "Object", input: `{"from": "JFK", "to": "LAX", "metadata": {"seat": "12A"}}`},
+		{name: "Unknown field", input: `{"from": "JFK", "to": "LAX", "seat": "12A"}`, unknown: true},
+		{name: "Trailing data", input: `{"from": "JFK", "to": "LAX"} {}`, err: dispatcher.ErrTrailingData},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var ticket dispatcher.Ticket
+			err := dispatcher.DecodeStrict([]byte(tt.input), &ticket)
+			if tt.unknown {
+				if err == nil || !strings.Contains(err.Error(), "unknown field") {
+					t.Errorf("DecodeStrict() error = %v; want an unknown field", err)
+				}
+
+				return
+			}
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("DecodeStrict() error = %v; want %v", err, tt.err)
+			}
+			if tt.err == nil && (ticket.From != "JFK" || ticket.To != "LAX") {
+				t.Errorf("DecodeStrict() ticket = %+v; want JFK -> LAX", ticket)
+			}
+		})
+	}
+}
+
+func flatten(pairs [][]string) []string {
+	out := []string{}
+	for _, pair := range pairs {
+		out = append(out, pair...)
+	}
+
+	return out
+}
+
+func TestTicketShapeProblem(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		ticket dispatcher.Ticket
+		want   string
+	}{
+		{name: "Pair", ticket: dispatcher.TicketFromPair([]string{"JFK", "LAX"})},
+		{name: "Object", ticket: dispatcher.Ticket{From: "JFK", To: "LAX"}},
+		{name: "Three elements", ticket: dispatcher.TicketFromPair([]string{"JFK", "LAX", "DXB"}), want: "has 3 elements, want [source, destination]"},
+		{name: "One element", ticket: dispatcher.TicketFromPair([]string{"JFK"}), want: "has 1 element, want [source, destination]"},
+		{name: "Empty pair", ticket: dispatcher.TicketFromPair([]string{}), want: "has 0 elements, want [source, destination]"},
+		{name: "Empty source", ticket: dispatcher.TicketFromPair([]string{"", "LAX"}), want: "source is empty"},
+		{name: "Empty destination", ticket: dispatcher.Ticket{From: "JFK"}, want: "destination is empty"},
+		{name: "Empty object", ticket: dispatcher.Ticket{}, want: "source and destination are empty"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := tt.ticket.ShapeProblem(); got != tt.want {
+				t.Errorf("ShapeProblem() = %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTotalPrice(t *testing.T) {
+	t.Parallel()
+
+	price := func(p float64) *float64 { return &p }
+	tickets := []dispatcher.Ticket{{From: "JFK", To: "LAX", Price: price(120)}, {From: "LAX", To: "SFO"}, {From: "SFO", To: "BOS", Price: price(80.5)}}
+
+	tests := []struct {
+		name     string
+		legs     []dispatcher.Leg
+		expected float64
+	}{
+		{name: "All legs", legs: []dispatcher.Leg{{TicketIndex: 0}, {TicketIndex: 1}, {TicketIndex: 2}}, expected: 200.5},
+		{name: "Surface transfer", legs: []dispatcher.Leg{{TicketIndex: 0}, {TicketIndex: -1}, {TicketIndex: 2}}, expected: 200.5},
+		{name: "Unpriced ticket", legs: []dispatcher.Leg{{TicketIndex: 1}}},
+		{name: "No legs"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := dispatcher.TotalPrice(tickets, tt.legs); got != tt.expected {
+				t.Errorf("TotalPrice() = %v; want %v", got, tt.expected)
+			}
+		})
+	}
+}
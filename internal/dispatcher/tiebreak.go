@@ -0,0 +1,63 @@
+package dispatcher
+
+import (
+	"errors"
+	"slices"
+	"sort"
+)
+
+var ErrUnknownTieBreak = errors.New("unknown tie-break policy")
+
+// TieBreak decides which destination is taken first when an airport has several, and with it which of several
+// valid orderings is returned. Time-aware reconstruction ignores it, as the chronology fully determines the order.
+type TieBreak string
+
+const (
+	// TieBreakSmallestFirst takes the lexicographically smallest destination first, so StrategyDefault returns
+	// the lexicographically smallest itinerary. It is the default.
+	TieBreakSmallestFirst TieBreak = "smallest_first"
+	// TieBreakLargestFirst takes the lexicographically largest destination first.
+	TieBreakLargestFirst TieBreak = "largest_first"
+	// TieBreakInputOrder takes the destination of the ticket listed first, so the same tickets listed in
+	// another order may yield another itinerary.
+	TieBreakInputOrder TieBreak = "input_order"
+)
+
+// WithDefaultTieBreak sets the tie-break policy of requests not naming one, TieBreakSmallestFirst unless set.
+// An empty policy keeps the current default.
+func WithDefaultTieBreak(tieBreak TieBreak) Option {
+	return func(dispatcher *Dispatcher) {
+		if tieBreak != "" {
+			dispatcher.defaultTieBreak = tieBreak
+		}
+	}
+}
+
+// TieBreak returns the tie-break policy of requests naming tieBreak, or the default policy when empty.
+// It fails with ErrUnknownTieBreak when the policy is unknown.
+func (d *Dispatcher) TieBreak(tieBreak TieBreak) (TieBreak, error) {
+	if tieBreak == "" {
+		tieBreak = d.defaultTieBreak
+	}
+	switch tieBreak {
+	case "", TieBreakSmallestFirst:
+		return TieBreakSmallestFirst, nil
+	case TieBreakLargestFirst, TieBreakInputOrder:
+		return tieBreak, nil
+	default:
+		return "", ErrUnknownTieBreak
+	}
+}
+
+// arrange orders the destination IDs of an airport, given in input order, for Hierholzer's traversal, which
+// takes them from the end. IDs compare like their codes (see internedTickets).
+func (t TieBreak) arrange(dests []int) {
+	switch t {
+	case TieBreakLargestFirst:
+		sort.Ints(dests)
+	case TieBreakInputOrder:
+		slices.Reverse(dests)
+	default:
+		sort.Sort(sort.Reverse(sort.IntSlice(dests)))
+	}
+}
@@ -0,0 +1,137 @@
+package dispatcher_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/dsha256/dispatcher/internal/dispatcher"
+)
+
+func TestDispatcherTieBreak(t *testing.T) {
+	t.Parallel()
+
+	sfoListedFirst := []dispatcher.Ticket{{From: "JFK", To: "SFO"}, {From: "JFK", To: "ATL"}, {From: "SFO", To: "ATL"}, {From: "ATL", To: "JFK"}}
+	atlListedFirst := []dispatcher.Ticket{{From: "JFK", To: "ATL"}, {From: "SFO", To: "ATL"}, {From: "ATL", To: "JFK"}, {From: "JFK", To: "SFO"}}
+
+	tests := []struct {
+		err      error
+		name     string
+		tieBreak dispatcher.TieBreak
+		version  string
+		tickets  []dispatcher.Ticket
+		opts     []dispatcher.Option
+		expected []string
+	}{
+		{
+			name:     "Smallest first by default",
+			tickets:  sfoListedFirst,
+			expected: []string{"JFK", "ATL", "JFK", "SFO", "ATL"},
+		},
+		{
+			name:     "Largest first",
+			tieBreak: dispatcher.TieBreakLargestFirst,
+			tickets:  atlListedFirst,
+			expected: []string{"JFK", "SFO", "ATL", "JFK", "ATL"},
+		},
+		{
+			name:     "Input order",
+			tieBreak: dispatcher.TieBreakInputOrder,
+			tickets:  sfoListedFirst,
+			expected: []string{"JFK", "SFO", "ATL", "JFK", "ATL"},
+		},
+		{
+			name:     "Input order reordered",
+			tieBreak: dispatcher.TieBreakInputOrder,
+			tickets:  atlListedFirst,
+			expected: []string{"JFK", "ATL", "JFK", "SFO", "ATL"},
+		},
+		{
+			name:     "Configured default",
+			opts:     []dispatcher.Option{dispatcher.WithDefaultTieBreak(dispatcher.TieBreakLargestFirst)},
+			tickets:  sfoListedFirst,
+			expected: []string{"JFK", "SFO", "ATL", "JFK", "ATL"},
+		},
+		{
+			name:     "Configured default ignored by version 1",
+			opts:     []dispatcher.Option{dispatcher.WithDefaultTieBreak(dispatcher.TieBreakLargestFirst)},
+			version:  "1",
+			tickets:  sfoListedFirst,
+			expected: []string{"JFK", "ATL", "JFK", "SFO", "ATL"},
+		},
+		{
+			name:     "Request over configured default in version 1",
+			opts:     []dispatcher.Option{dispatcher.WithDefaultTieBreak(dispatcher.TieBreakLargestFirst)},
+			tieBreak: dispatcher.TieBreakLargestFirst,
+			version:  "1",
+			tickets:  sfoListedFirst,
+			expected: []string{"JFK", "SFO", "ATL", "JFK", "ATL"},
+		},
+		{
+			name:     "Request over configured default",
+			opts:     []dispatcher.Option{dispatcher.WithDefaultTieBreak(dispatcher.TieBreakLargestFirst)},
+			tieBreak: dispatcher.TieBreakSmallestFirst,
+			tickets:  sfoListedFirst,
+			expected: []string{"JFK", "ATL", "JFK", "SFO", "ATL"},
+		},
+		{
+			name:     "Unknown policy",
+			tieBreak: "random",
+			tickets:  sfoListedFirst,
+			err:      dispatcher.ErrUnknownTieBreak,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := dispatcher.New(tt.opts...).Reconstruct(context.Background(), &dispatcher.Request{
+				Tickets:          tt.tickets,
+				TieBreak:         tt.tieBreak,
+				AlgorithmVersion: tt.version,
+			})
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Reconstruct() error = %v, want %v", err, tt.err)
+			}
+			if tt.err != nil {
+				return
+			}
+			if !reflect.DeepEqual(result.Path, tt.expected) {
+				t.Errorf("Reconstruct() path = %v, want %v", result.Path, tt.expected)
+			}
+			for i, leg := range result.Legs {
+				if ticket := tt.tickets[leg.TicketIndex]; ticket.From != result.Path[i] || ticket.To != result.Path[i+1] {
+					t.Errorf("Leg %d points to ticket %v, want %s -> %s", i, ticket, result.Path[i], result.Path[i+1])
+				}
+			}
+		})
+	}
+}
+
+func TestStreamItineraryTieBreak(t *testing.T) {
+	t.Parallel()
+
+	req := &dispatcher.Request{
+		Tickets:  []dispatcher.Ticket{{From: "JFK", To: "ATL"}, {From: "JFK", To: "SFO"}, {From: "SFO", To: "ATL"}, {From: "ATL", To: "JFK"}},
+		TieBreak: dispatcher.TieBreakLargestFirst,
+	}
+	path := make([]string, len(req.Tickets)+1)
+	err := dispatcher.New().StreamItinerary(context.Background(), req, func(index int, airport string) error {
+		path[index] = airport
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamItinerary() error = %v", err)
+	}
+	if expected := []string{"JFK", "SFO", "ATL", "JFK", "ATL"}; !reflect.DeepEqual(path, expected) {
+		t.Errorf("StreamItinerary() path = %v, want %v", path, expected)
+	}
+
+	req.TieBreak = "random"
+	if err = dispatcher.New().StreamItinerary(context.Background(), req, func(int, string) error { return nil }); !errors.Is(err, dispatcher.ErrUnknownTieBreak) {
+		t.Errorf("StreamItinerary() error = %v, want %v", err, dispatcher.ErrUnknownTieBreak)
+	}
+}
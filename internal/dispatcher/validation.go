@@ -0,0 +1,364 @@
+package dispatcher
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrMalformedTicket       = errors.New("malformed ticket")
+	ErrUnknownAirport        = errors.New("unknown airport")
+	ErrDisconnectedItinerary = errors.New("disconnected itinerary")
+)
+
+// IssueKind classifies a single problem found in a ticket set.
+type IssueKind string
+
+const (
+	IssueMalformedTicket  IssueKind = "malformed_ticket"
+	IssueUnknownAirport   IssueKind = "unknown_airport"
+	IssueDuplicateTicket  IssueKind = "duplicate_ticket"
+	IssueUnbalancedDegree IssueKind = "unbalanced_degree"
+	IssueNoStartingPoint  IssueKind = "no_starting_point"
+	IssueDisconnected     IssueKind = "disconnected"
+)
+
+// ValidationIssue describes one problem and the tickets causing it.
+// Indexes refer to positions in the original tickets slice.
+type ValidationIssue struct {
+	Kind    IssueKind  `json:"kind"`
+	Airport string     `json:"airport,omitempty"`
+	Detail  string     `json:"detail"`
+	Indexes []int      `json:"indexes"`
+	Tickets [][]string `json:"tickets"`
+}
+
+// AirportDegree is the number of departures and arrivals of a single airport.
+type AirportDegree struct {
+	Airport    string `json:"airport"`
+	Departures int    `json:"departures"`
+	Arrivals   int    `json:"arrivals"`
+}
+
+// ValidationReport is the structured result of ValidateTickets.
+type ValidationReport struct {
+	// StartCandidates are the airports with more departures than arrivals.
+	StartCandidates []string `json:"start_candidates"`
+	// EndCandidates are the airports with more arrivals than departures.
+	EndCandidates []string `json:"end_candidates"`
+	// Unbalanced are the airports whose degrees rule out a single path.
+	Unbalanced []AirportDegree   `json:"unbalanced"`
+	Issues     []ValidationIssue `json:"issues"`
+	Valid      bool              `json:"valid"`
+}
+
+func newValidationReport() *ValidationReport {
+	return &ValidationReport{
+		StartCandidates: []string{},
+		EndCandidates:   []string{},
+		Unbalanced:      []AirportDegree{},
+		Issues:          []ValidationIssue{},
+		Valid:           true,
+	}
+}
+
+// Err returns the sentinel error matching the most severe issue of the report, or nil when it is valid.
+func (r *ValidationReport) Err() error {
+	if r.Valid {
+		return nil
+	}
+
+	severity := []struct {
+		err   error
+		kinds []IssueKind
+	}{
+		{err: ErrMalformedTicket, kinds: []IssueKind{IssueMalformedTicket}},
+		{err: ErrUnknownAirport, kinds: []IssueKind{IssueUnknownAirport}},
+		{err: ErrMultipleSameDestination, kinds: []IssueKind{IssueDuplicateTicket}},
+		{err: ErrDifferentStartingPoints, kinds: []IssueKind{IssueUnbalancedDegree, IssueNoStartingPoint}},
+		{err: ErrDisconnectedItinerary, kinds: []IssueKind{IssueDisconnected}},
+	}
+	for _, level := range severity {
+		for _, issue := range r.Issues {
+			for _, kind := range level.kinds {
+				if issue.Kind == kind {
+					return level.err
+				}
+			}
+		}
+	}
+
+	return ErrDifferentStartingPoints
+}
+
+// ValidationError carries the full validation report alongside the sentinel error.
+// It unwraps to the sentinel, so errors.Is keeps working for callers.
+type ValidationError struct {
+	Err    error
+	Report *ValidationReport
+}
+
+func (e *ValidationError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ValidationError) Details() any {
+	return e.Report
+}
+
+// ValidateTickets runs every graph check against the tickets without reconstructing the itinerary.
+// Unlike ReconstructItinerary, it does not stop at the first failure: the report lists all duplicates,
+// unbalanced airports and disconnected tickets together with their indexes.
+func ValidateTickets(tickets [][]string) *ValidationReport {
+	ws := newWorkspace(len(tickets))
+	defer ws.release()
+
+	return validate(ws, tickets)
+}
+
+// validate is ValidateTickets interning the well-formed tickets into ws.
+func validate(ws *workspace, tickets [][]string) *ValidationReport {
+	report := newValidationReport()
+
+	wellFormed := make([]int, 0, len(tickets))
+	for i, ticket := range tickets {
+		if len(ticket) != 2 || ticket[0] == "" || ticket[1] == "" {
+			report.Issues = append(report.Issues, ValidationIssue{
+				Kind:    IssueMalformedTicket,
+				Detail:  "a ticket must be a non-empty [source, destination] pair",
+				Indexes: []int{i},
+				Tickets: [][]string{ticket},
+			})
+
+			continue
+		}
+		wellFormed = append(wellFormed, i)
+	}
+
+	interned := &ws.interned
+	interned.intern(ws.ids, tickets, wellFormed)
+	report.Issues = append(report.Issues, duplicateIssues(tickets, interned, ws.counts)...)
+	report.Issues = append(report.Issues, degreeIssues(report, tickets, interned)...)
+	report.Issues = append(report.Issues, connectivityIssues(tickets, interned)...)
+	report.Valid = len(report.Issues) == 0
+
+	return report
+}
+
+// CheckAirports adds an issue to the report for every well-formed ticket whose source or destination
+// is not a known airport code.
+func (r *ValidationReport) CheckAirports(tickets [][]string, known func(code string) bool) {
+	for i, ticket := range tickets {
+		if len(ticket) != 2 {
+			continue
+		}
+		for _, code := range ticket {
+			if code == "" || known(code) {
+				continue
+			}
+			r.Issues = append(r.Issues, ValidationIssue{
+				Kind:    IssueUnknownAirport,
+				Airport: code,
+				Detail:  fmt.Sprintf("%q is not a known IATA or ICAO airport code", code),
+				Indexes: []int{i},
+				Tickets: [][]string{ticket},
+			})
+		}
+	}
+	r.Valid = len(r.Issues) == 0
+}
+
+// duplicateIssues reports repeated tickets, counting them in counts, which must be empty.
+func duplicateIssues(tickets [][]string, interned *internedTickets, counts map[[2]int]int) []ValidationIssue {
+	for _, key := range interned.edges {
+		counts[key]++
+	}
+
+	// Only repeated tickets have their indexes collected.
+	seen := make(map[[2]int][]int)
+	order := [][2]int{}
+	for k, key := range interned.edges {
+		if counts[key] < 2 {
+			continue
+		}
+		if _, ok := seen[key]; !ok {
+			order = append(order, key)
+		}
+		seen[key] = append(seen[key], interned.indexes[k])
+	}
+
+	issues := []ValidationIssue{}
+	for _, key := range order {
+		from, to := interned.codes[key[0]], interned.codes[key[1]]
+		issues = append(issues, ValidationIssue{
+			Kind:    IssueDuplicateTicket,
+			Detail:  fmt.Sprintf("ticket %s -> %s appears %d times", from, to, len(seen[key])),
+			Indexes: seen[key],
+			Tickets: ticketsAt(tickets, seen[key]),
+		})
+	}
+
+	return issues
+}
+
+// degreeIssues reports the airports whose degrees rule out a single path. Airports are visited by ID,
+// which is their lexicographic order.
+func degreeIssues(report *ValidationReport, tickets [][]string, interned *internedTickets) []ValidationIssue {
+	if len(interned.edges) == 0 {
+		return nil
+	}
+
+	outDegree := make([]int, interned.airports())
+	inDegree := make([]int, interned.airports())
+	for _, edge := range interned.edges {
+		outDegree[edge[0]]++
+		inDegree[edge[1]]++
+	}
+
+	starts, ends, invalid := 0, 0, false
+	for airport, code := range interned.codes {
+		diff := outDegree[airport] - inDegree[airport]
+		switch {
+		case diff > 0:
+			report.StartCandidates = append(report.StartCandidates, code)
+		case diff < 0:
+			report.EndCandidates = append(report.EndCandidates, code)
+		}
+
+		switch diff {
+		case 0:
+		case 1:
+			starts++
+		case -1:
+			ends++
+		default:
+			invalid = true
+		}
+	}
+
+	if !invalid && starts == 1 && ends == 1 {
+		return nil
+	}
+
+	if starts == 0 && ends == 0 && !invalid {
+		return []ValidationIssue{{
+			Kind:    IssueNoStartingPoint,
+			Detail:  "every airport has as many departures as arrivals, so there is no unique starting point",
+			Indexes: interned.indexes,
+			Tickets: ticketsAt(tickets, interned.indexes),
+		}}
+	}
+
+	touching := make([][]int, interned.airports())
+	for k, edge := range interned.edges {
+		i := interned.indexes[k]
+		touching[edge[0]] = append(touching[edge[0]], i)
+		if edge[1] != edge[0] {
+			touching[edge[1]] = append(touching[edge[1]], i)
+		}
+	}
+
+	issues := []ValidationIssue{}
+	for airport, code := range interned.codes {
+		if outDegree[airport] == inDegree[airport] {
+			continue
+		}
+		report.Unbalanced = append(report.Unbalanced, AirportDegree{
+			Airport:    code,
+			Departures: outDegree[airport],
+			Arrivals:   inDegree[airport],
+		})
+		issues = append(issues, ValidationIssue{
+			Kind:    IssueUnbalancedDegree,
+			Airport: code,
+			Detail:  fmt.Sprintf("%s has %d departures and %d arrivals", code, outDegree[airport], inDegree[airport]),
+			Indexes: touching[airport],
+			Tickets: ticketsAt(tickets, touching[airport]),
+		})
+	}
+
+	return issues
+}
+
+func connectivityIssues(tickets [][]string, interned *internedTickets) []ValidationIssue {
+	if len(interned.edges) == 0 {
+		return nil
+	}
+
+	parent := make([]int, interned.airports())
+	for airport := range parent {
+		parent[airport] = airport
+	}
+	find := func(airport int) int {
+		for parent[airport] != airport {
+			parent[airport] = parent[parent[airport]]
+			airport = parent[airport]
+		}
+
+		return airport
+	}
+	for _, edge := range interned.edges {
+		parent[find(edge[0])] = find(edge[1])
+	}
+	if connected(interned.edges, find) {
+		return nil
+	}
+
+	components := make(map[int][]int)
+	order := []int{}
+	for k, edge := range interned.edges {
+		root := find(edge[0])
+		if _, ok := components[root]; !ok {
+			order = append(order, root)
+		}
+		components[root] = append(components[root], interned.indexes[k])
+	}
+
+	// The largest component is considered the itinerary, the rest are reported as disconnected.
+	largest := order[0]
+	for _, root := range order[1:] {
+		if len(components[root]) > len(components[largest]) {
+			largest = root
+		}
+	}
+
+	issues := []ValidationIssue{}
+	for _, root := range order {
+		if root == largest {
+			continue
+		}
+		issues = append(issues, ValidationIssue{
+			Kind:    IssueDisconnected,
+			Detail:  fmt.Sprintf("%d ticket(s) are not connected to the rest of the itinerary", len(components[root])),
+			Indexes: components[root],
+			Tickets: ticketsAt(tickets, components[root]),
+		})
+	}
+
+	return issues
+}
+
+func ticketsAt(tickets [][]string, indexes []int) [][]string {
+	out := make([][]string, 0, len(indexes))
+	for _, i := range indexes {
+		out = append(out, tickets[i])
+	}
+
+	return out
+}
+
+// connected reports whether every edge belongs to the same component.
+func connected(edges [][2]int, find func(int) int) bool {
+	root := find(edges[0][0])
+	for _, edge := range edges[1:] {
+		if find(edge[0]) != root {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,186 @@
+package dispatcher_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/dsha256/dispatcher/internal/dispatcher"
+)
+
+func TestValidateTickets(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		err     error
+		name    string
+		tickets [][]string
+		kinds   []dispatcher.IssueKind
+		indexes [][]int
+	}{
+		{
+			name:    "Valid itinerary",
+			tickets: [][]string{{"LAX", "DXB"}, {"JFK", "LAX"}, {"SFO", "SJC"}, {"DXB", "SFO"}},
+			kinds:   []dispatcher.IssueKind{},
+			indexes: [][]int{},
+		},
+		{
+			name:    "Duplicate tickets",
+			tickets: [][]string{{"JFK", "SFO"}, {"SFO", "LAX"}, {"JFK", "SFO"}},
+			err:     dispatcher.ErrMultipleSameDestination,
+			kinds:   []dispatcher.IssueKind{dispatcher.IssueDuplicateTicket, dispatcher.IssueUnbalancedDegree, dispatcher.IssueUnbalancedDegree, dispatcher.IssueUnbalancedDegree},
+			indexes: [][]int{{0, 2}, {0, 2}, {1}, {0, 1, 2}},
+		},
+		{
+			name:    "Malformed ticket",
+			tickets: [][]string{{"JFK"}, {"JFK", "SFO"}},
+			err:     dispatcher.ErrMalformedTicket,
+			kinds:   []dispatcher.IssueKind{dispatcher.IssueMalformedTicket},
+			indexes: [][]int{{0}},
+		},
+		{
+			name:    "Closed loop",
+			tickets: [][]string{{"SFO", "LAX"}, {"LAX", "JFK"}, {"JFK", "SFO"}},
+			err:     dispatcher.ErrDifferentStartingPoints,
+			kinds:   []dispatcher.IssueKind{dispatcher.IssueNoStartingPoint},
+			indexes: [][]int{{0, 1, 2}},
+		},
+		{
+			name:    "Disconnected loop",
+			tickets: [][]string{{"JFK", "LAX"}, {"LAX", "SFO"}, {"ATL", "ORD"}, {"ORD", "ATL"}},
+			err:     dispatcher.ErrDisconnectedItinerary,
+			kinds:   []dispatcher.IssueKind{dispatcher.IssueDisconnected},
+			indexes: [][]int{{2, 3}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			report := dispatcher.ValidateTickets(tt.tickets)
+			if report.Valid != (tt.err == nil) {
+				t.Fatalf("ValidateTickets(%v).Valid = %v; want %v", tt.tickets, report.Valid, tt.err == nil)
+			}
+
+			if !errors.Is(report.Err(), tt.err) {
+				t.Errorf("ValidateTickets(%v).Err() = %v; want %v", tt.tickets, report.Err(), tt.err)
+			}
+
+			kinds := []dispatcher.IssueKind{}
+			indexes := [][]int{}
+			for _, issue := range report.Issues {
+				kinds = append(kinds, issue.Kind)
+				indexes = append(indexes, issue.Indexes)
+			}
+
+			if !reflect.DeepEqual(kinds, tt.kinds) {
+				t.Errorf("ValidateTickets(%v) kinds = %v; want %v", tt.tickets, kinds, tt.kinds)
+			}
+			if !reflect.DeepEqual(indexes, tt.indexes) {
+				t.Errorf("ValidateTickets(%v) indexes = %v; want %v", tt.tickets, indexes, tt.indexes)
+			}
+
+			if tt.err != nil {
+				_, err := dispatcher.ReconstructItinerary(tt.tickets)
+
+				var validationErr *dispatcher.ValidationError
+				if !errors.As(err, &validationErr) || !errors.Is(err, tt.err) {
+					t.Errorf("ReconstructItinerary(%v) = %v; want a *ValidationError wrapping %v", tt.tickets, err, tt.err)
+				}
+			}
+		})
+	}
+}
+
+func TestDispatcherValidate(t *testing.T) {
+	t.Parallel()
+
+	allow := true
+	tests := []struct {
+		err     error
+		name    string
+		options []dispatcher.Option
+		req     dispatcher.Request
+		pairs   [][]string
+	}{
+		{
+			name: "Duplicates rejected by default",
+			req: dispatcher.Request{Tickets: []dispatcher.Ticket{
+				{From: "JFK", To: "LAX"}, {From: "LAX", To: "JFK"}, {From: "JFK", To: "LAX"},
+			}},
+			err: dispatcher.ErrMultipleSameDestination,
+		},
+		{
+			name: "Duplicates allowed by the request",
+			req: dispatcher.Request{AllowDuplicates: &allow, Tickets: []dispatcher.Ticket{
+				{From: "JFK", To: "LAX"}, {From: "LAX", To: "JFK"}, {From: "JFK", To: "LAX"},
+			}},
+			pairs: [][]string{{"JFK", "LAX"}, {"LAX", "JFK"}, {"JFK", "LAX"}},
+		},
+		{
+			name:    "Duplicates allowed by configuration",
+			options: []dispatcher.Option{dispatcher.WithDuplicateTolerance(true)},
+			req: dispatcher.Request{Tickets: []dispatcher.Ticket{
+				{From: "JFK", To: "LAX"}, {From: "LAX", To: "JFK"}, {From: "JFK", To: "LAX"},
+			}},
+			pairs: [][]string{{"JFK", "LAX"}, {"LAX", "JFK"}, {"JFK", "LAX"}},
+		},
+		{
+			name:    "Configuration ignored by version 1",
+			options: []dispatcher.Option{dispatcher.WithDuplicateTolerance(true)},
+			req: dispatcher.Request{AlgorithmVersion: "1", Tickets: []dispatcher.Ticket{
+				{From: "JFK", To: "LAX"}, {From: "LAX", To: "JFK"}, {From: "JFK", To: "LAX"},
+			}},
+			err: dispatcher.ErrMultipleSameDestination,
+		},
+		{
+			name: "Codes normalized and aliased",
+			req: dispatcher.Request{
+				NormalizeCodes: &allow,
+				Aliases:        dispatcher.Aliases{"NYC": {"JFK"}},
+				Tickets:        []dispatcher.Ticket{{From: " jfk", To: "LAX"}, {From: "LAX", To: "NYC"}, {From: "JFK", To: "SFO"}},
+			},
+			pairs: [][]string{{"NYC", "LAX"}, {"LAX", "NYC"}, {"NYC", "SFO"}},
+		},
+		{
+			name: "Surface transfers stitched",
+			req: dispatcher.Request{
+				SurfaceTransfers: []dispatcher.SurfaceTransfer{{From: "LAX", To: "SFO"}},
+				Tickets:          []dispatcher.Ticket{{From: "JFK", To: "LAX"}, {From: "SFO", To: "SEA"}},
+			},
+			pairs: [][]string{{"JFK", "LAX"}, {"SFO", "SEA"}, {"LAX", "SFO"}},
+		},
+		{
+			name: "Unsupported version",
+			req:  dispatcher.Request{AlgorithmVersion: "0", Tickets: []dispatcher.Ticket{{From: "JFK", To: "LAX"}}},
+			err:  dispatcher.ErrUnsupportedAlgorithmVersion,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			report, pairs, err := dispatcher.New(tt.options...).Validate(context.Background(), &tt.req)
+			if report == nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("Validate() error = %v; want %v", err, tt.err)
+				}
+
+				return
+			}
+			if err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+
+			if !errors.Is(report.Err(), tt.err) {
+				t.Errorf("Validate().Err() = %v; want %v", report.Err(), tt.err)
+			}
+			if tt.pairs != nil && !reflect.DeepEqual(pairs, tt.pairs) {
+				t.Errorf("Validate() pairs = %v; want %v", pairs, tt.pairs)
+			}
+		})
+	}
+}
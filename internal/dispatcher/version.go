@@ -0,0 +1,75 @@
+package dispatcher
+
+import "errors"
+
+var (
+	ErrUnsupportedAlgorithmVersion = errors.New("unsupported algorithm version")
+	ErrUnknownStability            = errors.New("unknown stability")
+)
+
+// AlgorithmVersion is the version of the reconstruction algorithm used unless a request pins another one.
+// Any change that can alter a returned path must ship as a new version, keeping the previous ones intact.
+//
+// Version "2" applies the server's defaults to the requests not overriding them: the strategy, the tie-break
+// policy, duplicate tolerance, code normalization and aliases. Version "1" predates them: it returns the
+// lexicographically smallest itinerary, rejects duplicate tickets and only rewrites the codes a request asks
+// to, unless the request itself opts in.
+const AlgorithmVersion = "2"
+
+// Stability controls whether a request may be served by a newer algorithm version.
+type Stability string
+
+const (
+	// StabilityBestEffort always uses the current AlgorithmVersion.
+	StabilityBestEffort Stability = "best_effort"
+	// StabilityStrict uses the pinned version so that re-running a stored request yields a byte-identical path.
+	StabilityStrict Stability = "strict"
+)
+
+// SupportedAlgorithmVersions lists the versions that can be pinned, oldest first.
+func SupportedAlgorithmVersions() []string {
+	return []string{"1", AlgorithmVersion}
+}
+
+// atVersion returns the dispatcher serving the requests of the algorithm version: itself for the current
+// version, and for version "1" a copy without the server defaults it predates, so pinned requests keep their
+// paths whatever the configuration. It fails with ErrUnsupportedAlgorithmVersion for unknown versions.
+func (d *Dispatcher) atVersion(version string) (*Dispatcher, error) {
+	switch version {
+	case "", AlgorithmVersion:
+		return d, nil
+	case "1":
+		v1 := *d
+		v1.defaultStrategy = StrategyDefault
+		v1.defaultTieBreak = ""
+		v1.allowDuplicates = false
+		v1.normalizeCodes = false
+		v1.aliases = nil
+
+		return &v1, nil
+	default:
+		return nil, ErrUnsupportedAlgorithmVersion
+	}
+}
+
+// ResolveAlgorithmVersion returns the algorithm version a request runs with.
+// Strict requests keep the requested version (the current one when empty); best-effort requests always get the current one.
+func ResolveAlgorithmVersion(stability Stability, requested string) (string, error) {
+	switch stability {
+	case "", StabilityBestEffort:
+		return AlgorithmVersion, nil
+	case StabilityStrict:
+		if requested == "" {
+			return AlgorithmVersion, nil
+		}
+		for _, version := range SupportedAlgorithmVersions() {
+			if version == requested {
+				return requested, nil
+			}
+		}
+
+		return "", ErrUnsupportedAlgorithmVersion
+	default:
+		return "", ErrUnknownStability
+	}
+}
@@ -0,0 +1,20 @@
+// Package embedding holds the configuration behind the options of package itinerary, so that pkg/server can
+// build the dispatcher an itinerary.Dispatcher was configured with, without either package exporting it.
+package embedding
+
+import "github.com/dsha256/dispatcher/internal/dispatcher"
+
+// Options are what the options of package itinerary configure: the dispatcher, and the requests made to it.
+type Options struct {
+	Dispatcher []dispatcher.Option
+	Request    dispatcher.Request
+}
+
+// NewDispatcher returns the dispatcher configured by the options. The strategy and tie-break policy of the
+// request become the defaults of requests not naming one; its other fields do not apply.
+func (o *Options) NewDispatcher() *dispatcher.Dispatcher {
+	return dispatcher.New(append(o.Dispatcher,
+		dispatcher.WithDefaultStrategy(o.Request.Strategy),
+		dispatcher.WithDefaultTieBreak(o.Request.TieBreak),
+	)...)
+}
@@ -0,0 +1,86 @@
+package emissions
+
+import (
+	"errors"
+	"sort"
+)
+
+var ErrInvalidCoefficients = errors.New("invalid emission coefficients")
+
+// Band is the emission factor applied to legs up to a given great-circle distance.
+type Band struct {
+	// UpToKm is the inclusive upper bound of the band; 0 means unbounded.
+	UpToKm float64 `json:"up_to_km" yaml:"up_to_km"`
+	// KgPerKm is the CO2 emitted per passenger and kilometer flown.
+	KgPerKm float64 `json:"kg_per_km" yaml:"kg_per_km"`
+}
+
+// Coefficients configure the calculator.
+type Coefficients struct {
+	// Bands are distance bands, the first band whose bound covers the leg applies.
+	Bands []Band `json:"bands" yaml:"bands"`
+	// DistanceUplift scales great-circle distances to account for routing and stacking, e.g. 1.08.
+	DistanceUplift float64 `json:"distance_uplift" yaml:"distance_uplift"`
+}
+
+// DefaultCoefficients are per-passenger economy factors in the range published by common
+// carbon reporting methodologies. Override them in configuration to match a specific methodology.
+func DefaultCoefficients() Coefficients {
+	return Coefficients{
+		Bands: []Band{
+			{UpToKm: 785, KgPerKm: 0.246},
+			{UpToKm: 3700, KgPerKm: 0.151},
+			{UpToKm: 0, KgPerKm: 0.148},
+		},
+		DistanceUplift: 1.08,
+	}
+}
+
+// Calculator estimates CO2 emissions from flown distances.
+type Calculator struct {
+	coefficients Coefficients
+}
+
+func NewCalculator(coefficients Coefficients) (*Calculator, error) {
+	if len(coefficients.Bands) == 0 {
+		return nil, ErrInvalidCoefficients
+	}
+	if coefficients.DistanceUplift == 0 {
+		coefficients.DistanceUplift = 1
+	}
+	if coefficients.DistanceUplift < 1 {
+		return nil, ErrInvalidCoefficients
+	}
+
+	bands := append([]Band(nil), coefficients.Bands...)
+	for _, band := range bands {
+		if band.UpToKm < 0 || band.KgPerKm < 0 {
+			return nil, ErrInvalidCoefficients
+		}
+	}
+	// Unbounded bands go last, bounded ones in increasing order.
+	sort.SliceStable(bands, func(i, j int) bool {
+		if bands[i].UpToKm == 0 || bands[j].UpToKm == 0 {
+			return bands[j].UpToKm == 0 && bands[i].UpToKm != 0
+		}
+
+		return bands[i].UpToKm < bands[j].UpToKm
+	})
+	coefficients.Bands = bands
+
+	return &Calculator{coefficients: coefficients}, nil
+}
+
+// LegKg estimates the CO2 in kilograms emitted per passenger for a leg of the given great-circle distance.
+func (c *Calculator) LegKg(distanceKm float64) float64 {
+	for _, band := range c.coefficients.Bands {
+		if band.UpToKm == 0 || distanceKm <= band.UpToKm {
+			return distanceKm * c.coefficients.DistanceUplift * band.KgPerKm
+		}
+	}
+
+	// Distances beyond the last bounded band use its factor.
+	last := c.coefficients.Bands[len(c.coefficients.Bands)-1]
+
+	return distanceKm * c.coefficients.DistanceUplift * last.KgPerKm
+}
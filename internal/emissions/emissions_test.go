@@ -0,0 +1,58 @@
+package emissions_test
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/dsha256/dispatcher/internal/emissions"
+)
+
+func TestCalculatorLegKg(t *testing.T) {
+	t.Parallel()
+
+	calc, err := emissions.NewCalculator(emissions.Coefficients{
+		Bands: []emissions.Band{
+			{UpToKm: 0, KgPerKm: 0.1},
+			{UpToKm: 1000, KgPerKm: 0.2},
+		},
+		DistanceUplift: 1.1,
+	})
+	if err != nil {
+		t.Fatalf("NewCalculator() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		distanceKm float64
+		expected   float64
+	}{
+		{name: "Short leg uses the bounded band", distanceKm: 500, expected: 500 * 1.1 * 0.2},
+		{name: "Band bound is inclusive", distanceKm: 1000, expected: 1000 * 1.1 * 0.2},
+		{name: "Long leg uses the unbounded band", distanceKm: 5000, expected: 5000 * 1.1 * 0.1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := calc.LegKg(tt.distanceKm); math.Abs(got-tt.expected) > 1e-9 {
+				t.Errorf("LegKg(%v) = %v; want %v", tt.distanceKm, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNewCalculatorInvalid(t *testing.T) {
+	t.Parallel()
+
+	for _, coefficients := range []emissions.Coefficients{
+		{},
+		{Bands: []emissions.Band{{KgPerKm: -1}}},
+		{Bands: []emissions.Band{{KgPerKm: 0.1}}, DistanceUplift: 0.5},
+	} {
+		if _, err := emissions.NewCalculator(coefficients); !errors.Is(err, emissions.ErrInvalidCoefficients) {
+			t.Errorf("NewCalculator(%+v) error = %v; want %v", coefficients, err, emissions.ErrInvalidCoefficients)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+// Package events publishes domain events about reconstructions, so analytics can follow them without
+// scraping access logs. Events carry the itinerary ID, sizes, durations and outcomes but no tickets.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/nats"
+)
+
+// Types of events.
+const (
+	TypeItineraryReconstructed = "ItineraryReconstructed"
+	TypeItineraryRejected      = "ItineraryRejected"
+)
+
+// Event describes a completed reconstruction request.
+type Event struct {
+	At   time.Time `json:"at"`
+	Type string    `json:"type"`
+	// ItineraryID is the fingerprint of the ticket set.
+	ItineraryID string `json:"itinerary_id"`
+	Tenant      string `json:"tenant"`
+	Strategy    string `json:"strategy,omitempty"`
+	// ErrorCode classifies why the itinerary was rejected, e.g. "CYCLE"; empty when reconstructed.
+	ErrorCode string `json:"error_code,omitempty"`
+	// Tickets is the number of tickets in the request.
+	Tickets  int           `json:"tickets"`
+	Duration time.Duration `json:"duration_ns"`
+}
+
+// Publisher publishes events. Implementations are safe for concurrent use.
+type Publisher interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+// Log publishes events as log records, for collectors already shipping the service logs.
+type Log struct {
+	logger *slog.Logger
+}
+
+func NewLog(logger *slog.Logger) *Log {
+	return &Log{logger: logger}
+}
+
+func (l *Log) Publish(ctx context.Context, event Event) error {
+	l.logger.InfoContext(ctx, "Domain event",
+		"type", event.Type,
+		"at", event.At,
+		"itinerary_id", event.ItineraryID,
+		"tenant", event.Tenant,
+		"strategy", event.Strategy,
+		"error_code", event.ErrorCode,
+		"tickets", event.Tickets,
+		"duration", event.Duration,
+	)
+
+	return nil
+}
+
+// NATS publishes events as JSON on a subject, with their type in the Event-Type header.
+type NATS struct {
+	client  *nats.Client
+	subject string
+}
+
+func NewNATS(client *nats.Client, subject string) *NATS {
+	return &NATS{client: client, subject: subject}
+}
+
+func (n *NATS) Publish(_ context.Context, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("encoding event: %w", err)
+	}
+
+	return n.client.Publish(n.subject, http.Header{"Event-Type": {event.Type}}, data)
+}
@@ -0,0 +1,73 @@
+// Package export renders reconstructed itineraries in the formats other tools import: iCalendar files with an
+// event per leg for calendars, GeoJSON and KML lines through the airports of the path for maps, and plain text
+// and Markdown to paste into tickets and chats.
+package export
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/airports"
+)
+
+// Itinerary is a reconstructed itinerary to render.
+type Itinerary struct {
+	// ID is the itinerary ID, the fingerprint of its tickets.
+	ID string
+	// TotalPrice is the sum of the prices of the tickets, nil when none has a price.
+	TotalPrice *float64
+	Path       []string
+	Legs       []Leg
+}
+
+// Leg is a flight of the itinerary, with the times and flight number of its ticket when it has them.
+type Leg struct {
+	DepartsAt *time.Time
+	ArrivesAt *time.Time
+	From      string
+	To        string
+	FlightNo  string
+}
+
+// route returns the leg's airports joined with an arrow and its flight number, e.g. "JFK → LAX (AA1)".
+func (l Leg) route() string {
+	route := l.From + " → " + l.To
+	if l.FlightNo != "" {
+		route += " (" + l.FlightNo + ")"
+	}
+
+	return route
+}
+
+// describeAirport returns the code of the airport with its name and city when the directory knows it, e.g.
+// "JFK, John F. Kennedy International Airport, New York".
+func describeAirport(directory *airports.Directory, code string) string {
+	airport, ok := directory.Lookup(code)
+	if !ok {
+		return code
+	}
+	parts := []string{code}
+	for _, part := range []string{airport.Name, airport.City} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// formatDuration formats the duration in hours and minutes, e.g. "2h 05m", or minutes under an hour.
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	sign := ""
+	if d < 0 {
+		sign, d = "-", -d
+	}
+	hours, minutes := int(d/time.Hour), int(d%time.Hour/time.Minute)
+	if hours == 0 {
+		return fmt.Sprintf("%s%dm", sign, minutes)
+	}
+
+	return fmt.Sprintf("%s%dh %02dm", sign, hours, minutes)
+}
@@ -0,0 +1,180 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/dsha256/dispatcher/internal/airports"
+)
+
+var ErrNoCoordinates = errors.New("fewer than two airports of the path have known coordinates")
+
+// Media types of the map exports.
+const (
+	MediaTypeGeoJSON = "application/geo+json"
+	MediaTypeKML     = "application/vnd.google-earth.kml+xml"
+)
+
+// stop is an airport of the path with known coordinates.
+type stop struct {
+	code    string
+	airport airports.Airport
+	index   int
+}
+
+// locate returns the airports of the path the directory knows, with their index in the path. Fewer than two
+// fail with ErrNoCoordinates, as they do not make a line.
+func locate(itinerary Itinerary, directory *airports.Directory) ([]stop, error) {
+	stops := make([]stop, 0, len(itinerary.Path))
+	for i, code := range itinerary.Path {
+		if airport, ok := directory.Lookup(code); ok {
+			stops = append(stops, stop{airport: airport, code: code, index: i})
+		}
+	}
+	if len(stops) < 2 {
+		return nil, ErrNoCoordinates
+	}
+
+	return stops, nil
+}
+
+type geoJSONCollection struct {
+	Type     string           `json:"type"`
+	Features []geoJSONFeature `json:"features"`
+}
+
+type geoJSONFeature struct {
+	Properties map[string]any  `json:"properties"`
+	Geometry   geoJSONGeometry `json:"geometry"`
+	Type       string          `json:"type"`
+}
+
+type geoJSONGeometry struct {
+	Coordinates any    `json:"coordinates"`
+	Type        string `json:"type"`
+}
+
+// GeoJSON renders the itinerary as a GeoJSON FeatureCollection (RFC 7946): a LineString through the airports
+// of the path, then a Point per airport. Airports the directory does not know are left out of both; the
+// LineString's complete property tells whether any was. Fewer than two known airports fail with
+// ErrNoCoordinates.
+func GeoJSON(itinerary Itinerary, directory *airports.Directory) ([]byte, error) {
+	stops, err := locate(itinerary, directory)
+	if err != nil {
+		return nil, err
+	}
+
+	line := make([][2]float64, 0, len(stops))
+	points := make([]geoJSONFeature, 0, len(stops))
+	for _, s := range stops {
+		position := [2]float64{s.airport.Longitude, s.airport.Latitude}
+		line = append(line, position)
+		points = append(points, geoJSONFeature{
+			Type:     "Feature",
+			Geometry: geoJSONGeometry{Type: "Point", Coordinates: position},
+			Properties: map[string]any{
+				"code":  s.code,
+				"name":  s.airport.Name,
+				"city":  s.airport.City,
+				"index": s.index,
+			},
+		})
+	}
+
+	return json.Marshal(geoJSONCollection{
+		Type: "FeatureCollection",
+		Features: append([]geoJSONFeature{{
+			Type:     "Feature",
+			Geometry: geoJSONGeometry{Type: "LineString", Coordinates: line},
+			Properties: map[string]any{
+				"itinerary_id": itinerary.ID,
+				"path":         itinerary.Path,
+				"complete":     len(stops) == len(itinerary.Path),
+			},
+		}}, points...),
+	})
+}
+
+// KML renders the itinerary as a KML document: a LineString placemark through the airports of the path, then
+// a Point placemark per airport. Airports the directory does not know are left out of both; fewer than two
+// known airports fail with ErrNoCoordinates.
+func KML(itinerary Itinerary, directory *airports.Directory) ([]byte, error) {
+	stops, err := locate(itinerary, directory)
+	if err != nil {
+		return nil, err
+	}
+
+	line := make([]string, 0, len(stops))
+	for _, s := range stops {
+		line = append(line, kmlPosition(s.airport))
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	k := kmlEncoder{Encoder: xml.NewEncoder(&buf)}
+	k.Indent("", "  ")
+	k.start("kml", xml.Attr{Name: xml.Name{Local: "xmlns"}, Value: "http://www.opengis.net/kml/2.2"})
+	k.start("Document")
+	name := strings.Join(itinerary.Path, " → ")
+	k.text("name", name)
+	k.placemark(name, "", "LineString", strings.Join(line, " "))
+	for i, s := range stops {
+		k.placemark(s.code, describeAirport(directory, s.code), "Point", line[i])
+	}
+	k.end("Document")
+	k.end("kml")
+	if err = k.Flush(); err != nil {
+		return nil, err
+	}
+	if k.err != nil {
+		return nil, k.err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// kmlEncoder writes KML elements in the order the schema requires, keeping the first error.
+type kmlEncoder struct {
+	err error
+	*xml.Encoder
+}
+
+func (k *kmlEncoder) start(name string, attrs ...xml.Attr) {
+	if k.err == nil {
+		k.err = k.EncodeToken(xml.StartElement{Name: xml.Name{Local: name}, Attr: attrs})
+	}
+}
+
+func (k *kmlEncoder) end(name string) {
+	if k.err == nil {
+		k.err = k.EncodeToken(xml.EndElement{Name: xml.Name{Local: name}})
+	}
+}
+
+func (k *kmlEncoder) text(name, value string) {
+	if k.err == nil {
+		k.err = k.EncodeElement(value, xml.StartElement{Name: xml.Name{Local: name}})
+	}
+}
+
+// placemark writes a placemark with the geometry, e.g. "Point", at the coordinates.
+func (k *kmlEncoder) placemark(name, description, geometry, coordinates string) {
+	k.start("Placemark")
+	k.text("name", name)
+	if description != "" {
+		k.text("description", description)
+	}
+	k.start(geometry)
+	k.text("coordinates", coordinates)
+	k.end(geometry)
+	k.end("Placemark")
+}
+
+// kmlPosition returns the KML coordinates of the airport, longitude first.
+func kmlPosition(airport airports.Airport) string {
+	return strconv.FormatFloat(airport.Longitude, 'f', -1, 64) + "," + strconv.FormatFloat(airport.Latitude, 'f', -1, 64)
+}
@@ -0,0 +1,101 @@
+package export_test
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dsha256/dispatcher/internal/airports"
+	"github.com/dsha256/dispatcher/internal/export"
+)
+
+func TestGeoJSON(t *testing.T) {
+	t.Parallel()
+
+	itinerary := export.Itinerary{ID: "abc", Path: []string{"JFK", "ZZZ", "LAX"}}
+	data, err := export.GeoJSON(itinerary, airports.Default())
+	if err != nil {
+		t.Fatalf("Failed to render: %v", err)
+	}
+
+	var collection struct {
+		Type     string `json:"type"`
+		Features []struct {
+			Properties map[string]any `json:"properties"`
+			Geometry   struct {
+				Type        string          `json:"type"`
+				Coordinates json.RawMessage `json:"coordinates"`
+			} `json:"geometry"`
+		} `json:"features"`
+	}
+	if err = json.Unmarshal(data, &collection); err != nil {
+		t.Fatalf("Failed to decode %s: %v", data, err)
+	}
+	if collection.Type != "FeatureCollection" || len(collection.Features) != 3 {
+		t.Fatalf("Expected a collection of the line and 2 points, got %s", data)
+	}
+
+	line := collection.Features[0]
+	var coordinates [][2]float64
+	if err = json.Unmarshal(line.Geometry.Coordinates, &coordinates); err != nil {
+		t.Fatalf("Failed to decode the line: %v", err)
+	}
+	jfk, _ := airports.Default().Lookup("JFK")
+	if line.Geometry.Type != "LineString" || len(coordinates) != 2 || coordinates[0] != [2]float64{jfk.Longitude, jfk.Latitude} {
+		t.Errorf("Expected a line through JFK and LAX, longitude first, got %s", line.Geometry.Coordinates)
+	}
+	if line.Properties["complete"] != false || line.Properties["itinerary_id"] != "abc" {
+		t.Errorf("Expected an incomplete line of the itinerary, got %v", line.Properties)
+	}
+	if point := collection.Features[2]; point.Geometry.Type != "Point" || point.Properties["code"] != "LAX" || point.Properties["index"] != 2.0 {
+		t.Errorf("Expected LAX at index 2, got %v", point.Properties)
+	}
+}
+
+func TestKML(t *testing.T) {
+	t.Parallel()
+
+	itinerary := export.Itinerary{ID: "abc", Path: []string{"JFK", "LAX", "DXB"}}
+	data, err := export.KML(itinerary, airports.Default())
+	if err != nil {
+		t.Fatalf("Failed to render: %v", err)
+	}
+
+	var doc struct {
+		XMLName  xml.Name `xml:"http://www.opengis.net/kml/2.2 kml"`
+		Document struct {
+			Name       string `xml:"name"`
+			Placemarks []struct {
+				Name       string `xml:"name"`
+				LineString string `xml:"LineString>coordinates"`
+				Point      string `xml:"Point>coordinates"`
+			} `xml:"Placemark"`
+		} `xml:"Document"`
+	}
+	if err = xml.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("Failed to decode %s: %v", data, err)
+	}
+	if doc.Document.Name != "JFK → LAX → DXB" || len(doc.Document.Placemarks) != 4 {
+		t.Fatalf("Expected the line and 3 points, got %s", data)
+	}
+	if got := strings.Fields(doc.Document.Placemarks[0].LineString); len(got) != 3 {
+		t.Errorf("Expected the line through 3 airports, got %q", got)
+	}
+	if p := doc.Document.Placemarks[1]; p.Name != "JFK" || p.Point == "" {
+		t.Errorf("Expected the point of JFK, got %+v", p)
+	}
+}
+
+func TestGeoWithoutCoordinates(t *testing.T) {
+	t.Parallel()
+
+	itinerary := export.Itinerary{ID: "abc", Path: []string{"JFK", "ZZZ"}}
+	if _, err := export.GeoJSON(itinerary, airports.Default()); !errors.Is(err, export.ErrNoCoordinates) {
+		t.Errorf("Expected ErrNoCoordinates, got %v", err)
+	}
+	if _, err := export.KML(itinerary, airports.Default()); !errors.Is(err, export.ErrNoCoordinates) {
+		t.Errorf("Expected ErrNoCoordinates, got %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package export
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dsha256/dispatcher/internal/airports"
+)
+
+var ErrNoDepartureTimes = errors.New("no ticket has a departure time to put in a calendar")
+
+// MediaTypeICS is the media type of iCalendar files.
+const MediaTypeICS = "text/calendar"
+
+const (
+	icsTimeFormat = "20060102T150405Z"
+	// icsLineOctets is the length lines are folded at, in octets, CRLF aside.
+	icsLineOctets = 75
+)
+
+// ICS renders the itinerary as an iCalendar file (RFC 5545) with an event per leg whose ticket has a
+// departure time, from departure to arrival, located at the departure airport. Descriptions name both
+// airports, as the directory knows them, and the layover before the next leg when both times are known.
+// Legs without a departure time are left out; none having one fails with ErrNoDepartureTimes. now stamps
+// the events.
+func ICS(itinerary Itinerary, directory *airports.Directory, now time.Time) ([]byte, error) {
+	var b icsBuilder
+	b.line("BEGIN", "VCALENDAR")
+	b.line("VERSION", "2.0")
+	b.line("PRODID", "-//dispatcher//itinerary//EN")
+	b.line("CALSCALE", "GREGORIAN")
+	b.line("METHOD", "PUBLISH")
+	b.line("X-WR-CALNAME", escapeText(strings.Join(itinerary.Path, " → ")))
+
+	events := 0
+	for i, leg := range itinerary.Legs {
+		if leg.DepartsAt == nil {
+			continue
+		}
+		events++
+
+		b.line("BEGIN", "VEVENT")
+		b.line("UID", itinerary.ID+"-"+strconv.Itoa(i)+"@dispatcher")
+		b.line("DTSTAMP", now.UTC().Format(icsTimeFormat))
+		b.line("DTSTART", leg.DepartsAt.UTC().Format(icsTimeFormat))
+		if leg.ArrivesAt != nil {
+			b.line("DTEND", leg.ArrivesAt.UTC().Format(icsTimeFormat))
+		}
+		b.line("SUMMARY", escapeText(leg.route()))
+		b.line("LOCATION", escapeText(describeAirport(directory, leg.From)))
+		if airport, ok := directory.Lookup(leg.From); ok {
+			b.line("GEO", strconv.FormatFloat(airport.Latitude, 'f', -1, 64)+";"+strconv.FormatFloat(airport.Longitude, 'f', -1, 64))
+		}
+		b.line("DESCRIPTION", escapeText(describeLeg(directory, itinerary.Legs, i)))
+		b.line("END", "VEVENT")
+	}
+	if events == 0 {
+		return nil, ErrNoDepartureTimes
+	}
+	b.line("END", "VCALENDAR")
+
+	return []byte(b.String()), nil
+}
+
+// describeLeg describes the leg at i for its event: its airports, and the layover before the next leg.
+func describeLeg(directory *airports.Directory, legs []Leg, i int) string {
+	leg := legs[i]
+	lines := []string{
+		"From: " + describeAirport(directory, leg.From),
+		"To: " + describeAirport(directory, leg.To),
+	}
+	if leg.FlightNo != "" {
+		lines = append(lines, "Flight: "+leg.FlightNo)
+	}
+	if layover, ok := layoverAfter(legs, i); ok {
+		lines = append(lines, "Layover at "+leg.To+": "+formatDuration(layover)+" before "+legs[i+1].route())
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// escapeText escapes the value of a TEXT property.
+func escapeText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// icsBuilder writes content lines with CRLF endings, folded at 75 octets without splitting characters.
+type icsBuilder struct {
+	strings.Builder
+}
+
+func (b *icsBuilder) line(name, value string) {
+	line, limit := name+":"+value, icsLineOctets
+	for len(line) > limit {
+		cut := limit
+		for !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut] + "\r\n ")
+		// Continuation lines start with a space, which counts towards their length.
+		line, limit = line[cut:], icsLineOctets-1
+	}
+	b.WriteString(line + "\r\n")
+}
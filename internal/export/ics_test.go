@@ -0,0 +1,85 @@
+package export_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/airports"
+	"github.com/dsha256/dispatcher/internal/export"
+)
+
+func at(value string) *time.Time {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		panic(err)
+	}
+
+	return &t
+}
+
+func TestICS(t *testing.T) {
+	t.Parallel()
+
+	itinerary := export.Itinerary{
+		ID:   "abc",
+		Path: []string{"JFK", "LAX", "DXB"},
+		Legs: []export.Leg{
+			{From: "JFK", To: "LAX", FlightNo: "AA1", DepartsAt: at("2026-05-01T08:00:00-04:00"), ArrivesAt: at("2026-05-01T11:30:00-07:00")},
+			{From: "LAX", To: "DXB", DepartsAt: at("2026-05-01T20:05:00Z")},
+		},
+	}
+	data, err := export.ICS(itinerary, airports.Default(), time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Failed to render: %v", err)
+	}
+	ics := string(data)
+
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\nVERSION:2.0\r\n",
+		"X-WR-CALNAME:JFK → LAX → DXB\r\n",
+		"UID:abc-0@dispatcher\r\nDTSTAMP:20260401T000000Z\r\nDTSTART:20260501T120000Z\r\nDTEND:20260501T183000Z\r\nSUMMARY:JFK → LAX (AA1)\r\n",
+		"UID:abc-1@dispatcher\r\nDTSTAMP:20260401T000000Z\r\nDTSTART:20260501T200500Z\r\nSUMMARY:LAX → DXB\r\n",
+		`Layover at LAX: 1h 35m before LAX → DXB`,
+		"END:VEVENT\r\nEND:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(strings.ReplaceAll(ics, "\r\n ", ""), want) {
+			t.Errorf("Expected the calendar to contain %q, got\n%s", want, ics)
+		}
+	}
+	if strings.Count(ics, "BEGIN:VEVENT") != 2 {
+		t.Errorf("Expected an event per leg, got\n%s", ics)
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(ics, "\r\n"), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("Expected lines folded at 75 octets, got %d: %q", len(line), line)
+		}
+	}
+}
+
+func TestICSEscaping(t *testing.T) {
+	t.Parallel()
+
+	itinerary := export.Itinerary{
+		ID:   "abc",
+		Path: []string{"A;B", "C,D"},
+		Legs: []export.Leg{{From: "A;B", To: "C,D", DepartsAt: at("2026-05-01T08:00:00Z")}},
+	}
+	data, err := export.ICS(itinerary, airports.Default(), time.Now())
+	if err != nil {
+		t.Fatalf("Failed to render: %v", err)
+	}
+	if want := `SUMMARY:A\;B → C\,D`; !strings.Contains(string(data), want) {
+		t.Errorf("Expected %q, got\n%s", want, data)
+	}
+}
+
+func TestICSWithoutTimes(t *testing.T) {
+	t.Parallel()
+
+	itinerary := export.Itinerary{ID: "abc", Path: []string{"JFK", "LAX"}, Legs: []export.Leg{{From: "JFK", To: "LAX"}}}
+	if _, err := export.ICS(itinerary, airports.Default(), time.Now()); !errors.Is(err, export.ErrNoDepartureTimes) {
+		t.Errorf("Expected ErrNoDepartureTimes, got %v", err)
+	}
+}
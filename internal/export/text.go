@@ -0,0 +1,137 @@
+package export
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/airports"
+)
+
+// Media types of the human-readable renderings.
+const (
+	MediaTypeText     = "text/plain"
+	MediaTypeMarkdown = "text/markdown"
+)
+
+// textTimeFormat formats the times of legs, in UTC.
+const textTimeFormat = "2006-01-02 15:04 UTC"
+
+// Text renders the itinerary as plain text to paste into tickets and chats: the headline (see Headline), then
+// a line per leg with its flight number and times, the layover before the next leg when both times are known,
+// and the total price when tickets have prices.
+//
+//	JFK → LAX → DXB, 2 legs, 1 stop in Los Angeles
+//
+//	1. JFK → LAX (AA1), departs 2026-05-01 12:00 UTC, arrives 2026-05-01 18:30 UTC
+//	   Layover in Los Angeles (LAX): 1h 35m
+//	2. LAX → DXB, departs 2026-05-01 20:05 UTC
+func Text(itinerary Itinerary, directory *airports.Directory) []byte {
+	var b strings.Builder
+	b.WriteString(Headline(itinerary, directory) + "\n\n")
+	for i, leg := range itinerary.Legs {
+		b.WriteString(strconv.Itoa(i+1) + ". " + leg.route())
+		if leg.DepartsAt != nil {
+			b.WriteString(", departs " + leg.DepartsAt.UTC().Format(textTimeFormat))
+		}
+		if leg.ArrivesAt != nil {
+			b.WriteString(", arrives " + leg.ArrivesAt.UTC().Format(textTimeFormat))
+		}
+		b.WriteString("\n")
+		if layover, ok := layoverAfter(itinerary.Legs, i); ok {
+			b.WriteString("   Layover in " + placeName(directory, leg.To) + " (" + leg.To + "): " + formatDuration(layover) + "\n")
+		}
+	}
+	if itinerary.TotalPrice != nil {
+		b.WriteString("\nTotal price: " + formatPrice(*itinerary.TotalPrice) + "\n")
+	}
+
+	return []byte(b.String())
+}
+
+// Markdown renders the itinerary as Markdown: the headline in bold, then a table of the legs and the total
+// price when tickets have prices.
+func Markdown(itinerary Itinerary, directory *airports.Directory) []byte {
+	var b strings.Builder
+	b.WriteString("**" + markdownEscape(strings.Join(itinerary.Path, " → ")) + "**, " + markdownEscape(legsAndStops(itinerary, directory)))
+	b.WriteString("\n\n| # | Flight | From | To | Departs | Arrives | Layover |\n|---|---|---|---|---|---|---|\n")
+	for i, leg := range itinerary.Legs {
+		cells := []string{strconv.Itoa(i + 1), leg.FlightNo, leg.From, leg.To, "", "", ""}
+		if leg.DepartsAt != nil {
+			cells[4] = leg.DepartsAt.UTC().Format(textTimeFormat)
+		}
+		if leg.ArrivesAt != nil {
+			cells[5] = leg.ArrivesAt.UTC().Format(textTimeFormat)
+		}
+		if layover, ok := layoverAfter(itinerary.Legs, i); ok {
+			cells[6] = formatDuration(layover)
+		}
+		for j, cell := range cells {
+			cells[j] = markdownEscape(cell)
+		}
+		b.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+	}
+	if itinerary.TotalPrice != nil {
+		b.WriteString("\n**Total price:** " + formatPrice(*itinerary.TotalPrice) + "\n")
+	}
+
+	return []byte(b.String())
+}
+
+// Headline summarizes the itinerary in a line: its path, number of legs and its stops, by city when the
+// directory knows them, e.g. "JFK → LAX → DXB → SFO, 3 legs, 2 stops in Los Angeles, Dubai".
+func Headline(itinerary Itinerary, directory *airports.Directory) string {
+	return strings.Join(itinerary.Path, " → ") + ", " + legsAndStops(itinerary, directory)
+}
+
+// legsAndStops counts the legs and names the stops of the itinerary, e.g. "2 legs, 1 stop in Los Angeles".
+func legsAndStops(itinerary Itinerary, directory *airports.Directory) string {
+	legs := strconv.Itoa(len(itinerary.Legs)) + " " + plural(len(itinerary.Legs), "leg", "legs")
+	if len(itinerary.Path) < 3 {
+		return legs + ", nonstop"
+	}
+
+	stops := itinerary.Path[1 : len(itinerary.Path)-1]
+	places := make([]string, 0, len(stops))
+	for _, code := range stops {
+		places = append(places, placeName(directory, code))
+	}
+
+	return legs + ", " + strconv.Itoa(len(stops)) + " " + plural(len(stops), "stop", "stops") + " in " + strings.Join(places, ", ")
+}
+
+// layoverAfter returns the layover between the leg at i and the next one, when both times are known.
+func layoverAfter(legs []Leg, i int) (time.Duration, bool) {
+	if i+1 >= len(legs) || legs[i].ArrivesAt == nil || legs[i+1].DepartsAt == nil {
+		return 0, false
+	}
+
+	return legs[i+1].DepartsAt.Sub(*legs[i].ArrivesAt), true
+}
+
+// placeName returns the city of the airport, or its code when the directory does not know it.
+func placeName(directory *airports.Directory, code string) string {
+	if airport, ok := directory.Lookup(code); ok && airport.City != "" {
+		return airport.City
+	}
+
+	return code
+}
+
+func plural(n int, singular, pluralForm string) string {
+	if n == 1 {
+		return singular
+	}
+
+	return pluralForm
+}
+
+// formatPrice formats the price with two decimals.
+func formatPrice(price float64) string {
+	return strconv.FormatFloat(price, 'f', 2, 64)
+}
+
+// markdownEscape escapes the characters that would end a table cell or start emphasis.
+func markdownEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "|", `\|`, "*", `\*`, "_", `\_`).Replace(s)
+}
@@ -0,0 +1,70 @@
+package export_test
+
+import (
+	"testing"
+
+	"github.com/dsha256/dispatcher/internal/airports"
+	"github.com/dsha256/dispatcher/internal/export"
+)
+
+func TestText(t *testing.T) {
+	t.Parallel()
+
+	price := 620.0
+	itinerary := export.Itinerary{
+		ID:         "abc",
+		TotalPrice: &price,
+		Path:       []string{"JFK", "LAX", "DXB"},
+		Legs: []export.Leg{
+			{From: "JFK", To: "LAX", FlightNo: "AA1", DepartsAt: at("2026-05-01T12:00:00Z"), ArrivesAt: at("2026-05-01T18:30:00Z")},
+			{From: "LAX", To: "DXB", DepartsAt: at("2026-05-01T20:05:00Z")},
+		},
+	}
+
+	want := "JFK → LAX → DXB, 2 legs, 1 stop in Los Angeles\n\n" +
+		"1. JFK → LAX (AA1), departs 2026-05-01 12:00 UTC, arrives 2026-05-01 18:30 UTC\n" +
+		"   Layover in Los Angeles (LAX): 1h 35m\n" +
+		"2. LAX → DXB, departs 2026-05-01 20:05 UTC\n\n" +
+		"Total price: 620.00\n"
+	if got := string(export.Text(itinerary, airports.Default())); got != want {
+		t.Errorf("Expected\n%s\ngot\n%s", want, got)
+	}
+
+	want = "**JFK → LAX → DXB**, 2 legs, 1 stop in Los Angeles\n\n" +
+		"| # | Flight | From | To | Departs | Arrives | Layover |\n|---|---|---|---|---|---|---|\n" +
+		"| 1 | AA1 | JFK | LAX | 2026-05-01 12:00 UTC | 2026-05-01 18:30 UTC | 1h 35m |\n" +
+		"| 2 |  | LAX | DXB | 2026-05-01 20:05 UTC |  |  |\n\n" +
+		"**Total price:** 620.00\n"
+	if got := string(export.Markdown(itinerary, airports.Default())); got != want {
+		t.Errorf("Expected\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestHeadline(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		want string
+		path []string
+	}{
+		{name: "Nonstop", path: []string{"JFK", "LAX"}, want: "JFK → LAX, 1 leg, nonstop"},
+		{
+			name: "Stops",
+			path: []string{"JFK", "LAX", "DXB", "SFO", "SJC"},
+			want: "JFK → LAX → DXB → SFO → SJC, 4 legs, 3 stops in Los Angeles, Dubai, San Francisco",
+		},
+		{name: "Unknown airport", path: []string{"JFK", "ZZZ", "LAX"}, want: "JFK → ZZZ → LAX, 2 legs, 1 stop in ZZZ"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			itinerary := export.Itinerary{Path: tt.path, Legs: make([]export.Leg, len(tt.path)-1)}
+			if got := export.Headline(itinerary, airports.Default()); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
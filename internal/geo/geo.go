@@ -0,0 +1,50 @@
+package geo
+
+import "math"
+
+const (
+	// EarthRadiusKm is the mean Earth radius used by the haversine formula.
+	EarthRadiusKm = 6371.0088
+	// KmPerMile converts statute miles to kilometers.
+	KmPerMile = 1.609344
+	// KmPerNauticalMile converts nautical miles to kilometers.
+	KmPerNauticalMile = 1.852
+)
+
+// Point is a position given in decimal degrees.
+type Point struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// DistanceKm returns the great-circle distance between two points in kilometers, using the haversine formula.
+func DistanceKm(a, b Point) float64 {
+	lat1, lat2 := radians(a.Latitude), radians(b.Latitude)
+	dLat := lat2 - lat1
+	dLon := radians(b.Longitude - a.Longitude)
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	return 2 * EarthRadiusKm * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// KmToMiles converts kilometers to statute miles.
+func KmToMiles(km float64) float64 {
+	return km / KmPerMile
+}
+
+// KmToNauticalMiles converts kilometers to nautical miles.
+func KmToNauticalMiles(km float64) float64 {
+	return km / KmPerNauticalMile
+}
+
+// Round rounds v to the given number of decimals.
+func Round(v float64, decimals int) float64 {
+	pow := math.Pow(10, float64(decimals))
+
+	return math.Round(v*pow) / pow
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
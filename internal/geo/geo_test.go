@@ -0,0 +1,52 @@
+package geo_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/dsha256/dispatcher/internal/geo"
+)
+
+func TestDistanceKm(t *testing.T) {
+	t.Parallel()
+
+	jfk := geo.Point{Latitude: 40.6413, Longitude: -73.7781}
+	lhr := geo.Point{Latitude: 51.4700, Longitude: -0.4543}
+	syd := geo.Point{Latitude: -33.9399, Longitude: 151.1753}
+	lax := geo.Point{Latitude: 33.9416, Longitude: -118.4085}
+
+	tests := []struct {
+		name     string
+		a, b     geo.Point
+		expected float64
+	}{
+		{name: "Same point", a: jfk, b: jfk, expected: 0},
+		{name: "JFK to LHR", a: jfk, b: lhr, expected: 5540},
+		{name: "LHR to JFK is symmetric", a: lhr, b: jfk, expected: 5540},
+		{name: "LAX to SYD crosses the equator and the antimeridian", a: lax, b: syd, expected: 12051},
+		{name: "Antipodes", a: geo.Point{Latitude: 0, Longitude: 0}, b: geo.Point{Latitude: 0, Longitude: 180}, expected: math.Pi * geo.EarthRadiusKm},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Published great-circle distances are rounded, allow 0.5%.
+			got := geo.DistanceKm(tt.a, tt.b)
+			if math.Abs(got-tt.expected) > tt.expected*0.005+1e-9 {
+				t.Errorf("DistanceKm() = %.1f; want %.1f", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestConversions(t *testing.T) {
+	t.Parallel()
+
+	if got := geo.Round(geo.KmToMiles(1609.344), 3); got != 1000 {
+		t.Errorf("KmToMiles(1609.344) = %v; want 1000", got)
+	}
+	if got := geo.Round(geo.KmToNauticalMiles(1852), 3); got != 1000 {
+		t.Errorf("KmToNauticalMiles(1852) = %v; want 1000", got)
+	}
+}
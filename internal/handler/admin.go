@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/dsha256/dispatcher/internal/limits"
+)
+
+var (
+	ErrMaintenance    = errors.New("the service is in maintenance, retry later")
+	ErrConfigDisabled = errors.New("the effective configuration is not available")
+	ErrLimitsDisabled = errors.New("request size limits are disabled")
+)
+
+// dispatcherPath prefixes the routes served to clients, which maintenance mode closes.
+const dispatcherPath = "/api/v1/dispatcher/"
+
+// MaintenanceRequest switches maintenance mode on or off, e.g. {"enabled": true}.
+type MaintenanceRequest struct {
+	Enabled bool `json:"enabled"`
+}
+
+// MaintenanceResponse is whether the service is in maintenance.
+type MaintenanceResponse struct {
+	Enabled bool `json:"enabled"`
+}
+
+// CacheFlushResponse is the number of cached results flushed.
+type CacheFlushResponse struct {
+	Flushed int `json:"flushed"`
+}
+
+// WithAdminReadToken authenticates the requests only reading admin routes, e.g. the effective configuration,
+// with the bearer token; the admin token reads them too. Without a read token, they require the admin token.
+func WithAdminReadToken(token string) Option {
+	return func(h *Handler) {
+		h.adminReadToken = token
+	}
+}
+
+// WithMaintenance starts the handler in maintenance mode when enabled.
+func WithMaintenance(enabled bool) Option {
+	return func(h *Handler) {
+		h.maintenance.Store(enabled)
+	}
+}
+
+// SetMaintenance switches maintenance mode on or off, e.g. when the configuration is reloaded, and returns
+// whether it was on.
+func (h *Handler) SetMaintenance(enabled bool) bool {
+	return h.maintenance.Swap(enabled)
+}
+
+// maintain answers the requests of client routes with 503 Service Unavailable while the service is in
+// maintenance; admin, health and discovery routes keep being served.
+func (h *Handler) maintain(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.maintenance.Load() {
+			h.handleError(w, r, ErrMaintenance, http.StatusServiceUnavailable)
+
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// inMaintenance reports whether maintenance mode closes the route.
+func (rt route) inMaintenance() bool {
+	return strings.HasPrefix(rt.path, dispatcherPath)
+}
+
+func (h *Handler) handleConfig(w http.ResponseWriter, r *http.Request) {
+	effective, err := h.bundler.EffectiveConfig()
+	if err != nil {
+		h.handleError(w, r, err, http.StatusInternalServerError)
+
+		return
+	}
+	if effective == nil {
+		h.handleError(w, r, ErrConfigDisabled, http.StatusNotFound)
+
+		return
+	}
+	h.writeSuccess(w, r, effective, nil)
+}
+
+func (h *Handler) handleMaintenance(w http.ResponseWriter, r *http.Request) {
+	h.writeSuccess(w, r, MaintenanceResponse{Enabled: h.maintenance.Load()}, nil)
+}
+
+func (h *Handler) handlePutMaintenance(w http.ResponseWriter, r *http.Request) {
+	var req MaintenanceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		status, bodyErr := bodyError(err)
+		h.handleError(w, r, bodyErr, status)
+
+		return
+	}
+
+	previous := h.SetMaintenance(req.Enabled)
+	h.logger.WarnContext(r.Context(), "Maintenance mode changed", "from", previous, "to", req.Enabled, "remote_addr", r.RemoteAddr)
+	h.writeSuccess(w, r, MaintenanceResponse{Enabled: req.Enabled}, nil)
+}
+
+func (h *Handler) handleFlushCache(w http.ResponseWriter, r *http.Request) {
+	flushed := h.cache.Flush()
+	h.logger.WarnContext(r.Context(), "Cache flushed", "entries", flushed, "remote_addr", r.RemoteAddr)
+	h.writeSuccess(w, r, CacheFlushResponse{Flushed: flushed}, nil)
+}
+
+func (h *Handler) handlePutLimits(w http.ResponseWriter, r *http.Request) {
+	if h.limits == nil {
+		h.handleError(w, r, ErrLimitsDisabled, http.StatusNotFound)
+
+		return
+	}
+	var req limits.Limits
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		status, bodyErr := bodyError(err)
+		h.handleError(w, r, bodyErr, status)
+
+		return
+	}
+
+	previous := h.limits.Limits()
+	if err := h.limits.SetLimits(req); err != nil {
+		h.handleError(w, r, err, http.StatusBadRequest)
+
+		return
+	}
+	h.logger.WarnContext(r.Context(), "Request limits changed", "from", previous, "to", req, "remote_addr", r.RemoteAddr)
+	h.writeSuccess(w, r, req, nil)
+}
+
+func (h *Handler) handleJobs(w http.ResponseWriter, r *http.Request) {
+	h.writeSuccess(w, r, h.jobs.Stats(r.Context()), nil)
+}
@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/audit"
+	"github.com/dsha256/dispatcher/internal/inspector"
+	"github.com/dsha256/dispatcher/internal/middleware"
+	"github.com/dsha256/dispatcher/internal/responder"
+)
+
+// WithAuditTrail records the reconstruction requests in the audit trail.
+func WithAuditTrail(trail *audit.Trail) Option {
+	return func(h *Handler) {
+		h.auditTrail = trail
+	}
+}
+
+// audit records the requests of a reconstruction route in the audit trail, if any, once answered: who sent
+// them, the fingerprint of their tickets, the size of their body, the status and error code of the response
+// and how long it took. Only the authenticated tenant and verified signing client are recorded as who sent
+// them; the tenant an unauthenticated request claims is recorded apart.
+func (h *Handler) audit(next http.Handler) http.Handler {
+	if h.auditTrail == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := &bodyCounter{ReadCloser: r.Body}
+		r.Body = body
+		rec := &auditRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		start := time.Now()
+		next.ServeHTTP(rec, r)
+
+		requestID := w.Header().Get(inspector.RequestIDHeader)
+		if requestID == "" {
+			requestID = r.Header.Get(inspector.RequestIDHeader)
+		}
+		record := audit.Record{
+			RequestID:   requestID,
+			RemoteAddr:  r.RemoteAddr,
+			Method:      r.Method,
+			Path:        r.URL.Path,
+			TicketsHash: w.Header().Get(ItineraryIDHeader),
+			Code:        w.Header().Get(responder.ErrorCodeHeader),
+			BodyBytes:   body.n,
+			Duration:    time.Since(start),
+			Status:      rec.status,
+		}
+		if identity, ok := middleware.IdentityFrom(r.Context()); ok {
+			record.Tenant = identity.Tenant
+		} else {
+			record.ClaimedTenant = r.Header.Get(TenantHeader)
+		}
+		record.ClientID, _ = middleware.VerifiedClient(r.Context())
+		h.auditTrail.Record(r.Context(), record)
+	})
+}
+
+// bodyCounter counts the bytes read from the request body.
+type bodyCounter struct {
+	io.ReadCloser
+	n int64
+}
+
+func (b *bodyCounter) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+
+	return n, err
+}
+
+// auditRecorder remembers the response status.
+type auditRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (a *auditRecorder) WriteHeader(status int) {
+	if !a.wroteHeader {
+		a.status = status
+		a.wroteHeader = true
+	}
+	a.ResponseWriter.WriteHeader(status)
+}
+
+func (a *auditRecorder) Write(p []byte) (int, error) {
+	a.wroteHeader = true
+
+	return a.ResponseWriter.Write(p)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to flush streamed responses.
+func (a *auditRecorder) Unwrap() http.ResponseWriter {
+	return a.ResponseWriter
+}
@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/dsha256/dispatcher/internal/blackout"
+	"github.com/dsha256/dispatcher/internal/messages"
+	"github.com/dsha256/dispatcher/internal/middleware"
+	"github.com/dsha256/dispatcher/internal/types"
+)
+
+const (
+	// TenantHeader identifies the tenant owning per-tenant resources such as blackout calendars.
+	TenantHeader  = "X-Tenant-Id"
+	defaultTenant = "default"
+
+	WarningBlackoutDate = "blackout_date"
+)
+
+// Warning is a non-fatal finding about a leg of a reconstructed itinerary: the envelope's warning shape,
+// located by the leg and its ticket.
+type Warning struct {
+	types.Warning
+
+	Calendar    string `json:"calendar,omitempty"`
+	Date        string `json:"date,omitempty"`
+	LegIndex    int    `json:"leg_index"`
+	TicketIndex int    `json:"ticket_index"`
+}
+
+func tenantOf(r *http.Request) string {
+	if tenant := r.Header.Get(TenantHeader); tenant != "" {
+		return tenant
+	}
+
+	return defaultTenant
+}
+
+// handleListBlackoutCalendars returns the calendars of the authenticated tenant. Like the other blackout routes,
+// it is refused unless the tenant is authenticated, as calendars are per-tenant resources.
+func (h *Handler) handleListBlackoutCalendars(w http.ResponseWriter, r *http.Request) {
+	tenant, ok := h.authenticatedTenant(w, r)
+	if !ok {
+		return
+	}
+	h.writeSuccess(w, r, h.blackouts.List(tenant), nil)
+}
+
+func (h *Handler) handlePutBlackoutCalendar(w http.ResponseWriter, r *http.Request) {
+	tenant, ok := h.authenticatedTenant(w, r)
+	if !ok {
+		return
+	}
+	var calendar blackout.Calendar
+	if err := json.NewDecoder(r.Body).Decode(&calendar); err != nil {
+		status, bodyErr := bodyError(err)
+		h.handleError(w, r, bodyErr, status)
+
+		return
+	}
+	if err := h.blackouts.Put(tenant, calendar); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, blackout.ErrTooManyCalendars) {
+			status = http.StatusConflict
+		}
+		h.handleError(w, r, err, status)
+
+		return
+	}
+	h.writeMessage(w, r, messages.BlackoutSaved, calendar)
+}
+
+func (h *Handler) handleDeleteBlackoutCalendar(w http.ResponseWriter, r *http.Request) {
+	tenant, ok := h.authenticatedTenant(w, r)
+	if !ok {
+		return
+	}
+	if err := h.blackouts.Delete(tenant, r.URL.Query().Get("name")); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, blackout.ErrCalendarNotFound) {
+			status = http.StatusNotFound
+		}
+		h.handleError(w, r, err, status)
+
+		return
+	}
+	h.writeMessage(w, r, messages.BlackoutDeleted, nil)
+}
+
+// blackoutWarnings flags legs whose ticket departs on a date blacked out by one of the calendars of the
+// request's authenticated tenant. Unauthenticated requests have no calendars.
+func (h *Handler) blackoutWarnings(r *http.Request, legs []Leg) []Warning {
+	warnings := []Warning{}
+	identity, ok := middleware.IdentityFrom(r.Context())
+	if !ok {
+		return warnings
+	}
+	tenant := identity.Tenant
+	for i, leg := range legs {
+		if leg.Ticket.DepartsAt == nil {
+			continue
+		}
+		for _, match := range h.blackouts.Check(tenant, *leg.Ticket.DepartsAt) {
+			warnings = append(warnings, Warning{
+				Warning: types.Warning{
+					Code:    WarningBlackoutDate,
+					Message: fmt.Sprintf("%s -> %s departs on %s, blacked out by calendar %q", leg.From, leg.To, match.Date, match.Calendar),
+				},
+				Calendar:    match.Calendar,
+				Date:        match.Date,
+				LegIndex:    i,
+				TicketIndex: leg.TicketIndex,
+			})
+		}
+	}
+
+	return warnings
+}
@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/dsha256/dispatcher/internal/cache"
+	"github.com/dsha256/dispatcher/internal/dispatcher"
+)
+
+// Headers of the result cache.
+const (
+	// CacheBypassHeader set to "true" reconstructs without reading or storing the cache.
+	CacheBypassHeader = "X-Dispatcher-Cache-Bypass"
+	// CacheStatusHeader reports where the result came from: hit, miss, shared or bypass.
+	CacheStatusHeader = "X-Dispatcher-Cache"
+)
+
+// cacheKeyPrefix namespaces the keys of reconstructions in a cache store shared with other data, e.g. Redis.
+const cacheKeyPrefix = "dispatcher:itinerary:"
+
+// WithCache serves repeated identical reconstructions from the cache and coalesces concurrent ones.
+func WithCache(c *cache.Cache[*dispatcher.Result]) Option {
+	return func(h *Handler) {
+		h.cache = c
+	}
+}
+
+// reconstruct reconstructs the request through the result cache, if any, and reports the cache outcome
+// in the response headers. Identical ticket sets share a cached result whatever their order: results are
+// cached with legs pointing to the tickets in canonical order and translated to the order of each request.
+func (h *Handler) reconstruct(
+	w http.ResponseWriter, r *http.Request, req *dispatcher.Request, canonical dispatcher.CanonicalTickets,
+) (*dispatcher.Result, error) {
+	compute := func() (*dispatcher.Result, error) {
+		return h.dispatcher.Reconstruct(r.Context(), req)
+	}
+
+	if h.cache == nil {
+		return compute()
+	}
+	// Results of the input order tie-break depend on the order of the tickets, which the key leaves out.
+	if tieBreak, err := h.dispatcher.TieBreak(req.TieBreak); err != nil || tieBreak == dispatcher.TieBreakInputOrder {
+		return compute()
+	}
+	key, ok := cacheKey(req, canonical.Fingerprint)
+	if !ok {
+		return compute()
+	}
+
+	if bypass, _ := strconv.ParseBool(r.Header.Get(CacheBypassHeader)); bypass {
+		result, outcome, err := h.cache.Bypass(compute)
+		w.Header().Set(CacheStatusHeader, outcome)
+
+		return result, err
+	}
+
+	// position maps the index of a ticket in the request to its index in canonical order.
+	position := make([]int, len(canonical.Order))
+	for i, index := range canonical.Order {
+		position[index] = i
+	}
+	result, outcome, err := h.cache.Do(r.Context(), key, func() (*dispatcher.Result, error) {
+		result, err := compute()
+		if err != nil {
+			return nil, err
+		}
+
+		return reindex(result, position), nil
+	})
+	w.Header().Set(CacheStatusHeader, outcome)
+	if err != nil {
+		return nil, err
+	}
+
+	return reindex(result, canonical.Order), nil
+}
+
+// reindex returns a copy of the result whose legs and code changes point to the ticket index[i] instead of
+// the ticket i; surface transfers point to no ticket either way. The path is shared with the original result.
+func reindex(result *dispatcher.Result, index []int) *dispatcher.Result {
+	reindexed := *result
+	reindexed.Legs = make([]dispatcher.Leg, len(result.Legs))
+	for i, leg := range result.Legs {
+		if !leg.SurfaceTransfer {
+			leg.TicketIndex = index[leg.TicketIndex]
+		}
+		reindexed.Legs[i] = leg
+	}
+	if result.Normalization != nil {
+		reindexed.Normalization = make([]dispatcher.CodeChange, len(result.Normalization))
+		for i, change := range result.Normalization {
+			change.TicketIndex = index[change.TicketIndex]
+			reindexed.Normalization[i] = change
+		}
+	}
+
+	return &reindexed
+}
+
+// cacheKey is the SHA-256 of the canonical JSON of the request without its tickets and of the fingerprint
+// of its ticket set, so bodies differing only in formatting, field order, ticket representation or ticket
+// order share a key. It returns false when the request cannot be encoded.
+func cacheKey(req *dispatcher.Request, fingerprint string) (string, bool) {
+	options := *req
+	options.Tickets = nil
+	canonical, err := json.Marshal(&options)
+	if err != nil {
+		return "", false
+	}
+	hash := sha256.New()
+	hash.Write(canonical)
+	hash.Write([]byte(fingerprint))
+
+	return cacheKeyPrefix + hex.EncodeToString(hash.Sum(nil)), true
+}
+
+func (h *Handler) handleCache(w http.ResponseWriter, r *http.Request) {
+	h.writeSuccess(w, r, h.cache.Stats(), nil)
+}
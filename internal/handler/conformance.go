@@ -0,0 +1,12 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/dsha256/dispatcher/internal/conformance"
+)
+
+func (h *Handler) handleConformance(w http.ResponseWriter, r *http.Request) {
+	h.writeSuccess(w, r, json.RawMessage(conformance.Raw()), nil)
+}
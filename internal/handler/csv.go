@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	"github.com/dsha256/dispatcher/internal/dispatcher"
+	"github.com/dsha256/dispatcher/internal/ticketcsv"
+)
+
+// WithCSVMapping sets the default column mapping of CSV request bodies.
+func WithCSVMapping(mapping ticketcsv.Mapping) Option {
+	return func(h *Handler) {
+		h.csvMapping = mapping
+	}
+}
+
+// decodeCSVTickets reads tickets from a CSV body. The ?columns= query parameter, e.g. "to,from,price",
+// overrides the configured mapping, and a header row naming the columns overrides both.
+// It writes the error response itself and returns false when the body is invalid.
+func (h *Handler) decodeCSVTickets(w http.ResponseWriter, r *http.Request) ([]byte, []dispatcher.Ticket, bool) {
+	mapping := h.csvMapping
+	if columns := r.URL.Query().Get("columns"); columns != "" {
+		var err error
+		if mapping, err = ticketcsv.ParseMapping(columns); err != nil {
+			h.handleError(w, r, err, http.StatusBadRequest)
+
+			return nil, nil, false
+		}
+	}
+
+	payload, err := io.ReadAll(r.Body)
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "error reading request body", "error", err, "path", r.URL.Path)
+		status, bodyErr := bodyError(err)
+		h.handleError(w, r, bodyErr, status)
+
+		return nil, nil, false
+	}
+
+	tickets, err := ticketcsv.Read(bytes.NewReader(payload), mapping)
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "error decoding CSV request body", "error", err, "path", r.URL.Path)
+		h.bundler.RecordFailure(payload, string(errorCode(err)))
+		h.handleError(w, r, err, http.StatusBadRequest)
+
+		return nil, nil, false
+	}
+
+	return payload, tickets, true
+}
@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"errors"
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"runtime/debug"
+)
+
+var ErrBuildInfoUnavailable = errors.New("build information is unavailable")
+
+// BuildInfoResponse describes the running binary: the Go version and module versions it was built with, and
+// its build settings, e.g. vcs.revision.
+type BuildInfoResponse struct {
+	Settings  map[string]string `json:"settings"`
+	GoVersion string            `json:"go_version"`
+	Path      string            `json:"path"`
+	Main      Module            `json:"main"`
+	Deps      []Module          `json:"deps"`
+}
+
+// Module is a module of the binary.
+type Module struct {
+	Path    string `json:"path"`
+	Version string `json:"version"`
+	Sum     string `json:"sum,omitempty"`
+}
+
+// WithDebug serves the runtime debug routes: the net/http/pprof profiles under /debug/pprof/, the expvar
+// variables at /debug/vars and the build information at /debug/buildinfo. They are admin routes.
+func WithDebug() Option {
+	return func(h *Handler) {
+		h.debug = true
+	}
+}
+
+// debugRoutes are the runtime debug routes, none unless WithDebug is given.
+func (h *Handler) debugRoutes() []route {
+	if !h.debug {
+		return nil
+	}
+
+	return []route{
+		{method: http.MethodGet, path: "/debug/pprof/", handler: pprof.Index, admin: true},
+		{method: http.MethodGet, path: "/debug/pprof/cmdline", handler: pprof.Cmdline, admin: true},
+		{method: http.MethodGet, path: "/debug/pprof/profile", handler: pprof.Profile, admin: true},
+		{method: http.MethodGet, path: "/debug/pprof/symbol", handler: pprof.Symbol, admin: true},
+		{method: http.MethodPost, path: "/debug/pprof/symbol", handler: pprof.Symbol, admin: true},
+		{method: http.MethodGet, path: "/debug/pprof/trace", handler: pprof.Trace, admin: true},
+		{method: http.MethodGet, path: "/debug/vars", handler: expvar.Handler().ServeHTTP, admin: true},
+		{method: http.MethodGet, path: "/debug/buildinfo", handler: h.handleBuildInfo, admin: true},
+	}
+}
+
+func (h *Handler) handleBuildInfo(w http.ResponseWriter, r *http.Request) {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		h.handleError(w, r, ErrBuildInfoUnavailable, http.StatusInternalServerError)
+
+		return
+	}
+
+	resp := BuildInfoResponse{
+		Settings:  make(map[string]string, len(info.Settings)),
+		GoVersion: info.GoVersion,
+		Path:      info.Path,
+		Main:      Module{Path: info.Main.Path, Version: info.Main.Version, Sum: info.Main.Sum},
+		Deps:      make([]Module, 0, len(info.Deps)),
+	}
+	for _, setting := range info.Settings {
+		resp.Settings[setting.Key] = setting.Value
+	}
+	for _, dep := range info.Deps {
+		resp.Deps = append(resp.Deps, Module{Path: dep.Path, Version: dep.Version, Sum: dep.Sum})
+	}
+	h.writeSuccess(w, r, resp, nil)
+}
@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"net/http"
+)
+
+// DiscoveryResponse lists the endpoints, in registration order.
+type DiscoveryResponse struct {
+	Endpoints []Endpoint `json:"endpoints"`
+}
+
+// Endpoint is a path and the methods it accepts, the same as in its Allow header.
+type Endpoint struct {
+	Path    string   `json:"path"`
+	Methods []string `json:"methods"`
+}
+
+// handleDiscovery answers with the discovery document of the routes, built once.
+func (h *Handler) handleDiscovery(routes []route) http.HandlerFunc {
+	var discovery DiscoveryResponse
+	index := make(map[string]int, len(routes))
+	for _, rt := range routes {
+		i, ok := index[rt.path]
+		if !ok {
+			i = len(discovery.Endpoints)
+			index[rt.path] = i
+			discovery.Endpoints = append(discovery.Endpoints, Endpoint{Path: rt.path})
+		}
+		endpoint := &discovery.Endpoints[i]
+		endpoint.Methods = append(endpoint.Methods, rt.method)
+		if rt.method == http.MethodGet {
+			endpoint.Methods = append(endpoint.Methods, http.MethodHead)
+		}
+	}
+	for i := range discovery.Endpoints {
+		discovery.Endpoints[i].Methods = append(discovery.Endpoints[i].Methods, http.MethodOptions)
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		h.writeSuccess(w, r, discovery, nil)
+	}
+}
@@ -1,49 +1,402 @@
 package handler
 
 import (
-	"encoding/json"
+	"io"
+	"mime"
 	"net/http"
 
+	"github.com/dsha256/dispatcher/internal/accounting"
+	"github.com/dsha256/dispatcher/internal/codec"
+	"github.com/dsha256/dispatcher/internal/degradation"
+	"github.com/dsha256/dispatcher/internal/dispatcher"
+	"github.com/dsha256/dispatcher/internal/pricing"
 	"github.com/dsha256/dispatcher/internal/responder"
 )
 
-func (h *Handler) handleItinerary(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodPost:
-		h.reconstructItinerary(w, r)
-	default:
-		h.handleError(w, ErrMethodNotAllowed, http.StatusMethodNotAllowed)
-	}
+const (
+	// DegradedHeader lists the degradation step that trimmed a response.
+	DegradedHeader = "X-Dispatcher-Degraded"
+	// ItineraryIDHeader carries the itinerary ID of the request's tickets, on every response of an endpoint taking tickets.
+	ItineraryIDHeader = "X-Dispatcher-Itinerary-Id"
+
+	// etagPrefixLength is the number of characters of the itinerary ID starting ETags.
+	etagPrefixLength = 16
+)
+
+// identify canonicalizes the tickets of the request and reports their itinerary ID, the fingerprint of the
+// ticket set, in the ItineraryIDHeader.
+func identify(w http.ResponseWriter, tickets []dispatcher.Ticket) dispatcher.CanonicalTickets {
+	canonical := dispatcher.Canonicalize(tickets)
+	w.Header().Set(ItineraryIDHeader, canonical.Fingerprint)
+
+	return canonical
 }
 
+// etagPrefix is the start of the itinerary ID, prefixing the ETags of responses about the ticket set.
+func etagPrefix(itineraryID string) string {
+	return itineraryID[:min(len(itineraryID), etagPrefixLength)]
+}
+
+// ReconstructItineraryRequest accepts tickets either as ["Source", "Destination"] pairs
+// or as objects carrying metadata, e.g. {"from": "JFK", "to": "LAX", "flight_no": "AA1"}.
+//
+// Strategy picks between several valid orderings by the name of a registered strategy, e.g. "default"
+// (lexicographically smallest); the server's default strategy when empty.
+// TieBreak picks the destination taken first when an airport has several: "smallest_first", "largest_first"
+// or "input_order"; the server's default policy when empty.
+// PreferredHubs weighs airports to route through when several orderings are valid, e.g. {"DXB": 1}; JSON only.
+// NormalizeCodes overrides the server default for uppercasing and trimming airport codes, and Aliases replace
+// codes on top of the server's, e.g. {"NYC": ["JFK", "LGA", "EWR"]}; JSON only.
+// SurfaceTransfers are the gaps an open-jaw trip may have, e.g. [{"from": "LAX", "to": "SFO"}] to fly into LAX
+// and out of SFO, stitched with a leg marked surface_transfer; JSON only.
+// ExchangeRates convert the totals of the ticket prices per currency to a grand total, in place of the server's
+// rates, e.g. {"currency": "USD", "rates": {"EUR": 1.08}}; JSON only.
+// StrictAirports overrides the server default for rejecting unknown IATA/ICAO airport codes.
+// AllowDuplicates overrides the server default for accepting repeated identical tickets.
+// Enrich adds airport names, cities, countries and coordinates for each stop of the linear path.
+// Stability "strict" pins the request to AlgorithmVersion (the current version when empty).
+// SuggestRepairs adds the tickets to add or remove to the error details when the ticket set is invalid.
+// SourceURL references a ticket file, e.g. s3://bucket/tickets.csv, fetched instead of sending Tickets.
+// Fields selects the sections of the response, e.g. ["linear_path", "timing"], unless the ?fields= query
+// parameter does; all of them when empty.
 type ReconstructItineraryRequest struct {
-	Tickets [][]string `json:"tickets"`
+	Constraints      *dispatcher.Constraints      `json:"constraints,omitempty"       xml:"constraints,omitempty"`
+	StrictAirports   *bool                        `json:"strict_airports,omitempty"   xml:"strict_airports,omitempty"`
+	AllowDuplicates  *bool                        `json:"allow_duplicates,omitempty"  xml:"allow_duplicates,omitempty"`
+	Enrich           bool                         `json:"enrich,omitempty"            xml:"enrich,omitempty"`
+	SuggestRepairs   bool                         `json:"suggest_repairs,omitempty"   xml:"suggest_repairs,omitempty"`
+	Strategy         dispatcher.Strategy          `json:"strategy,omitempty"          xml:"strategy,omitempty"`
+	TieBreak         dispatcher.TieBreak          `json:"tie_break,omitempty"         xml:"tie_break,omitempty"`
+	PreferredHubs    dispatcher.HubWeights        `json:"preferred_hubs,omitempty"    xml:"-"`
+	NormalizeCodes   *bool                        `json:"normalize_codes,omitempty"   xml:"normalize_codes,omitempty"`
+	Aliases          dispatcher.Aliases           `json:"aliases,omitempty"           xml:"-"`
+	SurfaceTransfers []dispatcher.SurfaceTransfer `json:"surface_transfers,omitempty" xml:"-"`
+	ExchangeRates    *pricing.Rates               `json:"exchange_rates,omitempty"    xml:"-"`
+	Stability        dispatcher.Stability         `json:"stability,omitempty"         xml:"stability,omitempty"`
+	AlgorithmVersion string                       `json:"algorithm_version,omitempty" xml:"algorithm_version,omitempty"`
+	SourceURL        string                       `json:"source_url,omitempty"        xml:"source_url,omitempty"`
+	Fields           []string                     `json:"fields,omitempty"            xml:"fields>field,omitempty"`
+	Tickets          []dispatcher.Ticket          `json:"tickets"                     xml:"tickets>ticket"`
 }
 
-func (h *Handler) reconstructItinerary(w http.ResponseWriter, r *http.Request) {
+// ReconstructItineraryResponse is the reconstructed itinerary. ItineraryID is the fingerprint of the ticket set,
+// the same for the same tickets in any order. ID retrieves the itinerary later from /api/v1/dispatcher/itinerary/{id};
+// it is omitted when itinerary storage is disabled.
+type ReconstructItineraryResponse struct {
+	ID               string           `json:"id,omitempty"`
+	ItineraryID      string           `json:"itinerary_id"`
+	TotalPrice       *float64         `json:"total_price,omitempty"`
+	AlgorithmVersion string           `json:"algorithm_version"`
+	LinearPath       []string         `json:"linear_path,omitzero"`
+	Stops            []Stop           `json:"stops,omitempty"`
+	Distances        *Distances       `json:"distances,omitempty"`
+	Emissions        *Emissions       `json:"emissions,omitempty"`
+	Timing           *Timing          `json:"timing,omitempty"`
+	Prices           *pricing.Summary `json:"prices,omitempty"`
+	Legs             []Leg            `json:"legs,omitzero"`
+	Warnings         []Warning        `json:"warnings,omitempty"`
+	// Normalization lists the airport codes of the tickets rewritten before reconstruction.
+	Normalization []dispatcher.CodeChange `json:"normalization,omitempty"`
+}
+
+// Leg is a step of the linear path together with the original ticket it was made with, none for a surface transfer.
+type Leg struct {
+	Ticket dispatcher.Ticket `json:"ticket,omitzero"`
+	dispatcher.Leg
+}
+
+type ValidateItineraryResponse struct {
+	ItineraryID     string                       `json:"itinerary_id"`
+	StartCandidates []string                     `json:"start_candidates"`
+	EndCandidates   []string                     `json:"end_candidates"`
+	UnbalancedNodes []dispatcher.AirportDegree   `json:"unbalanced_nodes"`
+	Duplicates      []dispatcher.ValidationIssue `json:"duplicates"`
+	Issues          []dispatcher.ValidationIssue `json:"issues"`
+	IsValid         bool                         `json:"is_valid"`
+}
+
+// decodeTicketsRequest reads and decodes the request body (see decodeTickets), then fetches the tickets of
+// its source URL, if any (see resolveSource). It writes the error response itself and returns false when the
+// body or its source is invalid.
+func (h *Handler) decodeTicketsRequest(w http.ResponseWriter, r *http.Request) ([]byte, ReconstructItineraryRequest, bool) {
+	payload, req, ok := h.decodeTickets(w, r)
+
+	return payload, req, ok && h.resolveSource(w, r, &req)
+}
+
+// decodeTickets reads and decodes the request body: JSON or one of its binary encodings (see
+// decodeRequest), XML with Content-Type application/xml or text/xml (see decodeXMLRequest), tickets in CSV
+// with Content-Type text/csv (see decodeCSVTickets), or streamed tickets with Content-Type
+// application/x-ndjson (see decodeNDJSONTickets). It writes the error response itself and returns false
+// when the body is invalid.
+func (h *Handler) decodeTickets(w http.ResponseWriter, r *http.Request) ([]byte, ReconstructItineraryRequest, bool) {
 	var req ReconstructItineraryRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	switch mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType {
+	case "text/csv":
+		payload, tickets, ok := h.decodeCSVTickets(w, r)
+		req.Tickets = tickets
+
+		return payload, req, ok && h.checkTicketShapes(w, r, payload, req.Tickets)
+	case "application/x-ndjson":
+		tickets, ok := h.decodeNDJSONTickets(w, r)
+		req.Tickets = tickets
+
+		return nil, req, ok
+	case "application/xml", "text/xml":
+		payload, ok := h.decodeXMLRequest(w, r, &req)
+
+		return payload, req, ok && h.checkTicketShapes(w, r, payload, req.Tickets)
+	}
+
+	payload, ok := h.decodeRequest(w, r, &req)
+
+	return payload, req, ok && h.checkTicketShapes(w, r, payload, req.Tickets)
+}
+
+// checkTicketShapes rejects the request with a *RequestError naming every ticket that is not exactly two
+// non-empty airport codes, so malformed tickets never reach the algorithms.
+// It writes the error response itself and returns false when a ticket is malformed.
+func (h *Handler) checkTicketShapes(w http.ResponseWriter, r *http.Request, payload []byte, tickets []dispatcher.Ticket) bool {
+	problems := shapeProblems(tickets)
+	if len(problems) == 0 {
+		return true
+	}
+
+	err := &RequestError{Problems: problems}
+	h.logger.WarnContext(r.Context(), "malformed tickets", "error", err, "path", r.URL.Path)
+	h.bundler.RecordFailure(payload, string(errorCode(err)))
+	h.handleError(w, r, err, http.StatusBadRequest)
+
+	return false
+}
+
+// decodeRequest reads the request body and decodes it into req. Bodies in MessagePack or protobuf, by
+// Content-Type, are decoded into JSON first, which is the payload returned. When the body is invalid it
+// responds with a *RequestError listing every problem found (see requestProblems) and returns false.
+func (h *Handler) decodeRequest(w http.ResponseWriter, r *http.Request, req any) ([]byte, bool) {
+	payload, err := io.ReadAll(r.Body)
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "error reading request body", "error", err, "path", r.URL.Path)
+		status, bodyErr := bodyError(err)
+		h.handleError(w, r, bodyErr, status)
+
+		return nil, false
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if c, ok := codec.Lookup(mediaType); ok {
+		if payload, err = c.Decode(payload); err != nil {
+			h.logger.WarnContext(r.Context(), "error decoding request body", "error", err, "encoding", c.Name(), "path", r.URL.Path)
+			h.handleError(w, r, &RequestError{Problems: []Problem{{Message: err.Error()}}}, http.StatusBadRequest)
+
+			return nil, false
+		}
+	}
+
+	if err = dispatcher.DecodeStrict(payload, req); err != nil {
 		h.logger.WarnContext(r.Context(), "error decoding request body", "error", err, "payload", req, "path", r.URL.Path)
-		h.handleError(w, err, http.StatusBadRequest)
+		h.bundler.RecordFailure(payload, string(errorCode(err)))
+		h.handleError(w, r, h.requestProblems(r, payload, req, err), http.StatusBadRequest)
 
+		return nil, false
+	}
+
+	return payload, true
+}
+
+func (h *Handler) validateItinerary(w http.ResponseWriter, r *http.Request) {
+	_, req, ok := h.decodeTicketsRequest(w, r)
+	if !ok {
+		return
+	}
+
+	warnings, ok := h.checkLimits(w, r, len(req.Tickets))
+	if !ok {
 		return
 	}
 
-	linearPath, err := h.dispatcher.ReconstructItinerary(r.Context(), &req.Tickets)
+	canonical := identify(w, req.Tickets)
+	report, _, ok := h.validate(w, r, &req)
+	if !ok {
+		return
+	}
+
+	duplicates := []dispatcher.ValidationIssue{}
+	for _, issue := range report.Issues {
+		if issue.Kind == dispatcher.IssueDuplicateTicket {
+			duplicates = append(duplicates, issue)
+		}
+	}
+
+	h.writeSuccess(w, r, ValidateItineraryResponse{
+		ItineraryID:     canonical.Fingerprint,
+		IsValid:         report.Valid,
+		StartCandidates: report.StartCandidates,
+		EndCandidates:   report.EndCandidates,
+		UnbalancedNodes: report.Unbalanced,
+		Duplicates:      duplicates,
+		Issues:          report.Issues,
+	}, warnings)
+}
+
+// dispatcherRequest returns the dispatcher request of the tickets and options of req, pinned to the algorithm
+// version.
+func (req *ReconstructItineraryRequest) dispatcherRequest(version string) *dispatcher.Request {
+	return &dispatcher.Request{
+		Constraints:      req.Constraints,
+		StrictAirports:   req.StrictAirports,
+		AllowDuplicates:  req.AllowDuplicates,
+		Strategy:         req.Strategy,
+		TieBreak:         req.TieBreak,
+		PreferredHubs:    req.PreferredHubs,
+		NormalizeCodes:   req.NormalizeCodes,
+		Aliases:          req.Aliases,
+		SurfaceTransfers: req.SurfaceTransfers,
+		AlgorithmVersion: version,
+		Tickets:          req.Tickets,
+	}
+}
+
+// validate validates the tickets of req with its options, the way its reconstruction would see them (see
+// dispatcher.Validate), and returns the report with the tickets validated. When the options are invalid, it
+// answers the request itself and returns false.
+func (h *Handler) validate(w http.ResponseWriter, r *http.Request, req *ReconstructItineraryRequest) (*dispatcher.ValidationReport, [][]string, bool) {
+	version, err := dispatcher.ResolveAlgorithmVersion(req.Stability, req.AlgorithmVersion)
+	if err != nil {
+		h.handleError(w, r, err, http.StatusBadRequest)
+
+		return nil, nil, false
+	}
+	report, pairs, err := h.dispatcher.Validate(r.Context(), req.dispatcherRequest(version))
+	if err != nil {
+		h.handleError(w, r, err, h.errorStatus(err))
+
+		return nil, nil, false
+	}
+
+	return report, pairs, true
+}
+
+// runReconstruction reconstructs the itinerary of the request the way every reconstruction route does: it
+// resolves the algorithm version, records the resource usage, publishes the domain event and compares the
+// shadow strategy. When the reconstruction fails, it answers the request itself and returns false.
+func (h *Handler) runReconstruction(
+	w http.ResponseWriter, r *http.Request, payload []byte, req *ReconstructItineraryRequest, canonical dispatcher.CanonicalTickets,
+) (*dispatcher.Result, bool) {
+	version, err := dispatcher.ResolveAlgorithmVersion(req.Stability, req.AlgorithmVersion)
+	if err == nil {
+		err = req.ExchangeRates.Validate()
+	}
 	if err != nil {
-		if h.isBadRequestError(err) {
-			h.logger.WarnContext(r.Context(), "error calculating linear path", "error", err, "payload", req, "path", r.URL.Path)
-			h.handleError(w, err, http.StatusBadRequest)
+		h.publishEvent(r, req, canonical.Fingerprint, 0, err)
+		h.handleError(w, r, err, http.StatusBadRequest)
+
+		return nil, false
+	}
+
+	var result *dispatcher.Result
+	reconstruction := req.dispatcherRequest(version)
+	usage := accounting.Measure(func() {
+		result, err = h.reconstruct(w, r, reconstruction, canonical)
+	})
+	h.recordUsage(w, r, usage)
+	h.publishEvent(r, req, canonical.Fingerprint, usage.WallTime, err)
+	h.compareShadow(w, r, reconstruction, canonical.Fingerprint, result, err)
+	if err == nil {
+		return result, true
+	}
 
-			return
+	switch status := h.errorStatus(err); status {
+	case http.StatusBadRequest:
+		h.bundler.RecordFailure(payload, string(errorCode(err)))
+		h.logger.WarnContext(r.Context(), "error calculating linear path", "error", err, "payload", req, "path", r.URL.Path)
+		if req.SuggestRepairs {
+			err = withRepairs(err, req.Tickets)
 		}
+		h.handleError(w, r, err, status)
+	case http.StatusInternalServerError:
+		h.bundler.RecordFailure(payload, string(errorCode(err)))
 		h.logger.ErrorContext(r.Context(), "error calculating linear path", "error", err)
-		h.handleError(w, err, http.StatusInternalServerError)
+		h.handleError(w, r, err, status)
+	default:
+		h.logger.WarnContext(r.Context(), "linear path calculation stopped", "error", err, "status", status, "path", r.URL.Path)
+		h.handleError(w, r, err, status)
+	}
+
+	return nil, false
+}
+
+func (h *Handler) reconstructItinerary(w http.ResponseWriter, r *http.Request) {
+	exp, ok := h.negotiateExport(w, r)
+	if !ok {
+		return
+	}
+	payload, req, ok := h.decodeTicketsRequest(w, r)
+	if !ok {
+		return
+	}
+	if req.Strategy, ok = h.selectStrategy(w, r, req.Strategy); !ok {
+		return
+	}
+	fields, err := responseFields(r.URL.Query().Get("fields"), req.Fields)
+	if err != nil {
+		h.handleError(w, r, err, http.StatusBadRequest)
 
 		return
 	}
 
-	responder.WriteSuccess(w, http.StatusOK, "", map[string][]string{
-		"linear_path": linearPath,
-	})
+	warnings, ok := h.checkLimits(w, r, len(req.Tickets))
+	if !ok {
+		return
+	}
+
+	canonical := identify(w, req.Tickets)
+	result, ok := h.runReconstruction(w, r, payload, &req, canonical)
+	if !ok {
+		return
+	}
+
+	resp := ReconstructItineraryResponse{
+		ItineraryID:      canonical.Fingerprint,
+		AlgorithmVersion: result.AlgorithmVersion,
+		LinearPath:       result.Path,
+		Legs:             make([]Leg, 0, len(result.Legs)),
+		Normalization:    result.Normalization,
+	}
+	for _, leg := range result.Legs {
+		if leg.SurfaceTransfer {
+			resp.Legs = append(resp.Legs, Leg{Leg: leg})
+		} else {
+			resp.Legs = append(resp.Legs, Leg{Leg: leg, Ticket: req.Tickets[leg.TicketIndex]})
+		}
+	}
+	resp.Warnings = h.blackoutWarnings(r, resp.Legs)
+	if h.ladder.Active(degradation.StepDisableEnrichment) {
+		w.Header().Set(DegradedHeader, string(degradation.StepDisableEnrichment))
+	} else {
+		if req.Enrich {
+			resp.Stops = h.enrichStops(result.Path)
+		}
+		resp.Distances = h.distances(resp.Legs)
+		resp.Emissions = h.emissions(resp.Distances)
+		resp.Timing = h.timing(resp.Legs)
+	}
+	for _, ticket := range req.Tickets {
+		if ticket.Price != nil {
+			total := dispatcher.TotalPrice(req.Tickets, result.Legs)
+			resp.TotalPrice = &total
+			resp.Prices = h.prices(resp.Legs, req.ExchangeRates)
+
+			break
+		}
+	}
+	resp.ID = h.saveItinerary(r, len(req.Tickets), resp)
+	if exp != nil {
+		h.writeExport(w, r, exp, resp)
+
+		return
+	}
+	fields.apply(&resp)
+
+	responder.WriteTagged(h.responder, w, r, etagPrefix(canonical.Fingerprint), responder.Success{Data: resp, Warnings: warnings})
 }
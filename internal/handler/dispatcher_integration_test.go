@@ -1,51 +1,142 @@
 package handler_test
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/textproto"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/dsha256/dispatcher/internal/airports"
+	"github.com/dsha256/dispatcher/internal/audit"
+	"github.com/dsha256/dispatcher/internal/blackout"
+	"github.com/dsha256/dispatcher/internal/cache"
+	"github.com/dsha256/dispatcher/internal/clock"
+	"github.com/dsha256/dispatcher/internal/codec"
+	"github.com/dsha256/dispatcher/internal/config"
 	"github.com/dsha256/dispatcher/internal/dispatcher"
+	"github.com/dsha256/dispatcher/internal/emissions"
+	"github.com/dsha256/dispatcher/internal/events"
 	"github.com/dsha256/dispatcher/internal/handler"
+	"github.com/dsha256/dispatcher/internal/health"
+	"github.com/dsha256/dispatcher/internal/i18n"
+	"github.com/dsha256/dispatcher/internal/idempotency"
+	"github.com/dsha256/dispatcher/internal/inspector"
+	"github.com/dsha256/dispatcher/internal/jobs"
+	"github.com/dsha256/dispatcher/internal/limits"
+	"github.com/dsha256/dispatcher/internal/logging"
+	"github.com/dsha256/dispatcher/internal/messages"
+	"github.com/dsha256/dispatcher/internal/middleware"
+	"github.com/dsha256/dispatcher/internal/remote"
+	"github.com/dsha256/dispatcher/internal/responder"
+	"github.com/dsha256/dispatcher/internal/shadow"
+	"github.com/dsha256/dispatcher/internal/shedding"
+	"github.com/dsha256/dispatcher/internal/store"
+	"github.com/dsha256/dispatcher/internal/support"
 )
 
 // setupTestServer creates a test server with the itinerary handler.
-func setupTestServer(t *testing.T) *httptest.Server {
+func setupTestServer(t *testing.T, opts ...handler.Option) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(setupTestMux(t, opts...))
+
+	// Add cleanup to ensure server is closed after test
+	t.Cleanup(func() {
+		server.Close()
+	})
+
+	return server
+}
+
+// setupTenantServer creates a test server authenticating every request as the tenant, with the API key the
+// server maps to it.
+func setupTenantServer(t *testing.T, tenant string, opts ...handler.Option) *httptest.Server {
+	t.Helper()
+
+	key := tenant + "-key"
+	opts = append(opts, handler.WithTenants(middleware.Tenants{APIKeys: map[string]string{key: tenant}}))
+	mux := setupTestMux(t, opts...)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Header.Set("Authorization", "Bearer "+key)
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// setupTestMux creates the routes of the itinerary handler, for tests driving requests directly.
+func setupTestMux(t *testing.T, opts ...handler.Option) *http.ServeMux {
 	t.Helper()
 
 	// Create a test logger that discards output
 	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError}))
 
 	// Create a dispatcher service
-	dispatcherService := dispatcher.New()
+	directory := airports.Default()
+	calculator, err := emissions.NewCalculator(emissions.DefaultCoefficients())
+	if err != nil {
+		t.Fatalf("Failed to create emissions calculator: %v", err)
+	}
+	// The "wrapped" strategy runs the default one through a ReconstructorFunc, so it answers like "default" without
+	// being streamable.
+	dispatcherService := dispatcher.New(
+		dispatcher.WithAirportValidation(directory.Known, false),
+		dispatcher.WithStrategy("wrapped", dispatcher.ReconstructorFunc(dispatcher.Lexicographic().Reconstruct)),
+	)
 
 	// Create a handler with the dispatcher service
-	h := handler.New(logger, dispatcherService)
+	opts = append([]handler.Option{handler.WithAirports(directory), handler.WithEmissions(calculator)}, opts...)
+	h := handler.New(logger, dispatcherService, support.NewBundler(clock.Real{}, nil, nil), opts...)
 
-	// Create a test server
 	mux := http.NewServeMux()
 	h.RegisterRoutes(mux)
-	server := httptest.NewServer(mux)
-
-	// Add cleanup to ensure server is closed after test
-	t.Cleanup(func() {
-		server.Close()
-	})
 
-	return server
+	return mux
 }
 
-// sendRequest is a helper function to send HTTP requests in tests.
+// sendRequest is a helper function to send HTTP requests to the itinerary endpoint in tests.
 func sendRequest(t *testing.T, server *httptest.Server, method string, body interface{}) (*http.Response, map[string]interface{}) {
 	t.Helper()
 
+	return sendRequestTo(t, server, method, "/api/v1/dispatcher/itinerary", body)
+}
+
+// sendRequestTo is a helper function to send HTTP requests to an arbitrary path in tests.
+func sendRequestTo(t *testing.T, server *httptest.Server, method, path string, body interface{}) (*http.Response, map[string]interface{}) {
+	t.Helper()
+
+	return sendRequestWithHeader(t, server, method, path, http.Header{}, body)
+}
+
+// sendAdminRequest sends an HTTP request authenticated with the admin token to an admin path in tests.
+func sendAdminRequest(t *testing.T, server *httptest.Server, method, path, token string, body interface{}) (*http.Response, map[string]interface{}) {
+	t.Helper()
+
+	return sendRequestWithHeader(t, server, method, path, http.Header{"Authorization": {"Bearer " + token}}, body)
+}
+
+// sendRequestWithHeader is a helper function to send HTTP requests with extra headers in tests.
+func sendRequestWithHeader(
+	t *testing.T, server *httptest.Server, method, path string, header http.Header, body interface{},
+) (*http.Response, map[string]interface{}) {
+	t.Helper()
+
 	// Create context with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -61,10 +152,11 @@ func sendRequest(t *testing.T, server *httptest.Server, method string, body inte
 	}
 
 	// Create HTTP request
-	req, err := http.NewRequestWithContext(ctx, method, server.URL+"/api/v1/dispatcher/itinerary", bytes.NewBuffer(reqBody))
+	req, err := http.NewRequestWithContext(ctx, method, server.URL+path, bytes.NewBuffer(reqBody))
 	if err != nil {
 		t.Fatalf("Failed to create request: %v", err)
 	}
+	req.Header = header.Clone()
 	req.Header.Set("Content-Type", "application/json")
 
 	// Send request
@@ -235,6 +327,98 @@ func TestHandleItinerary(t *testing.T) {
 			expectedBody:   nil,
 			expectedError:  true,
 		},
+		{
+			name:   "Strict stability with pinned version",
+			method: http.MethodPost,
+			requestBody: map[string]interface{}{
+				"tickets":           [][]string{{"JFK", "SFO"}, {"JFK", "ATL"}, {"SFO", "ATL"}, {"ATL", "JFK"}},
+				"stability":         "strict",
+				"algorithm_version": "1",
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody: map[string][]string{
+				"linear_path": {"JFK", "ATL", "JFK", "SFO", "ATL"},
+			},
+			expectedError: false,
+		},
+		{
+			name:   "Strict stability with unsupported version",
+			method: http.MethodPost,
+			requestBody: map[string]interface{}{
+				"tickets":           [][]string{{"JFK", "SFO"}},
+				"stability":         "strict",
+				"algorithm_version": "0",
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   nil,
+			expectedError:  true,
+		},
+		{
+			name:   "Constraints satisfied",
+			method: http.MethodPost,
+			requestBody: map[string]interface{}{
+				"tickets": [][]string{{"LAX", "DXB"}, {"JFK", "LAX"}},
+				"constraints": map[string]interface{}{
+					"required_waypoints": []string{"LAX"},
+					"max_stops":          1,
+				},
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody: map[string][]string{
+				"linear_path": {"JFK", "LAX", "DXB"},
+			},
+			expectedError: false,
+		},
+		{
+			name:   "Constraint avoid airports violated",
+			method: http.MethodPost,
+			requestBody: map[string]interface{}{
+				"tickets": [][]string{{"LAX", "DXB"}, {"JFK", "LAX"}},
+				"constraints": map[string]interface{}{
+					"avoid_airports": []string{"LAX"},
+				},
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   nil,
+			expectedError:  true,
+		},
+		{
+			name:   "Constraint max stops violated",
+			method: http.MethodPost,
+			requestBody: map[string]interface{}{
+				"tickets": [][]string{{"LAX", "DXB"}, {"JFK", "LAX"}},
+				"constraints": map[string]interface{}{
+					"max_stops": 0,
+				},
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   nil,
+			expectedError:  true,
+		},
+		{
+			name:   "Strict airports with known codes",
+			method: http.MethodPost,
+			requestBody: map[string]interface{}{
+				"tickets":         [][]string{{"LAX", "DXB"}, {"KJFK", "LAX"}},
+				"strict_airports": true,
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody: map[string][]string{
+				"linear_path": {"KJFK", "LAX", "DXB"},
+			},
+			expectedError: false,
+		},
+		{
+			name:   "Strict airports with unknown code",
+			method: http.MethodPost,
+			requestBody: map[string]interface{}{
+				"tickets":         [][]string{{"LAX", "XXX"}, {"JFK", "LAX"}},
+				"strict_airports": true,
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   nil,
+			expectedError:  true,
+		},
 		{
 			name:           "Invalid method",
 			method:         http.MethodGet,
@@ -372,3 +556,3698 @@ func TestHandleItineraryEdgeCases(t *testing.T) {
 		}
 	})
 }
+
+func TestHandleValidateItinerary(t *testing.T) {
+	t.Parallel()
+
+	server := setupTestServer(t)
+
+	tests := []struct {
+		requestBody     map[string]interface{}
+		name            string
+		startCandidates []interface{}
+		duplicates      int
+		unbalancedNodes int
+		isValid         bool
+	}{
+		{
+			name: "Valid itinerary",
+			requestBody: map[string]interface{}{
+				"tickets": [][]string{{"LAX", "DXB"}, {"JFK", "LAX"}},
+			},
+			isValid:         true,
+			startCandidates: []interface{}{"JFK"},
+		},
+		{
+			name: "Duplicates and unbalanced nodes",
+			requestBody: map[string]interface{}{
+				"tickets": [][]string{{"JFK", "SFO"}, {"JFK", "SFO"}, {"LAX", "ATL"}},
+			},
+			isValid:         false,
+			startCandidates: []interface{}{"JFK", "LAX"},
+			duplicates:      1,
+			unbalancedNodes: 4,
+		},
+		{
+			name: "Duplicates allowed by the request",
+			requestBody: map[string]interface{}{
+				"tickets":          [][]string{{"JFK", "LAX"}, {"LAX", "JFK"}, {"JFK", "LAX"}},
+				"allow_duplicates": true,
+			},
+			isValid:         true,
+			startCandidates: []interface{}{"JFK"},
+		},
+		{
+			name: "Codes normalized",
+			requestBody: map[string]interface{}{
+				"tickets":         [][]string{{"jfk", "LAX"}, {"LAX", "JFK"}, {"JFK", "SFO"}},
+				"normalize_codes": true,
+			},
+			isValid:         true,
+			startCandidates: []interface{}{"JFK"},
+		},
+		{
+			name: "Surface transfers stitched",
+			requestBody: map[string]interface{}{
+				"tickets":           [][]string{{"JFK", "LAX"}, {"SFO", "SEA"}},
+				"surface_transfers": []map[string]string{{"from": "LAX", "to": "SFO"}},
+			},
+			isValid:         true,
+			startCandidates: []interface{}{"JFK"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			resp, respBody := sendRequestTo(t, server, http.MethodPost, "/api/v1/dispatcher/itinerary/validate", tt.requestBody)
+			defer resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("Expected status code %d, got %d", http.StatusOK, resp.StatusCode)
+			}
+
+			data, ok := respBody["data"].(map[string]interface{})
+			if !ok {
+				t.Fatalf("Expected data field in response, got %v", respBody)
+			}
+
+			if data["is_valid"] != tt.isValid {
+				t.Errorf("Expected is_valid %v, got %v", tt.isValid, data["is_valid"])
+			}
+
+			if got, _ := data["start_candidates"].([]interface{}); fmt.Sprint(got) != fmt.Sprint(tt.startCandidates) {
+				t.Errorf("Expected start_candidates %v, got %v", tt.startCandidates, got)
+			}
+
+			if got, _ := data["duplicates"].([]interface{}); len(got) != tt.duplicates {
+				t.Errorf("Expected %d duplicates, got %v", tt.duplicates, got)
+			}
+
+			if got, _ := data["unbalanced_nodes"].([]interface{}); len(got) != tt.unbalancedNodes {
+				t.Errorf("Expected %d unbalanced nodes, got %v", tt.unbalancedNodes, got)
+			}
+		})
+	}
+}
+
+func TestHandleItineraryTicketObjects(t *testing.T) {
+	t.Parallel()
+
+	server := setupTestServer(t)
+
+	resp, respBody := sendRequest(t, server, http.MethodPost, map[string]interface{}{
+		"tickets": []interface{}{
+			map[string]interface{}{"from": "LAX", "to": "DXB", "flight_no": "EK216", "price": 899.5},
+			[]string{"JFK", "LAX"},
+		},
+	})
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	data, ok := respBody["data"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected data field in response, got %v", respBody)
+	}
+
+	legs, ok := data["legs"].([]interface{})
+	if !ok || len(legs) != 2 {
+		t.Fatalf("Expected two legs in data, got %v", data)
+	}
+
+	expected := []struct {
+		flightNo    interface{}
+		from        string
+		ticketIndex float64
+	}{
+		{from: "JFK", ticketIndex: 1, flightNo: nil},
+		{from: "LAX", ticketIndex: 0, flightNo: "EK216"},
+	}
+	for i, want := range expected {
+		leg, _ := legs[i].(map[string]interface{})
+		ticket, _ := leg["ticket"].(map[string]interface{})
+		if leg["from"] != want.from || leg["ticket_index"] != want.ticketIndex || ticket["flight_no"] != want.flightNo {
+			t.Errorf("Expected legs[%d] from %s with ticket_index %v and flight_no %v, got %v", i, want.from, want.ticketIndex, want.flightNo, leg)
+		}
+	}
+}
+
+func TestHandleItineraryBlackoutWarnings(t *testing.T) {
+	t.Parallel()
+
+	server := setupTenantServer(t, "acme")
+
+	resp, _ := sendRequestTo(t, server, http.MethodPut, "/api/v1/blackout-calendars", map[string]interface{}{
+		"name":  "christmas",
+		"dates": []string{"2025-12-25"},
+	})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	resp, respBody := sendRequest(t, server, http.MethodPost, map[string]interface{}{
+		"tickets": []interface{}{
+			map[string]interface{}{"from": "JFK", "to": "LAX", "departs_at": "2025-12-24T08:00:00-05:00"},
+			map[string]interface{}{"from": "LAX", "to": "DXB", "departs_at": "2025-12-25T23:30:00-08:00"},
+		},
+	})
+	defer resp.Body.Close()
+
+	data, ok := respBody["data"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected data field in response, got %v", respBody)
+	}
+
+	warnings, ok := data["warnings"].([]interface{})
+	if !ok || len(warnings) != 1 {
+		t.Fatalf("Expected a single warning, got %v", data["warnings"])
+	}
+
+	warning, _ := warnings[0].(map[string]interface{})
+	if warning["code"] != "blackout_date" || warning["leg_index"] != float64(1) || warning["calendar"] != "christmas" {
+		t.Errorf("Expected a blackout_date warning for leg 1, got %v", warning)
+	}
+}
+
+func TestHandleBlackoutCalendars(t *testing.T) {
+	t.Parallel()
+
+	server := setupTestServer(t, handler.WithTenants(middleware.Tenants{APIKeys: map[string]string{"acme-key": "acme", "globex-key": "globex"}}))
+
+	calendar := map[string]interface{}{"name": "christmas", "dates": []string{"2025-12-25"}}
+	steps := []struct {
+		server     *httptest.Server
+		body       interface{}
+		name       string
+		key        string
+		method     string
+		path       string
+		wantStatus int
+	}{
+		{name: "put", server: server, key: "acme-key", method: http.MethodPut, body: calendar, wantStatus: http.StatusOK},
+		{
+			name: "too many dates", server: server, key: "acme-key", method: http.MethodPut,
+			body:       map[string]interface{}{"name": "every day", "dates": make([]string, blackout.DefaultMaxDates+1)},
+			wantStatus: http.StatusBadRequest,
+		},
+		{name: "another tenant", server: server, key: "globex-key", method: http.MethodDelete, path: "?name=christmas", wantStatus: http.StatusNotFound},
+		{name: "unauthenticated", server: server, method: http.MethodGet, wantStatus: http.StatusUnauthorized},
+		{name: "no tenants", server: setupTestServer(t), method: http.MethodGet, wantStatus: http.StatusForbidden},
+		{name: "delete", server: server, key: "acme-key", method: http.MethodDelete, path: "?name=christmas", wantStatus: http.StatusOK},
+	}
+	for _, step := range steps {
+		// The X-Tenant-Id header is ignored: the tenant is the one of the API key.
+		header := http.Header{handler.TenantHeader: {"acme"}}
+		if step.key != "" {
+			header.Set("Authorization", "Bearer "+step.key)
+		}
+		resp, _ := sendRequestWithHeader(t, step.server, step.method, "/api/v1/blackout-calendars"+step.path, header, step.body)
+		resp.Body.Close()
+		if resp.StatusCode != step.wantStatus {
+			t.Errorf("%s: expected status %d, got %d", step.name, step.wantStatus, resp.StatusCode)
+		}
+	}
+}
+
+func TestHandleItineraryEnrichment(t *testing.T) {
+	t.Parallel()
+
+	server := setupTestServer(t)
+
+	resp, respBody := sendRequest(t, server, http.MethodPost, map[string]interface{}{
+		"tickets": [][]string{{"JFK", "ZZZ"}},
+		"enrich":  true,
+	})
+	defer resp.Body.Close()
+
+	data, ok := respBody["data"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected data field in response, got %v", respBody)
+	}
+
+	stops, ok := data["stops"].([]interface{})
+	if !ok || len(stops) != 2 {
+		t.Fatalf("Expected two stops, got %v", data["stops"])
+	}
+
+	jfk, _ := stops[0].(map[string]interface{})
+	if jfk["known"] != true || jfk["city"] != "New York" || jfk["latitude"] == nil {
+		t.Errorf("Expected JFK to be enriched, got %v", jfk)
+	}
+
+	unknown, _ := stops[1].(map[string]interface{})
+	if unknown["known"] != false || unknown["code"] != "ZZZ" || unknown["name"] != nil {
+		t.Errorf("Expected ZZZ to be reported as unknown, got %v", unknown)
+	}
+}
+
+func TestHandleItineraryDistances(t *testing.T) {
+	t.Parallel()
+
+	server := setupTestServer(t)
+
+	resp, respBody := sendRequest(t, server, http.MethodPost, map[string]interface{}{
+		"tickets": [][]string{{"LHR", "ZZZ"}, {"JFK", "LHR"}},
+	})
+	defer resp.Body.Close()
+
+	data, ok := respBody["data"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected data field in response, got %v", respBody)
+	}
+
+	distances, ok := data["distances"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected distances field in data, got %v", data)
+	}
+
+	if distances["complete"] != false {
+		t.Errorf("Expected incomplete distances because of ZZZ, got %v", distances["complete"])
+	}
+
+	total, _ := distances["total_kilometers"].(float64)
+	if total < 5500 || total > 5580 {
+		t.Errorf("Expected JFK -> LHR to be about 5540 km, got %v", total)
+	}
+
+	legs, _ := distances["legs"].([]interface{})
+	if len(legs) != 2 {
+		t.Fatalf("Expected two legs, got %v", legs)
+	}
+	if last, _ := legs[1].(map[string]interface{}); last["kilometers"] != nil {
+		t.Errorf("Expected unknown distance for LHR -> ZZZ, got %v", last)
+	}
+
+	emissions, ok := data["emissions"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected emissions field in data, got %v", data)
+	}
+	if totalCO2, _ := emissions["total_kg_co2"].(float64); totalCO2 <= 0 {
+		t.Errorf("Expected a positive CO2 estimate, got %v", emissions["total_kg_co2"])
+	}
+}
+
+func TestHandleItineraryTiming(t *testing.T) {
+	t.Parallel()
+
+	server := setupTestServer(t)
+
+	resp, respBody := sendRequest(t, server, http.MethodPost, map[string]interface{}{
+		"tickets": []map[string]string{
+			{"from": "LHR", "to": "DXB", "departs_at": "2026-03-02T09:30:00Z", "arrives_at": "2026-03-02T15:30:00Z"},
+			{"from": "JFK", "to": "LHR", "departs_at": "2026-03-01T23:00:00Z", "arrives_at": "2026-03-02T06:00:00Z"},
+		},
+	})
+	defer resp.Body.Close()
+
+	data, _ := respBody["data"].(map[string]interface{})
+	timing, ok := data["timing"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected timing field in data, got %v", data)
+	}
+	if timing["total_duration"] != "16h30m0s" || timing["complete"] != true {
+		t.Errorf("Expected a complete 16h30m trip, got %v", timing)
+	}
+	legs, _ := timing["legs"].([]interface{})
+	if len(legs) != 2 {
+		t.Fatalf("Expected two legs, got %v", timing["legs"])
+	}
+	if first, _ := legs[0].(map[string]interface{}); first["departs_at"] != "2026-03-01T18:00:00-05:00" || first["duration"] != "7h0m0s" {
+		t.Errorf("Expected the JFK departure in New York time, got %v", first)
+	}
+	if last, _ := legs[1].(map[string]interface{}); last["arrives_at"] != "2026-03-02T19:30:00+04:00" || last["duration"] != "6h0m0s" {
+		t.Errorf("Expected the DXB arrival in Dubai time, got %v", last)
+	}
+	layovers, _ := timing["layovers"].([]interface{})
+	if layover, _ := layovers[0].(map[string]interface{}); len(layovers) != 1 || layover["airport"] != "LHR" || layover["duration"] != "3h30m0s" {
+		t.Errorf("Expected a 3h30m layover at LHR, got %v", timing["layovers"])
+	}
+
+	resp, respBody = sendRequest(t, server, http.MethodPost, map[string]interface{}{
+		"tickets": [][]string{{"LHR", "DXB"}, {"JFK", "LHR"}},
+	})
+	defer resp.Body.Close()
+
+	if data, _ = respBody["data"].(map[string]interface{}); data["timing"] != nil {
+		t.Errorf("Expected no timing without times, got %v", data["timing"])
+	}
+}
+
+func TestHandleItineraryPrices(t *testing.T) {
+	t.Parallel()
+
+	server := setupTestServer(t)
+	tickets := []map[string]interface{}{
+		{"from": "JFK", "to": "LHR", "price": 420, "currency": "USD"},
+		{"from": "LHR", "to": "CDG", "price": 95, "currency": "eur"},
+		{"from": "CDG", "to": "DXB", "price": 300},
+	}
+
+	tests := []struct {
+		rates      map[string]interface{}
+		tickets    interface{}
+		expected   string
+		name       string
+		statusCode int
+	}{
+		{
+			name:       "Totals per currency",
+			tickets:    tickets,
+			statusCode: http.StatusOK,
+			expected:   `{"totals":[{"amount":300},{"amount":95,"currency":"EUR"},{"amount":420,"currency":"USD"}]}`,
+		},
+		{
+			name:       "Grand total",
+			tickets:    tickets,
+			rates:      map[string]interface{}{"currency": "USD", "rates": map[string]float64{"EUR": 1.08}},
+			statusCode: http.StatusOK,
+			expected: `{"grand_total":{"amount":522.6,"currency":"USD"},"missing_rates":[""],` +
+				`"totals":[{"amount":300},{"amount":95,"currency":"EUR"},{"amount":420,"currency":"USD"}]}`,
+		},
+		{
+			name:       "No currencies",
+			tickets:    []map[string]interface{}{{"from": "JFK", "to": "LHR", "price": 420}},
+			statusCode: http.StatusOK,
+			expected:   "null",
+		},
+		{
+			name:       "Invalid rates",
+			tickets:    tickets,
+			rates:      map[string]interface{}{"rates": map[string]float64{"EUR": 1.08}},
+			statusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			body := map[string]interface{}{"tickets": tt.tickets}
+			if tt.rates != nil {
+				body["exchange_rates"] = tt.rates
+			}
+			resp, respBody := sendRequest(t, server, http.MethodPost, body)
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.statusCode {
+				t.Fatalf("Expected status code %d, got %d: %v", tt.statusCode, resp.StatusCode, respBody)
+			}
+			if tt.statusCode != http.StatusOK {
+				if code := resp.Header.Get(responder.ErrorCodeHeader); code != "BAD_REQUEST" {
+					t.Errorf("Expected error code BAD_REQUEST, got %q", code)
+				}
+
+				return
+			}
+
+			data, _ := respBody["data"].(map[string]interface{})
+			if prices, _ := json.Marshal(data["prices"]); string(prices) != tt.expected {
+				t.Errorf("Expected prices %s, got %s", tt.expected, prices)
+			}
+		})
+	}
+}
+
+func TestHandleItineraryFields(t *testing.T) {
+	t.Parallel()
+
+	server := setupTestServer(t)
+	tickets := []map[string]string{
+		{"from": "JFK", "to": "LHR", "departs_at": "2026-03-01T23:00:00Z", "arrives_at": "2026-03-02T06:00:00Z"},
+	}
+
+	tests := []struct {
+		fields     interface{}
+		name       string
+		path       string
+		expected   []string
+		missing    []string
+		statusCode int
+	}{
+		{
+			name:       "All sections",
+			path:       "/api/v1/dispatcher/itinerary",
+			statusCode: http.StatusOK,
+			expected:   []string{"itinerary_id", "linear_path", "legs", "distances", "timing"},
+		},
+		{
+			name:       "Query parameter",
+			path:       "/api/v1/dispatcher/itinerary?fields=linear_path,+Timing",
+			statusCode: http.StatusOK,
+			expected:   []string{"itinerary_id", "linear_path", "timing"},
+			missing:    []string{"legs", "distances"},
+		},
+		{
+			name:       "Request option",
+			path:       "/api/v1/dispatcher/itinerary",
+			fields:     []string{"legs"},
+			statusCode: http.StatusOK,
+			expected:   []string{"itinerary_id", "legs"},
+			missing:    []string{"linear_path", "distances", "timing"},
+		},
+		{
+			name:       "Query parameter over the request option",
+			path:       "/api/v1/dispatcher/itinerary?fields=distances",
+			fields:     []string{"legs"},
+			statusCode: http.StatusOK,
+			expected:   []string{"distances"},
+			missing:    []string{"legs"},
+		},
+		{
+			name:       "Unknown section",
+			path:       "/api/v1/dispatcher/itinerary?fields=linear_path,stops",
+			statusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			body := map[string]interface{}{"tickets": tickets}
+			if tt.fields != nil {
+				body["fields"] = tt.fields
+			}
+			resp, respBody := sendRequestTo(t, server, http.MethodPost, tt.path, body)
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.statusCode {
+				t.Fatalf("Expected status code %d, got %d: %v", tt.statusCode, resp.StatusCode, respBody)
+			}
+			data, _ := respBody["data"].(map[string]interface{})
+			for _, field := range tt.expected {
+				if _, ok := data[field]; !ok {
+					t.Errorf("Expected %s in data, got %v", field, data)
+				}
+			}
+			for _, field := range tt.missing {
+				if _, ok := data[field]; ok {
+					t.Errorf("Expected no %s in data, got %v", field, data[field])
+				}
+			}
+		})
+	}
+}
+
+func TestHandleConformance(t *testing.T) {
+	t.Parallel()
+
+	server := setupTestServer(t)
+
+	resp, respBody := sendRequestTo(t, server, http.MethodGet, "/api/v1/conformance", nil)
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	data, ok := respBody["data"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected data field in response, got %v", respBody)
+	}
+
+	vectors, ok := data["vectors"].([]interface{})
+	if !ok || len(vectors) == 0 {
+		t.Errorf("Expected conformance vectors, got %v", data)
+	}
+}
+
+func TestHandleGraphExport(t *testing.T) {
+	t.Parallel()
+
+	server := setupTestServer(t)
+
+	tests := []struct {
+		name        string
+		path        string
+		statusCode  int
+		wantMermaid bool
+	}{
+		{name: "DOT only", path: "/api/v1/dispatcher/graph/export", statusCode: http.StatusOK},
+		{name: "With Mermaid", path: "/api/v1/dispatcher/graph/export?mermaid=true", statusCode: http.StatusOK, wantMermaid: true},
+		{name: "Invalid mermaid parameter", path: "/api/v1/dispatcher/graph/export?mermaid=maybe", statusCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			resp, respBody := sendRequestTo(t, server, http.MethodPost, tt.path, map[string]interface{}{
+				"tickets": [][]string{{"JFK", "SFO"}, {"JFK", "SFO"}},
+			})
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.statusCode {
+				t.Fatalf("Expected status code %d, got %d", tt.statusCode, resp.StatusCode)
+			}
+			if tt.statusCode != http.StatusOK {
+				return
+			}
+
+			data, ok := respBody["data"].(map[string]interface{})
+			if !ok {
+				t.Fatalf("Expected data field in response, got %v", respBody)
+			}
+
+			if data["is_valid"] != false {
+				t.Errorf("Expected is_valid false, got %v", data["is_valid"])
+			}
+
+			if dot, _ := data["dot"].(string); !strings.Contains(dot, `"JFK" -> "SFO" [label="#1", color=red];`) {
+				t.Errorf("Expected the duplicate ticket to be highlighted, got %q", dot)
+			}
+
+			if _, ok := data["mermaid"]; ok != tt.wantMermaid {
+				t.Errorf("Expected mermaid present %v, got %v", tt.wantMermaid, data["mermaid"])
+			}
+		})
+	}
+}
+
+func TestHandleGraphStats(t *testing.T) {
+	t.Parallel()
+
+	server := setupTestServer(t)
+
+	resp, respBody := sendRequestTo(t, server, http.MethodPost, "/api/v1/dispatcher/graph/stats", map[string]interface{}{
+		"tickets": [][]string{{"JFK", "SFO"}, {"LAX", "SFO"}},
+	})
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	data, ok := respBody["data"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected data field in response, got %v", respBody)
+	}
+
+	if data["node_count"] != float64(3) || data["edge_count"] != float64(2) {
+		t.Errorf("Expected 3 nodes and 2 edges, got %v and %v", data["node_count"], data["edge_count"])
+	}
+
+	if data["connected"] != true || data["eulerian_path"] != false {
+		t.Errorf("Expected a connected graph without an Eulerian path, got %v", data)
+	}
+}
+
+func TestHandleItinerarySummary(t *testing.T) {
+	t.Parallel()
+
+	server := setupTestServer(t)
+
+	tests := []struct {
+		requestBody map[string]interface{}
+		name        string
+		summary     string
+		statusCode  int
+	}{
+		{
+			name:        "Default locale",
+			requestBody: map[string]interface{}{"tickets": [][]string{{"LAX", "DXB"}, {"JFK", "LAX"}}},
+			statusCode:  http.StatusOK,
+			summary:     "JFK → LAX → DXB, 2 segments, 1 stop",
+		},
+		{
+			name:        "Spanish",
+			requestBody: map[string]interface{}{"tickets": [][]string{{"JFK", "LAX"}}, "locale": "es"},
+			statusCode:  http.StatusOK,
+			summary:     "JFK → LAX, 1 tramo, 0 escalas",
+		},
+		{
+			name:        "Named template",
+			requestBody: map[string]interface{}{"tickets": [][]string{{"JFK", "LAX"}, {"LAX", "DXB"}}, "template": "endpoints"},
+			statusCode:  http.StatusOK,
+			summary:     "JFK to DXB, 1 stop",
+		},
+		{
+			name:        "Caller-supplied template",
+			requestBody: map[string]interface{}{"tickets": [][]string{{"JFK", "LAX"}}, "template": "Your trip: {{.Route}}"},
+			statusCode:  http.StatusBadRequest,
+		},
+		{
+			name:        "Unsupported locale",
+			requestBody: map[string]interface{}{"tickets": [][]string{{"JFK", "LAX"}}, "locale": "xx"},
+			statusCode:  http.StatusBadRequest,
+		},
+		{
+			name:        "Invalid itinerary",
+			requestBody: map[string]interface{}{"tickets": [][]string{{"JFK", "LAX"}, {"SFO", "LAX"}}},
+			statusCode:  http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			resp, respBody := sendRequestTo(t, server, http.MethodPost, "/api/v1/dispatcher/itinerary/summary", tt.requestBody)
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.statusCode {
+				t.Fatalf("Expected status code %d, got %d", tt.statusCode, resp.StatusCode)
+			}
+			if tt.statusCode != http.StatusOK {
+				return
+			}
+
+			data, ok := respBody["data"].(map[string]interface{})
+			if !ok {
+				t.Fatalf("Expected data field in response, got %v", respBody)
+			}
+
+			if data["summary"] != tt.summary {
+				t.Errorf("Expected summary %q, got %v", tt.summary, data["summary"])
+			}
+			path, _ := data["linear_path"].([]interface{})
+			legs, _ := data["legs"].([]interface{})
+			if len(legs) != len(path)-1 {
+				t.Fatalf("Expected a leg per step of %v, got %v", path, data["legs"])
+			}
+			if first, _ := legs[0].(map[string]interface{}); first["from"] != path[0] || first["ticket_index"] == nil {
+				t.Errorf("Expected the first leg from %v with its ticket index, got %v", path[0], first)
+			}
+		})
+	}
+}
+
+func TestHandleItineraryRepairSuggestions(t *testing.T) {
+	t.Parallel()
+
+	server := setupTestServer(t)
+
+	resp, respBody := sendRequest(t, server, http.MethodPost, map[string]interface{}{
+		"tickets":         [][]string{{"JFK", "LAX"}, {"DXB", "SFO"}},
+		"suggest_repairs": true,
+	})
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected status code %d, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+
+	details, ok := respBody["details"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected details field in response, got %v", respBody)
+	}
+
+	if _, ok = details["cause"].(map[string]interface{}); !ok {
+		t.Errorf("Expected the validation report as cause, got %v", details["cause"])
+	}
+
+	plan, _ := details["repairs"].(map[string]interface{})
+	repairs, _ := plan["repairs"].([]interface{})
+	if len(repairs) != 1 || plan["complete"] != true {
+		t.Fatalf("Expected a single complete repair, got %v", plan)
+	}
+
+	repair, _ := repairs[0].(map[string]interface{})
+	if repair["action"] != "add" || fmt.Sprint(repair["ticket"]) != "[LAX DXB]" {
+		t.Errorf("Expected to add LAX -> DXB, got %v", repair)
+	}
+}
+
+func TestHandleItineraryLimits(t *testing.T) {
+	t.Parallel()
+
+	checker, err := limits.NewChecker(limits.Limits{WarnTickets: 1, MaxTickets: 2})
+	if err != nil {
+		t.Fatalf("Failed to create limits checker: %v", err)
+	}
+	server := setupTestServer(t, handler.WithLimits(checker))
+
+	tests := []struct {
+		name       string
+		tickets    [][]string
+		statusCode int
+		warnings   int
+	}{
+		{name: "Below soft limit", tickets: [][]string{{"JFK", "LAX"}}, statusCode: http.StatusOK},
+		{name: "Above soft limit", tickets: [][]string{{"JFK", "LAX"}, {"LAX", "DXB"}}, statusCode: http.StatusOK, warnings: 1},
+		{name: "Above hard limit", tickets: [][]string{{"JFK", "LAX"}, {"LAX", "DXB"}, {"DXB", "SFO"}}, statusCode: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			resp, respBody := sendRequest(t, server, http.MethodPost, map[string]interface{}{"tickets": tt.tickets})
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.statusCode {
+				t.Fatalf("Expected status code %d, got %d", tt.statusCode, resp.StatusCode)
+			}
+
+			got, _ := respBody["warnings"].([]interface{})
+			if len(got) != tt.warnings {
+				t.Fatalf("Expected %d warnings, got %v", tt.warnings, got)
+			}
+			for _, warning := range got {
+				if warning, _ := warning.(map[string]interface{}); warning["code"] != handler.WarningSoftLimit || warning["message"] == "" {
+					t.Errorf("Expected a %s warning with a message, got %v", handler.WarningSoftLimit, warning)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleItineraryCSV(t *testing.T) {
+	t.Parallel()
+
+	server := setupTestServer(t)
+
+	tests := []struct {
+		name       string
+		path       string
+		body       string
+		want       string
+		statusCode int
+	}{
+		{
+			name:       "Default mapping",
+			path:       "/api/v1/dispatcher/itinerary",
+			body:       "LAX,DXB,300\nJFK,LAX,200\n",
+			statusCode: http.StatusOK,
+			want:       "[JFK LAX DXB]",
+		},
+		{
+			name:       "Columns parameter",
+			path:       "/api/v1/dispatcher/itinerary?columns=to,from",
+			body:       "DXB,LAX\nLAX,JFK\n",
+			statusCode: http.StatusOK,
+			want:       "[JFK LAX DXB]",
+		},
+		{
+			name:       "Invalid columns parameter",
+			path:       "/api/v1/dispatcher/itinerary?columns=from,price",
+			body:       "JFK,LAX\n",
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "Invalid CSV",
+			path:       "/api/v1/dispatcher/itinerary",
+			body:       "JFK,LAX,free\n",
+			statusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+tt.path, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatalf("Failed to create request: %v", err)
+			}
+			req.Header.Set("Content-Type", "text/csv; charset=utf-8")
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Failed to send request: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.statusCode {
+				t.Fatalf("Expected status code %d, got %d", tt.statusCode, resp.StatusCode)
+			}
+			if tt.statusCode != http.StatusOK {
+				return
+			}
+
+			var respBody map[string]interface{}
+			if err = json.NewDecoder(resp.Body).Decode(&respBody); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			data, _ := respBody["data"].(map[string]interface{})
+			if got := fmt.Sprint(data["linear_path"]); got != tt.want {
+				t.Errorf("Expected linear_path %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestHandleItineraryNDJSON(t *testing.T) {
+	t.Parallel()
+
+	checker, err := limits.NewChecker(limits.Limits{MaxTickets: 3})
+	if err != nil {
+		t.Fatalf("Failed to create limits checker: %v", err)
+	}
+	server := setupTestServer(t, handler.WithLimits(checker))
+
+	tests := []struct {
+		name       string
+		body       string
+		want       string
+		statusCode int
+	}{
+		{
+			name:       "Streamed tickets",
+			body:       "[\"LAX\", \"DXB\"]\n{\"from\": \"JFK\", \"to\": \"LAX\"}\n",
+			statusCode: http.StatusOK,
+			want:       "[JFK LAX DXB]",
+		},
+		{
+			name:       "Malformed line",
+			body:       "[\"LAX\", \"DXB\"]\nnot json\n",
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "Above hard limit",
+			body:       strings.Repeat("[\"JFK\", \"LAX\"]\n", 10),
+			statusCode: http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/api/v1/dispatcher/itinerary", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatalf("Failed to create request: %v", err)
+			}
+			req.Header.Set("Content-Type", "application/x-ndjson")
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Failed to send request: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.statusCode {
+				t.Fatalf("Expected status code %d, got %d", tt.statusCode, resp.StatusCode)
+			}
+			if tt.statusCode != http.StatusOK {
+				return
+			}
+
+			var respBody map[string]interface{}
+			if err = json.NewDecoder(resp.Body).Decode(&respBody); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			data, _ := respBody["data"].(map[string]interface{})
+			if got := fmt.Sprint(data["linear_path"]); got != tt.want {
+				t.Errorf("Expected linear_path %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestHandleItineraryXML(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(middleware.EncodingMiddleware(setupTestMux(t)))
+	t.Cleanup(server.Close)
+
+	tests := []struct {
+		name       string
+		body       string
+		want       string
+		statusCode int
+	}{
+		{
+			name: "Tickets",
+			body: `<?xml version="1.0"?><itinerary><strategy>default</strategy><tickets>` +
+				`<ticket><from>LAX</from><to>DXB</to></ticket><ticket><from>JFK</from><to>LAX</to><price>200</price></ticket>` +
+				`</tickets></itinerary>`,
+			statusCode: http.StatusOK,
+			want:       "<linear_path><item>JFK</item><item>LAX</item><item>DXB</item></linear_path>",
+		},
+		{
+			name:       "Malformed ticket",
+			body:       `<itinerary><tickets><ticket><from>JFK</from></ticket></tickets></itinerary>`,
+			statusCode: http.StatusBadRequest,
+			want:       "<field>tickets[0]</field>",
+		},
+		{
+			name:       "Malformed XML",
+			body:       `<itinerary><tickets>`,
+			statusCode: http.StatusBadRequest,
+			want:       "<err>invalid request: XML syntax error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/api/v1/dispatcher/itinerary", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatalf("Failed to create request: %v", err)
+			}
+			req.Header.Set("Content-Type", "application/xml")
+			req.Header.Set("Accept", "application/xml")
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Failed to send request: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.statusCode {
+				t.Fatalf("Expected status code %d, got %d", tt.statusCode, resp.StatusCode)
+			}
+			if got := resp.Header.Get("Content-Type"); got != "application/xml" {
+				t.Errorf("Expected an XML response, got %s", got)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			if !strings.Contains(string(body), tt.want) {
+				t.Errorf("Expected the response to contain %s, got %s", tt.want, body)
+			}
+		})
+	}
+}
+
+// uploadRequest returns a multipart request uploading the file content with the part's Content-Type.
+func uploadRequest(t *testing.T, field, filename, contentType, content string) *http.Request {
+	t.Helper()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name=%q; filename=%q`, field, filename))
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	part, err := form.CreatePart(header)
+	if err != nil {
+		t.Fatalf("Failed to create form part: %v", err)
+	}
+	_, _ = part.Write([]byte(content))
+	if err = form.Close(); err != nil {
+		t.Fatalf("Failed to close form: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/dispatcher/itinerary/upload", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	return req
+}
+
+func TestHandleItineraryUpload(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		field       string
+		filename    string
+		contentType string
+		content     string
+		wantCode    string
+		wantPath    string
+		upload      handler.Upload
+		wantStatus  int
+	}{
+		{
+			name:       "CSV by extension",
+			field:      "file",
+			filename:   "tickets.csv",
+			content:    "from,to\nLAX,DXB\nJFK,LAX\n",
+			wantStatus: http.StatusOK,
+			wantPath:   `"linear_path":["JFK","LAX","DXB"]`,
+		},
+		{
+			name:        "NDJSON by Content-Type",
+			field:       "file",
+			filename:    "tickets",
+			contentType: "application/x-ndjson",
+			content:     "[\"LAX\",\"DXB\"]\n[\"JFK\",\"LAX\"]\n",
+			wantStatus:  http.StatusOK,
+			wantPath:    `"linear_path":["JFK","LAX","DXB"]`,
+		},
+		{
+			name:       "Unsupported file",
+			field:      "file",
+			filename:   "tickets.xlsx",
+			content:    "PK",
+			wantStatus: http.StatusUnsupportedMediaType,
+			wantCode:   "UNSUPPORTED_MEDIA_TYPE",
+		},
+		{
+			name:       "Missing file",
+			field:      "tickets",
+			filename:   "tickets.csv",
+			content:    "from,to\nJFK,LAX\n",
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "BAD_REQUEST",
+		},
+		{
+			name:       "Too large",
+			field:      "file",
+			filename:   "tickets.csv",
+			content:    "from,to\nJFK,LAX\n",
+			upload:     handler.Upload{MaxBytes: 8},
+			wantStatus: http.StatusRequestEntityTooLarge,
+			wantCode:   "BODY_TOO_LARGE",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mux := setupTestMux(t, handler.WithUploads(tt.upload, nil))
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, uploadRequest(t, tt.field, tt.filename, tt.contentType, tt.content))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body)
+			}
+			if got := rec.Header().Get(responder.ErrorCodeHeader); got != tt.wantCode {
+				t.Errorf("Expected error code %q, got %q", tt.wantCode, got)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantPath) {
+				t.Errorf("Expected the response to contain %s, got %s", tt.wantPath, rec.Body)
+			}
+		})
+	}
+}
+
+func TestHandleItineraryUploadJob(t *testing.T) {
+	t.Parallel()
+
+	store, err := jobs.New(clock.Real{}, jobs.Config{Enabled: true})
+	if err != nil {
+		t.Fatalf("Failed to create job store: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = store.Close(context.Background())
+	})
+	mux := setupTestMux(t, handler.WithUploads(handler.Upload{AsyncBytes: 8}, store))
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, uploadRequest(t, "file", "tickets.csv", "text/csv", "from,to\nLAX,DXB\nJFK,LAX\n"))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusAccepted, rec.Code, rec.Body)
+	}
+	location := rec.Header().Get("Location")
+	if !strings.HasPrefix(location, "/api/v1/dispatcher/jobs/") {
+		t.Fatalf("Expected the job's URL in Location, got %q", location)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		rec = httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, location, nil))
+		if rec.Code != http.StatusAccepted || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"linear_path":["JFK","LAX","DXB"]`) {
+		t.Errorf("Expected the job's reconstruction, got %d: %s", rec.Code, rec.Body)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, location, nil)
+	req.Header.Set("X-Tenant-Id", "acme")
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected the job hidden from other tenants, got %d", rec.Code)
+	}
+}
+
+func TestHandleItinerarySource(t *testing.T) {
+	t.Parallel()
+
+	files := http.NewServeMux()
+	files.HandleFunc("GET /tickets.csv", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("from,to\nLAX,DXB\nJFK,LAX\n"))
+	})
+	files.HandleFunc("GET /tickets.xlsx", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("PK"))
+	})
+	fileServer := httptest.NewServer(files)
+	t.Cleanup(fileServer.Close)
+
+	fetcher, err := remote.New(clock.Real{}, remote.Config{
+		Enabled: true,
+		Hosts:   []string{"127.0.0.1"},
+		Schemes: []string{remote.SchemeHTTP},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create fetcher: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		body       string
+		wantCode   string
+		wantPath   string
+		opts       []handler.Option
+		wantStatus int
+	}{
+		{
+			name:       "Fetched",
+			body:       `{"source_url": "` + fileServer.URL + `/tickets.csv"}`,
+			opts:       []handler.Option{handler.WithSources(fetcher)},
+			wantStatus: http.StatusOK,
+			wantPath:   `"linear_path":["JFK","LAX","DXB"]`,
+		},
+		{
+			name:       "Disabled",
+			body:       `{"source_url": "` + fileServer.URL + `/tickets.csv"}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "FEATURE_DISABLED",
+		},
+		{
+			name:       "With tickets",
+			body:       `{"source_url": "` + fileServer.URL + `/tickets.csv", "tickets": [["JFK", "LAX"]]}`,
+			opts:       []handler.Option{handler.WithSources(fetcher)},
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "BAD_REQUEST",
+		},
+		{
+			name:       "Host not allowed",
+			body:       `{"source_url": "http://example.com/tickets.csv"}`,
+			opts:       []handler.Option{handler.WithSources(fetcher)},
+			wantStatus: http.StatusForbidden,
+			wantCode:   "FORBIDDEN",
+		},
+		{
+			name:       "Unsupported file",
+			body:       `{"source_url": "` + fileServer.URL + `/tickets.xlsx"}`,
+			opts:       []handler.Option{handler.WithSources(fetcher)},
+			wantStatus: http.StatusUnsupportedMediaType,
+			wantCode:   "UNSUPPORTED_MEDIA_TYPE",
+		},
+		{
+			name:       "Not found",
+			body:       `{"source_url": "` + fileServer.URL + `/missing.csv"}`,
+			opts:       []handler.Option{handler.WithSources(fetcher)},
+			wantStatus: http.StatusBadGateway,
+			wantCode:   "UNAVAILABLE",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mux := setupTestMux(t, tt.opts...)
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/dispatcher/itinerary", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body)
+			}
+			if got := rec.Header().Get(responder.ErrorCodeHeader); got != tt.wantCode {
+				t.Errorf("Expected error code %q, got %q", tt.wantCode, got)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantPath) {
+				t.Errorf("Expected the response to contain %s, got %s", tt.wantPath, rec.Body)
+			}
+		})
+	}
+}
+
+func TestHandleItineraryExport(t *testing.T) {
+	t.Parallel()
+
+	mux := setupTestMux(t)
+	timed := `{"tickets": [{"from": "LAX", "to": "DXB", "departs_at": "2026-05-01T20:05:00Z"},` +
+		` {"from": "JFK", "to": "LAX", "flight_no": "AA1", "departs_at": "2026-05-01T12:00:00Z", "arrives_at": "2026-05-01T18:30:00Z"}]}`
+
+	tests := []struct {
+		name            string
+		target          string
+		accept          string
+		body            string
+		wantCode        string
+		wantBody        string
+		wantContentType string
+		wantStatus      int
+		inline          bool
+	}{
+		{
+			name:            "Accept",
+			target:          "/api/v1/dispatcher/itinerary",
+			accept:          "text/calendar, application/json;q=0.5",
+			body:            timed,
+			wantStatus:      http.StatusOK,
+			wantBody:        "SUMMARY:JFK → LAX (AA1)",
+			wantContentType: "text/calendar; charset=utf-8",
+		},
+		{
+			name:            "Format parameter",
+			target:          "/api/v1/dispatcher/itinerary?format=ics",
+			body:            timed,
+			wantStatus:      http.StatusOK,
+			wantBody:        "SUMMARY:LAX → DXB",
+			wantContentType: "text/calendar; charset=utf-8",
+		},
+		{
+			name:            "GeoJSON",
+			target:          "/api/v1/dispatcher/itinerary",
+			accept:          "application/geo+json",
+			body:            timed,
+			wantStatus:      http.StatusOK,
+			wantBody:        `"type":"LineString"`,
+			wantContentType: "application/geo+json; charset=utf-8",
+		},
+		{
+			name:            "KML",
+			target:          "/api/v1/dispatcher/itinerary?format=kml",
+			body:            timed,
+			wantStatus:      http.StatusOK,
+			wantBody:        "<LineString>",
+			wantContentType: "application/vnd.google-earth.kml+xml; charset=utf-8",
+		},
+		{
+			name:            "Text",
+			target:          "/api/v1/dispatcher/itinerary",
+			accept:          "text/plain",
+			body:            timed,
+			wantStatus:      http.StatusOK,
+			wantBody:        "JFK → LAX → DXB, 2 legs, 1 stop in Los Angeles\n",
+			wantContentType: "text/plain; charset=utf-8",
+			inline:          true,
+		},
+		{
+			name:            "Markdown",
+			target:          "/api/v1/dispatcher/itinerary?format=markdown",
+			body:            timed,
+			wantStatus:      http.StatusOK,
+			wantBody:        "| 1 | AA1 | JFK | LAX |",
+			wantContentType: "text/markdown; charset=utf-8",
+			inline:          true,
+		},
+		{
+			name:       "Unknown airports",
+			target:     "/api/v1/dispatcher/itinerary?format=geojson",
+			body:       `{"tickets": [["AAA", "BBB"]]}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "BAD_REQUEST",
+		},
+		{
+			name:       "JSON preferred",
+			target:     "/api/v1/dispatcher/itinerary",
+			accept:     "application/json, text/calendar;q=0.5",
+			body:       timed,
+			wantStatus: http.StatusOK,
+			wantBody:   `"linear_path":["JFK","LAX","DXB"]`,
+		},
+		{
+			name:       "Unknown format",
+			target:     "/api/v1/dispatcher/itinerary?format=pdf",
+			body:       timed,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "BAD_REQUEST",
+		},
+		{
+			name:       "No departure times",
+			target:     "/api/v1/dispatcher/itinerary?format=ics",
+			body:       `{"tickets": [["JFK", "LAX"]]}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "BAD_REQUEST",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body)
+			}
+			if got := rec.Header().Get(responder.ErrorCodeHeader); got != tt.wantCode {
+				t.Errorf("Expected error code %q, got %q", tt.wantCode, got)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("Expected the response to contain %s, got %s", tt.wantBody, rec.Body)
+			}
+			if tt.wantContentType == "" {
+				return
+			}
+			if got := rec.Header().Get("Content-Type"); got != tt.wantContentType {
+				t.Errorf("Expected Content-Type %s, got %s", tt.wantContentType, got)
+			}
+			got := rec.Header().Get("Content-Disposition")
+			if tt.inline && got != "" {
+				t.Errorf("Expected the export inline, got Content-Disposition %s", got)
+			}
+			if !tt.inline && !strings.HasPrefix(got, "attachment; filename=itinerary-") {
+				t.Errorf("Expected an attachment named after the itinerary, got %s", got)
+			}
+		})
+	}
+}
+
+func TestHandleItineraryOrdering(t *testing.T) {
+	t.Parallel()
+
+	mux := setupTestMux(t)
+
+	tests := []struct {
+		name       string
+		options    string
+		wantCode   string
+		wantPath   string
+		wantStatus int
+	}{
+		{name: "Default", wantStatus: http.StatusOK, wantPath: `"linear_path":["JFK","ATL","JFK","SFO","ATL"]`},
+		{
+			name:       "Largest first",
+			options:    `"tie_break": "largest_first", `,
+			wantStatus: http.StatusOK,
+			wantPath:   `"linear_path":["JFK","SFO","ATL","JFK","ATL"]`,
+		},
+		{
+			name:       "Input order",
+			options:    `"tie_break": "input_order", `,
+			wantStatus: http.StatusOK,
+			wantPath:   `"linear_path":["JFK","ATL","JFK","SFO","ATL"]`,
+		},
+		{
+			name:       "Preferred hubs",
+			options:    `"preferred_hubs": {"SFO": 1}, `,
+			wantStatus: http.StatusOK,
+			wantPath:   `"linear_path":["JFK","SFO","ATL","JFK","ATL"]`,
+		},
+		{name: "Unknown tie-break", options: `"tie_break": "random", `, wantStatus: http.StatusBadRequest, wantCode: "UNKNOWN_TIE_BREAK"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			body := `{` + tt.options + `"tickets": [["JFK", "ATL"], ["JFK", "SFO"], ["SFO", "ATL"], ["ATL", "JFK"]]}`
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/dispatcher/itinerary", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body)
+			}
+			if got := rec.Header().Get(responder.ErrorCodeHeader); got != tt.wantCode {
+				t.Errorf("Expected error code %q, got %q", tt.wantCode, got)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantPath) {
+				t.Errorf("Expected the response to contain %s, got %s", tt.wantPath, rec.Body)
+			}
+		})
+	}
+}
+
+func TestHandleItineraryNormalization(t *testing.T) {
+	t.Parallel()
+
+	server := setupTestServer(t)
+
+	resp, respBody := sendRequest(t, server, http.MethodPost, map[string]interface{}{
+		"tickets":         [][]string{{"jfk ", "LHR"}, {"SFO", "EWR"}},
+		"normalize_codes": true,
+		"aliases":         map[string][]string{"NYC": {"JFK", "LGA", "EWR"}},
+	})
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %v", http.StatusOK, resp.StatusCode, respBody)
+	}
+	data, _ := respBody["data"].(map[string]interface{})
+	if path := fmt.Sprint(data["linear_path"]); path != "[SFO NYC LHR]" {
+		t.Errorf("Expected the path through NYC, got %s", path)
+	}
+	changes, _ := data["normalization"].([]interface{})
+	if len(changes) != 2 {
+		t.Fatalf("Expected 2 code changes, got %v", data["normalization"])
+	}
+	if first, _ := changes[0].(map[string]interface{}); first["original"] != "jfk " || first["normalized"] != "NYC" || first["reason"] != "alias" {
+		t.Errorf("Expected jfk replaced by NYC, got %v", first)
+	}
+	legs, _ := data["legs"].([]interface{})
+	leg, _ := legs[1].(map[string]interface{})
+	if ticket, _ := leg["ticket"].(map[string]interface{}); leg["from"] != "NYC" || ticket["from"] != "jfk " {
+		t.Errorf("Expected the leg from NYC with the original ticket, got %v", leg)
+	}
+
+	resp, respBody = sendRequest(t, server, http.MethodPost, map[string]interface{}{
+		"tickets": [][]string{{"JFK", "LHR"}},
+		"aliases": map[string][]string{"NYC": {"JFK"}, "QNY": {"JFK"}},
+	})
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest || resp.Header.Get(responder.ErrorCodeHeader) != "BAD_REQUEST" {
+		t.Errorf("Expected a bad request for conflicting aliases, got %d %v", resp.StatusCode, respBody)
+	}
+}
+
+func TestHandleItinerarySurfaceTransfers(t *testing.T) {
+	t.Parallel()
+
+	server := setupTestServer(t)
+
+	resp, respBody := sendRequest(t, server, http.MethodPost, map[string]interface{}{
+		"tickets":           []map[string]interface{}{{"from": "SFO", "to": "BOS", "price": 150}, {"from": "JFK", "to": "LAX", "price": 200}},
+		"surface_transfers": []map[string]string{{"from": "LAX", "to": "SFO"}},
+	})
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %v", http.StatusOK, resp.StatusCode, respBody)
+	}
+	data, _ := respBody["data"].(map[string]interface{})
+	if path := fmt.Sprint(data["linear_path"]); path != "[JFK LAX SFO BOS]" {
+		t.Errorf("Expected the path stitched at LAX, got %s", path)
+	}
+	if data["total_price"] != 350.0 {
+		t.Errorf("Expected the surface transfer to cost nothing, got %v", data["total_price"])
+	}
+	legs, _ := data["legs"].([]interface{})
+	if len(legs) != 3 {
+		t.Fatalf("Expected 3 legs, got %v", data["legs"])
+	}
+	surface, _ := legs[1].(map[string]interface{})
+	if _, ok := surface["ticket"]; ok || surface["surface_transfer"] != true || surface["ticket_index"] != -1.0 {
+		t.Errorf("Expected a surface transfer without a ticket, got %v", surface)
+	}
+	if last, _ := legs[2].(map[string]interface{}); last["ticket_index"] != 0.0 || last["surface_transfer"] != nil {
+		t.Errorf("Expected the flight from SFO, got %v", last)
+	}
+
+	resp, respBody = sendRequest(t, server, http.MethodPost, map[string]interface{}{
+		"tickets":           [][]string{{"JFK", "LAX"}},
+		"surface_transfers": []map[string]string{{"from": "LAX"}},
+	})
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest || resp.Header.Get(responder.ErrorCodeHeader) != "BAD_REQUEST" {
+		t.Errorf("Expected a bad request for a transfer without a destination, got %d %v", resp.StatusCode, respBody)
+	}
+}
+
+func TestHandlePassengerItineraries(t *testing.T) {
+	t.Parallel()
+
+	server := setupTestServer(t)
+
+	resp, respBody := sendRequestTo(t, server, http.MethodPost, "/api/v1/dispatcher/itinerary/passengers", map[string]interface{}{
+		"tickets": []map[string]interface{}{
+			{"passenger": "alice", "from": "LAX", "to": "DXB"},
+			{"passenger": "bob", "from": "JFK", "to": "LAX"},
+			{"passenger": "alice", "from": "JFK", "to": "LAX"},
+			{"passenger": "carol", "from": "SFO", "to": "ATL"},
+			{"passenger": "bob", "from": "LAX", "to": "DXB"},
+		},
+	})
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %v", http.StatusOK, resp.StatusCode, respBody)
+	}
+	data, _ := respBody["data"].(map[string]interface{})
+	if data["reconstructions"] != 2.0 {
+		t.Errorf("Expected alice and bob to share a reconstruction, got %v", data["reconstructions"])
+	}
+	itineraries, _ := data["itineraries"].([]interface{})
+	if len(itineraries) != 3 {
+		t.Fatalf("Expected 3 itineraries, got %v", data["itineraries"])
+	}
+
+	expected := []struct {
+		passenger string
+		path      string
+		tickets   string
+	}{
+		{passenger: "alice", path: "[JFK LAX DXB]", tickets: "[2 0]"},
+		{passenger: "bob", path: "[JFK LAX DXB]", tickets: "[1 4]"},
+		{passenger: "carol", path: "[SFO ATL]", tickets: "[3]"},
+	}
+	for i, want := range expected {
+		itinerary, _ := itineraries[i].(map[string]interface{})
+		legs, _ := itinerary["legs"].([]interface{})
+		tickets := make([]interface{}, 0, len(legs))
+		for _, leg := range legs {
+			leg, _ := leg.(map[string]interface{})
+			tickets = append(tickets, leg["ticket_index"])
+		}
+		if itinerary["passenger"] != want.passenger || fmt.Sprint(itinerary["linear_path"]) != want.path || fmt.Sprint(tickets) != want.tickets {
+			t.Errorf("Expected %s to fly %s with tickets %s, got %v", want.passenger, want.path, want.tickets, itinerary)
+		}
+	}
+
+	resp, respBody = sendRequestTo(t, server, http.MethodPost, "/api/v1/dispatcher/itinerary/passengers", map[string]interface{}{
+		"tickets": []interface{}{[]string{"JFK", "LAX"}, map[string]string{"passenger": "alice", "from": "LAX", "to": "DXB"}},
+	})
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest || !strings.Contains(fmt.Sprint(respBody), "tickets[0].passenger") {
+		t.Errorf("Expected a bad request for the ticket without a passenger, got %d %v", resp.StatusCode, respBody)
+	}
+
+	resp, respBody = sendRequestTo(t, server, http.MethodPost, "/api/v1/dispatcher/itinerary/passengers", map[string]interface{}{
+		"tickets": []map[string]string{
+			{"passenger": "alice", "from": "JFK", "to": "LAX"},
+			{"passenger": "bob", "from": "JFK", "to": "LAX"},
+			{"passenger": "bob", "from": "SFO", "to": "ATL"},
+		},
+	})
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest || resp.Header.Get(responder.ErrorCodeHeader) != "MULTIPLE_STARTS" ||
+		!strings.Contains(fmt.Sprint(respBody), `passenger "bob"`) {
+		t.Errorf("Expected bob's tickets to be rejected, got %d %v", resp.StatusCode, respBody)
+	}
+}
+
+func TestHandleItineraryEncodings(t *testing.T) {
+	t.Parallel()
+
+	server := setupTestServer(t)
+	document := []byte(`{"tickets": [["LAX", "DXB"], {"from": "JFK", "to": "LAX", "price": 200}]}`)
+
+	tests := []struct {
+		codec      codec.Codec
+		name       string
+		statusCode int
+		corrupt    bool
+	}{
+		{name: "MessagePack", codec: codec.MsgPack{}, statusCode: http.StatusOK},
+		{name: "Protobuf", codec: codec.Protobuf{}, statusCode: http.StatusOK},
+		{name: "Malformed MessagePack", codec: codec.MsgPack{}, corrupt: true, statusCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			body, err := tt.codec.Encode(document)
+			if err != nil {
+				t.Fatalf("Failed to encode the request: %v", err)
+			}
+			if tt.corrupt {
+				body = body[:len(body)-1]
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/api/v1/dispatcher/itinerary", bytes.NewReader(body))
+			if err != nil {
+				t.Fatalf("Failed to create request: %v", err)
+			}
+			req.Header.Set("Content-Type", tt.codec.MediaType())
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Failed to send request: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.statusCode {
+				t.Fatalf("Expected status code %d, got %d", tt.statusCode, resp.StatusCode)
+			}
+			if tt.statusCode != http.StatusOK {
+				return
+			}
+
+			var respBody map[string]interface{}
+			if err = json.NewDecoder(resp.Body).Decode(&respBody); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			data, _ := respBody["data"].(map[string]interface{})
+			if got := fmt.Sprint(data["linear_path"], data["total_price"]); got != "[JFK LAX DXB] 200" {
+				t.Errorf("Expected linear_path [JFK LAX DXB] and total_price 200, got %s", got)
+			}
+		})
+	}
+}
+
+func TestHandleLivenessMessage(t *testing.T) {
+	t.Parallel()
+
+	catalog, err := messages.New(map[string]string{"service.live": "Alive"})
+	if err != nil {
+		t.Fatalf("Failed to create message catalog: %v", err)
+	}
+
+	tests := []struct {
+		server *httptest.Server
+		name   string
+		want   string
+	}{
+		{name: "Default message", server: setupTestServer(t), want: "All services are up and running"},
+		{name: "Overridden message", server: setupTestServer(t, handler.WithMessages(catalog)), want: "Alive"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, tt.server.URL+"/api/v1/liveness", nil)
+			if err != nil {
+				t.Fatalf("Failed to create request: %v", err)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Failed to send request: %v", err)
+			}
+			defer resp.Body.Close()
+
+			var respBody struct {
+				Msg    string `json:"msg"`
+				MsgKey string `json:"msg_key"`
+			}
+			if err = json.NewDecoder(resp.Body).Decode(&respBody); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if respBody.Msg != tt.want {
+				t.Errorf("Expected msg %q, got %q", tt.want, respBody.Msg)
+			}
+			if respBody.MsgKey != string(messages.ServiceLive) {
+				t.Errorf("Expected msg_key %q, got %q", messages.ServiceLive, respBody.MsgKey)
+			}
+		})
+	}
+}
+
+func TestHandleTranslatedMessages(t *testing.T) {
+	t.Parallel()
+
+	translator, err := i18n.New(i18n.Embedded())
+	if err != nil {
+		t.Fatalf("Failed to load translations: %v", err)
+	}
+	server := setupTestServer(t, handler.WithTranslator(translator))
+
+	tests := []struct {
+		body           any
+		name           string
+		method         string
+		path           string
+		acceptLanguage string
+		wantLanguage   string
+		wantMsg        string
+		wantErr        string
+	}{
+		{
+			name:           "Translated message",
+			method:         http.MethodGet,
+			path:           "/api/v1/liveness",
+			acceptLanguage: "de-CH, en;q=0.5",
+			wantLanguage:   "de",
+			wantMsg:        "Alle Dienste laufen",
+		},
+		{
+			name:           "English first",
+			method:         http.MethodGet,
+			path:           "/api/v1/liveness",
+			acceptLanguage: "en-US, de;q=0.5",
+			wantMsg:        "All services are up and running",
+		},
+		{
+			name:           "Unsupported language",
+			method:         http.MethodGet,
+			path:           "/api/v1/liveness",
+			acceptLanguage: "ja",
+			wantMsg:        "All services are up and running",
+		},
+		{
+			name:           "Translated error",
+			method:         http.MethodPost,
+			path:           "/api/v1/dispatcher/itinerary",
+			body:           map[string]any{"tickets": [][]string{{"JFK", "LAX"}, {"LAX", "JFK"}}},
+			acceptLanguage: "fr",
+			wantLanguage:   "fr",
+			wantErr:        "Les billets ont plusieurs points de départ possibles",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			var body io.Reader
+			if tt.body != nil {
+				payload, err := json.Marshal(tt.body)
+				if err != nil {
+					t.Fatalf("Failed to encode request: %v", err)
+				}
+				body = bytes.NewReader(payload)
+			}
+			req, err := http.NewRequestWithContext(ctx, tt.method, server.URL+tt.path, body)
+			if err != nil {
+				t.Fatalf("Failed to create request: %v", err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Accept-Language", tt.acceptLanguage)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Failed to send request: %v", err)
+			}
+			defer resp.Body.Close()
+
+			var respBody struct {
+				Msg string `json:"msg"`
+				Err string `json:"err"`
+			}
+			if err = json.NewDecoder(resp.Body).Decode(&respBody); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if respBody.Msg != tt.wantMsg || respBody.Err != tt.wantErr {
+				t.Errorf("Expected msg %q and err %q, got %q and %q", tt.wantMsg, tt.wantErr, respBody.Msg, respBody.Err)
+			}
+			if got := resp.Header.Get("Content-Language"); got != tt.wantLanguage {
+				t.Errorf("Expected Content-Language %q, got %q", tt.wantLanguage, got)
+			}
+			if got := resp.Header.Get("Vary"); !strings.Contains(got, "Accept-Language") {
+				t.Errorf("Expected Vary to list Accept-Language, got %q", got)
+			}
+		})
+	}
+}
+
+func TestHandleReadinessChecks(t *testing.T) {
+	t.Parallel()
+
+	errDown := errors.New("connection refused")
+	tests := []struct {
+		server     *httptest.Server
+		wantChecks map[string]string
+		name       string
+		wantStatus int
+	}{
+		{name: "Without checks", server: setupTestServer(t), wantStatus: http.StatusOK},
+		{
+			name:       "Passing check",
+			server:     setupTestServer(t, handler.WithReadinessCheck("redis", func(context.Context) error { return nil })),
+			wantStatus: http.StatusOK,
+			wantChecks: map[string]string{"redis": "ok"},
+		},
+		{
+			name:       "Failing check",
+			server:     setupTestServer(t, handler.WithReadinessCheck("redis", func(context.Context) error { return errDown })),
+			wantStatus: http.StatusServiceUnavailable,
+			wantChecks: map[string]string{"redis": errDown.Error()},
+		},
+		{
+			name: "Failing non-critical check",
+			server: setupTestServer(t,
+				handler.WithReadinessCheck("redis", func(context.Context) error { return nil }),
+				handler.WithReadinessCheck("nats", func(context.Context) error { return errDown }, health.NonCritical()),
+			),
+			wantStatus: http.StatusOK,
+			wantChecks: map[string]string{"nats": errDown.Error(), "redis": "ok"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, tt.server.URL+"/api/v1/readiness", nil)
+			if err != nil {
+				t.Fatalf("Failed to create request: %v", err)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Failed to send request: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, resp.StatusCode)
+			}
+
+			var respBody struct {
+				Data    handler.ReadinessResponse `json:"data"`
+				Details handler.ReadinessResponse `json:"details"`
+			}
+			if err = json.NewDecoder(resp.Body).Decode(&respBody); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			checks := respBody.Data.Checks
+			if resp.StatusCode != http.StatusOK {
+				checks = respBody.Details.Checks
+			}
+			if fmt.Sprint(checks) != fmt.Sprint(tt.wantChecks) {
+				t.Errorf("Expected checks %v, got %v", tt.wantChecks, checks)
+			}
+		})
+	}
+}
+
+func TestHandleItineraryStream(t *testing.T) {
+	t.Parallel()
+
+	server := setupTestServer(t)
+
+	tests := []struct {
+		name       string
+		body       string
+		want       []string
+		statusCode int
+	}{
+		{
+			name:       "Streamed path",
+			body:       `{"tickets": [["LAX", "DXB"], ["JFK", "LAX"], ["DXB", "SFO"]]}`,
+			statusCode: http.StatusOK,
+			want:       []string{"JFK", "LAX", "DXB", "SFO"},
+		},
+		{
+			name:       "Invalid tickets",
+			body:       `{"tickets": [["JFK", "LAX"], ["JFK", "LAX"]]}`,
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "Unsupported strategy",
+			body:       `{"strategy": "wrapped", "tickets": [["JFK", "LAX"]]}`,
+			statusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/api/v1/dispatcher/itinerary/stream", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatalf("Failed to create request: %v", err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Failed to send request: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.statusCode {
+				t.Fatalf("Expected status code %d, got %d", tt.statusCode, resp.StatusCode)
+			}
+			if tt.statusCode != http.StatusOK {
+				return
+			}
+			if got := resp.Header.Get("Content-Type"); got != "application/x-ndjson" {
+				t.Errorf("Expected Content-Type application/x-ndjson, got %s", got)
+			}
+
+			path := make([]string, len(tt.want))
+			var trailer handler.PathTrailer
+			decoder := json.NewDecoder(resp.Body)
+			for decoder.More() {
+				var line struct {
+					handler.PathTrailer
+					handler.PathElement
+				}
+				if err = decoder.Decode(&line); err != nil {
+					t.Fatalf("Failed to decode line: %v", err)
+				}
+				if line.Done {
+					trailer = line.PathTrailer
+
+					continue
+				}
+				path[line.Index] = line.Airport
+			}
+
+			if strings.Join(path, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Expected path %v, got %v", tt.want, path)
+			}
+			if !trailer.Done || trailer.Length != len(tt.want) {
+				t.Errorf("Expected trailer with length %d, got %+v", len(tt.want), trailer)
+			}
+		})
+	}
+}
+
+// flushRecorder records a response and counts the times it is flushed.
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	flushes int
+}
+
+func (f *flushRecorder) Flush() {
+	f.flushes++
+	f.ResponseRecorder.Flush()
+}
+
+func TestHandleItineraryStreamFlushes(t *testing.T) {
+	t.Parallel()
+
+	mux := setupTestMux(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/dispatcher/itinerary/stream",
+		strings.NewReader(`{"tickets": [["LAX", "DXB"], ["JFK", "LAX"], ["DXB", "SFO"]]}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, rec.Code)
+	}
+	if airports := 4; rec.flushes < airports {
+		t.Errorf("Expected a flush per streamed airport, %d, got %d", airports, rec.flushes)
+	}
+}
+
+func TestHandleItineraryContextDone(t *testing.T) {
+	t.Parallel()
+
+	mux := setupTestMux(t)
+
+	tests := []struct {
+		name       string
+		path       string
+		statusCode int
+		expired    bool
+	}{
+		{name: "Client disconnected", path: "/api/v1/dispatcher/itinerary", statusCode: handler.StatusClientClosedRequest},
+		{name: "Deadline exceeded", path: "/api/v1/dispatcher/itinerary", expired: true, statusCode: http.StatusGatewayTimeout},
+		{name: "Summary deadline exceeded", path: "/api/v1/dispatcher/itinerary/summary", expired: true, statusCode: http.StatusGatewayTimeout},
+		{name: "Stream client disconnected", path: "/api/v1/dispatcher/itinerary/stream", statusCode: handler.StatusClientClosedRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			if tt.expired {
+				ctx, cancel = context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+				defer cancel()
+			}
+
+			body := `{"tickets": [["JFK", "LAX"], ["LAX", "DXB"]]}`
+			req := httptest.NewRequestWithContext(ctx, http.MethodPost, tt.path, strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			if rec.Code != tt.statusCode {
+				t.Errorf("Expected status code %d, got %d: %s", tt.statusCode, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandleItineraryAggregatedProblems(t *testing.T) {
+	t.Parallel()
+
+	checker, err := limits.NewChecker(limits.Limits{MaxTickets: 3})
+	if err != nil {
+		t.Fatalf("Failed to create limits checker: %v", err)
+	}
+	server := setupTestServer(t, handler.WithLimits(checker))
+
+	tests := []struct {
+		name string
+		body string
+		want []handler.Problem
+	}{
+		{
+			name: "Every problem at once",
+			body: `{"constraints": {"max_stops": "2"}, "enrich": "yes", "strategy": 1, "tickets": [["JFK", "LAX"], 42, ["LAX"], ["LAX", "DXB"]]}`,
+			want: []handler.Problem{
+				{Field: "constraints.max_stops", Message: "expected int, got string"},
+				{Field: "enrich", Message: "expected bool, got string"},
+				{Field: "strategy", Message: "expected string, got number"},
+				{Field: "tickets[1]", Message: "a ticket must be a [source, destination] pair or an object, got number"},
+				{Field: "tickets[2]", Message: "has 1 element, want [source, destination]"},
+				{Field: "tickets", Message: "too many tickets: 4 tickets, the limit is 3"},
+			},
+		},
+		{
+			name: "Not an object",
+			body: `[["JFK", "LAX"]]`,
+			want: []handler.Problem{
+				{Message: "json: cannot unmarshal array into Go value of type handler.ReconstructItineraryRequest"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			resp, respBody := sendRequest(t, server, http.MethodPost, json.RawMessage(tt.body))
+			defer resp.Body.Close()
+
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Fatalf("Expected status code %d, got %d", http.StatusBadRequest, resp.StatusCode)
+			}
+
+			details, err := json.Marshal(respBody["details"])
+			if err != nil {
+				t.Fatalf("Failed to marshal details: %v", err)
+			}
+			var requestErr handler.RequestError
+			if err = json.Unmarshal(details, &requestErr); err != nil {
+				t.Fatalf("Failed to decode details: %v", err)
+			}
+			if fmt.Sprint(requestErr.Problems) != fmt.Sprint(tt.want) {
+				t.Errorf("Expected problems %v, got %v", tt.want, requestErr.Problems)
+			}
+		})
+	}
+}
+
+func TestHandleItineraryBodyLimit(t *testing.T) {
+	t.Parallel()
+
+	checker, err := limits.NewChecker(limits.Limits{MaxBodyBytes: 64})
+	if err != nil {
+		t.Fatalf("Failed to create limits checker: %v", err)
+	}
+	server := httptest.NewServer(checker.Middleware(setupTestMux(t, handler.WithLimits(checker))))
+	t.Cleanup(server.Close)
+
+	tests := []struct {
+		name        string
+		contentType string
+		statusCode  int
+		chunked     bool
+	}{
+		{name: "Content-Length above the limit", contentType: "application/json", statusCode: http.StatusRequestEntityTooLarge},
+		{name: "Chunked JSON above the limit", contentType: "application/json", chunked: true, statusCode: http.StatusRequestEntityTooLarge},
+		{name: "Chunked NDJSON above the limit", contentType: "application/x-ndjson", chunked: true, statusCode: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			body := strings.Repeat(`["JFK", "LAX"]`+"\n", 10)
+			if tt.contentType == "application/json" {
+				body = `{"tickets": [` + strings.Repeat(`["JFK", "LAX"], `, 10) + `["LAX", "JFK"]]}`
+			}
+			var reader io.Reader = strings.NewReader(body)
+			if tt.chunked {
+				// Hide the length so the body is sent chunked and only the reader enforces the limit.
+				reader = io.MultiReader(reader)
+			}
+
+			req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/api/v1/dispatcher/itinerary", reader)
+			if err != nil {
+				t.Fatalf("Failed to create request: %v", err)
+			}
+			req.Header.Set("Content-Type", tt.contentType)
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Failed to send request: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.statusCode {
+				t.Fatalf("Expected status code %d, got %d", tt.statusCode, resp.StatusCode)
+			}
+
+			var respBody struct {
+				Details limits.BodyError `json:"details"`
+			}
+			if err = json.NewDecoder(resp.Body).Decode(&respBody); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if respBody.Details.MaxBodyBytes != 64 {
+				t.Errorf("Expected max_body_bytes 64, got %d", respBody.Details.MaxBodyBytes)
+			}
+		})
+	}
+}
+
+func TestInspectorFeed(t *testing.T) {
+	t.Parallel()
+
+	server := setupTestServer(t, handler.WithInspector(inspector.New(16)), handler.WithAdminToken("s3cret"), handler.WithAdminReadToken("r3ad"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// The read token neither arms capture nor reads the feed.
+	for _, rt := range []struct{ method, path string }{
+		{method: http.MethodPost, path: "/api/v1/admin/inspector/capture?request_id=req-42"},
+		{method: http.MethodGet, path: "/api/v1/admin/inspector"},
+	} {
+		refused, _ := sendAdminRequest(t, server, rt.method, rt.path, "r3ad", nil)
+		refused.Body.Close()
+		if refused.StatusCode != http.StatusUnauthorized {
+			t.Errorf("%s %s: expected status %d with the read token, got %d", rt.method, rt.path, http.StatusUnauthorized, refused.StatusCode)
+		}
+	}
+
+	capture, respBody := sendAdminRequest(t, server, http.MethodPost, "/api/v1/admin/inspector/capture?request_id=req-42", "s3cret", nil)
+	capture.Body.Close()
+	if capture.StatusCode != http.StatusOK || respBody["msg_key"] != "inspector.capture_armed" {
+		t.Fatalf("Expected capture to be armed, got %d %v", capture.StatusCode, respBody)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/v1/admin/inspector", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer s3cret")
+	feed, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+	defer feed.Body.Close()
+	if got := feed.Header.Get("Content-Type"); got != "text/event-stream" {
+		t.Fatalf("Expected Content-Type text/event-stream, got %s", got)
+	}
+
+	body := `{"tickets":[["JFK","LAX"]]}`
+	itineraryReq, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/api/v1/dispatcher/itinerary", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	itineraryReq.Header.Set("Content-Type", "application/json")
+	itineraryReq.Header.Set(inspector.RequestIDHeader, "req-42")
+	itineraryReq.Header.Set(handler.TenantHeader, "acme")
+	resp, err := http.DefaultClient.Do(itineraryReq)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	resp.Body.Close()
+
+	scanner := bufio.NewScanner(feed.Body)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+
+		var event inspector.Event
+		if err = json.Unmarshal([]byte(data), &event); err != nil {
+			t.Fatalf("Failed to decode event: %v", err)
+		}
+		if event.RequestID != "req-42" || event.Tenant != "acme" || event.Status != http.StatusOK || event.BodyBytes != int64(len(body)) {
+			t.Errorf("Unexpected event %+v", event)
+		}
+		if string(event.Payload) != body {
+			t.Errorf("Expected captured payload %s, got %s", body, event.Payload)
+		}
+
+		return
+	}
+	t.Fatalf("Feed ended without an event: %v", scanner.Err())
+}
+
+func TestInspectorDisabled(t *testing.T) {
+	t.Parallel()
+
+	server := setupTestServer(t, handler.WithAdminToken("s3cret"))
+
+	resp, _ := sendAdminRequest(t, server, http.MethodPost, "/api/v1/admin/inspector/capture?request_id=req-42", "s3cret", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status code %d, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+}
+
+func TestHandleItineraryStrictDecoding(t *testing.T) {
+	t.Parallel()
+
+	server := setupTestServer(t)
+
+	tests := []struct {
+		name string
+		path string
+		body string
+		want []handler.Problem
+	}{
+		{
+			name: "Unknown field",
+			path: "/api/v1/dispatcher/itinerary",
+			body: `{"ticket": [["JFK", "LAX"]], "tickets": [["JFK", "LAX"]]}`,
+			want: []handler.Problem{{Field: "ticket", Message: "unknown field"}},
+		},
+		{
+			name: "Malformed tickets",
+			path: "/api/v1/dispatcher/itinerary",
+			body: `{"tickets": [["JFK", "LAX"], ["LAX", "DXB", "SFO"], ["DXB", ""], {"from": "SFO"}]}`,
+			want: []handler.Problem{
+				{Field: "tickets[1]", Message: "has 3 elements, want [source, destination]"},
+				{Field: "tickets[2]", Message: "destination is empty"},
+				{Field: "tickets[3]", Message: "destination is empty"},
+			},
+		},
+		{
+			name: "Unknown ticket field",
+			path: "/api/v1/dispatcher/itinerary",
+			body: `{"tickets": [["JFK", "LAX"], {"from": "LAX", "to": "DXB", "seat": "12A"}]}`,
+			want: []handler.Problem{{Field: "tickets[1]", Message: "unknown field"}},
+		},
+		{
+			name: "Unknown passenger ticket field",
+			path: "/api/v1/dispatcher/itinerary/passengers",
+			body: `{"tickets": [{"passenger": "alice", "from": "JFK", "to": "LAX", "seat": "12A"}]}`,
+			want: []handler.Problem{{Field: "tickets[0]", Message: "unknown field"}},
+		},
+		{
+			name: "Passenger tickets with another bad field",
+			path: "/api/v1/dispatcher/itinerary/passengers",
+			body: `{"strategy": 5, "tickets": [{"passenger": "alice", "from": "JFK", "to": "LAX"}]}`,
+			want: []handler.Problem{{Field: "strategy", Message: "expected string, got number"}},
+		},
+		{
+			name: "Malformed tickets to validate",
+			path: "/api/v1/dispatcher/itinerary/validate",
+			body: `{"tickets": [["JFK"]]}`,
+			want: []handler.Problem{{Field: "tickets[0]", Message: "has 1 element, want [source, destination]"}},
+		},
+		{
+			name: "Unknown summary field",
+			path: "/api/v1/dispatcher/itinerary/summary",
+			body: `{"language": "fr", "tickets": [["JFK", "LAX"]]}`,
+			want: []handler.Problem{{Field: "language", Message: "unknown field"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			resp, respBody := sendRequestTo(t, server, http.MethodPost, tt.path, json.RawMessage(tt.body))
+			defer resp.Body.Close()
+
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Fatalf("Expected status code %d, got %d", http.StatusBadRequest, resp.StatusCode)
+			}
+
+			details, err := json.Marshal(respBody["details"])
+			if err != nil {
+				t.Fatalf("Failed to marshal details: %v", err)
+			}
+			var requestErr handler.RequestError
+			if err = json.Unmarshal(details, &requestErr); err != nil {
+				t.Fatalf("Failed to decode details: %v", err)
+			}
+			if fmt.Sprint(requestErr.Problems) != fmt.Sprint(tt.want) {
+				t.Errorf("Expected problems %v, got %v", tt.want, requestErr.Problems)
+			}
+		})
+	}
+
+	t.Run("Trailing data", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		body := strings.NewReader(`{"tickets": [["JFK", "LAX"]]} {"tickets": [["LAX", "DXB"]]}`)
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/api/v1/dispatcher/itinerary", body)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, resp.StatusCode)
+		}
+	})
+}
+
+func TestHandleItineraryCache(t *testing.T) {
+	t.Parallel()
+
+	server := setupTestServer(t, handler.WithCache(cache.New[*dispatcher.Result](clock.Real{}, time.Minute, 10)), handler.WithAdminToken("s3cret"))
+
+	tests := []struct {
+		name    string
+		body    string
+		bypass  string
+		outcome string
+		status  int
+	}{
+		{name: "First request", body: `{"tickets":[["LAX","DXB"],["JFK","LAX"]]}`, outcome: "miss", status: http.StatusOK},
+		{name: "Same tickets as objects", body: `{"tickets": [{"from": "LAX", "to": "DXB"}, ["JFK", "LAX"]]}`, outcome: "hit", status: http.StatusOK},
+		{name: "Bypass", body: `{"tickets":[["LAX","DXB"],["JFK","LAX"]]}`, bypass: "true", outcome: "bypass", status: http.StatusOK},
+		{name: "Other strategy", body: `{"tickets":[["LAX","DXB"],["JFK","LAX"]],"strategy":"wrapped"}`, outcome: "miss", status: http.StatusOK},
+		{name: "Input order is not cached", body: `{"tickets":[["LAX","DXB"],["JFK","LAX"]],"tie_break":"input_order"}`, status: http.StatusOK},
+		{name: "Invalid tickets", body: `{"tickets":[["JFK","LAX"],["LAX","JFK"]]}`, outcome: "miss", status: http.StatusBadRequest},
+		{name: "Invalid tickets are not cached", body: `{"tickets":[["JFK","LAX"],["LAX","JFK"]]}`, outcome: "miss", status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/api/v1/dispatcher/itinerary", strings.NewReader(tt.body))
+		if err != nil {
+			cancel()
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if tt.bypass != "" {
+			req.Header.Set(handler.CacheBypassHeader, tt.bypass)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			cancel()
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		resp.Body.Close()
+		cancel()
+
+		if resp.StatusCode != tt.status || resp.Header.Get(handler.CacheStatusHeader) != tt.outcome {
+			t.Errorf("%s: expected %d %s, got %d %s", tt.name, tt.status, tt.outcome, resp.StatusCode, resp.Header.Get(handler.CacheStatusHeader))
+		}
+	}
+
+	// The same tickets in another order share the cached result, with legs pointing to their own tickets.
+	resp, respBody := sendRequestTo(t, server, http.MethodPost, "/api/v1/dispatcher/itinerary", map[string]interface{}{
+		"tickets": [][]string{{"JFK", "LAX"}, {"LAX", "DXB"}},
+	})
+	resp.Body.Close()
+	data, _ := respBody["data"].(map[string]interface{})
+	legs, _ := data["legs"].([]interface{})
+	firstLeg, _ := legs[0].(map[string]interface{})
+	if resp.Header.Get(handler.CacheStatusHeader) != "hit" || firstLeg["ticket_index"] != 0.0 {
+		t.Errorf("Expected a hit with the first leg on ticket 0, got %s %v", resp.Header.Get(handler.CacheStatusHeader), legs)
+	}
+
+	resp, respBody = sendAdminRequest(t, server, http.MethodGet, "/api/v1/admin/cache", "s3cret", nil)
+	resp.Body.Close()
+	stats, _ := respBody["data"].(map[string]interface{})
+	if stats["hits"] != 2.0 || stats["misses"] != 4.0 || stats["bypassed"] != 1.0 || stats["entries"] != 2.0 {
+		t.Errorf("Unexpected cache stats %v", stats)
+	}
+}
+
+func TestHandleSavedItineraries(t *testing.T) {
+	t.Parallel()
+
+	clk := clock.NewFake(time.Date(2025, 5, 1, 8, 0, 0, 0, time.UTC))
+	itineraries := store.NewMemory(clk)
+	server := setupTenantServer(t, "acme", handler.WithItineraryStore(itineraries))
+
+	ids := []string{}
+	for _, tickets := range [][][]string{{{"JFK", "LAX"}}, {{"LAX", "DXB"}}, {{"DXB", "SFO"}}} {
+		resp, respBody := sendRequestTo(t, server, http.MethodPost, "/api/v1/dispatcher/itinerary", map[string]interface{}{"tickets": tickets})
+		resp.Body.Close()
+		data, _ := respBody["data"].(map[string]interface{})
+		id, _ := data["id"].(string)
+		if resp.StatusCode != http.StatusOK || id == "" {
+			t.Fatalf("Expected a saved itinerary, got %d %v", resp.StatusCode, respBody)
+		}
+		ids = append(ids, id)
+		clk.Advance(time.Second)
+	}
+
+	resp, respBody := sendRequestTo(t, server, http.MethodGet, "/api/v1/dispatcher/itinerary/"+ids[0], nil)
+	resp.Body.Close()
+	data, _ := respBody["data"].(map[string]interface{})
+	result, _ := data["result"].(map[string]interface{})
+	if resp.StatusCode != http.StatusOK || data["id"] != ids[0] || fmt.Sprint(result["linear_path"]) != "[JFK LAX]" {
+		t.Errorf("Expected the first itinerary, got %d %v", resp.StatusCode, respBody)
+	}
+
+	// Saving the same ticket set again keeps its ID, and the set is found by its itinerary ID.
+	resp, respBody = sendRequestTo(t, server, http.MethodPost, "/api/v1/dispatcher/itinerary", map[string]interface{}{
+		"tickets": [][]string{{"JFK", "LAX"}}, "strategy": "wrapped",
+	})
+	resp.Body.Close()
+	data, _ = respBody["data"].(map[string]interface{})
+	itineraryID, _ := data["itinerary_id"].(string)
+	if data["id"] != ids[0] || itineraryID != resp.Header.Get(handler.ItineraryIDHeader) {
+		t.Errorf("Expected the ID %s again and the itinerary ID in the header, got %v", ids[0], respBody)
+	}
+	resp, respBody = sendRequestTo(t, server, http.MethodGet, "/api/v1/dispatcher/itinerary/by-hash/"+itineraryID, nil)
+	resp.Body.Close()
+	data, _ = respBody["data"].(map[string]interface{})
+	if resp.StatusCode != http.StatusOK || data["id"] != ids[0] || data["itinerary_id"] != itineraryID {
+		t.Errorf("Expected the first itinerary by hash, got %d %v", resp.StatusCode, respBody)
+	}
+
+	pages := []struct {
+		query      string
+		wantIDs    []string
+		wantNext   interface{}
+		wantStatus int
+	}{
+		{query: "?limit=2", wantIDs: []string{ids[2], ids[1]}, wantNext: 2.0, wantStatus: http.StatusOK},
+		{query: "?limit=2&offset=2", wantIDs: []string{ids[0]}, wantStatus: http.StatusOK},
+		{query: "?limit=0", wantStatus: http.StatusBadRequest},
+		{query: "?offset=-1", wantStatus: http.StatusBadRequest},
+	}
+	for _, page := range pages {
+		resp, respBody = sendRequestTo(t, server, http.MethodGet, "/api/v1/dispatcher/itineraries"+page.query, nil)
+		resp.Body.Close()
+		if resp.StatusCode != page.wantStatus {
+			t.Errorf("%s: expected status %d, got %d", page.query, page.wantStatus, resp.StatusCode)
+
+			continue
+		}
+		if page.wantStatus != http.StatusOK {
+			continue
+		}
+		data, _ = respBody["data"].(map[string]interface{})
+		listed, _ := data["itineraries"].([]interface{})
+		gotIDs := []string{}
+		for _, item := range listed {
+			itinerary, _ := item.(map[string]interface{})
+			id, _ := itinerary["id"].(string)
+			gotIDs = append(gotIDs, id)
+		}
+		if fmt.Sprint(gotIDs) != fmt.Sprint(page.wantIDs) || data["next_offset"] != page.wantNext {
+			t.Errorf("%s: expected %v next %v, got %v next %v", page.query, page.wantIDs, page.wantNext, gotIDs, data["next_offset"])
+		}
+	}
+
+	resp, _ = sendRequestTo(t, server, http.MethodDelete, "/api/v1/dispatcher/itinerary/"+ids[0], nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected delete status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	resp, _ = sendRequestTo(t, server, http.MethodGet, "/api/v1/dispatcher/itinerary/"+ids[0], nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status %d after delete, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+
+	// Saved itineraries are only served to their authenticated tenant, whatever tenant a request claims.
+	guarded := []struct {
+		server     *httptest.Server
+		name       string
+		wantStatus int
+	}{
+		{name: "another tenant", server: setupTenantServer(t, "globex", handler.WithItineraryStore(itineraries)), wantStatus: http.StatusNotFound},
+		{name: "no tenants", server: setupTestServer(t, handler.WithItineraryStore(itineraries)), wantStatus: http.StatusForbidden},
+		{name: "no storage", server: setupTestServer(t), wantStatus: http.StatusNotFound},
+		{
+			name:       "unauthenticated",
+			server:     setupTestServer(t, handler.WithItineraryStore(itineraries), handler.WithTenants(middleware.Tenants{APIKeys: map[string]string{"key": "acme"}})),
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+	for _, tc := range guarded {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, tc.server.URL+"/api/v1/dispatcher/itinerary/"+ids[1], nil)
+		req.Header.Set(handler.TenantHeader, "acme")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s: failed to send request: %v", tc.name, err)
+		}
+		resp.Body.Close()
+		cancel()
+		if resp.StatusCode != tc.wantStatus {
+			t.Errorf("%s: expected status %d, got %d", tc.name, tc.wantStatus, resp.StatusCode)
+		}
+	}
+}
+
+// recordingPublisher keeps the events published.
+type recordingPublisher struct {
+	events []events.Event
+	mu     sync.Mutex
+}
+
+func (p *recordingPublisher) Publish(_ context.Context, event events.Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.events = append(p.events, event)
+
+	return nil
+}
+
+func TestHandleItineraryEvents(t *testing.T) {
+	t.Parallel()
+
+	publisher := &recordingPublisher{}
+	server := setupTestServer(t, handler.WithEventPublisher(publisher))
+
+	requests := []map[string]any{
+		{"tickets": [][]string{{"JFK", "LAX"}, {"LAX", "DXB"}}, "strategy": "wrapped"},
+		{"tickets": [][]string{{"JFK", "LAX"}, {"LAX", "JFK"}}},
+	}
+	ids := make([]string, 0, len(requests))
+	for _, body := range requests {
+		resp, _ := sendRequest(t, server, http.MethodPost, body)
+		resp.Body.Close()
+		ids = append(ids, resp.Header.Get(handler.ItineraryIDHeader))
+	}
+
+	// A malformed request is rejected before reconstruction and publishes nothing.
+	resp, _ := sendRequest(t, server, http.MethodPost, map[string]any{"tickets": [][]string{{"JFK"}}})
+	resp.Body.Close()
+
+	publisher.mu.Lock()
+	defer publisher.mu.Unlock()
+
+	want := []events.Event{
+		{Type: events.TypeItineraryReconstructed, ItineraryID: ids[0], Tenant: "default", Strategy: "wrapped", Tickets: 2},
+		{Type: events.TypeItineraryRejected, ItineraryID: ids[1], Tenant: "default", ErrorCode: "MULTIPLE_STARTS", Tickets: 2},
+	}
+	if len(publisher.events) != len(want) {
+		t.Fatalf("Expected %d events, got %+v", len(want), publisher.events)
+	}
+	for i, event := range publisher.events {
+		if event.At.IsZero() || event.ItineraryID == "" {
+			t.Errorf("Expected event %d to have a time and an itinerary ID, got %+v", i, event)
+		}
+		event.At, event.Duration = time.Time{}, 0
+		if event != want[i] {
+			t.Errorf("Expected event %d to be %+v, got %+v", i, want[i], event)
+		}
+	}
+}
+
+func TestHandleItineraryETag(t *testing.T) {
+	t.Parallel()
+
+	server := setupTenantServer(t, "acme", handler.WithItineraryStore(store.NewMemory(clock.Real{})))
+
+	// send sends a request with If-None-Match and returns its status, ETag and body.
+	send := func(method, path, body, ifNoneMatch string) (int, string, string) {
+		t.Helper()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		req, err := http.NewRequestWithContext(ctx, method, server.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		req.Header.Set("If-None-Match", ifNoneMatch)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		defer resp.Body.Close()
+		payload, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("Failed to read response: %v", err)
+		}
+
+		return resp.StatusCode, resp.Header.Get("ETag"), string(payload)
+	}
+
+	const path = "/api/v1/dispatcher/itinerary"
+	tickets := `{"tickets": [["JFK", "LAX"], ["LAX", "DXB"]]}`
+	status, etag, body := send(http.MethodPost, path, tickets, "")
+	if status != http.StatusOK || !strings.HasPrefix(etag, `"`) {
+		t.Fatalf("Expected 200 with an ETag, got %d and %q", status, etag)
+	}
+
+	if repeated, again, empty := send(http.MethodPost, path, tickets, `"other", `+etag); repeated != http.StatusNotModified || again != etag || empty != "" {
+		t.Errorf("Expected 304 with the same ETag and no body for a repeat submission, got %d, %q and %q", repeated, again, empty)
+	}
+
+	// The same tickets in another order share the itinerary ID prefix, not the ETag, since their legs differ.
+	status, reordered, _ := send(http.MethodPost, path, `{"tickets": [["LAX", "DXB"], ["JFK", "LAX"]]}`, etag)
+	if status != http.StatusOK || reordered == etag || reordered[:17] != etag[:17] {
+		t.Errorf("Expected 200 with another ETag of the same prefix for reordered tickets, got %d and %q (first %q)", status, reordered, etag)
+	}
+
+	var resp struct {
+		Data handler.ReconstructItineraryResponse `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(body), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	status, saved, _ := send(http.MethodGet, path+"/"+resp.Data.ID, "", "")
+	if status != http.StatusOK || saved == "" {
+		t.Fatalf("Expected the saved itinerary with an ETag, got %d and %q", status, saved)
+	}
+	if status, _, _ = send(http.MethodGet, path+"/by-hash/"+resp.Data.ItineraryID, "", "W/"+saved); status != http.StatusNotModified {
+		t.Errorf("Expected 304 for a saved itinerary matching a weak ETag, got %d", status)
+	}
+}
+
+func TestHandleUnmatchedRoutes(t *testing.T) {
+	t.Parallel()
+
+	server := setupTestServer(t)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantErr    string
+		wantAllow  string
+		wantCode   string
+		wantStatus int
+	}{
+		{
+			name:       "Wrong method",
+			method:     http.MethodGet,
+			path:       "/api/v1/dispatcher/itinerary",
+			wantStatus: http.StatusMethodNotAllowed,
+			wantErr:    handler.ErrMethodNotAllowed.Error(),
+			wantAllow:  "POST, OPTIONS",
+			wantCode:   "METHOD_NOT_ALLOWED",
+		},
+		{
+			name:       "Wrong method with path value",
+			method:     http.MethodPut,
+			path:       "/api/v1/dispatcher/itinerary/5XQ7LM2KPA3VJ4TRZ6W8NBHCDE",
+			wantStatus: http.StatusMethodNotAllowed,
+			wantErr:    handler.ErrMethodNotAllowed.Error(),
+			wantAllow:  "GET, HEAD, DELETE, OPTIONS",
+			wantCode:   "METHOD_NOT_ALLOWED",
+		},
+		{
+			name:       "Unknown path",
+			method:     http.MethodGet,
+			path:       "/api/v1/unknown",
+			wantStatus: http.StatusNotFound,
+			wantErr:    handler.ErrNotFound.Error(),
+			wantCode:   "NOT_FOUND",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			resp, body := sendRequestTo(t, server, tt.method, tt.path, nil)
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, resp.StatusCode)
+			}
+			if resp.Header.Get("Allow") != tt.wantAllow {
+				t.Errorf("Expected Allow %q, got %q", tt.wantAllow, resp.Header.Get("Allow"))
+			}
+			if resp.Header.Get(responder.ErrorCodeHeader) != tt.wantCode {
+				t.Errorf("Expected error code %q, got %q", tt.wantCode, resp.Header.Get(responder.ErrorCodeHeader))
+			}
+			if body["err"] != tt.wantErr {
+				t.Errorf("Expected error %q, got %v", tt.wantErr, body["err"])
+			}
+		})
+	}
+}
+
+func TestHandleOptions(t *testing.T) {
+	t.Parallel()
+
+	server := setupTestServer(t)
+
+	tests := []struct {
+		path       string
+		wantAllow  string
+		wantStatus int
+	}{
+		{path: "/api/v1/dispatcher/itinerary", wantStatus: http.StatusNoContent, wantAllow: "POST, OPTIONS"},
+		{path: "/api/v1/blackout-calendars", wantStatus: http.StatusNoContent, wantAllow: "GET, HEAD, PUT, DELETE, OPTIONS"},
+		{path: "/api/v1/unknown", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			t.Parallel()
+
+			req, err := http.NewRequestWithContext(t.Context(), http.MethodOptions, server.URL+tt.path, nil)
+			if err != nil {
+				t.Fatalf("Failed to create request: %v", err)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Failed to send request: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, resp.StatusCode)
+			}
+			if resp.Header.Get("Allow") != tt.wantAllow {
+				t.Errorf("Expected Allow %q, got %q", tt.wantAllow, resp.Header.Get("Allow"))
+			}
+		})
+	}
+}
+
+func TestHandleDiscovery(t *testing.T) {
+	t.Parallel()
+
+	server := setupTestServer(t)
+
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, server.URL+"/api/v1", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	var body struct {
+		Data handler.DiscoveryResponse `json:"data"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	want := map[string]string{
+		"/api/v1/dispatcher/itinerary":      "POST, OPTIONS",
+		"/api/v1/dispatcher/itinerary/{id}": "GET, HEAD, DELETE, OPTIONS",
+		"/api/v1/liveness":                  "GET, HEAD, OPTIONS",
+	}
+	for _, endpoint := range body.Data.Endpoints {
+		if methods, ok := want[endpoint.Path]; ok && strings.Join(endpoint.Methods, ", ") != methods {
+			t.Errorf("Expected %s to accept %s, got %v", endpoint.Path, methods, endpoint.Methods)
+		}
+		delete(want, endpoint.Path)
+	}
+	if len(want) > 0 {
+		t.Errorf("Expected endpoints missing from the discovery document: %v", want)
+	}
+}
+
+func TestHandleMiddleware(t *testing.T) {
+	t.Parallel()
+
+	trace := func(name string) middleware.Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Add("X-Trace", name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	mux := setupTestMux(t,
+		handler.WithMiddleware(trace("auth"), trace("tracing")),
+		handler.WithRouteMiddleware("GET /api/v1/liveness", trace("liveness")),
+	)
+
+	tests := []struct {
+		path      string
+		wantTrace string
+	}{
+		{path: "/api/v1/liveness", wantTrace: "auth,tracing,liveness"},
+		{path: "/api/v1/readiness", wantTrace: "auth,tracing"},
+		{path: "/api/v1/unknown", wantTrace: "auth,tracing"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			t.Parallel()
+
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if trace := strings.Join(rec.Header().Values("X-Trace"), ","); trace != tt.wantTrace {
+				t.Errorf("Expected trace %q, got %q", tt.wantTrace, trace)
+			}
+		})
+	}
+}
+
+// requestIDResponder is the default envelope with the request ID in a header, as an embedder would write it.
+type requestIDResponder struct {
+	responder.Envelope
+}
+
+func (rsp requestIDResponder) Success(w http.ResponseWriter, r *http.Request, status int, success responder.Success) {
+	w.Header().Set("X-Request-Id", r.Header.Get("X-Request-Id"))
+	rsp.Envelope.Success(w, r, status, success)
+}
+
+func TestHandleResponder(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		rsp        responder.Responder
+		name       string
+		body       string
+		wantField  string
+		wantHeader string
+		wantStatus int
+	}{
+		{
+			name:       "Bare success",
+			rsp:        responder.Bare{},
+			body:       `{"tickets": [["JFK", "LAX"], ["LAX", "DXB"]]}`,
+			wantStatus: http.StatusOK,
+			wantField:  "itinerary_id",
+		},
+		{
+			name:       "Bare error",
+			rsp:        responder.Bare{},
+			body:       `{"tickets": [["JFK", "LAX"], ["SFO", "DXB"]]}`,
+			wantStatus: http.StatusBadRequest,
+			wantField:  "err",
+		},
+		{
+			name:       "Custom success",
+			rsp:        requestIDResponder{},
+			body:       `{"tickets": [["JFK", "LAX"], ["LAX", "DXB"]]}`,
+			wantStatus: http.StatusOK,
+			wantField:  "data",
+			wantHeader: "req-1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mux := setupTestMux(t, handler.WithResponder(tt.rsp))
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/dispatcher/itinerary", strings.NewReader(tt.body))
+			req.Header.Set("X-Request-Id", "req-1")
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body)
+			}
+			var body map[string]any
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if _, ok := body[tt.wantField]; !ok {
+				t.Errorf("Expected a top-level %q field, got %v", tt.wantField, body)
+			}
+			if rec.Header().Get("X-Request-Id") != tt.wantHeader {
+				t.Errorf("Expected X-Request-Id %q, got %q", tt.wantHeader, rec.Header().Get("X-Request-Id"))
+			}
+			if tt.wantStatus == http.StatusOK && rec.Header().Get("ETag") == "" {
+				t.Error("Expected an ETag")
+			}
+		})
+	}
+}
+
+func TestHandleLogLevel(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		token      string
+		auth       string
+		body       string
+		wantCode   string
+		wantLevel  slog.Level
+		wantStatus int
+	}{
+		{
+			name:       "Authenticated",
+			token:      "s3cret",
+			auth:       "Bearer s3cret",
+			body:       `{"level": "debug"}`,
+			wantStatus: http.StatusOK,
+			wantLevel:  slog.LevelDebug,
+		},
+		{
+			name:       "Wrong token",
+			token:      "s3cret",
+			auth:       "Bearer guess",
+			body:       `{"level": "debug"}`,
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   "UNAUTHORIZED",
+			wantLevel:  slog.LevelInfo,
+		},
+		{
+			name:       "Without a configured token",
+			auth:       "Bearer ",
+			body:       `{"level": "debug"}`,
+			wantStatus: http.StatusForbidden,
+			wantCode:   "FORBIDDEN",
+			wantLevel:  slog.LevelInfo,
+		},
+		{
+			name:       "Unknown level",
+			token:      "s3cret",
+			auth:       "Bearer s3cret",
+			body:       `{"level": "verbose"}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "BAD_REQUEST",
+			wantLevel:  slog.LevelInfo,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, level, err := logging.New(io.Discard, logging.Config{})
+			if err != nil {
+				t.Fatalf("Failed to create logger: %v", err)
+			}
+			mux := setupTestMux(t, handler.WithLogLevel(level), handler.WithAdminToken(tt.token))
+
+			req := httptest.NewRequest(http.MethodPut, "/api/v1/admin/log-level", strings.NewReader(tt.body))
+			req.Header.Set("Authorization", tt.auth)
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body)
+			}
+			if got := rec.Header().Get(responder.ErrorCodeHeader); got != tt.wantCode {
+				t.Errorf("Expected error code %q, got %q", tt.wantCode, got)
+			}
+			if level.Level() != tt.wantLevel {
+				t.Errorf("Expected level %v, got %v", tt.wantLevel, level.Level())
+			}
+
+			if tt.token == "" {
+				return
+			}
+			rec = httptest.NewRecorder()
+			req = httptest.NewRequest(http.MethodGet, "/api/v1/admin/log-level", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			mux.ServeHTTP(rec, req)
+			var resp struct {
+				Data handler.LogLevelResponse `json:"data"`
+			}
+			if err = json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if resp.Data.Level != tt.wantLevel {
+				t.Errorf("Expected reported level %v, got %v", tt.wantLevel, resp.Data.Level)
+			}
+		})
+	}
+}
+
+func TestHandleShedding(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		body       string
+		wantCode   string
+		opts       []handler.Option
+		wantStatus int
+		wantLimit  int64
+	}{
+		{name: "Raised", body: `{"max_concurrent": 8}`, wantStatus: http.StatusOK, wantLimit: 8},
+		{name: "Invalid limit", body: `{"max_concurrent": 0}`, wantStatus: http.StatusBadRequest, wantCode: "BAD_REQUEST", wantLimit: 1},
+		{name: "Disabled", body: `{"max_concurrent": 8}`, wantStatus: http.StatusNotFound, wantCode: "FEATURE_DISABLED"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			opts := []handler.Option{handler.WithAdminToken("s3cret")}
+			var shedder *shedding.Shedder
+			if tt.wantLimit > 0 {
+				var err error
+				if shedder, err = shedding.New(shedding.Config{MaxConcurrent: 1, Enabled: true}); err != nil {
+					t.Fatalf("Failed to create shedder: %v", err)
+				}
+				opts = append(opts, handler.WithShedding(shedder))
+			}
+			mux := setupTestMux(t, opts...)
+
+			req := httptest.NewRequest(http.MethodPut, "/api/v1/admin/shedding", strings.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer s3cret")
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body)
+			}
+			if got := rec.Header().Get(responder.ErrorCodeHeader); got != tt.wantCode {
+				t.Errorf("Expected error code %q, got %q", tt.wantCode, got)
+			}
+			if got := shedder.Status().MaxConcurrent; got != tt.wantLimit {
+				t.Errorf("Expected limit %d, got %d", tt.wantLimit, got)
+			}
+		})
+	}
+}
+
+func TestAdminReadToken(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		token      string
+		readToken  string
+		auth       string
+		wantStatus int
+	}{
+		{name: "Admin token without a read token", token: "s3cret", auth: "Bearer s3cret", wantStatus: http.StatusOK},
+		{name: "Without a token nor read token", token: "s3cret", wantStatus: http.StatusUnauthorized},
+		{name: "Without configured tokens", wantStatus: http.StatusForbidden},
+		{name: "Read token", token: "s3cret", readToken: "r3ad", auth: "Bearer r3ad", wantStatus: http.StatusOK},
+		{name: "Read token only", readToken: "r3ad", auth: "Bearer r3ad", wantStatus: http.StatusOK},
+		{name: "Admin token", token: "s3cret", readToken: "r3ad", auth: "Bearer s3cret", wantStatus: http.StatusOK},
+		{name: "Without a token", token: "s3cret", readToken: "r3ad", wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mux := setupTestMux(t, handler.WithAdminToken(tt.token), handler.WithAdminReadToken(tt.readToken))
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/maintenance", nil)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body)
+			}
+		})
+	}
+
+	// The read token does not authorize changes.
+	mux := setupTestMux(t, handler.WithAdminToken("s3cret"), handler.WithAdminReadToken("r3ad"))
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/admin/maintenance", strings.NewReader(`{"enabled": true}`))
+	req.Header.Set("Authorization", "Bearer r3ad")
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d for a change with the read token, got %d", http.StatusUnauthorized, rec.Code)
+	}
+}
+
+func TestHandleMaintenance(t *testing.T) {
+	t.Parallel()
+
+	mux := setupTestMux(t, handler.WithAdminToken("s3cret"))
+	setMaintenance := func(enabled bool) {
+		t.Helper()
+
+		body := fmt.Sprintf(`{"enabled": %t}`, enabled)
+		req := httptest.NewRequest(http.MethodPut, "/api/v1/admin/maintenance", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer s3cret")
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+		}
+	}
+	reconstruct := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/dispatcher/itinerary", strings.NewReader(`{"tickets": [["JFK", "LAX"]]}`))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	setMaintenance(true)
+	rec := reconstruct()
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d in maintenance, got %d", http.StatusServiceUnavailable, rec.Code)
+	}
+	if got := rec.Header().Get(responder.ErrorCodeHeader); got != "UNAVAILABLE" {
+		t.Errorf("Expected error code UNAVAILABLE, got %q", got)
+	}
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/liveness", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected liveness served in maintenance, got %d", rec.Code)
+	}
+
+	setMaintenance(false)
+	if rec = reconstruct(); rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d after maintenance, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+}
+
+func TestHandleAdminOperations(t *testing.T) {
+	t.Parallel()
+
+	checker, err := limits.NewChecker(limits.Limits{})
+	if err != nil {
+		t.Fatalf("Failed to create checker: %v", err)
+	}
+	resultCache := cache.New[*dispatcher.Result](clock.Real{}, time.Minute, 10)
+	mux := setupTestMux(t, handler.WithAdminToken("s3cret"), handler.WithLimits(checker), handler.WithCache(resultCache))
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer s3cret")
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	if rec := serve(http.MethodPost, "/api/v1/dispatcher/itinerary", `{"tickets": [["JFK", "LAX"], ["LAX", "SFO"]]}`); rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+	rec := serve(http.MethodDelete, "/api/v1/admin/cache", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"flushed":1`) {
+		t.Errorf("Expected one result flushed, got %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = serve(http.MethodPut, "/api/v1/admin/limits", `{"warn_tickets": 2, "max_tickets": 1}`)
+	if rec.Code != http.StatusBadRequest || rec.Header().Get(responder.ErrorCodeHeader) != "BAD_REQUEST" {
+		t.Errorf("Expected invalid limits refused, got %d: %s", rec.Code, rec.Body)
+	}
+	if rec = serve(http.MethodPut, "/api/v1/admin/limits", `{"max_tickets": 1}`); rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+	rec = serve(http.MethodPost, "/api/v1/dispatcher/itinerary", `{"tickets": [["JFK", "LAX"], ["LAX", "SFO"]]}`)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected the new limit enforced, got %d: %s", rec.Code, rec.Body)
+	}
+	// A malformed request reports the limit among its problems without being counted again.
+	rec = serve(http.MethodPost, "/api/v1/dispatcher/itinerary", `{"tickets": [["JFK", "LAX"], ["LAX", "SFO"]], "bogus": true}`)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "too many tickets") {
+		t.Errorf("Expected the limit reported as a problem, got %d: %s", rec.Code, rec.Body)
+	}
+	if counts := checker.Snapshot(); len(counts) != 1 || counts[0].Rejected != 1 {
+		t.Errorf("Expected a single rejection counted, got %+v", counts)
+	}
+
+	rec = serve(http.MethodGet, "/api/v1/admin/jobs", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"enabled":false`) {
+		t.Errorf("Expected jobs reported disabled, got %d: %s", rec.Code, rec.Body)
+	}
+	rec = serve(http.MethodGet, "/api/v1/admin/config", "")
+	if rec.Code != http.StatusNotFound || rec.Header().Get(responder.ErrorCodeHeader) != "FEATURE_DISABLED" {
+		t.Errorf("Expected no configuration to report, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestHandleConfigRedactsSecrets(t *testing.T) {
+	t.Parallel()
+
+	cfg := &config.Config{
+		Admin:   middleware.Admin{Token: "admin-token", ReadToken: "read-token"},
+		Signing: middleware.Signing{Secrets: map[string]string{"partner": "signing-secret"}, Enabled: true},
+		Tenants: middleware.Tenants{APIKeys: map[string]string{"acme-api-key": "acme"}},
+	}
+	h := handler.New(slog.New(slog.DiscardHandler), dispatcher.New(), support.NewBundler(clock.Real{}, cfg, nil),
+		handler.WithAdminToken(cfg.Admin.Token), handler.WithAdminReadToken(cfg.Admin.ReadToken))
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+
+	// The read token is enough to read the configuration, so it must not reveal any credential.
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/config", nil)
+	req.Header.Set("Authorization", "Bearer read-token")
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+	for _, secret := range []string{"admin-token", "read-token", "signing-secret", "acme-api-key"} {
+		if strings.Contains(rec.Body.String(), secret) {
+			t.Errorf("Expected %q redacted, got %s", secret, rec.Body)
+		}
+	}
+	if !strings.Contains(rec.Body.String(), `"api_keys":"[REDACTED]"`) {
+		t.Errorf("Expected the API keys redacted as a whole, got %s", rec.Body)
+	}
+}
+
+func TestHandleDebug(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		path       string
+		auth       string
+		wantBody   string
+		opts       []handler.Option
+		wantStatus int
+	}{
+		{
+			name:       "Disabled",
+			path:       "/debug/buildinfo",
+			auth:       "Bearer s3cret",
+			opts:       []handler.Option{handler.WithAdminToken("s3cret")},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "Build information",
+			path:       "/debug/buildinfo",
+			auth:       "Bearer s3cret",
+			opts:       []handler.Option{handler.WithDebug(), handler.WithAdminToken("s3cret")},
+			wantStatus: http.StatusOK,
+			wantBody:   `"go_version"`,
+		},
+		{
+			name:       "Expvar",
+			path:       "/debug/vars",
+			auth:       "Bearer s3cret",
+			opts:       []handler.Option{handler.WithDebug(), handler.WithAdminToken("s3cret")},
+			wantStatus: http.StatusOK,
+			wantBody:   `"memstats"`,
+		},
+		{
+			name:       "Heap profile",
+			path:       "/debug/pprof/heap?debug=1",
+			auth:       "Bearer s3cret",
+			opts:       []handler.Option{handler.WithDebug(), handler.WithAdminToken("s3cret")},
+			wantStatus: http.StatusOK,
+			wantBody:   "heap profile",
+		},
+		{
+			name:       "Without the token",
+			path:       "/debug/pprof/",
+			opts:       []handler.Option{handler.WithDebug(), handler.WithAdminToken("s3cret")},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "Without a configured token",
+			path:       "/debug/pprof/",
+			auth:       "Bearer ",
+			opts:       []handler.Option{handler.WithDebug()},
+			wantStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mux := setupTestMux(t, tt.opts...)
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("Expected a body containing %s, got %s", tt.wantBody, rec.Body)
+			}
+		})
+	}
+}
+
+func TestAuditTrail(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	sink, err := audit.OpenFile(path)
+	if err != nil {
+		t.Fatalf("Failed to open audit file: %v", err)
+	}
+	trail, err := audit.New(context.Background(), slog.New(slog.DiscardHandler), clock.Real{}, sink, audit.Config{Node: "node-a", Key: "secret"})
+	if err != nil {
+		t.Fatalf("Failed to create audit trail: %v", err)
+	}
+	mux := setupTestMux(t, handler.WithAuditTrail(trail))
+
+	bodies := []string{`{"tickets": [["JFK", "LAX"]]}`, `{"tickets": [["JFK", "LAX"], ["LAX", "JFK"]]}`}
+	for _, body := range bodies {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/dispatcher/itinerary", strings.NewReader(body))
+		req.Header.Set(handler.TenantHeader, "acme")
+		mux.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	// Requests to routes not reconstructing itineraries are not audited.
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/liveness", nil))
+	if err = trail.Close(); err != nil {
+		t.Fatalf("Failed to close audit trail: %v", err)
+	}
+
+	records, err := audit.ReadFile(path)
+	if err != nil || len(records) != 2 {
+		t.Fatalf("Expected 2 audit records, got %+v, %v", records, err)
+	}
+	if err = audit.Verify(records, []byte("secret")); err != nil {
+		t.Errorf("Expected an unbroken chain, got %v", err)
+	}
+	for i, record := range records {
+		// Without tenant authentication, the tenant is only claimed.
+		if record.Tenant != "" || record.ClaimedTenant != "acme" || record.Path != "/api/v1/dispatcher/itinerary" || record.BodyBytes != int64(len(bodies[i])) ||
+			record.TicketsHash == "" || record.Duration <= 0 {
+			t.Errorf("Unexpected audit record %d: %+v", i, record)
+		}
+	}
+	if records[0].Status != http.StatusOK || records[0].Code != "" {
+		t.Errorf("Expected a successful first request, got %d %q", records[0].Status, records[0].Code)
+	}
+	if records[1].Status != http.StatusBadRequest || records[1].Code != "MULTIPLE_STARTS" {
+		t.Errorf("Expected the second request to fail with MULTIPLE_STARTS, got %d %q", records[1].Status, records[1].Code)
+	}
+}
+
+func TestShadowComparison(t *testing.T) {
+	t.Parallel()
+
+	// The "legacy" strategy answers by default, and returns the lexicographic path reversed.
+	legacy := dispatcher.ReconstructorFunc(func(ctx context.Context, tickets []dispatcher.Ticket, allowDuplicates bool) ([]string, []dispatcher.Leg, error) {
+		path, legs, err := dispatcher.Lexicographic().Reconstruct(ctx, tickets, allowDuplicates)
+		slices.Reverse(path)
+
+		return path, legs, err
+	})
+	d := dispatcher.New(dispatcher.WithStrategy("legacy", legacy), dispatcher.WithDefaultStrategy("legacy"))
+	comparison := shadow.New(slog.New(slog.DiscardHandler), shadow.Config{Enabled: true})
+	h := handler.New(slog.New(slog.DiscardHandler), d, support.NewBundler(clock.Real{}, nil, nil), handler.WithShadow(comparison))
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+
+	bodies := []string{
+		`{"tickets": [["JFK", "LAX"], ["LAX", "SFO"]]}`,
+		// Both strategies fail alike.
+		`{"tickets": [["JFK", "LAX"], ["LAX", "JFK"]]}`,
+		// Requests already using the shadow strategy are not compared.
+		`{"tickets": [["JFK", "LAX"], ["LAX", "SFO"]], "strategy": "default"}`,
+	}
+	for _, body := range bodies {
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/v1/dispatcher/itinerary", strings.NewReader(body)))
+	}
+	comparison.Wait()
+
+	if got, want := comparison.Stats(), (shadow.Stats{Compared: 2, PathDivergences: 1}); got != want {
+		t.Errorf("Expected shadow stats %+v, got %+v", want, got)
+	}
+}
+
+func TestStrategyHeader(t *testing.T) {
+	t.Parallel()
+
+	// The "reversed" strategy returns the lexicographic path reversed.
+	reversed := dispatcher.ReconstructorFunc(func(ctx context.Context, tickets []dispatcher.Ticket, allowDuplicates bool) ([]string, []dispatcher.Leg, error) {
+		path, legs, err := dispatcher.Lexicographic().Reconstruct(ctx, tickets, allowDuplicates)
+		slices.Reverse(path)
+
+		return path, legs, err
+	})
+	// Requests are authenticated by API key, as tenants, or by signature, as clients.
+	signatures := middleware.NewSignatures(middleware.Signing{Secrets: map[string]string{"canary": "secret"}, Enabled: true}, clock.Real{})
+	tenants := handler.WithTenants(middleware.Tenants{APIKeys: map[string]string{"acme-key": "acme", "globex-key": "globex"}})
+	newMux := func(opts ...handler.Option) http.Handler {
+		d := dispatcher.New(dispatcher.WithStrategy("reversed", reversed))
+		h := handler.New(slog.New(slog.DiscardHandler), d, support.NewBundler(clock.Real{}, nil, nil), append(opts, tenants)...)
+		mux := http.NewServeMux()
+		h.RegisterRoutes(mux)
+
+		return signatures.Middleware(mux)
+	}
+	nobody := newMux(handler.WithStrategySelection(handler.StrategySelection{}))
+	allowList := newMux(handler.WithStrategySelection(handler.StrategySelection{
+		Strategies: []string{"reversed"},
+		Tenants:    []string{"acme"},
+		Clients:    []string{"canary"},
+	}))
+
+	tests := []struct {
+		header   map[string]string
+		mux      http.Handler
+		name     string
+		key      string
+		body     string
+		path     string
+		selected string
+		status   int
+		signed   bool
+	}{
+		{
+			name:   "disabled",
+			mux:    newMux(),
+			header: map[string]string{handler.StrategyHeader: "reversed"},
+			path:   `["JFK","LAX","SFO"]`,
+			status: http.StatusOK,
+		},
+		{
+			name:   "empty lists",
+			mux:    nobody,
+			key:    "acme-key",
+			header: map[string]string{handler.StrategyHeader: "reversed"},
+			path:   `["JFK","LAX","SFO"]`,
+			status: http.StatusOK,
+		},
+		{
+			name:     "allowed tenant",
+			mux:      allowList,
+			key:      "acme-key",
+			header:   map[string]string{handler.StrategyHeader: "reversed"},
+			path:     `["SFO","LAX","JFK"]`,
+			selected: "reversed",
+			status:   http.StatusOK,
+		},
+		{
+			name:   "claimed tenant",
+			mux:    allowList,
+			header: map[string]string{handler.StrategyHeader: "reversed", handler.TenantHeader: "acme"},
+			path:   `["JFK","LAX","SFO"]`,
+			status: http.StatusOK,
+		},
+		{
+			name:     "allowed client",
+			mux:      allowList,
+			signed:   true,
+			header:   map[string]string{handler.StrategyHeader: "reversed"},
+			path:     `["SFO","LAX","JFK"]`,
+			selected: "reversed",
+			status:   http.StatusOK,
+		},
+		{
+			name:   "claimed client",
+			mux:    allowList,
+			header: map[string]string{handler.StrategyHeader: "reversed", middleware.ClientIDHeader: "canary"},
+			path:   `["JFK","LAX","SFO"]`,
+			status: http.StatusOK,
+		},
+		{
+			name:   "other tenant",
+			mux:    allowList,
+			key:    "globex-key",
+			header: map[string]string{handler.StrategyHeader: "reversed"},
+			path:   `["JFK","LAX","SFO"]`,
+			status: http.StatusOK,
+		},
+		{
+			name:   "strategy in the body",
+			mux:    allowList,
+			key:    "acme-key",
+			body:   `, "strategy": "default"`,
+			header: map[string]string{handler.StrategyHeader: "reversed"},
+			path:   `["JFK","LAX","SFO"]`,
+			status: http.StatusOK,
+		},
+		{
+			name:   "gated strategy in the body",
+			mux:    allowList,
+			key:    "acme-key",
+			body:   `, "strategy": "reversed"`,
+			path:   `["SFO","LAX","JFK"]`,
+			status: http.StatusOK,
+		},
+		{
+			name:   "gated strategy in the body of another tenant",
+			mux:    allowList,
+			key:    "globex-key",
+			body:   `, "strategy": "reversed"`,
+			status: http.StatusForbidden,
+		},
+		{
+			name:     "unknown strategy",
+			mux:      allowList,
+			key:      "acme-key",
+			header:   map[string]string{handler.StrategyHeader: "fastest"},
+			selected: "fastest",
+			status:   http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			body := `{"tickets": [["LAX", "SFO"], ["JFK", "LAX"]]` + tt.body + `}`
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/dispatcher/itinerary", strings.NewReader(body))
+			for name, value := range tt.header {
+				req.Header.Set(name, value)
+			}
+			if tt.key != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.key)
+			}
+			if tt.signed {
+				now := time.Now().Unix()
+				req.Header.Set(middleware.ClientIDHeader, "canary")
+				req.Header.Set(middleware.SignatureTimestampHeader, strconv.FormatInt(now, 10))
+				req.Header.Set(middleware.SignatureNonceHeader, tt.name)
+				req.Header.Set(middleware.SignatureHeader, middleware.Sign("secret", now, tt.name, req, []byte(body)))
+			}
+			rec := httptest.NewRecorder()
+			tt.mux.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d: %s", tt.status, rec.Code, rec.Body)
+			}
+			if got := rec.Header().Get(handler.StrategyHeader); got != tt.selected {
+				t.Errorf("Expected %s %q, got %q", handler.StrategyHeader, tt.selected, got)
+			}
+			if tt.path != "" && !strings.Contains(rec.Body.String(), `"linear_path":`+tt.path) {
+				t.Errorf("Expected linear path %s, got %s", tt.path, rec.Body)
+			}
+			wantCode := map[int]string{http.StatusBadRequest: "UNKNOWN_STRATEGY", http.StatusForbidden: "FORBIDDEN"}[tt.status]
+			if got := rec.Header().Get(responder.ErrorCodeHeader); got != wantCode {
+				t.Errorf("Expected error code %q, got %q", wantCode, got)
+			}
+		})
+	}
+}
+
+func TestUsageHeaders(t *testing.T) {
+	t.Parallel()
+
+	server := setupTestServer(t, handler.WithAdminToken("s3cret"), handler.WithAdminReadToken("r3ad"))
+	body := map[string]interface{}{"tickets": [][]string{{"JFK", "LAX"}}}
+
+	tests := []struct {
+		name    string
+		token   string
+		headers bool
+	}{
+		{name: "Anonymous"},
+		{name: "Wrong token", token: "guess"},
+		{name: "Read token", token: "r3ad", headers: true},
+		{name: "Admin token", token: "s3cret", headers: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			header := http.Header{}
+			if tt.token != "" {
+				header.Set("Authorization", "Bearer "+tt.token)
+			}
+			resp, _ := sendRequestWithHeader(t, server, http.MethodPost, "/api/v1/dispatcher/itinerary", header, body)
+			defer resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("Expected status code %d, got %d", http.StatusOK, resp.StatusCode)
+			}
+			if got := resp.Header.Get(handler.UsageAllocBytesHeader) != ""; got != tt.headers {
+				t.Errorf("Expected usage headers %v, got %v", tt.headers, resp.Header)
+			}
+		})
+	}
+}
+
+func TestIdempotencyKeysAfterAuthentication(t *testing.T) {
+	t.Parallel()
+
+	store, err := idempotency.New(clock.NewFake(time.Now()), idempotency.Config{Enabled: true})
+	if err != nil {
+		t.Fatalf("Failed to create idempotency store: %v", err)
+	}
+	mux := setupTestMux(t,
+		handler.WithIdempotency(store),
+		handler.WithTenants(middleware.Tenants{APIKeys: map[string]string{"acme-key": "acme", "globex-key": "globex"}}),
+		handler.WithAdminToken("s3cret"),
+		handler.WithDebug(),
+	)
+	itinerary := `{"tickets": [["JFK", "LAX"], ["LAX", "SFO"]]}`
+
+	// The steps run in order against the same store.
+	steps := []struct {
+		header       map[string]string
+		name         string
+		path         string
+		body         string
+		wantStatus   int
+		wantReplayed bool
+	}{
+		{
+			name:       "tenant",
+			path:       "/api/v1/dispatcher/itinerary",
+			body:       itinerary,
+			header:     map[string]string{"Authorization": "Bearer acme-key"},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:         "tenant retry",
+			path:         "/api/v1/dispatcher/itinerary",
+			body:         itinerary,
+			header:       map[string]string{"Authorization": "Bearer acme-key"},
+			wantStatus:   http.StatusOK,
+			wantReplayed: true,
+		},
+		{
+			name:       "other tenant",
+			path:       "/api/v1/dispatcher/itinerary",
+			body:       itinerary,
+			header:     map[string]string{"Authorization": "Bearer globex-key"},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "claimed tenant",
+			path:       "/api/v1/dispatcher/itinerary",
+			body:       itinerary,
+			header:     map[string]string{handler.TenantHeader: "acme"},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "admin with a wrong token",
+			path:       "/debug/pprof/symbol",
+			body:       "0x0",
+			header:     map[string]string{"Authorization": "Bearer wrong"},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "admin with the corrected token",
+			path:       "/debug/pprof/symbol",
+			body:       "0x0",
+			header:     map[string]string{"Authorization": "Bearer s3cret"},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "anonymous admin retry",
+			path:       "/debug/pprof/symbol",
+			body:       "0x0",
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, step := range steps {
+		req := httptest.NewRequest(http.MethodPost, step.path, strings.NewReader(step.body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(idempotency.Header, "key-"+step.path)
+		for name, value := range step.header {
+			req.Header.Set(name, value)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		if rec.Code != step.wantStatus {
+			t.Errorf("%s: expected status %d, got %d: %s", step.name, step.wantStatus, rec.Code, rec.Body.String())
+		}
+		if replayed := rec.Header().Get(idempotency.ReplayedHeader) == "true"; replayed != step.wantReplayed {
+			t.Errorf("%s: expected replayed %t, got %t", step.name, step.wantReplayed, replayed)
+		}
+	}
+}
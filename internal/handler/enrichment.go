@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"github.com/dsha256/dispatcher/internal/airports"
+	"github.com/dsha256/dispatcher/internal/geo"
+)
+
+// Stop is an airport of the linear path, enriched with reference data when the code is known.
+type Stop struct {
+	*airports.Airport
+	Code  string `json:"code"`
+	Known bool   `json:"known"`
+}
+
+// enrichStops maps every stop of the linear path to its airport details.
+func (h *Handler) enrichStops(path []string) []Stop {
+	stops := make([]Stop, 0, len(path))
+	for _, code := range path {
+		stop := Stop{Code: code}
+		if airport, ok := h.airports.Lookup(code); ok {
+			stop.Airport = &airport
+			stop.Known = true
+		}
+		stops = append(stops, stop)
+	}
+
+	return stops
+}
+
+// Distances is the great-circle mileage of the itinerary.
+// Totals only cover the legs whose airports have known coordinates; Complete tells whether that is all of them.
+type Distances struct {
+	Legs            []LegDistance `json:"legs"`
+	TotalKilometers float64       `json:"total_kilometers"`
+	TotalMiles      float64       `json:"total_miles"`
+	Complete        bool          `json:"complete"`
+}
+
+// LegDistance is the great-circle distance of a single leg, nil when a coordinate is unknown.
+type LegDistance struct {
+	Kilometers      *float64 `json:"kilometers"`
+	Miles           *float64 `json:"miles"`
+	From            string   `json:"from"`
+	To              string   `json:"to"`
+	SurfaceTransfer bool     `json:"surface_transfer,omitempty"`
+}
+
+// distances computes per-leg haversine distances, or returns nil when no leg has known coordinates.
+func (h *Handler) distances(legs []Leg) *Distances {
+	d := &Distances{Legs: make([]LegDistance, 0, len(legs)), Complete: true}
+
+	totalKm, known := 0.0, 0
+	for _, leg := range legs {
+		ld := LegDistance{From: leg.From, To: leg.To, SurfaceTransfer: leg.SurfaceTransfer}
+
+		from, okFrom := h.airports.Lookup(leg.From)
+		to, okTo := h.airports.Lookup(leg.To)
+		if okFrom && okTo {
+			km := geo.DistanceKm(from.Point(), to.Point())
+			totalKm += km
+			known++
+
+			roundedKm, roundedMiles := geo.Round(km, 1), geo.Round(geo.KmToMiles(km), 1)
+			ld.Kilometers, ld.Miles = &roundedKm, &roundedMiles
+		} else {
+			d.Complete = false
+		}
+
+		d.Legs = append(d.Legs, ld)
+	}
+
+	if known == 0 {
+		return nil
+	}
+
+	d.TotalKilometers = geo.Round(totalKm, 1)
+	d.TotalMiles = geo.Round(geo.KmToMiles(totalKm), 1)
+
+	return d
+}
+
+// Emissions is the estimated CO2 per passenger of the itinerary.
+// Totals only cover the legs with a known distance; Complete tells whether that is all of them.
+type Emissions struct {
+	Legs       []LegEmissions `json:"legs"`
+	TotalKgCO2 float64        `json:"total_kg_co2"`
+	Complete   bool           `json:"complete"`
+}
+
+// LegEmissions is the CO2 estimate of a single leg, nil when its distance is unknown.
+type LegEmissions struct {
+	KgCO2 *float64 `json:"kg_co2"`
+	From  string   `json:"from"`
+	To    string   `json:"to"`
+}
+
+// emissions estimates CO2 from the leg distances, or returns nil when the calculator is disabled.
+// Surface transfers are not flown and emit nothing.
+func (h *Handler) emissions(distances *Distances) *Emissions {
+	if h.emissionsCalculator == nil || distances == nil {
+		return nil
+	}
+
+	e := &Emissions{Legs: make([]LegEmissions, 0, len(distances.Legs)), Complete: distances.Complete}
+
+	total := 0.0
+	for _, leg := range distances.Legs {
+		le := LegEmissions{From: leg.From, To: leg.To}
+		if leg.Kilometers != nil && !leg.SurfaceTransfer {
+			kg := h.emissionsCalculator.LegKg(*leg.Kilometers)
+			total += kg
+
+			rounded := geo.Round(kg, 1)
+			le.KgCO2 = &rounded
+		}
+		e.Legs = append(e.Legs, le)
+	}
+	e.TotalKgCO2 = geo.Round(total, 1)
+
+	return e
+}
@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/breaker"
+	"github.com/dsha256/dispatcher/internal/dispatcher"
+	"github.com/dsha256/dispatcher/internal/events"
+	"github.com/dsha256/dispatcher/internal/export"
+	"github.com/dsha256/dispatcher/internal/jobs"
+	"github.com/dsha256/dispatcher/internal/limits"
+	"github.com/dsha256/dispatcher/internal/pricing"
+	"github.com/dsha256/dispatcher/internal/remote"
+	"github.com/dsha256/dispatcher/internal/shedding"
+	"github.com/dsha256/dispatcher/internal/summary"
+	"github.com/dsha256/dispatcher/pkg/apierror"
+)
+
+// WithEventPublisher publishes an ItineraryReconstructed or ItineraryRejected event after each reconstruction.
+func WithEventPublisher(publisher events.Publisher) Option {
+	return func(h *Handler) {
+		h.events = publisher
+	}
+}
+
+// publishEvent publishes the outcome of reconstructing the tickets, rejected when err is not nil. A failure
+// to publish is logged and never fails the request.
+func (h *Handler) publishEvent(r *http.Request, req *ReconstructItineraryRequest, itineraryID string, duration time.Duration, err error) {
+	if h.events == nil {
+		return
+	}
+
+	event := events.Event{
+		At:          h.clock.Now().UTC(),
+		Type:        events.TypeItineraryReconstructed,
+		ItineraryID: itineraryID,
+		Tenant:      tenantOf(r),
+		Strategy:    string(req.Strategy),
+		Tickets:     len(req.Tickets),
+		Duration:    duration,
+	}
+	if err != nil {
+		event.Type, event.ErrorCode = events.TypeItineraryRejected, string(errorCode(err))
+	}
+
+	if err = h.events.Publish(context.WithoutCancel(r.Context()), event); err != nil {
+		h.logger.ErrorContext(r.Context(), "error publishing event", "error", err, "type", event.Type)
+	}
+}
+
+// errorCode classifies an error for events and error responses: the apierror.Code of the error it wraps,
+// or apierror.CodeInternal.
+func errorCode(err error) apierror.Code {
+	codes := []struct {
+		err  error
+		code apierror.Code
+	}{
+		{err: dispatcher.ErrDifferentStartingPoints, code: apierror.CodeMultipleStarts},
+		{err: dispatcher.ErrMultipleSameDestination, code: apierror.CodeDuplicateTicket},
+		{err: dispatcher.ErrCycleInItinerary, code: apierror.CodeCycle},
+		{err: dispatcher.ErrMalformedTicket, code: apierror.CodeMalformedTicket},
+		{err: dispatcher.ErrUnknownAirport, code: apierror.CodeUnknownAirport},
+		{err: dispatcher.ErrDisconnectedItinerary, code: apierror.CodeDisconnected},
+		{err: dispatcher.ErrInfeasibleConnection, code: apierror.CodeInfeasibleConnection},
+		{err: dispatcher.ErrUnknownStrategy, code: apierror.CodeUnknownStrategy},
+		{err: dispatcher.ErrUnknownTieBreak, code: apierror.CodeUnknownTieBreak},
+		{err: dispatcher.ErrConflictingAlias, code: apierror.CodeBadRequest},
+		{err: dispatcher.ErrInvalidSurfaceTransfer, code: apierror.CodeBadRequest},
+		{err: dispatcher.ErrUntimedTickets, code: apierror.CodeBadRequest},
+		{err: pricing.ErrInvalidRates, code: apierror.CodeBadRequest},
+		{err: summary.ErrUnsupportedLocale, code: apierror.CodeBadRequest},
+		{err: summary.ErrUnknownTemplate, code: apierror.CodeBadRequest},
+		{err: summary.ErrSummaryTooLong, code: apierror.CodeBadRequest},
+		{err: dispatcher.ErrUnsupportedAlgorithmVersion, code: apierror.CodeUnsupportedAlgorithmVersion},
+		{err: dispatcher.ErrUnknownStability, code: apierror.CodeUnknownStability},
+		{err: dispatcher.ErrConstraintViolated, code: apierror.CodeConstraintViolated},
+		{err: dispatcher.ErrStreamingUnsupported, code: apierror.CodeStreamingUnsupported},
+		{err: limits.ErrTooManyTickets, code: apierror.CodeTooManyTickets},
+		{err: limits.ErrBodyTooLarge, code: apierror.CodeBodyTooLarge},
+		{err: limits.ErrInvalidLimits, code: apierror.CodeBadRequest},
+		{err: ErrInvalidRequest, code: apierror.CodeBadJSON},
+		{err: ErrNotFound, code: apierror.CodeNotFound},
+		{err: ErrTenantsDisabled, code: apierror.CodeForbidden},
+		{err: ErrUnauthenticatedTenant, code: apierror.CodeUnauthorized},
+		{err: ErrStrategyNotAllowed, code: apierror.CodeForbidden},
+		{err: ErrMethodNotAllowed, code: apierror.CodeMethodNotAllowed},
+		{err: ErrStorageDisabled, code: apierror.CodeFeatureDisabled},
+		{err: ErrInspectorDisabled, code: apierror.CodeFeatureDisabled},
+		{err: ErrLogLevelDisabled, code: apierror.CodeFeatureDisabled},
+		{err: ErrSheddingDisabled, code: apierror.CodeFeatureDisabled},
+		{err: ErrJobsDisabled, code: apierror.CodeFeatureDisabled},
+		{err: ErrLimitsDisabled, code: apierror.CodeFeatureDisabled},
+		{err: ErrConfigDisabled, code: apierror.CodeFeatureDisabled},
+		{err: ErrMissingUpload, code: apierror.CodeBadRequest},
+		{err: ErrUnsupportedUpload, code: apierror.CodeUnsupportedMediaType},
+		{err: ErrUnknownFormat, code: apierror.CodeBadRequest},
+		{err: ErrUnknownField, code: apierror.CodeBadRequest},
+		{err: export.ErrNoDepartureTimes, code: apierror.CodeBadRequest},
+		{err: export.ErrNoCoordinates, code: apierror.CodeBadRequest},
+		{err: ErrSourcesDisabled, code: apierror.CodeFeatureDisabled},
+		{err: ErrSourceConflict, code: apierror.CodeBadRequest},
+		{err: remote.ErrNotAllowed, code: apierror.CodeForbidden},
+		{err: remote.ErrFetch, code: apierror.CodeUnavailable},
+		{err: jobs.ErrTooManyJobs, code: apierror.CodeUnavailable},
+		{err: shedding.ErrOverloaded, code: apierror.CodeUnavailable},
+		{err: ErrNotReady, code: apierror.CodeUnavailable},
+		{err: ErrMaintenance, code: apierror.CodeUnavailable},
+		{err: breaker.ErrOpen, code: apierror.CodeUnavailable},
+		{err: context.Canceled, code: apierror.CodeCanceled},
+		{err: context.DeadlineExceeded, code: apierror.CodeTimeout},
+	}
+	for _, c := range codes {
+		if errors.Is(err, c.err) {
+			return c.code
+		}
+	}
+
+	return apierror.CodeInternal
+}
@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/dsha256/dispatcher/internal/export"
+)
+
+var ErrUnknownFormat = errors.New("unknown format")
+
+// formatJSON is the format of the regular JSON responses.
+const formatJSON = "json"
+
+// itineraryExport renders reconstructed itineraries in a format other than JSON.
+type itineraryExport struct {
+	render    func(h *Handler, itinerary export.Itinerary) ([]byte, error)
+	mediaType string
+	// extension names the file of the export, for Content-Disposition; exports without one are shown inline.
+	extension string
+}
+
+// lookupExport returns the export of the format name or media type.
+func lookupExport(format string) (*itineraryExport, bool) {
+	switch strings.ToLower(format) {
+	case "ics", export.MediaTypeICS:
+		return &itineraryExport{
+			render: func(h *Handler, itinerary export.Itinerary) ([]byte, error) {
+				return export.ICS(itinerary, h.airports, h.clock.Now())
+			},
+			mediaType: export.MediaTypeICS,
+			extension: "ics",
+		}, true
+	case "geojson", export.MediaTypeGeoJSON:
+		return &itineraryExport{
+			render: func(h *Handler, itinerary export.Itinerary) ([]byte, error) {
+				return export.GeoJSON(itinerary, h.airports)
+			},
+			mediaType: export.MediaTypeGeoJSON,
+			extension: "geojson",
+		}, true
+	case "kml", export.MediaTypeKML:
+		return &itineraryExport{
+			render: func(h *Handler, itinerary export.Itinerary) ([]byte, error) {
+				return export.KML(itinerary, h.airports)
+			},
+			mediaType: export.MediaTypeKML,
+			extension: "kml",
+		}, true
+	case "text", export.MediaTypeText:
+		return &itineraryExport{
+			render: func(h *Handler, itinerary export.Itinerary) ([]byte, error) {
+				return export.Text(itinerary, h.airports), nil
+			},
+			mediaType: export.MediaTypeText,
+		}, true
+	case "markdown", export.MediaTypeMarkdown:
+		return &itineraryExport{
+			render: func(h *Handler, itinerary export.Itinerary) ([]byte, error) {
+				return export.Markdown(itinerary, h.airports), nil
+			},
+			mediaType: export.MediaTypeMarkdown,
+		}, true
+	default:
+		return nil, false
+	}
+}
+
+// negotiateExport returns the export the request asks for: by the format query parameter, e.g. ?format=ics,
+// or else the media type the Accept header prefers, by quality then order; nil for JSON. It writes the error
+// response itself and returns false when the format is unknown.
+func (h *Handler) negotiateExport(w http.ResponseWriter, r *http.Request) (*itineraryExport, bool) {
+	if format := r.URL.Query().Get("format"); format != "" {
+		if format == formatJSON {
+			return nil, true
+		}
+		exp, ok := lookupExport(format)
+		if !ok {
+			h.handleError(w, r, fmt.Errorf("%w %q", ErrUnknownFormat, format), http.StatusBadRequest)
+
+			return nil, false
+		}
+
+		return exp, true
+	}
+
+	var best *itineraryExport
+	bestQuality := 0.0
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			if quality, err = strconv.ParseFloat(q, 64); err != nil {
+				continue
+			}
+		}
+		if quality > bestQuality {
+			best, _ = lookupExport(mediaType)
+			bestQuality = quality
+		}
+	}
+
+	return best, true
+}
+
+// writeExport renders the itinerary in the export and sends it, as a file named after the itinerary when the
+// export has a file extension.
+func (h *Handler) writeExport(w http.ResponseWriter, r *http.Request, exp *itineraryExport, resp ReconstructItineraryResponse) {
+	itinerary := export.Itinerary{
+		ID:         resp.ItineraryID,
+		TotalPrice: resp.TotalPrice,
+		Path:       resp.LinearPath,
+		Legs:       make([]export.Leg, 0, len(resp.Legs)),
+	}
+	for _, leg := range resp.Legs {
+		itinerary.Legs = append(itinerary.Legs, export.Leg{
+			DepartsAt: leg.Ticket.DepartsAt,
+			ArrivesAt: leg.Ticket.ArrivesAt,
+			From:      leg.From,
+			To:        leg.To,
+			FlightNo:  leg.Ticket.FlightNo,
+		})
+	}
+
+	body, err := exp.render(h, itinerary)
+	if err != nil {
+		h.handleError(w, r, err, http.StatusBadRequest)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", exp.mediaType+"; charset=utf-8")
+	if exp.extension != "" {
+		name := resp.ItineraryID
+		if len(name) > 12 {
+			name = name[:12]
+		}
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+			"filename": "itinerary-" + name + "." + exp.extension,
+		}))
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+}
@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrUnknownField = errors.New("unknown response field")
+
+// The response sections a request can select; the other members of the response are always included.
+const (
+	fieldLinearPath = "linear_path"
+	fieldLegs       = "legs"
+	fieldEnrichment = "enrichment"
+	fieldDistances  = "distances"
+	fieldEmissions  = "emissions"
+	fieldTiming     = "timing"
+	fieldPrices     = "prices"
+)
+
+// fieldSet is the response sections a request selects; an empty set selects them all.
+type fieldSet map[string]bool
+
+// responseFields returns the sections selected by the comma-separated query parameter, e.g. "linear_path,timing",
+// or else by the fields of the request.
+func responseFields(query string, fields []string) (fieldSet, error) {
+	if query != "" {
+		fields = strings.Split(query, ",")
+	}
+	set := make(fieldSet, len(fields))
+	for _, field := range fields {
+		field = strings.ToLower(strings.TrimSpace(field))
+		switch field {
+		case fieldLinearPath, fieldLegs, fieldEnrichment, fieldDistances, fieldEmissions, fieldTiming, fieldPrices:
+			set[field] = true
+		default:
+			return nil, fmt.Errorf("%w %q", ErrUnknownField, field)
+		}
+	}
+
+	return set, nil
+}
+
+// apply drops the sections of the response the set does not select.
+func (s fieldSet) apply(resp *ReconstructItineraryResponse) {
+	if len(s) == 0 {
+		return
+	}
+	if !s[fieldLinearPath] {
+		resp.LinearPath = nil
+	}
+	if !s[fieldLegs] {
+		resp.Legs = nil
+	}
+	if !s[fieldEnrichment] {
+		resp.Stops = nil
+	}
+	if !s[fieldDistances] {
+		resp.Distances = nil
+	}
+	if !s[fieldEmissions] {
+		resp.Emissions = nil
+	}
+	if !s[fieldTiming] {
+		resp.Timing = nil
+	}
+	if !s[fieldPrices] {
+		resp.Prices = nil
+	}
+}
@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/dsha256/dispatcher/internal/dispatcher"
+)
+
+// GraphExportResponse carries the ticket graph as DOT, and as Mermaid when requested with ?mermaid=true.
+type GraphExportResponse struct {
+	Graph   *dispatcher.Graph            `json:"graph"`
+	DOT     string                       `json:"dot"`
+	Mermaid string                       `json:"mermaid,omitempty"`
+	Issues  []dispatcher.ValidationIssue `json:"issues"`
+	IsValid bool                         `json:"is_valid"`
+}
+
+func (h *Handler) handleGraphExport(w http.ResponseWriter, r *http.Request) {
+	mermaid := false
+	if raw := r.URL.Query().Get("mermaid"); raw != "" {
+		var err error
+		if mermaid, err = strconv.ParseBool(raw); err != nil {
+			h.handleError(w, r, fmt.Errorf("invalid mermaid parameter: %w", err), http.StatusBadRequest)
+
+			return
+		}
+	}
+
+	_, req, ok := h.decodeTicketsRequest(w, r)
+	if !ok {
+		return
+	}
+
+	warnings, ok := h.checkLimits(w, r, len(req.Tickets))
+	if !ok {
+		return
+	}
+	identify(w, req.Tickets)
+
+	report, pairs, ok := h.validate(w, r, &req)
+	if !ok {
+		return
+	}
+	graph := dispatcher.NewGraph(pairs, report)
+
+	resp := GraphExportResponse{
+		Graph:   graph,
+		DOT:     graph.DOT(),
+		Issues:  report.Issues,
+		IsValid: report.Valid,
+	}
+	if mermaid {
+		resp.Mermaid = graph.Mermaid()
+	}
+
+	h.writeSuccess(w, r, resp, warnings)
+}
+
+func (h *Handler) handleGraphStats(w http.ResponseWriter, r *http.Request) {
+	_, req, ok := h.decodeTicketsRequest(w, r)
+	if !ok {
+		return
+	}
+
+	warnings, ok := h.checkLimits(w, r, len(req.Tickets))
+	if !ok {
+		return
+	}
+	identify(w, req.Tickets)
+
+	h.writeSuccess(w, r, dispatcher.NewGraph(dispatcher.Pairs(req.Tickets), nil).Stats(), warnings)
+}
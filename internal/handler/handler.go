@@ -1,65 +1,583 @@
 package handler
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
 	"log/slog"
 	"net/http"
+	"slices"
+	"strings"
+	"sync/atomic"
 
+	"github.com/dsha256/dispatcher/internal/accounting"
+	"github.com/dsha256/dispatcher/internal/airports"
+	"github.com/dsha256/dispatcher/internal/audit"
+	"github.com/dsha256/dispatcher/internal/blackout"
+	"github.com/dsha256/dispatcher/internal/cache"
+	"github.com/dsha256/dispatcher/internal/clock"
+	"github.com/dsha256/dispatcher/internal/degradation"
 	"github.com/dsha256/dispatcher/internal/dispatcher"
+	"github.com/dsha256/dispatcher/internal/emissions"
+	"github.com/dsha256/dispatcher/internal/events"
+	"github.com/dsha256/dispatcher/internal/health"
+	"github.com/dsha256/dispatcher/internal/i18n"
+	"github.com/dsha256/dispatcher/internal/idempotency"
+	"github.com/dsha256/dispatcher/internal/inspector"
+	"github.com/dsha256/dispatcher/internal/jobs"
+	"github.com/dsha256/dispatcher/internal/limits"
+	"github.com/dsha256/dispatcher/internal/logging"
+	"github.com/dsha256/dispatcher/internal/messages"
 	"github.com/dsha256/dispatcher/internal/middleware"
+	"github.com/dsha256/dispatcher/internal/pricing"
+	"github.com/dsha256/dispatcher/internal/remote"
 	"github.com/dsha256/dispatcher/internal/responder"
+	"github.com/dsha256/dispatcher/internal/shadow"
+	"github.com/dsha256/dispatcher/internal/shedding"
+	"github.com/dsha256/dispatcher/internal/store"
+	"github.com/dsha256/dispatcher/internal/support"
+	"github.com/dsha256/dispatcher/internal/ticketcsv"
+	"github.com/dsha256/dispatcher/internal/types"
+	"github.com/dsha256/dispatcher/pkg/apierror"
 )
 
-var ErrMethodNotAllowed = errors.New("method not allowed")
+var (
+	ErrMethodNotAllowed = errors.New("method not allowed")
+	ErrNotFound         = errors.New("not found")
+	ErrNotReady         = errors.New("service is not ready")
+)
+
+// StatusClientClosedRequest is the non-standard status logged for requests aborted by a client disconnect.
+const StatusClientClosedRequest = 499
 
 type Handler struct {
 	logger     *slog.Logger
 	dispatcher *dispatcher.Dispatcher
+	bundler    *support.Bundler
+	// clock timestamps events, panics, exports and support bundles; the system clock unless set.
+	clock clock.Clock
+	// blackouts is an in-memory store of its own unless set.
+	blackouts *blackout.Store
+	// airports is the embedded directory unless set.
+	airports *airports.Directory
+	// emissionsCalculator is nil when emission estimates are disabled.
+	emissionsCalculator *emissions.Calculator
+	// usage is a tracker of its own unless set.
+	usage *accounting.Tracker
+	// ladder is nil when graceful degradation is disabled.
+	ladder *degradation.Ladder
+	// limits is nil when request size limits are disabled.
+	limits     *limits.Checker
+	csvMapping ticketcsv.Mapping
+	// messages is nil when the default success messages are used.
+	messages *messages.Catalog
+	// translator is nil when messages are only sent in English.
+	translator *i18n.Translator
+	// inspector is nil when the live request inspector is disabled.
+	inspector *inspector.Inspector
+	// auditTrail is nil when reconstruction requests are not audited.
+	auditTrail *audit.Trail
+	// shadow is nil when reconstructions are not compared with an alternate strategy.
+	shadow *shadow.Shadow
+	// strategySelection is nil when the StrategyHeader is ignored.
+	strategySelection *StrategySelection
+	// cache is nil when reconstructions are not cached.
+	cache *cache.Cache[*dispatcher.Result]
+	// itineraries is nil when reconstructed itineraries are not saved.
+	itineraries store.Itineraries
+	// idempotency is nil when idempotency keys are disabled.
+	idempotency *idempotency.Store
+	// shedder is nil when reconstructions are not capped.
+	shedder *shedding.Shedder
+	// jobs is nil when uploads are reconstructed while the client waits, whatever their size.
+	jobs *jobs.Store
+	// sources is nil when requests cannot reference their tickets by URL.
+	sources *remote.Fetcher
+	// logLevel is nil when the log level cannot be changed at runtime.
+	logLevel *logging.Level
+	// tenants authenticates the tenant of client requests; the routes of per-tenant resources are refused
+	// when none is configured.
+	tenants middleware.Tenants
+	// adminToken authenticates the requests to admin routes, which are refused when empty.
+	adminToken string
+	// adminReadToken authenticates the requests reading admin routes, along with adminToken; those routes
+	// are refused when both are empty.
+	adminReadToken string
+	// maintenance closes the client routes while set.
+	maintenance atomic.Bool
+	// debug serves the runtime debug routes.
+	debug bool
+	// events is nil when domain events are not published.
+	events events.Publisher
+	// health holds the checks of the dependencies readiness depends on.
+	health *health.Registry
+	// responder writes the responses, in the default envelope unless set.
+	responder responder.Responder
+	// recovery counts and reports the panics of every route; nil only logs them.
+	recovery *middleware.Recovery
+	// accessLog configures the access log of every route.
+	accessLog middleware.AccessLog
+	// middleware wraps every route; routeMiddleware wraps single routes, by pattern.
+	middleware      []middleware.Middleware
+	routeMiddleware map[string][]middleware.Middleware
+	// upload configures the itinerary upload endpoint.
+	upload Upload
+	// exchangeRates is nil when prices are only totaled per currency, unless the request carries rates.
+	exchangeRates *pricing.Rates
+}
+
+// ReadinessCheck reports whether a dependency the service needs is reachable.
+type ReadinessCheck = health.Check
+
+type Option func(*Handler)
+
+// WithClock timestamps events, panics, exports and support bundles with the clock instead of the system clock.
+func WithClock(clk clock.Clock) Option {
+	return func(h *Handler) {
+		h.clock = clk
+	}
+}
+
+// WithBlackouts flags the legs departing on the dates of the store's calendars, which tenants manage through
+// the blackout routes. The handler keeps a store of its own in memory unless set.
+func WithBlackouts(store *blackout.Store) Option {
+	return func(h *Handler) {
+		h.blackouts = store
+	}
+}
+
+// WithAirports enriches and exports itineraries with the airports of the directory, the embedded one unless
+// set; it should be the directory the dispatcher validates airport codes against.
+func WithAirports(directory *airports.Directory) Option {
+	return func(h *Handler) {
+		h.airports = directory
+	}
+}
+
+// WithEmissions estimates the emissions of every leg with the calculator; estimates are disabled unless set.
+func WithEmissions(calculator *emissions.Calculator) Option {
+	return func(h *Handler) {
+		h.emissionsCalculator = calculator
+	}
+}
+
+// WithAccounting records the usage of every tenant in the tracker, served by the usage route. The handler
+// keeps a tracker of its own unless set.
+func WithAccounting(usage *accounting.Tracker) Option {
+	return func(h *Handler) {
+		h.usage = usage
+	}
+}
+
+// WithIdempotency replays the stored responses to the POST requests retried with the same Idempotency-Key.
+// Keys are checked once the requests are authenticated, and scoped by their authenticated identity.
+func WithIdempotency(store *idempotency.Store) Option {
+	return func(h *Handler) {
+		h.idempotency = store
+	}
+}
+
+// WithDegradation switches optional work off according to the ladder's active steps.
+func WithDegradation(ladder *degradation.Ladder) Option {
+	return func(h *Handler) {
+		h.ladder = ladder
+	}
+}
+
+// WithLimits enforces soft and hard ticket limits on every endpoint taking tickets.
+func WithLimits(checker *limits.Checker) Option {
+	return func(h *Handler) {
+		h.limits = checker
+	}
+}
+
+// WithReadinessCheck makes readiness depend on the named check, e.g. "redis": the service is not ready while
+// it fails, unless registered health.NonCritical.
+func WithReadinessCheck(name string, check ReadinessCheck, opts ...health.Option) Option {
+	return func(h *Handler) {
+		h.health.Register(name, check, opts...)
+	}
+}
+
+// WithMiddleware wraps every route in the middleware, the first one outermost, inside logging and panic
+// recovery, e.g. to authenticate or trace requests. It may be given several times.
+func WithMiddleware(mw ...middleware.Middleware) Option {
+	return func(h *Handler) {
+		h.middleware = append(h.middleware, mw...)
+	}
+}
+
+// WithRouteMiddleware wraps the route with the pattern, e.g. "POST /api/v1/dispatcher/itinerary", in the
+// middleware, inside those given to WithMiddleware. It may be given several times.
+func WithRouteMiddleware(pattern string, mw ...middleware.Middleware) Option {
+	return func(h *Handler) {
+		if h.routeMiddleware == nil {
+			h.routeMiddleware = make(map[string][]middleware.Middleware)
+		}
+		h.routeMiddleware[pattern] = append(h.routeMiddleware[pattern], mw...)
+	}
+}
+
+// WithAdminToken authenticates the requests to admin routes changing the service or exposing its internals,
+// e.g. changing the log level, with the bearer token. They are refused without a token.
+func WithAdminToken(token string) Option {
+	return func(h *Handler) {
+		h.adminToken = token
+	}
+}
+
+// WithAccessLog sets the format and sampling of the access log.
+func WithAccessLog(cfg middleware.AccessLog) Option {
+	return func(h *Handler) {
+		h.accessLog = cfg
+	}
+}
+
+// WithRecovery counts the panics recovered from and reports them through the recovery.
+func WithRecovery(rc *middleware.Recovery) Option {
+	return func(h *Handler) {
+		h.recovery = rc
+	}
+}
+
+// WithResponder writes the responses with the responder instead of the default envelope.
+func WithResponder(rsp responder.Responder) Option {
+	return func(h *Handler) {
+		h.responder = rsp
+	}
+}
+
+// WithMessages replaces the default success messages with the catalog's.
+func WithMessages(catalog *messages.Catalog) Option {
+	return func(h *Handler) {
+		h.messages = catalog
+	}
+}
+
+// WithTranslator translates the success and error messages into the language of the Accept-Language header.
+func WithTranslator(translator *i18n.Translator) Option {
+	return func(h *Handler) {
+		h.translator = translator
+	}
 }
 
 func New(
 	logger *slog.Logger,
 	dispatcher *dispatcher.Dispatcher,
+	bundler *support.Bundler,
+	opts ...Option,
 ) *Handler {
-	return &Handler{
+	h := &Handler{
 		logger:     logger,
 		dispatcher: dispatcher,
+		bundler:    bundler,
+		csvMapping: ticketcsv.DefaultMapping(),
+		responder:  responder.Envelope{},
+		health:     &health.Registry{},
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	if h.clock == nil {
+		h.clock = clock.Real{}
+	}
+	if h.blackouts == nil {
+		h.blackouts = blackout.NewStore()
+	}
+	if h.airports == nil {
+		h.airports = airports.Default()
+	}
+	if h.usage == nil {
+		h.usage = accounting.NewTracker()
+	}
+	if h.jobs != nil {
+		h.jobs.Start(http.HandlerFunc(h.reconstructItinerary))
 	}
+
+	return h
+}
+
+// route is a route the handler registers, and lists in the discovery document.
+type route struct {
+	handler http.HandlerFunc
+	method  string
+	path    string
+	// inspect records the route's requests in the live request inspector.
+	inspect bool
+	// admin requires requests to carry the admin token, and logs them for audit.
+	admin bool
+	// adminRead requires requests to carry the admin read token or admin token.
+	adminRead bool
+	// shed counts the route's requests against the load shedding limit, for routes reconstructing itineraries.
+	shed bool
+	// audit records the route's requests in the audit trail, for routes reconstructing itineraries.
+	audit bool
+	// batch rejects the route's requests while the degradation ladder rejects batches, for routes reconstructing
+	// many itineraries per request.
+	batch bool
 }
 
+// pattern is the route's pattern, as given to WithRouteMiddleware.
+func (rt route) pattern() string {
+	return rt.method + " " + rt.path
+}
+
+func (h *Handler) routes() []route {
+	return []route{
+		{method: http.MethodPost, path: "/api/v1/dispatcher/itinerary", handler: h.reconstructItinerary, inspect: true, shed: true, audit: true},
+		{method: http.MethodPost, path: "/api/v1/dispatcher/itinerary/stream", handler: h.handleItineraryStream, inspect: true, shed: true, audit: true},
+		{method: http.MethodPost, path: "/api/v1/dispatcher/itinerary/validate", handler: h.validateItinerary, inspect: true},
+		{method: http.MethodPost, path: "/api/v1/dispatcher/itinerary/summary", handler: h.handleItinerarySummary, inspect: true, shed: true, audit: true},
+		{method: http.MethodPost, path: "/api/v1/dispatcher/itinerary/passengers", handler: h.handlePassengerItineraries, inspect: true, shed: true, audit: true, batch: true},
+		{method: http.MethodPost, path: "/api/v1/dispatcher/itinerary/upload", handler: h.handleItineraryUpload, shed: true, audit: true, batch: true},
+		{method: http.MethodGet, path: "/api/v1/dispatcher/jobs/{id}", handler: h.handleJob},
+		{method: http.MethodGet, path: "/api/v1/dispatcher/itinerary/{id}", handler: h.handleSavedItinerary},
+		{method: http.MethodDelete, path: "/api/v1/dispatcher/itinerary/{id}", handler: h.handleDeleteSavedItinerary},
+		{method: http.MethodGet, path: "/api/v1/dispatcher/itinerary/by-hash/{hash}", handler: h.handleSavedItineraryByHash},
+		{method: http.MethodGet, path: "/api/v1/dispatcher/itineraries", handler: h.handleSavedItineraries},
+		{method: http.MethodPost, path: "/api/v1/dispatcher/graph/export", handler: h.handleGraphExport, inspect: true},
+		{method: http.MethodPost, path: "/api/v1/dispatcher/graph/stats", handler: h.handleGraphStats, inspect: true},
+		{method: http.MethodGet, path: "/api/v1/conformance", handler: h.handleConformance},
+		{method: http.MethodGet, path: "/api/v1/blackout-calendars", handler: h.handleListBlackoutCalendars},
+		{method: http.MethodPut, path: "/api/v1/blackout-calendars", handler: h.handlePutBlackoutCalendar},
+		{method: http.MethodDelete, path: "/api/v1/blackout-calendars", handler: h.handleDeleteBlackoutCalendar},
+		{method: http.MethodGet, path: "/api/v1/liveness", handler: h.handleLiveness},
+		{method: http.MethodGet, path: "/api/v1/readiness", handler: h.handleReadiness},
+		{method: http.MethodGet, path: "/api/v1/admin/support-bundle", handler: h.handleSupportBundle, admin: true},
+		{method: http.MethodGet, path: "/api/v1/admin/config", handler: h.handleConfig, adminRead: true},
+		{method: http.MethodGet, path: "/api/v1/admin/usage", handler: h.handleUsage, adminRead: true},
+		{method: http.MethodGet, path: "/api/v1/admin/limits", handler: h.handleLimits, adminRead: true},
+		{method: http.MethodPut, path: "/api/v1/admin/limits", handler: h.handlePutLimits, admin: true},
+		{method: http.MethodGet, path: "/api/v1/admin/cache", handler: h.handleCache, adminRead: true},
+		{method: http.MethodDelete, path: "/api/v1/admin/cache", handler: h.handleFlushCache, admin: true},
+		{method: http.MethodGet, path: "/api/v1/admin/jobs", handler: h.handleJobs, adminRead: true},
+		{method: http.MethodGet, path: "/api/v1/admin/maintenance", handler: h.handleMaintenance, adminRead: true},
+		{method: http.MethodPut, path: "/api/v1/admin/maintenance", handler: h.handlePutMaintenance, admin: true},
+		{method: http.MethodGet, path: "/api/v1/admin/log-level", handler: h.handleLogLevel, adminRead: true},
+		{method: http.MethodGet, path: "/api/v1/admin/panics", handler: h.handlePanics, adminRead: true},
+		{method: http.MethodPut, path: "/api/v1/admin/log-level", handler: h.handlePutLogLevel, admin: true},
+		{method: http.MethodGet, path: "/api/v1/admin/shedding", handler: h.handleShedding, adminRead: true},
+		{method: http.MethodPut, path: "/api/v1/admin/shedding", handler: h.handlePutShedding, admin: true},
+		{method: http.MethodGet, path: "/api/v1/admin/inspector", handler: h.handleInspectorFeed, admin: true},
+		{method: http.MethodPost, path: "/api/v1/admin/inspector/capture", handler: h.handleInspectorCapture, admin: true},
+	}
+}
+
+// RegisterRoutes registers the routes by method and path, and the discovery document listing them under
+// /api/v1. Requests matching no route are answered by handleUnmatched.
+//
+// Every route is wrapped in its middleware chain, outermost first: logging, panic recovery, tenant
+// authentication, the middleware given to WithMiddleware, those given to WithRouteMiddleware for the route,
+// authentication and audit logging of admin routes, the audit trail of reconstruction routes, maintenance mode
+// of client routes, load shedding of reconstruction routes, and the request inspector.
 func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
-	mux.Handle("/api/v1/dispatcher/itinerary", h.wrapHandler(h.handleItinerary))
-	mux.Handle("/api/v1/liveness", h.wrapHandler(h.handleLiveness))
-	mux.Handle("/api/v1/readiness", h.wrapHandler(h.handleReadiness))
+	chain := middleware.NewChain(
+		func(next http.Handler) http.Handler { return middleware.LoggingMiddleware(h.logger, h.accessLog, next) },
+		func(next http.Handler) http.Handler {
+			return middleware.RecoveryMiddleware(h.logger, h.clock, h.recovery, next)
+		},
+		h.tenants.Middleware,
+	).Use(h.middleware...)
+
+	routes := append(h.routes(), h.debugRoutes()...)
+	for _, rt := range routes {
+		routeChain := chain.With(h.routeMiddleware[rt.pattern()]...)
+		if rt.admin {
+			routeChain.Use(middleware.AuditMiddleware(h.logger), middleware.AuthMiddleware(h.adminToken))
+		}
+		if rt.adminRead {
+			routeChain.Use(middleware.ReadAuthMiddleware(middleware.Admin{Token: h.adminToken, ReadToken: h.adminReadToken}))
+		}
+		routeChain.Use(h.idempotency.Middleware)
+		if rt.audit {
+			routeChain.Use(h.audit)
+		}
+		if rt.inMaintenance() {
+			routeChain.Use(h.maintain)
+		}
+		if rt.batch {
+			routeChain.Use(h.ladder.BatchMiddleware)
+		}
+		if rt.shed {
+			routeChain.Use(h.shedder.Middleware)
+		}
+		if rt.inspect {
+			routeChain.Use(h.inspect)
+		}
+		mux.Handle(rt.pattern(), routeChain.ThenFunc(rt.handler))
+	}
+	for pattern := range h.routeMiddleware {
+		if !slices.ContainsFunc(routes, func(rt route) bool { return rt.pattern() == pattern }) {
+			h.logger.Warn("Middleware given for an unknown route", "pattern", pattern)
+		}
+	}
+
+	discovery := chain.ThenFunc(h.handleDiscovery(routes))
+	mux.Handle("GET /api/v1", discovery)
+	mux.Handle("GET /api/v1/{$}", discovery)
+	mux.Handle("/", chain.ThenFunc(h.handleUnmatched(mux)))
 	h.logger.Info("Routes registered")
 }
 
-func (h *Handler) wrapHandler(handler http.HandlerFunc) http.Handler {
-	return middleware.LoggingMiddleware(
-		h.logger,
-		middleware.RecoveryMiddleware(
-			h.logger,
-			handler,
-		),
-	)
+// handleUnmatched answers the requests no route matches: OPTIONS with 204 No Content and the Allow header
+// when the path has routes, other methods with the error envelope, 405 Method Not Allowed and the Allow
+// header when the path has routes for other methods, 404 Not Found otherwise.
+func (h *Handler) handleUnmatched(mux *http.ServeMux) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		allowed := allowedMethods(mux, r)
+		if len(allowed) == 0 {
+			h.handleError(w, r, ErrNotFound, http.StatusNotFound)
+
+			return
+		}
+
+		w.Header().Set("Allow", strings.Join(append(allowed, http.MethodOptions), ", "))
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+
+			return
+		}
+		h.handleError(w, r, ErrMethodNotAllowed, http.StatusMethodNotAllowed)
+	}
+}
+
+// allowedMethods returns the methods with a route for the request path, OPTIONS aside, by asking the mux
+// which route the request would match with each of them.
+func allowedMethods(mux *http.ServeMux, r *http.Request) []string {
+	var allowed []string
+	for _, method := range []string{
+		http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete,
+	} {
+		probe := r.WithContext(r.Context())
+		probe.Method = method
+		if _, pattern := mux.Handler(probe); pattern != "" && pattern != "/" {
+			allowed = append(allowed, method)
+		}
+	}
+
+	return allowed
+}
+
+func (h *Handler) handleLiveness(w http.ResponseWriter, r *http.Request) {
+	h.writeMessage(w, r, messages.ServiceLive, nil)
 }
 
-func (h *Handler) handleLiveness(w http.ResponseWriter, _ *http.Request) {
-	responder.WriteSuccess(w, http.StatusOK, "All services are up and running", json.RawMessage{})
+// ReadinessResponse reports the degradation state; the service stays ready while degraded.
+// Checks has the outcome of every readiness check by name, "ok" or the error, and Details their results;
+// the service is not ready while any critical check fails, and degraded while any other does.
+type ReadinessResponse struct {
+	Checks      map[string]string        `json:"checks,omitempty"`
+	Details     map[string]health.Result `json:"check_details,omitempty"`
+	Degradation degradation.Status       `json:"degradation"`
+	Degraded    bool                     `json:"degraded"`
 }
 
-func (h *Handler) handleReadiness(w http.ResponseWriter, _ *http.Request) {
-	responder.WriteSuccess(w, http.StatusOK, "All services are up and ready to process requests", json.RawMessage{})
+func (h *Handler) handleReadiness(w http.ResponseWriter, r *http.Request) {
+	results, ready := h.health.Run(r.Context())
+	resp := ReadinessResponse{Details: results, Degradation: h.ladder.Status()}
+	resp.Degraded = resp.Degradation.Level > 0
+	if results != nil {
+		resp.Checks = make(map[string]string, len(results))
+	}
+	for name, result := range results {
+		resp.Checks[name] = result.Status
+		if result.Status != health.StatusOK {
+			h.logger.WarnContext(r.Context(), "readiness check failed", "check", name, "error", result.Error, "critical", result.Critical)
+			resp.Checks[name] = result.Error
+			resp.Degraded = true
+		}
+	}
+
+	if !ready {
+		h.responder.Error(w, r, http.StatusServiceUnavailable, ErrNotReady, resp)
+
+		return
+	}
+	if resp.Degraded {
+		h.writeMessage(w, r, messages.ServiceDegraded, resp)
+
+		return
+	}
+
+	h.writeMessage(w, r, messages.ServiceReady, resp)
 }
 
-func (h *Handler) handleError(w http.ResponseWriter, err error, status int) {
+func (h *Handler) handleError(w http.ResponseWriter, r *http.Request, err error, status int) {
 	h.logger.Error("Error handling request", "error", err)
-	responder.WriteError(w, status, err)
+
+	code := errorCode(err)
+	if code == apierror.CodeInternal && status != http.StatusInternalServerError {
+		code = apierror.FromStatus(status)
+	}
+	w.Header().Set(responder.ErrorCodeHeader, string(code))
+
+	var details any
+	var detailed interface{ Details() any }
+	if errors.As(err, &detailed) {
+		details = detailed.Details()
+	}
+	if text, ok := h.translate(w, r, func(language string) (string, bool) { return h.translator.Error(language, code) }); ok {
+		err = &translatedError{err: err, text: text}
+	}
+	h.responder.Error(w, r, status, err, details)
+}
+
+// writeSuccess writes a success response through the responder.
+func (h *Handler) writeSuccess(w http.ResponseWriter, r *http.Request, data any, warnings []types.Warning) {
+	h.responder.Success(w, r, http.StatusOK, responder.Success{Data: data, Warnings: warnings})
+}
+
+// writeMessage writes a success response whose message comes from the catalog, together with its key.
+func (h *Handler) writeMessage(w http.ResponseWriter, r *http.Request, key messages.Key, data any) {
+	text := h.messages.Text(key)
+	if translated, ok := h.translate(w, r, func(language string) (string, bool) { return h.translator.Message(language, key) }); ok {
+		text = translated
+	}
+	h.responder.Success(w, r, http.StatusOK, responder.Success{Data: data, Message: text, MessageKey: string(key)})
+}
+
+// errorStatus maps a reconstruction error to the response status: 499 when the client went away,
+// 504 when the request deadline passed, 413 above the dispatcher's ticket limit, 400 for invalid tickets
+// and 500 otherwise.
+func (h *Handler) errorStatus(err error) int {
+	switch {
+	case errors.Is(err, limits.ErrTooManyTickets):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, context.Canceled):
+		return StatusClientClosedRequest
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case h.isBadRequestError(err):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
 }
 
 func (h *Handler) isBadRequestError(err error) bool {
 	return errors.Is(err, dispatcher.ErrDifferentStartingPoints) ||
 		errors.Is(err, dispatcher.ErrMultipleSameDestination) ||
-		errors.Is(err, dispatcher.ErrCycleInItinerary)
+		errors.Is(err, dispatcher.ErrCycleInItinerary) ||
+		errors.Is(err, dispatcher.ErrMalformedTicket) ||
+		errors.Is(err, dispatcher.ErrUnknownAirport) ||
+		errors.Is(err, dispatcher.ErrDisconnectedItinerary) ||
+		errors.Is(err, dispatcher.ErrInfeasibleConnection) ||
+		errors.Is(err, dispatcher.ErrUnknownStrategy) ||
+		errors.Is(err, dispatcher.ErrUnknownTieBreak) ||
+		errors.Is(err, dispatcher.ErrConflictingAlias) ||
+		errors.Is(err, dispatcher.ErrInvalidSurfaceTransfer) ||
+		errors.Is(err, dispatcher.ErrUntimedTickets) ||
+		errors.Is(err, dispatcher.ErrUnsupportedAlgorithmVersion) ||
+		errors.Is(err, dispatcher.ErrConstraintViolated) ||
+		errors.Is(err, dispatcher.ErrStreamingUnsupported)
+}
+
+// bodyError maps an error reading the request body to the response status and error:
+// 413 with a *limits.BodyError once the body crossed the size limit, 400 otherwise.
+func bodyError(err error) (int, error) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return http.StatusRequestEntityTooLarge, &limits.BodyError{MaxBodyBytes: maxBytesErr.Limit}
+	}
+
+	return http.StatusBadRequest, err
 }
@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/dsha256/dispatcher/internal/i18n"
+)
+
+// translatedError is an error whose message is translated; it unwraps to the original error.
+type translatedError struct {
+	err  error
+	text string
+}
+
+func (e *translatedError) Error() string {
+	return e.text
+}
+
+func (e *translatedError) Unwrap() error {
+	return e.err
+}
+
+// translate negotiates the language of the request's messages and looks the message up in it, setting the
+// Content-Language of the response when it is translated. Responses with translatable messages vary by
+// Accept-Language.
+func (h *Handler) translate(w http.ResponseWriter, r *http.Request, lookup func(language string) (string, bool)) (string, bool) {
+	if h.translator == nil {
+		return "", false
+	}
+	w.Header().Add("Vary", "Accept-Language")
+	language := h.translator.Negotiate(r.Header.Get("Accept-Language"))
+	if language == i18n.DefaultLanguage {
+		return "", false
+	}
+	text, ok := lookup(language)
+	if ok {
+		w.Header().Set("Content-Language", language)
+	}
+
+	return text, ok
+}
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/inspector"
+	"github.com/dsha256/dispatcher/internal/messages"
+)
+
+var (
+	ErrInspectorDisabled = errors.New("request inspector is disabled")
+	ErrMissingRequestID  = errors.New("missing request_id parameter")
+)
+
+// WithInspector publishes the dispatcher requests to the live request inspector.
+func WithInspector(i *inspector.Inspector) Option {
+	return func(h *Handler) {
+		h.inspector = i
+	}
+}
+
+// inspect publishes the requests of a dispatcher route to the inspector, if any.
+func (h *Handler) inspect(next http.Handler) http.Handler {
+	return h.inspector.Middleware(tenantOf, next)
+}
+
+// handleInspectorFeed streams the live request feed as server-sent events until the client disconnects.
+func (h *Handler) handleInspectorFeed(w http.ResponseWriter, r *http.Request) {
+	if h.inspector == nil {
+		h.handleError(w, r, ErrInspectorDisabled, http.StatusNotFound)
+
+		return
+	}
+
+	// The feed outlives the server write timeout.
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		h.logger.WarnContext(r.Context(), "error lifting write deadline of the request feed", "error", err)
+	}
+
+	feed, stop := h.inspector.Subscribe()
+	defer stop()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		h.logger.WarnContext(r.Context(), "error flushing the request feed", "error", err)
+
+		return
+	}
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event := <-feed:
+			data, err := json.Marshal(event)
+			if err != nil {
+				h.logger.ErrorContext(r.Context(), "error encoding request event", "error", err)
+
+				continue
+			}
+			if _, err = fmt.Fprintf(w, "event: request\ndata: %s\n\n", data); err != nil {
+				return
+			}
+			if err = rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// handleInspectorCapture arms payload capture for the next request with the ?request_id= ID.
+func (h *Handler) handleInspectorCapture(w http.ResponseWriter, r *http.Request) {
+	if h.inspector == nil {
+		h.handleError(w, r, ErrInspectorDisabled, http.StatusNotFound)
+
+		return
+	}
+
+	requestID := r.URL.Query().Get("request_id")
+	if requestID == "" {
+		h.handleError(w, r, ErrMissingRequestID, http.StatusBadRequest)
+
+		return
+	}
+
+	h.inspector.Capture(requestID)
+	h.logger.InfoContext(r.Context(), "payload capture armed", "request_id", requestID)
+	h.writeMessage(w, r, messages.CaptureArmed, map[string]string{"request_id": requestID})
+}
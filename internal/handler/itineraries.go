@@ -0,0 +1,199 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/dsha256/dispatcher/internal/breaker"
+	"github.com/dsha256/dispatcher/internal/messages"
+	"github.com/dsha256/dispatcher/internal/middleware"
+	"github.com/dsha256/dispatcher/internal/responder"
+	"github.com/dsha256/dispatcher/internal/store"
+)
+
+var (
+	ErrStorageDisabled = errors.New("itinerary storage is disabled")
+	ErrInvalidPage     = errors.New("invalid page")
+)
+
+// Page sizes of the saved itinerary listing.
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// WithItineraryStore saves every reconstructed itinerary, so clients can retrieve it later by its ID.
+func WithItineraryStore(s store.Itineraries) Option {
+	return func(h *Handler) {
+		h.itineraries = s
+	}
+}
+
+// ListItinerariesResponse is a page of the tenant's saved itineraries, most recent first.
+// NextOffset is the offset of the next page, omitted on the last one.
+type ListItinerariesResponse struct {
+	NextOffset  *int              `json:"next_offset,omitempty"`
+	Itineraries []store.Itinerary `json:"itineraries"`
+}
+
+// saveItinerary saves the response for the authenticated tenant and returns its ID, or an empty ID when
+// storage is disabled, the request is unauthenticated or saving fails; a failure to save never fails the
+// reconstruction. Saving the same ticket set again
+// replaces the saved response and keeps its ID.
+func (h *Handler) saveItinerary(r *http.Request, tickets int, resp ReconstructItineraryResponse) string {
+	identity, ok := middleware.IdentityFrom(r.Context())
+	if h.itineraries == nil || !ok {
+		return ""
+	}
+
+	result, err := json.Marshal(resp)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error encoding itinerary", "error", err)
+
+		return ""
+	}
+	itinerary := store.Itinerary{Tenant: identity.Tenant, Hash: resp.ItineraryID, Tickets: tickets, Result: result}
+	if err = h.itineraries.Save(r.Context(), &itinerary); err != nil {
+		h.logger.ErrorContext(r.Context(), "error saving itinerary", "error", err)
+
+		return ""
+	}
+
+	return itinerary.ID
+}
+
+// handleSavedItinerary returns a saved itinerary of the tenant by its ID.
+func (h *Handler) handleSavedItinerary(w http.ResponseWriter, r *http.Request) {
+	if h.itineraries == nil {
+		h.handleError(w, r, ErrStorageDisabled, http.StatusNotFound)
+
+		return
+	}
+	tenant, ok := h.authenticatedTenant(w, r)
+	if !ok {
+		return
+	}
+
+	itinerary, err := h.itineraries.Get(r.Context(), tenant, r.PathValue("id"))
+	if err != nil {
+		h.handleError(w, r, err, storageErrorStatus(err))
+
+		return
+	}
+	responder.WriteTagged(h.responder, w, r, etagPrefix(itinerary.Hash), responder.Success{Data: itinerary})
+}
+
+// handleDeleteSavedItinerary deletes a saved itinerary of the tenant by its ID.
+func (h *Handler) handleDeleteSavedItinerary(w http.ResponseWriter, r *http.Request) {
+	if h.itineraries == nil {
+		h.handleError(w, r, ErrStorageDisabled, http.StatusNotFound)
+
+		return
+	}
+	tenant, ok := h.authenticatedTenant(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.itineraries.Delete(r.Context(), tenant, r.PathValue("id")); err != nil {
+		h.handleError(w, r, err, storageErrorStatus(err))
+
+		return
+	}
+	h.writeMessage(w, r, messages.ItineraryDeleted, nil)
+}
+
+// handleSavedItineraryByHash returns the saved itinerary of the tenant with the itinerary ID, the fingerprint
+// of its ticket set.
+func (h *Handler) handleSavedItineraryByHash(w http.ResponseWriter, r *http.Request) {
+	if h.itineraries == nil {
+		h.handleError(w, r, ErrStorageDisabled, http.StatusNotFound)
+
+		return
+	}
+	tenant, ok := h.authenticatedTenant(w, r)
+	if !ok {
+		return
+	}
+
+	itinerary, err := h.itineraries.GetByHash(r.Context(), tenant, r.PathValue("hash"))
+	if err != nil {
+		h.handleError(w, r, err, storageErrorStatus(err))
+
+		return
+	}
+	responder.WriteTagged(h.responder, w, r, etagPrefix(itinerary.Hash), responder.Success{Data: itinerary})
+}
+
+// handleSavedItineraries lists the saved itineraries of the tenant a page at a time, selected by the
+// offset and limit query parameters.
+func (h *Handler) handleSavedItineraries(w http.ResponseWriter, r *http.Request) {
+	if h.itineraries == nil {
+		h.handleError(w, r, ErrStorageDisabled, http.StatusNotFound)
+
+		return
+	}
+	tenant, ok := h.authenticatedTenant(w, r)
+	if !ok {
+		return
+	}
+
+	page, err := parsePage(r)
+	if err != nil {
+		h.handleError(w, r, err, http.StatusBadRequest)
+
+		return
+	}
+
+	// One more than the page tells whether there is a next page.
+	itineraries, err := h.itineraries.List(r.Context(), tenant, store.Page{Offset: page.Offset, Limit: page.Limit + 1})
+	if err != nil {
+		h.handleError(w, r, err, storageErrorStatus(err))
+
+		return
+	}
+
+	resp := ListItinerariesResponse{Itineraries: itineraries}
+	if len(itineraries) > page.Limit {
+		resp.Itineraries = itineraries[:page.Limit]
+		next := page.Offset + page.Limit
+		resp.NextOffset = &next
+	}
+	h.writeSuccess(w, r, resp, nil)
+}
+
+func parsePage(r *http.Request) (store.Page, error) {
+	page := store.Page{Limit: defaultPageLimit}
+	query := r.URL.Query()
+
+	if raw := query.Get("offset"); raw != "" {
+		offset, err := strconv.Atoi(raw)
+		if err != nil || offset < 0 {
+			return page, fmt.Errorf("%w: offset must be a non-negative integer, got %q", ErrInvalidPage, raw)
+		}
+		page.Offset = offset
+	}
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			return page, fmt.Errorf("%w: limit must be between 1 and %d, got %q", ErrInvalidPage, maxPageLimit, raw)
+		}
+		page.Limit = limit
+	}
+
+	return page, nil
+}
+
+func storageErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, breaker.ErrOpen):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
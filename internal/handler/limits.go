@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/dsha256/dispatcher/internal/types"
+)
+
+// WarningSoftLimit is the code of the envelope warning of requests above the soft ticket limit.
+const WarningSoftLimit = "soft_limit"
+
+// checkLimits enforces the ticket limits on a request and returns the warnings to add to the response envelope.
+// It writes the error response itself and returns false when the request is rejected.
+func (h *Handler) checkLimits(w http.ResponseWriter, r *http.Request, tickets int) ([]types.Warning, bool) {
+	warning, err := h.limits.Check(tenantOf(r), tickets)
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "request rejected by hard limit", "error", err, "tenant", tenantOf(r), "path", r.URL.Path)
+		h.handleError(w, r, err, http.StatusRequestEntityTooLarge)
+
+		return nil, false
+	}
+	if warning == "" {
+		return nil, true
+	}
+
+	h.logger.WarnContext(r.Context(), "request above soft limit", "warning", warning, "tenant", tenantOf(r), "path", r.URL.Path)
+
+	return []types.Warning{{Code: WarningSoftLimit, Message: warning}}, true
+}
+
+func (h *Handler) handleLimits(w http.ResponseWriter, r *http.Request) {
+	h.writeSuccess(w, r, h.limits.Snapshot(), nil)
+}
@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/dsha256/dispatcher/internal/logging"
+)
+
+var ErrLogLevelDisabled = errors.New("log level control is disabled")
+
+// LogLevelRequest changes the log level, e.g. {"level": "debug"}.
+type LogLevelRequest struct {
+	Level slog.Level `json:"level"`
+}
+
+// LogLevelResponse is the current log level.
+type LogLevelResponse struct {
+	Level slog.Level `json:"level"`
+}
+
+// PanicsResponse is the number of panics recovered from since the service started.
+type PanicsResponse struct {
+	Panics uint64 `json:"panics"`
+}
+
+// WithLogLevel reports the log level, and lets admin requests change it.
+func WithLogLevel(level *logging.Level) Option {
+	return func(h *Handler) {
+		h.logLevel = level
+	}
+}
+
+func (h *Handler) handleLogLevel(w http.ResponseWriter, r *http.Request) {
+	if h.logLevel == nil {
+		h.handleError(w, r, ErrLogLevelDisabled, http.StatusNotFound)
+
+		return
+	}
+	h.writeSuccess(w, r, LogLevelResponse{Level: h.logLevel.Level()}, nil)
+}
+
+func (h *Handler) handlePutLogLevel(w http.ResponseWriter, r *http.Request) {
+	if h.logLevel == nil {
+		h.handleError(w, r, ErrLogLevelDisabled, http.StatusNotFound)
+
+		return
+	}
+	var req LogLevelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		status, bodyErr := bodyError(err)
+		h.handleError(w, r, bodyErr, status)
+
+		return
+	}
+
+	previous := h.logLevel.Level()
+	h.logLevel.Set(req.Level)
+	h.logger.WarnContext(r.Context(), "Log level changed", "from", previous, "to", req.Level, "remote_addr", r.RemoteAddr)
+	h.writeSuccess(w, r, LogLevelResponse{Level: req.Level}, nil)
+}
+
+func (h *Handler) handlePanics(w http.ResponseWriter, r *http.Request) {
+	h.writeSuccess(w, r, PanicsResponse{Panics: h.recovery.Panics()}, nil)
+}
@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/dsha256/dispatcher/internal/dispatcher"
+)
+
+// decodeNDJSONTickets decodes one ticket per line while the body is read. The raw body is never buffered,
+// so the payload is not available for support bundles, but the decoded tickets are, since reconstruction
+// needs all of them; reading stops as soon as the hard ticket limit is exceeded, which bounds them. It writes
+// the error response itself and returns false when the body is invalid.
+func (h *Handler) decodeNDJSONTickets(w http.ResponseWriter, r *http.Request) ([]dispatcher.Ticket, bool) {
+	maxTickets := h.limits.MaxTickets()
+	decoder := dispatcher.NewTicketDecoder(r.Body)
+
+	tickets := []dispatcher.Ticket{}
+	for maxTickets == 0 || len(tickets) <= maxTickets {
+		ticket, err := decoder.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			h.logger.WarnContext(r.Context(), "error decoding NDJSON request body", "error", err, "path", r.URL.Path)
+			h.bundler.RecordFailure(nil, string(errorCode(err)))
+			status, bodyErr := bodyError(err)
+			h.handleError(w, r, bodyErr, status)
+
+			return nil, false
+		}
+		tickets = append(tickets, ticket)
+	}
+
+	return tickets, true
+}
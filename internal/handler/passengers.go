@@ -0,0 +1,235 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/dsha256/dispatcher/internal/dispatcher"
+)
+
+// PassengerTicket is a ticket of a group booking, tagged with the passenger flying it,
+// e.g. {"passenger": "alice", "from": "JFK", "to": "LAX"}.
+type PassengerTicket struct {
+	Passenger string `json:"passenger"`
+
+	dispatcher.Ticket
+}
+
+// UnmarshalJSON decodes the ticket as strictly as a dispatcher.Ticket, its passenger aside.
+func (t *PassengerTicket) UnmarshalJSON(data []byte) error {
+	// Pairs carry no passenger, which is reported with the other problems of the request.
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		t.Passenger = ""
+
+		return t.Ticket.UnmarshalJSON(data)
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	t.Passenger = ""
+	if passenger, ok := fields["passenger"]; ok {
+		if err := json.Unmarshal(passenger, &t.Passenger); err != nil {
+			return err
+		}
+		delete(fields, "passenger")
+	}
+	ticket, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+
+	return t.Ticket.UnmarshalJSON(ticket)
+}
+
+// PassengerItinerariesRequest reconstructs the itinerary of every passenger of a group booking in one call.
+// The options are those of ReconstructItineraryRequest and apply to every passenger; Enrich, SuggestRepairs,
+// SourceURL and Fields are not supported.
+type PassengerItinerariesRequest struct {
+	ReconstructItineraryRequest
+
+	Tickets []PassengerTicket `json:"tickets"`
+}
+
+// PassengerItinerariesResponse lists the itinerary of every passenger, in the order passengers first appear in
+// the tickets. Passengers with identical ticket sets share one reconstruction: Reconstructions counts the
+// distinct ones.
+type PassengerItinerariesResponse struct {
+	Itineraries     []PassengerItinerary `json:"itineraries"`
+	Reconstructions int                  `json:"reconstructions"`
+}
+
+// PassengerItinerary is the itinerary of a passenger. The legs and code changes point to the tickets of the request,
+// all passengers included.
+type PassengerItinerary struct {
+	Passenger     string                  `json:"passenger"`
+	ItineraryID   string                  `json:"itinerary_id"`
+	LinearPath    []string                `json:"linear_path"`
+	Legs          []Leg                   `json:"legs"`
+	Normalization []dispatcher.CodeChange `json:"normalization,omitempty"`
+}
+
+// passengerGroup is the tickets of a passenger, with their indexes in the request.
+type passengerGroup struct {
+	canonical dispatcher.CanonicalTickets
+	name      string
+	tickets   []dispatcher.Ticket
+	indexes   []int
+}
+
+func (h *Handler) handlePassengerItineraries(w http.ResponseWriter, r *http.Request) {
+	var req PassengerItinerariesRequest
+	payload, ok := h.decodeRequest(w, r, &req)
+	if !ok {
+		return
+	}
+	if req.Strategy, ok = h.selectStrategy(w, r, req.Strategy); !ok {
+		return
+	}
+	if problems := passengerProblems(req.Tickets); len(problems) > 0 {
+		err := &RequestError{Problems: problems}
+		h.bundler.RecordFailure(payload, string(errorCode(err)))
+		h.handleError(w, r, err, http.StatusBadRequest)
+
+		return
+	}
+
+	warnings, ok := h.checkLimits(w, r, len(req.Tickets))
+	if !ok {
+		return
+	}
+
+	version, err := dispatcher.ResolveAlgorithmVersion(req.Stability, req.AlgorithmVersion)
+	if err != nil {
+		h.handleError(w, r, err, http.StatusBadRequest)
+
+		return
+	}
+	// Results of the input order tie-break depend on the order of the tickets, so passengers only share
+	// reconstructions of identical ticket sets under the other policies.
+	tieBreak, err := h.dispatcher.TieBreak(req.TieBreak)
+	if err != nil {
+		h.handleError(w, r, err, http.StatusBadRequest)
+
+		return
+	}
+
+	type reconstruction struct {
+		result *dispatcher.Result
+		group  *passengerGroup
+	}
+	resp := PassengerItinerariesResponse{Itineraries: []PassengerItinerary{}}
+	// shared maps the fingerprint of a ticket set to the first passenger reconstructed with it and the result.
+	shared := make(map[string]reconstruction)
+	for _, group := range groupByPassenger(req.Tickets) {
+		done, ok := shared[group.canonical.Fingerprint]
+		if !ok || tieBreak == dispatcher.TieBreakInputOrder {
+			result, err := h.reconstruct(w, r, &dispatcher.Request{
+				Constraints:      req.Constraints,
+				StrictAirports:   req.StrictAirports,
+				AllowDuplicates:  req.AllowDuplicates,
+				Strategy:         req.Strategy,
+				TieBreak:         req.TieBreak,
+				PreferredHubs:    req.PreferredHubs,
+				NormalizeCodes:   req.NormalizeCodes,
+				Aliases:          req.Aliases,
+				SurfaceTransfers: req.SurfaceTransfers,
+				AlgorithmVersion: version,
+				Tickets:          group.tickets,
+			}, group.canonical)
+			if err != nil {
+				h.handlePassengerError(w, r, payload, fmt.Errorf("passenger %q: %w", group.name, err))
+
+				return
+			}
+			done = reconstruction{result: result, group: group}
+			shared[group.canonical.Fingerprint] = done
+			resp.Reconstructions++
+		}
+
+		result := reindex(done.result, sharedIndex(done.group, group))
+		itinerary := PassengerItinerary{
+			Passenger:     group.name,
+			ItineraryID:   group.canonical.Fingerprint,
+			LinearPath:    result.Path,
+			Legs:          make([]Leg, 0, len(result.Legs)),
+			Normalization: result.Normalization,
+		}
+		for _, leg := range result.Legs {
+			if leg.SurfaceTransfer {
+				itinerary.Legs = append(itinerary.Legs, Leg{Leg: leg})
+			} else {
+				itinerary.Legs = append(itinerary.Legs, Leg{Leg: leg, Ticket: req.Tickets[leg.TicketIndex].Ticket})
+			}
+		}
+		resp.Itineraries = append(resp.Itineraries, itinerary)
+	}
+
+	h.writeSuccess(w, r, resp, warnings)
+}
+
+func (h *Handler) handlePassengerError(w http.ResponseWriter, r *http.Request, payload []byte, err error) {
+	switch status := h.errorStatus(err); status {
+	case http.StatusBadRequest:
+		h.bundler.RecordFailure(payload, string(errorCode(err)))
+		h.handleError(w, r, err, status)
+	case http.StatusInternalServerError:
+		h.bundler.RecordFailure(payload, string(errorCode(err)))
+		h.logger.ErrorContext(r.Context(), "error calculating linear path", "error", err)
+		h.handleError(w, r, err, status)
+	default:
+		h.logger.WarnContext(r.Context(), "linear path calculation stopped", "error", err, "status", status, "path", r.URL.Path)
+		h.handleError(w, r, err, status)
+	}
+}
+
+// passengerProblems reports the malformed tickets and the tickets without a passenger.
+func passengerProblems(tickets []PassengerTicket) []Problem {
+	problems := []Problem{}
+	for i := range tickets {
+		field := fmt.Sprintf("tickets[%d]", i)
+		if problem := tickets[i].ShapeProblem(); problem != "" {
+			problems = append(problems, Problem{Field: field, Message: problem})
+		}
+		if tickets[i].Passenger == "" {
+			problems = append(problems, Problem{Field: field + ".passenger", Message: "is required"})
+		}
+	}
+
+	return problems
+}
+
+// groupByPassenger splits the tickets by passenger, in the order passengers first appear.
+func groupByPassenger(tickets []PassengerTicket) []*passengerGroup {
+	var groups []*passengerGroup
+	byName := make(map[string]*passengerGroup)
+	for i := range tickets {
+		group, ok := byName[tickets[i].Passenger]
+		if !ok {
+			group = &passengerGroup{name: tickets[i].Passenger}
+			byName[group.name] = group
+			groups = append(groups, group)
+		}
+		group.tickets = append(group.tickets, tickets[i].Ticket)
+		group.indexes = append(group.indexes, i)
+	}
+	for _, group := range groups {
+		group.canonical = dispatcher.Canonicalize(group.tickets)
+	}
+
+	return groups
+}
+
+// sharedIndex maps the tickets of the passenger a result was reconstructed for to the request indexes of the
+// identical tickets of the passenger sharing it, through their canonical order.
+func sharedIndex(reconstructed, sharing *passengerGroup) []int {
+	index := make([]int, len(reconstructed.indexes))
+	for position, ticket := range reconstructed.canonical.Order {
+		index[ticket] = sharing.indexes[sharing.canonical.Order[position]]
+	}
+
+	return index
+}
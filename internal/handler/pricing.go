@@ -0,0 +1,33 @@
+package handler
+
+import "github.com/dsha256/dispatcher/internal/pricing"
+
+// WithExchangeRates sets the exchange rates converting the ticket prices to a grand total when the request
+// carries none.
+func WithExchangeRates(rates *pricing.Rates) Option {
+	return func(h *Handler) {
+		h.exchangeRates = rates
+	}
+}
+
+// prices totals the prices of the tickets flown by the legs per currency, converted with the rates of the request
+// or else the configured ones. It returns nil unless some priced ticket names its currency.
+func (h *Handler) prices(legs []Leg, rates *pricing.Rates) *pricing.Summary {
+	var prices []pricing.Price
+	withCurrency := false
+	for _, leg := range legs {
+		if leg.SurfaceTransfer || leg.Ticket.Price == nil {
+			continue
+		}
+		prices = append(prices, pricing.Price{Currency: leg.Ticket.Currency, Amount: *leg.Ticket.Price})
+		withCurrency = withCurrency || leg.Ticket.Currency != ""
+	}
+	if !withCurrency {
+		return nil
+	}
+	if rates == nil {
+		rates = h.exchangeRates
+	}
+
+	return pricing.Summarize(prices, rates)
+}
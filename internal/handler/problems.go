@@ -0,0 +1,167 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/dsha256/dispatcher/internal/dispatcher"
+)
+
+var ErrInvalidRequest = errors.New("invalid request")
+
+// Problem is one thing wrong with a request body. Field is the JSON field at fault, e.g. "strategy"
+// or "tickets[3]", and is empty when the body as a whole is unreadable.
+type Problem struct {
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// RequestError reports every problem found in a request body at once, so clients can fix them in a
+// single round-trip. It unwraps to ErrInvalidRequest.
+type RequestError struct {
+	Problems []Problem `json:"problems"`
+}
+
+func (e *RequestError) Error() string {
+	messages := make([]string, 0, len(e.Problems))
+	for _, p := range e.Problems {
+		if p.Field == "" {
+			messages = append(messages, p.Message)
+
+			continue
+		}
+		messages = append(messages, p.Field+": "+p.Message)
+	}
+
+	return fmt.Sprintf("%s: %s", ErrInvalidRequest, strings.Join(messages, "; "))
+}
+
+func (e *RequestError) Unwrap() error {
+	return ErrInvalidRequest
+}
+
+func (e *RequestError) Details() any {
+	return e
+}
+
+// requestProblems is called once decoding payload into req failed with err. It decodes the body again
+// field by field, and the tickets one by one, to collect every bad field, every malformed ticket and a
+// hard limit violation instead of only the first problem json.Unmarshal stopped at.
+func (h *Handler) requestProblems(r *http.Request, payload []byte, req any, err error) *RequestError {
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(payload, &fields) != nil {
+		// The body is not a JSON object at all, so the original error is all there is to say.
+		return &RequestError{Problems: []Problem{{Message: err.Error()}}}
+	}
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	problems := []Problem{}
+	for _, name := range names {
+		if name == "tickets" {
+			problems = append(problems, h.ticketProblems(fields[name], ticketType(req))...)
+
+			continue
+		}
+
+		field, _ := json.Marshal(map[string]json.RawMessage{name: fields[name]})
+		if fieldErr := dispatcher.DecodeStrict(field, reflect.New(reflect.TypeOf(req).Elem()).Interface()); fieldErr != nil {
+			problems = append(problems, newProblem(name, fieldErr))
+		}
+	}
+	if len(problems) == 0 {
+		problems = append(problems, Problem{Message: err.Error()})
+	}
+
+	return &RequestError{Problems: problems}
+}
+
+// ticketType returns the type of the tickets of req: the elements of its Tickets field, e.g. PassengerTicket,
+// dispatcher.Ticket when it has none.
+func ticketType(req any) reflect.Type {
+	if field, ok := reflect.TypeOf(req).Elem().FieldByName("Tickets"); ok && field.Type.Kind() == reflect.Slice {
+		return field.Type.Elem()
+	}
+
+	return reflect.TypeOf(dispatcher.Ticket{})
+}
+
+// ticketProblems decodes the tickets one by one into ticketType and reports the ones that cannot be decoded or
+// are not a non-empty [source, destination] pair, followed by a hard limit violation.
+func (h *Handler) ticketProblems(raw json.RawMessage, ticketType reflect.Type) []Problem {
+	var tickets []json.RawMessage
+	if err := json.Unmarshal(raw, &tickets); err != nil {
+		return []Problem{{Field: "tickets", Message: err.Error()}}
+	}
+
+	problems := []Problem{}
+	for i, rawTicket := range tickets {
+		field := fmt.Sprintf("tickets[%d]", i)
+
+		ticket, _ := reflect.New(ticketType).Interface().(interface{ ShapeProblem() string })
+		if err := json.Unmarshal(rawTicket, ticket); err != nil {
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &typeErr) && typeErr.Field == "" {
+				problems = append(problems, Problem{
+					Field:   field,
+					Message: "a ticket must be a [source, destination] pair or an object, got " + typeErr.Value,
+				})
+
+				continue
+			}
+			problems = append(problems, newProblem(field, err))
+
+			continue
+		}
+		if problem := ticket.ShapeProblem(); problem != "" {
+			problems = append(problems, Problem{Field: field, Message: problem})
+		}
+	}
+
+	if err := h.limits.Exceeds(len(tickets)); err != nil {
+		problems = append(problems, Problem{Field: "tickets", Message: err.Error()})
+	}
+
+	return problems
+}
+
+// shapeProblems reports the tickets that are not exactly two non-empty airport codes.
+func shapeProblems(tickets []dispatcher.Ticket) []Problem {
+	problems := []Problem{}
+	for i := range tickets {
+		if problem := tickets[i].ShapeProblem(); problem != "" {
+			problems = append(problems, Problem{Field: fmt.Sprintf("tickets[%d]", i), Message: problem})
+		}
+	}
+
+	return problems
+}
+
+// newProblem reports err for the field, rewording JSON type errors without the Go type names json.Unmarshal
+// puts in them. Type errors of nested fields are reported for the nested field, e.g. "constraints.max_legs".
+func newProblem(field string, err error) Problem {
+	var typeErr *json.UnmarshalTypeError
+	if !errors.As(err, &typeErr) {
+		// The decoder reports unknown fields with a plain error.
+		if strings.HasPrefix(err.Error(), "json: unknown field") {
+			return Problem{Field: field, Message: "unknown field"}
+		}
+
+		return Problem{Field: field, Message: err.Error()}
+	}
+
+	if path, nested := strings.CutPrefix(typeErr.Field, field+"."); nested {
+		field += "." + path
+	}
+
+	return Problem{Field: field, Message: fmt.Sprintf("expected %s, got %s", typeErr.Type.Kind(), typeErr.Value)}
+}
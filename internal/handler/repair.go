@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/dsha256/dispatcher/internal/dispatcher"
+)
+
+// RepairDetails are the error details of an invalid ticket set when repairs were requested.
+// Cause holds the details the error carries otherwise, e.g. the validation report.
+type RepairDetails struct {
+	Cause   any                    `json:"cause,omitempty"`
+	Repairs *dispatcher.RepairPlan `json:"repairs"`
+}
+
+type repairError struct {
+	err  error
+	plan *dispatcher.RepairPlan
+}
+
+// withRepairs attaches repair suggestions to errors caused by the structure of the ticket set.
+// Other errors, such as constraint violations, are returned unchanged.
+func withRepairs(err error, tickets []dispatcher.Ticket) error {
+	var validation *dispatcher.ValidationError
+	if !errors.As(err, &validation) && !errors.Is(err, dispatcher.ErrCycleInItinerary) {
+		return err
+	}
+
+	return &repairError{err: err, plan: dispatcher.SuggestRepairs(dispatcher.Pairs(tickets))}
+}
+
+func (e *repairError) Error() string {
+	return e.err.Error()
+}
+
+func (e *repairError) Unwrap() error {
+	return e.err
+}
+
+func (e *repairError) Details() any {
+	details := RepairDetails{Repairs: e.plan}
+	var detailed interface{ Details() any }
+	if errors.As(e.err, &detailed) {
+		details.Cause = detailed.Details()
+	}
+
+	return details
+}
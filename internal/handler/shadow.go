@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/dsha256/dispatcher/internal/dispatcher"
+	"github.com/dsha256/dispatcher/internal/inspector"
+	"github.com/dsha256/dispatcher/internal/shadow"
+)
+
+// WithShadow also runs a sample of the itinerary reconstructions through the shadow's strategy, in the
+// background, comparing its outcome with the response.
+func WithShadow(s *shadow.Shadow) Option {
+	return func(h *Handler) {
+		h.shadow = s
+	}
+}
+
+// compareShadow runs the reconstruction request through the shadow strategy and compares the outcome with the
+// primary result or error, unless the request already used that strategy or was not answered by the algorithm,
+// e.g. it timed out or named an unknown strategy.
+func (h *Handler) compareShadow(
+	w http.ResponseWriter, r *http.Request, req *dispatcher.Request, fingerprint string, result *dispatcher.Result, err error,
+) {
+	strategy := h.dispatcher.StrategyOf(req)
+	if h.shadow == nil || string(strategy) == h.shadow.Strategy() {
+		return
+	}
+	if err != nil && (h.errorStatus(err) != http.StatusBadRequest || errors.Is(err, dispatcher.ErrUnknownStrategy)) {
+		return
+	}
+
+	requestID := w.Header().Get(inspector.RequestIDHeader)
+	if requestID == "" {
+		requestID = r.Header.Get(inspector.RequestIDHeader)
+	}
+	shadowReq := *req
+	shadowReq.Strategy = dispatcher.Strategy(h.shadow.Strategy())
+	h.shadow.Compare(
+		shadow.Request{RequestID: requestID, Tenant: tenantOf(r), ItineraryID: fingerprint, Strategy: string(strategy)},
+		shadowOutcome(result, err),
+		func(ctx context.Context) shadow.Outcome {
+			return shadowOutcome(h.dispatcher.Reconstruct(ctx, &shadowReq))
+		},
+	)
+}
+
+func shadowOutcome(result *dispatcher.Result, err error) shadow.Outcome {
+	if err != nil {
+		return shadow.Outcome{Code: string(errorCode(err))}
+	}
+
+	return shadow.Outcome{Path: result.Path}
+}
@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/dsha256/dispatcher/internal/shedding"
+)
+
+var ErrSheddingDisabled = errors.New("load shedding is disabled")
+
+// SheddingRequest changes the limit of concurrent reconstructions, e.g. {"max_concurrent": 64}.
+type SheddingRequest struct {
+	MaxConcurrent int `json:"max_concurrent"`
+}
+
+// WithShedding caps the reconstructions running at once, shedding the requests beyond the limit, reports
+// the shedder's status, and lets admin requests change the limit.
+func WithShedding(shedder *shedding.Shedder) Option {
+	return func(h *Handler) {
+		h.shedder = shedder
+	}
+}
+
+func (h *Handler) handleShedding(w http.ResponseWriter, r *http.Request) {
+	if h.shedder == nil {
+		h.handleError(w, r, ErrSheddingDisabled, http.StatusNotFound)
+
+		return
+	}
+	h.writeSuccess(w, r, h.shedder.Status(), nil)
+}
+
+func (h *Handler) handlePutShedding(w http.ResponseWriter, r *http.Request) {
+	if h.shedder == nil {
+		h.handleError(w, r, ErrSheddingDisabled, http.StatusNotFound)
+
+		return
+	}
+	var req SheddingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		status, bodyErr := bodyError(err)
+		h.handleError(w, r, bodyErr, status)
+
+		return
+	}
+
+	previous := h.shedder.Status().MaxConcurrent
+	if err := h.shedder.SetLimit(req.MaxConcurrent); err != nil {
+		h.handleError(w, r, err, http.StatusBadRequest)
+
+		return
+	}
+	h.logger.WarnContext(r.Context(), "Load shedding limit changed",
+		"from", previous, "to", req.MaxConcurrent, "remote_addr", r.RemoteAddr)
+	h.writeSuccess(w, r, h.shedder.Status(), nil)
+}
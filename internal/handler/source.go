@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/dsha256/dispatcher/internal/limits"
+	"github.com/dsha256/dispatcher/internal/remote"
+)
+
+var (
+	ErrSourcesDisabled = errors.New("remote sources are disabled")
+	ErrSourceConflict  = errors.New("source_url and tickets are mutually exclusive")
+)
+
+// WithSources lets requests reference their ticket file by URL in source_url, fetched by the fetcher.
+func WithSources(fetcher *remote.Fetcher) Option {
+	return func(h *Handler) {
+		h.sources = fetcher
+	}
+}
+
+// resolveSource sets the tickets of the request to those of the file its source URL references: CSV, JSON or
+// NDJSON, as the body of the itinerary endpoint would be, by the file's Content-Type or extension. Only the
+// tickets of the file are used; the other fields come from the request. It writes the error response itself
+// and returns false when the source cannot be fetched or is invalid.
+func (h *Handler) resolveSource(w http.ResponseWriter, r *http.Request, req *ReconstructItineraryRequest) bool {
+	if req.SourceURL == "" {
+		return true
+	}
+	if h.sources == nil {
+		h.handleError(w, r, ErrSourcesDisabled, http.StatusBadRequest)
+
+		return false
+	}
+	if len(req.Tickets) > 0 {
+		h.handleError(w, r, ErrSourceConflict, http.StatusBadRequest)
+
+		return false
+	}
+
+	file, err := h.sources.Fetch(r.Context(), req.SourceURL)
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "error fetching source", "error", err, "path", r.URL.Path)
+		h.handleError(w, r, err, sourceStatus(err))
+
+		return false
+	}
+	mediaType, err := uploadMediaType(file.ContentType, file.Name)
+	if err != nil {
+		h.handleError(w, r, err, http.StatusUnsupportedMediaType)
+
+		return false
+	}
+
+	source := r.Clone(r.Context())
+	source.Header.Set("Content-Type", mediaType)
+	source.Body = io.NopCloser(bytes.NewReader(file.Data))
+	source.ContentLength = int64(len(file.Data))
+	_, sourceReq, ok := h.decodeTickets(w, source)
+	req.Tickets = sourceReq.Tickets
+
+	return ok
+}
+
+// sourceStatus returns the response status of an error fetching a source.
+func sourceStatus(err error) int {
+	switch {
+	case errors.Is(err, remote.ErrNotAllowed):
+		return http.StatusForbidden
+	case errors.Is(err, limits.ErrBodyTooLarge):
+		return http.StatusRequestEntityTooLarge
+	default:
+		return http.StatusBadGateway
+	}
+}
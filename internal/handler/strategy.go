@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/dsha256/dispatcher/internal/dispatcher"
+	"github.com/dsha256/dispatcher/internal/middleware"
+)
+
+// StrategyHeader selects the strategy of a request naming none in its body, for the clients allowed by
+// WithStrategySelection, e.g. to canary a strategy with some clients before making it the default. The
+// responses to requests whose header was honored carry it back.
+const StrategyHeader = "X-Dispatcher-Strategy"
+
+var ErrStrategyNotAllowed = errors.New("the strategy is not available to this client")
+
+// StrategySelection lists the clients allowed to select the strategy of their requests with the
+// StrategyHeader, and to run the gated strategies. Clients are allowed by their authenticated identity, never
+// by the headers they claim: none is allowed when both lists are empty.
+type StrategySelection struct {
+	// Strategies are gated: only the allowed clients may run them, whether they name them in the body or in the
+	// StrategyHeader, e.g. the strategies being canaried.
+	Strategies []string
+	// Tenants are the allowed tenants, as authenticated by WithTenants.
+	Tenants []string
+	// Clients are the allowed clients, as authenticated by request signing.
+	Clients []string
+}
+
+// WithStrategySelection honors the StrategyHeader of the requests of the selection's clients, and refuses the
+// gated strategies to the others.
+func WithStrategySelection(selection StrategySelection) Option {
+	return func(h *Handler) {
+		h.strategySelection = &selection
+	}
+}
+
+// allows reports whether the authenticated client of the request may select its strategy.
+func (s *StrategySelection) allows(r *http.Request) bool {
+	if identity, ok := middleware.IdentityFrom(r.Context()); ok && slices.Contains(s.Tenants, identity.Tenant) {
+		return true
+	}
+	clientID, ok := middleware.VerifiedClient(r.Context())
+
+	return ok && slices.Contains(s.Clients, clientID)
+}
+
+// selectStrategy returns the strategy of the request: the one named in its body, or else the one of its
+// StrategyHeader when its client may select it. It answers with 403 Forbidden and returns false when the
+// strategy is gated and the client not allowed to run it.
+func (h *Handler) selectStrategy(w http.ResponseWriter, r *http.Request, requested dispatcher.Strategy) (dispatcher.Strategy, bool) {
+	if h.strategySelection == nil {
+		return requested, true
+	}
+	allowed := h.strategySelection.allows(r)
+
+	if selected := r.Header.Get(StrategyHeader); requested == "" && selected != "" {
+		if allowed {
+			w.Header().Set(StrategyHeader, selected)
+			requested = dispatcher.Strategy(selected)
+		} else {
+			h.logger.DebugContext(r.Context(), "Strategy header ignored, the client may not select the strategy", "strategy", selected)
+		}
+	}
+	if !allowed && slices.Contains(h.strategySelection.Strategies, string(requested)) {
+		h.handleError(w, r, ErrStrategyNotAllowed, http.StatusForbidden)
+
+		return "", false
+	}
+
+	return requested, true
+}
@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/dsha256/dispatcher/internal/dispatcher"
+	"github.com/dsha256/dispatcher/internal/types"
+)
+
+// PathElement is a line of a streamed linear path: an airport and its position in the path.
+type PathElement struct {
+	Airport string `json:"airport"`
+	Index   int    `json:"index"`
+}
+
+// PathTrailer is the last line of a streamed linear path, written once every airport has been sent.
+type PathTrailer struct {
+	ItineraryID      string          `json:"itinerary_id"`
+	AlgorithmVersion string          `json:"algorithm_version"`
+	Warnings         []types.Warning `json:"warnings,omitempty"`
+	Length           int             `json:"length"`
+	Done             bool            `json:"done"`
+}
+
+func (h *Handler) handleItineraryStream(w http.ResponseWriter, r *http.Request) {
+	payload, req, ok := h.decodeTicketsRequest(w, r)
+	if !ok {
+		return
+	}
+	if req.Strategy, ok = h.selectStrategy(w, r, req.Strategy); !ok {
+		return
+	}
+
+	warnings, ok := h.checkLimits(w, r, len(req.Tickets))
+	if !ok {
+		return
+	}
+
+	canonical := identify(w, req.Tickets)
+	version, err := dispatcher.ResolveAlgorithmVersion(req.Stability, req.AlgorithmVersion)
+	if err != nil {
+		h.handleError(w, r, err, http.StatusBadRequest)
+
+		return
+	}
+
+	// Validation errors surface before the first airport, while a regular error response can still be written.
+	// Every line is flushed once written, so the client gets each airport as soon as it is found.
+	rc := http.NewResponseController(w)
+	var encoder *json.Encoder
+	begin := func() {
+		if encoder == nil {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.WriteHeader(http.StatusOK)
+			encoder = json.NewEncoder(w)
+		}
+	}
+	length := 0
+	err = h.dispatcher.StreamItinerary(r.Context(), &dispatcher.Request{
+		StrictAirports:   req.StrictAirports,
+		AllowDuplicates:  req.AllowDuplicates,
+		Strategy:         req.Strategy,
+		TieBreak:         req.TieBreak,
+		PreferredHubs:    req.PreferredHubs,
+		NormalizeCodes:   req.NormalizeCodes,
+		Aliases:          req.Aliases,
+		Constraints:      req.Constraints,
+		AlgorithmVersion: version,
+		Tickets:          req.Tickets,
+	}, func(index int, airport string) error {
+		begin()
+		length++
+		if err := encoder.Encode(PathElement{Index: index, Airport: airport}); err != nil {
+			return err
+		}
+		if err := rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+
+		return nil
+	})
+	if err != nil && encoder == nil {
+		status := h.errorStatus(err)
+		if status == http.StatusBadRequest || status == http.StatusInternalServerError {
+			h.bundler.RecordFailure(payload, string(errorCode(err)))
+		}
+		h.handleError(w, r, err, status)
+
+		return
+	}
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "error streaming linear path", "error", err, "path", r.URL.Path)
+
+		return
+	}
+
+	begin()
+	if err = encoder.Encode(PathTrailer{ItineraryID: canonical.Fingerprint, AlgorithmVersion: version, Warnings: warnings, Length: length, Done: true}); err != nil {
+		h.logger.WarnContext(r.Context(), "error streaming linear path", "error", err, "path", r.URL.Path)
+	}
+}
@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dsha256/dispatcher/internal/dispatcher"
+	"github.com/dsha256/dispatcher/internal/summary"
+)
+
+// SummarizeItineraryRequest reconstructs the itinerary like ReconstructItineraryRequest and renders it as text.
+// Locale defaults to the Accept-Language header; Template names one of the summary.Templates, the locale's
+// default one when empty.
+type SummarizeItineraryRequest struct {
+	ReconstructItineraryRequest
+
+	Locale   string `json:"locale,omitempty"`
+	Template string `json:"template,omitempty"`
+}
+
+// SummarizeItineraryResponse carries the legs of the path like ReconstructItineraryResponse, so the summary can
+// be joined back to the tickets of the request even when airports repeat.
+type SummarizeItineraryResponse struct {
+	ItineraryID string           `json:"itinerary_id"`
+	Summary     string           `json:"summary"`
+	Locale      string           `json:"locale"`
+	LinearPath  []string         `json:"linear_path"`
+	Legs        []dispatcher.Leg `json:"legs"`
+}
+
+func (h *Handler) handleItinerarySummary(w http.ResponseWriter, r *http.Request) {
+	var req SummarizeItineraryRequest
+	payload, ok := h.decodeRequest(w, r, &req)
+	if !ok || !h.checkTicketShapes(w, r, payload, req.Tickets) {
+		return
+	}
+	if req.Strategy, ok = h.selectStrategy(w, r, req.Strategy); !ok {
+		return
+	}
+
+	warnings, ok := h.checkLimits(w, r, len(req.Tickets))
+	if !ok {
+		return
+	}
+
+	canonical := identify(w, req.Tickets)
+	locale := req.Locale
+	if locale == "" {
+		locale = summary.NegotiateLocale(r.Header.Get("Accept-Language"))
+	}
+	summarizer, err := summary.New(locale, req.Template)
+	if err != nil {
+		h.handleError(w, r, err, http.StatusBadRequest)
+
+		return
+	}
+
+	result, ok := h.runReconstruction(w, r, payload, &req.ReconstructItineraryRequest, canonical)
+	if !ok {
+		return
+	}
+
+	text, err := summarizer.Summarize(r.Context(), result.Path)
+	if err != nil {
+		h.handleError(w, r, err, h.summaryErrorStatus(err))
+
+		return
+	}
+
+	h.writeSuccess(w, r, SummarizeItineraryResponse{
+		ItineraryID: canonical.Fingerprint,
+		Summary:     text,
+		Locale:      summarizer.Locale(),
+		LinearPath:  result.Path,
+		Legs:        result.Legs,
+	}, warnings)
+}
+
+// summaryErrorStatus maps an error rendering a summary to the response status.
+func (h *Handler) summaryErrorStatus(err error) int {
+	if errors.Is(err, summary.ErrSummaryTooLong) {
+		return http.StatusUnprocessableEntity
+	}
+
+	return h.errorStatus(err)
+}
@@ -0,0 +1,16 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+)
+
+func (h *Handler) handleSupportBundle(w http.ResponseWriter, r *http.Request) {
+	filename := fmt.Sprintf("dispatcher-support-%s.tar.gz", h.clock.Now().UTC().Format("20060102T150405Z"))
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	if err := h.bundler.Write(w); err != nil {
+		h.logger.ErrorContext(r.Context(), "error writing support bundle", "error", err)
+	}
+}
@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dsha256/dispatcher/internal/middleware"
+)
+
+var (
+	ErrTenantsDisabled       = errors.New("per-tenant resources are disabled: no tenant authentication is configured")
+	ErrUnauthenticatedTenant = errors.New("missing or invalid tenant credentials")
+)
+
+// WithTenants authenticates the tenant of client requests by API key or signing client, instead of trusting
+// the X-Tenant-Id header they claim. The routes of per-tenant resources, such as saved itineraries, are
+// refused without it.
+func WithTenants(tenants middleware.Tenants) Option {
+	return func(h *Handler) {
+		h.tenants = tenants
+	}
+}
+
+// authenticatedTenant returns the authenticated tenant of the request, for routes of per-tenant resources.
+// Otherwise, it answers with 403 Forbidden when no tenant authentication is configured, and 401 Unauthorized
+// when the request is unauthenticated, and returns false.
+func (h *Handler) authenticatedTenant(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if !h.tenants.Enabled() {
+		h.handleError(w, r, ErrTenantsDisabled, http.StatusForbidden)
+
+		return "", false
+	}
+	identity, ok := middleware.IdentityFrom(r.Context())
+	if !ok {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		h.handleError(w, r, ErrUnauthenticatedTenant, http.StatusUnauthorized)
+
+		return "", false
+	}
+
+	return identity.Tenant, true
+}
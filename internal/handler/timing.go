@@ -0,0 +1,96 @@
+package handler
+
+import "time"
+
+// Timing is the schedule of the itinerary, from the departure and arrival times of its tickets. Times are given
+// in the timezone of their airport when the airport is known, and durations only when both ends are known;
+// Complete tells whether every flight has both times.
+type Timing struct {
+	// TotalDuration is the elapsed time from the departure of the first flight to the arrival of the last.
+	TotalDuration string      `json:"total_duration,omitempty"`
+	Legs          []LegTiming `json:"legs"`
+	Layovers      []Layover   `json:"layovers"`
+	Complete      bool        `json:"complete"`
+}
+
+// LegTiming is the schedule of a single leg. Surface transfers have no times.
+type LegTiming struct {
+	DepartsAt       *time.Time `json:"departs_at,omitempty"`
+	ArrivesAt       *time.Time `json:"arrives_at,omitempty"`
+	From            string     `json:"from"`
+	To              string     `json:"to"`
+	Duration        string     `json:"duration,omitempty"`
+	SurfaceTransfer bool       `json:"surface_transfer,omitempty"`
+}
+
+// Layover is the time between the arrival of a flight and the departure of the next one. DepartureAirport is
+// only set when the next flight leaves from another airport, after a surface transfer.
+type Layover struct {
+	Airport          string `json:"airport"`
+	DepartureAirport string `json:"departure_airport,omitempty"`
+	Duration         string `json:"duration"`
+}
+
+// timing computes the schedule of the legs, or returns nil when no ticket carries a time.
+func (h *Handler) timing(legs []Leg) *Timing {
+	t := &Timing{Legs: make([]LegTiming, 0, len(legs)), Layovers: []Layover{}, Complete: true}
+
+	timed := false
+	// flights are the legs flown, with their times.
+	var flights []LegTiming
+	for _, leg := range legs {
+		lt := LegTiming{From: leg.From, To: leg.To, SurfaceTransfer: leg.SurfaceTransfer}
+		if leg.SurfaceTransfer {
+			t.Legs = append(t.Legs, lt)
+
+			continue
+		}
+
+		lt.DepartsAt = h.localTime(leg.Ticket.DepartsAt, leg.From)
+		lt.ArrivesAt = h.localTime(leg.Ticket.ArrivesAt, leg.To)
+		if lt.DepartsAt != nil || lt.ArrivesAt != nil {
+			timed = true
+		}
+		if lt.DepartsAt != nil && lt.ArrivesAt != nil {
+			lt.Duration = lt.ArrivesAt.Sub(*lt.DepartsAt).String()
+		} else {
+			t.Complete = false
+		}
+		t.Legs = append(t.Legs, lt)
+
+		if len(flights) > 0 {
+			if previous := flights[len(flights)-1]; previous.ArrivesAt != nil && lt.DepartsAt != nil {
+				layover := Layover{Airport: previous.To, Duration: lt.DepartsAt.Sub(*previous.ArrivesAt).String()}
+				if previous.To != lt.From {
+					layover.DepartureAirport = lt.From
+				}
+				t.Layovers = append(t.Layovers, layover)
+			}
+		}
+		flights = append(flights, lt)
+	}
+
+	if !timed {
+		return nil
+	}
+	if first, last := flights[0], flights[len(flights)-1]; first.DepartsAt != nil && last.ArrivesAt != nil {
+		t.TotalDuration = last.ArrivesAt.Sub(*first.DepartsAt).String()
+	}
+
+	return t
+}
+
+// localTime returns the time in the timezone of the airport, or as given when the timezone is unknown.
+func (h *Handler) localTime(at *time.Time, code string) *time.Time {
+	if at == nil {
+		return nil
+	}
+	local := *at
+	if airport, ok := h.airports.Lookup(code); ok {
+		if location, ok := airport.Location(); ok {
+			local = at.In(location)
+		}
+	}
+
+	return &local
+}
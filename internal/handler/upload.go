@@ -0,0 +1,200 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/dsha256/dispatcher/internal/jobs"
+	"github.com/dsha256/dispatcher/internal/limits"
+	"github.com/dsha256/dispatcher/internal/responder"
+)
+
+var (
+	ErrMissingUpload     = errors.New("multipart form has no file field")
+	ErrUnsupportedUpload = errors.New("unsupported ticket file")
+	ErrJobsDisabled      = errors.New("jobs are disabled")
+)
+
+const (
+	// uploadField is the form field carrying the uploaded file.
+	uploadField = "file"
+	// jobsPath prefixes the URLs of jobs.
+	jobsPath = "/api/v1/dispatcher/jobs/"
+
+	defaultUploadMaxBytes   = 32 << 20
+	defaultUploadAsyncBytes = 1 << 20
+)
+
+// Upload configures the itinerary upload endpoint.
+type Upload struct {
+	// MaxBytes caps the size of uploaded files, 32 MiB when 0. The request, form included, is capped by
+	// the limits' MaxBodyBytes too.
+	MaxBytes int64
+	// AsyncBytes is the size above which uploaded files are reconstructed as jobs when jobs are enabled,
+	// 1 MiB when 0.
+	AsyncBytes int64
+}
+
+// WithUploads configures the itinerary upload endpoint, reconstructing files above cfg.AsyncBytes as jobs of
+// the store, whose workers New starts; a nil store reconstructs every file while the client waits. The store
+// is closed by its owner.
+func WithUploads(cfg Upload, store *jobs.Store) Option {
+	return func(h *Handler) {
+		h.upload = cfg
+		h.jobs = store
+	}
+}
+
+// handleItineraryUpload reconstructs the itinerary of a ticket file uploaded in the file field of a
+// multipart form: CSV, JSON or NDJSON, as the body of the itinerary endpoint would be. Small files are
+// answered as the itinerary endpoint answers; files above the async size are run as a job, answered with
+// 202 Accepted, the job and its URL in Location.
+func (h *Handler) handleItineraryUpload(w http.ResponseWriter, r *http.Request) {
+	data, mediaType, err := h.readUpload(r)
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "error reading upload", "error", err, "path", r.URL.Path)
+		status, uploadErr := uploadError(err)
+		h.handleError(w, r, uploadErr, status)
+
+		return
+	}
+
+	upload := r.Clone(r.Context())
+	upload.Header.Set("Content-Type", mediaType)
+	upload.Body = io.NopCloser(bytes.NewReader(data))
+	upload.ContentLength = int64(len(data))
+
+	asyncBytes := h.upload.AsyncBytes
+	if asyncBytes <= 0 {
+		asyncBytes = defaultUploadAsyncBytes
+	}
+	if h.jobs == nil || int64(len(data)) <= asyncBytes {
+		h.reconstructItinerary(w, upload)
+
+		return
+	}
+
+	job, err := h.jobs.Submit(tenantOf(r), upload)
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "error submitting job", "error", err, "path", r.URL.Path)
+		h.handleError(w, r, err, http.StatusServiceUnavailable)
+
+		return
+	}
+	w.Header().Set("Location", jobsPath+job.ID)
+	h.responder.Success(w, r, http.StatusAccepted, responder.Success{Data: job})
+}
+
+// readUpload reads the file of the multipart form and returns it with its media type, from its
+// Content-Type or else its extension.
+func (h *Handler) readUpload(r *http.Request) ([]byte, string, error) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %w", ErrUnsupportedUpload, err)
+	}
+
+	maxBytes := h.upload.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultUploadMaxBytes
+	}
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			return nil, "", ErrMissingUpload
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		if part.FormName() != uploadField {
+			continue
+		}
+
+		mediaType, err := uploadMediaType(part.Header.Get("Content-Type"), part.FileName())
+		if err != nil {
+			return nil, "", err
+		}
+		data, err := io.ReadAll(io.LimitReader(part, maxBytes+1))
+		if err != nil {
+			return nil, "", err
+		}
+		if int64(len(data)) > maxBytes {
+			return nil, "", &limits.BodyError{MaxBodyBytes: maxBytes}
+		}
+
+		return data, mediaType, nil
+	}
+}
+
+// uploadMediaType returns the media type of the uploaded file, by its Content-Type, or by its extension when
+// the Content-Type is missing or generic, e.g. application/octet-stream.
+func uploadMediaType(contentType, filename string) (string, error) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "text/csv", "application/json", "application/x-ndjson":
+		return mediaType, nil
+	}
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		return "text/csv", nil
+	case ".json":
+		return "application/json", nil
+	case ".ndjson", ".jsonl":
+		return "application/x-ndjson", nil
+	default:
+		return "", fmt.Errorf("%w %q: want a CSV, JSON or NDJSON file", ErrUnsupportedUpload, filename)
+	}
+}
+
+// uploadError maps an error reading an upload to the response status and error.
+func uploadError(err error) (int, error) {
+	switch {
+	case errors.Is(err, ErrUnsupportedUpload):
+		return http.StatusUnsupportedMediaType, err
+	case errors.Is(err, limits.ErrBodyTooLarge):
+		return http.StatusRequestEntityTooLarge, err
+	default:
+		return bodyError(err)
+	}
+}
+
+// handleJob answers a job of the tenant: 202 Accepted with the job while it runs, then the response of its
+// request.
+func (h *Handler) handleJob(w http.ResponseWriter, r *http.Request) {
+	if h.jobs == nil {
+		h.handleError(w, r, ErrJobsDisabled, http.StatusNotFound)
+
+		return
+	}
+	job, ok, err := h.jobs.Get(r.Context(), tenantOf(r), r.PathValue("id"))
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error getting job", "error", err, "path", r.URL.Path)
+		h.handleError(w, r, err, http.StatusServiceUnavailable)
+
+		return
+	}
+	if !ok {
+		h.handleError(w, r, ErrNotFound, http.StatusNotFound)
+
+		return
+	}
+
+	if job.Response == nil {
+		w.Header().Set("Retry-After", "1")
+		h.responder.Success(w, r, http.StatusAccepted, responder.Success{Data: job})
+
+		return
+	}
+	header := w.Header()
+	for name, values := range job.Response.Header {
+		header[name] = values
+	}
+	w.WriteHeader(job.Response.Status)
+	_, _ = w.Write(job.Response.Body)
+}
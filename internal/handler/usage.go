@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/dsha256/dispatcher/internal/accounting"
+	"github.com/dsha256/dispatcher/internal/middleware"
+)
+
+// Headers carrying the approximate resources consumed by a reconstruction, to requests authorized to read
+// the admin endpoints only: they reveal the load of the service.
+const (
+	UsageCPUTimeHeader    = "X-Usage-Cpu-Time-Ns"
+	UsageAllocBytesHeader = "X-Usage-Alloc-Bytes"
+)
+
+// recordUsage adds the usage to the totals of the request's tenant, the authenticated one when tenants are
+// authenticated, and sets the usage headers when the request carries an admin read token.
+func (h *Handler) recordUsage(w http.ResponseWriter, r *http.Request, usage accounting.Usage) {
+	tenant := tenantOf(r)
+	if identity, ok := middleware.IdentityFrom(r.Context()); ok {
+		tenant = identity.Tenant
+	}
+	h.usage.Record(tenant, usage)
+
+	if !(middleware.Admin{Token: h.adminToken, ReadToken: h.adminReadToken}).Reads(r) {
+		return
+	}
+	w.Header().Set(UsageCPUTimeHeader, strconv.FormatInt(usage.CPUTime.Nanoseconds(), 10))
+	w.Header().Set(UsageAllocBytesHeader, strconv.FormatUint(usage.AllocBytes, 10))
+}
+
+func (h *Handler) handleUsage(w http.ResponseWriter, r *http.Request) {
+	h.writeSuccess(w, r, h.usage.Snapshot(), nil)
+}
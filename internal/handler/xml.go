@@ -0,0 +1,32 @@
+package handler
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+)
+
+// decodeXMLRequest reads an XML body into req through the xml tags of the request's model structs, e.g.
+// <itinerary><tickets><ticket><from>JFK</from><to>LAX</to></ticket></tickets></itinerary>; the root element
+// may have any name, and elements the structs do not have are ignored. Tickets have no metadata in XML.
+// It writes the error response itself and returns false when the body is invalid.
+func (h *Handler) decodeXMLRequest(w http.ResponseWriter, r *http.Request, req *ReconstructItineraryRequest) ([]byte, bool) {
+	payload, err := io.ReadAll(r.Body)
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "error reading request body", "error", err, "path", r.URL.Path)
+		status, bodyErr := bodyError(err)
+		h.handleError(w, r, bodyErr, status)
+
+		return nil, false
+	}
+
+	if err = xml.Unmarshal(payload, req); err != nil {
+		h.logger.WarnContext(r.Context(), "error decoding XML request body", "error", err, "path", r.URL.Path)
+		h.bundler.RecordFailure(payload, string(errorCode(err)))
+		h.handleError(w, r, &RequestError{Problems: []Problem{{Message: err.Error()}}}, http.StatusBadRequest)
+
+		return nil, false
+	}
+
+	return payload, true
+}
@@ -0,0 +1,146 @@
+// Package health is the registry of the checks readiness depends on: subsystems register a check of each
+// dependency they need, e.g. a ping of Redis, and readiness runs them all.
+package health
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Check statuses.
+const (
+	StatusOK      = "ok"
+	StatusFailing = "failing"
+)
+
+// DefaultTimeout bounds each check unless registered with WithTimeout, so an unresponsive dependency fails
+// its check rather than hanging readiness.
+const DefaultTimeout = 2 * time.Second
+
+// Check reports whether a dependency is healthy, e.g. by pinging it.
+type Check func(ctx context.Context) error
+
+// Result is the outcome of a check.
+type Result struct {
+	// Error is the error of a failing check.
+	Error  string `json:"error,omitempty"`
+	Status string `json:"status"`
+	// DurationMS is how long the check took, in milliseconds.
+	DurationMS float64 `json:"duration_ms"`
+	// Critical checks failing make the service not ready; others only degrade it.
+	Critical bool `json:"critical"`
+}
+
+// Option configures a registered check.
+type Option func(*entry)
+
+// NonCritical registers a check of a dependency the service can do without, e.g. the publisher of
+// best-effort events: the service stays ready, degraded, while it fails.
+func NonCritical() Option {
+	return func(e *entry) {
+		e.critical = false
+	}
+}
+
+// WithTimeout bounds the check with the timeout instead of DefaultTimeout.
+func WithTimeout(timeout time.Duration) Option {
+	return func(e *entry) {
+		e.timeout = timeout
+	}
+}
+
+type entry struct {
+	check    Check
+	name     string
+	timeout  time.Duration
+	critical bool
+}
+
+// Registry holds the checks readiness depends on. It is safe for concurrent use, so subsystems may register
+// checks once they start.
+type Registry struct {
+	entries []entry
+	mu      sync.RWMutex
+}
+
+// Register adds the named check, critical unless registered NonCritical. A check registered under a name
+// already taken replaces the previous one.
+func (r *Registry) Register(name string, check Check, opts ...Option) {
+	e := entry{name: name, check: check, timeout: DefaultTimeout, critical: true}
+	for _, opt := range opts {
+		opt(&e)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.entries {
+		if r.entries[i].name == name {
+			r.entries[i] = e
+
+			return
+		}
+	}
+	r.entries = append(r.entries, e)
+}
+
+// Len returns the number of registered checks.
+func (r *Registry) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return len(r.entries)
+}
+
+// Run runs every check concurrently and returns their results by name, nil without checks, and whether
+// every critical check passed.
+func (r *Registry) Run(ctx context.Context) (map[string]Result, bool) {
+	r.mu.RLock()
+	entries := slices.Clone(r.entries)
+	r.mu.RUnlock()
+	if len(entries) == 0 {
+		return nil, true
+	}
+
+	results := make([]Result, len(entries))
+	var wg sync.WaitGroup
+	for i, e := range entries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = e.run(ctx)
+		}()
+	}
+	wg.Wait()
+
+	byName := make(map[string]Result, len(entries))
+	ready := true
+	for i, e := range entries {
+		byName[e.name] = results[i]
+		if results[i].Status != StatusOK && e.critical {
+			ready = false
+		}
+	}
+
+	return byName, ready
+}
+
+func (e entry) run(ctx context.Context) Result {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := e.check(ctx)
+	result := Result{
+		Status:     StatusOK,
+		DurationMS: float64(time.Since(start).Microseconds()) / 1000,
+		Critical:   e.critical,
+	}
+	if err != nil {
+		result.Status, result.Error = StatusFailing, err.Error()
+	}
+
+	return result
+}
@@ -0,0 +1,103 @@
+package health_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/health"
+)
+
+func TestRegistryRun(t *testing.T) {
+	t.Parallel()
+
+	errDown := errors.New("connection refused")
+	ok := func(context.Context) error { return nil }
+	down := func(context.Context) error { return errDown }
+	hang := func(ctx context.Context) error {
+		<-ctx.Done()
+
+		return ctx.Err()
+	}
+
+	tests := []struct {
+		register    func(r *health.Registry)
+		wantResults map[string]string
+		name        string
+		wantReady   bool
+	}{
+		{
+			name:      "Without checks",
+			register:  func(*health.Registry) {},
+			wantReady: true,
+		},
+		{
+			name: "Passing checks",
+			register: func(r *health.Registry) {
+				r.Register("redis", ok)
+				r.Register("postgres", ok)
+			},
+			wantResults: map[string]string{"redis": health.StatusOK, "postgres": health.StatusOK},
+			wantReady:   true,
+		},
+		{
+			name: "Failing critical check",
+			register: func(r *health.Registry) {
+				r.Register("redis", ok)
+				r.Register("postgres", down)
+			},
+			wantResults: map[string]string{"redis": health.StatusOK, "postgres": health.StatusFailing},
+		},
+		{
+			name: "Failing non-critical check",
+			register: func(r *health.Registry) {
+				r.Register("redis", ok)
+				r.Register("events", down, health.NonCritical())
+			},
+			wantResults: map[string]string{"redis": health.StatusOK, "events": health.StatusFailing},
+			wantReady:   true,
+		},
+		{
+			name: "Hanging check",
+			register: func(r *health.Registry) {
+				r.Register("queue", hang, health.WithTimeout(10*time.Millisecond))
+			},
+			wantResults: map[string]string{"queue": health.StatusFailing},
+		},
+		{
+			name: "Replaced check",
+			register: func(r *health.Registry) {
+				r.Register("redis", down)
+				r.Register("redis", ok)
+			},
+			wantResults: map[string]string{"redis": health.StatusOK},
+			wantReady:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var registry health.Registry
+			tt.register(&registry)
+
+			results, ready := registry.Run(context.Background())
+			if ready != tt.wantReady {
+				t.Errorf("Expected ready %t, got %t", tt.wantReady, ready)
+			}
+			if len(results) != len(tt.wantResults) {
+				t.Fatalf("Expected %d results, got %v", len(tt.wantResults), results)
+			}
+			for name, want := range tt.wantResults {
+				if results[name].Status != want {
+					t.Errorf("Expected %s %s, got %+v", name, want, results[name])
+				}
+				if want == health.StatusFailing && results[name].Error == "" {
+					t.Errorf("Expected the error of %s, got %+v", name, results[name])
+				}
+			}
+		})
+	}
+}
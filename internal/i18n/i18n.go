@@ -0,0 +1,204 @@
+// Package i18n translates the messages of responses into the language of the Accept-Language header:
+// success messages by their messages.Key, and error messages by their apierror.Code. English is the
+// language of the service itself, so it is never translated.
+//
+// Translations are JSON documents named after their language, e.g. de.json:
+//
+//	{"messages": {"service.live": "Alle Dienste laufen"}, "errors": {"CYCLE": "Die Tickets bilden eine Schleife"}}
+//
+// A catalog is embedded in the binary; loaders add or override translations, e.g. from a directory.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/dsha256/dispatcher/internal/messages"
+	"github.com/dsha256/dispatcher/pkg/apierror"
+)
+
+var ErrInvalidTranslations = errors.New("invalid translations")
+
+// DefaultLanguage is the language of untranslated messages.
+const DefaultLanguage = "en"
+
+//go:embed translations/*.json
+var embedded embed.FS
+
+// Catalog is the translations of a language.
+type Catalog struct {
+	// Messages are the success messages by messages.Key, e.g. "service.live".
+	Messages map[string]string `json:"messages"`
+	// Errors are the error messages by apierror.Code, e.g. "CYCLE".
+	Errors map[string]string `json:"errors"`
+}
+
+// Translations are the catalogs by language, e.g. "de".
+type Translations map[string]Catalog
+
+// Loader loads translations, e.g. from files or a translation service.
+type Loader interface {
+	Load() (Translations, error)
+}
+
+// LoaderFunc adapts a function to a Loader.
+type LoaderFunc func() (Translations, error)
+
+func (f LoaderFunc) Load() (Translations, error) {
+	return f()
+}
+
+// Embedded returns the loader of the catalog embedded in the binary.
+func Embedded() Loader {
+	return FS(embedded, "translations")
+}
+
+// Dir returns the loader of the translations in a directory, one <language>.json file per language.
+func Dir(dir string) Loader {
+	return FS(os.DirFS(dir), ".")
+}
+
+// FS returns the loader of the translations in a directory of the file system, one <language>.json file
+// per language; other files are ignored.
+func FS(fsys fs.FS, dir string) Loader {
+	return LoaderFunc(func() (Translations, error) {
+		entries, err := fs.ReadDir(fsys, dir)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidTranslations, err)
+		}
+
+		translations := make(Translations, len(entries))
+		for _, entry := range entries {
+			language, ok := strings.CutSuffix(entry.Name(), ".json")
+			if !ok || entry.IsDir() {
+				continue
+			}
+			data, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w", ErrInvalidTranslations, err)
+			}
+			var catalog Catalog
+			if err := json.Unmarshal(data, &catalog); err != nil {
+				return nil, fmt.Errorf("%w: %s: %w", ErrInvalidTranslations, entry.Name(), err)
+			}
+			translations[strings.ToLower(language)] = catalog
+		}
+
+		return translations, nil
+	})
+}
+
+// Translator resolves messages in a language. It is read-only after construction and safe for concurrent
+// use; a nil Translator translates nothing.
+type Translator struct {
+	catalogs map[string]Catalog
+}
+
+// New returns the translator of the translations of the loaders, later loaders overriding the texts of
+// earlier ones key by key. Translating an unknown message key or error code is an error, so typos are
+// caught at startup rather than silently ignored.
+func New(loaders ...Loader) (*Translator, error) {
+	t := &Translator{catalogs: make(map[string]Catalog)}
+	for _, loader := range loaders {
+		translations, err := loader.Load()
+		if err != nil {
+			return nil, err
+		}
+		for language, catalog := range translations {
+			if err := validate(language, catalog); err != nil {
+				return nil, err
+			}
+			merged := t.catalogs[language]
+			merged.Messages = merge(merged.Messages, catalog.Messages)
+			merged.Errors = merge(merged.Errors, catalog.Errors)
+			t.catalogs[language] = merged
+		}
+	}
+
+	return t, nil
+}
+
+// validate checks that the catalog translates known message keys and error codes, into a language other
+// than the default one.
+func validate(language string, catalog Catalog) error {
+	if language == "" || language == DefaultLanguage {
+		return fmt.Errorf("%w: %q cannot be translated", ErrInvalidTranslations, language)
+	}
+
+	unknown := []string{}
+	defaults := messages.Defaults()
+	for key := range catalog.Messages {
+		if _, ok := defaults[messages.Key(key)]; !ok {
+			unknown = append(unknown, key)
+		}
+	}
+	for code := range catalog.Errors {
+		if !apierror.Code(code).Known() {
+			unknown = append(unknown, code)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+
+		return fmt.Errorf("%w: %s: unknown keys %s", ErrInvalidTranslations, language, strings.Join(unknown, ", "))
+	}
+
+	return nil
+}
+
+func merge(texts, overrides map[string]string) map[string]string {
+	if texts == nil {
+		texts = make(map[string]string, len(overrides))
+	}
+	for key, text := range overrides {
+		texts[key] = text
+	}
+
+	return texts
+}
+
+// Negotiate picks the first language of an Accept-Language header that is translated or the default one,
+// ignoring weights and regions, e.g. "de" for "de-CH, en;q=0.5". It returns DefaultLanguage when no
+// language of the header is supported.
+func (t *Translator) Negotiate(acceptLanguage string) string {
+	if t == nil {
+		return DefaultLanguage
+	}
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, _, _ := strings.Cut(strings.TrimSpace(part), ";")
+		language, _, _ := strings.Cut(tag, "-")
+		language = strings.ToLower(language)
+		if _, ok := t.catalogs[language]; ok || language == DefaultLanguage {
+			return language
+		}
+	}
+
+	return DefaultLanguage
+}
+
+// Message returns the translation of the success message, false when it has none in the language.
+func (t *Translator) Message(language string, key messages.Key) (string, bool) {
+	if t == nil {
+		return "", false
+	}
+	text, ok := t.catalogs[language].Messages[string(key)]
+
+	return text, ok
+}
+
+// Error returns the translation of the error message of the code, false when it has none in the language.
+func (t *Translator) Error(language string, code apierror.Code) (string, bool) {
+	if t == nil {
+		return "", false
+	}
+	text, ok := t.catalogs[language].Errors[string(code)]
+
+	return text, ok
+}
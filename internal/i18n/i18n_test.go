@@ -0,0 +1,118 @@
+package i18n_test
+
+import (
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	"github.com/dsha256/dispatcher/internal/i18n"
+	"github.com/dsha256/dispatcher/internal/messages"
+	"github.com/dsha256/dispatcher/pkg/apierror"
+)
+
+func TestTranslator(t *testing.T) {
+	t.Parallel()
+
+	overrides := fstest.MapFS{
+		"de.json":    {Data: []byte(`{"messages": {"service.live": "Läuft"}}`)},
+		"it.json":    {Data: []byte(`{"errors": {"CYCLE": "I biglietti formano un anello"}}`)},
+		"README.txt": {Data: []byte("Not a translation")},
+	}
+	translator, err := i18n.New(i18n.Embedded(), i18n.FS(overrides, "."))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		acceptLanguage string
+		wantLanguage   string
+		wantMessage    string
+		wantError      string
+	}{
+		{
+			name:           "Overridden message",
+			acceptLanguage: "de-DE",
+			wantLanguage:   "de",
+			wantMessage:    "Läuft",
+			wantError:      "Die Tickets bilden eine Schleife ohne Startpunkt",
+		},
+		{
+			name:           "Added language",
+			acceptLanguage: "it;q=0.9, fr;q=0.8",
+			wantLanguage:   "it",
+			wantError:      "I biglietti formano un anello",
+		},
+		{name: "Default language first", acceptLanguage: "en, de", wantLanguage: "en"},
+		{name: "Unsupported language", acceptLanguage: "ja", wantLanguage: "en"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			language := translator.Negotiate(tt.acceptLanguage)
+			if language != tt.wantLanguage {
+				t.Fatalf("Negotiate(%q) = %q; want %q", tt.acceptLanguage, language, tt.wantLanguage)
+			}
+			if got, _ := translator.Message(language, messages.ServiceLive); got != tt.wantMessage {
+				t.Errorf("Message() = %q; want %q", got, tt.wantMessage)
+			}
+			if got, _ := translator.Error(language, apierror.CodeCycle); got != tt.wantError {
+				t.Errorf("Error() = %q; want %q", got, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestEmbeddedTranslations(t *testing.T) {
+	t.Parallel()
+
+	translator, err := i18n.New(i18n.Embedded())
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	for _, language := range []string{"de", "es", "fr"} {
+		for key := range messages.Defaults() {
+			if _, ok := translator.Message(language, key); !ok {
+				t.Errorf("Message(%q, %q) is missing", language, key)
+			}
+		}
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		files fstest.MapFS
+		name  string
+	}{
+		{name: "Unknown message key", files: fstest.MapFS{"de.json": {Data: []byte(`{"messages": {"service.alive": "Läuft"}}`)}}},
+		{name: "Unknown error code", files: fstest.MapFS{"de.json": {Data: []byte(`{"errors": {"LOOP": "Schleife"}}`)}}},
+		{name: "Default language", files: fstest.MapFS{"en.json": {Data: []byte(`{"messages": {"service.live": "Up"}}`)}}},
+		{name: "Malformed file", files: fstest.MapFS{"de.json": {Data: []byte(`{"messages": [`)}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := i18n.New(i18n.FS(tt.files, ".")); !errors.Is(err, i18n.ErrInvalidTranslations) {
+				t.Errorf("New() error = %v; want %v", err, i18n.ErrInvalidTranslations)
+			}
+		})
+	}
+}
+
+func TestNilTranslator(t *testing.T) {
+	t.Parallel()
+
+	var translator *i18n.Translator
+	if got := translator.Negotiate("de"); got != i18n.DefaultLanguage {
+		t.Errorf("Negotiate() = %q; want %q", got, i18n.DefaultLanguage)
+	}
+	if _, ok := translator.Message("de", messages.ServiceLive); ok {
+		t.Error("Message() translated with a nil translator")
+	}
+}
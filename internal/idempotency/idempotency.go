@@ -0,0 +1,357 @@
+// Package idempotency honors Idempotency-Key headers on POST requests: the first response for a key is
+// stored and replayed to the retries carrying the same key and payload, so clients retrying aggressively,
+// e.g. through flaky mobile networks, get the same answer rather than a new computation.
+package idempotency
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/clock"
+	"github.com/dsha256/dispatcher/internal/middleware"
+	"github.com/dsha256/dispatcher/internal/responder"
+	"github.com/dsha256/dispatcher/pkg/apierror"
+)
+
+var (
+	ErrKeyReused    = errors.New("idempotency key already used with another request")
+	ErrInProgress   = errors.New("a request with this idempotency key is still in progress")
+	ErrKeyTooLong   = errors.New("idempotency key too long")
+	ErrInvalidTTL   = errors.New("idempotency ttl must not be negative")
+	ErrInvalidSize  = errors.New("idempotency max_entries must not be negative")
+	ErrInvalidBytes = errors.New("idempotency max_bytes must not be negative")
+)
+
+const (
+	// Header carries the idempotency key of a request, chosen by the client, e.g. a UUID.
+	Header = "Idempotency-Key"
+	// ReplayedHeader is set to "true" on replayed responses.
+	ReplayedHeader = "Idempotent-Replayed"
+	// MaxKeyLength is the length limit of keys.
+	MaxKeyLength = 255
+)
+
+const (
+	defaultTTL        = 24 * time.Hour
+	defaultMaxEntries = 10000
+	defaultMaxBytes   = 64 << 20
+	// statusClientClosedRequest is the non-standard status of requests abandoned by the client.
+	statusClientClosedRequest = 499
+)
+
+type Config struct {
+	// TTL is how long responses are replayed, 24h when 0.
+	TTL time.Duration `json:"ttl" yaml:"ttl"`
+	// MaxEntries bounds the stored responses, 10000 when 0; the oldest are evicted first.
+	MaxEntries int `json:"max_entries" yaml:"max_entries"`
+	// MaxBytes bounds the stored response bodies in bytes, 64 MiB when 0; the oldest are evicted first, and a
+	// response beyond it is not stored.
+	MaxBytes int64 `json:"max_bytes" yaml:"max_bytes"`
+	Enabled  bool  `json:"enabled"   yaml:"enabled"`
+}
+
+// Store keeps the responses by key. It is safe for concurrent use; a nil Store honors no key.
+type Store struct {
+	clock   clock.Clock
+	entries map[string]*list.Element
+	// order holds the entries, oldest first.
+	order      *list.List
+	ttl        time.Duration
+	maxEntries int
+	maxBytes   int64
+	// bytes is the size of the stored response bodies.
+	bytes int64
+	mu    sync.Mutex
+}
+
+// entry is the request of a key and, once answered, its response.
+type entry struct {
+	expires time.Time
+	// response is nil while the request is in progress.
+	response    *response
+	key         string
+	fingerprint [sha256.Size]byte
+}
+
+type response struct {
+	header http.Header
+	body   []byte
+	status int
+}
+
+// New returns the store of the configuration, nil when idempotency keys are disabled.
+func New(clk clock.Clock, cfg Config) (*Store, error) {
+	if !cfg.Enabled {
+		return nil, nil //nolint:nilnil // A nil Store honors no key.
+	}
+	if cfg.TTL < 0 {
+		return nil, ErrInvalidTTL
+	}
+	if cfg.MaxEntries < 0 {
+		return nil, ErrInvalidSize
+	}
+	if cfg.TTL == 0 {
+		cfg.TTL = defaultTTL
+	}
+	if cfg.MaxBytes < 0 {
+		return nil, ErrInvalidBytes
+	}
+	if cfg.MaxEntries == 0 {
+		cfg.MaxEntries = defaultMaxEntries
+	}
+	if cfg.MaxBytes == 0 {
+		cfg.MaxBytes = defaultMaxBytes
+	}
+
+	return &Store{
+		clock:      clk,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+		ttl:        cfg.TTL,
+		maxEntries: cfg.MaxEntries,
+		maxBytes:   cfg.MaxBytes,
+	}, nil
+}
+
+// Middleware honors the Idempotency-Key of POST requests. The first request with a key is served and its
+// response stored when it is final, see storable, so other requests can be retried; the retries with the same key, method,
+// path and body get the stored response again, with Idempotent-Replayed: true. Keys are scoped by the
+// authenticated identity of the request, never by the X-Tenant-Id it claims, so the middleware must run once
+// requests are authenticated. Requests reusing a key with another payload, or while its first request is in
+// progress, are answered with 409 Conflict.
+func (s *Store) Middleware(next http.Handler) http.Handler {
+	if s == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(Header)
+		if r.Method != http.MethodPost || key == "" {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+		if len(key) > MaxKeyLength {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("%w: the limit is %d characters", ErrKeyTooLong, MaxKeyLength))
+
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			middleware.WriteBodyError(w, err)
+
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		key = scope(r) + "\x00" + key
+		stored, err := s.begin(key, fingerprint(r, body))
+		switch {
+		case err != nil:
+			writeError(w, http.StatusConflict, err)
+		case stored != nil:
+			for name, values := range stored.header {
+				w.Header()[name] = values
+			}
+			w.Header().Set(ReplayedHeader, "true")
+			w.WriteHeader(stored.status)
+			_, _ = w.Write(stored.body)
+		default:
+			rec := &recorder{ResponseWriter: w, limit: s.maxBytes}
+			next.ServeHTTP(rec, r)
+			resp := rec.response()
+			if rec.overflow || r.Context().Err() != nil {
+				resp = nil
+			}
+			s.finish(key, resp)
+		}
+	})
+}
+
+// begin returns the stored response of the key, or records the request as in progress and returns nil when
+// the key is new or expired. It fails when the key is in progress or was used with another fingerprint.
+func (s *Store) begin(key string, fingerprint [sha256.Size]byte) (*response, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock.Now()
+	s.evict(now)
+	if elem, ok := s.entries[key]; ok {
+		e := elem.Value.(*entry) //nolint:forcetypeassert // The list only holds entries.
+		switch {
+		case e.fingerprint != fingerprint:
+			return nil, ErrKeyReused
+		case e.response == nil:
+			return nil, ErrInProgress
+		default:
+			return e.response, nil
+		}
+	}
+
+	s.entries[key] = s.order.PushBack(&entry{expires: now.Add(s.ttl), key: key, fingerprint: fingerprint})
+
+	return nil, nil
+}
+
+// finish stores the response of the key's request, or forgets the key when the response is nil, e.g. for an
+// abandoned request or a body beyond the byte budget, or not storable.
+func (s *Store) finish(key string, resp *response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elem, ok := s.entries[key]
+	if !ok {
+		return
+	}
+	if resp == nil || !storable(resp.status) {
+		s.remove(elem)
+
+		return
+	}
+	elem.Value.(*entry).response = resp //nolint:forcetypeassert // The list only holds entries.
+	s.bytes += int64(len(resp.body))
+	for s.bytes > s.maxBytes {
+		s.remove(s.order.Front())
+	}
+}
+
+// scope returns the authenticated tenant and signing client of the request, empty for anonymous requests.
+func scope(r *http.Request) string {
+	identity, ok := middleware.IdentityFrom(r.Context())
+	if !ok {
+		return ""
+	}
+
+	return identity.Tenant + "\x00" + identity.ClientID
+}
+
+// storable reports whether a response of the status is the final outcome of its request, to be replayed: a
+// success or a client error, except the timeouts, rate limits and cancellations a retry may get past, and the
+// authentication failures a retry with corrected credentials gets past. Server errors, and informational or
+// redirect responses, are served again.
+func storable(status int) bool {
+	switch status {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusRequestTimeout, http.StatusTooManyRequests,
+		statusClientClosedRequest:
+		return false
+	}
+
+	return status >= http.StatusOK && status < http.StatusMultipleChoices ||
+		status >= http.StatusBadRequest && status < http.StatusInternalServerError
+}
+
+// evict removes the expired entries, and the oldest ones beyond the size or byte limits. Entries share the
+// ttl, so the oldest expire first.
+func (s *Store) evict(now time.Time) {
+	for elem := s.order.Front(); elem != nil; elem = s.order.Front() {
+		e := elem.Value.(*entry) //nolint:forcetypeassert // The list only holds entries.
+		if s.order.Len() < s.maxEntries && s.bytes <= s.maxBytes && now.Before(e.expires) {
+			return
+		}
+		s.remove(elem)
+	}
+}
+
+// remove forgets the entry of the element.
+func (s *Store) remove(elem *list.Element) {
+	e := elem.Value.(*entry) //nolint:forcetypeassert // The list only holds entries.
+	s.order.Remove(elem)
+	delete(s.entries, e.key)
+	if e.response != nil {
+		s.bytes -= int64(len(e.response.body))
+	}
+}
+
+// Len returns the number of keys stored.
+func (s *Store) Len() int {
+	if s == nil {
+		return 0
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.order.Len()
+}
+
+// fingerprint identifies the payload of a request: its method, path and body.
+func fingerprint(r *http.Request, body []byte) [sha256.Size]byte {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s\n", r.Method, r.URL.RequestURI())
+	h.Write(body)
+
+	var sum [sha256.Size]byte
+	h.Sum(sum[:0])
+
+	return sum
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set(responder.ErrorCodeHeader, string(apierror.FromStatus(status)))
+	responder.WriteError(w, status, err)
+}
+
+// recorder passes the response on while keeping a copy of it, up to limit bytes of body.
+type recorder struct {
+	http.ResponseWriter
+	header http.Header
+	body   bytes.Buffer
+	limit  int64
+	status int
+	// overflow is set once the body outgrows the limit, and the copy is dropped.
+	overflow bool
+}
+
+func (rec *recorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+		rec.header = rec.Header().Clone()
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *recorder) Write(p []byte) (int, error) {
+	if rec.status == 0 {
+		rec.WriteHeader(http.StatusOK)
+	}
+	switch {
+	case rec.overflow:
+	case int64(rec.body.Len()+len(p)) > rec.limit:
+		rec.overflow = true
+		rec.body = bytes.Buffer{}
+	default:
+		rec.body.Write(p)
+	}
+
+	return rec.ResponseWriter.Write(p)
+}
+
+// Flush flushes the response, starting it if needed, e.g. for streamed reconstructions.
+func (rec *recorder) Flush() {
+	if rec.status == 0 {
+		rec.WriteHeader(http.StatusOK)
+	}
+	_ = http.NewResponseController(rec.ResponseWriter).Flush()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to extend its write deadline.
+func (rec *recorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// response returns the recorded response.
+func (rec *recorder) response() *response {
+	if rec.status == 0 {
+		return &response{header: rec.Header().Clone(), status: http.StatusOK}
+	}
+
+	return &response{header: rec.header, body: rec.body.Bytes(), status: rec.status}
+}
@@ -0,0 +1,328 @@
+package idempotency_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/clock"
+	"github.com/dsha256/dispatcher/internal/idempotency"
+	"github.com/dsha256/dispatcher/internal/middleware"
+	"github.com/dsha256/dispatcher/internal/responder"
+)
+
+// counting answers every request with its number, starting at 1, and the status.
+func counting(status int) (http.Handler, *atomic.Int64) {
+	var calls atomic.Int64
+
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		n := calls.Add(1)
+		w.Header().Set("X-Call", fmt.Sprint(n))
+		w.WriteHeader(status)
+		fmt.Fprintf(w, "call %d", n)
+	}), &calls
+}
+
+func post(handler http.Handler, tenant, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/dispatcher/itinerary", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(idempotency.Header, key)
+	}
+	if tenant != "" {
+		req = req.WithContext(middleware.WithIdentity(req.Context(), middleware.Identity{Tenant: tenant}))
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestMiddleware(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		second     func(http.Handler) *httptest.ResponseRecorder
+		wantStatus int
+		wantBody   string
+		wantCalls  int64
+		replayed   bool
+	}{
+		{
+			name:       "Retry is replayed",
+			second:     func(h http.Handler) *httptest.ResponseRecorder { return post(h, "", "key-1", "a") },
+			wantStatus: http.StatusOK,
+			wantBody:   "call 1",
+			wantCalls:  1,
+			replayed:   true,
+		},
+		{
+			name:       "Another payload conflicts",
+			second:     func(h http.Handler) *httptest.ResponseRecorder { return post(h, "", "key-1", "b") },
+			wantStatus: http.StatusConflict,
+			wantCalls:  1,
+		},
+		{
+			name:       "Another key is served",
+			second:     func(h http.Handler) *httptest.ResponseRecorder { return post(h, "", "key-2", "a") },
+			wantStatus: http.StatusOK,
+			wantBody:   "call 2",
+			wantCalls:  2,
+		},
+		{
+			name:       "Another tenant is served",
+			second:     func(h http.Handler) *httptest.ResponseRecorder { return post(h, "acme", "key-1", "a") },
+			wantStatus: http.StatusOK,
+			wantBody:   "call 2",
+			wantCalls:  2,
+		},
+		{
+			name: "A claimed tenant shares the anonymous scope",
+			second: func(h http.Handler) *httptest.ResponseRecorder {
+				req := httptest.NewRequest(http.MethodPost, "/api/v1/dispatcher/itinerary", strings.NewReader("a"))
+				req.Header.Set(idempotency.Header, "key-1")
+				req.Header.Set("X-Tenant-Id", "acme")
+				rec := httptest.NewRecorder()
+				h.ServeHTTP(rec, req)
+
+				return rec
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   "call 1",
+			wantCalls:  1,
+			replayed:   true,
+		},
+		{
+			name:       "Without a key",
+			second:     func(h http.Handler) *httptest.ResponseRecorder { return post(h, "", "", "a") },
+			wantStatus: http.StatusOK,
+			wantBody:   "call 2",
+			wantCalls:  2,
+		},
+		{
+			name:       "Too long key",
+			second:     func(h http.Handler) *httptest.ResponseRecorder { return post(h, "", strings.Repeat("k", 256), "a") },
+			wantStatus: http.StatusBadRequest,
+			wantCalls:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			store, err := idempotency.New(clock.NewFake(time.Now()), idempotency.Config{Enabled: true})
+			if err != nil {
+				t.Fatalf("Failed to create store: %v", err)
+			}
+			next, calls := counting(http.StatusOK)
+			handler := store.Middleware(next)
+
+			if rec := post(handler, "", "key-1", "a"); rec.Code != http.StatusOK || rec.Header().Get(idempotency.ReplayedHeader) != "" {
+				t.Fatalf("Expected the first request to be served, got %d", rec.Code)
+			}
+			rec := tt.second(handler)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+				t.Errorf("Expected body %q, got %q", tt.wantBody, rec.Body.String())
+			}
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Errorf("Expected %d calls, got %d", tt.wantCalls, got)
+			}
+			if replayed := rec.Header().Get(idempotency.ReplayedHeader) == "true"; replayed != tt.replayed {
+				t.Errorf("Expected replayed %t, got %t", tt.replayed, replayed)
+			}
+			if tt.replayed && rec.Header().Get("X-Call") != "1" {
+				t.Errorf("Expected the stored headers to be replayed, got X-Call %q", rec.Header().Get("X-Call"))
+			}
+			if tt.wantStatus == http.StatusConflict && rec.Header().Get(responder.ErrorCodeHeader) != "CONFLICT" {
+				t.Errorf("Expected error code CONFLICT, got %q", rec.Header().Get(responder.ErrorCodeHeader))
+			}
+		})
+	}
+}
+
+func TestMiddlewareStoresFinalResponses(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		status int
+		stored bool
+	}{
+		{name: "Success", status: http.StatusOK, stored: true},
+		{name: "Client error", status: http.StatusUnprocessableEntity, stored: true},
+		{name: "Server error", status: http.StatusServiceUnavailable},
+		{name: "Unauthorized", status: http.StatusUnauthorized},
+		{name: "Forbidden", status: http.StatusForbidden},
+		{name: "Request timeout", status: http.StatusRequestTimeout},
+		{name: "Rate limited", status: http.StatusTooManyRequests},
+		{name: "Client closed request", status: 499},
+		{name: "Redirect", status: http.StatusTemporaryRedirect},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			store, err := idempotency.New(clock.NewFake(time.Now()), idempotency.Config{Enabled: true})
+			if err != nil {
+				t.Fatalf("Failed to create store: %v", err)
+			}
+			next, calls := counting(tt.status)
+			handler := store.Middleware(next)
+
+			post(handler, "", "key-1", "a")
+			rec := post(handler, "", "key-1", "a")
+			if replayed := rec.Header().Get(idempotency.ReplayedHeader) == "true"; replayed != tt.stored {
+				t.Errorf("Expected replayed %t, got %t", tt.stored, replayed)
+			}
+			wantCalls := int64(2)
+			if tt.stored {
+				wantCalls = 1
+			}
+			if got := calls.Load(); got != wantCalls {
+				t.Errorf("Expected %d calls, got %d", wantCalls, got)
+			}
+		})
+	}
+}
+
+func TestMiddlewareByteBudget(t *testing.T) {
+	t.Parallel()
+
+	store, err := idempotency.New(clock.NewFake(time.Now()), idempotency.Config{MaxBytes: 12, Enabled: true})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	handler := store.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		_, _ = w.Write(body)
+	}))
+
+	if rec := post(handler, "", "large", "a body beyond the budget"); rec.Body.String() != "a body beyond the budget" {
+		t.Errorf("Expected the large response to be served whole, got %q", rec.Body.String())
+	}
+	if got := store.Len(); got != 0 {
+		t.Errorf("Expected the large response not to be stored, got %d keys", got)
+	}
+
+	post(handler, "", "key-1", "0123456")
+	post(handler, "", "key-2", "0123456")
+	if got := store.Len(); got != 1 {
+		t.Errorf("Expected the oldest response to be evicted beyond the budget, got %d keys", got)
+	}
+	if rec := post(handler, "", "key-2", "0123456"); rec.Header().Get(idempotency.ReplayedHeader) != "true" {
+		t.Error("Expected the newest response to be replayed")
+	}
+}
+
+func TestMiddlewareAbandonedRequestsAreNotStored(t *testing.T) {
+	t.Parallel()
+
+	store, err := idempotency.New(clock.NewFake(time.Now()), idempotency.Config{Enabled: true})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	next, calls := counting(http.StatusOK)
+	handler := store.Middleware(next)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequestWithContext(ctx, http.MethodPost, "/api/v1/dispatcher/itinerary", strings.NewReader("a"))
+	req.Header.Set(idempotency.Header, "key-1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if rec := post(handler, "", "key-1", "a"); rec.Header().Get(idempotency.ReplayedHeader) != "" || calls.Load() != 2 {
+		t.Errorf("Expected the retry of an abandoned request to be served again, got %d calls", calls.Load())
+	}
+}
+
+func TestMiddlewareInProgress(t *testing.T) {
+	t.Parallel()
+
+	store, err := idempotency.New(clock.NewFake(time.Now()), idempotency.Config{Enabled: true})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	started, release := make(chan struct{}), make(chan struct{})
+	handler := store.Middleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		post(handler, "", "key-1", "a")
+	}()
+	<-started
+
+	if rec := post(handler, "", "key-1", "a"); rec.Code != http.StatusConflict {
+		t.Errorf("Expected a concurrent retry to conflict, got %d", rec.Code)
+	}
+	close(release)
+	<-done
+}
+
+func TestMiddlewareExpiry(t *testing.T) {
+	t.Parallel()
+
+	clk := clock.NewFake(time.Now())
+	store, err := idempotency.New(clk, idempotency.Config{TTL: time.Hour, MaxEntries: 2, Enabled: true})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	next, calls := counting(http.StatusOK)
+	handler := store.Middleware(next)
+
+	post(handler, "", "key-1", "a")
+	clk.Advance(2 * time.Hour)
+	if rec := post(handler, "", "key-1", "b"); rec.Code != http.StatusOK || calls.Load() != 2 {
+		t.Errorf("Expected an expired key to be reusable, got %d", rec.Code)
+	}
+
+	post(handler, "", "key-2", "a")
+	post(handler, "", "key-3", "a")
+	if got := store.Len(); got != 2 {
+		t.Errorf("Expected 2 keys stored at most, got %d", got)
+	}
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		wantErr error
+		name    string
+		cfg     idempotency.Config
+	}{
+		{name: "Negative ttl", cfg: idempotency.Config{TTL: -time.Second, Enabled: true}, wantErr: idempotency.ErrInvalidTTL},
+		{name: "Negative size", cfg: idempotency.Config{MaxEntries: -1, Enabled: true}, wantErr: idempotency.ErrInvalidSize},
+		{name: "Negative bytes", cfg: idempotency.Config{MaxBytes: -1, Enabled: true}, wantErr: idempotency.ErrInvalidBytes},
+		{name: "Disabled", cfg: idempotency.Config{TTL: -time.Second}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			store, err := idempotency.New(clock.Real{}, tt.cfg)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if !tt.cfg.Enabled && store != nil {
+				t.Error("Expected no store when disabled")
+			}
+		})
+	}
+}
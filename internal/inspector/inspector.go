@@ -0,0 +1,247 @@
+// Package inspector publishes a live feed of incoming requests for debugging during incidents.
+// Events carry sizes, tenants, durations and outcomes but no request content, except for payloads
+// captured on purpose for a single request ID.
+package inspector
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RequestIDHeader carries the request ID. A client-supplied ID is kept, otherwise one is generated;
+// either way it is echoed in the response.
+const RequestIDHeader = "X-Request-Id"
+
+// MaxCaptureBytes bounds a captured payload; longer payloads are truncated.
+const MaxCaptureBytes = 64 << 10
+
+// Outcomes of a request.
+const (
+	OutcomeSuccess     = "success"
+	OutcomeClientError = "client_error"
+	OutcomeServerError = "server_error"
+)
+
+// Event describes a completed request.
+type Event struct {
+	At        time.Time `json:"at"`
+	RequestID string    `json:"request_id"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Tenant    string    `json:"tenant"`
+	Outcome   string    `json:"outcome"`
+	// Payload is the request body, only set when capture was armed for the request ID.
+	Payload    json.RawMessage `json:"payload,omitempty"`
+	DurationMS float64         `json:"duration_ms"`
+	BodyBytes  int64           `json:"body_bytes"`
+	Status     int             `json:"status"`
+	// Truncated is set when the captured payload was cut at MaxCaptureBytes.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// Inspector fans events out to subscribers. It is safe for concurrent use.
+type Inspector struct {
+	subscribers map[chan Event]struct{}
+	captures    map[string]struct{}
+	dropped     atomic.Uint64
+	buffer      int
+	mu          sync.Mutex
+}
+
+// New returns an inspector buffering up to buffer events per subscriber.
+func New(buffer int) *Inspector {
+	return &Inspector{
+		subscribers: make(map[chan Event]struct{}),
+		captures:    make(map[string]struct{}),
+		buffer:      max(buffer, 1),
+	}
+}
+
+// Subscribe returns a feed of events and the function to stop it. Events are dropped rather than
+// delaying requests when the subscriber falls behind.
+func (i *Inspector) Subscribe() (<-chan Event, func()) {
+	feed := make(chan Event, i.buffer)
+
+	i.mu.Lock()
+	i.subscribers[feed] = struct{}{}
+	i.mu.Unlock()
+
+	var once sync.Once
+
+	return feed, func() {
+		once.Do(func() {
+			i.mu.Lock()
+			delete(i.subscribers, feed)
+			i.mu.Unlock()
+		})
+	}
+}
+
+// Publish sends the event to every subscriber with room in its buffer.
+func (i *Inspector) Publish(event Event) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for feed := range i.subscribers {
+		select {
+		case feed <- event:
+		default:
+			i.dropped.Add(1)
+		}
+	}
+}
+
+// Dropped returns the number of events dropped for slow subscribers.
+func (i *Inspector) Dropped() uint64 {
+	if i == nil {
+		return 0
+	}
+
+	return i.dropped.Load()
+}
+
+// Capture arms payload capture for the next request with the request ID.
+func (i *Inspector) Capture(requestID string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.captures[requestID] = struct{}{}
+}
+
+// watch reports whether anyone subscribes, and whether the request's payload is to be captured,
+// disarming the capture.
+func (i *Inspector) watch(requestID string) (bool, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	_, capture := i.captures[requestID]
+	delete(i.captures, requestID)
+
+	return len(i.subscribers) > 0, capture
+}
+
+// Middleware assigns request IDs and publishes an event for every request while anyone subscribes.
+// tenantOf names the tenant of a request. A nil inspector passes requests through untouched.
+func (i *Inspector) Middleware(tenantOf func(*http.Request) string, next http.Handler) http.Handler {
+	if i == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if requestID == "" {
+			requestID = newRequestID()
+			r.Header.Set(RequestIDHeader, requestID)
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+
+		subscribed, capture := i.watch(requestID)
+		if !subscribed {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		body := &countingReader{ReadCloser: r.Body}
+		if capture {
+			body.capture = &bytes.Buffer{}
+		}
+		r.Body = body
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		start := time.Now()
+		next.ServeHTTP(rec, r)
+
+		event := Event{
+			At:         start.UTC(),
+			RequestID:  requestID,
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			Tenant:     tenantOf(r),
+			Outcome:    outcome(rec.status),
+			DurationMS: float64(time.Since(start).Microseconds()) / 1000,
+			BodyBytes:  body.n,
+			Status:     rec.status,
+		}
+		if capture {
+			event.Payload, event.Truncated = capturedPayload(body.capture.Bytes(), body.n)
+		}
+		i.Publish(event)
+	})
+}
+
+func outcome(status int) string {
+	switch {
+	case status >= http.StatusInternalServerError:
+		return OutcomeServerError
+	case status >= http.StatusBadRequest:
+		return OutcomeClientError
+	default:
+		return OutcomeSuccess
+	}
+}
+
+// capturedPayload returns the payload as JSON: as is when it is complete JSON, as a JSON string otherwise.
+func capturedPayload(captured []byte, size int64) (json.RawMessage, bool) {
+	truncated := size > int64(len(captured))
+	if !truncated && json.Valid(captured) {
+		return json.RawMessage(captured), false
+	}
+
+	quoted, _ := json.Marshal(string(captured))
+
+	return quoted, truncated
+}
+
+func newRequestID() string {
+	var id [8]byte
+	_, _ = rand.Read(id[:])
+
+	return hex.EncodeToString(id[:])
+}
+
+// countingReader counts the bytes read from the body and keeps the first MaxCaptureBytes when capturing.
+type countingReader struct {
+	io.ReadCloser
+	capture *bytes.Buffer
+	n       int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n += int64(n)
+	if c.capture != nil {
+		if room := MaxCaptureBytes - c.capture.Len(); room > 0 {
+			c.capture.Write(p[:min(n, room)])
+		}
+	}
+
+	return n, err
+}
+
+// statusRecorder remembers the response status.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	if !s.wroteHeader {
+		s.status = status
+		s.wroteHeader = true
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to flush streamed responses.
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
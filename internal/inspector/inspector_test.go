@@ -0,0 +1,127 @@
+package inspector_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dsha256/dispatcher/internal/inspector"
+)
+
+func TestMiddleware(t *testing.T) {
+	t.Parallel()
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			t.Errorf("ReadAll() error = %v", err)
+		}
+		if strings.HasSuffix(r.URL.Path, "/bad") {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	})
+	tenantOf := func(r *http.Request) string { return r.Header.Get("X-Tenant-Id") }
+
+	tests := []struct {
+		name      string
+		path      string
+		requestID string
+		body      string
+		capture   bool
+		want      inspector.Event
+	}{
+		{
+			name: "Success without payload",
+			path: "/ok",
+			body: `{"tickets": [["JFK", "LAX"]]}`,
+			want: inspector.Event{Method: http.MethodPost, Path: "/ok", Tenant: "acme", Outcome: inspector.OutcomeSuccess, Status: http.StatusOK, BodyBytes: 29},
+		},
+		{
+			name:      "Client error with captured payload",
+			path:      "/bad",
+			requestID: "req-1",
+			body:      `{"tickets": []}`,
+			capture:   true,
+			want: inspector.Event{
+				RequestID: "req-1", Method: http.MethodPost, Path: "/bad", Tenant: "acme", Outcome: inspector.OutcomeClientError,
+				Status: http.StatusBadRequest, BodyBytes: 15, Payload: json.RawMessage(`{"tickets": []}`),
+			},
+		},
+		{
+			name:      "Capture of another request",
+			path:      "/ok",
+			requestID: "req-2",
+			body:      `{}`,
+			want:      inspector.Event{RequestID: "req-2", Method: http.MethodPost, Path: "/ok", Tenant: "acme", Outcome: inspector.OutcomeSuccess, Status: http.StatusOK, BodyBytes: 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			i := inspector.New(1)
+			feed, stop := i.Subscribe()
+			defer stop()
+			if tt.capture {
+				i.Capture(tt.requestID)
+			}
+			i.Capture("someone-else")
+
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("X-Tenant-Id", "acme")
+			if tt.requestID != "" {
+				req.Header.Set(inspector.RequestIDHeader, tt.requestID)
+			}
+			rec := httptest.NewRecorder()
+			i.Middleware(tenantOf, next).ServeHTTP(rec, req)
+
+			got := <-feed
+			if rec.Header().Get(inspector.RequestIDHeader) != got.RequestID || got.RequestID == "" {
+				t.Errorf("response request ID = %q; want the event's %q", rec.Header().Get(inspector.RequestIDHeader), got.RequestID)
+			}
+			if tt.want.RequestID == "" {
+				tt.want.RequestID = got.RequestID
+			}
+			got.At, got.DurationMS = tt.want.At, tt.want.DurationMS
+			if gotJSON, wantJSON := mustMarshal(t, got), mustMarshal(t, tt.want); gotJSON != wantJSON {
+				t.Errorf("event = %s; want %s", gotJSON, wantJSON)
+			}
+		})
+	}
+}
+
+func TestPublishDropsForSlowSubscribers(t *testing.T) {
+	t.Parallel()
+
+	i := inspector.New(1)
+	feed, stop := i.Subscribe()
+	i.Publish(inspector.Event{RequestID: "1"})
+	i.Publish(inspector.Event{RequestID: "2"})
+
+	if got := (<-feed).RequestID; got != "1" {
+		t.Errorf("first event = %q; want 1", got)
+	}
+	if got := i.Dropped(); got != 1 {
+		t.Errorf("Dropped() = %d; want 1", got)
+	}
+
+	stop()
+	i.Publish(inspector.Event{RequestID: "3"})
+	if got := i.Dropped(); got != 1 {
+		t.Errorf("Dropped() after stop = %d; want 1", got)
+	}
+}
+
+func mustMarshal(t *testing.T, v any) string {
+	t.Helper()
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	return string(data)
+}
@@ -0,0 +1,479 @@
+// Package jobs runs requests too big to answer while the client waits, e.g. reconstructions of large
+// uploaded ticket files, in the background. The client gets a job ID right away and polls the job, whose
+// result is the response the request would have had.
+//
+// Jobs are queued in a Backend and run by the workers of every replica sharing it, so a job submitted to one
+// replica can be run by another and polled on a third, and queued jobs survive restarts. The default backend
+// keeps them in memory, per replica.
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/clock"
+	"github.com/dsha256/dispatcher/internal/middleware"
+)
+
+var (
+	ErrTooManyJobs = errors.New("too many jobs queued")
+	ErrInvalidSize = errors.New("jobs max_running, max_queued and max_jobs must not be negative")
+	ErrNotStarted  = errors.New("jobs are not started")
+)
+
+// Status is the status of a job.
+type Status string
+
+const (
+	// StatusQueued is a job waiting for a worker.
+	StatusQueued Status = "queued"
+	// StatusRunning is a job still computing its response.
+	StatusRunning Status = "running"
+	// StatusDone is a job whose response is ready.
+	StatusDone Status = "done"
+)
+
+const (
+	// BackendMemory keeps the jobs of a replica in its memory.
+	BackendMemory = "memory"
+	// BackendRedis shares the jobs between the replicas through Redis.
+	BackendRedis = "redis"
+
+	defaultTTL        = time.Hour
+	defaultTimeout    = 5 * time.Minute
+	defaultMaxRunning = 4
+	defaultMaxQueued  = 100
+	defaultMaxJobs    = 1000
+
+	// queueKey is the key of the queue of the requests of jobs, jobKeyPrefix prefixes the keys of the jobs.
+	queueKey     = "dispatcher:jobs:queue"
+	jobKeyPrefix = "dispatcher:jobs:job:"
+	// popTimeout is how long a worker waits for a job before checking whether it is stopped.
+	popTimeout = time.Second
+	// retryDelay is how long a worker waits after the backend failed.
+	retryDelay = time.Second
+)
+
+type Config struct {
+	// TTL is how long finished jobs are kept for their clients to fetch, 1h when 0.
+	TTL time.Duration `json:"ttl" yaml:"ttl"`
+	// Timeout bounds the run of a job, 5m when 0.
+	Timeout time.Duration `json:"timeout" yaml:"timeout"`
+	// Backend is where jobs are queued and kept: "memory" (the default) per replica, or "redis" shared by the
+	// replicas through the redis section.
+	Backend string `json:"backend" yaml:"backend"`
+	// MaxRunning is the number of jobs each replica runs at once, 4 when 0.
+	MaxRunning int `json:"max_running" yaml:"max_running"`
+	// MaxQueued caps the jobs waiting for a worker, 100 when 0; more are refused with ErrTooManyJobs.
+	MaxQueued int `json:"max_queued" yaml:"max_queued"`
+	// MaxJobs caps the jobs the memory backend keeps, 1000 when 0; the oldest are dropped first.
+	MaxJobs int  `json:"max_jobs" yaml:"max_jobs"`
+	Enabled bool `json:"enabled"  yaml:"enabled"`
+}
+
+// Backend queues the requests of jobs and keeps the jobs, e.g. a Redis client.
+type Backend interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Push appends the value to the queue under key, Pop removes the value at its head, waiting up to timeout
+	// for one, and Len returns its length.
+	Push(ctx context.Context, key string, value []byte) error
+	Pop(ctx context.Context, key string, timeout time.Duration) ([]byte, bool, error)
+	Len(ctx context.Context, key string) (int, error)
+}
+
+// Job is a request run in the background.
+type Job struct {
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	// Response is the response of the request once the job is done.
+	Response *Response `json:"-"`
+	ID       string    `json:"id"`
+	Status   Status    `json:"status"`
+}
+
+// Stats are the jobs of a store: those queued in its backend and those its replica runs.
+type Stats struct {
+	Backend    string `json:"backend"`
+	Queued     int    `json:"queued"`
+	Running    int    `json:"running"`
+	MaxQueued  int    `json:"max_queued"`
+	MaxRunning int    `json:"max_running"`
+	Enabled    bool   `json:"enabled"`
+}
+
+// Response is the response of a finished job.
+type Response struct {
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+	Status int         `json:"status"`
+}
+
+// credentialHeaders are the headers of requests not kept in their job.
+func credentialHeaders() []string {
+	return []string{"Authorization", "Cookie", middleware.SignatureHeader}
+}
+
+// record is a job as kept in the backend.
+type record struct {
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Response   *Response  `json:"response,omitempty"`
+	Status     Status     `json:"status"`
+	Tenant     string     `json:"tenant"`
+}
+
+// task is the request of a job as queued in the backend, carrying the authenticated identity of the
+// original request, if any, since it may run on another replica. Its credentials are not kept (see
+// credentialHeaders): the request is not authenticated again.
+type task struct {
+	Header    http.Header          `json:"header"`
+	Identity  *middleware.Identity `json:"identity,omitempty"`
+	CreatedAt time.Time            `json:"created_at"`
+	ID        string               `json:"id"`
+	Tenant    string               `json:"tenant"`
+	Method    string               `json:"method"`
+	URL       string               `json:"url"`
+	Body      []byte               `json:"body"`
+}
+
+// Option configures a Store.
+type Option func(*Store)
+
+// WithBackend queues and keeps the jobs in the backend instead of in memory.
+func WithBackend(backend Backend) Option {
+	return func(s *Store) {
+		s.backend = backend
+	}
+}
+
+// Store queues jobs in its backend and runs them with its workers once started. It is safe for concurrent
+// use.
+type Store struct {
+	clock   clock.Clock
+	backend Backend
+	handler http.Handler
+	// stop stops the workers from taking jobs, abort cancels the jobs they run.
+	stop    context.CancelFunc
+	abort   context.CancelFunc
+	workers sync.WaitGroup
+	cfg     Config
+	running int
+	mu      sync.Mutex
+}
+
+// New returns the store of the configuration, nil when jobs are disabled.
+func New(clk clock.Clock, cfg Config, opts ...Option) (*Store, error) {
+	if !cfg.Enabled {
+		return nil, nil //nolint:nilnil // Jobs are disabled.
+	}
+	if cfg.MaxRunning < 0 || cfg.MaxQueued < 0 || cfg.MaxJobs < 0 {
+		return nil, fmt.Errorf("%w: %d, %d, %d", ErrInvalidSize, cfg.MaxRunning, cfg.MaxQueued, cfg.MaxJobs)
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = defaultTTL
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.MaxRunning == 0 {
+		cfg.MaxRunning = defaultMaxRunning
+	}
+	if cfg.MaxQueued == 0 {
+		cfg.MaxQueued = defaultMaxQueued
+	}
+	if cfg.MaxJobs == 0 {
+		cfg.MaxJobs = defaultMaxJobs
+	}
+
+	s := &Store{clock: clk, cfg: cfg}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.backend == nil {
+		s.backend = NewMemory(clk, cfg.MaxJobs, cfg.MaxQueued)
+	}
+
+	return s, nil
+}
+
+// Start starts MaxRunning workers running the jobs queued in the backend through the handler. The context
+// of their requests carries the authenticated identity of the original request and is canceled after the
+// configured timeout. Starting a started store does nothing.
+func (s *Store) Start(handler http.Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.handler != nil {
+		return
+	}
+	s.handler = handler
+
+	var stopCtx, abortCtx context.Context
+	stopCtx, s.stop = context.WithCancel(context.Background())
+	abortCtx, s.abort = context.WithCancel(context.Background())
+	for range s.cfg.MaxRunning {
+		s.workers.Add(1)
+		go func() {
+			defer s.workers.Done()
+			s.work(stopCtx, abortCtx)
+		}()
+	}
+}
+
+// Close stops the workers from taking jobs and waits for the jobs they run until ctx is done. The jobs still
+// running then are canceled and queued again, to be run by another replica or after a restart. Closing a nil
+// Store does nothing.
+func (s *Store) Close(ctx context.Context) error {
+	if s == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	stop, abort := s.stop, s.abort
+	s.mu.Unlock()
+	if stop == nil {
+		return nil
+	}
+
+	stop()
+	done := make(chan struct{})
+	go func() {
+		s.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		abort()
+
+		return nil
+	case <-ctx.Done():
+		abort()
+		<-done
+
+		return ctx.Err()
+	}
+}
+
+// Submit queues the request as a job of the tenant and returns the job. The request's body is read in full.
+func (s *Store) Submit(tenant string, r *http.Request) (Job, error) {
+	ctx := r.Context()
+	s.mu.Lock()
+	started := s.handler != nil
+	s.mu.Unlock()
+	if !started {
+		return Job{}, ErrNotStarted
+	}
+
+	queued, err := s.backend.Len(ctx, queueKey)
+	if err != nil {
+		return Job{}, err
+	}
+	if queued >= s.cfg.MaxQueued {
+		return Job{}, fmt.Errorf("%w: %d queued", ErrTooManyJobs, queued)
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return Job{}, err
+	}
+	header := r.Header.Clone()
+	for _, name := range credentialHeaders() {
+		header.Del(name)
+	}
+	t := task{
+		Header:    header,
+		CreatedAt: s.clock.Now(),
+		ID:        rand.Text(),
+		Tenant:    tenant,
+		Method:    r.Method,
+		URL:       r.URL.String(),
+		Body:      body,
+	}
+	if identity, ok := middleware.IdentityFrom(ctx); ok {
+		t.Identity = &identity
+	}
+
+	rec := record{CreatedAt: t.CreatedAt, Status: StatusQueued, Tenant: tenant}
+	if err = s.save(ctx, t.ID, &rec); err != nil {
+		return Job{}, err
+	}
+	data, err := json.Marshal(t)
+	if err != nil {
+		return Job{}, err
+	}
+	if err = s.backend.Push(ctx, queueKey, data); err != nil {
+		return Job{}, err
+	}
+
+	return rec.job(t.ID), nil
+}
+
+// Get returns the tenant's job with the ID; jobs of other tenants are not found.
+func (s *Store) Get(ctx context.Context, tenant, id string) (Job, bool, error) {
+	data, ok, err := s.backend.Get(ctx, jobKeyPrefix+id)
+	if err != nil || !ok {
+		return Job{}, false, err
+	}
+	var rec record
+	if err = json.Unmarshal(data, &rec); err != nil {
+		return Job{}, false, err
+	}
+	if rec.Tenant != tenant {
+		return Job{}, false, nil
+	}
+
+	return rec.job(id), true, nil
+}
+
+// Stats returns the jobs of the store. A nil Store reports itself disabled.
+func (s *Store) Stats(ctx context.Context) Stats {
+	if s == nil {
+		return Stats{}
+	}
+
+	queued, _ := s.backend.Len(ctx, queueKey)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	backend := s.cfg.Backend
+	if backend == "" {
+		backend = BackendMemory
+	}
+
+	return Stats{
+		Backend:    backend,
+		Queued:     queued,
+		Running:    s.running,
+		MaxQueued:  s.cfg.MaxQueued,
+		MaxRunning: s.cfg.MaxRunning,
+		Enabled:    true,
+	}
+}
+
+// work runs the jobs of the queue until stopCtx is done; abortCtx cancels the job it runs.
+func (s *Store) work(stopCtx, abortCtx context.Context) {
+	for stopCtx.Err() == nil {
+		data, ok, err := s.backend.Pop(stopCtx, queueKey, popTimeout)
+		if err != nil {
+			select {
+			case <-stopCtx.Done():
+			case <-time.After(retryDelay):
+			}
+
+			continue
+		}
+		if !ok {
+			continue
+		}
+
+		var t task
+		if err = json.Unmarshal(data, &t); err != nil {
+			continue
+		}
+		s.run(abortCtx, &t, data)
+	}
+}
+
+// run runs the task, saving its job while it runs and once done. A task canceled by abortCtx is queued again.
+func (s *Store) run(abortCtx context.Context, t *task, data []byte) {
+	s.mu.Lock()
+	s.running++
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.running--
+		s.mu.Unlock()
+	}()
+
+	ctx, cancel := context.WithTimeout(abortCtx, s.cfg.Timeout)
+	defer cancel()
+	if t.Identity != nil {
+		ctx = middleware.WithIdentity(ctx, *t.Identity)
+	}
+	saveCtx := context.WithoutCancel(ctx)
+
+	rec := record{CreatedAt: t.CreatedAt, Status: StatusRunning, Tenant: t.Tenant}
+	if err := s.save(saveCtx, t.ID, &rec); err != nil {
+		_ = s.backend.Push(saveCtx, queueKey, data)
+
+		return
+	}
+
+	r, err := http.NewRequestWithContext(ctx, t.Method, t.URL, bytes.NewReader(t.Body))
+	if err != nil {
+		return
+	}
+	r.Header = t.Header
+	resp := &recorder{header: make(http.Header), status: http.StatusOK}
+	s.handler.ServeHTTP(resp, r)
+
+	if abortCtx.Err() != nil {
+		rec.Status = StatusQueued
+		_ = s.save(saveCtx, t.ID, &rec)
+		_ = s.backend.Push(saveCtx, queueKey, data)
+
+		return
+	}
+	finished := s.clock.Now()
+	rec.Status, rec.FinishedAt = StatusDone, &finished
+	rec.Response = &Response{Header: resp.header, Body: resp.body.Bytes(), Status: resp.status}
+	_ = s.save(saveCtx, t.ID, &rec)
+}
+
+// save keeps the job in the backend: for the TTL once done, and until it could have run and been fetched
+// before.
+func (s *Store) save(ctx context.Context, id string, rec *record) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	ttl := s.cfg.TTL
+	if rec.Status != StatusDone {
+		ttl += s.cfg.Timeout
+	}
+
+	return s.backend.Set(ctx, jobKeyPrefix+id, data, ttl)
+}
+
+func (rec *record) job(id string) Job {
+	return Job{
+		CreatedAt:  rec.CreatedAt,
+		FinishedAt: rec.FinishedAt,
+		Response:   rec.Response,
+		ID:         id,
+		Status:     rec.Status,
+	}
+}
+
+// recorder keeps the response of a job in memory.
+type recorder struct {
+	header      http.Header
+	body        bytes.Buffer
+	status      int
+	wroteHeader bool
+}
+
+func (rec *recorder) Header() http.Header {
+	return rec.header
+}
+
+func (rec *recorder) WriteHeader(status int) {
+	if !rec.wroteHeader {
+		rec.status, rec.wroteHeader = status, true
+	}
+}
+
+func (rec *recorder) Write(p []byte) (int, error) {
+	rec.wroteHeader = true
+
+	return rec.body.Write(p)
+}
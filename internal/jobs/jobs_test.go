@@ -0,0 +1,255 @@
+package jobs_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"github.com/dsha256/dispatcher/internal/clock"
+	"github.com/dsha256/dispatcher/internal/jobs"
+	"github.com/dsha256/dispatcher/internal/middleware"
+	"github.com/dsha256/dispatcher/internal/store/redis"
+)
+
+// wait polls the job until it is done.
+func wait(t *testing.T, store *jobs.Store, tenant, id string) jobs.Job {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		job, ok, err := store.Get(context.Background(), tenant, id)
+		if err != nil || !ok {
+			t.Fatalf("Expected job %s to be found, got %v, %v", id, ok, err)
+		}
+		if job.Status == jobs.StatusDone {
+			return job
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("Job %s did not finish", id)
+
+	return jobs.Job{}
+}
+
+// newStore returns a started store closed at the end of the test.
+func newStore(t *testing.T, clk clock.Clock, cfg jobs.Config, handler http.Handler, opts ...jobs.Option) *jobs.Store {
+	t.Helper()
+
+	cfg.Enabled = true
+	store, err := jobs.New(clk, cfg, opts...)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	store.Start(handler)
+	t.Cleanup(func() {
+		_ = store.Close(context.Background())
+	})
+
+	return store
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	store, err := jobs.New(clock.Real{}, jobs.Config{})
+	if err != nil || store != nil {
+		t.Errorf("Expected no store when disabled, got %v, %v", store, err)
+	}
+	for _, cfg := range []jobs.Config{{MaxRunning: -1}, {MaxQueued: -1}, {MaxJobs: -1}} {
+		cfg.Enabled = true
+		if _, err = jobs.New(clock.Real{}, cfg); !errors.Is(err, jobs.ErrInvalidSize) {
+			t.Errorf("New(%+v) error = %v, want %v", cfg, err, jobs.ErrInvalidSize)
+		}
+	}
+
+	store, err = jobs.New(clock.Real{}, jobs.Config{Enabled: true})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/dispatcher/itinerary", nil)
+	if _, err = store.Submit("", req); !errors.Is(err, jobs.ErrNotStarted) {
+		t.Errorf("Submit() before Start error = %v, want %v", err, jobs.ErrNotStarted)
+	}
+}
+
+func TestSubmit(t *testing.T) {
+	t.Parallel()
+
+	clk := clock.NewFake(time.Date(2026, 10, 17, 9, 30, 0, 0, time.UTC))
+	store := newStore(t, clk, jobs.Config{TTL: time.Minute}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Context().Err() != nil {
+			t.Error("Expected the job to outlive the request's cancellation")
+		}
+		if identity, ok := middleware.IdentityFrom(r.Context()); !ok || identity.Tenant != "acme" {
+			t.Errorf("Expected the job to carry the request's identity, got %+v, %v", identity, ok)
+		}
+		if r.Header.Get("Authorization") != "" || r.Header.Get("X-Trace") != "abc" {
+			t.Errorf("Expected the job's headers without credentials, got %v", r.Header)
+		}
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != "from,to\nJFK,LAX\n" {
+			t.Errorf("Expected the job's body, got %q", body)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+
+	ctx, cancel := context.WithCancel(middleware.WithIdentity(context.Background(), middleware.Identity{Tenant: "acme"}))
+	req := httptest.NewRequestWithContext(ctx, http.MethodPost, "/api/v1/dispatcher/itinerary", strings.NewReader("from,to\nJFK,LAX\n"))
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("X-Trace", "abc")
+	job, err := store.Submit("acme", req)
+	cancel()
+	if err != nil {
+		t.Fatalf("Failed to submit job: %v", err)
+	}
+	if job.ID == "" || job.Status != jobs.StatusQueued {
+		t.Errorf("Expected a queued job with an ID, got %+v", job)
+	}
+
+	done := wait(t, store, "acme", job.ID)
+	if done.FinishedAt == nil || done.Response == nil {
+		t.Fatalf("Expected the finished job to have its response, got %+v", done)
+	}
+	if done.Response.Status != http.StatusCreated || string(done.Response.Body) != `{"ok":true}` ||
+		done.Response.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("Expected the handler's response, got %+v", done.Response)
+	}
+
+	if _, ok, _ := store.Get(context.Background(), "", job.ID); ok {
+		t.Error("Expected the job hidden from other tenants")
+	}
+	clk.Advance(time.Minute)
+	if _, ok, _ := store.Get(context.Background(), "acme", job.ID); ok {
+		t.Error("Expected the job to expire after the TTL")
+	}
+}
+
+func TestSubmitTooMany(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	store := newStore(t, clock.Real{}, jobs.Config{MaxRunning: 1, MaxQueued: 1}, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-release
+	}))
+	req := func() *http.Request {
+		return httptest.NewRequest(http.MethodPost, "/api/v1/dispatcher/itinerary", nil)
+	}
+
+	// The first job runs, the second waits in the queue, the third is refused.
+	first, err := store.Submit("", req())
+	if err != nil {
+		t.Fatalf("Failed to submit job: %v", err)
+	}
+	<-started
+	second, err := store.Submit("", req())
+	if err != nil {
+		t.Fatalf("Failed to submit job: %v", err)
+	}
+	if _, err = store.Submit("", req()); !errors.Is(err, jobs.ErrTooManyJobs) {
+		t.Errorf("Expected ErrTooManyJobs, got %v", err)
+	}
+	if stats := store.Stats(context.Background()); stats.Queued != 1 || stats.Running != 1 || stats.Backend != jobs.BackendMemory {
+		t.Errorf("Expected one job queued and one running, got %+v", stats)
+	}
+
+	close(release)
+	wait(t, store, "", first.ID)
+	wait(t, store, "", second.ID)
+	if _, err = store.Submit("", req()); err != nil {
+		t.Errorf("Expected a job to be accepted once the queue drained, got %v", err)
+	}
+}
+
+func TestCloseRequeues(t *testing.T) {
+	t.Parallel()
+
+	server := miniredis.RunT(t)
+	client, err := redis.New(redis.Config{Addr: server.Addr()})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+
+	// The first replica is stopped while running the job, which is canceled and queued again.
+	started := make(chan struct{})
+	first, err := jobs.New(clock.Real{}, jobs.Config{Enabled: true, MaxRunning: 1}, jobs.WithBackend(client))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	first.Start(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+	}))
+	job, err := first.Submit("acme", httptest.NewRequest(http.MethodPost, "/api/v1/dispatcher/itinerary", nil))
+	if err != nil {
+		t.Fatalf("Failed to submit job: %v", err)
+	}
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err = first.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Close() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if queued, _, _ := first.Get(context.Background(), "acme", job.ID); queued.Status != jobs.StatusQueued {
+		t.Errorf("Expected the canceled job queued again, got %+v", queued)
+	}
+
+	// Another replica sharing the backend runs it.
+	second := newStore(t, clock.Real{}, jobs.Config{}, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}), jobs.WithBackend(client))
+	if done := wait(t, second, "acme", job.ID); done.Response.Status != http.StatusNoContent {
+		t.Errorf("Expected the job run by the second replica, got %+v", done.Response)
+	}
+}
+
+func TestMemoryBounds(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	clk := clock.NewFake(time.Date(2026, 10, 17, 9, 30, 0, 0, time.UTC))
+	memory := jobs.NewMemory(clk, 2, 1)
+
+	for _, key := range []string{"a", "b", "c"} {
+		_ = memory.Set(ctx, key, []byte(key), time.Minute)
+	}
+	if _, ok, _ := memory.Get(ctx, "a"); ok {
+		t.Error("Expected the oldest value dropped beyond the cap")
+	}
+	if value, ok, _ := memory.Get(ctx, "c"); !ok || string(value) != "c" {
+		t.Errorf("Get(c) = %q, %v, want c", value, ok)
+	}
+	clk.Advance(time.Minute)
+	if _, ok, _ := memory.Get(ctx, "c"); ok {
+		t.Error("Expected the value to expire after its ttl")
+	}
+
+	if err := memory.Push(ctx, "queue", []byte("first")); err != nil {
+		t.Fatalf("Push() error = %v", err)
+	}
+	if err := memory.Push(ctx, "queue", []byte("second")); !errors.Is(err, jobs.ErrTooManyJobs) {
+		t.Errorf("Push() to a full queue error = %v, want %v", err, jobs.ErrTooManyJobs)
+	}
+	if value, ok, _ := memory.Pop(ctx, "queue", time.Second); !ok || string(value) != "first" {
+		t.Errorf("Pop() = %q, %v, want first", value, ok)
+	}
+	if _, ok, _ := memory.Pop(ctx, "queue", time.Millisecond); ok {
+		t.Error("Expected nothing popped from an empty queue")
+	}
+}
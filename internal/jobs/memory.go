@@ -0,0 +1,122 @@
+package jobs
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/clock"
+)
+
+// Memory is the Backend keeping jobs in memory, at most maxJobs of them, and queues of at most maxQueued
+// values. It is safe for concurrent use.
+type Memory struct {
+	clock  clock.Clock
+	values map[string]*list.Element
+	// order holds the values from the least to the most recently set.
+	order     *list.List
+	queues    map[string]chan []byte
+	maxJobs   int
+	maxQueued int
+	mu        sync.Mutex
+}
+
+type memoryValue struct {
+	expires time.Time
+	key     string
+	value   []byte
+}
+
+// NewMemory returns an empty memory backend.
+func NewMemory(clk clock.Clock, maxJobs, maxQueued int) *Memory {
+	return &Memory{
+		clock:     clk,
+		values:    make(map[string]*list.Element),
+		order:     list.New(),
+		queues:    make(map[string]chan []byte),
+		maxJobs:   maxJobs,
+		maxQueued: maxQueued,
+	}
+}
+
+// Get returns the value of key, or false when it does not exist or expired.
+func (m *Memory) Get(_ context.Context, key string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	elem, ok := m.values[key]
+	if !ok {
+		return nil, false, nil
+	}
+	value, _ := elem.Value.(*memoryValue)
+	if !m.clock.Now().Before(value.expires) {
+		m.order.Remove(elem)
+		delete(m.values, key)
+
+		return nil, false, nil
+	}
+
+	return value.value, true, nil
+}
+
+// Set stores the value under key, expiring after ttl, dropping the least recently set value when full.
+func (m *Memory) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if elem, ok := m.values[key]; ok {
+		m.order.Remove(elem)
+	}
+	m.values[key] = m.order.PushBack(&memoryValue{expires: m.clock.Now().Add(ttl), key: key, value: value})
+	for m.order.Len() > m.maxJobs {
+		oldest, _ := m.order.Remove(m.order.Front()).(*memoryValue)
+		delete(m.values, oldest.key)
+	}
+
+	return nil
+}
+
+// Push appends the value to the queue under key, failing with ErrTooManyJobs when it is full.
+func (m *Memory) Push(_ context.Context, key string, value []byte) error {
+	select {
+	case m.queue(key) <- value:
+		return nil
+	default:
+		return ErrTooManyJobs
+	}
+}
+
+// Pop removes and returns the value at the head of the queue under key, waiting up to timeout for one, or
+// until ctx is done.
+func (m *Memory) Pop(ctx context.Context, key string, timeout time.Duration) ([]byte, bool, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case value := <-m.queue(key):
+		return value, true, nil
+	case <-timer.C:
+		return nil, false, nil
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	}
+}
+
+// Len returns the length of the queue under key.
+func (m *Memory) Len(_ context.Context, key string) (int, error) {
+	return len(m.queue(key)), nil
+}
+
+func (m *Memory) queue(key string) chan []byte {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	queue, ok := m.queues[key]
+	if !ok {
+		queue = make(chan []byte, m.maxQueued)
+		m.queues[key] = queue
+	}
+
+	return queue
+}
@@ -0,0 +1,226 @@
+// Package limits enforces two-tier request size limits: requests above a soft limit are served
+// with a warning, requests above the hard limit are rejected. Both are counted per tenant so
+// clients approaching the cutoff can be spotted before it breaks them. Request bodies are
+// additionally capped in bytes, before they are decoded.
+package limits
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/dsha256/dispatcher/internal/dispatcher"
+	"github.com/dsha256/dispatcher/internal/responder"
+)
+
+var (
+	// ErrTooManyTickets is the dispatcher's, so requests above the hard limit fail alike whichever rejects them.
+	ErrTooManyTickets = dispatcher.ErrTooManyTickets
+	ErrBodyTooLarge   = errors.New("request body too large")
+	ErrInvalidLimits  = errors.New("invalid limits")
+)
+
+// Limits configure the checker; 0 disables a limit.
+type Limits struct {
+	// WarnTickets is the soft limit: larger requests are served with a warning.
+	WarnTickets int `json:"warn_tickets" yaml:"warn_tickets"`
+	// MaxTickets is the hard limit: larger requests are rejected.
+	MaxTickets int `json:"max_tickets" yaml:"max_tickets"`
+	// MaxBodyBytes caps the size of request bodies.
+	MaxBodyBytes int64 `json:"max_body_bytes" yaml:"max_body_bytes"`
+}
+
+// LimitError is returned for requests above the hard limit, the dispatcher's error for its own guard (see
+// dispatcher.WithMaxTickets). It unwraps to ErrTooManyTickets.
+type LimitError = dispatcher.LimitError
+
+// BodyError is returned for request bodies above MaxBodyBytes. It unwraps to ErrBodyTooLarge.
+type BodyError struct {
+	MaxBodyBytes int64 `json:"max_body_bytes"`
+}
+
+func (e *BodyError) Error() string {
+	return fmt.Sprintf("%s: the limit is %d bytes", ErrBodyTooLarge, e.MaxBodyBytes)
+}
+
+func (e *BodyError) Unwrap() error {
+	return ErrBodyTooLarge
+}
+
+func (e *BodyError) Details() any {
+	return e
+}
+
+const (
+	// MaxTenants caps the tenants a Checker keeps counts of; the requests of tenants beyond it are counted under
+	// OtherTenants. Tenants may come from an unauthenticated header, so their number is unbounded.
+	MaxTenants = 1000
+	// OtherTenants is the tenant the requests of the tenants beyond MaxTenants are counted under.
+	OtherTenants = "_other"
+)
+
+// Counts are the limit hits of a tenant.
+type Counts struct {
+	Tenant   string `json:"tenant"`
+	Warned   uint64 `json:"warned"`
+	Rejected uint64 `json:"rejected"`
+	// MaxTickets is the largest request seen from the tenant.
+	MaxTickets int `json:"max_tickets"`
+}
+
+// Checker checks requests against the limits. It is safe for concurrent use.
+type Checker struct {
+	counts map[string]*Counts
+	limits Limits
+	mu     sync.Mutex
+}
+
+func NewChecker(limits Limits) (*Checker, error) {
+	if err := limits.validate(); err != nil {
+		return nil, err
+	}
+
+	return &Checker{counts: make(map[string]*Counts), limits: limits}, nil
+}
+
+func (l Limits) validate() error {
+	if l.WarnTickets < 0 || l.MaxTickets < 0 || l.MaxBodyBytes < 0 {
+		return fmt.Errorf("%w: limits must not be negative", ErrInvalidLimits)
+	}
+	if l.WarnTickets > 0 && l.MaxTickets > 0 && l.WarnTickets >= l.MaxTickets {
+		return fmt.Errorf("%w: warn_tickets (%d) must be below max_tickets (%d)", ErrInvalidLimits, l.WarnTickets, l.MaxTickets)
+	}
+
+	return nil
+}
+
+// Limits returns the limits the checker enforces.
+func (c *Checker) Limits() Limits {
+	if c == nil {
+		return Limits{}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.limits
+}
+
+// SetLimits replaces the limits at runtime, keeping the counts; invalid limits are refused and the
+// current ones kept.
+func (c *Checker) SetLimits(limits Limits) error {
+	if err := limits.validate(); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.limits = limits
+
+	return nil
+}
+
+// Check counts a request of the tenant with the given number of tickets. It returns a warning
+// when the soft limit is exceeded and a *LimitError when the hard limit is. A nil checker allows everything.
+func (c *Checker) Check(tenant string, tickets int) (string, error) {
+	if c == nil {
+		return "", nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	counts, ok := c.counts[tenant]
+	if !ok && len(c.counts) >= MaxTenants {
+		tenant = OtherTenants
+		counts, ok = c.counts[tenant]
+	}
+	if !ok {
+		counts = &Counts{Tenant: tenant}
+		c.counts[tenant] = counts
+	}
+	counts.MaxTickets = max(counts.MaxTickets, tickets)
+
+	switch {
+	case c.limits.MaxTickets > 0 && tickets > c.limits.MaxTickets:
+		counts.Rejected++
+
+		return "", &LimitError{Tickets: tickets, MaxTickets: c.limits.MaxTickets}
+	case c.limits.WarnTickets > 0 && tickets > c.limits.WarnTickets:
+		counts.Warned++
+		if c.limits.MaxTickets > 0 {
+			return fmt.Sprintf("%d tickets exceed the soft limit of %d; requests above %d tickets will be rejected",
+				tickets, c.limits.WarnTickets, c.limits.MaxTickets), nil
+		}
+
+		return fmt.Sprintf("%d tickets exceed the soft limit of %d", tickets, c.limits.WarnTickets), nil
+	default:
+		return "", nil
+	}
+}
+
+// Exceeds returns a *LimitError when the number of tickets is above the hard limit, like Check, without counting
+// the request: for validating a request that is not served. A nil checker allows everything.
+func (c *Checker) Exceeds(tickets int) error {
+	if maxTickets := c.MaxTickets(); maxTickets > 0 && tickets > maxTickets {
+		return &LimitError{Tickets: tickets, MaxTickets: maxTickets}
+	}
+
+	return nil
+}
+
+// MaxTickets returns the hard limit, 0 when there is none, so streamed requests can stop reading once it is exceeded.
+func (c *Checker) MaxTickets() int {
+	return c.Limits().MaxTickets
+}
+
+// Middleware caps request bodies at MaxBodyBytes. Bodies announcing a larger Content-Length are rejected
+// with 413 before the handler runs; others fail with *http.MaxBytesError once reading crosses the limit,
+// which handlers report with BodyError. A nil checker or a 0 limit leaves bodies unbounded. The limit is read
+// per request, so it follows SetLimits.
+func (c *Checker) Middleware(next http.Handler) http.Handler {
+	if c == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		maxBodyBytes := c.Limits().MaxBodyBytes
+		if maxBodyBytes == 0 {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+		if r.ContentLength > maxBodyBytes {
+			err := &BodyError{MaxBodyBytes: maxBodyBytes}
+			responder.WriteErrorWithDetails(w, http.StatusRequestEntityTooLarge, err, err.Details())
+
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Snapshot returns the counts of every tenant sorted by tenant.
+func (c *Checker) Snapshot() []Counts {
+	if c == nil {
+		return []Counts{}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	out := make([]Counts, 0, len(c.counts))
+	for _, counts := range c.counts {
+		out = append(out, *counts)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Tenant < out[j].Tenant
+	})
+
+	return out
+}
@@ -0,0 +1,192 @@
+package limits_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dsha256/dispatcher/internal/limits"
+)
+
+func TestNewChecker(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		limits  limits.Limits
+		wantErr bool
+	}{
+		{name: "Disabled", limits: limits.Limits{}},
+		{name: "Soft and hard", limits: limits.Limits{WarnTickets: 10, MaxTickets: 20}},
+		{name: "Hard only", limits: limits.Limits{MaxTickets: 20}},
+		{name: "Negative", limits: limits.Limits{WarnTickets: -1}, wantErr: true},
+		{name: "Soft above hard", limits: limits.Limits{WarnTickets: 20, MaxTickets: 20}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := limits.NewChecker(tt.limits)
+			if got := errors.Is(err, limits.ErrInvalidLimits); got != tt.wantErr {
+				t.Errorf("NewChecker() error = %v; want invalid limits: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckerCheck(t *testing.T) {
+	t.Parallel()
+
+	checker, err := limits.NewChecker(limits.Limits{WarnTickets: 2, MaxTickets: 4})
+	if err != nil {
+		t.Fatalf("NewChecker() error = %v", err)
+	}
+
+	if warning, err := checker.Check("a", 2); warning != "" || err != nil {
+		t.Errorf("Check(2) = %q, %v; want no warning", warning, err)
+	}
+	if warning, err := checker.Check("a", 3); warning == "" || err != nil {
+		t.Errorf("Check(3) = %q, %v; want a warning", warning, err)
+	}
+	var limitErr *limits.LimitError
+	if _, err := checker.Check("b", 5); !errors.As(err, &limitErr) || !errors.Is(err, limits.ErrTooManyTickets) {
+		t.Errorf("Check(5) error = %v; want a limit error", err)
+	}
+
+	if err := checker.Exceeds(5); !errors.As(err, &limitErr) {
+		t.Errorf("Exceeds(5) error = %v; want a limit error", err)
+	}
+	if err := checker.Exceeds(3); err != nil {
+		t.Errorf("Exceeds(3) error = %v; want none below the hard limit", err)
+	}
+
+	// Exceeds counts nothing.
+	want := []limits.Counts{
+		{Tenant: "a", Warned: 1, MaxTickets: 3},
+		{Tenant: "b", Rejected: 1, MaxTickets: 5},
+	}
+	if got := checker.Snapshot(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Snapshot() = %+v; want %+v", got, want)
+	}
+
+	var disabled *limits.Checker
+	if warning, err := disabled.Check("a", 1_000_000); warning != "" || err != nil {
+		t.Errorf("nil checker Check() = %q, %v; want no limits", warning, err)
+	}
+	if err := disabled.Exceeds(1_000_000); err != nil {
+		t.Errorf("nil checker Exceeds() = %v; want no limits", err)
+	}
+}
+
+func TestCheckerMaxTenants(t *testing.T) {
+	t.Parallel()
+
+	checker, err := limits.NewChecker(limits.Limits{WarnTickets: 2})
+	if err != nil {
+		t.Fatalf("NewChecker() error = %v", err)
+	}
+	for i := range limits.MaxTenants + 10 {
+		_, _ = checker.Check(fmt.Sprintf("tenant-%04d", i), 3)
+	}
+	_, _ = checker.Check("tenant-0000", 3)
+
+	snapshot := checker.Snapshot()
+	if len(snapshot) != limits.MaxTenants+1 {
+		t.Fatalf("Snapshot() has %d tenants; want %d", len(snapshot), limits.MaxTenants+1)
+	}
+	if first := snapshot[0]; first.Tenant != limits.OtherTenants || first.Warned != 10 {
+		t.Errorf("Snapshot() first = %+v; want the 10 requests beyond the cap under %s", first, limits.OtherTenants)
+	}
+	if known := snapshot[1]; known.Tenant != "tenant-0000" || known.Warned != 2 {
+		t.Errorf("Snapshot() second = %+v; want tenant-0000 warned twice", known)
+	}
+}
+
+func TestCheckerMiddleware(t *testing.T) {
+	t.Parallel()
+
+	checker, err := limits.NewChecker(limits.Limits{MaxBodyBytes: 8})
+	if err != nil {
+		t.Fatalf("NewChecker() error = %v", err)
+	}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if !errors.As(err, &maxBytesErr) {
+				t.Errorf("ReadAll() error = %v; want *http.MaxBytesError", err)
+			}
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		checker *limits.Checker
+		name    string
+		body    string
+		status  int
+		chunked bool
+	}{
+		{name: "Within the limit", checker: checker, body: "12345678", status: http.StatusOK},
+		{name: "Content-Length above the limit", checker: checker, body: "123456789", status: http.StatusRequestEntityTooLarge},
+		{name: "Chunked body above the limit", checker: checker, body: "123456789", chunked: true, status: http.StatusRequestEntityTooLarge},
+		{name: "Disabled", checker: nil, body: "123456789", status: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			if tt.chunked {
+				req.ContentLength = -1
+			}
+			rec := httptest.NewRecorder()
+			tt.checker.Middleware(next).ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Errorf("status = %d; want %d", rec.Code, tt.status)
+			}
+		})
+	}
+}
+
+func TestCheckerSetLimits(t *testing.T) {
+	t.Parallel()
+
+	checker, err := limits.NewChecker(limits.Limits{MaxTickets: 4})
+	if err != nil {
+		t.Fatalf("NewChecker() error = %v", err)
+	}
+	if err := checker.SetLimits(limits.Limits{WarnTickets: 8, MaxTickets: 8}); !errors.Is(err, limits.ErrInvalidLimits) {
+		t.Errorf("SetLimits() error = %v; want %v", err, limits.ErrInvalidLimits)
+	}
+	if _, err := checker.Check("a", 5); err == nil {
+		t.Error("Check(5) passed; want the limits kept after an invalid change")
+	}
+
+	want := limits.Limits{MaxTickets: 8, MaxBodyBytes: 4}
+	if err := checker.SetLimits(want); err != nil {
+		t.Fatalf("SetLimits() error = %v", err)
+	}
+	if got := checker.Limits(); got != want {
+		t.Errorf("Limits() = %+v; want %+v", got, want)
+	}
+	if _, err := checker.Check("a", 5); err != nil {
+		t.Errorf("Check(5) error = %v; want it within the new limit", err)
+	}
+
+	rec := httptest.NewRecorder()
+	checker.Middleware(http.NotFoundHandler()).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("12345")))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status = %d; want the new body limit enforced", rec.Code)
+	}
+}
@@ -0,0 +1,117 @@
+// Package listener opens the socket the service listens on: a TCP port, a Unix domain socket, e.g. for a
+// sidecar avoiding TCP overhead and port management, or the socket systemd passes on socket activation.
+package listener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strconv"
+)
+
+var (
+	ErrUnknownNetwork   = errors.New("unknown listener network")
+	ErrMissingPath      = errors.New("listener path is required for unix sockets")
+	ErrInvalidMode      = errors.New("invalid listener mode")
+	ErrNoSystemdSockets = errors.New("no socket passed by systemd")
+)
+
+// Networks of listeners.
+const (
+	NetworkTCP     = "tcp"
+	NetworkUnix    = "unix"
+	NetworkSystemd = "systemd"
+)
+
+// systemdFirstFD is the first file descriptor systemd passes sockets from, SD_LISTEN_FDS_START.
+const systemdFirstFD = 3
+
+type Config struct {
+	// Network is "tcp" (the default) on the server port, "unix" on Path, or "systemd" for the first socket
+	// systemd passes on socket activation (LISTEN_FDS).
+	Network string `json:"network" yaml:"network"`
+	// Path is the path of the Unix socket; a socket left there by a previous run is replaced.
+	Path string `json:"path" yaml:"path"`
+	// Mode is the octal permission of the Unix socket, e.g. "0660" for clients in the service's group; the
+	// umask decides when empty.
+	Mode string `json:"mode" yaml:"mode"`
+}
+
+// Listen opens the configured listener, on the port for TCP.
+func Listen(ctx context.Context, cfg Config, port int) (net.Listener, error) {
+	switch cfg.Network {
+	case "", NetworkTCP:
+		return (&net.ListenConfig{}).Listen(ctx, "tcp", fmt.Sprintf(":%d", port))
+	case NetworkUnix:
+		return listenUnix(ctx, cfg)
+	case NetworkSystemd:
+		return listenSystemd()
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnknownNetwork, cfg.Network)
+	}
+}
+
+func listenUnix(ctx context.Context, cfg Config) (net.Listener, error) {
+	if cfg.Path == "" {
+		return nil, ErrMissingPath
+	}
+	var mode fs.FileMode
+	if cfg.Mode != "" {
+		parsed, err := strconv.ParseUint(cfg.Mode, 8, 32)
+		if err != nil || parsed > uint64(fs.ModePerm) {
+			return nil, fmt.Errorf("%w %q: want octal permissions, e.g. 0660", ErrInvalidMode, cfg.Mode)
+		}
+		mode = fs.FileMode(parsed)
+	}
+
+	// A socket left by a run that did not shut down cleanly would fail the listen.
+	if info, err := os.Lstat(cfg.Path); err == nil && info.Mode().Type() == fs.ModeSocket {
+		if err = os.Remove(cfg.Path); err != nil {
+			return nil, fmt.Errorf("removing stale socket: %w", err)
+		}
+	}
+
+	ln, err := (&net.ListenConfig{}).Listen(ctx, "unix", cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+	if mode != 0 {
+		if err = os.Chmod(cfg.Path, mode); err != nil {
+			_ = ln.Close()
+
+			return nil, fmt.Errorf("setting socket mode: %w", err)
+		}
+	}
+
+	return ln, nil
+}
+
+// listenSystemd returns the first socket systemd passed to the process, following sd_listen_fds: the sockets
+// start at file descriptor 3, LISTEN_FDS counts them and LISTEN_PID names the process they are meant for.
+// The variables are unset, so child processes do not inherit them.
+func listenSystemd() (net.Listener, error) {
+	pid, fds := os.Getenv("LISTEN_PID"), os.Getenv("LISTEN_FDS")
+	_ = os.Unsetenv("LISTEN_PID")
+	_ = os.Unsetenv("LISTEN_FDS")
+	_ = os.Unsetenv("LISTEN_FDNAMES")
+
+	if pid != strconv.Itoa(os.Getpid()) {
+		return nil, fmt.Errorf("%w: LISTEN_PID is %q, not this process", ErrNoSystemdSockets, pid)
+	}
+	if n, err := strconv.Atoi(fds); err != nil || n < 1 {
+		return nil, fmt.Errorf("%w: LISTEN_FDS is %q", ErrNoSystemdSockets, fds)
+	}
+
+	file := os.NewFile(systemdFirstFD, "systemd-socket")
+	defer file.Close()
+
+	ln, err := net.FileListener(file)
+	if err != nil {
+		return nil, fmt.Errorf("using the systemd socket: %w", err)
+	}
+
+	return ln, nil
+}
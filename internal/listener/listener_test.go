@@ -0,0 +1,111 @@
+package listener_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/dsha256/dispatcher/internal/listener"
+)
+
+func TestListenUnix(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "dispatcher.sock")
+	// A socket left by a previous run.
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("Failed to create the stale socket: %v", err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	_ = stale.Close()
+
+	ln, err := listener.Listen(context.Background(), listener.Config{Network: listener.NetworkUnix, Path: path, Mode: "0660"}, 0)
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { //nolint:gosec // Test server.
+		w.WriteHeader(http.StatusNoContent)
+	})}
+	go func() { _ = srv.Serve(ln) }()
+	t.Cleanup(func() { _ = srv.Close() })
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat the socket: %v", err)
+	}
+	if got := info.Mode().Perm(); got != 0o660 {
+		t.Errorf("Expected mode 0660, got %o", got)
+	}
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+	resp, err := client.Get("http://dispatcher/api/v1/liveness") //nolint:noctx // Test request.
+	if err != nil {
+		t.Fatalf("Failed to request over the socket: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected status 204, got %d", resp.StatusCode)
+	}
+}
+
+func TestListenTCP(t *testing.T) {
+	t.Parallel()
+
+	ln, err := listener.Listen(context.Background(), listener.Config{}, 0)
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer ln.Close()
+
+	if got := ln.Addr().Network(); got != "tcp" {
+		t.Errorf("Expected a tcp listener, got %s", got)
+	}
+}
+
+func TestListenErrors(t *testing.T) {
+	t.Parallel()
+
+	notASocket := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(notASocket, nil, 0o600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	tests := []struct {
+		wantErr error
+		name    string
+		cfg     listener.Config
+	}{
+		{name: "Unknown network", cfg: listener.Config{Network: "udp"}, wantErr: listener.ErrUnknownNetwork},
+		{name: "Unix without a path", cfg: listener.Config{Network: listener.NetworkUnix}, wantErr: listener.ErrMissingPath},
+		{
+			name:    "Invalid mode",
+			cfg:     listener.Config{Network: listener.NetworkUnix, Path: filepath.Join(t.TempDir(), "s.sock"), Mode: "rw"},
+			wantErr: listener.ErrInvalidMode,
+		},
+		{name: "Not a socket", cfg: listener.Config{Network: listener.NetworkUnix, Path: notASocket}, wantErr: syscall.EADDRINUSE},
+		{name: "Without systemd sockets", cfg: listener.Config{Network: listener.NetworkSystemd}, wantErr: listener.ErrNoSystemdSockets},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ln, err := listener.Listen(context.Background(), tt.cfg, 0)
+			if err == nil {
+				_ = ln.Close()
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package logbuffer
+
+import (
+	"bytes"
+	"sync"
+)
+
+// Buffer is an io.Writer that keeps the most recent log lines in memory.
+// It is meant to be combined with a slog text/JSON handler through io.MultiWriter
+// so that recent logs can be collected later without reading them back from stdout.
+type Buffer struct {
+	lines    []string
+	next     int
+	capacity int
+	full     bool
+	mu       sync.Mutex
+}
+
+func New(capacity int) *Buffer {
+	if capacity <= 0 {
+		capacity = 1
+	}
+
+	return &Buffer{
+		lines:    make([]string, capacity),
+		capacity: capacity,
+	}
+}
+
+// Write stores every complete line of p, evicting the oldest lines once the buffer is full.
+func (b *Buffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, line := range bytes.Split(bytes.TrimRight(p, "\n"), []byte("\n")) {
+		b.lines[b.next] = string(line)
+		b.next = (b.next + 1) % b.capacity
+		if b.next == 0 {
+			b.full = true
+		}
+	}
+
+	return len(p), nil
+}
+
+// Lines returns the buffered lines from the oldest to the newest.
+func (b *Buffer) Lines() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.full {
+		return append([]string(nil), b.lines[:b.next]...)
+	}
+
+	out := make([]string, 0, b.capacity)
+	out = append(out, b.lines[b.next:]...)
+
+	return append(out, b.lines[:b.next]...)
+}
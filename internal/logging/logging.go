@@ -0,0 +1,75 @@
+// Package logging builds the service's logger from its configuration, with a level that can be changed
+// while the service runs, e.g. to get debug logs from production without a redeploy.
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+var ErrUnknownFormat = errors.New("unknown log format")
+
+// Log formats.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+type Config struct {
+	// Format is the format of log records: "text" (the default) or "json".
+	Format string `json:"format" yaml:"format"`
+	// Level is the level logged from at startup: debug, info (the default), warn or error.
+	Level slog.Level `json:"level" yaml:"level"`
+}
+
+// Level is the level of a logger, changeable at runtime. It is safe for concurrent use.
+type Level struct {
+	level      slog.LevelVar
+	configured slog.LevelVar
+}
+
+// New returns a logger writing records in the configured format to w, and its level.
+func New(w io.Writer, cfg Config) (*slog.Logger, *Level, error) {
+	level := &Level{}
+	level.Configure(cfg.Level)
+
+	opts := &slog.HandlerOptions{Level: level}
+	switch cfg.Format {
+	case "", FormatText:
+		return slog.New(slog.NewTextHandler(w, opts)), level, nil
+	case FormatJSON:
+		return slog.New(slog.NewJSONHandler(w, opts)), level, nil
+	default:
+		return nil, nil, fmt.Errorf("%w %q", ErrUnknownFormat, cfg.Format)
+	}
+}
+
+// Level returns the current level, so a *Level is a slog.Leveler.
+func (l *Level) Level() slog.Level {
+	return l.level.Level()
+}
+
+// Set changes the current level.
+func (l *Level) Set(level slog.Level) {
+	l.level.Set(level)
+}
+
+// Configure changes the current and the configured level, e.g. when the configuration is reloaded.
+func (l *Level) Configure(level slog.Level) {
+	l.configured.Set(level)
+	l.level.Set(level)
+}
+
+// Toggle switches to debug from any other level, and back to the configured level from debug, and returns
+// the new level.
+func (l *Level) Toggle() slog.Level {
+	level := slog.LevelDebug
+	if l.Level() == slog.LevelDebug {
+		level = l.configured.Level()
+	}
+	l.Set(level)
+
+	return level
+}
@@ -0,0 +1,85 @@
+package logging_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/dsha256/dispatcher/internal/logging"
+)
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		cfg        logging.Config
+		wantErr    error
+		wantPrefix string
+	}{
+		{name: "Text by default", cfg: logging.Config{}, wantPrefix: "time="},
+		{name: "JSON", cfg: logging.Config{Format: logging.FormatJSON}, wantPrefix: `{"time":`},
+		{name: "Unknown format", cfg: logging.Config{Format: "xml"}, wantErr: logging.ErrUnknownFormat},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var out bytes.Buffer
+			logger, _, err := logging.New(&out, tt.cfg)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+
+			logger.Info("hello")
+			if !strings.HasPrefix(out.String(), tt.wantPrefix) {
+				t.Errorf("Expected a record starting with %q, got %q", tt.wantPrefix, out.String())
+			}
+		})
+	}
+}
+
+func TestLevel(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	logger, level, err := logging.New(&out, logging.Config{Level: slog.LevelWarn})
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+
+	if logger.Enabled(context.Background(), slog.LevelInfo) {
+		t.Error("Expected info records to be dropped at the configured level")
+	}
+
+	if got := level.Toggle(); got != slog.LevelDebug {
+		t.Errorf("Expected toggling to switch to debug, got %v", got)
+	}
+	if !logger.Enabled(context.Background(), slog.LevelDebug) {
+		t.Error("Expected debug records to be logged after toggling")
+	}
+	if got := level.Toggle(); got != slog.LevelWarn {
+		t.Errorf("Expected toggling again to restore the configured level, got %v", got)
+	}
+
+	level.Set(slog.LevelError)
+	if logger.Enabled(context.Background(), slog.LevelWarn) {
+		t.Error("Expected warn records to be dropped after setting the error level")
+	}
+	if got := level.Toggle(); got != slog.LevelDebug {
+		t.Errorf("Expected toggling from error to switch to debug, got %v", got)
+	}
+	level.Configure(slog.LevelInfo)
+	if got := level.Toggle(); got != slog.LevelDebug {
+		t.Errorf("Expected toggling from the new configured level to switch to debug, got %v", got)
+	}
+	if got := level.Toggle(); got != slog.LevelInfo {
+		t.Errorf("Expected toggling again to restore the new configured level, got %v", got)
+	}
+}
@@ -0,0 +1,75 @@
+// Package messages is the catalog of human-readable success messages. Every message has a stable
+// key that is sent alongside the text, so clients can match on the key while deployments reword the text.
+package messages
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var ErrUnknownKey = errors.New("unknown message key")
+
+// Key identifies a message independently of its wording.
+type Key string
+
+const (
+	ServiceLive      Key = "service.live"
+	ServiceReady     Key = "service.ready"
+	ServiceDegraded  Key = "service.degraded"
+	BlackoutSaved    Key = "blackout.saved"
+	BlackoutDeleted  Key = "blackout.deleted"
+	ItineraryDeleted Key = "itinerary.deleted"
+	CaptureArmed     Key = "inspector.capture_armed"
+)
+
+// Defaults returns the built-in text of every key.
+func Defaults() map[Key]string {
+	return map[Key]string{
+		ServiceLive:      "All services are up and running",
+		ServiceReady:     "All services are up and ready to process requests",
+		ServiceDegraded:  "Service is ready but degraded",
+		BlackoutSaved:    "Blackout calendar saved",
+		BlackoutDeleted:  "Blackout calendar deleted",
+		ItineraryDeleted: "Saved itinerary deleted",
+		CaptureArmed:     "Payload capture armed for the next request with this ID",
+	}
+}
+
+// Catalog resolves keys to text. It is read-only after construction and safe for concurrent use.
+type Catalog struct {
+	texts map[Key]string
+}
+
+// New returns the default catalog with the overrides applied. Overriding an unknown key is an error,
+// so typos in the configuration are caught at startup rather than silently ignored.
+func New(overrides map[string]string) (*Catalog, error) {
+	texts := Defaults()
+
+	unknown := []string{}
+	for key, text := range overrides {
+		if _, ok := texts[Key(key)]; !ok {
+			unknown = append(unknown, key)
+
+			continue
+		}
+		texts[Key(key)] = text
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, strings.Join(unknown, ", "))
+	}
+
+	return &Catalog{texts: texts}, nil
+}
+
+// Text returns the text of the key. A nil catalog returns the default text.
+func (c *Catalog) Text(key Key) string {
+	if c == nil {
+		return Defaults()[key]
+	}
+
+	return c.texts[key]
+}
@@ -0,0 +1,67 @@
+package messages_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/dsha256/dispatcher/internal/messages"
+)
+
+func TestCatalog(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		overrides map[string]string
+		name      string
+		key       messages.Key
+		want      string
+		wantErr   bool
+	}{
+		{name: "Default", key: messages.ServiceLive, want: "All services are up and running"},
+		{
+			name:      "Override",
+			overrides: map[string]string{"service.live": "OK"},
+			key:       messages.ServiceLive,
+			want:      "OK",
+		},
+		{
+			name:      "Other keys keep defaults",
+			overrides: map[string]string{"service.live": "OK"},
+			key:       messages.BlackoutSaved,
+			want:      "Blackout calendar saved",
+		},
+		{
+			name:      "Unknown key",
+			overrides: map[string]string{"service.alive": "OK"},
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			catalog, err := messages.New(tt.overrides)
+			if got := errors.Is(err, messages.ErrUnknownKey); got != tt.wantErr {
+				t.Fatalf("New() error = %v; want unknown key: %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := catalog.Text(tt.key); got != tt.want {
+				t.Errorf("Text(%q) = %q; want %q", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNilCatalog(t *testing.T) {
+	t.Parallel()
+
+	var catalog *messages.Catalog
+	for key, want := range messages.Defaults() {
+		if got := catalog.Text(key); got != want {
+			t.Errorf("Text(%q) = %q; want %q", key, got, want)
+		}
+	}
+}
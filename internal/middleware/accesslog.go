@@ -0,0 +1,175 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Access log formats.
+const (
+	// AccessLogJSON logs every request as a record with its fields, in JSON with the json log format.
+	AccessLogJSON = "json"
+	// AccessLogCombined logs every request as a record whose message is in the Apache combined log format,
+	// followed by the request ID, tenant and duration in milliseconds.
+	AccessLogCombined = "combined"
+)
+
+const (
+	// tenantHeader names the tenant of a request, the default one when missing.
+	tenantHeader  = "X-Tenant-Id"
+	defaultTenant = "default"
+	// combinedTime is the time layout of the Apache combined log format.
+	combinedTime = "02/Jan/2006:15:04:05 -0700"
+)
+
+// AccessLog configures the access log of LoggingMiddleware.
+type AccessLog struct {
+	// Format is AccessLogJSON (the default) or AccessLogCombined.
+	Format string `json:"format" yaml:"format"`
+	// Sampling keeps the access log readable during high-volume periods.
+	Sampling Sampling `json:"sampling" yaml:"sampling"`
+	// TrustForwardedFor takes the client IP from the first X-Forwarded-For address rather than the remote
+	// address, for services behind a proxy.
+	TrustForwardedFor bool `json:"trust_forwarded_for" yaml:"trust_forwarded_for"`
+}
+
+// Sampling logs every request up to PerSecond requests a second, and beyond only the Rate share of
+// successful ones; failed requests, with a status of 400 and above, are always logged.
+type Sampling struct {
+	PerSecond int `json:"per_second" yaml:"per_second"`
+	// Rate is the share of successful requests logged beyond PerSecond, from 0 to 1.
+	Rate    float64 `json:"rate"    yaml:"rate"`
+	Enabled bool    `json:"enabled" yaml:"enabled"`
+}
+
+// accessEntry is a request as it is logged.
+type accessEntry struct {
+	start     time.Time
+	method    string
+	path      string
+	proto     string
+	clientIP  string
+	requestID string
+	tenant    string
+	referer   string
+	userAgent string
+	duration  time.Duration
+	bytes     int64
+	status    int
+}
+
+func (e accessEntry) log(ctx context.Context, logger *slog.Logger, format string) {
+	if format == AccessLogCombined {
+		logger.LogAttrs(ctx, slog.LevelInfo, e.combined())
+
+		return
+	}
+
+	logger.LogAttrs(ctx, slog.LevelInfo, "Request completed",
+		slog.String("method", e.method),
+		slog.String("path", e.path),
+		slog.Int("status", e.status),
+		slog.Int64("bytes", e.bytes),
+		slog.Float64("duration_ms", float64(e.duration.Microseconds())/1000),
+		slog.String("client_ip", e.clientIP),
+		slog.String("request_id", e.requestID),
+		slog.String("tenant", e.tenant),
+	)
+}
+
+// combined formats the entry in the Apache combined log format, with "-" for missing values, followed by
+// the request ID, tenant and duration in milliseconds.
+func (e accessEntry) combined() string {
+	return fmt.Sprintf(`%s - - [%s] "%s %s %s" %d %d %q %q %q %q %.3f`,
+		e.clientIP, e.start.Format(combinedTime), e.method, e.path, e.proto, e.status, e.bytes,
+		orDash(e.referer), orDash(e.userAgent), orDash(e.requestID), e.tenant,
+		float64(e.duration.Microseconds())/1000,
+	)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+
+	return s
+}
+
+// clientIP returns the IP of the client, from the first X-Forwarded-For address when trusted.
+func clientIP(r *http.Request, trustForwardedFor bool) string {
+	if trustForwardedFor {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+
+			return strings.TrimSpace(first)
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// sampler counts the requests of the current second to decide which are logged.
+type sampler struct {
+	cfg    Sampling
+	second int64
+	count  int
+	mu     sync.Mutex
+}
+
+// keep reports whether the request completed at now with the status is logged.
+func (s *sampler) keep(now time.Time, status int) bool {
+	if !s.cfg.Enabled {
+		return true
+	}
+
+	s.mu.Lock()
+	if second := now.Unix(); second != s.second {
+		s.second, s.count = second, 0
+	}
+	s.count++
+	count := s.count
+	s.mu.Unlock()
+
+	return status >= http.StatusBadRequest || count <= s.cfg.PerSecond ||
+		rand.Float64() < s.cfg.Rate //nolint:gosec // Sampling does not need a CSPRNG.
+}
+
+// accessRecorder remembers the response status and counts the bytes of the response body.
+type accessRecorder struct {
+	http.ResponseWriter
+	bytes       int64
+	status      int
+	wroteHeader bool
+}
+
+func (ar *accessRecorder) WriteHeader(status int) {
+	if !ar.wroteHeader {
+		ar.status = status
+		ar.wroteHeader = true
+	}
+	ar.ResponseWriter.WriteHeader(status)
+}
+
+func (ar *accessRecorder) Write(p []byte) (int, error) {
+	ar.wroteHeader = true
+	n, err := ar.ResponseWriter.Write(p)
+	ar.bytes += int64(n)
+
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to flush streamed responses.
+func (ar *accessRecorder) Unwrap() http.ResponseWriter {
+	return ar.ResponseWriter
+}
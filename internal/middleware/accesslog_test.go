@@ -0,0 +1,130 @@
+package middleware_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/dsha256/dispatcher/internal/middleware"
+)
+
+func TestLoggingMiddleware(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		wantFields map[string]any
+		name       string
+		wantLine   string
+		cfg        middleware.AccessLog
+	}{
+		{
+			name: "JSON",
+			cfg:  middleware.AccessLog{Format: middleware.AccessLogJSON},
+			wantFields: map[string]any{
+				"msg":        "Request completed",
+				"method":     "POST",
+				"path":       "/api/v1/dispatcher/itinerary?strict=true",
+				"status":     float64(http.StatusCreated),
+				"bytes":      float64(len("created")),
+				"client_ip":  "192.0.2.1",
+				"request_id": "req-1",
+				"tenant":     "acme",
+			},
+		},
+		{
+			name: "Forwarded client IP",
+			cfg:  middleware.AccessLog{TrustForwardedFor: true},
+			wantFields: map[string]any{
+				"client_ip": "203.0.113.7",
+			},
+		},
+		{
+			name: "Combined",
+			cfg:  middleware.AccessLog{Format: middleware.AccessLogCombined},
+			wantLine: `^192\.0\.2\.1 - - \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] ` +
+				`"POST /api/v1/dispatcher/itinerary\?strict=true HTTP/1\.1" 201 7 "-" "test-agent" "req-1" "acme" \d+\.\d{3}$`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var out bytes.Buffer
+			logger := slog.New(slog.NewJSONHandler(&out, nil))
+			handler := middleware.LoggingMiddleware(logger, tt.cfg, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("X-Request-Id", "req-1")
+				w.WriteHeader(http.StatusCreated)
+				_, _ = w.Write([]byte("created"))
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/dispatcher/itinerary?strict=true", nil)
+			req.Header.Set("X-Tenant-Id", "acme")
+			req.Header.Set("X-Forwarded-For", "203.0.113.7, 10.0.0.1")
+			req.Header.Set("User-Agent", "test-agent")
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			var record map[string]any
+			if err := json.Unmarshal(out.Bytes(), &record); err != nil {
+				t.Fatalf("Expected a single access log record, got %q: %v", out.String(), err)
+			}
+			for field, want := range tt.wantFields {
+				if record[field] != want {
+					t.Errorf("Expected %s %v, got %v", field, want, record[field])
+				}
+			}
+			if tt.wantLine != "" {
+				if msg, _ := record["msg"].(string); !regexp.MustCompile(tt.wantLine).MatchString(msg) {
+					t.Errorf("Expected a combined log line, got %q", msg)
+				}
+			}
+		})
+	}
+}
+
+func TestLoggingMiddlewareSampling(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&out, nil))
+	cfg := middleware.AccessLog{Sampling: middleware.Sampling{Enabled: true, PerSecond: 2, Rate: 0}}
+	handler := middleware.LoggingMiddleware(logger, cfg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	paths := []string{"/ok", "/ok", "/ok", "/missing", "/ok", "/ok", "/ok"}
+	for _, path := range paths {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	var failed, succeeded int
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		var record struct {
+			Path string `json:"path"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("Failed to decode record: %v", err)
+		}
+		if record.Path == "/missing" {
+			failed++
+		} else {
+			succeeded++
+		}
+	}
+
+	if failed != 1 {
+		t.Errorf("Expected the failed request to be logged, got %d records", failed)
+	}
+	// The requests may straddle two seconds, each logging its first 2 successful requests.
+	if succeeded < 2 || succeeded > 4 {
+		t.Errorf("Expected 2 successful requests logged per second, got %d", succeeded)
+	}
+}
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+)
+
+// AuditMiddleware logs every request it wraps once answered, whether it succeeded or not, so the changes
+// made through admin endpoints can be traced: method, path, status, client IP and request ID. The records
+// carry audit=true, to be told apart from the access log.
+func AuditMiddleware(logger *slog.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rec := &accessRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+
+			requestID := w.Header().Get(requestIDHeader)
+			if requestID == "" {
+				requestID = r.Header.Get(requestIDHeader)
+			}
+			logger.WarnContext(r.Context(), "Admin action",
+				"audit", true,
+				"method", r.Method,
+				"path", r.URL.Path,
+				"status", rec.status,
+				"remote_addr", r.RemoteAddr,
+				"request_id", requestID,
+			)
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package middleware_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dsha256/dispatcher/internal/middleware"
+)
+
+func TestAuditMiddleware(t *testing.T) {
+	t.Parallel()
+
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, nil))
+	handler := middleware.AuditMiddleware(logger)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/admin/cache", nil)
+	req.Header.Set("X-Request-Id", "req-1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	var record map[string]any
+	if err := json.Unmarshal(logs.Bytes(), &record); err != nil {
+		t.Fatalf("Expected one JSON log record, got %q: %v", logs.String(), err)
+	}
+	want := map[string]any{
+		"msg":        "Admin action",
+		"audit":      true,
+		"method":     http.MethodDelete,
+		"path":       "/api/v1/admin/cache",
+		"status":     float64(http.StatusNoContent),
+		"request_id": "req-1",
+	}
+	for key, value := range want {
+		if record[key] != value {
+			t.Errorf("Expected %s=%v, got %v", key, value, record[key])
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/dsha256/dispatcher/internal/responder"
+	"github.com/dsha256/dispatcher/pkg/apierror"
+)
+
+var (
+	ErrUnauthorized = errors.New("missing or invalid admin token")
+	ErrForbidden    = errors.New("admin endpoint disabled: no admin token is configured")
+)
+
+// Admin configures the authentication of the admin endpoints changing the service or exposing its internals.
+type Admin struct {
+	// Token is the bearer token of admin requests; those endpoints refuse every request when empty.
+	Token string `json:"-" yaml:"token"`
+	// ReadToken is the bearer token of requests only reading the admin endpoints, which the admin token
+	// reads too. Those endpoints require the admin token when empty, and refuse every request when both are.
+	ReadToken string `json:"-" yaml:"read_token"`
+}
+
+// AuthMiddleware lets through the requests carrying the token in an "Authorization: Bearer" header, and
+// answers the others with 401 Unauthorized; every request is answered with 403 Forbidden when the token is
+// empty, so admin endpoints are closed unless a token is configured.
+func AuthMiddleware(token string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token == "" {
+				w.Header().Set(responder.ErrorCodeHeader, string(apierror.CodeForbidden))
+				responder.WriteError(w, http.StatusForbidden, ErrForbidden)
+
+				return
+			}
+			if !authorized(r, token) {
+				unauthorized(w)
+
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ReadAuthMiddleware lets through the requests carrying the admin's read token or token, and answers the
+// others with 401 Unauthorized. Every request is answered with 403 Forbidden when both tokens are empty, so
+// reading the admin endpoints stays closed unless a token is configured.
+func ReadAuthMiddleware(admin Admin) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if admin.Token == "" && admin.ReadToken == "" {
+				w.Header().Set(responder.ErrorCodeHeader, string(apierror.CodeForbidden))
+				responder.WriteError(w, http.StatusForbidden, ErrForbidden)
+
+				return
+			}
+			if !admin.Reads(r) {
+				unauthorized(w)
+
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Reads reports whether the request carries the admin's read token or token.
+func (admin Admin) Reads(r *http.Request) bool {
+	return (admin.ReadToken != "" && authorized(r, admin.ReadToken)) || (admin.Token != "" && authorized(r, admin.Token))
+}
+
+// authorized reports whether the request carries the token in an "Authorization: Bearer" header.
+func authorized(r *http.Request, token string) bool {
+	given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+	return ok && subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+}
+
+func unauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	w.Header().Set(responder.ErrorCodeHeader, string(apierror.CodeUnauthorized))
+	responder.WriteError(w, http.StatusUnauthorized, ErrUnauthorized)
+}
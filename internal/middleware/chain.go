@@ -0,0 +1,49 @@
+package middleware
+
+import "net/http"
+
+// Middleware wraps a handler, e.g. to authenticate or trace requests.
+type Middleware func(http.Handler) http.Handler
+
+// Chain is an ordered list of middleware: the first one used is the outermost, so it sees requests first
+// and responses last. The zero value is an empty chain.
+type Chain struct {
+	middleware []Middleware
+}
+
+// NewChain returns a chain of the middleware, the first one outermost.
+func NewChain(middleware ...Middleware) *Chain {
+	return (&Chain{}).Use(middleware...)
+}
+
+// Use appends the middleware to the chain, inside the middleware already used, and returns the chain.
+// Nil middleware are skipped, so optional ones can be used unconditionally.
+func (c *Chain) Use(middleware ...Middleware) *Chain {
+	for _, mw := range middleware {
+		if mw != nil {
+			c.middleware = append(c.middleware, mw)
+		}
+	}
+
+	return c
+}
+
+// With returns a copy of the chain with the middleware appended, leaving the chain unchanged, e.g. for the
+// middleware of a single route.
+func (c *Chain) With(middleware ...Middleware) *Chain {
+	return NewChain(c.middleware...).Use(middleware...)
+}
+
+// Then wraps the handler in the chain's middleware.
+func (c *Chain) Then(handler http.Handler) http.Handler {
+	for i := len(c.middleware) - 1; i >= 0; i-- {
+		handler = c.middleware[i](handler)
+	}
+
+	return handler
+}
+
+// ThenFunc wraps the handler function in the chain's middleware.
+func (c *Chain) ThenFunc(handler http.HandlerFunc) http.Handler {
+	return c.Then(handler)
+}
@@ -0,0 +1,51 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dsha256/dispatcher/internal/middleware"
+)
+
+func TestChain(t *testing.T) {
+	t.Parallel()
+
+	trace := func(name string) middleware.Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Add("X-Trace", name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	chain := middleware.NewChain(trace("first"), nil).Use(trace("second"))
+	route := chain.With(trace("route"))
+
+	tests := []struct {
+		chain     *middleware.Chain
+		name      string
+		wantTrace string
+	}{
+		{name: "Chain", chain: chain, wantTrace: "first,second,handler"},
+		{name: "Route chain", chain: route, wantTrace: "first,second,route,handler"},
+		{name: "Empty chain", chain: &middleware.Chain{}, wantTrace: "handler"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			handler := tt.chain.ThenFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Add("X-Trace", "handler")
+			})
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/liveness", nil))
+
+			if trace := strings.Join(rec.Header().Values("X-Trace"), ","); trace != tt.wantTrace {
+				t.Errorf("Expected trace %q, got %q", tt.wantTrace, trace)
+			}
+		})
+	}
+}
@@ -0,0 +1,251 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/dsha256/dispatcher/internal/responder"
+)
+
+var (
+	ErrInvalidCompressedBody = errors.New("invalid gzip request body")
+	ErrUnsupportedEncoding   = errors.New("unsupported content encoding")
+)
+
+// Compression configures gzip compression of responses and decompression of request bodies.
+type Compression struct {
+	// Level is the gzip level, from 1 (fastest) to 9 (smallest); the gzip default when 0.
+	Level int `json:"level"     yaml:"level"`
+	// MinBytes is the size below which responses are sent uncompressed, 1024 when 0.
+	MinBytes int  `json:"min_bytes" yaml:"min_bytes"`
+	Enabled  bool `json:"enabled"   yaml:"enabled"`
+}
+
+const defaultCompressionMinBytes = 1024
+
+// CompressionMiddleware decompresses request bodies sent with Content-Encoding: gzip, rejecting other
+// encodings with 415, and compresses responses with gzip for clients accepting it through
+// Accept-Encoding. Responses below MinBytes, already encoded or streamed as server-sent events are
+// sent as they are. Request body limits apply to the decompressed body when enforced further down.
+func CompressionMiddleware(cfg Compression, next http.Handler) http.Handler {
+	if !cfg.Enabled {
+		return next
+	}
+	if cfg.Level == 0 {
+		cfg.Level = gzip.DefaultCompression
+	}
+	if cfg.MinBytes <= 0 {
+		cfg.MinBytes = defaultCompressionMinBytes
+	}
+	writers := &sync.Pool{New: func() any {
+		gz, _ := gzip.NewWriterLevel(io.Discard, cfg.Level)
+
+		return gz
+	}}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !decompressBody(w, r) {
+			return
+		}
+
+		w.Header().Add("Vary", "Accept-Encoding")
+		if r.Method == http.MethodHead || !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: w, writers: writers, minBytes: cfg.MinBytes, status: http.StatusOK}
+		defer cw.close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// decompressBody replaces a gzip request body by its decompressed content. It writes the error response
+// itself and returns false when the body is not gzip or has another encoding.
+func decompressBody(w http.ResponseWriter, r *http.Request) bool {
+	switch encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding"))); encoding {
+	case "", "identity":
+		return true
+	case "gzip", "x-gzip":
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			responder.WriteError(w, http.StatusBadRequest, fmt.Errorf("%w: %w", ErrInvalidCompressedBody, err))
+
+			return false
+		}
+		r.Body = &gzipBody{Reader: gz, body: r.Body}
+		r.ContentLength = -1
+		r.Header.Del("Content-Encoding")
+		r.Header.Del("Content-Length")
+
+		return true
+	default:
+		responder.WriteError(w, http.StatusUnsupportedMediaType, fmt.Errorf("%w %q", ErrUnsupportedEncoding, encoding))
+
+		return false
+	}
+}
+
+// gzipBody reads a decompressed request body and closes the original one.
+type gzipBody struct {
+	*gzip.Reader
+	body io.ReadCloser
+}
+
+func (b *gzipBody) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	if err != nil && !errors.Is(err, io.EOF) {
+		err = fmt.Errorf("%w: %w", ErrInvalidCompressedBody, err)
+	}
+
+	return n, err
+}
+
+func (b *gzipBody) Close() error {
+	return errors.Join(b.Reader.Close(), b.body.Close())
+}
+
+// acceptsGzip reports whether the Accept-Encoding header accepts gzip, by name or through "*", with a
+// non-zero quality.
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != "gzip" && name != "*" {
+			continue
+		}
+
+		if q, ok := strings.CutPrefix(strings.ReplaceAll(params, " ", ""), "q="); ok {
+			if quality, err := strconv.ParseFloat(q, 64); err != nil || quality == 0 {
+				continue
+			}
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// compressWriter buffers the start of the response until it reaches minBytes, then sends it compressed;
+// a response completed or flushed earlier is decided on then.
+type compressWriter struct {
+	http.ResponseWriter
+	writers  *sync.Pool
+	gz       *gzip.Writer
+	buf      []byte
+	minBytes int
+	status   int
+	decided  bool
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if !cw.decided {
+		cw.status = status
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if !cw.decided {
+		cw.buf = append(cw.buf, p...)
+		if len(cw.buf) < cw.minBytes && cw.compressible() {
+			return len(p), nil
+		}
+		if err := cw.decide(cw.compressible()); err != nil {
+			return 0, err
+		}
+
+		return len(p), nil
+	}
+
+	if cw.gz != nil {
+		return cw.gz.Write(p)
+	}
+
+	return cw.ResponseWriter.Write(p)
+}
+
+// Flush sends what was written so far, compressed unless the response is not compressible, so streamed
+// responses stay compressed whatever the size of their first lines.
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		if err := cw.decide(cw.compressible()); err != nil {
+			return
+		}
+	}
+	if cw.gz != nil {
+		_ = cw.gz.Flush()
+	}
+	_ = http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to extend its write deadline.
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// compressible reports whether the response may be compressed, from its status and headers.
+func (cw *compressWriter) compressible() bool {
+	if cw.status < http.StatusOK || cw.status == http.StatusNoContent || cw.status == http.StatusNotModified {
+		return false
+	}
+	header := cw.ResponseWriter.Header()
+	if header.Get("Content-Encoding") != "" {
+		return false
+	}
+	mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+
+	return mediaType != "text/event-stream"
+}
+
+// decide sends the headers, compressed or not, and the buffered start of the response.
+func (cw *compressWriter) decide(compress bool) error {
+	cw.decided = true
+	if compress {
+		header := cw.ResponseWriter.Header()
+		header.Set("Content-Encoding", "gzip")
+		header.Del("Content-Length")
+		// The compressed representation is not byte for byte the tagged one.
+		if etag := header.Get("ETag"); strings.HasPrefix(etag, `"`) {
+			header.Set("ETag", "W/"+etag)
+		}
+		cw.gz, _ = cw.writers.Get().(*gzip.Writer)
+		cw.gz.Reset(cw.ResponseWriter)
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	if len(cw.buf) == 0 {
+		return nil
+	}
+	var err error
+	if cw.gz != nil {
+		_, err = cw.gz.Write(cw.buf)
+	} else {
+		_, err = cw.ResponseWriter.Write(cw.buf)
+	}
+	cw.buf = nil
+
+	return err
+}
+
+// close sends a response still buffered uncompressed, since it stayed below minBytes, or completes the
+// compressed stream.
+func (cw *compressWriter) close() {
+	if !cw.decided {
+		_ = cw.decide(false)
+	}
+	if cw.gz != nil {
+		_ = cw.gz.Close()
+		cw.gz.Reset(io.Discard)
+		cw.writers.Put(cw.gz)
+		cw.gz = nil
+	}
+}
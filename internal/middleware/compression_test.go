@@ -0,0 +1,128 @@
+package middleware_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dsha256/dispatcher/internal/middleware"
+)
+
+func TestCompressionMiddlewareResponses(t *testing.T) {
+	t.Parallel()
+
+	large := strings.Repeat(`"JFK", "LAX", `, 200)
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		contentType    string
+		body           string
+		wantGzip       bool
+	}{
+		{name: "Large response", acceptEncoding: "gzip, deflate", body: large, wantGzip: true},
+		{name: "Small response", acceptEncoding: "gzip", body: `{"data": {}}`},
+		{name: "Gzip not accepted", acceptEncoding: "gzip;q=0, br", body: large},
+		{name: "Any encoding accepted", acceptEncoding: "*", body: large, wantGzip: true},
+		{name: "Server-sent events", acceptEncoding: "gzip", contentType: "text/event-stream", body: large},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			handler := middleware.CompressionMiddleware(middleware.Compression{Enabled: true}, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("ETag", `"abc"`)
+				if tt.contentType != "" {
+					w.Header().Set("Content-Type", tt.contentType)
+				}
+				w.WriteHeader(http.StatusAccepted)
+				// Written in two parts, so the decision spans writes.
+				_, _ = io.WriteString(w, tt.body[:len(tt.body)/2])
+				_, _ = io.WriteString(w, tt.body[len(tt.body)/2:])
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/dispatcher/itinerary", nil)
+			req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusAccepted {
+				t.Errorf("Expected status %d, got %d", http.StatusAccepted, rec.Code)
+			}
+			if rec.Header().Get("Vary") != "Accept-Encoding" {
+				t.Errorf("Expected Vary: Accept-Encoding, got %q", rec.Header().Get("Vary"))
+			}
+			if gotGzip := rec.Header().Get("Content-Encoding") == "gzip"; gotGzip != tt.wantGzip {
+				t.Fatalf("Expected gzip = %v, got headers %v", tt.wantGzip, rec.Header())
+			}
+
+			if wantETag := map[bool]string{true: `W/"abc"`, false: `"abc"`}[tt.wantGzip]; rec.Header().Get("ETag") != wantETag {
+				t.Errorf("Expected ETag %s, got %s", wantETag, rec.Header().Get("ETag"))
+			}
+
+			body := rec.Body.Bytes()
+			if tt.wantGzip {
+				gz, err := gzip.NewReader(rec.Body)
+				if err != nil {
+					t.Fatalf("Failed to open gzip response: %v", err)
+				}
+				if body, err = io.ReadAll(gz); err != nil {
+					t.Fatalf("Failed to decompress response: %v", err)
+				}
+			}
+			if string(body) != tt.body {
+				t.Errorf("Expected body %q, got %q", tt.body, body)
+			}
+		})
+	}
+}
+
+func TestCompressionMiddlewareRequests(t *testing.T) {
+	t.Parallel()
+
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	_, _ = gz.Write([]byte(`{"tickets": [["JFK", "LAX"]]}`))
+	_ = gz.Close()
+
+	tests := []struct {
+		name       string
+		encoding   string
+		body       []byte
+		wantBody   string
+		wantStatus int
+	}{
+		{name: "Gzip body", encoding: "gzip", body: compressed.Bytes(), wantStatus: http.StatusOK, wantBody: `{"tickets": [["JFK", "LAX"]]}`},
+		{name: "Plain body", body: []byte(`{}`), wantStatus: http.StatusOK, wantBody: `{}`},
+		{name: "Invalid gzip body", encoding: "gzip", body: []byte(`{}`), wantStatus: http.StatusBadRequest},
+		{name: "Unsupported encoding", encoding: "br", body: []byte(`{}`), wantStatus: http.StatusUnsupportedMediaType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			handler := middleware.CompressionMiddleware(middleware.Compression{Enabled: true}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(r.Body)
+				if err != nil || r.Header.Get("Content-Encoding") != "" {
+					t.Errorf("Expected a decompressed body, got %v with headers %v", err, r.Header)
+				}
+				_, _ = w.Write(body)
+			}))
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/dispatcher/itinerary", bytes.NewReader(tt.body))
+			req.Header.Set("Content-Encoding", tt.encoding)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body)
+			}
+			if tt.wantStatus == http.StatusOK && rec.Body.String() != tt.wantBody {
+				t.Errorf("Expected body %q, got %q", tt.wantBody, rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORS configures cross-origin requests from browsers.
+type CORS struct {
+	// AllowedOrigins are the origins allowed to call the service, e.g. "https://tools.example.com", or "*" for any.
+	AllowedOrigins []string `json:"allowed_origins"   yaml:"allowed_origins"`
+	// AllowedMethods are the methods allowed in preflight requests, GET, POST and DELETE when empty.
+	AllowedMethods []string `json:"allowed_methods"   yaml:"allowed_methods"`
+	// AllowedHeaders are the request headers allowed in preflight requests, Content-Type,
+	// Content-Encoding and X-Tenant-Id when empty.
+	AllowedHeaders []string `json:"allowed_headers"   yaml:"allowed_headers"`
+	// ExposedHeaders are the response headers readable by browser scripts.
+	ExposedHeaders []string `json:"exposed_headers"   yaml:"exposed_headers"`
+	// MaxAge is how long browsers may cache a preflight response; not sent when 0.
+	MaxAge time.Duration `json:"max_age"           yaml:"max_age"`
+	// AllowCredentials lets browsers send cookies and authorization headers; the allowed origin is then
+	// always echoed, even with "*".
+	AllowCredentials bool `json:"allow_credentials" yaml:"allow_credentials"`
+	Enabled          bool `json:"enabled"           yaml:"enabled"`
+}
+
+// CORSMiddleware answers preflight requests from allowed origins with 204 No Content and lets browsers read
+// the responses to their other requests. Requests from other origins are served without CORS headers, so
+// browsers refuse them.
+func CORSMiddleware(cfg CORS, next http.Handler) http.Handler {
+	if !cfg.Enabled {
+		return next
+	}
+	if len(cfg.AllowedMethods) == 0 {
+		cfg.AllowedMethods = []string{http.MethodGet, http.MethodPost, http.MethodDelete}
+	}
+	if len(cfg.AllowedHeaders) == 0 {
+		cfg.AllowedHeaders = []string{"Content-Type", "Content-Encoding", "X-Tenant-Id"}
+	}
+	anyOrigin := slices.Contains(cfg.AllowedOrigins, "*")
+	methods, headers := strings.Join(cfg.AllowedMethods, ", "), strings.Join(cfg.AllowedHeaders, ", ")
+	exposed := strings.Join(cfg.ExposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(cfg.MaxAge.Seconds()))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := w.Header()
+		header.Add("Vary", "Origin")
+
+		origin := r.Header.Get("Origin")
+		if origin == "" || (!anyOrigin && !slices.Contains(cfg.AllowedOrigins, origin)) {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		if anyOrigin && !cfg.AllowCredentials {
+			header.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			header.Set("Access-Control-Allow-Origin", origin)
+		}
+		if cfg.AllowCredentials {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if r.Method != http.MethodOptions || r.Header.Get("Access-Control-Request-Method") == "" {
+			if exposed != "" {
+				header.Set("Access-Control-Expose-Headers", exposed)
+			}
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		header.Add("Vary", "Access-Control-Request-Method")
+		header.Add("Vary", "Access-Control-Request-Headers")
+		header.Set("Access-Control-Allow-Methods", methods)
+		header.Set("Access-Control-Allow-Headers", headers)
+		if cfg.MaxAge > 0 {
+			header.Set("Access-Control-Max-Age", maxAge)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,110 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/middleware"
+)
+
+func TestCORSMiddleware(t *testing.T) {
+	t.Parallel()
+
+	cfg := middleware.CORS{
+		Enabled:        true,
+		AllowedOrigins: []string{"https://tools.example.com"},
+		ExposedHeaders: []string{"X-Dispatcher-Itinerary-Id"},
+		MaxAge:         10 * time.Minute,
+	}
+
+	tests := []struct {
+		cfg         middleware.CORS
+		wantHeaders map[string]string
+		name        string
+		method      string
+		origin      string
+		wantStatus  int
+	}{
+		{
+			name:       "Preflight",
+			cfg:        cfg,
+			method:     http.MethodOptions,
+			origin:     "https://tools.example.com",
+			wantStatus: http.StatusNoContent,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin":  "https://tools.example.com",
+				"Access-Control-Allow-Methods": "GET, POST, DELETE",
+				"Access-Control-Allow-Headers": "Content-Type, Content-Encoding, X-Tenant-Id",
+				"Access-Control-Max-Age":       "600",
+			},
+		},
+		{
+			name:       "Simple request",
+			cfg:        cfg,
+			method:     http.MethodPost,
+			origin:     "https://tools.example.com",
+			wantStatus: http.StatusOK,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin":   "https://tools.example.com",
+				"Access-Control-Expose-Headers": "X-Dispatcher-Itinerary-Id",
+				"Access-Control-Allow-Methods":  "",
+			},
+		},
+		{
+			name:        "Other origin",
+			cfg:         cfg,
+			method:      http.MethodOptions,
+			origin:      "https://evil.example.com",
+			wantStatus:  http.StatusOK,
+			wantHeaders: map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:        "Any origin",
+			cfg:         middleware.CORS{Enabled: true, AllowedOrigins: []string{"*"}},
+			method:      http.MethodPost,
+			origin:      "https://elsewhere.example.com",
+			wantStatus:  http.StatusOK,
+			wantHeaders: map[string]string{"Access-Control-Allow-Origin": "*"},
+		},
+		{
+			name:       "Any origin with credentials",
+			cfg:        middleware.CORS{Enabled: true, AllowedOrigins: []string{"*"}, AllowCredentials: true},
+			method:     http.MethodPost,
+			origin:     "https://elsewhere.example.com",
+			wantStatus: http.StatusOK,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin":      "https://elsewhere.example.com",
+				"Access-Control-Allow-Credentials": "true",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			handler := middleware.CORSMiddleware(tt.cfg, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+			req := httptest.NewRequest(tt.method, "/api/v1/dispatcher/itinerary", nil)
+			req.Header.Set("Origin", tt.origin)
+			if tt.method == http.MethodOptions {
+				req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+			for name, want := range tt.wantHeaders {
+				if got := rec.Header().Get(name); got != want {
+					t.Errorf("Expected %s %q, got %q", name, want, got)
+				}
+			}
+			if rec.Header().Get("Vary") != "Origin" {
+				t.Errorf("Expected Vary to start with Origin, got %v", rec.Header().Values("Vary"))
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package middleware
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/dsha256/dispatcher/internal/codec"
+)
+
+// EncodingMiddleware sends the JSON responses of requests preferring MessagePack, protobuf or XML in Accept
+// (see codec.Negotiate) in that encoding, buffering them until complete; other responses, e.g. streams and CSV,
+// are passed through. The ETags of encoded responses get the encoding's suffix, stripped from If-None-Match
+// before the request goes on, so each encoding has its own. Request bodies in the encodings are decoded by
+// the handler, after their signature is verified.
+func EncodingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		addVary(w.Header(), "Accept")
+		encoder := codec.Negotiate(r.Header.Get("Accept"))
+		if encoder == nil {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		suffix := "-" + encoder.Name()
+		if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+			r = r.Clone(r.Context())
+			r.Header.Set("If-None-Match", strings.ReplaceAll(ifNoneMatch, suffix+`"`, `"`))
+		}
+
+		ew := &encodingWriter{ResponseWriter: w, encoder: encoder, etagSuffix: suffix}
+		defer ew.finish()
+		next.ServeHTTP(ew, r)
+	})
+}
+
+// addVary adds the header name to the Vary header unless it is listed already.
+func addVary(header http.Header, name string) {
+	for _, value := range header.Values("Vary") {
+		for _, listed := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(listed), name) {
+				return
+			}
+		}
+	}
+	header.Add("Vary", name)
+}
+
+// isJSON reports whether the media type is JSON, e.g. application/json or the v2 envelope's.
+func isJSON(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// encodingWriter buffers JSON responses to encode them once complete; other responses are passed through
+// as they are written.
+type encodingWriter struct {
+	http.ResponseWriter
+	encoder     codec.Encoder
+	etagSuffix  string
+	buf         []byte
+	status      int
+	wroteHeader bool
+	buffering   bool
+}
+
+func (ew *encodingWriter) WriteHeader(status int) {
+	if ew.wroteHeader {
+		return
+	}
+	ew.wroteHeader, ew.status = true, status
+
+	header := ew.ResponseWriter.Header()
+	if etag := header.Get("ETag"); strings.HasSuffix(etag, `"`) {
+		header.Set("ETag", strings.TrimSuffix(etag, `"`)+ew.etagSuffix+`"`)
+	}
+	mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	if ew.buffering = isJSON(mediaType); !ew.buffering {
+		ew.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (ew *encodingWriter) Write(p []byte) (int, error) {
+	if !ew.wroteHeader {
+		ew.WriteHeader(http.StatusOK)
+	}
+	if ew.buffering {
+		ew.buf = append(ew.buf, p...)
+
+		return len(p), nil
+	}
+
+	return ew.ResponseWriter.Write(p)
+}
+
+// Flush flushes the responses passed through; JSON responses are only sent once complete.
+func (ew *encodingWriter) Flush() {
+	if ew.buffering {
+		return
+	}
+	_ = http.NewResponseController(ew.ResponseWriter).Flush()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to extend its write deadline.
+func (ew *encodingWriter) Unwrap() http.ResponseWriter {
+	return ew.ResponseWriter
+}
+
+// finish encodes and sends the buffered JSON response, or sends it as it is when it is empty or cannot be
+// encoded.
+func (ew *encodingWriter) finish() {
+	if !ew.buffering {
+		return
+	}
+
+	header := ew.ResponseWriter.Header()
+	body := ew.buf
+	if len(body) > 0 {
+		if encoded, err := ew.encoder.Encode(body); err == nil {
+			body = encoded
+			header.Set("Content-Type", ew.encoder.MediaType())
+		}
+	}
+
+	header.Del("Content-Length")
+	ew.ResponseWriter.WriteHeader(ew.status)
+	_, _ = ew.ResponseWriter.Write(body)
+}
@@ -0,0 +1,128 @@
+package middleware_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dsha256/dispatcher/internal/codec"
+	"github.com/dsha256/dispatcher/internal/middleware"
+	"github.com/dsha256/dispatcher/internal/responder"
+)
+
+func TestEncodingMiddleware(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name            string
+		accept          string
+		contentType     string
+		wantContentType string
+		wantJSON        string
+	}{
+		{
+			name:            "MessagePack",
+			accept:          "application/msgpack",
+			contentType:     "application/json",
+			wantContentType: codec.MediaTypeMsgPack,
+			wantJSON:        `{"data":["JFK","LAX"]}`,
+		},
+		{
+			name:            "Protobuf",
+			accept:          "application/json;q=0.5, application/x-protobuf",
+			contentType:     "application/json",
+			wantContentType: codec.MediaTypeProtobuf,
+			wantJSON:        `{"data":["JFK","LAX"]}`,
+		},
+		{
+			name:            "JSON",
+			accept:          "application/json",
+			contentType:     "application/json",
+			wantContentType: "application/json",
+		},
+		{
+			name:            "Not JSON",
+			accept:          "application/msgpack",
+			contentType:     "text/csv",
+			wantContentType: "text/csv",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			body := `{"data":["JFK","LAX"]}`
+			handler := middleware.EncodingMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if got := r.Header.Get("If-None-Match"); got != `"abc"` {
+					t.Errorf("Expected the encoding's suffix stripped from If-None-Match, got %s", got)
+				}
+				w.Header().Set("Content-Type", tt.contentType)
+				w.Header().Set("ETag", `"abc"`)
+				w.WriteHeader(http.StatusOK)
+				_, _ = w.Write([]byte(body))
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/dispatcher/itinerary/1", nil)
+			req.Header.Set("Accept", tt.accept)
+			req.Header.Set("If-None-Match", `"abc"`)
+			if c := codec.Negotiate(tt.accept); c != nil {
+				req.Header.Set("If-None-Match", `"abc-`+c.Name()+`"`)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if got := rec.Header().Get("Content-Type"); got != tt.wantContentType {
+				t.Errorf("Expected Content-Type %s, got %s", tt.wantContentType, got)
+			}
+			if got := rec.Header().Get("Vary"); got != "Accept" {
+				t.Errorf("Expected Vary: Accept, got %q", got)
+			}
+			c, ok := codec.Lookup(tt.wantContentType)
+			if !ok {
+				if got := rec.Body.String(); got != body {
+					t.Errorf("Expected the response passed through, got %s", got)
+				}
+
+				return
+			}
+			if got := rec.Header().Get("ETag"); got != `"abc-`+c.Name()+`"` {
+				t.Errorf("Expected the ETag suffixed with the encoding, got %s", got)
+			}
+			decoded, err := c.Decode(rec.Body.Bytes())
+			if err != nil {
+				t.Fatalf("Failed to decode the response: %v", err)
+			}
+			if string(decoded) != tt.wantJSON {
+				t.Errorf("Expected %s, got %s", tt.wantJSON, decoded)
+			}
+		})
+	}
+}
+
+func TestEncodingMiddlewareVersion(t *testing.T) {
+	t.Parallel()
+
+	handler := middleware.EncodingMiddleware(middleware.VersionMiddleware(middleware.Versioning{}, http.HandlerFunc(
+		func(w http.ResponseWriter, _ *http.Request) {
+			responder.WriteSuccess(w, http.StatusOK, "", []string{"JFK", "LAX"})
+		})))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v2/dispatcher/itinerary/1", nil)
+	req.Header.Set("Accept", "application/msgpack")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if got := rec.Header().Values("Vary"); len(got) != 1 {
+		t.Errorf("Expected Accept listed once in Vary, got %q", got)
+	}
+	decoded, err := codec.MsgPack{}.Decode(rec.Body.Bytes())
+	if err != nil {
+		t.Fatalf("Failed to decode the response: %v", err)
+	}
+	if !json.Valid(decoded) || !strings.Contains(string(decoded), `"meta"`) {
+		t.Errorf("Expected the v2 envelope, got %s", decoded)
+	}
+}
@@ -0,0 +1,172 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/dsha256/dispatcher/internal/responder"
+)
+
+var ErrInvalidOutputOption = errors.New("invalid output option")
+
+const (
+	// etagBareSuffix and etagPrettySuffix distinguish the ETags of bare and indented responses from those of
+	// the responses they are made of.
+	etagBareSuffix   = "-bare"
+	etagPrettySuffix = "-pretty"
+)
+
+// FormatMiddleware reshapes the JSON responses of requests asking for it, buffering them until complete:
+// ?envelope=false sends the data of success responses without their envelope, errors keeping theirs so
+// clients can tell them apart, and ?pretty=true indents them. Other responses, e.g. streams and CSV, are
+// passed through. Like EncodingMiddleware, it suffixes the ETags of the responses it changes and strips the
+// suffix from If-None-Match before the request goes on. Invalid option values are rejected with 400.
+func FormatMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		envelope, err := boolOption(query, "envelope", true)
+		if err != nil {
+			responder.WriteError(w, http.StatusBadRequest, err)
+
+			return
+		}
+		pretty, err := boolOption(query, "pretty", false)
+		if err != nil {
+			responder.WriteError(w, http.StatusBadRequest, err)
+
+			return
+		}
+		if envelope && !pretty {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		suffix := ""
+		if !envelope {
+			suffix += etagBareSuffix
+		}
+		if pretty {
+			suffix += etagPrettySuffix
+		}
+		if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+			r = r.Clone(r.Context())
+			r.Header.Set("If-None-Match", strings.ReplaceAll(ifNoneMatch, suffix+`"`, `"`))
+		}
+
+		fw := &formatWriter{ResponseWriter: w, etagSuffix: suffix, bare: !envelope, pretty: pretty}
+		defer fw.finish()
+		next.ServeHTTP(fw, r)
+	})
+}
+
+// boolOption parses the boolean query parameter, e.g. ?pretty=true; an absent or empty parameter is the
+// default value.
+func boolOption(query url.Values, name string, defaultValue bool) (bool, error) {
+	raw := query.Get(name)
+	if raw == "" {
+		return defaultValue, nil
+	}
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, fmt.Errorf("%w: %s must be true or false, got %q", ErrInvalidOutputOption, name, raw)
+	}
+
+	return value, nil
+}
+
+// formatWriter buffers JSON responses to reshape them once complete; other responses are passed through
+// as they are written.
+type formatWriter struct {
+	http.ResponseWriter
+	etagSuffix  string
+	buf         []byte
+	status      int
+	wroteHeader bool
+	buffering   bool
+	bare        bool
+	pretty      bool
+}
+
+func (fw *formatWriter) WriteHeader(status int) {
+	if fw.wroteHeader {
+		return
+	}
+	fw.wroteHeader, fw.status = true, status
+
+	header := fw.ResponseWriter.Header()
+	if etag := header.Get("ETag"); strings.HasSuffix(etag, `"`) {
+		header.Set("ETag", strings.TrimSuffix(etag, `"`)+fw.etagSuffix+`"`)
+	}
+	mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	if fw.buffering = isJSON(mediaType); !fw.buffering {
+		fw.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (fw *formatWriter) Write(p []byte) (int, error) {
+	if !fw.wroteHeader {
+		fw.WriteHeader(http.StatusOK)
+	}
+	if fw.buffering {
+		fw.buf = append(fw.buf, p...)
+
+		return len(p), nil
+	}
+
+	return fw.ResponseWriter.Write(p)
+}
+
+// Flush flushes the responses passed through; JSON responses are only sent once complete.
+func (fw *formatWriter) Flush() {
+	if fw.buffering {
+		return
+	}
+	_ = http.NewResponseController(fw.ResponseWriter).Flush()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to extend its write deadline.
+func (fw *formatWriter) Unwrap() http.ResponseWriter {
+	return fw.ResponseWriter
+}
+
+// finish reshapes and sends the buffered JSON response, or sends it as it is when it cannot be decoded.
+// Success responses without data are sent without a body when bare.
+func (fw *formatWriter) finish() {
+	if !fw.buffering {
+		return
+	}
+
+	header := fw.ResponseWriter.Header()
+	body := fw.buf
+	if fw.bare && fw.status < http.StatusBadRequest {
+		var envelope struct {
+			Data json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(body, &envelope); err == nil {
+			body = envelope.Data
+			if len(body) > 0 {
+				body = append(body, '\n')
+			} else {
+				header.Del("Content-Type")
+			}
+		}
+	}
+	if fw.pretty && len(body) > 0 {
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, body, "", "  "); err == nil {
+			body = indented.Bytes()
+		}
+	}
+
+	header.Del("Content-Length")
+	fw.ResponseWriter.WriteHeader(fw.status)
+	_, _ = fw.ResponseWriter.Write(body)
+}
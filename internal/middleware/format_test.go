@@ -0,0 +1,99 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dsha256/dispatcher/internal/middleware"
+	"github.com/dsha256/dispatcher/internal/responder"
+)
+
+func TestFormatMiddleware(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		query      string
+		etag       string
+		wantBody   string
+		wantStatus int
+		failure    bool
+	}{
+		{
+			name:       "Unchanged",
+			wantStatus: http.StatusOK,
+			etag:       `"abc"`,
+			wantBody:   `{"data":{"linear_path":["JFK","LAX"]}}` + "\n",
+		},
+		{
+			name:       "Bare",
+			query:      "?envelope=false",
+			wantStatus: http.StatusOK,
+			etag:       `"abc-bare"`,
+			wantBody:   `{"linear_path":["JFK","LAX"]}` + "\n",
+		},
+		{
+			name:       "Pretty",
+			query:      "?pretty=true",
+			wantStatus: http.StatusOK,
+			etag:       `"abc-pretty"`,
+			wantBody:   "{\n  \"data\": {\n    \"linear_path\": [\n      \"JFK\",\n      \"LAX\"\n    ]\n  }\n}\n",
+		},
+		{
+			name:       "Bare and pretty",
+			query:      "?pretty=1&envelope=0",
+			wantStatus: http.StatusOK,
+			etag:       `"abc-bare-pretty"`,
+			wantBody:   "{\n  \"linear_path\": [\n    \"JFK\",\n    \"LAX\"\n  ]\n}\n",
+		},
+		{
+			name:       "Bare error",
+			query:      "?envelope=false",
+			failure:    true,
+			wantStatus: http.StatusBadRequest,
+			etag:       `"abc-bare"`,
+			wantBody:   `{"err":"cycle in itinerary"}` + "\n",
+		},
+		{
+			name:       "Invalid value",
+			query:      "?pretty=yes",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"err":"invalid output option: pretty must be true or false, got \"yes\""}` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			handler := middleware.FormatMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if got := r.Header.Get("If-None-Match"); got != `"abc"` {
+					t.Errorf("Expected the suffix stripped from If-None-Match, got %s", got)
+				}
+				w.Header().Set("ETag", `"abc"`)
+				if tt.failure {
+					responder.WriteError(w, http.StatusBadRequest, errCycle)
+
+					return
+				}
+				responder.WriteJSON(w, http.StatusOK, map[string]any{"data": map[string]any{"linear_path": []string{"JFK", "LAX"}}})
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/dispatcher/itinerary/1"+tt.query, nil)
+			req.Header.Set("If-None-Match", tt.etag)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if got := rec.Body.String(); got != tt.wantBody {
+				t.Errorf("Expected body %q, got %q", tt.wantBody, got)
+			}
+			if got := rec.Header().Get("ETag"); tt.etag != "" && got != tt.etag {
+				t.Errorf("Expected ETag %s, got %s", tt.etag, got)
+			}
+		})
+	}
+}
@@ -6,25 +6,42 @@ import (
 	"time"
 )
 
-// LoggingMiddleware logs the request details.
-func LoggingMiddleware(logger *slog.Logger, next http.Handler) http.Handler {
+// LoggingMiddleware logs the start of every request at debug level, and its completion in the access log:
+// method, path, status, bytes, duration, client IP, request ID and tenant.
+func LoggingMiddleware(logger *slog.Logger, cfg AccessLog, next http.Handler) http.Handler {
+	sampling := &sampler{cfg: cfg.Sampling}
+
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
-		logger.Info("Request started", "method", r.Method, "url", r.URL.String())
-		next.ServeHTTP(w, r)
-		logger.Info("Request completed", "method", r.Method, "url", r.URL.String(), "duration", time.Since(start).String())
-	})
-}
+		logger.DebugContext(r.Context(), "Request started", "method", r.Method, "url", r.URL.String())
 
-// RecoveryMiddleware recovers from panics and logs them.
-func RecoveryMiddleware(logger *slog.Logger, next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		defer func() {
-			if err := recover(); err != nil {
-				logger.Error("Recovery from panic", "error", err)
-				http.Error(w, "Internal server error", http.StatusInternalServerError)
-			}
-		}()
-		next.ServeHTTP(w, r)
+		rec := &accessRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		end := time.Now()
+		if !sampling.keep(end, rec.status) {
+			return
+		}
+		entry := accessEntry{
+			start:     start,
+			method:    r.Method,
+			path:      r.URL.RequestURI(),
+			proto:     r.Proto,
+			clientIP:  clientIP(r, cfg.TrustForwardedFor),
+			requestID: w.Header().Get(requestIDHeader),
+			tenant:    r.Header.Get(tenantHeader),
+			referer:   r.Referer(),
+			userAgent: r.UserAgent(),
+			duration:  end.Sub(start),
+			bytes:     rec.bytes,
+			status:    rec.status,
+		}
+		if entry.requestID == "" {
+			entry.requestID = r.Header.Get(requestIDHeader)
+		}
+		if entry.tenant == "" {
+			entry.tenant = defaultTenant
+		}
+		entry.log(r.Context(), logger, cfg.Format)
 	})
 }
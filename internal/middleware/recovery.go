@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/clock"
+	"github.com/dsha256/dispatcher/internal/responder"
+	"github.com/dsha256/dispatcher/pkg/apierror"
+)
+
+var ErrInternal = errors.New("internal server error")
+
+// Panic is a panic recovered while serving a request.
+type Panic struct {
+	At time.Time `json:"at"`
+	// Value is the value the handler panicked with.
+	Value any `json:"value"`
+	// ID identifies the panic in the logs, the reports and the 500 response: the request ID.
+	ID     string `json:"id"`
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	// Stack is the stack trace of the panicking goroutine.
+	Stack string `json:"stack"`
+}
+
+// Message describes the panic value, e.g. "runtime error: index out of range [3] with length 3".
+func (p Panic) Message() string {
+	return fmt.Sprint(p.Value)
+}
+
+// PanicReporter sends recovered panics to an error tracker, e.g. Sentry or Rollbar.
+type PanicReporter interface {
+	ReportPanic(ctx context.Context, p Panic) error
+}
+
+// PanicDetails are the details of the 500 response to a panicking request, so the panic can be found in the
+// logs and the error tracker.
+type PanicDetails struct {
+	ID string `json:"id"`
+}
+
+// reportTimeout bounds the reporting of a panic, which happens after the response is sent.
+const reportTimeout = 10 * time.Second
+
+// Recovery counts the panics RecoveryMiddleware recovers and reports them. A nil *Recovery only logs them.
+type Recovery struct {
+	reporters []PanicReporter
+	panics    atomic.Uint64
+}
+
+// NewRecovery returns a Recovery reporting panics to the reporters. Nil reporters are skipped, so optional
+// ones can be given unconditionally.
+func NewRecovery(reporters ...PanicReporter) *Recovery {
+	rc := &Recovery{}
+	for _, reporter := range reporters {
+		if reporter != nil {
+			rc.reporters = append(rc.reporters, reporter)
+		}
+	}
+
+	return rc
+}
+
+// Panics returns the number of panics recovered so far.
+func (rc *Recovery) Panics() uint64 {
+	if rc == nil {
+		return 0
+	}
+
+	return rc.panics.Load()
+}
+
+// report counts the panic and reports it in the background, so reporting does not hold the response.
+func (rc *Recovery) report(ctx context.Context, logger *slog.Logger, p Panic) {
+	if rc == nil {
+		return
+	}
+
+	rc.panics.Add(1)
+	for _, reporter := range rc.reporters {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), reportTimeout)
+			defer cancel()
+			if err := reporter.ReportPanic(ctx, p); err != nil {
+				logger.WarnContext(ctx, "Failed to report panic", "error", err, "panic_id", p.ID)
+			}
+		}()
+	}
+}
+
+// RecoveryMiddleware recovers from panics, logs them with their stack trace, reports them through recovery,
+// and answers with a 500 error whose details carry the panic ID. The ID is the request ID, generated when
+// the request has none, and is also sent in the X-Request-Id header. Panics are timestamped by clk.
+func RecoveryMiddleware(logger *slog.Logger, clk clock.Clock, recovery *Recovery, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			value := recover()
+			if value == nil {
+				return
+			}
+			if value == http.ErrAbortHandler {
+				panic(value)
+			}
+
+			p := Panic{
+				At:     clk.Now().UTC(),
+				Value:  value,
+				ID:     r.Header.Get(requestIDHeader),
+				Method: r.Method,
+				Path:   r.URL.Path,
+				Stack:  string(debug.Stack()),
+			}
+			if p.ID == "" {
+				p.ID = newRequestID()
+			}
+			logger.ErrorContext(r.Context(), "Recovery from panic",
+				"error", p.Message(), "panic_id", p.ID, "method", p.Method, "path", p.Path, "stack", p.Stack)
+			recovery.report(r.Context(), logger, p)
+
+			w.Header().Set(requestIDHeader, p.ID)
+			w.Header().Set(responder.ErrorCodeHeader, string(apierror.CodeInternal))
+			responder.WriteErrorWithDetails(w, http.StatusInternalServerError, ErrInternal, PanicDetails{ID: p.ID})
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
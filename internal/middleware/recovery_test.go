@@ -0,0 +1,97 @@
+package middleware_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/clock"
+	"github.com/dsha256/dispatcher/internal/middleware"
+	"github.com/dsha256/dispatcher/internal/responder"
+)
+
+// chanReporter sends the panics it is given on a channel.
+type chanReporter chan middleware.Panic
+
+func (c chanReporter) ReportPanic(_ context.Context, p middleware.Panic) error {
+	c <- p
+
+	return nil
+}
+
+func TestRecoveryMiddleware(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		requestID string
+		wantID    bool
+	}{
+		{name: "With a request ID", requestID: "req-1"},
+		{name: "Without a request ID", wantID: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			reports := make(chanReporter, 1)
+			recovery := middleware.NewRecovery(reports, nil)
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			clk := clock.NewFake(time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC))
+			handler := middleware.RecoveryMiddleware(logger, clk, recovery, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+				panic("boom")
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/dispatcher/itinerary", nil)
+			if tt.requestID != "" {
+				req.Header.Set("X-Request-Id", tt.requestID)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusInternalServerError {
+				t.Fatalf("Expected status 500, got %d", rec.Code)
+			}
+			if got := rec.Header().Get(responder.ErrorCodeHeader); got != "INTERNAL" {
+				t.Errorf("Expected error code INTERNAL, got %q", got)
+			}
+			var resp struct {
+				Details middleware.PanicDetails `json:"details"`
+				Err     string                  `json:"err"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			wantID := tt.requestID
+			if tt.wantID {
+				wantID = rec.Header().Get("X-Request-Id")
+			}
+			if resp.Details.ID == "" || resp.Details.ID != wantID || rec.Header().Get("X-Request-Id") != wantID {
+				t.Errorf("Expected panic ID %q in the details and header, got %q and %q",
+					wantID, resp.Details.ID, rec.Header().Get("X-Request-Id"))
+			}
+
+			select {
+			case p := <-reports:
+				if p.ID != resp.Details.ID || p.Message() != "boom" || p.Path != req.URL.Path || !p.At.Equal(clk.Now()) {
+					t.Errorf("Expected the panic reported with its ID, got %+v", p)
+				}
+				if !strings.Contains(p.Stack, "TestRecoveryMiddleware") {
+					t.Errorf("Expected the stack trace of the panic, got %q", p.Stack)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("Expected the panic to be reported")
+			}
+			if recovery.Panics() != 1 {
+				t.Errorf("Expected 1 panic counted, got %d", recovery.Panics())
+			}
+		})
+	}
+}
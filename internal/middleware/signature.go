@@ -0,0 +1,218 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/clock"
+	"github.com/dsha256/dispatcher/internal/limits"
+	"github.com/dsha256/dispatcher/internal/responder"
+	"github.com/dsha256/dispatcher/pkg/apierror"
+)
+
+var (
+	ErrMissingSignature = errors.New("missing request signature")
+	ErrUnknownClient    = errors.New("unknown signing client")
+	ErrInvalidSignature = errors.New("invalid request signature")
+	ErrStaleSignature   = errors.New("request signature timestamp outside the allowed window")
+	ErrReplayedNonce    = errors.New("request signature nonce already used")
+)
+
+// Headers of signed requests.
+const (
+	ClientIDHeader           = "X-Client-Id"
+	SignatureHeader          = "X-Signature"
+	SignatureTimestampHeader = "X-Signature-Timestamp"
+	SignatureNonceHeader     = "X-Signature-Nonce"
+)
+
+// signaturePrefix names the algorithm of X-Signature values.
+const signaturePrefix = "sha256="
+
+// defaultMaxSkew is the allowed distance between a signature timestamp and the server time when unset.
+const defaultMaxSkew = 5 * time.Minute
+
+// Signing configures the verification of signed requests, for partners requiring requests signed end to end.
+type Signing struct {
+	// Secrets are the shared secrets by client ID, sent in X-Client-Id.
+	Secrets map[string]string `json:"-" yaml:"secrets"`
+	// MaxSkew is how far a signature timestamp may be from the server time, 5m when 0; nonces are
+	// remembered that long.
+	MaxSkew time.Duration `json:"max_skew" yaml:"max_skew"`
+	// Unsigned are the paths served unsigned when signatures are required, e.g. the liveness and readiness probes
+	// of the orchestrator; their signed requests are still verified.
+	Unsigned []string `json:"unsigned" yaml:"unsigned"`
+	// Required rejects unsigned requests; otherwise, only the requests carrying a signature are verified.
+	Required bool `json:"required" yaml:"required"`
+	Enabled  bool `json:"enabled"  yaml:"enabled"`
+}
+
+// Signatures verifies request signatures and remembers their nonces, so signed requests cannot be replayed.
+// It is safe for concurrent use.
+type Signatures struct {
+	clock clock.Clock
+	// nonces are the expiry times of the nonces seen, by client ID and nonce.
+	nonces    map[string]time.Time
+	lastSweep time.Time
+	cfg       Signing
+	mu        sync.Mutex
+}
+
+// NewSignatures returns the verifier of the configuration, nil when signing is disabled.
+func NewSignatures(cfg Signing, clk clock.Clock) *Signatures {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.MaxSkew <= 0 {
+		cfg.MaxSkew = defaultMaxSkew
+	}
+
+	return &Signatures{clock: clk, nonces: make(map[string]time.Time), lastSweep: clk.Now(), cfg: cfg}
+}
+
+// Sign returns the X-Signature value of the request with the body: the hex HMAC-SHA256, with the client's
+// secret, of the timestamp in Unix seconds, the nonce, the method, the escaped path, the query with its
+// parameters sorted by name, the X-Tenant-Id header and the body, separated by newlines. Signing the request
+// line and the tenant binds the signature to the resource and the tenant it was made for.
+func Sign(secret string, timestamp int64, nonce string, r *http.Request, body []byte) string {
+	target := requestTarget(r)
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d\n%s\n%s\n%s\n%s\n%s\n",
+		timestamp, nonce, r.Method, target.EscapedPath(), target.Query().Encode(), r.Header.Get(tenantHeader))
+	mac.Write(body)
+
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// requestTarget returns the path and query of the request as the client sent them: its RequestURI on the
+// server, which middleware rewriting the URL, e.g. VersionMiddleware, leaves as received, and its URL on the
+// client.
+func requestTarget(r *http.Request) *url.URL {
+	if r.RequestURI != "" {
+		if target, err := url.ParseRequestURI(r.RequestURI); err == nil {
+			return target
+		}
+	}
+
+	return r.URL
+}
+
+// Middleware verifies the signatures of requests, and answers those with a missing, invalid, stale or replayed
+// signature with 401 Unauthorized. Requests carrying a signature are verified whatever their method; when
+// signatures are required, every request is, but CORS preflights (OPTIONS), which browsers send unsigned, and
+// the requests for the Unsigned paths. The client ID of a verified signature is returned by VerifiedClient. A nil verifier lets every request through.
+func (s *Signatures) Middleware(next http.Handler) http.Handler {
+	if s == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(SignatureHeader) == "" && !s.mustSign(r) {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			WriteBodyError(w, err)
+
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		if err = s.verify(r, body); err != nil {
+			w.Header().Set(responder.ErrorCodeHeader, string(apierror.CodeUnauthorized))
+			responder.WriteError(w, http.StatusUnauthorized, err)
+
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(withVerifiedClient(r.Context(), r.Header.Get(ClientIDHeader))))
+	})
+}
+
+// mustSign reports whether the request is refused unsigned.
+func (s *Signatures) mustSign(r *http.Request) bool {
+	return s.cfg.Required && r.Method != http.MethodOptions && !slices.Contains(s.cfg.Unsigned, r.URL.Path)
+}
+
+// verify checks the signature headers against the request and its body, and records the nonce of a valid
+// signature.
+func (s *Signatures) verify(r *http.Request, body []byte) error {
+	header := r.Header
+	clientID, signature := header.Get(ClientIDHeader), header.Get(SignatureHeader)
+	nonce, timestamp := header.Get(SignatureNonceHeader), header.Get(SignatureTimestampHeader)
+	if clientID == "" || signature == "" || nonce == "" || timestamp == "" {
+		return fmt.Errorf("%w: %s, %s, %s and %s are required", ErrMissingSignature,
+			ClientIDHeader, SignatureHeader, SignatureTimestampHeader, SignatureNonceHeader)
+	}
+
+	secret, ok := s.cfg.Secrets[clientID]
+	if !ok {
+		return fmt.Errorf("%w %q", ErrUnknownClient, clientID)
+	}
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: %s must be Unix seconds", ErrInvalidSignature, SignatureTimestampHeader)
+	}
+	if !strings.HasPrefix(signature, signaturePrefix) ||
+		!hmac.Equal([]byte(signature), []byte(Sign(secret, unix, nonce, r, body))) {
+		return ErrInvalidSignature
+	}
+
+	now := s.clock.Now()
+	if skew := now.Sub(time.Unix(unix, 0)).Abs(); skew > s.cfg.MaxSkew {
+		return fmt.Errorf("%w: %s from the server time, the limit is %s", ErrStaleSignature, skew, s.cfg.MaxSkew)
+	}
+
+	return s.useNonce(now, clientID+"\x00"+nonce)
+}
+
+// useNonce records the nonce, failing when it is already recorded. Nonces expire once their requests'
+// timestamps would be stale anyway, and expired ones are swept at most once per MaxSkew.
+func (s *Signatures) useNonce(now time.Time, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) > s.cfg.MaxSkew {
+		for nonce, expiry := range s.nonces {
+			if now.After(expiry) {
+				delete(s.nonces, nonce)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	if expiry, ok := s.nonces[key]; ok && !now.After(expiry) {
+		return ErrReplayedNonce
+	}
+	// A timestamp up to MaxSkew behind the server time stays fresh until 2*MaxSkew from now.
+	s.nonces[key] = now.Add(2 * s.cfg.MaxSkew)
+
+	return nil
+}
+
+// WriteBodyError answers a request whose body could not be read, with 413 when it crossed the body limit, e.g.
+// for middleware reading bodies before the handlers.
+func WriteBodyError(w http.ResponseWriter, err error) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		bodyErr := &limits.BodyError{MaxBodyBytes: maxBytesErr.Limit}
+		responder.WriteErrorWithDetails(w, http.StatusRequestEntityTooLarge, bodyErr, bodyErr.Details())
+
+		return
+	}
+	responder.WriteError(w, http.StatusBadRequest, err)
+}
@@ -0,0 +1,193 @@
+package middleware_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/clock"
+	"github.com/dsha256/dispatcher/internal/middleware"
+	"github.com/dsha256/dispatcher/internal/responder"
+)
+
+func TestSignaturesMiddleware(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	body := `{"tickets":[["JFK","LAX"]]}`
+	target := "/api/v1/dispatcher/itinerary?strategy=fastest&envelope=false"
+
+	tests := []struct {
+		at         time.Time
+		name       string
+		method     string
+		secret     string
+		client     string
+		signedBody string
+		// signed is the request signed, when it differs from the one sent.
+		signed     func(*http.Request)
+		wantStatus int
+		required   bool
+		// rewritten serves the request under another path, like VersionMiddleware serving /api/v2 as /api/v1.
+		rewritten bool
+		// unsigned lists the path as served unsigned.
+		unsigned bool
+	}{
+		{name: "Valid signature", secret: "secret", wantStatus: http.StatusNoContent},
+		{name: "Skewed within the window", secret: "secret", at: now.Add(-4 * time.Minute), wantStatus: http.StatusNoContent},
+		{name: "Wrong secret", secret: "other", wantStatus: http.StatusUnauthorized},
+		{name: "Tampered body", secret: "secret", signedBody: `{"tickets":[]}`, wantStatus: http.StatusUnauthorized},
+		{
+			name: "Tampered method", secret: "secret", method: http.MethodDelete,
+			signed: func(r *http.Request) { r.Method = http.MethodPost }, wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "Tampered path", secret: "secret",
+			signed: func(r *http.Request) { r.URL.Path = "/api/v1/dispatcher/itinerary/validate" }, wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "Tampered query", secret: "secret",
+			signed: func(r *http.Request) { r.URL.RawQuery = "strategy=default&envelope=false" }, wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "Tampered tenant", secret: "secret",
+			signed: func(r *http.Request) { r.Header.Set("X-Tenant-Id", "globex") }, wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "Query in another order", secret: "secret",
+			signed: func(r *http.Request) { r.URL.RawQuery = "envelope=false&strategy=fastest" }, wantStatus: http.StatusNoContent,
+		},
+		{name: "URL rewritten by the server", secret: "secret", rewritten: true, wantStatus: http.StatusNoContent},
+		{name: "Stale timestamp", secret: "secret", at: now.Add(-6 * time.Minute), wantStatus: http.StatusUnauthorized},
+		{name: "Unsigned", wantStatus: http.StatusNoContent},
+		{name: "Unsigned when required", required: true, wantStatus: http.StatusUnauthorized},
+		{name: "Signed read", method: http.MethodGet, secret: "other", wantStatus: http.StatusUnauthorized},
+		{name: "Unsigned read when required", method: http.MethodGet, required: true, wantStatus: http.StatusUnauthorized},
+		{name: "Unsigned preflight when required", method: http.MethodOptions, required: true, wantStatus: http.StatusNoContent},
+		{name: "Unsigned path when required", method: http.MethodGet, required: true, unsigned: true, wantStatus: http.StatusNoContent},
+		{name: "Signed unsigned path", method: http.MethodGet, secret: "other", unsigned: true, required: true, wantStatus: http.StatusUnauthorized},
+		{name: "Unknown client", secret: "secret", client: "stranger", wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := middleware.Signing{Secrets: map[string]string{"partner": "secret"}, Required: tt.required, Enabled: true}
+			if tt.unsigned {
+				cfg.Unsigned = []string{"/api/v1/dispatcher/itinerary"}
+			}
+			signatures := middleware.NewSignatures(cfg, clock.NewFake(now))
+			handler := signatures.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if got, _ := io.ReadAll(r.Body); r.Method == http.MethodPost && string(got) != body {
+					t.Errorf("Expected the body to be passed on, got %q", got)
+				}
+				w.WriteHeader(http.StatusNoContent)
+			}))
+
+			method := tt.method
+			if method == "" {
+				method = http.MethodPost
+			}
+			req := httptest.NewRequest(method, target, strings.NewReader(body))
+			req.Header.Set("X-Tenant-Id", "acme")
+			if tt.secret != "" {
+				at, signedBody, client := tt.at, tt.signedBody, tt.client
+				if at.IsZero() {
+					at = now
+				}
+				if signedBody == "" {
+					signedBody = body
+				}
+				if client == "" {
+					client = "partner"
+				}
+				// The request as the client signs it, before it is sent.
+				signed := req.Clone(req.Context())
+				signed.RequestURI = ""
+				if tt.signed != nil {
+					tt.signed(signed)
+				}
+				req.Header.Set(middleware.ClientIDHeader, client)
+				req.Header.Set(middleware.SignatureTimestampHeader, strconv.FormatInt(at.Unix(), 10))
+				req.Header.Set(middleware.SignatureNonceHeader, "n-1")
+				req.Header.Set(middleware.SignatureHeader, middleware.Sign(tt.secret, at.Unix(), "n-1", signed, []byte(signedBody)))
+			}
+			if tt.rewritten {
+				req.URL.Path = "/api/v2/dispatcher/itinerary"
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if tt.wantStatus == http.StatusUnauthorized && rec.Header().Get(responder.ErrorCodeHeader) != "UNAUTHORIZED" {
+				t.Errorf("Expected error code UNAUTHORIZED, got %q", rec.Header().Get(responder.ErrorCodeHeader))
+			}
+		})
+	}
+}
+
+func TestSignaturesMiddlewareReplay(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	clk := clock.NewFake(now)
+	signatures := middleware.NewSignatures(middleware.Signing{
+		Secrets: map[string]string{"partner": "secret"},
+		MaxSkew: time.Minute,
+		Enabled: true,
+	}, clk)
+	handler := signatures.Middleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	send := func(nonce string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/dispatcher/itinerary", strings.NewReader("{}"))
+		req.Header.Set(middleware.ClientIDHeader, "partner")
+		req.Header.Set(middleware.SignatureTimestampHeader, strconv.FormatInt(now.Unix(), 10))
+		req.Header.Set(middleware.SignatureNonceHeader, nonce)
+		req.Header.Set(middleware.SignatureHeader, middleware.Sign("secret", now.Unix(), nonce, req, []byte("{}")))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		return rec.Code
+	}
+
+	if code := send("n-1"); code != http.StatusNoContent {
+		t.Fatalf("Expected the first request to be accepted, got %d", code)
+	}
+	if code := send("n-1"); code != http.StatusUnauthorized {
+		t.Errorf("Expected the replayed request to be rejected, got %d", code)
+	}
+	if code := send("n-2"); code != http.StatusNoContent {
+		t.Errorf("Expected a new nonce to be accepted, got %d", code)
+	}
+
+	// Once the timestamp is stale, the replay is rejected as stale, and the nonce may be forgotten.
+	clk.Advance(3 * time.Minute)
+	if code := send("n-1"); code != http.StatusUnauthorized {
+		t.Errorf("Expected the stale replay to be rejected, got %d", code)
+	}
+}
+
+func TestNewSignaturesDisabled(t *testing.T) {
+	t.Parallel()
+
+	if signatures := middleware.NewSignatures(middleware.Signing{}, clock.Real{}); signatures != nil {
+		t.Fatal("Expected no verifier when signing is disabled")
+	}
+	var signatures *middleware.Signatures
+	handler := signatures.Middleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("Expected a nil verifier to let requests through, got %d", rec.Code)
+	}
+}
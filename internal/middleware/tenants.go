@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// Tenants authenticates the tenant of client requests, by API key or by the client ID of a verified request
+// signature. The X-Tenant-Id header only claims a tenant, so it is replaced by the authenticated one once
+// tenants are configured.
+type Tenants struct {
+	// APIKeys are the tenants by API key, sent as a bearer token in the Authorization header.
+	APIKeys map[string]string `json:"-"       yaml:"api_keys"`
+	// Clients are the tenants by signing client ID, for the requests whose signature is verified.
+	Clients map[string]string `json:"clients" yaml:"clients"`
+}
+
+// Enabled reports whether any API key or signing client authenticates a tenant.
+func (t Tenants) Enabled() bool {
+	return len(t.APIKeys) > 0 || len(t.Clients) > 0
+}
+
+// Identity is the authenticated identity of a request.
+type Identity struct {
+	Tenant string
+	// ClientID is the signing client ID, empty for requests authenticated by API key.
+	ClientID string
+}
+
+type identityKey struct{}
+
+type verifiedClientKey struct{}
+
+// IdentityFrom returns the authenticated identity of the request, false when it carries none.
+func IdentityFrom(ctx context.Context) (Identity, bool) {
+	identity, ok := ctx.Value(identityKey{}).(Identity)
+
+	return identity, ok
+}
+
+// WithIdentity returns the context carrying the identity, e.g. for requests authenticated out of band.
+func WithIdentity(ctx context.Context, identity Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// VerifiedClient returns the client ID of the verified request signature, false when the request is unsigned.
+func VerifiedClient(ctx context.Context) (string, bool) {
+	clientID, ok := ctx.Value(verifiedClientKey{}).(string)
+
+	return clientID, ok
+}
+
+func withVerifiedClient(ctx context.Context, clientID string) context.Context {
+	return context.WithValue(ctx, verifiedClientKey{}, clientID)
+}
+
+// Middleware authenticates the tenant of every request and sets X-Tenant-Id to it, removing the header from
+// unauthenticated requests. It must run inside Signatures.Middleware, to see the verified client IDs. Without
+// tenants configured, it lets every request through untouched.
+func (t Tenants) Middleware(next http.Handler) http.Handler {
+	if !t.Enabled() {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Header.Del(tenantHeader)
+		if identity, ok := t.authenticate(r); ok {
+			r.Header.Set(tenantHeader, identity.Tenant)
+			r = r.WithContext(WithIdentity(r.Context(), identity))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authenticate returns the identity of the verified signing client, else of the API key.
+func (t Tenants) authenticate(r *http.Request) (Identity, bool) {
+	if clientID, ok := VerifiedClient(r.Context()); ok {
+		if tenant, ok := t.Clients[clientID]; ok {
+			return Identity{Tenant: tenant, ClientID: clientID}, true
+		}
+	}
+
+	key, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || key == "" {
+		return Identity{}, false
+	}
+	for apiKey, tenant := range t.APIKeys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(apiKey)) == 1 {
+			return Identity{Tenant: tenant}, true
+		}
+	}
+
+	return Identity{}, false
+}
@@ -0,0 +1,82 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/clock"
+	"github.com/dsha256/dispatcher/internal/middleware"
+)
+
+func TestTenantsMiddleware(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	signatures := middleware.NewSignatures(middleware.Signing{
+		Secrets: map[string]string{"partner": "secret", "unmapped": "secret"},
+		Enabled: true,
+	}, clock.NewFake(now))
+	tenants := middleware.Tenants{
+		APIKeys: map[string]string{"acme-key": "acme"},
+		Clients: map[string]string{"partner": "globex"},
+	}
+
+	tests := []struct {
+		header     map[string]string
+		name       string
+		client     string
+		wantTenant string
+		wantClient string
+		wantAuth   bool
+	}{
+		{name: "API key", header: map[string]string{"Authorization": "Bearer acme-key"}, wantTenant: "acme", wantAuth: true},
+		{name: "Signing client", client: "partner", wantTenant: "globex", wantClient: "partner", wantAuth: true},
+		{name: "Unmapped signing client", client: "unmapped"},
+		{name: "Unknown API key", header: map[string]string{"Authorization": "Bearer other"}},
+		{name: "Claimed tenant", header: map[string]string{"X-Tenant-Id": "acme"}},
+		{
+			name:       "Claimed tenant with an API key",
+			header:     map[string]string{"Authorization": "Bearer acme-key", "X-Tenant-Id": "globex"},
+			wantTenant: "acme",
+			wantAuth:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var identity middleware.Identity
+			var authenticated bool
+			var tenant string
+			handler := signatures.Middleware(tenants.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				identity, authenticated = middleware.IdentityFrom(r.Context())
+				tenant = r.Header.Get("X-Tenant-Id")
+				w.WriteHeader(http.StatusNoContent)
+			})))
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/dispatcher/itinerary", strings.NewReader("{}"))
+			for name, value := range tt.header {
+				req.Header.Set(name, value)
+			}
+			if tt.client != "" {
+				req.Header.Set(middleware.ClientIDHeader, tt.client)
+				req.Header.Set(middleware.SignatureTimestampHeader, strconv.FormatInt(now.Unix(), 10))
+				req.Header.Set(middleware.SignatureNonceHeader, tt.name)
+				req.Header.Set(middleware.SignatureHeader, middleware.Sign("secret", now.Unix(), tt.name, req, []byte("{}")))
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if authenticated != tt.wantAuth || identity.Tenant != tt.wantTenant || identity.ClientID != tt.wantClient {
+				t.Errorf("Expected identity %q/%q (%t), got %+v (%t)", tt.wantTenant, tt.wantClient, tt.wantAuth, identity, authenticated)
+			}
+			if tenant != tt.wantTenant {
+				t.Errorf("Expected X-Tenant-Id %q, got %q", tt.wantTenant, tenant)
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/responder"
+)
+
+var ErrRequestTimeout = errors.New("request timed out")
+
+// Timeouts bound the time handlers have to respond, per route.
+type Timeouts struct {
+	// Routes override Default by path: an exact path, or a prefix when it ends with "/", the longest
+	// matching one winning, e.g. "/api/v1/admin/" for every admin endpoint.
+	Routes map[string]time.Duration `json:"routes"  yaml:"routes"`
+	// Default applies to routes without their own timeout; 0 disables it.
+	Default time.Duration `json:"default" yaml:"default"`
+}
+
+// For returns the timeout of the request path, 0 when it has none.
+func (t Timeouts) For(path string) time.Duration {
+	if timeout, ok := t.Routes[path]; ok {
+		return timeout
+	}
+
+	timeout, longest := t.Default, 0
+	for route, routeTimeout := range t.Routes {
+		if strings.HasSuffix(route, "/") && strings.HasPrefix(path, route) && len(route) > longest {
+			timeout, longest = routeTimeout, len(route)
+		}
+	}
+
+	return timeout
+}
+
+// TimeoutPolicy gives the timeout of a request path, 0 when it has none; Timeouts and *ReloadableTimeouts are
+// timeout policies.
+type TimeoutPolicy interface {
+	For(path string) time.Duration
+}
+
+// ReloadableTimeouts are timeouts that can be replaced while requests are served, e.g. when the configuration
+// is reloaded. It is safe for concurrent use.
+type ReloadableTimeouts struct {
+	current atomic.Pointer[Timeouts]
+}
+
+func NewReloadableTimeouts(timeouts Timeouts) *ReloadableTimeouts {
+	r := &ReloadableTimeouts{}
+	r.Set(timeouts)
+
+	return r
+}
+
+// Set replaces the timeouts; requests already started keep their deadline.
+func (r *ReloadableTimeouts) Set(timeouts Timeouts) {
+	r.current.Store(&timeouts)
+}
+
+// For returns the timeout of the request path under the current timeouts.
+func (r *ReloadableTimeouts) For(path string) time.Duration {
+	return r.current.Load().For(path)
+}
+
+// TimeoutDetails are the details of the 504 response of a request that timed out.
+type TimeoutDetails struct {
+	Timeout string `json:"timeout"`
+}
+
+// TimeoutMiddleware gives each request the deadline of its route through its context, and answers
+// 504 Gateway Timeout with ErrRequestTimeout when the handler has not started responding by then.
+// Whatever the handler writes afterwards, e.g. its own error about the expired context, is discarded;
+// a response already started, e.g. a stream, is left to finish.
+func TimeoutMiddleware(timeouts TimeoutPolicy, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeout := timeouts.For(r.URL.Path)
+		if timeout <= 0 {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		tw := &timeoutWriter{ResponseWriter: w, ctx: ctx, header: w.Header().Clone()}
+		done := make(chan struct{})
+		panics := make(chan any, 1)
+		go func() {
+			defer func() {
+				if p := recover(); p != nil {
+					panics <- p
+				}
+				close(done)
+			}()
+			next.ServeHTTP(tw, r.WithContext(ctx))
+		}()
+
+		select {
+		case <-done:
+		case <-ctx.Done():
+			if tw.expire() {
+				responder.WriteErrorWithDetails(w, http.StatusGatewayTimeout, ErrRequestTimeout, TimeoutDetails{Timeout: timeout.String()})
+
+				return
+			}
+			<-done
+		}
+
+		select {
+		case p := <-panics:
+			panic(p)
+		default:
+		}
+	})
+}
+
+// timeoutWriter passes the response through until the request expires without a response started;
+// from then on writes are discarded. Headers are kept apart until the response starts, so the handler
+// never touches the headers of the timeout response; once it starts, the middleware no longer does.
+type timeoutWriter struct {
+	http.ResponseWriter
+	ctx     context.Context //nolint:containedctx // The deadline decides whether a response may start.
+	header  http.Header
+	mu      sync.Mutex
+	started bool
+	expired bool
+}
+
+// expire marks the request expired unless its response started, and reports whether it did.
+func (tw *timeoutWriter) expire() bool {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if !tw.started {
+		tw.expired = true
+	}
+
+	return tw.expired
+}
+
+// Header returns the headers kept apart until the response starts, then those of the response, e.g. to
+// set trailers.
+func (tw *timeoutWriter) Header() http.Header {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.started {
+		return tw.ResponseWriter.Header()
+	}
+
+	return tw.header
+}
+
+func (tw *timeoutWriter) WriteHeader(status int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	tw.start(status)
+}
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	tw.start(http.StatusOK)
+	if tw.expired {
+		return 0, http.ErrHandlerTimeout
+	}
+
+	return tw.ResponseWriter.Write(p)
+}
+
+// Flush flushes the response, starting it if needed.
+func (tw *timeoutWriter) Flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	tw.start(http.StatusOK)
+	if tw.expired {
+		return
+	}
+	_ = http.NewResponseController(tw.ResponseWriter).Flush()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to extend its write deadline.
+func (tw *timeoutWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
+
+// start sends the headers with the status, once, unless the request expired; tw.mu must be held.
+func (tw *timeoutWriter) start(status int) {
+	if tw.started || tw.expired {
+		return
+	}
+	if tw.ctx.Err() != nil {
+		tw.expired = true
+
+		return
+	}
+	tw.started = true
+	maps.Copy(tw.ResponseWriter.Header(), tw.header)
+	tw.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,125 @@
+package middleware_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/middleware"
+)
+
+func TestTimeoutsFor(t *testing.T) {
+	t.Parallel()
+
+	timeouts := middleware.Timeouts{
+		Default: 10 * time.Second,
+		Routes: map[string]time.Duration{
+			"/api/v1/liveness":        time.Second,
+			"/api/v1/admin/":          20 * time.Second,
+			"/api/v1/admin/inspector": 0,
+			"/api/v1/admin/usage/":    30 * time.Second,
+		},
+	}
+
+	tests := []struct {
+		path string
+		want time.Duration
+	}{
+		{path: "/api/v1/liveness", want: time.Second},
+		{path: "/api/v1/readiness", want: 10 * time.Second},
+		{path: "/api/v1/admin/cache", want: 20 * time.Second},
+		{path: "/api/v1/admin/inspector", want: 0},
+		{path: "/api/v1/admin/usage/acme", want: 30 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := timeouts.For(tt.path); got != tt.want {
+			t.Errorf("For(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestTimeoutMiddleware(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		handler    http.HandlerFunc
+		name       string
+		wantBody   string
+		wantStatus int
+	}{
+		{
+			name: "In time",
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("X-Answer", "42")
+				w.WriteHeader(http.StatusCreated)
+				_, _ = w.Write([]byte("done"))
+			},
+			wantStatus: http.StatusCreated,
+			wantBody:   "done",
+		},
+		{
+			name: "Deadline honored",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				<-r.Context().Done()
+				w.Header().Set("X-Answer", "42")
+				_, _ = w.Write([]byte("late"))
+			},
+			wantStatus: http.StatusGatewayTimeout,
+		},
+		{
+			name: "Deadline ignored",
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				time.Sleep(100 * time.Millisecond)
+				_, _ = w.Write([]byte("late"))
+			},
+			wantStatus: http.StatusGatewayTimeout,
+		},
+		{
+			name: "Response started",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("first "))
+				<-r.Context().Done()
+				_, _ = w.Write([]byte("last"))
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   "first last",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			handler := middleware.TimeoutMiddleware(middleware.Timeouts{Default: 20 * time.Millisecond}, tt.handler)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/dispatcher/itinerary", nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if tt.wantStatus != http.StatusGatewayTimeout {
+				if rec.Body.String() != tt.wantBody {
+					t.Errorf("Expected body %q, got %q", tt.wantBody, rec.Body.String())
+				}
+
+				return
+			}
+
+			var resp struct {
+				Details middleware.TimeoutDetails `json:"details"`
+				Err     string                    `json:"err"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if resp.Err != middleware.ErrRequestTimeout.Error() || resp.Details.Timeout != "20ms" {
+				t.Errorf("Expected the timeout error, got %+v", resp)
+			}
+			if rec.Header().Get("X-Answer") != "" {
+				t.Errorf("Expected the timed out handler's headers to be discarded, got %v", rec.Header())
+			}
+		})
+	}
+}
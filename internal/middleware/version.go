@@ -0,0 +1,198 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/responder"
+	"github.com/dsha256/dispatcher/pkg/apierror"
+)
+
+// Versioning configures the deprecation of API v1 in favor of v2.
+type Versioning struct {
+	// Deprecation is when v1 was deprecated, sent in the Deprecation header of v1 responses; v1 is not
+	// deprecated when zero.
+	Deprecation time.Time `json:"deprecation" yaml:"deprecation"`
+	// Sunset is when v1 is retired, sent in the Sunset header of v1 responses when set.
+	Sunset time.Time `json:"sunset" yaml:"sunset"`
+}
+
+const (
+	apiV1Prefix = "/api/v1"
+	apiV2Prefix = "/api/v2"
+
+	// requestIDHeader carries the request ID, also read by the request inspector.
+	requestIDHeader = "X-Request-Id"
+	// etagV2Suffix distinguishes the ETags of v2 responses from those of the v1 responses they are made of.
+	etagV2Suffix = "-v2"
+)
+
+// VersionMiddleware serves API v2, under /api/v2 or under /api/v1 to requests accepting MediaTypeV2, by
+// serving the v1 route and converting its JSON response to the v2 envelope; other responses, e.g. streams
+// and CSV, are the same in both versions. Responses to v1 requests carry the Deprecation, Sunset and
+// successor-version Link headers the configuration calls for.
+func VersionMiddleware(cfg Versioning, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rest, v2 := strings.CutPrefix(r.URL.Path, apiV2Prefix)
+		if !v2 {
+			if rest, v2 = strings.CutPrefix(r.URL.Path, apiV1Prefix); !v2 || (rest != "" && rest[0] != '/') {
+				next.ServeHTTP(w, r)
+
+				return
+			}
+			addVary(w.Header(), "Accept")
+			if v2 = acceptsV2(r.Header.Get("Accept")); !v2 {
+				deprecateV1(w.Header(), cfg, rest)
+				next.ServeHTTP(w, r)
+
+				return
+			}
+		} else if rest != "" && rest[0] != '/' {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		r = asV1(r, rest)
+		requestID := r.Header.Get(requestIDHeader)
+		if requestID == "" {
+			requestID = newRequestID()
+			r.Header.Set(requestIDHeader, requestID)
+		}
+		w.Header().Set(requestIDHeader, requestID)
+
+		vw := &v2Writer{ResponseWriter: w, start: time.Now(), requestID: requestID}
+		defer vw.finish()
+		next.ServeHTTP(vw, r)
+	})
+}
+
+// acceptsV2 reports whether the Accept header lists MediaTypeV2.
+func acceptsV2(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		if mediaType, _, err := mime.ParseMediaType(part); err == nil && mediaType == responder.MediaTypeV2 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// deprecateV1 sets the headers announcing the deprecation of the v1 path whose rest follows /api/v1.
+func deprecateV1(header http.Header, cfg Versioning, rest string) {
+	if !cfg.Deprecation.IsZero() {
+		header.Set("Deprecation", "@"+strconv.FormatInt(cfg.Deprecation.Unix(), 10))
+		header.Set("Link", "<"+apiV2Prefix+rest+`>; rel="successor-version"`)
+	}
+	if !cfg.Sunset.IsZero() {
+		header.Set("Sunset", cfg.Sunset.UTC().Format(http.TimeFormat))
+	}
+}
+
+// asV1 returns a copy of the request for the v1 path whose rest follows /api/v1, with the ETags of its
+// If-None-Match header stripped of their v2 suffix.
+func asV1(r *http.Request, rest string) *http.Request {
+	url := *r.URL
+	url.Path = apiV1Prefix + rest
+	url.RawPath = ""
+	v1 := r.WithContext(r.Context())
+	v1.URL = &url
+	v1.Header = r.Header.Clone()
+	if ifNoneMatch := v1.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		v1.Header.Set("If-None-Match", strings.ReplaceAll(ifNoneMatch, etagV2Suffix+`"`, `"`))
+	}
+
+	return v1
+}
+
+func newRequestID() string {
+	var id [8]byte
+	_, _ = rand.Read(id[:])
+
+	return hex.EncodeToString(id[:])
+}
+
+// v2Writer buffers JSON responses to convert them to the v2 envelope once complete; other responses are
+// passed through as they are written.
+type v2Writer struct {
+	http.ResponseWriter
+	start       time.Time
+	requestID   string
+	buf         []byte
+	status      int
+	wroteHeader bool
+	buffering   bool
+}
+
+func (vw *v2Writer) WriteHeader(status int) {
+	if vw.wroteHeader {
+		return
+	}
+	vw.wroteHeader, vw.status = true, status
+
+	header := vw.ResponseWriter.Header()
+	if etag := header.Get("ETag"); strings.HasSuffix(etag, `"`) {
+		header.Set("ETag", strings.TrimSuffix(etag, `"`)+etagV2Suffix+`"`)
+	}
+	mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	if vw.buffering = mediaType == "application/json"; !vw.buffering {
+		vw.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (vw *v2Writer) Write(p []byte) (int, error) {
+	if !vw.wroteHeader {
+		vw.WriteHeader(http.StatusOK)
+	}
+	if vw.buffering {
+		vw.buf = append(vw.buf, p...)
+
+		return len(p), nil
+	}
+
+	return vw.ResponseWriter.Write(p)
+}
+
+// Flush flushes the responses passed through; JSON responses are only sent once complete.
+func (vw *v2Writer) Flush() {
+	if vw.buffering {
+		return
+	}
+	_ = http.NewResponseController(vw.ResponseWriter).Flush()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to extend its write deadline.
+func (vw *v2Writer) Unwrap() http.ResponseWriter {
+	return vw.ResponseWriter
+}
+
+// finish converts and sends the buffered JSON response, or sends it as it is when it is not a v1
+// envelope.
+func (vw *v2Writer) finish() {
+	if !vw.buffering {
+		return
+	}
+
+	header := vw.ResponseWriter.Header()
+	code := apierror.Code(header.Get(responder.ErrorCodeHeader))
+	if code == "" {
+		code = apierror.FromStatus(vw.status)
+	}
+	meta := responder.MetaV2{
+		RequestID:  vw.requestID,
+		DurationMS: float64(time.Since(vw.start)) / float64(time.Millisecond),
+	}
+	body := vw.buf
+	if converted, err := responder.ToV2(vw.buf, code, meta); err == nil {
+		body = converted
+	}
+
+	header.Del("Content-Length")
+	vw.ResponseWriter.WriteHeader(vw.status)
+	_, _ = vw.ResponseWriter.Write(body)
+}
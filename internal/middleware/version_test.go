@@ -0,0 +1,169 @@
+package middleware_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/middleware"
+	"github.com/dsha256/dispatcher/internal/responder"
+	"github.com/dsha256/dispatcher/pkg/apierror"
+)
+
+var errCycle = errors.New("cycle in itinerary")
+
+func TestVersionMiddlewareV2(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		handler     http.HandlerFunc
+		name        string
+		path        string
+		accept      string
+		wantData    string
+		wantCode    apierror.Code
+		wantMessage string
+		wantStatus  int
+	}{
+		{
+			name: "Success",
+			path: "/api/v2/dispatcher/itinerary",
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				responder.WriteSuccessWithWarnings(w, http.StatusOK, "done", []string{"JFK", "LAX"}, nil)
+			},
+			wantStatus:  http.StatusOK,
+			wantData:    `["JFK","LAX"]`,
+			wantMessage: "done",
+		},
+		{
+			name:   "Accept header",
+			path:   "/api/v1/dispatcher/itinerary",
+			accept: "application/json;q=0.5, " + responder.MediaTypeV2,
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				responder.WriteSuccess(w, http.StatusOK, "", []string{"JFK", "LAX"})
+			},
+			wantStatus: http.StatusOK,
+			wantData:   `["JFK","LAX"]`,
+		},
+		{
+			name: "Error with code",
+			path: "/api/v2/dispatcher/itinerary",
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set(responder.ErrorCodeHeader, string(apierror.CodeCycle))
+				responder.WriteError(w, http.StatusBadRequest, errCycle)
+			},
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    apierror.CodeCycle,
+			wantMessage: errCycle.Error(),
+		},
+		{
+			name: "Error without code",
+			path: "/api/v2/unknown",
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				responder.WriteError(w, http.StatusNotFound, errCycle)
+			},
+			wantStatus:  http.StatusNotFound,
+			wantCode:    apierror.CodeNotFound,
+			wantMessage: errCycle.Error(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			handler := middleware.VersionMiddleware(middleware.Versioning{Deprecation: time.Now()}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !strings.HasPrefix(r.URL.Path, "/api/v1/") {
+					t.Errorf("Expected a v1 path, got %q", r.URL.Path)
+				}
+				tt.handler(w, r)
+			}))
+			req := httptest.NewRequest(http.MethodPost, tt.path, nil)
+			req.Header.Set("Accept", tt.accept)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if rec.Header().Get("Deprecation") != "" {
+				t.Errorf("Expected no Deprecation header, got %q", rec.Header().Get("Deprecation"))
+			}
+
+			var resp responder.EnvelopeV2
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("Failed to decode response %q: %v", rec.Body, err)
+			}
+			if resp.Meta.RequestID == "" || resp.Meta.RequestID != rec.Header().Get("X-Request-Id") {
+				t.Errorf("Expected the request ID in meta and headers, got %q and %q", resp.Meta.RequestID, rec.Header().Get("X-Request-Id"))
+			}
+			if string(resp.Data) != tt.wantData {
+				t.Errorf("Expected data %s, got %s", tt.wantData, resp.Data)
+			}
+			if tt.wantCode == "" {
+				if resp.Error != nil || resp.Meta.Message != tt.wantMessage {
+					t.Errorf("Expected no error and message %q, got %+v and %q", tt.wantMessage, resp.Error, resp.Meta.Message)
+				}
+
+				return
+			}
+			if resp.Error == nil || resp.Error.Code != tt.wantCode || resp.Error.Message != tt.wantMessage {
+				t.Errorf("Expected error %s: %q, got %+v", tt.wantCode, tt.wantMessage, resp.Error)
+			}
+		})
+	}
+}
+
+func TestVersionMiddlewareV1(t *testing.T) {
+	t.Parallel()
+
+	deprecation := time.Date(2026, time.October, 17, 0, 0, 0, 0, time.UTC)
+	sunset := time.Date(2027, time.June, 30, 0, 0, 0, 0, time.UTC)
+	handler := middleware.VersionMiddleware(middleware.Versioning{Deprecation: deprecation, Sunset: sunset}, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		responder.WriteSuccess(w, http.StatusOK, "done", "JFK")
+	}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/liveness", nil))
+
+	wantHeaders := map[string]string{
+		"Deprecation": "@1792195200",
+		"Sunset":      "Wed, 30 Jun 2027 00:00:00 GMT",
+		"Link":        `</api/v2/liveness>; rel="successor-version"`,
+		"Vary":        "Accept",
+	}
+	for name, want := range wantHeaders {
+		if got := rec.Header().Get(name); got != want {
+			t.Errorf("Expected %s %q, got %q", name, want, got)
+		}
+	}
+	if want := `{"data":"JFK","msg":"done"}` + "\n"; rec.Body.String() != want {
+		t.Errorf("Expected the v1 body %q, got %q", want, rec.Body.String())
+	}
+}
+
+func TestVersionMiddlewareETag(t *testing.T) {
+	t.Parallel()
+
+	handler := middleware.VersionMiddleware(middleware.Versioning{}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		responder.WriteSuccessWithETag(w, r, "abc", "JFK", nil)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v2/dispatcher/itinerary/abc", nil))
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || !strings.HasSuffix(etag, `-v2"`) {
+		t.Fatalf("Expected a v2 ETag, got %d with %q", rec.Code, etag)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v2/dispatcher/itinerary/abc", nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified || rec.Header().Get("ETag") != etag || rec.Body.Len() != 0 {
+		t.Errorf("Expected 304 with ETag %s, got %d with %q and body %q", etag, rec.Code, rec.Header().Get("ETag"), rec.Body)
+	}
+}
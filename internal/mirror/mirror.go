@@ -0,0 +1,245 @@
+package mirror
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/middleware"
+	"github.com/dsha256/dispatcher/pkg/apierror"
+)
+
+const (
+	// Header marks mirrored requests so the canary can tell them apart from live traffic.
+	Header = "X-Dispatcher-Mirror"
+
+	// maxLoggedBody bounds the size of the bodies included in diff logs.
+	maxLoggedBody = 2048
+)
+
+// Config configures request mirroring.
+type Config struct {
+	// CanaryURL is the base URL of the secondary deployment; mirroring is disabled when empty.
+	CanaryURL string
+	// Paths are the request paths to mirror.
+	Paths []string
+	// Percentage of matching requests to mirror, from 0 to 100.
+	Percentage float64
+	// Timeout bounds each mirrored request.
+	Timeout time.Duration
+	// MaxInFlight bounds the concurrently mirrored requests; extra requests are not mirrored.
+	MaxInFlight int
+}
+
+// Mirror copies a sample of requests to a canary deployment, fire-and-forget, without their credentials (see
+// credentialHeaders). Canary responses are discarded; differences from the primary response are logged.
+type Mirror struct {
+	client   *http.Client
+	logger   *slog.Logger
+	inFlight chan struct{}
+	paths    map[string]struct{}
+	cfg      Config
+}
+
+func New(logger *slog.Logger, cfg Config) *Mirror {
+	if cfg.MaxInFlight <= 0 {
+		cfg.MaxInFlight = 1
+	}
+
+	paths := make(map[string]struct{}, len(cfg.Paths))
+	for _, path := range cfg.Paths {
+		paths[path] = struct{}{}
+	}
+
+	return &Mirror{
+		client:   &http.Client{Timeout: cfg.Timeout},
+		logger:   logger,
+		inFlight: make(chan struct{}, cfg.MaxInFlight),
+		paths:    paths,
+		cfg:      cfg,
+	}
+}
+
+// Enabled reports whether any request can be mirrored.
+func (m *Mirror) Enabled() bool {
+	return m.cfg.CanaryURL != "" && m.cfg.Percentage > 0 && len(m.paths) > 0
+}
+
+// Middleware mirrors sampled requests after the primary response has been written.
+func (m *Mirror) Middleware(next http.Handler) http.Handler {
+	if !m.Enabled() {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := m.paths[r.URL.Path]; !ok || rand.Float64()*100 >= m.cfg.Percentage { //nolint:gosec // Sampling does not need a CSPRNG.
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		payload, err := io.ReadAll(r.Body)
+		if err != nil {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(payload))
+		// The headers as received, before the handlers rewrite any, e.g. the tenant.
+		header := r.Header.Clone()
+		for _, name := range credentialHeaders() {
+			header.Del(name)
+		}
+
+		rec := &recorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		select {
+		case m.inFlight <- struct{}{}:
+		default:
+			m.logger.Debug("Mirror skipped, too many requests in flight", "path", r.URL.Path)
+
+			return
+		}
+
+		go func() {
+			defer func() { <-m.inFlight }()
+			m.send(r.Method, r.URL.RequestURI(), header, payload, rec.response())
+		}()
+	})
+}
+
+// credentialHeaders are the headers of requests not mirrored: the canary must not be handed the clients'
+// credentials, nor a signature it would check against the credentials of its own clients.
+func credentialHeaders() []string {
+	return []string{"Authorization", "Cookie", middleware.SignatureHeader}
+}
+
+// response is the primary response a mirrored one is compared with.
+type response struct {
+	header http.Header
+	body   []byte
+	status int
+}
+
+func (m *Mirror) send(method, uri string, header http.Header, payload []byte, primary response) {
+	ctx, cancel := context.WithTimeout(context.Background(), m.cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(m.cfg.CanaryURL, "/")+uri, bytes.NewReader(payload))
+	if err != nil {
+		m.logger.Warn("Mirror request failed", "error", err, "uri", uri)
+
+		return
+	}
+	req.Header = header
+	req.Header.Set(Header, "1")
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		m.logger.Warn("Mirror request failed", "error", err, "uri", uri)
+
+		return
+	}
+	defer resp.Body.Close()
+
+	canaryBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		m.logger.Warn("Mirror response unreadable", "error", err, "uri", uri)
+
+		return
+	}
+
+	primaryCode, canaryCode := primary.header.Get(apierror.Header), resp.Header.Get(apierror.Header)
+	if resp.StatusCode == primary.status && primaryCode == canaryCode && sameJSON(primary.body, canaryBody) {
+		m.logger.Debug("Mirror response matches", "uri", uri, "status", resp.StatusCode)
+
+		return
+	}
+
+	m.logger.Warn("Mirror response differs",
+		"uri", uri,
+		"primary_status", primary.status,
+		"canary_status", resp.StatusCode,
+		"primary_error_code", primaryCode,
+		"canary_error_code", canaryCode,
+		"primary_body", truncate(primary.body),
+		"canary_body", truncate(canaryBody),
+	)
+}
+
+// sameJSON compares two bodies as JSON values, falling back to a byte comparison.
+func sameJSON(a, b []byte) bool {
+	var va, vb any
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return bytes.Equal(a, b)
+	}
+
+	na, errA := json.Marshal(va)
+	nb, errB := json.Marshal(vb)
+
+	return errA == nil && errB == nil && bytes.Equal(na, nb)
+}
+
+func truncate(body []byte) string {
+	if len(body) > maxLoggedBody {
+		return string(body[:maxLoggedBody]) + "..."
+	}
+
+	return string(body)
+}
+
+// recorder captures the primary response while still writing it to the client. The headers are those sent with
+// the status: a handler may change its header map afterwards, to no effect on the response.
+type recorder struct {
+	http.ResponseWriter
+	header  http.Header
+	body    bytes.Buffer
+	status  int
+	started bool
+}
+
+func (r *recorder) WriteHeader(status int) {
+	r.start(status)
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *recorder) Write(p []byte) (int, error) {
+	r.start(http.StatusOK)
+	r.body.Write(p)
+
+	return r.ResponseWriter.Write(p)
+}
+
+// Flush flushes the response, starting it if needed, so streamed responses still reach the client as they are
+// written.
+func (r *recorder) Flush() {
+	r.start(http.StatusOK)
+	_ = http.NewResponseController(r.ResponseWriter).Flush()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to extend its write deadline.
+func (r *recorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// start records the status and snapshots the headers of the response, once.
+func (r *recorder) start(status int) {
+	if r.started {
+		return
+	}
+	r.started, r.status, r.header = true, status, r.ResponseWriter.Header().Clone()
+}
+
+// response returns the recorded response; a handler that wrote nothing answered 200 OK.
+func (r *recorder) response() response {
+	r.start(http.StatusOK)
+
+	return response{header: r.header, body: r.body.Bytes(), status: r.status}
+}
@@ -0,0 +1,138 @@
+package mirror_test
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/logbuffer"
+	"github.com/dsha256/dispatcher/internal/mirror"
+	"github.com/dsha256/dispatcher/pkg/apierror"
+)
+
+func TestMirrorLogsDiffs(t *testing.T) {
+	t.Parallel()
+
+	received := make(chan string, 1)
+	canary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r.Header.Get(mirror.Header) + " " + string(body)
+		_, _ = w.Write([]byte(`{"data":{"linear_path":["B","A"]}}`))
+	}))
+	t.Cleanup(canary.Close)
+
+	logs := logbuffer.New(100)
+	logger := slog.New(slog.NewTextHandler(logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	m := mirror.New(logger, mirror.Config{
+		CanaryURL:   canary.URL,
+		Paths:       []string{"/itinerary"},
+		Percentage:  100,
+		Timeout:     time.Second,
+		MaxInFlight: 1,
+	})
+
+	primary := m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != `{"tickets":[]}` {
+			t.Errorf("primary received %q", body)
+		}
+		_, _ = w.Write([]byte(`{"data":{"linear_path":["A","B"]}}`))
+	}))
+
+	rec := httptest.NewRecorder()
+	primary.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/itinerary", bytes.NewBufferString(`{"tickets":[]}`)))
+
+	if got := rec.Body.String(); got != `{"data":{"linear_path":["A","B"]}}` {
+		t.Errorf("client received %q; want the primary response", got)
+	}
+
+	select {
+	case got := <-received:
+		if got != `1 {"tickets":[]}` {
+			t.Errorf("canary received %q", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("canary did not receive the mirrored request")
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if strings.Contains(strings.Join(logs.Lines(), "\n"), "Mirror response differs") {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("expected a diff to be logged, got %v", logs.Lines())
+}
+
+func TestMirrorRecorder(t *testing.T) {
+	t.Parallel()
+
+	received := make(chan http.Header, 1)
+	canary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Clone()
+		w.Header().Set(apierror.Header, "CYCLE")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"err":"cycle"}`))
+	}))
+	t.Cleanup(canary.Close)
+
+	logs := logbuffer.New(100)
+	m := mirror.New(slog.New(slog.NewTextHandler(logs, &slog.HandlerOptions{Level: slog.LevelDebug})), mirror.Config{
+		CanaryURL:  canary.URL,
+		Paths:      []string{"/itinerary"},
+		Percentage: 100,
+		Timeout:    time.Second,
+	})
+
+	primary := m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Header.Set("X-Tenant-Id", "rewritten")
+		w.Header().Set(apierror.Header, "CYCLE")
+		w.WriteHeader(http.StatusBadRequest)
+		// Changes to the headers once the response started are not part of it.
+		w.Header().Set(apierror.Header, "MALFORMED_TICKET")
+		if err := http.NewResponseController(w).Flush(); err != nil {
+			t.Errorf("Flush() error = %v", err)
+		}
+		_, _ = w.Write([]byte(`{"err":"cycle"}`))
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/itinerary", bytes.NewBufferString(`{"tickets":[]}`))
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("X-Signature", "v1=abc")
+	req.Header.Set("X-Tenant-Id", "acme")
+	rec := httptest.NewRecorder()
+	primary.ServeHTTP(rec, req)
+
+	if !rec.Flushed {
+		t.Error("Expected the flush to reach the client's writer")
+	}
+
+	select {
+	case header := <-received:
+		if header.Get("Authorization") != "" || header.Get("X-Signature") != "" {
+			t.Errorf("Expected the credentials stripped from the mirrored request, got %v", header)
+		}
+		if header.Get("X-Tenant-Id") != "acme" {
+			t.Errorf("Expected the headers as received, got X-Tenant-Id %q", header.Get("X-Tenant-Id"))
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("canary did not receive the mirrored request")
+	}
+
+	// The headers sent with the status are compared, so the responses match.
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if strings.Contains(strings.Join(logs.Lines(), "\n"), "Mirror response matches") {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("Expected the responses to match, got %v", logs.Lines())
+}
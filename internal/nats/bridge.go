@@ -0,0 +1,89 @@
+package nats
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strconv"
+)
+
+// StatusHeader carries the HTTP status of the response bridged into a reply.
+const StatusHeader = "X-Dispatcher-Status"
+
+// Bridge answers requests received over NATS with an HTTP handler: each message is served as a POST
+// to path, with the message data as body and the forwarded message headers as request headers, and the
+// response body is published to the reply subject with the response headers and its status in StatusHeader.
+// Messages without a reply subject are dropped, since nobody would read their answer.
+func Bridge(c *Client, next http.Handler, path string) Handler {
+	return func(ctx context.Context, msg Msg) {
+		if msg.Reply == "" {
+			return
+		}
+
+		r, err := http.NewRequestWithContext(ctx, http.MethodPost, path, bytes.NewReader(msg.Data))
+		if err != nil {
+			c.logger.ErrorContext(ctx, "error bridging nats request", "error", err, "subject", msg.Subject)
+
+			return
+		}
+		for key, values := range msg.Header {
+			if key = http.CanonicalHeaderKey(key); forwarded(key) {
+				r.Header[key] = values
+			}
+		}
+		if r.Header.Get("Content-Type") == "" {
+			r.Header.Set("Content-Type", "application/json")
+		}
+		r.RemoteAddr = "nats:" + msg.Subject
+
+		w := &response{header: http.Header{}}
+		next.ServeHTTP(w, r)
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		w.header.Set(StatusHeader, strconv.Itoa(w.status))
+
+		if err = c.Publish(msg.Reply, w.header, w.body.Bytes()); err != nil {
+			c.logger.ErrorContext(ctx, "error replying over nats", "error", err, "subject", msg.Subject)
+		}
+	}
+}
+
+// forwarded reports whether a message header is passed on as a request header. Only the headers describing
+// the request are: anyone able to publish on the subject could otherwise claim a tenant or client with
+// X-Tenant-Id or X-Client-Id, or pass on the signature headers of another request. Tenants still
+// authenticate with their API key in Authorization.
+func forwarded(key string) bool {
+	switch key {
+	case "Accept", "Accept-Language", "Authorization", "Content-Type", "Idempotency-Key", "If-None-Match",
+		"X-Dispatcher-Cache-Bypass", "X-Dispatcher-Strategy", "X-Priority", "X-Request-Id":
+		return true
+	default:
+		return false
+	}
+}
+
+// response records a response to be published as a reply.
+type response struct {
+	header http.Header
+	body   bytes.Buffer
+	status int
+}
+
+func (w *response) Header() http.Header {
+	return w.header
+}
+
+func (w *response) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	return w.body.Write(data)
+}
+
+func (w *response) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
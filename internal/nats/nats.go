@@ -0,0 +1,300 @@
+// Package nats connects the service to a NATS server with nats.go, covering what it needs to answer requests
+// over NATS: queue subscriptions, publishing replies with headers and PING for readiness. Connections are
+// re-established, and subscriptions renewed, until the client stops.
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+var (
+	ErrMissingAddr  = errors.New("nats address is required")
+	ErrNotConnected = errors.New("not connected to nats")
+	ErrTLSKeyPair   = errors.New("nats tls cert_file and key_file must be set together")
+)
+
+// Config addresses a NATS server.
+type Config struct {
+	// Addr is the host:port of the server, or its URL, e.g. tls://nats:4222.
+	Addr     string `json:"addr"     yaml:"addr"`
+	User     string `json:"user"     yaml:"user"`
+	Password string `json:"-"        yaml:"password"`
+	Token    string `json:"-"        yaml:"token"`
+	// CredentialsFile authenticates with the JWT and NKey seed of a .creds file, for decentralized auth.
+	CredentialsFile string `json:"credentials_file" yaml:"credentials_file"`
+	TLS             TLS    `json:"tls"              yaml:"tls"`
+	// ReconnectWait is the delay between connection attempts, 1s when 0.
+	ReconnectWait time.Duration `json:"reconnect_wait" yaml:"reconnect_wait"`
+	// MaxInFlight bounds the messages handled concurrently, 64 when 0; further messages wait in the
+	// subscription's pending buffer.
+	MaxInFlight int `json:"max_in_flight" yaml:"max_in_flight"`
+}
+
+// TLS secures the connection to the server.
+type TLS struct {
+	// CAFile verifies the server's certificate against its PEM CA certificates instead of the system's.
+	CAFile string `json:"ca_file" yaml:"ca_file"`
+	// CertFile and KeyFile are the PEM certificate and key of the client, for servers verifying them.
+	CertFile string `json:"cert_file" yaml:"cert_file"`
+	KeyFile  string `json:"key_file"  yaml:"key_file"`
+	// Enabled requires TLS even when the server does not ask for it. Setting any file also enables it.
+	Enabled bool `json:"enabled" yaml:"enabled"`
+}
+
+const (
+	defaultReconnectWait = time.Second
+	defaultMaxInFlight   = 64
+
+	// pingTimeout bounds a Ping whose context has no deadline.
+	pingTimeout = 5 * time.Second
+)
+
+// Msg is a message received on a subscription. NATS headers share the format of HTTP headers.
+type Msg struct {
+	Header  http.Header
+	Subject string
+	// Reply is the subject to answer on, empty when the sender expects no answer.
+	Reply string
+	Data  []byte
+}
+
+// Handler handles a message; ctx is canceled when the client stops.
+type Handler func(ctx context.Context, msg Msg)
+
+type subscription struct {
+	handle  Handler
+	subject string
+	queue   string
+}
+
+// Client receives messages from a NATS server and publishes to it. It is safe for concurrent use.
+type Client struct {
+	logger   *slog.Logger
+	ctx      context.Context //nolint:containedctx // Run's, handed to the handlers of the subscriptions made while running.
+	conn     *nats.Conn
+	inFlight chan struct{}
+	subs     []subscription
+	// active are the subscriptions of conn, unsubscribed when the client stops.
+	active   []*nats.Subscription
+	handlers sync.WaitGroup
+	cfg      Config
+	// mu guards ctx, conn, subs, active and stopped.
+	mu sync.Mutex
+	// stopped is set once Run's context is canceled, so that no more handlers start.
+	stopped bool
+}
+
+// New returns a client for the server. It connects once Run is called.
+func New(logger *slog.Logger, cfg Config) (*Client, error) {
+	if cfg.Addr == "" {
+		return nil, ErrMissingAddr
+	}
+	if (cfg.TLS.CertFile == "") != (cfg.TLS.KeyFile == "") {
+		return nil, ErrTLSKeyPair
+	}
+	if cfg.ReconnectWait <= 0 {
+		cfg.ReconnectWait = defaultReconnectWait
+	}
+	if cfg.MaxInFlight <= 0 {
+		cfg.MaxInFlight = defaultMaxInFlight
+	}
+
+	return &Client{
+		logger:   logger,
+		inFlight: make(chan struct{}, cfg.MaxInFlight),
+		cfg:      cfg,
+	}, nil
+}
+
+// Subscribe handles the messages published on subject. Subscribers sharing a non-empty queue each
+// receive a share of the messages instead of all of them.
+func (c *Client) Subscribe(subject, queue string, handle Handler) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	sub := subscription{handle: handle, subject: subject, queue: queue}
+	c.subs = append(c.subs, sub)
+	if c.conn == nil {
+		return nil
+	}
+
+	return c.subscribe(sub)
+}
+
+// Run connects to the server and dispatches the messages received to their handlers, reconnecting
+// whenever the connection is lost, until ctx is canceled. It returns once the handlers have returned.
+func (c *Client) Run(ctx context.Context) {
+	conn, err := nats.Connect(c.cfg.Addr, c.options(ctx)...)
+	if err != nil {
+		c.logger.ErrorContext(ctx, "Failed to connect to nats", "error", err, "addr", c.cfg.Addr)
+
+		return
+	}
+
+	c.mu.Lock()
+	c.ctx, c.conn = ctx, conn
+	for _, sub := range c.subs {
+		if err = c.subscribe(sub); err != nil {
+			c.logger.ErrorContext(ctx, "Failed to subscribe to nats", "error", err, "subject", sub.subject)
+		}
+	}
+	c.mu.Unlock()
+
+	<-ctx.Done()
+
+	c.mu.Lock()
+	c.stopped = true
+	for _, sub := range c.active {
+		_ = sub.Unsubscribe()
+	}
+	c.mu.Unlock()
+
+	// The replies of the handlers still running are flushed before the connection is closed.
+	c.handlers.Wait()
+	_ = conn.FlushTimeout(pingTimeout)
+	conn.Close()
+}
+
+// Publish publishes data with the headers, if any, on subject.
+func (c *Client) Publish(subject string, header http.Header, data []byte) error {
+	conn, err := c.connection()
+	if err != nil {
+		return err
+	}
+
+	msg := &nats.Msg{Subject: subject, Data: data}
+	if len(header) > 0 {
+		msg.Header = nats.Header(header)
+	}
+	if err = conn.PublishMsg(msg); err != nil {
+		return fmt.Errorf("publishing to nats: %w", err)
+	}
+
+	return nil
+}
+
+// Ping checks that the client is connected and the server answering.
+func (c *Client) Ping(ctx context.Context) error {
+	conn, err := c.connection()
+	if err != nil {
+		return err
+	}
+	if !conn.IsConnected() {
+		return ErrNotConnected
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, pingTimeout)
+		defer cancel()
+	}
+	if err = conn.FlushWithContext(ctx); err != nil {
+		return fmt.Errorf("waiting for nats pong: %w", err)
+	}
+
+	return nil
+}
+
+func (c *Client) connection() (*nats.Conn, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		return nil, ErrNotConnected
+	}
+
+	return c.conn, nil
+}
+
+// options configure the connection: authentication, TLS, endless reconnection, and logging of the changes of
+// its state. The first connection is retried like the next ones.
+func (c *Client) options(ctx context.Context) []nats.Option {
+	opts := []nats.Option{
+		nats.Name("dispatcher"),
+		nats.RetryOnFailedConnect(true),
+		nats.MaxReconnects(-1),
+		nats.ReconnectWait(c.cfg.ReconnectWait),
+		nats.ConnectHandler(func(conn *nats.Conn) {
+			c.logger.InfoContext(ctx, "Connected to nats", "addr", conn.ConnectedUrlRedacted())
+		}),
+		nats.ReconnectHandler(func(conn *nats.Conn) {
+			c.logger.InfoContext(ctx, "Connected to nats", "addr", conn.ConnectedUrlRedacted())
+		}),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			if ctx.Err() == nil {
+				c.logger.ErrorContext(ctx, "Lost nats connection", "error", err, "addr", c.cfg.Addr)
+			}
+		}),
+		nats.ErrorHandler(func(_ *nats.Conn, sub *nats.Subscription, err error) {
+			attrs := []any{"error", err}
+			if sub != nil {
+				attrs = append(attrs, "subject", sub.Subject)
+			}
+			c.logger.ErrorContext(ctx, "Nats error", attrs...)
+		}),
+	}
+
+	if c.cfg.User != "" {
+		opts = append(opts, nats.UserInfo(c.cfg.User, c.cfg.Password))
+	}
+	if c.cfg.Token != "" {
+		opts = append(opts, nats.Token(c.cfg.Token))
+	}
+	if c.cfg.CredentialsFile != "" {
+		opts = append(opts, nats.UserCredentials(c.cfg.CredentialsFile))
+	}
+
+	tlsCfg := c.cfg.TLS
+	if tlsCfg.Enabled || tlsCfg.CAFile != "" || tlsCfg.CertFile != "" {
+		opts = append(opts, nats.Secure())
+	}
+	if tlsCfg.CAFile != "" {
+		opts = append(opts, nats.RootCAs(tlsCfg.CAFile))
+	}
+	if tlsCfg.CertFile != "" {
+		opts = append(opts, nats.ClientCert(tlsCfg.CertFile, tlsCfg.KeyFile))
+	}
+
+	return opts
+}
+
+// subscribe subscribes to the subscription's subject on the connection; c.mu must be held. The messages are
+// handed to the handler in goroutines, once fewer than MaxInFlight messages are being handled.
+func (c *Client) subscribe(sub subscription) error {
+	ctx := c.ctx
+	active, err := c.conn.QueueSubscribe(sub.subject, sub.queue, func(msg *nats.Msg) {
+		c.inFlight <- struct{}{}
+
+		c.mu.Lock()
+		if c.stopped {
+			c.mu.Unlock()
+			<-c.inFlight
+
+			return
+		}
+		c.handlers.Add(1)
+		c.mu.Unlock()
+
+		go func() {
+			defer func() {
+				<-c.inFlight
+				c.handlers.Done()
+			}()
+			sub.handle(ctx, Msg{Header: http.Header(msg.Header), Subject: msg.Subject, Reply: msg.Reply, Data: msg.Data})
+		}()
+	})
+	if err != nil {
+		return fmt.Errorf("subscribing to %q: %w", sub.subject, err)
+	}
+	c.active = append(c.active, active)
+
+	return nil
+}
@@ -0,0 +1,190 @@
+package nats_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/nats"
+)
+
+// published is a message published to the fake server.
+type published struct {
+	subject string
+	header  string
+	data    string
+}
+
+// fakeServer speaks enough of the NATS protocol to accept a client: it completes the handshake, reports
+// subscriptions on subs and messages published on pubs, and sends what is written to send.
+type fakeServer struct {
+	listener net.Listener
+	subs     chan string
+	pubs     chan published
+	send     chan string
+}
+
+func startFakeServer(t *testing.T) *fakeServer {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	s := &fakeServer{listener: listener, subs: make(chan string, 8), pubs: make(chan published, 8), send: make(chan string, 8)}
+	t.Cleanup(func() {
+		listener.Close()
+	})
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+
+	return s
+}
+
+func (s *fakeServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	fmt.Fprint(conn, "INFO {\"server_id\":\"fake\",\"headers\":true,\"max_payload\":1048576}\r\n")
+	go func() {
+		for msg := range s.send {
+			fmt.Fprint(conn, msg)
+		}
+	}()
+
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "PING":
+			fmt.Fprint(conn, "PONG\r\n")
+		case "SUB":
+			s.subs <- strings.Join(fields[1:], " ")
+		case "PUB", "HPUB":
+			headerSize, _ := strconv.Atoi(fields[len(fields)-2])
+			if fields[0] == "PUB" {
+				headerSize = 0
+			}
+			total, _ := strconv.Atoi(fields[len(fields)-1])
+			payload := make([]byte, total+2)
+			if _, err = io.ReadFull(reader, payload); err != nil {
+				return
+			}
+			s.pubs <- published{subject: fields[1], header: string(payload[:headerSize]), data: string(payload[headerSize:total])}
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		cfg     nats.Config
+		wantErr error
+	}{
+		{name: "valid", cfg: nats.Config{Addr: "localhost:4222"}},
+		{name: "without address", wantErr: nats.ErrMissingAddr},
+		{
+			name:    "certificate without key",
+			cfg:     nats.Config{Addr: "tls://localhost:4222", TLS: nats.TLS{CertFile: "client.pem"}},
+			wantErr: nats.ErrTLSKeyPair,
+		},
+		{
+			name: "mutual TLS",
+			cfg:  nats.Config{Addr: "tls://localhost:4222", TLS: nats.TLS{CertFile: "client.pem", KeyFile: "client-key.pem"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := nats.New(slog.New(slog.DiscardHandler), tt.cfg); !errors.Is(err, tt.wantErr) {
+				t.Errorf("New() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBridge(t *testing.T) {
+	t.Parallel()
+
+	server := startFakeServer(t)
+	client, err := nats.New(slog.New(slog.DiscardHandler), nats.Config{Addr: server.listener.Addr().String()})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err = client.Ping(ctx); !errors.Is(err, nats.ErrNotConnected) {
+		t.Errorf("Ping() before Run() error = %v, want %v", err, nats.ErrNotConnected)
+	}
+
+	routes := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Path", r.Method+" "+r.URL.Path)
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		fmt.Fprintf(w, "%s for %q as %q", body, r.Header.Get("X-Tenant-Id"), r.Header.Get("X-Request-Id"))
+	})
+	if err = client.Subscribe("dispatcher.itinerary", "dispatcher", nats.Bridge(client, routes, "/api/v1/dispatcher/itinerary")); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		client.Run(ctx)
+	}()
+
+	if sub := <-server.subs; sub != "dispatcher.itinerary dispatcher 1" {
+		t.Errorf("SUB %q, want the subject and queue", sub)
+	}
+	if err = client.Ping(ctx); err != nil {
+		t.Errorf("Ping() error = %v", err)
+	}
+
+	headers := "NATS/1.0\r\nX-Tenant-Id: acme\r\nX-Client-Id: acme-client\r\nx-request-id: req-1\r\n\r\n"
+	server.send <- "MSG dispatcher.itinerary 1 6\r\nignore\r\n"
+	server.send <- fmt.Sprintf("HMSG dispatcher.itinerary 1 _INBOX.reply %d %d\r\n%s[]\r\n", len(headers), len(headers)+2, headers)
+
+	// The message without a reply subject is dropped, so the only reply is to the second one. Its tenant is
+	// claimed by a header that is not forwarded, unlike its request ID.
+	reply := <-server.pubs
+	if want := `[] for "" as "req-1"`; reply.subject != "_INBOX.reply" || reply.data != want {
+		t.Errorf("reply %+v, want %q on the reply subject", reply, want)
+	}
+	for _, want := range []string{"X-Path: POST /api/v1/dispatcher/itinerary\r\n", nats.StatusHeader + ": 422\r\n"} {
+		if !strings.HasPrefix(reply.header, "NATS/1.0\r\n") || !strings.Contains(reply.header, want) {
+			t.Errorf("reply headers %q, want %q", reply.header, want)
+		}
+	}
+
+	cancel()
+	<-done
+}
@@ -0,0 +1,118 @@
+package pricing
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+var ErrInvalidRates = errors.New("invalid exchange rates")
+
+// Rates convert prices to a single currency: Rates is the value of one unit of each currency in Currency,
+// e.g. {"EUR": 1.08, "GBP": 1.27} with Currency "USD".
+type Rates struct {
+	Rates    map[string]float64 `json:"rates"    yaml:"rates"`
+	Currency string             `json:"currency" yaml:"currency"`
+}
+
+// Validate checks that the rates name their currency and are positive, with a single rate per currency.
+func (r *Rates) Validate() error {
+	if r == nil {
+		return nil
+	}
+	if normalize(r.Currency) == "" {
+		return fmt.Errorf("%w: currency is required", ErrInvalidRates)
+	}
+	seen := make(map[string]bool, len(r.Rates))
+	for currency, rate := range r.Rates {
+		if !(rate > 0) || math.IsInf(rate, 0) {
+			return fmt.Errorf("%w: rate of %s must be positive, got %v", ErrInvalidRates, currency, rate)
+		}
+		if seen[normalize(currency)] {
+			return fmt.Errorf("%w: %s has several rates", ErrInvalidRates, normalize(currency))
+		}
+		seen[normalize(currency)] = true
+	}
+
+	return nil
+}
+
+// rate returns the value of one unit of the currency in the currency of the rates.
+func (r *Rates) rate(currency string) (float64, bool) {
+	if currency == normalize(r.Currency) {
+		return 1, true
+	}
+	for code, rate := range r.Rates {
+		if normalize(code) == currency {
+			return rate, true
+		}
+	}
+
+	return 0, false
+}
+
+// Price is the price of a ticket. Currency is an ISO 4217 code, empty when unknown.
+type Price struct {
+	Currency string
+	Amount   float64
+}
+
+// Amount is a sum of prices in a currency.
+type Amount struct {
+	Currency string  `json:"currency,omitempty"`
+	Amount   float64 `json:"amount"`
+}
+
+// Summary is the total of the prices in each currency and, with exchange rates, their grand total.
+type Summary struct {
+	// GrandTotal converts every total with a rate to the currency of the rates, nil without rates.
+	GrandTotal *Amount `json:"grand_total,omitempty"`
+	// Totals are sorted by currency; prices without a currency are totaled without one.
+	Totals []Amount `json:"totals"`
+	// MissingRates lists the currencies left out of the grand total for lack of a rate, "" for prices without one.
+	MissingRates []string `json:"missing_rates,omitempty"`
+}
+
+// Summarize totals the prices by currency, case-insensitively, and converts the totals with the rates, if any.
+// Amounts are rounded to cents.
+func Summarize(prices []Price, rates *Rates) *Summary {
+	byCurrency := make(map[string]float64)
+	for _, price := range prices {
+		byCurrency[normalize(price.Currency)] += price.Amount
+	}
+
+	summary := &Summary{Totals: make([]Amount, 0, len(byCurrency))}
+	for currency, total := range byCurrency {
+		summary.Totals = append(summary.Totals, Amount{Currency: currency, Amount: round(total)})
+	}
+	sort.Slice(summary.Totals, func(i, j int) bool {
+		return summary.Totals[i].Currency < summary.Totals[j].Currency
+	})
+	if rates == nil {
+		return summary
+	}
+
+	grandTotal := 0.0
+	for _, total := range summary.Totals {
+		rate, ok := rates.rate(total.Currency)
+		if !ok || total.Currency == "" {
+			summary.MissingRates = append(summary.MissingRates, total.Currency)
+
+			continue
+		}
+		grandTotal += byCurrency[total.Currency] * rate
+	}
+	summary.GrandTotal = &Amount{Currency: normalize(rates.Currency), Amount: round(grandTotal)}
+
+	return summary
+}
+
+func normalize(currency string) string {
+	return strings.ToUpper(strings.TrimSpace(currency))
+}
+
+func round(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,86 @@
+package pricing_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/dsha256/dispatcher/internal/pricing"
+)
+
+func TestSummarize(t *testing.T) {
+	t.Parallel()
+
+	prices := []pricing.Price{
+		{Currency: "USD", Amount: 200},
+		{Currency: "eur", Amount: 100.1},
+		{Currency: "EUR", Amount: 100.2},
+		{Amount: 50},
+	}
+	totals := []pricing.Amount{{Amount: 50}, {Currency: "EUR", Amount: 200.3}, {Currency: "USD", Amount: 200}}
+
+	tests := []struct {
+		rates    *pricing.Rates
+		expected *pricing.Summary
+		name     string
+	}{
+		{
+			name:     "Without rates",
+			expected: &pricing.Summary{Totals: totals},
+		},
+		{
+			name:  "Converted",
+			rates: &pricing.Rates{Currency: "usd", Rates: map[string]float64{"EUR": 1.1}},
+			expected: &pricing.Summary{
+				Totals:       totals,
+				GrandTotal:   &pricing.Amount{Currency: "USD", Amount: 420.33},
+				MissingRates: []string{""},
+			},
+		},
+		{
+			name:  "Missing rate",
+			rates: &pricing.Rates{Currency: "EUR", Rates: map[string]float64{"GBP": 1.2}},
+			expected: &pricing.Summary{
+				Totals:       totals,
+				GrandTotal:   &pricing.Amount{Currency: "EUR", Amount: 200.3},
+				MissingRates: []string{"", "USD"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if summary := pricing.Summarize(prices, tt.rates); !reflect.DeepEqual(summary, tt.expected) {
+				t.Errorf("Summarize() = %+v, want %+v", summary, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRatesValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		rates *pricing.Rates
+		err   error
+		name  string
+	}{
+		{name: "None"},
+		{name: "Valid", rates: &pricing.Rates{Currency: "USD", Rates: map[string]float64{"EUR": 1.08}}},
+		{name: "Missing currency", rates: &pricing.Rates{Rates: map[string]float64{"EUR": 1.08}}, err: pricing.ErrInvalidRates},
+		{name: "Duplicate rate", rates: &pricing.Rates{Currency: "USD", Rates: map[string]float64{"EUR": 1.08, "eur": 1.1}}, err: pricing.ErrInvalidRates},
+		{name: "Zero rate", rates: &pricing.Rates{Currency: "USD", Rates: map[string]float64{"EUR": 0}}, err: pricing.ErrInvalidRates},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if err := tt.rates.Validate(); !errors.Is(err, tt.err) {
+				t.Errorf("Validate() error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,255 @@
+// Package remote fetches the ticket files requests reference by URL instead of carrying, so large files do not
+// go through the API gateway: S3 objects (s3://bucket/key), Google Cloud Storage objects (gs://bucket/key) and
+// plain http(s) URLs. Only allow-listed schemes and hosts are fetched, and files are capped in size.
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/clock"
+	"github.com/dsha256/dispatcher/internal/limits"
+)
+
+var (
+	ErrNotAllowed    = errors.New("source URL is not allowed")
+	ErrFetch         = errors.New("fetching source failed")
+	ErrUnknownScheme = errors.New("unknown source URL scheme")
+	ErrNoHosts       = errors.New("remote sources need allowed hosts")
+	ErrInvalidSize   = errors.New("remote max_bytes must not be negative")
+)
+
+// URL schemes of sources.
+const (
+	SchemeS3    = "s3"
+	SchemeGCS   = "gs"
+	SchemeHTTPS = "https"
+	SchemeHTTP  = "http"
+)
+
+const (
+	defaultMaxBytes    = 100 << 20
+	defaultTimeout     = 30 * time.Second
+	defaultS3Region    = "us-east-1"
+	defaultGCSEndpoint = "https://storage.googleapis.com"
+	// gcsRegion is the region of signatures of the Cloud Storage XML API.
+	gcsRegion = "auto"
+	// maxRedirects is the number of redirects of http(s) sources followed, each to an allowed host.
+	maxRedirects = 5
+)
+
+type Config struct {
+	S3  S3  `json:"s3"  yaml:"s3"`
+	GCS GCS `json:"gcs" yaml:"gcs"`
+	// Hosts allow-lists the hosts of http(s) sources and the buckets of s3 and gs sources; "*.example.com"
+	// matches the subdomains of example.com and "*" any host. Nothing is fetched from other hosts.
+	Hosts []string `json:"hosts" yaml:"hosts"`
+	// Schemes allow-lists the URL schemes of sources among s3, gs, https and http; s3, gs and https when empty.
+	Schemes []string `json:"schemes" yaml:"schemes"`
+	// MaxBytes caps the size of fetched files, 100 MiB when 0.
+	MaxBytes int64 `json:"max_bytes" yaml:"max_bytes"`
+	// Timeout bounds every fetch, 30s when 0.
+	Timeout time.Duration `json:"timeout" yaml:"timeout"`
+	Enabled bool          `json:"enabled" yaml:"enabled"`
+}
+
+// S3 locates and authenticates S3 objects; objects are fetched anonymously without an access key.
+type S3 struct {
+	// Region is the region of the buckets, us-east-1 when empty.
+	Region string `json:"region" yaml:"region"`
+	// Endpoint is the S3 API objects are fetched from with path-style URLs, e.g. of MinIO;
+	// https://s3.<region>.amazonaws.com when empty.
+	Endpoint        string `json:"endpoint" yaml:"endpoint"`
+	AccessKeyID     string `json:"-"        yaml:"access_key_id"`
+	SecretAccessKey string `json:"-"        yaml:"secret_access_key"`
+	// SessionToken is the token of temporary credentials.
+	SessionToken string `json:"-" yaml:"session_token"`
+}
+
+// GCS locates and authenticates Cloud Storage objects, through its XML API with HMAC keys; objects are fetched
+// anonymously without an access key.
+type GCS struct {
+	// Endpoint is the XML API, https://storage.googleapis.com when empty.
+	Endpoint    string `json:"endpoint" yaml:"endpoint"`
+	AccessKeyID string `json:"-"        yaml:"access_key_id"`
+	Secret      string `json:"-"        yaml:"secret"`
+}
+
+// File is a fetched ticket file.
+type File struct {
+	// Name is the base name of the URL's path, e.g. "tickets.csv".
+	Name string
+	// ContentType is the Content-Type it was served with.
+	ContentType string
+	Data        []byte
+}
+
+// Fetcher fetches sources. It is safe for concurrent use.
+type Fetcher struct {
+	clock  clock.Clock
+	client *http.Client
+	cfg    Config
+}
+
+// New returns the fetcher of the configuration, nil when remote sources are disabled.
+func New(clk clock.Clock, cfg Config) (*Fetcher, error) {
+	if !cfg.Enabled {
+		return nil, nil //nolint:nilnil // Remote sources are disabled.
+	}
+	if len(cfg.Hosts) == 0 {
+		return nil, ErrNoHosts
+	}
+	if cfg.MaxBytes < 0 {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidSize, cfg.MaxBytes)
+	}
+	if len(cfg.Schemes) == 0 {
+		cfg.Schemes = []string{SchemeS3, SchemeGCS, SchemeHTTPS}
+	}
+	for _, scheme := range cfg.Schemes {
+		if !slices.Contains([]string{SchemeS3, SchemeGCS, SchemeHTTPS, SchemeHTTP}, scheme) {
+			return nil, fmt.Errorf("%w %q", ErrUnknownScheme, scheme)
+		}
+	}
+	if cfg.MaxBytes == 0 {
+		cfg.MaxBytes = defaultMaxBytes
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.S3.Region == "" {
+		cfg.S3.Region = defaultS3Region
+	}
+	if cfg.S3.Endpoint == "" {
+		cfg.S3.Endpoint = "https://s3." + cfg.S3.Region + ".amazonaws.com"
+	}
+	if cfg.GCS.Endpoint == "" {
+		cfg.GCS.Endpoint = defaultGCSEndpoint
+	}
+
+	f := &Fetcher{clock: clk, cfg: cfg}
+	f.client = &http.Client{Timeout: cfg.Timeout, CheckRedirect: f.checkRedirect}
+
+	return f, nil
+}
+
+// Fetch fetches the file of the source URL. URLs of schemes or hosts that are not allowed fail with
+// ErrNotAllowed, files above the size cap with a *limits.BodyError and failed requests with ErrFetch.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (File, error) {
+	source, err := url.Parse(rawURL)
+	if err != nil {
+		return File{}, fmt.Errorf("%w: %w", ErrNotAllowed, err)
+	}
+	if !slices.Contains(f.cfg.Schemes, source.Scheme) {
+		return File{}, fmt.Errorf("%w: scheme %q", ErrNotAllowed, source.Scheme)
+	}
+	if !f.allowed(source.Hostname()) {
+		return File{}, fmt.Errorf("%w: host %q", ErrNotAllowed, source.Hostname())
+	}
+
+	req, err := f.newRequest(ctx, source)
+	if err != nil {
+		return File{}, err
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return File{}, fmt.Errorf("%w: %w", ErrFetch, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return File{}, fmt.Errorf("%w: %s answered %s", ErrFetch, source.Redacted(), resp.Status)
+	}
+	if resp.ContentLength > f.cfg.MaxBytes {
+		return File{}, &limits.BodyError{MaxBodyBytes: f.cfg.MaxBytes}
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, f.cfg.MaxBytes+1))
+	if err != nil {
+		return File{}, fmt.Errorf("%w: %w", ErrFetch, err)
+	}
+	if int64(len(data)) > f.cfg.MaxBytes {
+		return File{}, &limits.BodyError{MaxBodyBytes: f.cfg.MaxBytes}
+	}
+
+	return File{Name: path.Base(source.Path), ContentType: resp.Header.Get("Content-Type"), Data: data}, nil
+}
+
+// newRequest returns the request fetching the source: for s3 and gs URLs, of the object from the storage's
+// API, signed when an access key is configured.
+func (f *Fetcher) newRequest(ctx context.Context, source *url.URL) (*http.Request, error) {
+	var (
+		endpoint string
+		creds    Credentials
+		region   string
+	)
+	switch source.Scheme {
+	case SchemeS3:
+		endpoint, region = f.cfg.S3.Endpoint, f.cfg.S3.Region
+		creds = Credentials{
+			AccessKeyID:     f.cfg.S3.AccessKeyID,
+			SecretAccessKey: f.cfg.S3.SecretAccessKey,
+			SessionToken:    f.cfg.S3.SessionToken,
+		}
+	case SchemeGCS:
+		endpoint, region = f.cfg.GCS.Endpoint, gcsRegion
+		creds = Credentials{AccessKeyID: f.cfg.GCS.AccessKeyID, SecretAccessKey: f.cfg.GCS.Secret}
+	default:
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, source.String(), nil)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrFetch, err)
+		}
+
+		return req, nil
+	}
+
+	key := strings.TrimPrefix(source.Path, "/")
+	if key == "" {
+		return nil, fmt.Errorf("%w: %s names no object", ErrNotAllowed, source.Redacted())
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(endpoint, "/"), nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFetch, err)
+	}
+	req.URL.Path += "/" + source.Host + "/" + key
+	req.URL.RawPath = escapePath(req.URL.Path)
+	if creds.AccessKeyID != "" {
+		Sign(req, creds, region, "s3", f.clock.Now())
+	}
+
+	return req, nil
+}
+
+// checkRedirect follows redirects of http(s) sources to allowed schemes and hosts only.
+func (f *Fetcher) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("%w: %d redirects", ErrFetch, len(via))
+	}
+	if !slices.Contains(f.cfg.Schemes, req.URL.Scheme) || !f.allowed(req.URL.Hostname()) {
+		return fmt.Errorf("%w: redirect to %s", ErrNotAllowed, req.URL.Redacted())
+	}
+
+	return nil
+}
+
+// allowed reports whether the host, or bucket, is allow-listed.
+func (f *Fetcher) allowed(host string) bool {
+	host = strings.ToLower(host)
+	for _, pattern := range f.cfg.Hosts {
+		pattern = strings.ToLower(pattern)
+		if suffix, ok := strings.CutPrefix(pattern, "*"); ok && strings.HasSuffix(host, suffix) {
+			return true
+		}
+		if host == pattern {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,103 @@
+// Package reporting sends the panics recovered while serving requests to an error tracker, Sentry or
+// Rollbar, through their HTTP APIs.
+package reporting
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/middleware"
+)
+
+var (
+	ErrUnknownTracker = errors.New("unknown error tracker")
+	ErrInvalidDSN     = errors.New("invalid sentry DSN")
+	ErrMissingToken   = errors.New("rollbar access token is required")
+	ErrRejected       = errors.New("error tracker rejected the report")
+)
+
+// Error trackers.
+const (
+	TrackerSentry  = "sentry"
+	TrackerRollbar = "rollbar"
+)
+
+// defaultTimeout bounds every report when the configuration sets no timeout.
+const defaultTimeout = 5 * time.Second
+
+// Config selects the error tracker panics are reported to.
+type Config struct {
+	// Tracker is TrackerSentry or TrackerRollbar; panics are only logged when empty.
+	Tracker string `json:"tracker" yaml:"tracker"`
+	// SentryDSN is the DSN of the Sentry project, e.g. https://<key>@o0.ingest.sentry.io/<project>.
+	SentryDSN string `json:"-" yaml:"sentry_dsn"`
+	// RollbarToken is a post_server_item access token of the Rollbar project.
+	RollbarToken string `json:"-" yaml:"rollbar_token"`
+	// RollbarEndpoint is the Rollbar item API, https://api.rollbar.com/api/1/item/ when empty.
+	RollbarEndpoint string `json:"rollbar_endpoint" yaml:"rollbar_endpoint"`
+	// Environment tags the reports, e.g. "production".
+	Environment string `json:"environment" yaml:"environment"`
+	// Timeout bounds every report, 5s when 0.
+	Timeout time.Duration `json:"timeout" yaml:"timeout"`
+}
+
+// New returns the reporter of the configured tracker, nil when none is.
+func New(cfg Config) (middleware.PanicReporter, error) {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	client := &http.Client{Timeout: cfg.Timeout}
+
+	switch cfg.Tracker {
+	case "":
+		return nil, nil //nolint:nilnil // No tracker is not an error.
+	case TrackerSentry:
+		return NewSentry(client, cfg.SentryDSN, cfg.Environment)
+	case TrackerRollbar:
+		return NewRollbar(client, cfg.RollbarEndpoint, cfg.RollbarToken, cfg.Environment)
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnknownTracker, cfg.Tracker)
+	}
+}
+
+// post sends the report as JSON with the headers, and fails unless the tracker accepts it.
+func post(ctx context.Context, client *http.Client, url string, header http.Header, report any) error {
+	body, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("encoding report: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating report request: %w", err)
+	}
+	req.Header = header
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending report: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("%w: %s", ErrRejected, resp.Status)
+	}
+
+	return nil
+}
+
+// eventID returns a random 32 hex digit ID, the form of Sentry event IDs.
+func eventID() string {
+	var id [16]byte
+	_, _ = rand.Read(id[:])
+
+	return hex.EncodeToString(id[:])
+}
@@ -0,0 +1,126 @@
+package reporting_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/middleware"
+	"github.com/dsha256/dispatcher/internal/reporting"
+)
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		wantErr error
+		name    string
+		cfg     reporting.Config
+		wantNil bool
+	}{
+		{name: "None", cfg: reporting.Config{}, wantNil: true},
+		{name: "Sentry", cfg: reporting.Config{Tracker: reporting.TrackerSentry, SentryDSN: "https://key@sentry.example.com/42"}},
+		{name: "Sentry without a key", cfg: reporting.Config{Tracker: reporting.TrackerSentry, SentryDSN: "https://sentry.example.com/42"}, wantErr: reporting.ErrInvalidDSN},
+		{name: "Rollbar", cfg: reporting.Config{Tracker: reporting.TrackerRollbar, RollbarToken: "token"}},
+		{name: "Rollbar without a token", cfg: reporting.Config{Tracker: reporting.TrackerRollbar}, wantErr: reporting.ErrMissingToken},
+		{name: "Unknown", cfg: reporting.Config{Tracker: "bugsnag"}, wantErr: reporting.ErrUnknownTracker},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			reporter, err := reporting.New(tt.cfg)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if err == nil && (reporter == nil) != tt.wantNil {
+				t.Errorf("Expected nil reporter %t, got %v", tt.wantNil, reporter)
+			}
+		})
+	}
+}
+
+func TestReportPanic(t *testing.T) {
+	t.Parallel()
+
+	p := middleware.Panic{
+		At:     time.Date(2025, 5, 1, 8, 0, 0, 0, time.UTC),
+		Value:  "boom",
+		ID:     "req-1",
+		Method: http.MethodPost,
+		Path:   "/api/v1/dispatcher/itinerary",
+		Stack:  "goroutine 1 [running]:",
+	}
+
+	tests := []struct {
+		newReporter func(url string) (middleware.PanicReporter, error)
+		name        string
+		wantPath    string
+		wantHeader  string
+		wantAuth    string
+		wantBody    []string
+	}{
+		{
+			name: "Sentry",
+			newReporter: func(url string) (middleware.PanicReporter, error) {
+				return reporting.NewSentry(http.DefaultClient, strings.Replace(url, "http://", "http://key@", 1)+"/42", "test")
+			},
+			wantPath:   "/api/42/store/",
+			wantHeader: "X-Sentry-Auth",
+			wantAuth:   "sentry_key=key",
+			wantBody:   []string{`"message":"panic: boom"`, `"panic_id":"req-1"`, `"stack":"goroutine 1 [running]:"`},
+		},
+		{
+			name: "Rollbar",
+			newReporter: func(url string) (middleware.PanicReporter, error) {
+				return reporting.NewRollbar(http.DefaultClient, url+"/api/1/item/", "token", "test")
+			},
+			wantPath:   "/api/1/item/",
+			wantHeader: "X-Rollbar-Access-Token",
+			wantAuth:   "token",
+			wantBody:   []string{`"body":"panic: boom"`, `"panic_id":"req-1"`, `"level":"critical"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			received := make(chan *http.Request, 1)
+			bodies := make(chan string, 1)
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var body json.RawMessage
+				_ = json.NewDecoder(r.Body).Decode(&body)
+				received <- r
+				bodies <- string(body)
+				w.WriteHeader(http.StatusOK)
+			}))
+			t.Cleanup(srv.Close)
+
+			reporter, err := tt.newReporter(srv.URL)
+			if err != nil {
+				t.Fatalf("Failed to create reporter: %v", err)
+			}
+			if err = reporter.ReportPanic(context.Background(), p); err != nil {
+				t.Fatalf("Failed to report panic: %v", err)
+			}
+
+			r, body := <-received, <-bodies
+			if r.URL.Path != tt.wantPath {
+				t.Errorf("Expected path %s, got %s", tt.wantPath, r.URL.Path)
+			}
+			if !strings.Contains(r.Header.Get(tt.wantHeader), tt.wantAuth) {
+				t.Errorf("Expected %s with %q, got %q", tt.wantHeader, tt.wantAuth, r.Header.Get(tt.wantHeader))
+			}
+			for _, want := range tt.wantBody {
+				if !strings.Contains(body, want) {
+					t.Errorf("Expected the report to contain %s, got %s", want, body)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package reporting
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/dsha256/dispatcher/internal/middleware"
+)
+
+// rollbarEndpoint is the item API of Rollbar.
+const rollbarEndpoint = "https://api.rollbar.com/api/1/item/"
+
+// Rollbar reports panics to a Rollbar project through its item API.
+type Rollbar struct {
+	client      *http.Client
+	endpoint    string
+	token       string
+	environment string
+}
+
+// NewRollbar returns a reporter to the project of the access token, through the endpoint or Rollbar's
+// own when empty.
+func NewRollbar(client *http.Client, endpoint, token, environment string) (*Rollbar, error) {
+	if token == "" {
+		return nil, ErrMissingToken
+	}
+	if endpoint == "" {
+		endpoint = rollbarEndpoint
+	}
+
+	return &Rollbar{client: client, endpoint: endpoint, token: token, environment: environment}, nil
+}
+
+type rollbarItem struct {
+	Data rollbarData `json:"data"`
+}
+
+type rollbarData struct {
+	Custom      map[string]string `json:"custom"`
+	Request     rollbarRequest    `json:"request"`
+	Body        rollbarBody       `json:"body"`
+	Environment string            `json:"environment"`
+	Level       string            `json:"level"`
+	Platform    string            `json:"platform"`
+	Language    string            `json:"language"`
+	UUID        string            `json:"uuid"`
+	Timestamp   int64             `json:"timestamp"`
+}
+
+type rollbarBody struct {
+	Message rollbarMessage `json:"message"`
+}
+
+type rollbarMessage struct {
+	Body  string `json:"body"`
+	Stack string `json:"stack"`
+}
+
+type rollbarRequest struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+}
+
+func (rb *Rollbar) ReportPanic(ctx context.Context, p middleware.Panic) error {
+	environment := rb.environment
+	if environment == "" {
+		environment = "production"
+	}
+	item := rollbarItem{Data: rollbarData{
+		Environment: environment,
+		Level:       "critical",
+		Platform:    "go",
+		Language:    "go",
+		UUID:        eventID(),
+		Timestamp:   p.At.Unix(),
+		Body:        rollbarBody{Message: rollbarMessage{Body: "panic: " + p.Message(), Stack: p.Stack}},
+		Request:     rollbarRequest{Method: p.Method, URL: p.Path},
+		Custom:      map[string]string{"panic_id": p.ID},
+	}}
+
+	return post(ctx, rb.client, rb.endpoint, http.Header{"X-Rollbar-Access-Token": {rb.token}}, item)
+}
@@ -0,0 +1,87 @@
+package reporting
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/dsha256/dispatcher/internal/middleware"
+)
+
+// sentryClient names the reporter to Sentry.
+const sentryClient = "dispatcher/1.0"
+
+// Sentry reports panics to a Sentry project through its store API.
+type Sentry struct {
+	client      *http.Client
+	storeURL    string
+	auth        string
+	environment string
+}
+
+// NewSentry returns a reporter to the project of the DSN.
+func NewSentry(client *http.Client, dsn, environment string) (*Sentry, error) {
+	parsed, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidDSN, err)
+	}
+	project := strings.Trim(parsed.Path, "/")
+	if parsed.User == nil || parsed.User.Username() == "" || parsed.Host == "" || project == "" {
+		return nil, ErrInvalidDSN
+	}
+
+	return &Sentry{
+		client:      client,
+		storeURL:    fmt.Sprintf("%s://%s/api/%s/store/", parsed.Scheme, parsed.Host, project),
+		auth:        fmt.Sprintf("Sentry sentry_version=7, sentry_client=%s, sentry_key=%s", sentryClient, parsed.User.Username()),
+		environment: environment,
+	}, nil
+}
+
+type sentryEvent struct {
+	Tags        map[string]string `json:"tags"`
+	Extra       map[string]string `json:"extra"`
+	Request     sentryRequest     `json:"request"`
+	EventID     string            `json:"event_id"`
+	Timestamp   string            `json:"timestamp"`
+	Platform    string            `json:"platform"`
+	Level       string            `json:"level"`
+	Logger      string            `json:"logger"`
+	Environment string            `json:"environment,omitempty"`
+	Message     string            `json:"message"`
+	Exception   sentryExceptions  `json:"exception"`
+}
+
+type sentryExceptions struct {
+	Values []sentryException `json:"values"`
+}
+
+type sentryException struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type sentryRequest struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+}
+
+func (s *Sentry) ReportPanic(ctx context.Context, p middleware.Panic) error {
+	event := sentryEvent{
+		EventID:     eventID(),
+		Timestamp:   p.At.Format("2006-01-02T15:04:05.000Z07:00"),
+		Platform:    "go",
+		Level:       "fatal",
+		Logger:      "dispatcher",
+		Environment: s.environment,
+		Message:     "panic: " + p.Message(),
+		Exception:   sentryExceptions{Values: []sentryException{{Type: "panic", Value: p.Message()}}},
+		Request:     sentryRequest{Method: p.Method, URL: p.Path},
+		Tags:        map[string]string{"panic_id": p.ID},
+		Extra:       map[string]string{"stack": p.Stack},
+	}
+
+	return post(ctx, s.client, s.storeURL, http.Header{"X-Sentry-Auth": {s.auth}}, event)
+}
@@ -0,0 +1,83 @@
+package responder
+
+import (
+	"net/http"
+	"reflect"
+
+	"github.com/dsha256/dispatcher/internal/types"
+)
+
+// Responder writes the handler's responses, choosing their envelope: the default Envelope, Bare, or one of
+// the embedder's, e.g. JSON:API or an envelope carrying the request ID.
+type Responder interface {
+	// Success writes a success response with the status.
+	Success(w http.ResponseWriter, r *http.Request, status int, success Success)
+	// Error writes an error response with the status. Details, e.g. a validation report, may be nil.
+	Error(w http.ResponseWriter, r *http.Request, status int, err error, details any)
+}
+
+// Success is a success response before it is put in an envelope.
+type Success struct {
+	// Data is the payload; nil and empty payloads are left out of the Envelope.
+	Data any
+	// Message is the success message, and MessageKey its catalog key.
+	Message    string
+	MessageKey string
+	// Warnings are non-fatal notices about the request, e.g. approaching a limit.
+	Warnings []types.Warning
+}
+
+// Envelope is the default Responder, with the payload under data and the error under err.
+type Envelope struct{}
+
+func (Envelope) Success(w http.ResponseWriter, _ *http.Request, status int, success Success) {
+	data := success.Data
+	if isEmpty(data) {
+		data = nil
+	}
+	WriteJSON(w, status, types.Response[any]{
+		Data:     data,
+		Msg:      success.Message,
+		MsgKey:   success.MessageKey,
+		Warnings: success.Warnings,
+	})
+}
+
+func (Envelope) Error(w http.ResponseWriter, _ *http.Request, status int, err error, details any) {
+	WriteJSON(w, status, types.NewErrorResponseWithDetails[any](err.Error(), details))
+}
+
+// Bare is a Responder writing success payloads as they are, without an envelope; errors keep the
+// Envelope's form, so clients can tell them apart.
+type Bare struct{}
+
+func (Bare) Success(w http.ResponseWriter, _ *http.Request, status int, success Success) {
+	if isEmpty(success.Data) {
+		w.WriteHeader(status)
+
+		return
+	}
+	WriteJSON(w, status, success.Data)
+}
+
+func (Bare) Error(w http.ResponseWriter, r *http.Request, status int, err error, details any) {
+	Envelope{}.Error(w, r, status, err, details)
+}
+
+// isEmpty reports whether encoding/json's omitempty leaves the payload out: nil, or an empty slice, map or
+// string.
+func isEmpty(data any) bool {
+	if data == nil {
+		return true
+	}
+
+	v := reflect.ValueOf(data)
+	switch v.Kind() { //nolint:exhaustive // Other kinds are never empty payloads.
+	case reflect.Pointer, reflect.Interface:
+		return v.IsNil()
+	case reflect.Slice, reflect.Map, reflect.String:
+		return v.Len() == 0
+	default:
+		return false
+	}
+}
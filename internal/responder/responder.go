@@ -1,9 +1,14 @@
 package responder
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"net/http"
+	"strings"
 
+	"github.com/dsha256/dispatcher/internal/messages"
 	"github.com/dsha256/dispatcher/internal/types"
 )
 
@@ -19,6 +24,83 @@ func WriteSuccess[T any](w http.ResponseWriter, status int, message string, data
 	WriteJSON(w, status, types.NewSuccessResponse(message, data))
 }
 
+// WriteMessage writes a success response whose message comes from the catalog, together with its key.
+func WriteMessage[T any](w http.ResponseWriter, status int, catalog *messages.Catalog, key messages.Key, data T) {
+	WriteJSON(w, status, types.NewMessageResponse(string(key), catalog.Text(key), data))
+}
+
+func WriteSuccessWithWarnings[T any](w http.ResponseWriter, status int, message string, data T, warnings []types.Warning) {
+	WriteJSON(w, status, types.NewSuccessResponseWithWarnings(message, data, warnings))
+}
+
 func WriteError(w http.ResponseWriter, status int, err error) {
 	WriteJSON(w, status, types.NewErrorResponse[string](err.Error()))
 }
+
+func WriteErrorWithDetails(w http.ResponseWriter, status int, err error, details any) {
+	WriteJSON(w, status, types.NewErrorResponseWithDetails[string](err.Error(), details))
+}
+
+// WriteSuccessWithETag writes a success response tagged with an ETag made of the prefix and a hash of the
+// body, or 304 Not Modified without a body when the request's If-None-Match already has that ETag.
+func WriteSuccessWithETag[T any](w http.ResponseWriter, r *http.Request, prefix string, data T, warnings []types.Warning) {
+	WriteTagged(Envelope{}, w, r, prefix, Success{Data: data, Warnings: warnings})
+}
+
+// WriteTagged writes the success response of the responder tagged with an ETag made of the prefix and a
+// hash of the body, or 304 Not Modified without a body when the request's If-None-Match already has that
+// ETag.
+func WriteTagged(rsp Responder, w http.ResponseWriter, r *http.Request, prefix string, success Success) {
+	buffered := &bufferedWriter{header: make(http.Header), status: http.StatusOK}
+	rsp.Success(buffered, r, http.StatusOK, success)
+
+	header := w.Header()
+	for name, values := range buffered.header {
+		header[name] = values
+	}
+	hash := sha256.Sum256(buffered.body.Bytes())
+	etag := `"` + prefix + "-" + hex.EncodeToString(hash[:8]) + `"`
+	header.Set("ETag", etag)
+	if buffered.status == http.StatusOK && MatchesETag(r.Header.Get("If-None-Match"), etag) {
+		header.Del("Content-Type")
+		w.WriteHeader(http.StatusNotModified)
+
+		return
+	}
+
+	w.WriteHeader(buffered.status)
+	_, _ = w.Write(buffered.body.Bytes())
+}
+
+// MatchesETag reports whether an If-None-Match header lists the ETag, or is "*". Tags are compared weakly,
+// ignoring W/ prefixes, so the weak tags of compressed responses match too.
+func MatchesETag(ifNoneMatch, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// bufferedWriter keeps a response in memory, for WriteTagged to hash it before sending it.
+type bufferedWriter struct {
+	header http.Header
+	body   bytes.Buffer
+	status int
+}
+
+func (bw *bufferedWriter) Header() http.Header {
+	return bw.header
+}
+
+func (bw *bufferedWriter) WriteHeader(status int) {
+	bw.status = status
+}
+
+func (bw *bufferedWriter) Write(p []byte) (int, error) {
+	return bw.body.Write(p)
+}
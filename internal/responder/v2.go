@@ -0,0 +1,65 @@
+package responder
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/dsha256/dispatcher/internal/types"
+	"github.com/dsha256/dispatcher/pkg/apierror"
+)
+
+// MediaTypeV2 is the media type clients list in Accept to get the v2 envelope from v1 paths.
+const MediaTypeV2 = "application/vnd.dispatcher.v2+json"
+
+// ErrorCodeHeader carries the apierror.Code of an error response, e.g. "CYCLE", from which the v2 envelope
+// takes error.code.
+const ErrorCodeHeader = apierror.Header
+
+// EnvelopeV2 is the v2 response: the payload under data, or the error under error, with meta always set.
+type EnvelopeV2 struct {
+	Error *ErrorV2        `json:"error,omitempty"`
+	Data  json.RawMessage `json:"data,omitempty"`
+	Meta  MetaV2          `json:"meta"`
+}
+
+// ErrorV2 is the error of a v2 response. Clients should switch on Code, not on Message.
+type ErrorV2 struct {
+	Details json.RawMessage `json:"details,omitempty"`
+	Code    apierror.Code   `json:"code"`
+	Message string          `json:"message"`
+}
+
+// MetaV2 describes the request a v2 response answers.
+type MetaV2 struct {
+	RequestID  string          `json:"request_id"`
+	Message    string          `json:"message,omitempty"`
+	MessageKey string          `json:"message_key,omitempty"`
+	Warnings   []types.Warning `json:"warnings,omitempty"`
+	// DurationMS is the time spent serving the request, in milliseconds.
+	DurationMS float64 `json:"duration_ms"`
+}
+
+// ToV2 converts a v1 response body to the v2 envelope. The error code is used when the body is an error.
+func ToV2(body []byte, code apierror.Code, meta MetaV2) ([]byte, error) {
+	// The v1 response, with the details kept as they are rather than decoded.
+	var v1 struct {
+		types.Response[json.RawMessage]
+		Details json.RawMessage `json:"details"`
+	}
+	if err := json.Unmarshal(body, &v1); err != nil {
+		return nil, fmt.Errorf("decoding v1 response: %w", err)
+	}
+
+	meta.Message, meta.MessageKey, meta.Warnings = v1.Msg, v1.MsgKey, v1.Warnings
+	envelope := EnvelopeV2{Data: v1.Data, Meta: meta}
+	if v1.Err != "" {
+		envelope.Error = &ErrorV2{Code: code, Message: v1.Err, Details: v1.Details}
+	}
+
+	converted, err := json.Marshal(envelope)
+	if err != nil {
+		return nil, fmt.Errorf("encoding v2 response: %w", err)
+	}
+
+	return append(converted, '\n'), nil
+}
@@ -0,0 +1,211 @@
+// Package shadow runs reconstructions a second time through an alternate strategy, in the background, and
+// reports where it diverges from the strategy that answered: a different path, or a different error. Clients
+// only ever get the primary answer; the shadow's is compared, logged and counted, to migrate strategies safely.
+package shadow
+
+import (
+	"context"
+	"log/slog"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultStrategy    = "default"
+	defaultTimeout     = 5 * time.Second
+	defaultMaxInFlight = 4
+)
+
+// Config configures shadow comparison.
+type Config struct {
+	// Strategy is the alternate strategy reconstructions are also run through, "default" (the lexicographically
+	// smallest itinerary) when empty.
+	Strategy string `json:"strategy" yaml:"strategy"`
+	// Percentage of the reconstructions also run through the strategy, from 0 to 100; all of them when 0.
+	Percentage float64 `json:"percentage" yaml:"percentage"`
+	// Timeout bounds each shadow reconstruction, 5s when 0.
+	Timeout time.Duration `json:"timeout" yaml:"timeout"`
+	// MaxInFlight bounds the shadow reconstructions running at once, 4 when 0; extra ones are skipped.
+	MaxInFlight int  `json:"max_in_flight" yaml:"max_in_flight"`
+	Enabled     bool `json:"enabled"       yaml:"enabled"`
+}
+
+// Request identifies the compared request in the divergence logs.
+type Request struct {
+	RequestID   string
+	Tenant      string
+	ItineraryID string
+	// Strategy is the strategy that answered the request.
+	Strategy string
+}
+
+// Outcome is the outcome of a reconstruction: its path, or the code of its error.
+type Outcome struct {
+	// Code is the error code of a failed reconstruction, e.g. "CYCLE"; empty on success.
+	Code string
+	Path []string
+}
+
+// Stats counts the comparisons since startup.
+type Stats struct {
+	// Compared counts the reconstructions compared with the shadow's.
+	Compared int64 `json:"compared"`
+	// PathDivergences counts the comparisons where both succeeded with different paths.
+	PathDivergences int64 `json:"path_divergences"`
+	// ErrorDivergences counts the comparisons where one failed and the other did not, or both failed differently.
+	ErrorDivergences int64 `json:"error_divergences"`
+	// Skipped counts the sampled reconstructions not run through the shadow, as MaxInFlight were running.
+	Skipped int64 `json:"skipped"`
+	// TimedOut counts the shadow reconstructions that did not finish within Timeout, which are not compared.
+	TimedOut int64 `json:"timed_out"`
+}
+
+// Shadow compares reconstructions with those of its strategy. It is safe for concurrent use.
+type Shadow struct {
+	logger   *slog.Logger
+	inFlight chan struct{}
+	cfg      Config
+	running  sync.WaitGroup
+
+	compared, pathDivergences, errorDivergences, skipped, timedOut atomic.Int64
+}
+
+// New returns the shadow of the configured strategy, nil when shadow comparison is disabled.
+func New(logger *slog.Logger, cfg Config) *Shadow {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.Strategy == "" {
+		cfg.Strategy = defaultStrategy
+	}
+	if cfg.Percentage <= 0 {
+		cfg.Percentage = 100
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.MaxInFlight <= 0 {
+		cfg.MaxInFlight = defaultMaxInFlight
+	}
+
+	return &Shadow{logger: logger, inFlight: make(chan struct{}, cfg.MaxInFlight), cfg: cfg}
+}
+
+// Strategy returns the strategy of the shadow, empty for a nil Shadow.
+func (s *Shadow) Strategy() string {
+	if s == nil {
+		return ""
+	}
+
+	return s.cfg.Strategy
+}
+
+// Compare runs the shadow reconstruction in the background, for a sample of the requests, and compares its
+// outcome with the primary one. run is given a context bounded by the timeout.
+func (s *Shadow) Compare(req Request, primary Outcome, run func(ctx context.Context) Outcome) {
+	if s == nil || rand.Float64()*100 >= s.cfg.Percentage { //nolint:gosec // Sampling does not need a CSPRNG.
+		return
+	}
+
+	select {
+	case s.inFlight <- struct{}{}:
+	default:
+		s.skipped.Add(1)
+		s.logger.Debug("Shadow reconstruction skipped, too many in flight", "request_id", req.RequestID)
+
+		return
+	}
+
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		defer func() { <-s.inFlight }()
+
+		ctx, cancel := context.WithTimeout(context.Background(), s.cfg.Timeout)
+		defer cancel()
+
+		shadowed := run(ctx)
+		if ctx.Err() != nil {
+			s.timedOut.Add(1)
+			s.logger.Warn("Shadow reconstruction timed out", "request_id", req.RequestID, "timeout", s.cfg.Timeout)
+
+			return
+		}
+		s.compare(req, primary, shadowed)
+	}()
+}
+
+// compare counts and logs the divergence of the outcomes. The paths are customer itineraries, so only their
+// lengths and the position of the first airport they differ at are logged: hashes of paths this short would
+// be reversed by enumerating airports. The request's itinerary_id identifies the tickets to reproduce it.
+func (s *Shadow) compare(req Request, primary, shadowed Outcome) {
+	s.compared.Add(1)
+
+	var divergence string
+	switch {
+	case primary.Code != shadowed.Code:
+		s.errorDivergences.Add(1)
+		divergence = "error"
+	case !slices.Equal(primary.Path, shadowed.Path):
+		s.pathDivergences.Add(1)
+		divergence = "path"
+	default:
+		s.logger.Debug("Shadow reconstruction matches", "request_id", req.RequestID, "shadow_strategy", s.cfg.Strategy)
+
+		return
+	}
+
+	s.logger.Warn("Shadow reconstruction diverges",
+		"divergence", divergence,
+		"request_id", req.RequestID,
+		"tenant", req.Tenant,
+		"itinerary_id", req.ItineraryID,
+		"strategy", req.Strategy,
+		"shadow_strategy", s.cfg.Strategy,
+		"path_length", len(primary.Path),
+		"shadow_path_length", len(shadowed.Path),
+		"diverges_at", divergesAt(primary.Path, shadowed.Path),
+		"code", primary.Code,
+		"shadow_code", shadowed.Code,
+	)
+}
+
+// divergesAt returns the index of the first airport the paths differ at, the length of the shorter one when it
+// is a prefix of the other, and -1 when they are equal.
+func divergesAt(path, shadowed []string) int {
+	for i := range min(len(path), len(shadowed)) {
+		if path[i] != shadowed[i] {
+			return i
+		}
+	}
+	if len(path) == len(shadowed) {
+		return -1
+	}
+
+	return min(len(path), len(shadowed))
+}
+
+// Wait waits for the shadow reconstructions running to be compared, e.g. before shutdown.
+func (s *Shadow) Wait() {
+	if s != nil {
+		s.running.Wait()
+	}
+}
+
+// Stats returns the counts of comparisons, zero for a nil Shadow.
+func (s *Shadow) Stats() Stats {
+	if s == nil {
+		return Stats{}
+	}
+
+	return Stats{
+		Compared:         s.compared.Load(),
+		PathDivergences:  s.pathDivergences.Load(),
+		ErrorDivergences: s.errorDivergences.Load(),
+		Skipped:          s.skipped.Load(),
+		TimedOut:         s.timedOut.Load(),
+	}
+}
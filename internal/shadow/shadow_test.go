@@ -0,0 +1,131 @@
+package shadow_test
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/logbuffer"
+	"github.com/dsha256/dispatcher/internal/shadow"
+)
+
+func TestShadowCompare(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		primary  shadow.Outcome
+		shadowed shadow.Outcome
+		want     shadow.Stats
+		log      string
+	}{
+		{
+			name:     "match",
+			primary:  shadow.Outcome{Path: []string{"JFK", "LAX", "SFO"}},
+			shadowed: shadow.Outcome{Path: []string{"JFK", "LAX", "SFO"}},
+			want:     shadow.Stats{Compared: 1},
+		},
+		{
+			name:     "different path",
+			primary:  shadow.Outcome{Path: []string{"JFK", "SFO", "JFK", "LAX"}},
+			shadowed: shadow.Outcome{Path: []string{"JFK", "LAX", "JFK", "SFO"}},
+			want:     shadow.Stats{Compared: 1, PathDivergences: 1},
+			log:      "path_length=4 shadow_path_length=4 diverges_at=1",
+		},
+		{
+			name:     "different error",
+			primary:  shadow.Outcome{Code: "CYCLE"},
+			shadowed: shadow.Outcome{Code: "MULTIPLE_STARTS"},
+			want:     shadow.Stats{Compared: 1, ErrorDivergences: 1},
+			log:      "divergence=error",
+		},
+		{
+			name:     "longer path",
+			primary:  shadow.Outcome{Path: []string{"JFK", "LAX"}},
+			shadowed: shadow.Outcome{Path: []string{"JFK", "LAX", "SFO"}},
+			want:     shadow.Stats{Compared: 1, PathDivergences: 1},
+			log:      "diverges_at=2",
+		},
+		{
+			name:     "error and success",
+			primary:  shadow.Outcome{Path: []string{"JFK", "LAX"}},
+			shadowed: shadow.Outcome{Code: "CONSTRAINT_VIOLATED"},
+			want:     shadow.Stats{Compared: 1, ErrorDivergences: 1},
+			log:      "divergence=error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			logs := logbuffer.New(10)
+			s := shadow.New(slog.New(slog.NewTextHandler(logs, nil)), shadow.Config{Strategy: "candidate", Enabled: true})
+			req := shadow.Request{RequestID: "req-1", Strategy: "default"}
+			s.Compare(req, tt.primary, func(context.Context) shadow.Outcome { return tt.shadowed })
+			s.Wait()
+
+			if got := s.Stats(); got != tt.want {
+				t.Errorf("Stats() = %+v, want %+v", got, tt.want)
+			}
+			logged := strings.Join(logs.Lines(), "\n")
+			if tt.log == "" && logged != "" {
+				t.Errorf("logged %q, want nothing", logged)
+			}
+			if tt.log != "" && (!strings.Contains(logged, tt.log) || !strings.Contains(logged, "shadow_strategy=candidate")) {
+				t.Errorf("logged %q, want a divergence with %q", logged, tt.log)
+			}
+			for _, airport := range slices.Concat(tt.primary.Path, tt.shadowed.Path) {
+				if tt.log != "" && strings.Contains(logged, airport) {
+					t.Errorf("logged %q, want no airport of the paths", logged)
+				}
+			}
+		})
+	}
+}
+
+func TestShadowLimits(t *testing.T) {
+	t.Parallel()
+
+	s := shadow.New(slog.New(slog.DiscardHandler), shadow.Config{Timeout: 10 * time.Millisecond, MaxInFlight: 1, Enabled: true})
+	if got := s.Strategy(); got != "default" {
+		t.Errorf("Strategy() = %q, want default", got)
+	}
+
+	release := make(chan struct{})
+	s.Compare(shadow.Request{}, shadow.Outcome{}, func(ctx context.Context) shadow.Outcome {
+		<-ctx.Done()
+		<-release
+
+		return shadow.Outcome{}
+	})
+	// The first reconstruction fills MaxInFlight.
+	s.Compare(shadow.Request{}, shadow.Outcome{}, func(context.Context) shadow.Outcome { return shadow.Outcome{} })
+	close(release)
+	s.Wait()
+
+	if got, want := s.Stats(), (shadow.Stats{Skipped: 1, TimedOut: 1}); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+}
+
+func TestShadowDisabled(t *testing.T) {
+	t.Parallel()
+
+	s := shadow.New(slog.New(slog.DiscardHandler), shadow.Config{Strategy: "candidate"})
+	if s != nil {
+		t.Fatalf("New() = %v, want nil when disabled", s)
+	}
+	s.Compare(shadow.Request{}, shadow.Outcome{}, func(context.Context) shadow.Outcome {
+		t.Error("disabled shadow ran a reconstruction")
+
+		return shadow.Outcome{}
+	})
+	s.Wait()
+	if got := s.Stats(); got != (shadow.Stats{}) {
+		t.Errorf("Stats() = %+v, want zero", got)
+	}
+}
@@ -0,0 +1,108 @@
+// Package shedding caps the reconstructions running at once: requests beyond the limit are answered right
+// away with 503 Service Unavailable and Retry-After rather than queued, so latency holds during traffic
+// spikes. The limit can be changed while the service runs.
+package shedding
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/responder"
+	"github.com/dsha256/dispatcher/pkg/apierror"
+)
+
+var (
+	ErrOverloaded   = errors.New("service overloaded, retry later")
+	ErrInvalidLimit = errors.New("shedding max_concurrent must be positive")
+)
+
+// defaultRetryAfter is the Retry-After of shed requests when unset.
+const defaultRetryAfter = time.Second
+
+type Config struct {
+	// MaxConcurrent caps the requests served at once.
+	MaxConcurrent int `json:"max_concurrent" yaml:"max_concurrent"`
+	// RetryAfter is sent with shed requests, rounded up to seconds, 1s when 0.
+	RetryAfter time.Duration `json:"retry_after" yaml:"retry_after"`
+	Enabled    bool          `json:"enabled"     yaml:"enabled"`
+}
+
+// Status is the current state of the shedder.
+type Status struct {
+	MaxConcurrent int64  `json:"max_concurrent"`
+	InFlight      int64  `json:"in_flight"`
+	Shed          uint64 `json:"shed"`
+}
+
+// Shedder counts the requests in flight and sheds those beyond the limit. It is safe for concurrent use;
+// a nil Shedder sheds nothing.
+type Shedder struct {
+	retryAfter string
+	limit      atomic.Int64
+	inFlight   atomic.Int64
+	shed       atomic.Uint64
+}
+
+// New returns the shedder of the configuration, nil when shedding is disabled.
+func New(cfg Config) (*Shedder, error) {
+	if !cfg.Enabled {
+		return nil, nil //nolint:nilnil // A nil Shedder sheds nothing.
+	}
+	if cfg.MaxConcurrent <= 0 {
+		return nil, ErrInvalidLimit
+	}
+	if cfg.RetryAfter <= 0 {
+		cfg.RetryAfter = defaultRetryAfter
+	}
+
+	s := &Shedder{retryAfter: strconv.Itoa(int((cfg.RetryAfter + time.Second - 1) / time.Second))}
+	s.limit.Store(int64(cfg.MaxConcurrent))
+
+	return s, nil
+}
+
+// SetLimit changes the limit; requests in flight beyond a lowered limit complete.
+func (s *Shedder) SetLimit(limit int) error {
+	if limit <= 0 {
+		return fmt.Errorf("%w, got %d", ErrInvalidLimit, limit)
+	}
+	s.limit.Store(int64(limit))
+
+	return nil
+}
+
+// Status returns a snapshot of the shedder.
+func (s *Shedder) Status() Status {
+	if s == nil {
+		return Status{}
+	}
+
+	return Status{MaxConcurrent: s.limit.Load(), InFlight: s.inFlight.Load(), Shed: s.shed.Load()}
+}
+
+// Middleware serves the requests within the limit, and answers the others with 503 Service Unavailable, the
+// UNAVAILABLE code and Retry-After.
+func (s *Shedder) Middleware(next http.Handler) http.Handler {
+	if s == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.inFlight.Add(1) > s.limit.Load() {
+			s.inFlight.Add(-1)
+			s.shed.Add(1)
+			w.Header().Set("Retry-After", s.retryAfter)
+			w.Header().Set(responder.ErrorCodeHeader, string(apierror.CodeUnavailable))
+			responder.WriteError(w, http.StatusServiceUnavailable, ErrOverloaded)
+
+			return
+		}
+		defer s.inFlight.Add(-1)
+
+		next.ServeHTTP(w, r)
+	})
+}
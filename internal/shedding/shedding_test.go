@@ -0,0 +1,117 @@
+package shedding_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/responder"
+	"github.com/dsha256/dispatcher/internal/shedding"
+)
+
+func TestMiddleware(t *testing.T) {
+	t.Parallel()
+
+	shedder, err := shedding.New(shedding.Config{MaxConcurrent: 2, RetryAfter: 1500 * time.Millisecond, Enabled: true})
+	if err != nil {
+		t.Fatalf("Failed to create shedder: %v", err)
+	}
+	started, release := make(chan struct{}), make(chan struct{})
+	handler := shedder.Middleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		started <- struct{}{}
+		<-release
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	serve := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/dispatcher/itinerary", nil))
+
+		return rec
+	}
+
+	var wg sync.WaitGroup
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if rec := serve(); rec.Code != http.StatusNoContent {
+				t.Errorf("Expected requests within the limit to be served, got %d", rec.Code)
+			}
+		}()
+		<-started
+	}
+
+	rec := serve()
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected status 503 beyond the limit, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("Expected Retry-After 2, got %q", got)
+	}
+	if got := rec.Header().Get(responder.ErrorCodeHeader); got != "UNAVAILABLE" {
+		t.Errorf("Expected error code UNAVAILABLE, got %q", got)
+	}
+	if status := shedder.Status(); status.InFlight != 2 || status.Shed != 1 {
+		t.Errorf("Expected 2 in flight and 1 shed, got %+v", status)
+	}
+
+	if err = shedder.SetLimit(3); err != nil {
+		t.Fatalf("Failed to raise the limit: %v", err)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		serve()
+	}()
+	<-started
+	close(release)
+	wg.Wait()
+
+	if status := shedder.Status(); status.InFlight != 0 || status.MaxConcurrent != 3 {
+		t.Errorf("Expected nothing in flight with a limit of 3, got %+v", status)
+	}
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		wantErr error
+		name    string
+		cfg     shedding.Config
+	}{
+		{name: "Without a limit", cfg: shedding.Config{Enabled: true}, wantErr: shedding.ErrInvalidLimit},
+		{name: "Disabled", cfg: shedding.Config{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			shedder, err := shedding.New(tt.cfg)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if shedder != nil {
+				t.Errorf("Expected no shedder, got %+v", shedder.Status())
+			}
+		})
+	}
+}
+
+func TestSetLimit(t *testing.T) {
+	t.Parallel()
+
+	shedder, err := shedding.New(shedding.Config{MaxConcurrent: 4, Enabled: true})
+	if err != nil {
+		t.Fatalf("Failed to create shedder: %v", err)
+	}
+	if err = shedder.SetLimit(0); !errors.Is(err, shedding.ErrInvalidLimit) {
+		t.Errorf("Expected ErrInvalidLimit, got %v", err)
+	}
+	if got := shedder.Status().MaxConcurrent; got != 4 {
+		t.Errorf("Expected the limit to be kept, got %d", got)
+	}
+}
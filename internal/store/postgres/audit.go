@@ -0,0 +1,79 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/audit"
+)
+
+// Audit writes the audit trail to the audit_records table of the store, as an audit.Sink that is also an
+// audit.Tail and an audit.Pruner. Closing it closes the store.
+type Audit struct {
+	*Store
+}
+
+func NewAudit(s *Store) *Audit {
+	return &Audit{Store: s}
+}
+
+func (a *Audit) Write(ctx context.Context, record audit.Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = a.db.ExecContext(ctx,
+		`INSERT INTO audit_records (node, seq, at, tenant, hash, record) VALUES ($1, $2, $3, $4, $5, $6)`,
+		record.Node, int64(record.Seq), record.At, record.Tenant, record.Hash, string(data), //nolint:gosec // Sequence numbers stay far below 2^63.
+	)
+	if err != nil {
+		return fmt.Errorf("writing audit record: %w", err)
+	}
+
+	return nil
+}
+
+func (a *Audit) Last(ctx context.Context, node string) (audit.Record, bool, error) {
+	var data []byte
+	err := a.db.QueryRowContext(ctx,
+		`SELECT record FROM audit_records WHERE node = $1 ORDER BY seq DESC LIMIT 1`, node,
+	).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return audit.Record{}, false, nil
+	}
+	if err != nil {
+		return audit.Record{}, false, fmt.Errorf("reading the latest audit record: %w", err)
+	}
+
+	var record audit.Record
+	if err = json.Unmarshal(data, &record); err != nil {
+		return audit.Record{}, false, fmt.Errorf("%w: unreadable record: %w", audit.ErrTampered, err)
+	}
+
+	return record, true, nil
+}
+
+// Prune deletes the records of each node from before its latest checkpoint older than the time.
+func (a *Audit) Prune(ctx context.Context, before time.Time) (int, error) {
+	result, err := a.db.ExecContext(ctx, `
+		DELETE FROM audit_records r
+		USING (
+			SELECT node, MAX(seq) AS seq FROM audit_records
+			WHERE at < $1 AND record->>'kind' = $2
+			GROUP BY node
+		) c
+		WHERE r.node = c.node AND r.seq < c.seq`, before, audit.KindCheckpoint)
+	if err != nil {
+		return 0, fmt.Errorf("pruning audit records: %w", err)
+	}
+	pruned, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("pruning audit records: %w", err)
+	}
+
+	return int(pruned), nil
+}
@@ -0,0 +1,10 @@
+CREATE TABLE itineraries (
+    id         TEXT        PRIMARY KEY,
+    tenant     TEXT        NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    tickets    INTEGER     NOT NULL,
+    -- JSON rather than JSONB keeps the response exactly as it was sent.
+    result     JSON        NOT NULL
+);
+
+CREATE INDEX itineraries_tenant_created_at ON itineraries (tenant, created_at DESC, id DESC);
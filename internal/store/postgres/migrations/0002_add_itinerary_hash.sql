@@ -0,0 +1,5 @@
+-- hash is the fingerprint of the ticket set; a tenant saves each ticket set once. Rows saved before
+-- have no hash, which the unique index does not constrain.
+ALTER TABLE itineraries ADD COLUMN hash TEXT;
+
+CREATE UNIQUE INDEX itineraries_tenant_hash ON itineraries (tenant, hash);
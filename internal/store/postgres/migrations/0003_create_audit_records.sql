@@ -0,0 +1,13 @@
+-- audit_records holds the audit trail: the chain of each node, numbered by seq. record is the record as it
+-- was hashed; JSON rather than JSONB keeps it byte for byte.
+CREATE TABLE audit_records (
+    node   TEXT        NOT NULL,
+    seq    BIGINT      NOT NULL,
+    at     TIMESTAMPTZ NOT NULL,
+    tenant TEXT        NOT NULL,
+    hash   TEXT        NOT NULL,
+    record JSON        NOT NULL,
+    PRIMARY KEY (node, seq)
+);
+
+CREATE INDEX audit_records_at ON audit_records (at);
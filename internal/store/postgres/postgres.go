@@ -0,0 +1,226 @@
+// Package postgres persists saved itineraries in PostgreSQL. The schema is created and upgraded by the
+// embedded migrations, applied in file name order by Migrate.
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"time"
+
+	// Registers the "postgres" database/sql driver.
+	_ "github.com/lib/pq"
+
+	"github.com/dsha256/dispatcher/internal/store"
+)
+
+var ErrMissingDSN = errors.New("postgres dsn is required")
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// migrationLock is the key of the advisory lock serializing migrations, so replicas starting together
+// apply each migration once.
+const migrationLock = 0x6469737061746368
+
+// Config addresses a PostgreSQL database.
+type Config struct {
+	// DSN is the connection string, e.g. "postgres://dispatcher@localhost:5432/dispatcher?sslmode=disable".
+	DSN string `json:"-" yaml:"dsn"`
+	// MaxOpenConns bounds the number of open connections, 10 when 0.
+	MaxOpenConns int `json:"max_open_conns" yaml:"max_open_conns"`
+}
+
+const defaultMaxOpenConns = 10
+
+// Store saves itineraries in the itineraries table. It is safe for concurrent use.
+type Store struct {
+	db *sql.DB
+}
+
+// Open returns a store for the database. Connections are opened on first use, so a database that is
+// not up yet only fails the calls made before it is.
+func Open(cfg Config) (*Store, error) {
+	if cfg.DSN == "" {
+		return nil, ErrMissingDSN
+	}
+	if cfg.MaxOpenConns <= 0 {
+		cfg.MaxOpenConns = defaultMaxOpenConns
+	}
+
+	db, err := sql.Open("postgres", cfg.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("opening postgres: %w", err)
+	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxOpenConns)
+
+	return &Store{db: db}, nil
+}
+
+// Migrate applies the migrations not applied yet, each in its own transaction, recording them in
+// the schema_migrations table.
+func (s *Store) Migrate(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    TEXT        PRIMARY KEY,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`); err != nil {
+		return fmt.Errorf("creating schema_migrations: %w", err)
+	}
+
+	names, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err = s.migrate(ctx, name); err != nil {
+			return fmt.Errorf("applying migration %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) migrate(ctx context.Context, name string) error {
+	script, err := migrations.ReadFile(name)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if _, err = tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, migrationLock); err != nil {
+		return err
+	}
+	var applied bool
+	if err = tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`, name).Scan(&applied); err != nil {
+		return err
+	}
+	if applied {
+		return nil
+	}
+
+	if _, err = tx.ExecContext(ctx, string(script)); err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, name); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Ping checks that the database is reachable.
+func (s *Store) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// Close closes the connections.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func (s *Store) Save(ctx context.Context, itinerary *store.Itinerary) error {
+	var createdAt time.Time
+	err := s.db.QueryRowContext(ctx,
+		`INSERT INTO itineraries (id, tenant, hash, tickets, result) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (tenant, hash) DO UPDATE SET tickets = EXCLUDED.tickets, result = EXCLUDED.result
+		RETURNING id, created_at`,
+		store.NewID(), itinerary.Tenant, nullable(itinerary.Hash), itinerary.Tickets, string(itinerary.Result),
+	).Scan(&itinerary.ID, &createdAt)
+	if err != nil {
+		return fmt.Errorf("saving itinerary: %w", err)
+	}
+	itinerary.CreatedAt = createdAt.UTC()
+
+	return nil
+}
+
+// Queries of a single itinerary of a tenant, by ID and by hash.
+const (
+	getByID   = `SELECT id, COALESCE(hash, ''), created_at, tickets, result FROM itineraries WHERE tenant = $1 AND id = $2`
+	getByHash = `SELECT id, COALESCE(hash, ''), created_at, tickets, result FROM itineraries WHERE tenant = $1 AND hash = $2`
+)
+
+func (s *Store) Get(ctx context.Context, tenant, id string) (*store.Itinerary, error) {
+	return s.get(ctx, getByID, tenant, id)
+}
+
+func (s *Store) GetByHash(ctx context.Context, tenant, hash string) (*store.Itinerary, error) {
+	return s.get(ctx, getByHash, tenant, hash)
+}
+
+// get returns the itinerary of the tenant selected by the query, getByID or getByHash.
+func (s *Store) get(ctx context.Context, query, tenant, value string) (*store.Itinerary, error) {
+	itinerary := store.Itinerary{Tenant: tenant}
+	err := s.db.QueryRowContext(ctx, query, tenant, value).Scan(&itinerary.ID, &itinerary.Hash, &itinerary.CreatedAt, &itinerary.Tickets, &itinerary.Result)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting itinerary: %w", err)
+	}
+	itinerary.CreatedAt = itinerary.CreatedAt.UTC()
+
+	return &itinerary, nil
+}
+
+func (s *Store) List(ctx context.Context, tenant string, page store.Page) ([]store.Itinerary, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, COALESCE(hash, ''), created_at, tickets FROM itineraries WHERE tenant = $1
+		ORDER BY created_at DESC, id DESC OFFSET $2 LIMIT $3`,
+		tenant, max(page.Offset, 0), max(page.Limit, 0),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing itineraries: %w", err)
+	}
+	defer rows.Close()
+
+	itineraries := []store.Itinerary{}
+	for rows.Next() {
+		itinerary := store.Itinerary{Tenant: tenant}
+		if err = rows.Scan(&itinerary.ID, &itinerary.Hash, &itinerary.CreatedAt, &itinerary.Tickets); err != nil {
+			return nil, fmt.Errorf("listing itineraries: %w", err)
+		}
+		itinerary.CreatedAt = itinerary.CreatedAt.UTC()
+		itineraries = append(itineraries, itinerary)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("listing itineraries: %w", err)
+	}
+
+	return itineraries, nil
+}
+
+func (s *Store) Delete(ctx context.Context, tenant, id string) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM itineraries WHERE tenant = $1 AND id = $2`, tenant, id)
+	if err != nil {
+		return fmt.Errorf("deleting itinerary: %w", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("deleting itinerary: %w", err)
+	}
+	if deleted == 0 {
+		return store.ErrNotFound
+	}
+
+	return nil
+}
+
+// nullable is NULL for an empty value.
+func nullable(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
@@ -0,0 +1,139 @@
+package postgres_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/audit"
+	"github.com/dsha256/dispatcher/internal/store"
+	"github.com/dsha256/dispatcher/internal/store/postgres"
+)
+
+// dsnVariable names the environment variable with the DSN of a disposable database to run the tests
+// against; they are skipped when it is unset.
+const dsnVariable = "DISPATCHER_TEST_POSTGRES_DSN"
+
+func TestOpen(t *testing.T) {
+	t.Parallel()
+
+	if _, err := postgres.Open(postgres.Config{}); !errors.Is(err, postgres.ErrMissingDSN) {
+		t.Errorf("Open() without DSN error = %v, want %v", err, postgres.ErrMissingDSN)
+	}
+}
+
+func TestStore(t *testing.T) {
+	t.Parallel()
+
+	dsn := os.Getenv(dsnVariable)
+	if dsn == "" {
+		t.Skipf("%s is not set", dsnVariable)
+	}
+	s, err := postgres.Open(postgres.Config{DSN: dsn})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer s.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// Migrating twice applies each migration once.
+	for range 2 {
+		if err = s.Migrate(ctx); err != nil {
+			t.Fatalf("Migrate() error = %v", err)
+		}
+	}
+
+	tenant := "test-" + store.NewID()
+	result := json.RawMessage(`{"linear_path": ["JFK", "LAX"]}`)
+	first := store.Itinerary{Tenant: tenant, Hash: "first", Tickets: 1, Result: result}
+	second := store.Itinerary{Tenant: tenant, Hash: "second", Tickets: 2, Result: result}
+	again := store.Itinerary{Tenant: tenant, Hash: "first", Tickets: 1, Result: result}
+	for _, itinerary := range []*store.Itinerary{&first, &second, &again} {
+		if err = s.Save(ctx, itinerary); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+	}
+	if again.ID != first.ID || !again.CreatedAt.Equal(first.CreatedAt) {
+		t.Errorf("Save() of a saved hash = %+v, want the ID and creation time of %+v", again, first)
+	}
+
+	got, err := s.Get(ctx, tenant, first.ID)
+	if err != nil || got.Tickets != 1 || string(got.Result) != string(result) || !got.CreatedAt.Equal(first.CreatedAt) {
+		t.Errorf("Get() = %+v, %v, want %+v", got, err, first)
+	}
+	if _, err = s.Get(ctx, "other", first.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Get() of another tenant error = %v, want %v", err, store.ErrNotFound)
+	}
+	if got, err = s.GetByHash(ctx, tenant, "second"); err != nil || got.ID != second.ID {
+		t.Errorf("GetByHash() = %+v, %v, want %+v", got, err, second)
+	}
+
+	listed, err := s.List(ctx, tenant, store.Page{Limit: 1})
+	if err != nil || len(listed) != 1 || listed[0].ID != second.ID || listed[0].Result != nil {
+		t.Errorf("List() = %+v, %v, want the second itinerary without its result", listed, err)
+	}
+
+	if err = s.Delete(ctx, tenant, first.ID); err != nil {
+		t.Errorf("Delete() error = %v", err)
+	}
+	if err = s.Delete(ctx, tenant, first.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Delete() twice error = %v, want %v", err, store.ErrNotFound)
+	}
+	_ = s.Delete(ctx, tenant, second.ID)
+}
+
+func TestAudit(t *testing.T) {
+	t.Parallel()
+
+	dsn := os.Getenv(dsnVariable)
+	if dsn == "" {
+		t.Skipf("%s is not set", dsnVariable)
+	}
+	s, err := postgres.Open(postgres.Config{DSN: dsn})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	sink := postgres.NewAudit(s)
+	defer sink.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err = sink.Migrate(ctx); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+
+	node := "test-" + store.NewID()
+	if _, found, err := sink.Last(ctx, node); err != nil || found {
+		t.Errorf("Last() of a new node = %v, %v, want nothing", found, err)
+	}
+	at := time.Now().UTC().Truncate(time.Microsecond)
+	first := audit.Record{At: at.Add(-time.Hour), Node: node, Seq: 1, Hash: "first", Tenant: "acme", Status: 200}
+	second := audit.Record{At: at, Node: node, Kind: audit.KindCheckpoint, Seq: 2, PrevHash: "first", Hash: "second"}
+	for _, record := range []audit.Record{first, second} {
+		if err = sink.Write(ctx, record); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err = sink.Write(ctx, second); err == nil {
+		t.Error("Write() of a written sequence number error = nil, want an error")
+	}
+
+	last, found, err := sink.Last(ctx, node)
+	if err != nil || !found || last != second {
+		t.Errorf("Last() = %+v, %v, %v, want %+v", last, found, err, second)
+	}
+
+	// The records before the checkpoint are pruned, not the checkpoint.
+	if pruned, err := sink.Prune(ctx, at.Add(time.Minute)); err != nil || pruned != 1 {
+		t.Errorf("Prune() = %d, %v, want 1", pruned, err)
+	}
+	if last, _, err = sink.Last(ctx, node); err != nil || last != second {
+		t.Errorf("Last() after Prune() = %+v, %v, want %+v", last, err, second)
+	}
+}
@@ -0,0 +1,144 @@
+// Package redis connects the service to Redis through go-redis, covering what it needs: PING for readiness,
+// GET and SET with an expiry for the shared reconstruction cache, and lists as the queue of background jobs.
+package redis
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrMissingAddr = errors.New("redis address is required")
+	ErrServer      = errors.New("redis error")
+)
+
+// Config addresses a Redis server.
+type Config struct {
+	// Addr is the host:port of the server.
+	Addr string `json:"addr"      yaml:"addr"`
+	// Username and Password authenticate the connections, as an ACL user when Username is set.
+	Username string `json:"username"  yaml:"username"`
+	Password string `json:"-"         yaml:"password"`
+	// DB is the database selected on every connection.
+	DB int `json:"db"        yaml:"db"`
+	// PoolSize bounds the number of connections kept for reuse, 4 when 0.
+	PoolSize int `json:"pool_size" yaml:"pool_size"`
+	// TLS connects over TLS, verifying the server's certificate against the system roots.
+	TLS bool `json:"tls"       yaml:"tls"`
+}
+
+const (
+	defaultPoolSize = 4
+	// popSlice is how long the server blocks a pop at a time.
+	popSlice = time.Second
+)
+
+// Client sends commands to a Redis server over pooled connections. It is safe for concurrent use.
+type Client struct {
+	rdb *goredis.Client
+}
+
+// New returns a client for the server. Connections are opened on first use, so a server that is not
+// up yet only fails the commands sent before it is.
+func New(cfg Config) (*Client, error) {
+	if cfg.Addr == "" {
+		return nil, ErrMissingAddr
+	}
+	if cfg.PoolSize <= 0 {
+		cfg.PoolSize = defaultPoolSize
+	}
+
+	opts := &goredis.Options{
+		Addr:                  cfg.Addr,
+		Username:              cfg.Username,
+		Password:              cfg.Password,
+		DB:                    cfg.DB,
+		PoolSize:              cfg.PoolSize,
+		ContextTimeoutEnabled: true,
+		DisableIdentity:       true,
+	}
+	if cfg.TLS {
+		opts.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+
+	return &Client{rdb: goredis.NewClient(opts)}, nil
+}
+
+// Ping checks that the server is reachable and answering.
+func (c *Client) Ping(ctx context.Context) error {
+	return wrap(c.rdb.Ping(ctx).Err())
+}
+
+// Get returns the value of key, or false when it does not exist.
+func (c *Client) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := c.rdb.Get(ctx, key).Bytes()
+	if errors.Is(err, goredis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, wrap(err)
+	}
+
+	return value, true, nil
+}
+
+// Set stores the value under key, expiring after ttl.
+func (c *Client) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return wrap(c.rdb.Set(ctx, key, value, max(ttl, time.Millisecond)).Err())
+}
+
+// Push appends the value to the tail of the queue, the list under key.
+func (c *Client) Push(ctx context.Context, key string, value []byte) error {
+	return wrap(c.rdb.RPush(ctx, key, value).Err())
+}
+
+// Pop removes and returns the value at the head of the queue, the list under key, waiting up to timeout for
+// one to be pushed. It returns false when the queue stayed empty. The server waits in slices of popSlice,
+// the shortest it supports, and Pop returns ctx's error between them once ctx is done: returning in the
+// middle of a slice would lose a value popped by the server afterwards.
+func (c *Client) Pop(ctx context.Context, key string, timeout time.Duration) ([]byte, bool, error) {
+	for remaining := timeout; ; remaining -= popSlice {
+		if err := ctx.Err(); err != nil {
+			return nil, false, err
+		}
+
+		reply, err := c.rdb.BLPop(ctx, popSlice, key).Result()
+		if err == nil {
+			return []byte(reply[1]), true, nil
+		}
+		if !errors.Is(err, goredis.Nil) {
+			return nil, false, wrap(err)
+		}
+		if remaining <= popSlice {
+			return nil, false, nil
+		}
+	}
+}
+
+// Len returns the length of the queue, the list under key.
+func (c *Client) Len(ctx context.Context, key string) (int, error) {
+	n, err := c.rdb.LLen(ctx, key).Result()
+
+	return int(n), wrap(err)
+}
+
+// Close closes the connections.
+func (c *Client) Close() error {
+	return c.rdb.Close()
+}
+
+// wrap marks the errors returned by the server, e.g. a wrong password, with ErrServer; the others are
+// connection errors.
+func wrap(err error) error {
+	var serverErr goredis.Error
+	if errors.As(err, &serverErr) {
+		return fmt.Errorf("%w: %w", ErrServer, err)
+	}
+
+	return err
+}
@@ -0,0 +1,138 @@
+package redis_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"github.com/dsha256/dispatcher/internal/store/redis"
+)
+
+func TestClient(t *testing.T) {
+	t.Parallel()
+
+	server := miniredis.RunT(t)
+	server.RequireAuth("secret")
+	client, err := redis.New(redis.Config{Addr: server.Addr(), Password: "secret", DB: 2})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err = client.Ping(ctx); err != nil {
+		t.Fatalf("Ping() error = %v", err)
+	}
+	if _, ok, err := client.Get(ctx, "missing"); ok || err != nil {
+		t.Errorf("Get(missing) = %v, %v, want not found", ok, err)
+	}
+
+	// Values are binary-safe, including line breaks.
+	value := []byte("{\"path\":\r\n[\"JFK\",\"LAX\"]}")
+	if err = client.Set(ctx, "key", value, time.Minute); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	got, ok, err := client.Get(ctx, "key")
+	if !ok || err != nil || string(got) != string(value) {
+		t.Errorf("Get(key) = %q, %v, %v, want %q", got, ok, err, value)
+	}
+
+	server.FastForward(time.Minute)
+	if _, ok, err = client.Get(ctx, "key"); ok || err != nil {
+		t.Errorf("Get(key) after its ttl = %v, %v, want not found", ok, err)
+	}
+}
+
+func TestClientQueue(t *testing.T) {
+	t.Parallel()
+
+	server := miniredis.RunT(t)
+	client, err := redis.New(redis.Config{Addr: server.Addr()})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for _, value := range []string{"first", "second"} {
+		if err = client.Push(ctx, "queue", []byte(value)); err != nil {
+			t.Fatalf("Push(%s) error = %v", value, err)
+		}
+	}
+	if n, err := client.Len(ctx, "queue"); n != 2 || err != nil {
+		t.Errorf("Len() = %d, %v, want 2", n, err)
+	}
+
+	// Values are popped in the order they were pushed.
+	for _, want := range []string{"first", "second"} {
+		got, ok, err := client.Pop(ctx, "queue", time.Second)
+		if !ok || err != nil || string(got) != want {
+			t.Errorf("Pop() = %q, %v, %v, want %q", got, ok, err, want)
+		}
+	}
+	if _, ok, err := client.Pop(ctx, "queue", time.Second); ok || err != nil {
+		t.Errorf("Pop() of an empty queue = %v, %v, want nothing", ok, err)
+	}
+
+	// A blocked pop returns once its context is canceled, within a slice.
+	popCtx, popCancel := context.WithCancel(ctx)
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := client.Pop(popCtx, "queue", time.Minute)
+		done <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	popCancel()
+	select {
+	case err = <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Pop() after cancellation error = %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Pop() kept blocking after its context was canceled")
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := redis.New(redis.Config{}); !errors.Is(err, redis.ErrMissingAddr) {
+		t.Errorf("New() without address error = %v, want %v", err, redis.ErrMissingAddr)
+	}
+
+	server := miniredis.RunT(t)
+	server.RequireAuth("secret")
+	client, err := redis.New(redis.Config{Addr: server.Addr(), Password: "wrong"})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if err = client.Ping(ctx); !errors.Is(err, redis.ErrServer) {
+		t.Errorf("Ping() with a wrong password error = %v, want %v", err, redis.ErrServer)
+	}
+
+	// Nothing listens on the address of a closed listener.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+	client, err = redis.New(redis.Config{Addr: addr})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if err = client.Ping(ctx); err == nil {
+		t.Error("Ping() of an unreachable server succeeded")
+	}
+}
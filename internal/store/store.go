@@ -0,0 +1,161 @@
+// Package store saves reconstructed itineraries, so clients can retrieve previous results instead of
+// resubmitting their tickets. Memory keeps them per replica; the postgres package persists them.
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/clock"
+)
+
+var ErrNotFound = errors.New("itinerary not found")
+
+// Itinerary is a saved reconstruction, owned by the tenant that requested it. A tenant has at most one
+// itinerary per ticket set, identified by Hash, the fingerprint of the ticket set.
+type Itinerary struct {
+	CreatedAt time.Time `json:"created_at"`
+	ID        string    `json:"id"`
+	Hash      string    `json:"itinerary_id"`
+	Tenant    string    `json:"-"`
+	// Result is the JSON of the reconstruction response; listings leave it empty.
+	Result json.RawMessage `json:"result,omitempty"`
+	// Tickets is the number of tickets reconstructed.
+	Tickets int `json:"tickets"`
+}
+
+// Page selects a page of a listing, most recently saved itineraries first.
+type Page struct {
+	Offset int
+	Limit  int
+}
+
+// Itineraries saves itineraries and retrieves them by tenant. Implementations are safe for concurrent use.
+type Itineraries interface {
+	// Save assigns the itinerary a new ID and creation time and saves it. When the tenant already saved an
+	// itinerary with its hash, that one's result is replaced instead, and the itinerary gets its ID and creation time.
+	Save(ctx context.Context, itinerary *Itinerary) error
+	// Get returns the itinerary of the tenant, or ErrNotFound.
+	Get(ctx context.Context, tenant, id string) (*Itinerary, error)
+	// GetByHash returns the itinerary of the tenant with the hash, or ErrNotFound.
+	GetByHash(ctx context.Context, tenant, hash string) (*Itinerary, error)
+	// List returns a page of the tenant's itineraries without their results.
+	List(ctx context.Context, tenant string, page Page) ([]Itinerary, error)
+	// Delete removes the itinerary of the tenant, or returns ErrNotFound.
+	Delete(ctx context.Context, tenant, id string) error
+}
+
+// NewID returns a random itinerary ID.
+func NewID() string {
+	return rand.Text()
+}
+
+// Memory keeps itineraries in memory, so they are lost on restart. It is safe for concurrent use.
+type Memory struct {
+	clock clock.Clock
+	// itineraries maps tenant -> ID -> itinerary.
+	itineraries map[string]map[string]Itinerary
+	// hashes maps tenant -> hash -> ID.
+	hashes map[string]map[string]string
+	mu     sync.RWMutex
+}
+
+func NewMemory(clk clock.Clock) *Memory {
+	return &Memory{
+		clock:       clk,
+		itineraries: make(map[string]map[string]Itinerary),
+		hashes:      make(map[string]map[string]string),
+	}
+}
+
+func (m *Memory) Save(_ context.Context, itinerary *Itinerary) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.itineraries[itinerary.Tenant] == nil {
+		m.itineraries[itinerary.Tenant] = make(map[string]Itinerary)
+		m.hashes[itinerary.Tenant] = make(map[string]string)
+	}
+
+	if id, ok := m.hashes[itinerary.Tenant][itinerary.Hash]; ok && itinerary.Hash != "" {
+		itinerary.ID = id
+		itinerary.CreatedAt = m.itineraries[itinerary.Tenant][id].CreatedAt
+	} else {
+		itinerary.ID = NewID()
+		itinerary.CreatedAt = m.clock.Now().UTC()
+	}
+	m.itineraries[itinerary.Tenant][itinerary.ID] = *itinerary
+	if itinerary.Hash != "" {
+		m.hashes[itinerary.Tenant][itinerary.Hash] = itinerary.ID
+	}
+
+	return nil
+}
+
+func (m *Memory) Get(_ context.Context, tenant, id string) (*Itinerary, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	itinerary, ok := m.itineraries[tenant][id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return &itinerary, nil
+}
+
+func (m *Memory) GetByHash(_ context.Context, tenant, hash string) (*Itinerary, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	id, ok := m.hashes[tenant][hash]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	itinerary := m.itineraries[tenant][id]
+
+	return &itinerary, nil
+}
+
+func (m *Memory) List(_ context.Context, tenant string, page Page) ([]Itinerary, error) {
+	m.mu.RLock()
+	itineraries := make([]Itinerary, 0, len(m.itineraries[tenant]))
+	for _, itinerary := range m.itineraries[tenant] {
+		itinerary.Result = nil
+		itineraries = append(itineraries, itinerary)
+	}
+	m.mu.RUnlock()
+
+	// Same order as the postgres store: newest first, ties broken by ID.
+	sort.Slice(itineraries, func(i, j int) bool {
+		if !itineraries[i].CreatedAt.Equal(itineraries[j].CreatedAt) {
+			return itineraries[i].CreatedAt.After(itineraries[j].CreatedAt)
+		}
+
+		return itineraries[i].ID > itineraries[j].ID
+	})
+
+	start := min(max(page.Offset, 0), len(itineraries))
+	end := min(start+max(page.Limit, 0), len(itineraries))
+
+	return itineraries[start:end], nil
+}
+
+func (m *Memory) Delete(_ context.Context, tenant, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	itinerary, ok := m.itineraries[tenant][id]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(m.itineraries[tenant], id)
+	delete(m.hashes[tenant], itinerary.Hash)
+
+	return nil
+}
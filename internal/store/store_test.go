@@ -0,0 +1,89 @@
+package store_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/clock"
+	"github.com/dsha256/dispatcher/internal/store"
+)
+
+func TestMemory(t *testing.T) {
+	t.Parallel()
+
+	clk := clock.NewFake(time.Date(2025, 5, 1, 8, 0, 0, 0, time.UTC))
+	m := store.NewMemory(clk)
+	ctx := context.Background()
+
+	saved := make([]store.Itinerary, 0, 3)
+	for i := range 3 {
+		itinerary := store.Itinerary{Tenant: "acme", Hash: fmt.Sprint("hash-", i), Tickets: i + 1, Result: json.RawMessage(`{"linear_path":["JFK","LAX"]}`)}
+		if err := m.Save(ctx, &itinerary); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+		saved = append(saved, itinerary)
+		clk.Advance(time.Minute)
+	}
+	if saved[0].ID == "" || saved[0].ID == saved[1].ID || !saved[0].CreatedAt.Equal(time.Date(2025, 5, 1, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("Save() assigned %q at %v and %q, want distinct IDs and the clock's time", saved[0].ID, saved[0].CreatedAt, saved[1].ID)
+	}
+
+	// Saving a ticket set again replaces its result and keeps its ID and creation time.
+	again := store.Itinerary{Tenant: "acme", Hash: "hash-1", Tickets: 2, Result: json.RawMessage(`{"linear_path":["JFK","LAX","SFO"]}`)}
+	if err := m.Save(ctx, &again); err != nil || again.ID != saved[1].ID || !again.CreatedAt.Equal(saved[1].CreatedAt) {
+		t.Errorf("Save() of a saved hash = %+v, %v, want the ID and creation time of %+v", again, err, saved[1])
+	}
+	saved[1] = again
+
+	got, err := m.Get(ctx, "acme", saved[1].ID)
+	if err != nil || got.Tickets != 2 || string(got.Result) != string(saved[1].Result) {
+		t.Errorf("Get() = %+v, %v, want the second itinerary", got, err)
+	}
+	if _, err = m.Get(ctx, "other", saved[1].ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Get() of another tenant error = %v, want %v", err, store.ErrNotFound)
+	}
+	if got, err = m.GetByHash(ctx, "acme", "hash-2"); err != nil || got.ID != saved[2].ID {
+		t.Errorf("GetByHash() = %+v, %v, want the third itinerary", got, err)
+	}
+
+	tests := []struct {
+		name string
+		want []int
+		page store.Page
+	}{
+		{name: "Newest first", page: store.Page{Limit: 10}, want: []int{3, 2, 1}},
+		{name: "Limited", page: store.Page{Limit: 2}, want: []int{3, 2}},
+		{name: "Offset", page: store.Page{Offset: 2, Limit: 2}, want: []int{1}},
+		{name: "Past the end", page: store.Page{Offset: 5, Limit: 2}, want: []int{}},
+	}
+	for _, tt := range tests {
+		listed, err := m.List(ctx, "acme", tt.page)
+		if err != nil {
+			t.Fatalf("%s: List() error = %v", tt.name, err)
+		}
+		tickets := []int{}
+		for _, itinerary := range listed {
+			tickets = append(tickets, itinerary.Tickets)
+			if itinerary.Result != nil {
+				t.Errorf("%s: List() returned the result of %s", tt.name, itinerary.ID)
+			}
+		}
+		if len(tickets) != len(tt.want) || (len(tickets) > 0 && tickets[0] != tt.want[0]) {
+			t.Errorf("%s: List() = %v, want %v", tt.name, tickets, tt.want)
+		}
+	}
+
+	if err = m.Delete(ctx, "acme", saved[0].ID); err != nil {
+		t.Errorf("Delete() error = %v", err)
+	}
+	if err = m.Delete(ctx, "acme", saved[0].ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Delete() twice error = %v, want %v", err, store.ErrNotFound)
+	}
+	if _, err = m.GetByHash(ctx, "acme", "hash-0"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetByHash() after Delete() error = %v, want %v", err, store.ErrNotFound)
+	}
+}
@@ -0,0 +1,196 @@
+// Package summary renders reconstructed itineraries as short human-readable texts
+// for traveler notifications, e.g. "JFK → LAX → DXB, 2 segments, 1 stop".
+package summary
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+var (
+	ErrUnsupportedLocale = errors.New("unsupported locale")
+	ErrUnknownTemplate   = errors.New("unknown summary template")
+	ErrSummaryTooLong    = errors.New("summary too long")
+)
+
+const (
+	DefaultLocale = "en"
+	// DefaultTemplate names the template used when none is requested.
+	DefaultTemplate = "default"
+	// MaxSummaryBytes bounds the rendered summaries, whatever the length of the path.
+	MaxSummaryBytes = 1 << 20
+)
+
+// Data is what templates are executed against.
+type Data struct {
+	// Route is the path joined with arrows.
+	Route       string
+	Origin      string
+	Destination string
+	Path        []string
+	// Segments is the number of flights, Stops the number of intermediate airports.
+	Segments int
+	Stops    int
+}
+
+// Summarizer renders summaries in a single locale.
+type Summarizer struct {
+	tmpl   *template.Template
+	locale string
+}
+
+type locale struct {
+	// templates are the texts of the named templates, in the locale's language.
+	templates map[string]string
+	// singular reports whether a count takes the singular form.
+	singular func(n int) bool
+}
+
+func lookupLocale(name string) (locale, bool) {
+	one := func(n int) bool { return n == 1 }
+	switch name {
+	case "en":
+		return locale{
+			templates: map[string]string{
+				DefaultTemplate: `{{.Route}}, {{.Segments}} {{plural .Segments "segment" "segments"}}, {{.Stops}} {{plural .Stops "stop" "stops"}}`,
+				"endpoints":     `{{.Origin}} to {{.Destination}}, {{.Stops}} {{plural .Stops "stop" "stops"}}`,
+				"route":         `{{.Route}}`,
+			},
+			singular: one,
+		}, true
+	case "es":
+		return locale{
+			templates: map[string]string{
+				DefaultTemplate: `{{.Route}}, {{.Segments}} {{plural .Segments "tramo" "tramos"}}, {{.Stops}} {{plural .Stops "escala" "escalas"}}`,
+				"endpoints":     `{{.Origin}} a {{.Destination}}, {{.Stops}} {{plural .Stops "escala" "escalas"}}`,
+				"route":         `{{.Route}}`,
+			},
+			singular: one,
+		}, true
+	case "fr":
+		return locale{
+			templates: map[string]string{
+				DefaultTemplate: `{{.Route}}, {{.Segments}} {{plural .Segments "segment" "segments"}}, {{.Stops}} {{plural .Stops "escale" "escales"}}`,
+				"endpoints":     `{{.Origin}} à {{.Destination}}, {{.Stops}} {{plural .Stops "escale" "escales"}}`,
+				"route":         `{{.Route}}`,
+			},
+			singular: func(n int) bool { return n <= 1 },
+		}, true
+	case "de":
+		return locale{
+			templates: map[string]string{
+				DefaultTemplate: `{{.Route}}, {{.Segments}} {{plural .Segments "Segment" "Segmente"}}, {{.Stops}} {{plural .Stops "Zwischenstopp" "Zwischenstopps"}}`,
+				"endpoints":     `{{.Origin}} nach {{.Destination}}, {{.Stops}} {{plural .Stops "Zwischenstopp" "Zwischenstopps"}}`,
+				"route":         `{{.Route}}`,
+			},
+			singular: one,
+		}, true
+	default:
+		return locale{}, false
+	}
+}
+
+// Templates returns the names of the templates every locale has: DefaultTemplate, the route with its segment
+// and stop counts; "endpoints", the origin and destination with the stop count; and "route", the route alone.
+func Templates() []string {
+	return []string{DefaultTemplate, "endpoints", "route"}
+}
+
+// Locales returns the supported locales.
+func Locales() []string {
+	return []string{"de", "en", "es", "fr"}
+}
+
+// NegotiateLocale picks the first supported language of an Accept-Language header, ignoring weights and regions,
+// and falls back to DefaultLocale.
+func NegotiateLocale(acceptLanguage string) string {
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, _, _ := strings.Cut(strings.TrimSpace(part), ";")
+		lang, _, _ := strings.Cut(tag, "-")
+		lang = strings.ToLower(lang)
+		if _, ok := lookupLocale(lang); ok {
+			return lang
+		}
+	}
+
+	return DefaultLocale
+}
+
+// New creates a summarizer for the locale rendering the named template, one of Templates; an empty name is
+// DefaultTemplate. Templates are only defined server-side, never by callers.
+func New(localeName, templateName string) (*Summarizer, error) {
+	loc, ok := lookupLocale(localeName)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q, supported: %s", ErrUnsupportedLocale, localeName, strings.Join(Locales(), ", "))
+	}
+	if templateName == "" {
+		templateName = DefaultTemplate
+	}
+	text, ok := loc.templates[templateName]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q, supported: %s", ErrUnknownTemplate, templateName, strings.Join(Templates(), ", "))
+	}
+
+	tmpl := template.Must(template.New(templateName).Option("missingkey=error").Funcs(template.FuncMap{
+		"plural": func(n int, one, other string) string {
+			if loc.singular(n) {
+				return one
+			}
+
+			return other
+		},
+	}).Parse(text))
+
+	return &Summarizer{tmpl: tmpl, locale: localeName}, nil
+}
+
+// Locale returns the locale the summarizer renders in.
+func (s *Summarizer) Locale() string {
+	return s.locale
+}
+
+// Summarize renders the summary of a linear path, failing with ErrSummaryTooLong past MaxSummaryBytes and
+// with the context's error once it is done.
+func (s *Summarizer) Summarize(ctx context.Context, path []string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	data := Data{
+		Route: strings.Join(path, " → "),
+		Path:  path,
+	}
+	if len(path) > 0 {
+		data.Origin = path[0]
+		data.Destination = path[len(path)-1]
+		data.Segments = len(path) - 1
+		data.Stops = max(len(path)-2, 0)
+	}
+
+	out := &cappedWriter{ctx: ctx}
+	if err := s.tmpl.Execute(out, data); err != nil {
+		return "", err
+	}
+
+	return out.b.String(), nil
+}
+
+// cappedWriter collects the output of a template up to MaxSummaryBytes, failing once the context is done.
+type cappedWriter struct {
+	ctx context.Context //nolint:containedctx // The writer lives for one template execution.
+	b   strings.Builder
+}
+
+func (w *cappedWriter) Write(p []byte) (int, error) {
+	if err := w.ctx.Err(); err != nil {
+		return 0, err
+	}
+	if w.b.Len()+len(p) > MaxSummaryBytes {
+		return 0, fmt.Errorf("%w: longer than %d bytes", ErrSummaryTooLong, MaxSummaryBytes)
+	}
+
+	return w.b.Write(p)
+}
@@ -0,0 +1,132 @@
+package summary_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dsha256/dispatcher/internal/summary"
+)
+
+func TestSummarize(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		err      error
+		name     string
+		locale   string
+		template string
+		want     string
+		path     []string
+	}{
+		{
+			name:   "English",
+			locale: "en",
+			path:   []string{"JFK", "LAX", "DXB", "SFO"},
+			want:   "JFK → LAX → DXB → SFO, 3 segments, 2 stops",
+		},
+		{
+			name:   "English nonstop",
+			locale: "en",
+			path:   []string{"JFK", "LAX"},
+			want:   "JFK → LAX, 1 segment, 0 stops",
+		},
+		{
+			name:   "French singular zero",
+			locale: "fr",
+			path:   []string{"JFK", "LAX"},
+			want:   "JFK → LAX, 1 segment, 0 escale",
+		},
+		{
+			name:   "German",
+			locale: "de",
+			path:   []string{"JFK", "LAX", "SFO"},
+			want:   "JFK → LAX → SFO, 2 Segmente, 1 Zwischenstopp",
+		},
+		{
+			name:     "Endpoints template",
+			locale:   "en",
+			template: "endpoints",
+			path:     []string{"JFK", "LAX", "SFO"},
+			want:     "JFK to SFO, 1 stop",
+		},
+		{
+			name:     "Route template",
+			locale:   "de",
+			template: "route",
+			path:     []string{"JFK", "LAX", "SFO"},
+			want:     "JFK → LAX → SFO",
+		},
+		{
+			name:   "Unsupported locale",
+			locale: "xx",
+			path:   []string{"JFK", "LAX"},
+			err:    summary.ErrUnsupportedLocale,
+		},
+		{
+			name:     "Caller-supplied template",
+			locale:   "en",
+			template: `{{.Route}}{{.Route}}`,
+			path:     []string{"JFK", "LAX"},
+			err:      summary.ErrUnknownTemplate,
+		},
+		{
+			name:     "Too long",
+			locale:   "en",
+			template: "route",
+			path:     strings.Split(strings.Repeat("JFK,", summary.MaxSummaryBytes/8)+"LAX", ","),
+			err:      summary.ErrSummaryTooLong,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			summarizer, err := summary.New(tt.locale, tt.template)
+			var got string
+			if err == nil {
+				got, err = summarizer.Summarize(context.Background(), tt.path)
+			}
+
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("error = %v; want %v", err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("Summarize() = %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSummarizeCanceled(t *testing.T) {
+	t.Parallel()
+
+	summarizer, err := summary.New("en", "")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err = summarizer.Summarize(ctx, []string{"JFK", "LAX"}); !errors.Is(err, context.Canceled) {
+		t.Errorf("error = %v; want %v", err, context.Canceled)
+	}
+}
+
+func TestNegotiateLocale(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"":                "en",
+		"de-DE,de;q=0.9":  "de",
+		"pt-BR, fr;q=0.8": "fr",
+		"ES":              "es",
+		"ja, zh-CN;q=0.5": "en",
+	}
+	for header, want := range tests {
+		if got := summary.NegotiateLocale(header); got != want {
+			t.Errorf("NegotiateLocale(%q) = %q; want %q", header, got, want)
+		}
+	}
+}
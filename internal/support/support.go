@@ -0,0 +1,324 @@
+package support
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"runtime"
+	"runtime/pprof"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/dsha256/dispatcher/internal/clock"
+	"github.com/dsha256/dispatcher/internal/config"
+	"github.com/dsha256/dispatcher/internal/logbuffer"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are substrings of config keys whose values never leave the process.
+func sensitiveKeys() []string {
+	return []string{"secret", "password", "token", "key", "credential", "dsn"}
+}
+
+// ticketKeys are the log attributes carrying ticket data, e.g. payloads or error messages naming airports,
+// whose values never leave the process.
+func ticketKeys() []string {
+	return []string{"error", "payload", "tickets", "linear_path"}
+}
+
+// Bundler collects diagnostic data into a single archive that can be attached to support tickets.
+type Bundler struct {
+	started     time.Time
+	clock       clock.Clock
+	cfg         *config.Config
+	logs        *logbuffer.Buffer
+	lastFailure failure
+	mu          sync.RWMutex
+}
+
+type failure struct {
+	At          time.Time `json:"at"`
+	PayloadHash string    `json:"payload_hash,omitempty"`
+	ErrorCode   string    `json:"error_code"`
+}
+
+type metrics struct {
+	StartedAt      time.Time `json:"started_at"`
+	Uptime         string    `json:"uptime"`
+	GoVersion      string    `json:"go_version"`
+	Goroutines     int       `json:"goroutines"`
+	NumCPU         int       `json:"num_cpu"`
+	HeapAlloc      uint64    `json:"heap_alloc_bytes"`
+	HeapObjects    uint64    `json:"heap_objects"`
+	TotalAlloc     uint64    `json:"total_alloc_bytes"`
+	Sys            uint64    `json:"sys_bytes"`
+	NumGC          uint32    `json:"num_gc"`
+	PauseTotalNs   uint64    `json:"gc_pause_total_ns"`
+	LastGCUnixNano uint64    `json:"last_gc_unix_nano"`
+}
+
+func NewBundler(clk clock.Clock, cfg *config.Config, logs *logbuffer.Buffer) *Bundler {
+	return &Bundler{
+		started: clk.Now(),
+		clock:   clk,
+		cfg:     cfg,
+		logs:    logs,
+	}
+}
+
+// SetConfig replaces the configuration reported, e.g. once reloaded.
+func (b *Bundler) SetConfig(cfg *config.Config) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.cfg = cfg
+}
+
+func (b *Bundler) config() *config.Config {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return b.cfg
+}
+
+// RecordFailure remembers the hash of the latest payload that failed to process and the code of its error.
+// Only the hash and code are kept, not the error message naming tickets, so the bundle never carries customer
+// data.
+func (b *Bundler) RecordFailure(payload []byte, code string) {
+	if b == nil {
+		return
+	}
+
+	// Streamed payloads are not buffered, so there is nothing to hash.
+	var hash string
+	if payload != nil {
+		sum := sha256.Sum256(payload)
+		hash = hex.EncodeToString(sum[:])
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastFailure = failure{
+		At:          b.clock.Now().UTC(),
+		PayloadHash: hash,
+		ErrorCode:   code,
+	}
+}
+
+// Write streams a gzip compressed tar archive with the redacted config, recent logs,
+// a metrics snapshot, a goroutine dump and the last failing request payload hash.
+func (b *Bundler) Write(w io.Writer) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	files := []struct {
+		build func() ([]byte, error)
+		name  string
+	}{
+		{name: "config.yaml", build: b.redactedConfig},
+		{name: "logs.txt", build: b.recentLogs},
+		{name: "metrics.json", build: b.metricsSnapshot},
+		{name: "goroutines.txt", build: goroutineDump},
+		{name: "last_failure.json", build: b.failure},
+	}
+
+	now := b.clock.Now()
+	for _, file := range files {
+		content, err := file.build()
+		if err != nil {
+			return fmt.Errorf("building %s: %w", file.name, err)
+		}
+
+		hdr := &tar.Header{
+			Name:    file.name,
+			Mode:    0o600,
+			Size:    int64(len(content)),
+			ModTime: now,
+		}
+		if err = tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err = tw.Write(content); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	return gz.Close()
+}
+
+// EffectiveConfig returns the configuration the service runs with, sensitive values redacted as in the
+// bundle, nil when the bundler has no configuration.
+func (b *Bundler) EffectiveConfig() (map[string]any, error) {
+	if b == nil {
+		return nil, nil //nolint:nilnil // There is no configuration to report.
+	}
+	cfg := b.config()
+	if cfg == nil {
+		return nil, nil //nolint:nilnil // There is no configuration to report.
+	}
+
+	node, err := redactedNode(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var out map[string]any
+	if err := node.Decode(&out); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+func (b *Bundler) redactedConfig() ([]byte, error) {
+	cfg := b.config()
+	if cfg == nil {
+		return []byte{}, nil
+	}
+
+	node, err := redactedNode(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return yaml.Marshal(node)
+}
+
+func redactedNode(cfg *config.Config) (*yaml.Node, error) {
+	var node yaml.Node
+	if err := node.Encode(cfg); err != nil {
+		return nil, err
+	}
+	redact(&node)
+
+	return &node, nil
+}
+
+// redact replaces the values of sensitive mapping keys in place. A sensitive map or list is replaced as a whole,
+// since its own keys may be the secrets, e.g. the API keys mapped to their tenants.
+func redact(node *yaml.Node) {
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if !isSensitive(key.Value) {
+				redact(value)
+
+				continue
+			}
+			if value.Value != "" || len(value.Content) > 0 {
+				*value = yaml.Node{}
+				value.SetString(redacted)
+			}
+		}
+
+		return
+	}
+
+	for _, child := range node.Content {
+		redact(child)
+	}
+}
+
+func isSensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range sensitiveKeys() {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (b *Bundler) recentLogs() ([]byte, error) {
+	if b.logs == nil {
+		return []byte{}, nil
+	}
+
+	lines := b.logs.Lines()
+	if len(lines) == 0 {
+		return []byte{}, nil
+	}
+	// Text log lines carry attributes as key=value, the value quoted when it has spaces.
+	text := regexp.MustCompile(`(^|\s)(` + strings.Join(ticketKeys(), "|") + `)=("(?:[^"\\]|\\.)*"|\S*)`)
+	for i, line := range lines {
+		lines[i] = redactLine(line, text)
+	}
+
+	return []byte(strings.Join(lines, "\n") + "\n"), nil
+}
+
+// redactLine replaces the values of the ticket attributes of a JSON or text log line.
+func redactLine(line string, text *regexp.Regexp) string {
+	var record map[string]any
+	decoder := json.NewDecoder(strings.NewReader(line))
+	decoder.UseNumber()
+	if decoder.Decode(&record) != nil {
+		return text.ReplaceAllString(line, "${1}${2}="+redacted)
+	}
+
+	for _, key := range ticketKeys() {
+		if _, ok := record[key]; ok {
+			record[key] = redacted
+		}
+	}
+	out, err := json.Marshal(record)
+	if err != nil {
+		return redacted
+	}
+
+	return string(out)
+}
+
+func (b *Bundler) metricsSnapshot() ([]byte, error) {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+
+	return json.MarshalIndent(metrics{
+		StartedAt:      b.started.UTC(),
+		Uptime:         b.clock.Now().Sub(b.started).String(),
+		GoVersion:      runtime.Version(),
+		Goroutines:     runtime.NumGoroutine(),
+		NumCPU:         runtime.NumCPU(),
+		HeapAlloc:      ms.HeapAlloc,
+		HeapObjects:    ms.HeapObjects,
+		TotalAlloc:     ms.TotalAlloc,
+		Sys:            ms.Sys,
+		NumGC:          ms.NumGC,
+		PauseTotalNs:   ms.PauseTotalNs,
+		LastGCUnixNano: ms.LastGC,
+	}, "", "  ")
+}
+
+func (b *Bundler) failure() ([]byte, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.lastFailure.At.IsZero() {
+		return []byte("{}\n"), nil
+	}
+
+	return json.MarshalIndent(b.lastFailure, "", "  ")
+}
+
+func goroutineDump() ([]byte, error) {
+	var sb strings.Builder
+	if err := pprof.Lookup("goroutine").WriteTo(&sb, 2); err != nil {
+		return nil, err
+	}
+
+	return []byte(sb.String()), nil
+}
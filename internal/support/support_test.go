@@ -0,0 +1,180 @@
+package support_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/dsha256/dispatcher/internal/clock"
+	"github.com/dsha256/dispatcher/internal/config"
+	"github.com/dsha256/dispatcher/internal/logbuffer"
+	"github.com/dsha256/dispatcher/internal/middleware"
+	"github.com/dsha256/dispatcher/internal/store/postgres"
+	"github.com/dsha256/dispatcher/internal/support"
+)
+
+func TestBundlerWrite(t *testing.T) {
+	t.Parallel()
+
+	logs := logbuffer.New(2)
+	_, _ = logs.Write([]byte("first\nsecond\nthird\n"))
+
+	cfg := &config.Config{
+		Server:   config.Server{Port: 3000},
+		Postgres: postgres.Config{DSN: "postgres://dispatcher:hunter2@db:5432/dispatcher"},
+	}
+	b := support.NewBundler(clock.Real{}, cfg, logs)
+	b.RecordFailure([]byte(`{"tickets":[["JFK","JFK"]]}`), "CYCLE")
+	files := readBundle(t, b)
+
+	for _, name := range []string{"config.yaml", "logs.txt", "metrics.json", "goroutines.txt", "last_failure.json"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("bundle is missing %s", name)
+		}
+	}
+
+	if files["logs.txt"] != "second\nthird\n" {
+		t.Errorf("logs.txt = %q; want only the two most recent lines", files["logs.txt"])
+	}
+
+	if !strings.Contains(files["config.yaml"], "port: 3000") {
+		t.Errorf("config.yaml = %q; want the server port", files["config.yaml"])
+	}
+
+	if strings.Contains(files["config.yaml"], "hunter2") {
+		t.Errorf("config.yaml = %q; want the postgres DSN redacted", files["config.yaml"])
+	}
+
+	if !strings.Contains(files["last_failure.json"], "payload_hash") || strings.Contains(files["last_failure.json"], "JFK") {
+		t.Errorf("last_failure.json = %q; want the payload hash only", files["last_failure.json"])
+	}
+}
+
+func TestBundlerRedactsTicketData(t *testing.T) {
+	t.Parallel()
+
+	logs := logbuffer.New(4)
+	_, _ = logs.Write([]byte(strings.Join([]string{
+		`time=2025-05-01T08:00:00Z level=WARN msg="malformed tickets" error="ticket 1: JFK -> JFK" path=/api/v1/dispatcher/itinerary`,
+		`time=2025-05-01T08:00:00Z level=WARN msg="error decoding request body" error=EOF payload={Tickets:[[LAX,DXB]]}`,
+		`{"time":"2025-05-01T08:00:00Z","level":"WARN","msg":"error calculating linear path","error":"cycle at SFO","payload":{"tickets":[["SFO","SFO"]]},"path":"/api/v1/dispatcher/itinerary"}`,
+		"",
+	}, "\n")))
+
+	files := readBundle(t, support.NewBundler(clock.Real{}, nil, logs))
+	for _, code := range []string{"JFK", "LAX", "DXB", "SFO"} {
+		if strings.Contains(files["logs.txt"], code) {
+			t.Errorf("logs.txt = %q; want the ticket data redacted", files["logs.txt"])
+		}
+	}
+	if strings.Count(files["logs.txt"], "[REDACTED]") != 5 || strings.Count(files["logs.txt"], "/api/v1/dispatcher/itinerary") != 2 {
+		t.Errorf("logs.txt = %q; want the ticket attributes only redacted", files["logs.txt"])
+	}
+}
+
+// readBundle returns the contents of the bundle's files by name.
+func readBundle(t *testing.T, b *support.Bundler) map[string]string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := b.Write(&buf); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	gz, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatalf("gzip.NewReader() error = %v", err)
+	}
+	tr := tar.NewReader(gz)
+
+	files := map[string]string{}
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("tar.Next() error = %v", err)
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatalf("reading %s: %v", hdr.Name, err)
+		}
+		files[hdr.Name] = string(content)
+	}
+
+	return files
+}
+
+func TestBundlerEffectiveConfig(t *testing.T) {
+	t.Parallel()
+
+	cfg := &config.Config{
+		Server:   config.Server{Port: 3000},
+		Postgres: postgres.Config{DSN: "postgres://dispatcher:hunter2@db:5432/dispatcher"},
+	}
+	effective, err := support.NewBundler(clock.Real{}, cfg, nil).EffectiveConfig()
+	if err != nil {
+		t.Fatalf("EffectiveConfig() error = %v", err)
+	}
+
+	server, _ := effective["server"].(map[string]any)
+	if server["port"] != 3000 {
+		t.Errorf("server.port = %v; want 3000", server["port"])
+	}
+	postgresCfg, _ := effective["postgres"].(map[string]any)
+	if postgresCfg["dsn"] != "[REDACTED]" {
+		t.Errorf("postgres.dsn = %v; want it redacted", postgresCfg["dsn"])
+	}
+}
+
+func TestBundlerRedactsMapSecrets(t *testing.T) {
+	t.Parallel()
+
+	cfg := &config.Config{
+		Signing: middleware.Signing{Secrets: map[string]string{"partner": "signing-secret"}, Enabled: true},
+		Tenants: middleware.Tenants{
+			APIKeys: map[string]string{"acme-api-key": "acme"},
+			Clients: map[string]string{"partner": "globex"},
+		},
+	}
+	b := support.NewBundler(clock.Real{}, cfg, nil)
+	effective, err := b.EffectiveConfig()
+	if err != nil {
+		t.Fatalf("EffectiveConfig() error = %v", err)
+	}
+	bundled := readBundle(t, b)["config.yaml"]
+
+	tests := []struct {
+		name    string
+		section string
+		key     string
+		secret  string
+	}{
+		{name: "signing secrets", section: "signing", key: "secrets", secret: "signing-secret"},
+		{name: "API keys", section: "tenants", key: "api_keys", secret: "acme-api-key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			section, _ := effective[tt.section].(map[string]any)
+			if section[tt.key] != "[REDACTED]" {
+				t.Errorf("%s.%s = %v; want it redacted as a whole", tt.section, tt.key, section[tt.key])
+			}
+			if strings.Contains(bundled, tt.secret) {
+				t.Errorf("config.yaml = %q; want %q redacted", bundled, tt.secret)
+			}
+		})
+	}
+
+	// The signing clients mapped to tenants are not secrets.
+	tenants, _ := effective["tenants"].(map[string]any)
+	if clients, _ := tenants["clients"].(map[string]any); clients["partner"] != "globex" {
+		t.Errorf("tenants.clients = %v; want them kept", tenants["clients"])
+	}
+}
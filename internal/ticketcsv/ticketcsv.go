@@ -0,0 +1,160 @@
+// Package ticketcsv reads tickets from CSV, the format airline ops exports come in.
+package ticketcsv
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/dispatcher"
+)
+
+var (
+	ErrInvalidCSV     = errors.New("invalid ticket CSV")
+	ErrInvalidMapping = errors.New("invalid CSV column mapping")
+)
+
+// Column is the ticket field a CSV column maps to.
+type Column string
+
+const (
+	ColumnFrom      Column = "from"
+	ColumnTo        Column = "to"
+	ColumnPrice     Column = "price"
+	ColumnDepartsAt Column = "departs_at"
+	ColumnArrivesAt Column = "arrives_at"
+	ColumnFlightNo  Column = "flight_no"
+	ColumnCurrency  Column = "currency"
+	// ColumnSkip ignores the column.
+	ColumnSkip Column = "-"
+)
+
+// Mapping maps CSV columns, by position, to ticket fields.
+type Mapping []Column
+
+// DefaultMapping is from,to,price,departs_at; trailing columns may be omitted.
+func DefaultMapping() Mapping {
+	return Mapping{ColumnFrom, ColumnTo, ColumnPrice, ColumnDepartsAt}
+}
+
+// ParseMapping parses a comma-separated mapping such as "from,to,-,price".
+func ParseMapping(s string) (Mapping, error) {
+	fields := strings.Split(s, ",")
+	columns := make([]Column, 0, len(fields))
+	for _, field := range fields {
+		columns = append(columns, Column(strings.ToLower(strings.TrimSpace(field))))
+	}
+	mapping := Mapping(columns)
+
+	return mapping, mapping.Validate()
+}
+
+// Validate checks that every column is known, appears once, and that from and to are mapped.
+func (m Mapping) Validate() error {
+	seen := make(map[Column]bool, len(m))
+	for _, column := range m {
+		switch column {
+		case ColumnFrom, ColumnTo, ColumnPrice, ColumnDepartsAt, ColumnArrivesAt, ColumnFlightNo, ColumnCurrency:
+			if seen[column] {
+				return fmt.Errorf("%w: duplicate column %q", ErrInvalidMapping, column)
+			}
+			seen[column] = true
+		case ColumnSkip:
+		default:
+			return fmt.Errorf("%w: unknown column %q", ErrInvalidMapping, column)
+		}
+	}
+	if !seen[ColumnFrom] || !seen[ColumnTo] {
+		return fmt.Errorf("%w: from and to columns are required", ErrInvalidMapping)
+	}
+
+	return nil
+}
+
+// Read reads one ticket per record using the mapping. When the first record is a header naming
+// the columns, e.g. "to,from,price", it overrides the mapping and is skipped. Empty cells leave the field unset;
+// times are RFC 3339.
+func Read(r io.Reader, mapping Mapping) ([]dispatcher.Ticket, error) {
+	if err := mapping.Validate(); err != nil {
+		return nil, err
+	}
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	tickets := []dispatcher.Ticket{}
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return tickets, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidCSV, err)
+		}
+		if line == 1 {
+			if header, err := ParseMapping(strings.Join(record, ",")); err == nil {
+				mapping = header
+
+				continue
+			}
+		}
+
+		ticket, err := parseRecord(record, mapping)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %w", ErrInvalidCSV, line, err)
+		}
+		tickets = append(tickets, ticket)
+	}
+}
+
+func parseRecord(record []string, mapping Mapping) (dispatcher.Ticket, error) {
+	var ticket dispatcher.Ticket
+	for i, column := range mapping {
+		if i >= len(record) {
+			if column == ColumnFrom || column == ColumnTo {
+				return ticket, fmt.Errorf("missing %s column", column)
+			}
+
+			break
+		}
+		value := strings.TrimSpace(record[i])
+		if value == "" {
+			continue
+		}
+
+		switch column {
+		case ColumnFrom:
+			ticket.From = value
+		case ColumnTo:
+			ticket.To = value
+		case ColumnFlightNo:
+			ticket.FlightNo = value
+		case ColumnCurrency:
+			ticket.Currency = value
+		case ColumnPrice:
+			price, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return ticket, fmt.Errorf("invalid price %q", value)
+			}
+			ticket.Price = &price
+		case ColumnDepartsAt, ColumnArrivesAt:
+			at, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return ticket, fmt.Errorf("invalid %s %q, expected RFC 3339", column, value)
+			}
+			if column == ColumnDepartsAt {
+				ticket.DepartsAt = &at
+			} else {
+				ticket.ArrivesAt = &at
+			}
+		case ColumnSkip:
+		}
+	}
+
+	return ticket, nil
+}
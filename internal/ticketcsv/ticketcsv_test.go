@@ -0,0 +1,94 @@
+package ticketcsv_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/ticketcsv"
+)
+
+func TestRead(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		err     error
+		name    string
+		input   string
+		columns string
+		want    string
+	}{
+		{
+			name:  "Default mapping",
+			input: "JFK,LAX,120.5,2025-05-01T06:00:00Z\nLAX,DXB\n",
+			want:  "JFK-LAX 120.5 2025-05-01T06:00:00Z|LAX-DXB - -|",
+		},
+		{
+			name:  "Header overrides the mapping",
+			input: "to,from,flight_no\nLAX,JFK,AA1\n",
+			want:  "JFK-LAX - -|",
+		},
+		{
+			name:    "Configured mapping",
+			input:   "AA1,JFK,LAX\n",
+			columns: "-,from,to",
+			want:    "JFK-LAX - -|",
+		},
+		{
+			name:  "Invalid price",
+			input: "JFK,LAX,cheap\n",
+			err:   ticketcsv.ErrInvalidCSV,
+		},
+		{
+			name:  "Missing destination",
+			input: "JFK\n",
+			err:   ticketcsv.ErrInvalidCSV,
+		},
+		{
+			name:    "Mapping without destination",
+			input:   "JFK,LAX\n",
+			columns: "from,price",
+			err:     ticketcsv.ErrInvalidMapping,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mapping := ticketcsv.DefaultMapping()
+			if tt.columns != "" {
+				mapping = ticketcsv.Mapping{}
+				for _, column := range strings.Split(tt.columns, ",") {
+					mapping = append(mapping, ticketcsv.Column(column))
+				}
+			}
+
+			tickets, err := ticketcsv.Read(strings.NewReader(tt.input), mapping)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Read() error = %v; want %v", err, tt.err)
+			}
+
+			var got strings.Builder
+			for _, ticket := range tickets {
+				got.WriteString(ticket.From + "-" + ticket.To)
+				if ticket.Price != nil {
+					fmt.Fprintf(&got, " %v", *ticket.Price)
+				} else {
+					got.WriteString(" -")
+				}
+				if ticket.DepartsAt != nil {
+					got.WriteString(" " + ticket.DepartsAt.Format(time.RFC3339))
+				} else {
+					got.WriteString(" -")
+				}
+				got.WriteString("|")
+			}
+			if got.String() != tt.want {
+				t.Errorf("Read() = %q; want %q", got.String(), tt.want)
+			}
+		})
+	}
+}
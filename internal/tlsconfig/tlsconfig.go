@@ -0,0 +1,117 @@
+// Package tlsconfig terminates TLS natively, for deployments that cannot put the service behind a proxy:
+// it loads the certificate, key and client CAs from files, reloads them while the service runs, e.g. when
+// certificates are rotated, and optionally verifies client certificates (mutual TLS).
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+)
+
+var (
+	ErrMissingCertificate = errors.New("tls cert_file and key_file are required")
+	ErrNoClientCAs        = errors.New("no client CA certificates found")
+	ErrUnknownClientAuth  = errors.New("unknown tls client_auth")
+)
+
+// Client certificate policies of mutual TLS.
+const (
+	// ClientAuthRequire refuses connections without a client certificate signed by a client CA.
+	ClientAuthRequire = "require"
+	// ClientAuthVerifyIfGiven accepts connections without a client certificate, and verifies those with one.
+	ClientAuthVerifyIfGiven = "verify_if_given"
+)
+
+type Config struct {
+	// CertFile and KeyFile are the PEM server certificate chain and private key.
+	CertFile string `json:"cert_file" yaml:"cert_file"`
+	KeyFile  string `json:"key_file"  yaml:"key_file"`
+	// ClientCAFile enables mutual TLS: client certificates are verified against its PEM CA certificates.
+	ClientCAFile string `json:"client_ca_file" yaml:"client_ca_file"`
+	// ClientAuth is the client certificate policy of mutual TLS: ClientAuthRequire (the default) or
+	// ClientAuthVerifyIfGiven.
+	ClientAuth string `json:"client_auth" yaml:"client_auth"`
+	Enabled    bool   `json:"enabled"     yaml:"enabled"`
+}
+
+// Reloader serves the certificate and client CAs last loaded from the files. It is safe for concurrent use.
+type Reloader struct {
+	loaded     atomic.Pointer[loaded]
+	cfg        Config
+	clientAuth tls.ClientAuthType
+}
+
+type loaded struct {
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+}
+
+// New loads the files of the configuration.
+func New(cfg Config) (*Reloader, error) {
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return nil, ErrMissingCertificate
+	}
+
+	r := &Reloader{cfg: cfg, clientAuth: tls.NoClientCert}
+	if cfg.ClientCAFile != "" {
+		switch cfg.ClientAuth {
+		case "", ClientAuthRequire:
+			r.clientAuth = tls.RequireAndVerifyClientCert
+		case ClientAuthVerifyIfGiven:
+			r.clientAuth = tls.VerifyClientCertIfGiven
+		default:
+			return nil, fmt.Errorf("%w %q", ErrUnknownClientAuth, cfg.ClientAuth)
+		}
+	}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Reload loads the files again; connections made since use the new certificate and client CAs. On error,
+// the previous ones are kept.
+func (r *Reloader) Reload() error {
+	cert, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+	if err != nil {
+		return fmt.Errorf("loading tls certificate: %w", err)
+	}
+
+	next := &loaded{cert: &cert}
+	if r.cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.cfg.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("reading tls client CAs: %w", err)
+		}
+		next.clientCAs = x509.NewCertPool()
+		if !next.clientCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("%w in %s", ErrNoClientCAs, r.cfg.ClientCAFile)
+		}
+	}
+	r.loaded.Store(next)
+
+	return nil
+}
+
+// TLSConfig returns the server TLS configuration, with the certificate and client CAs last loaded.
+func (r *Reloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			current := r.loaded.Load()
+
+			return &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*current.cert},
+				ClientAuth:   r.clientAuth,
+				ClientCAs:    current.clientCAs,
+				NextProtos:   []string{"h2", "http/1.1"},
+			}, nil
+		},
+	}
+}
@@ -0,0 +1,237 @@
+package tlsconfig_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/tlsconfig"
+)
+
+// issued is a certificate and its key, signed by a CA or self-signed.
+type issued struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+	tls  tls.Certificate
+}
+
+func issue(t *testing.T, name string, parent *issued, isCA bool) *issued {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+
+	return &issued{
+		cert: cert,
+		key:  key,
+		pem:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		tls:  tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key},
+	}
+}
+
+// write writes the certificate and key of the issued certificate to the files.
+func (i *issued) write(t *testing.T, certFile, keyFile string) {
+	t.Helper()
+
+	keyDER, err := x509.MarshalECPrivateKey(i.key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	if err = os.WriteFile(certFile, i.pem, 0o600); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+	if err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+}
+
+// serve starts a TLS server with the reloader's configuration.
+func serve(t *testing.T, reloader *tlsconfig.Reloader) string {
+	t.Helper()
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	srv.TLS = reloader.TLSConfig()
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	return srv.URL
+}
+
+// get requests the URL trusting the roots, with the client certificate when not nil, and returns the
+// server certificate's common name.
+func get(url string, roots *x509.CertPool, clientCert *tls.Certificate) (string, error) {
+	tlsConfig := &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}
+	if clientCert != nil {
+		// Certificates would only be sent when signed by a CA the server accepts.
+		tlsConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return clientCert, nil
+		}
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig, DisableKeepAlives: true}}
+
+	resp, err := client.Get(url) //nolint:noctx // Test request.
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	return resp.TLS.PeerCertificates[0].Subject.CommonName, nil
+}
+
+func TestReload(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	ca := issue(t, "ca", nil, true)
+	issue(t, "first", ca, false).write(t, certFile, keyFile)
+
+	reloader, err := tlsconfig.New(tlsconfig.Config{Enabled: true, CertFile: certFile, KeyFile: keyFile})
+	if err != nil {
+		t.Fatalf("Failed to load TLS configuration: %v", err)
+	}
+	url := serve(t, reloader)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	if name, err := get(url, roots, nil); err != nil || name != "first" {
+		t.Fatalf("Expected the first certificate, got %q: %v", name, err)
+	}
+
+	issue(t, "second", ca, false).write(t, certFile, keyFile)
+	if err = reloader.Reload(); err != nil {
+		t.Fatalf("Failed to reload: %v", err)
+	}
+	if name, err := get(url, roots, nil); err != nil || name != "second" {
+		t.Errorf("Expected the reloaded certificate, got %q: %v", name, err)
+	}
+
+	if err = os.WriteFile(keyFile, []byte("not a key"), 0o600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+	if err = reloader.Reload(); err == nil {
+		t.Error("Expected reloading an invalid key to fail")
+	}
+	if name, err := get(url, roots, nil); err != nil || name != "second" {
+		t.Errorf("Expected the previous certificate to be kept, got %q: %v", name, err)
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	clientCAFile := filepath.Join(dir, "client-ca.crt")
+	ca := issue(t, "ca", nil, true)
+	issue(t, "server", ca, false).write(t, certFile, keyFile)
+	clientCA := issue(t, "client-ca", nil, true)
+	if err := os.WriteFile(clientCAFile, clientCA.pem, 0o600); err != nil {
+		t.Fatalf("Failed to write client CA: %v", err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	trusted := issue(t, "partner", clientCA, false).tls
+	untrusted := issue(t, "stranger", issue(t, "other-ca", nil, true), false).tls
+
+	tests := []struct {
+		clientCert *tls.Certificate
+		name       string
+		clientAuth string
+		wantErr    bool
+	}{
+		{name: "Trusted client", clientCert: &trusted},
+		{name: "Untrusted client", clientCert: &untrusted, wantErr: true},
+		{name: "Without a client certificate", wantErr: true},
+		{name: "Optional client certificate", clientAuth: tlsconfig.ClientAuthVerifyIfGiven},
+		{name: "Optional untrusted client certificate", clientAuth: tlsconfig.ClientAuthVerifyIfGiven, clientCert: &untrusted, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			reloader, err := tlsconfig.New(tlsconfig.Config{
+				Enabled:      true,
+				CertFile:     certFile,
+				KeyFile:      keyFile,
+				ClientCAFile: clientCAFile,
+				ClientAuth:   tt.clientAuth,
+			})
+			if err != nil {
+				t.Fatalf("Failed to load TLS configuration: %v", err)
+			}
+
+			_, err = get(serve(t, reloader), roots, tt.clientCert)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Expected error %t, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		wantErr error
+		name    string
+		cfg     tlsconfig.Config
+	}{
+		{name: "Without a certificate", cfg: tlsconfig.Config{Enabled: true}, wantErr: tlsconfig.ErrMissingCertificate},
+		{
+			name:    "Unknown client auth",
+			cfg:     tlsconfig.Config{CertFile: "tls.crt", KeyFile: "tls.key", ClientCAFile: "ca.crt", ClientAuth: "maybe"},
+			wantErr: tlsconfig.ErrUnknownClientAuth,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := tlsconfig.New(tt.cfg); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
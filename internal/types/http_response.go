@@ -1,9 +1,21 @@
 package types
 
 type Response[T any] struct {
-	Data T      `json:"data,omitempty"`
-	Err  string `json:"err,omitempty"`
-	Msg  string `json:"msg,omitempty"`
+	Data    T      `json:"data,omitempty"`
+	Details any    `json:"details,omitempty"`
+	Err     string `json:"err,omitempty"`
+	Msg     string `json:"msg,omitempty"`
+	// MsgKey is the stable catalog key of Msg; clients should match on it rather than on the wording.
+	MsgKey string `json:"msg_key,omitempty"`
+	// Warnings are non-fatal notices about the request, e.g. approaching a limit.
+	Warnings []Warning `json:"warnings,omitempty"`
+}
+
+// Warning is a non-fatal notice about a request. Clients should match on Code, not on Message. Warnings about a
+// part of the payload, e.g. a leg, extend it with the fields locating that part.
+type Warning struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
 }
 
 func NewSuccessResponse[T any](msg string, data T) Response[T] {
@@ -13,8 +25,31 @@ func NewSuccessResponse[T any](msg string, data T) Response[T] {
 	}
 }
 
+func NewMessageResponse[T any](key, msg string, data T) Response[T] {
+	return Response[T]{
+		Msg:    msg,
+		MsgKey: key,
+		Data:   data,
+	}
+}
+
+func NewSuccessResponseWithWarnings[T any](msg string, data T, warnings []Warning) Response[T] {
+	return Response[T]{
+		Msg:      msg,
+		Data:     data,
+		Warnings: warnings,
+	}
+}
+
 func NewErrorResponse[T any](err string) Response[T] {
 	return Response[T]{
 		Err: err,
 	}
 }
+
+func NewErrorResponseWithDetails[T any](err string, details any) Response[T] {
+	return Response[T]{
+		Err:     err,
+		Details: details,
+	}
+}
@@ -0,0 +1,255 @@
+// Package apierror defines the stable error codes of the dispatcher API, for clients to switch on rather
+// than on error messages, which may change. Every error response carries its code: in error.code with
+// API v2, and in the X-Dispatcher-Error-Code header with API v1.
+//
+//	resp, err := http.Post(url, "application/json", body)
+//	...
+//	switch apierror.CodeOf(apierror.FromResponse(resp)) {
+//	case "":
+//		// Success.
+//	case apierror.CodeCycle, apierror.CodeMultipleStarts:
+//		// Ask the user for the missing tickets.
+//	case apierror.CodeUnavailable, apierror.CodeTimeout:
+//		// Retry later.
+//	}
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Header carries the code of API v1 error responses.
+const Header = "X-Dispatcher-Error-Code"
+
+// Code is a stable error code. Codes are never renamed or removed; new ones may be added, so clients
+// should handle unknown codes by their Status.
+type Code string
+
+const (
+	// CodeBadJSON is a request body that cannot be decoded, e.g. with an unknown field or a ticket that is
+	// not a pair.
+	CodeBadJSON Code = "BAD_JSON"
+	// CodeBadRequest is any other invalid request, e.g. an invalid page.
+	CodeBadRequest Code = "BAD_REQUEST"
+	// CodeMalformedTicket is a ticket that is not a non-empty source and destination.
+	CodeMalformedTicket Code = "MALFORMED_TICKET"
+	// CodeUnknownAirport is an airport code missing from the dataset, in strict mode.
+	CodeUnknownAirport Code = "UNKNOWN_AIRPORT"
+	// CodeDuplicateTicket is several tickets to the same destination.
+	CodeDuplicateTicket Code = "DUPLICATE_TICKET"
+	// CodeMultipleStarts is tickets with several possible starting points.
+	CodeMultipleStarts Code = "MULTIPLE_STARTS"
+	// CodeCycle is tickets forming a closed loop, without a starting point.
+	CodeCycle Code = "CYCLE"
+	// CodeDisconnected is tickets not all connected to the rest of the itinerary.
+	CodeDisconnected Code = "DISCONNECTED"
+	// CodeInfeasibleConnection is a connection breaking chronology or the minimum layover.
+	CodeInfeasibleConnection Code = "INFEASIBLE_CONNECTION"
+	// CodeConstraintViolated is an itinerary violating the request's constraints.
+	CodeConstraintViolated Code = "CONSTRAINT_VIOLATED"
+	// CodeUnknownStrategy is a strategy the service does not know.
+	CodeUnknownStrategy Code = "UNKNOWN_STRATEGY"
+	// CodeUnknownTieBreak is a tie-break policy the service does not know.
+	CodeUnknownTieBreak Code = "UNKNOWN_TIE_BREAK"
+	// CodeUnsupportedAlgorithmVersion is an algorithm version the service does not support.
+	CodeUnsupportedAlgorithmVersion Code = "UNSUPPORTED_ALGORITHM_VERSION"
+	// CodeUnknownStability is a stability the service does not know.
+	CodeUnknownStability Code = "UNKNOWN_STABILITY"
+	// CodeStreamingUnsupported is a streamed reconstruction with options only batch reconstruction supports.
+	CodeStreamingUnsupported Code = "STREAMING_UNSUPPORTED"
+	// CodeUnauthorized is a request to an authenticated endpoint without valid credentials.
+	CodeUnauthorized Code = "UNAUTHORIZED"
+	// CodeForbidden is a request the service refuses whatever its credentials, e.g. an admin change that is
+	// not enabled.
+	CodeForbidden Code = "FORBIDDEN"
+	// CodeNotFound is an unknown path or a missing resource, e.g. a saved itinerary.
+	CodeNotFound Code = "NOT_FOUND"
+	// CodeFeatureDisabled is an endpoint of a feature disabled on the service, e.g. itinerary storage.
+	CodeFeatureDisabled Code = "FEATURE_DISABLED"
+	// CodeMethodNotAllowed is a method the path does not accept.
+	CodeMethodNotAllowed Code = "METHOD_NOT_ALLOWED"
+	// CodeConflict is a request conflicting with an earlier one, e.g. an idempotency key reused with another
+	// payload or still in progress.
+	CodeConflict Code = "CONFLICT"
+	// CodeBodyTooLarge is a request body above the size limit.
+	CodeBodyTooLarge Code = "BODY_TOO_LARGE"
+	// CodeTooManyTickets is a ticket count above the hard limit.
+	CodeTooManyTickets Code = "TOO_MANY_TICKETS"
+	// CodeUnsupportedMediaType is a request body in a content type or encoding the endpoint does not take.
+	CodeUnsupportedMediaType Code = "UNSUPPORTED_MEDIA_TYPE"
+	// CodeCanceled is a request abandoned by the client.
+	CodeCanceled Code = "CANCELED"
+	// CodeTimeout is a request that outlasted its timeout.
+	CodeTimeout Code = "TIMEOUT"
+	// CodeUnavailable is a service not ready or shedding load; the request may be retried.
+	CodeUnavailable Code = "UNAVAILABLE"
+	// CodeInternal is an unexpected failure of the service.
+	CodeInternal Code = "INTERNAL"
+)
+
+// statusClientClosedRequest is the non-standard status of requests abandoned by the client.
+const statusClientClosedRequest = 499
+
+//nolint:gochecknoglobals // Read-only table of the codes.
+var statuses = map[Code]int{
+	CodeBadJSON:                     http.StatusBadRequest,
+	CodeBadRequest:                  http.StatusBadRequest,
+	CodeMalformedTicket:             http.StatusBadRequest,
+	CodeUnknownAirport:              http.StatusBadRequest,
+	CodeDuplicateTicket:             http.StatusBadRequest,
+	CodeMultipleStarts:              http.StatusBadRequest,
+	CodeCycle:                       http.StatusBadRequest,
+	CodeDisconnected:                http.StatusBadRequest,
+	CodeInfeasibleConnection:        http.StatusBadRequest,
+	CodeConstraintViolated:          http.StatusBadRequest,
+	CodeUnknownStrategy:             http.StatusBadRequest,
+	CodeUnknownTieBreak:             http.StatusBadRequest,
+	CodeUnsupportedAlgorithmVersion: http.StatusBadRequest,
+	CodeUnknownStability:            http.StatusBadRequest,
+	CodeStreamingUnsupported:        http.StatusBadRequest,
+	CodeNotFound:                    http.StatusNotFound,
+	CodeFeatureDisabled:             http.StatusNotFound,
+	CodeMethodNotAllowed:            http.StatusMethodNotAllowed,
+	CodeConflict:                    http.StatusConflict,
+	CodeBodyTooLarge:                http.StatusRequestEntityTooLarge,
+	CodeTooManyTickets:              http.StatusRequestEntityTooLarge,
+	CodeUnsupportedMediaType:        http.StatusUnsupportedMediaType,
+	CodeCanceled:                    statusClientClosedRequest,
+	CodeTimeout:                     http.StatusGatewayTimeout,
+	CodeUnavailable:                 http.StatusServiceUnavailable,
+	CodeInternal:                    http.StatusInternalServerError,
+}
+
+// Status returns the HTTP status of responses with the code, 500 for unknown codes.
+func (c Code) Status() int {
+	if status, ok := statuses[c]; ok {
+		return status
+	}
+
+	return http.StatusInternalServerError
+}
+
+// Known reports whether the code is one of this package's.
+func (c Code) Known() bool {
+	_, ok := statuses[c]
+
+	return ok
+}
+
+// Retryable reports whether a request failing with the code may succeed when sent again unchanged.
+func (c Code) Retryable() bool {
+	return c == CodeUnavailable || c == CodeTimeout
+}
+
+// FromStatus returns the code of error responses with the status and no more specific code.
+func FromStatus(status int) Code {
+	switch status {
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusMethodNotAllowed:
+		return CodeMethodNotAllowed
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusRequestEntityTooLarge:
+		return CodeBodyTooLarge
+	case http.StatusUnsupportedMediaType:
+		return CodeUnsupportedMediaType
+	case statusClientClosedRequest:
+		return CodeCanceled
+	case http.StatusServiceUnavailable, http.StatusTooManyRequests:
+		return CodeUnavailable
+	case http.StatusGatewayTimeout:
+		return CodeTimeout
+	}
+	if status >= http.StatusBadRequest && status < http.StatusInternalServerError {
+		return CodeBadRequest
+	}
+
+	return CodeInternal
+}
+
+// Error is an error response of the API.
+type Error struct {
+	// Details are the error's details as sent, e.g. a validation report, or nil.
+	Details json.RawMessage
+	Code    Code
+	Message string
+	Status  int
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("dispatcher API: %s (%d %s)", e.Message, e.Status, e.Code)
+}
+
+// Is matches the errors with the same code, so errors.Is(err, &apierror.Error{Code: apierror.CodeCycle})
+// tells a cycle.
+func (e *Error) Is(target error) bool {
+	var other *Error
+
+	return errors.As(target, &other) && other.Code == e.Code
+}
+
+// CodeOf returns the code of the *Error err wraps, "" when err is nil and CodeInternal for other errors.
+func CodeOf(err error) Code {
+	if err == nil {
+		return ""
+	}
+
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Code
+	}
+
+	return CodeInternal
+}
+
+// FromResponse reads the *Error of an error response, of either API version, and returns nil for
+// successful responses. It reads and closes the body of error responses.
+func FromResponse(resp *http.Response) error {
+	if resp.StatusCode < http.StatusBadRequest {
+		return nil
+	}
+	defer resp.Body.Close()
+
+	apiErr := &Error{Status: resp.StatusCode, Code: Code(resp.Header.Get(Header))}
+
+	// Both envelopes: v1 has err and details, v2 has error.
+	var body struct {
+		Error *struct {
+			Details json.RawMessage `json:"details"`
+			Code    Code            `json:"code"`
+			Message string          `json:"message"`
+		} `json:"error"`
+		Details json.RawMessage `json:"details"`
+		Err     string          `json:"err"`
+	}
+	payload, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("reading error response: %w", err)
+	}
+	// Bodies that are not JSON, e.g. from a proxy, only have a status.
+	_ = json.Unmarshal(payload, &body)
+
+	switch {
+	case body.Error != nil:
+		apiErr.Code, apiErr.Message, apiErr.Details = body.Error.Code, body.Error.Message, body.Error.Details
+	case body.Err != "":
+		apiErr.Message, apiErr.Details = body.Err, body.Details
+	}
+	if apiErr.Code == "" {
+		apiErr.Code = FromStatus(resp.StatusCode)
+	}
+	if apiErr.Message == "" {
+		apiErr.Message = http.StatusText(resp.StatusCode)
+	}
+
+	return apiErr
+}
@@ -0,0 +1,147 @@
+package apierror_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/dsha256/dispatcher/pkg/apierror"
+)
+
+func TestFromResponse(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		header      string
+		body        string
+		wantCode    apierror.Code
+		wantMessage string
+		wantDetails string
+		status      int
+	}{
+		{
+			name:        "Success",
+			status:      http.StatusOK,
+			body:        `{"data":{}}`,
+			wantCode:    "",
+			wantMessage: "",
+		},
+		{
+			name:        "Version 1 with a code",
+			status:      http.StatusBadRequest,
+			header:      string(apierror.CodeCycle),
+			body:        `{"err":"cycle in itinerary","details":{"tickets":2}}`,
+			wantCode:    apierror.CodeCycle,
+			wantMessage: "cycle in itinerary",
+			wantDetails: `{"tickets":2}`,
+		},
+		{
+			name:        "Version 1 without a code",
+			status:      http.StatusNotFound,
+			body:        `{"err":"not found"}`,
+			wantCode:    apierror.CodeNotFound,
+			wantMessage: "not found",
+		},
+		{
+			name:        "Version 2",
+			status:      http.StatusBadRequest,
+			body:        `{"error":{"code":"MULTIPLE_STARTS","message":"different starting points"},"meta":{}}`,
+			wantCode:    apierror.CodeMultipleStarts,
+			wantMessage: "different starting points",
+		},
+		{
+			name:        "Not JSON",
+			status:      http.StatusBadGateway,
+			body:        "<html>Bad Gateway</html>",
+			wantCode:    apierror.CodeInternal,
+			wantMessage: "Bad Gateway",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			resp := &http.Response{
+				StatusCode: tt.status,
+				Header:     http.Header{},
+				Body:       io.NopCloser(strings.NewReader(tt.body)),
+			}
+			if tt.header != "" {
+				resp.Header.Set(apierror.Header, tt.header)
+			}
+
+			err := apierror.FromResponse(resp)
+			if tt.wantCode == "" {
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+
+				return
+			}
+
+			var apiErr *apierror.Error
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("Expected an *apierror.Error, got %v", err)
+			}
+			if apiErr.Code != tt.wantCode || apiErr.Message != tt.wantMessage || apiErr.Status != tt.status {
+				t.Errorf("Expected %d %s: %q, got %+v", tt.status, tt.wantCode, tt.wantMessage, apiErr)
+			}
+			if string(apiErr.Details) != tt.wantDetails {
+				t.Errorf("Expected details %s, got %s", tt.wantDetails, apiErr.Details)
+			}
+		})
+	}
+}
+
+func TestCode(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		code          apierror.Code
+		wantStatus    int
+		wantKnown     bool
+		wantRetryable bool
+	}{
+		{code: apierror.CodeDuplicateTicket, wantStatus: http.StatusBadRequest, wantKnown: true},
+		{code: apierror.CodeFeatureDisabled, wantStatus: http.StatusNotFound, wantKnown: true},
+		{code: apierror.CodeTooManyTickets, wantStatus: http.StatusRequestEntityTooLarge, wantKnown: true},
+		{code: apierror.CodeUnavailable, wantStatus: http.StatusServiceUnavailable, wantKnown: true, wantRetryable: true},
+		{code: apierror.CodeTimeout, wantStatus: http.StatusGatewayTimeout, wantKnown: true, wantRetryable: true},
+		{code: "SOMETHING_NEW", wantStatus: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.code), func(t *testing.T) {
+			t.Parallel()
+
+			if got := tt.code.Status(); got != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, got)
+			}
+			if got := tt.code.Known(); got != tt.wantKnown {
+				t.Errorf("Expected known %t, got %t", tt.wantKnown, got)
+			}
+			if got := tt.code.Retryable(); got != tt.wantRetryable {
+				t.Errorf("Expected retryable %t, got %t", tt.wantRetryable, got)
+			}
+		})
+	}
+}
+
+func TestErrorIs(t *testing.T) {
+	t.Parallel()
+
+	err := fmt.Errorf("reconstructing: %w", &apierror.Error{Code: apierror.CodeCycle, Status: http.StatusBadRequest})
+
+	if !errors.Is(err, &apierror.Error{Code: apierror.CodeCycle}) {
+		t.Error("Expected the error to match its code")
+	}
+	if errors.Is(err, &apierror.Error{Code: apierror.CodeDisconnected}) {
+		t.Error("Expected the error not to match another code")
+	}
+	if got := apierror.CodeOf(err); got != apierror.CodeCycle {
+		t.Errorf("Expected code %s, got %s", apierror.CodeCycle, got)
+	}
+}
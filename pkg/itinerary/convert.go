@@ -0,0 +1,185 @@
+package itinerary
+
+import (
+	"context"
+	"errors"
+
+	"github.com/dsha256/dispatcher/internal/dispatcher"
+)
+
+func fromTicket(t dispatcher.Ticket) Ticket {
+	ticket := Ticket{
+		DepartsAt: t.DepartsAt,
+		ArrivesAt: t.ArrivesAt,
+		Price:     t.Price,
+		Metadata:  t.Metadata,
+		From:      t.From,
+		To:        t.To,
+		FlightNo:  t.FlightNo,
+		Currency:  t.Currency,
+	}
+	if pair := t.Pair(); len(pair) != 2 {
+		ticket.pair = pair
+	}
+
+	return ticket
+}
+
+func fromTickets(tickets []dispatcher.Ticket) []Ticket {
+	converted := make([]Ticket, 0, len(tickets))
+	for _, t := range tickets {
+		converted = append(converted, fromTicket(t))
+	}
+
+	return converted
+}
+
+func toTicket(t Ticket) dispatcher.Ticket {
+	ticket := dispatcher.Ticket{From: t.From, To: t.To}
+	if t.pair != nil {
+		ticket = dispatcher.TicketFromPair(t.pair)
+	}
+	ticket.DepartsAt = t.DepartsAt
+	ticket.ArrivesAt = t.ArrivesAt
+	ticket.Price = t.Price
+	ticket.Metadata = t.Metadata
+	ticket.FlightNo = t.FlightNo
+	ticket.Currency = t.Currency
+
+	return ticket
+}
+
+func toTickets(tickets []Ticket) []dispatcher.Ticket {
+	converted := make([]dispatcher.Ticket, 0, len(tickets))
+	for _, t := range tickets {
+		converted = append(converted, toTicket(t))
+	}
+
+	return converted
+}
+
+func fromLegs(legs []dispatcher.Leg) []Leg {
+	if legs == nil {
+		return nil
+	}
+	converted := make([]Leg, 0, len(legs))
+	for _, leg := range legs {
+		converted = append(converted, Leg(leg))
+	}
+
+	return converted
+}
+
+func toLegs(legs []Leg) []dispatcher.Leg {
+	if legs == nil {
+		return nil
+	}
+	converted := make([]dispatcher.Leg, 0, len(legs))
+	for _, leg := range legs {
+		converted = append(converted, dispatcher.Leg(leg))
+	}
+
+	return converted
+}
+
+func fromResult(result *dispatcher.Result) *Result {
+	if result == nil {
+		return nil
+	}
+
+	converted := &Result{AlgorithmVersion: result.AlgorithmVersion, Path: result.Path, Legs: fromLegs(result.Legs)}
+	for _, change := range result.Normalization {
+		converted.Normalization = append(converted.Normalization, CodeChange(change))
+	}
+
+	return converted
+}
+
+func toConstraints(constraints *Constraints) *dispatcher.Constraints {
+	if constraints == nil {
+		return nil
+	}
+	converted := dispatcher.Constraints(*constraints)
+
+	return &converted
+}
+
+func toSurfaceTransfers(transfers []SurfaceTransfer) []dispatcher.SurfaceTransfer {
+	converted := make([]dispatcher.SurfaceTransfer, 0, len(transfers))
+	for _, transfer := range transfers {
+		converted = append(converted, dispatcher.SurfaceTransfer(transfer))
+	}
+
+	return converted
+}
+
+func fromReport(report *dispatcher.ValidationReport) *ValidationReport {
+	if report == nil {
+		return nil
+	}
+
+	converted := &ValidationReport{
+		StartCandidates: report.StartCandidates,
+		EndCandidates:   report.EndCandidates,
+		Unbalanced:      make([]AirportDegree, 0, len(report.Unbalanced)),
+		Issues:          make([]ValidationIssue, 0, len(report.Issues)),
+		Valid:           report.Valid,
+	}
+	for _, degree := range report.Unbalanced {
+		converted.Unbalanced = append(converted.Unbalanced, AirportDegree(degree))
+	}
+	for _, issue := range report.Issues {
+		converted.Issues = append(converted.Issues, ValidationIssue{
+			Kind:    IssueKind(issue.Kind),
+			Airport: issue.Airport,
+			Detail:  issue.Detail,
+			Indexes: issue.Indexes,
+			Tickets: issue.Tickets,
+		})
+	}
+
+	return converted
+}
+
+// fromError returns the error of the package carrying the details of the service's error, e.g. a
+// *ValidationError for a *dispatcher.ValidationError; the sentinel errors are shared.
+func fromError(err error) error {
+	var (
+		validationErr *dispatcher.ValidationError
+		constraintErr *dispatcher.ConstraintError
+		connectionErr *dispatcher.ConnectionError
+	)
+	switch {
+	case errors.As(err, &validationErr):
+		return &ValidationError{Err: validationErr.Err, Report: fromReport(validationErr.Report)}
+	case errors.As(err, &constraintErr):
+		converted := ConstraintError(*constraintErr)
+
+		return &converted
+	case errors.As(err, &connectionErr):
+		converted := &ConnectionError{Connections: make([]InfeasibleConnection, 0, len(connectionErr.Connections))}
+		for _, connection := range connectionErr.Connections {
+			converted.Connections = append(converted.Connections, InfeasibleConnection(connection))
+		}
+
+		return converted
+	default:
+		return err
+	}
+}
+
+// reconstructor runs a Reconstructor as the service's.
+type reconstructor struct {
+	Reconstructor
+}
+
+func (r reconstructor) Reconstruct(ctx context.Context, tickets []dispatcher.Ticket, allowDuplicates bool) ([]string, []dispatcher.Leg, error) {
+	path, legs, err := r.Reconstructor.Reconstruct(ctx, fromTickets(tickets), allowDuplicates)
+
+	return path, toLegs(legs), err
+}
+
+// toReconstructor returns the service's Reconstructor running r.
+func toReconstructor(r Reconstructor) dispatcher.Reconstructor {
+	return reconstructor{Reconstructor: r}
+}
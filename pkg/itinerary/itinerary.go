@@ -0,0 +1,202 @@
+// Package itinerary is the public Go API of the itinerary reconstruction algorithm served by the dispatcher API.
+// It lets other Go services reconstruct and validate itineraries in-process instead of calling the HTTP API.
+//
+// The API is stable: results are identical to the HTTP API for the same AlgorithmVersion, and new behavior is
+// only added through new options. The types are the package's own, converted to and from the service's, so the
+// service's types can change without breaking it.
+package itinerary
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/dispatcher"
+	"github.com/dsha256/dispatcher/internal/embedding"
+)
+
+// Dispatcher reconstructs itineraries with fixed options, e.g. to serve them with pkg/server.
+type Dispatcher struct {
+	dispatcher *dispatcher.Dispatcher
+	opts       []Option
+}
+
+const (
+	StrategyDefault       = Strategy(dispatcher.StrategyDefault)
+	StrategyChronological = Strategy(dispatcher.StrategyChronological)
+
+	TieBreakSmallestFirst = TieBreak(dispatcher.TieBreakSmallestFirst)
+	TieBreakLargestFirst  = TieBreak(dispatcher.TieBreakLargestFirst)
+	TieBreakInputOrder    = TieBreak(dispatcher.TieBreakInputOrder)
+
+	// AlgorithmVersion is the version used unless WithAlgorithmVersion pins another one.
+	AlgorithmVersion = dispatcher.AlgorithmVersion
+)
+
+var (
+	ErrMalformedTicket             = dispatcher.ErrMalformedTicket
+	ErrUnknownAirport              = dispatcher.ErrUnknownAirport
+	ErrMultipleSameDestination     = dispatcher.ErrMultipleSameDestination
+	ErrDifferentStartingPoints     = dispatcher.ErrDifferentStartingPoints
+	ErrDisconnectedItinerary       = dispatcher.ErrDisconnectedItinerary
+	ErrCycleInItinerary            = dispatcher.ErrCycleInItinerary
+	ErrInfeasibleConnection        = dispatcher.ErrInfeasibleConnection
+	ErrUntimedTickets              = dispatcher.ErrUntimedTickets
+	ErrConstraintViolated          = dispatcher.ErrConstraintViolated
+	ErrUnknownStrategy             = dispatcher.ErrUnknownStrategy
+	ErrUnknownTieBreak             = dispatcher.ErrUnknownTieBreak
+	ErrConflictingAlias            = dispatcher.ErrConflictingAlias
+	ErrInvalidSurfaceTransfer      = dispatcher.ErrInvalidSurfaceTransfer
+	ErrUnsupportedAlgorithmVersion = dispatcher.ErrUnsupportedAlgorithmVersion
+	ErrTooManyTickets              = dispatcher.ErrTooManyTickets
+)
+
+// Option configures a reconstruction.
+type Option func(*options)
+
+type options = embedding.Options
+
+// WithStrategy picks between several valid orderings, StrategyDefault by default.
+func WithStrategy(strategy Strategy) Option {
+	return func(o *options) {
+		o.Request.Strategy = dispatcher.Strategy(strategy)
+	}
+}
+
+// WithTieBreak decides which destination is taken first when an airport has several, TieBreakSmallestFirst
+// by default.
+func WithTieBreak(tieBreak TieBreak) Option {
+	return func(o *options) {
+		o.Request.TieBreak = dispatcher.TieBreak(tieBreak)
+	}
+}
+
+// WithPreferredHubs routes through the airports with the highest weights whenever several valid orderings
+// allow it, e.g. HubWeights{"DXB": 1}.
+func WithPreferredHubs(weights HubWeights) Option {
+	return func(o *options) {
+		o.Request.PreferredHubs = dispatcher.HubWeights(weights)
+	}
+}
+
+// WithNormalizedCodes uppercases and trims airport codes before reconstruction; Result.Normalization lists
+// the codes rewritten.
+func WithNormalizedCodes() Option {
+	return func(o *options) {
+		normalize := true
+		o.Request.NormalizeCodes = &normalize
+	}
+}
+
+// WithAliases replaces airport codes before reconstruction, e.g. Aliases{"NYC": {"JFK", "LGA", "EWR"}} so that
+// flying into one New York airport and out of another connects.
+func WithAliases(aliases Aliases) Option {
+	return func(o *options) {
+		o.Request.Aliases = dispatcher.Aliases(aliases)
+	}
+}
+
+// WithSurfaceTransfers allows the gaps of an open-jaw trip, e.g. SurfaceTransfer{From: "LAX", To: "SFO"} to
+// fly into LAX and out of SFO: the legs stitching them are marked SurfaceTransfer and point to no ticket.
+func WithSurfaceTransfers(transfers ...SurfaceTransfer) Option {
+	return func(o *options) {
+		o.Request.SurfaceTransfers = toSurfaceTransfers(transfers)
+	}
+}
+
+// WithCustomStrategy registers the reconstructor of a strategy, replacing the built-in one of the same name,
+// and picks it.
+func WithCustomStrategy(strategy Strategy, reconstructor Reconstructor) Option {
+	return func(o *options) {
+		o.Dispatcher = append(o.Dispatcher, dispatcher.WithStrategy(dispatcher.Strategy(strategy), toReconstructor(reconstructor)))
+		o.Request.Strategy = dispatcher.Strategy(strategy)
+	}
+}
+
+// WithConstraints rejects itineraries violating the constraints.
+func WithConstraints(constraints *Constraints) Option {
+	return func(o *options) {
+		o.Request.Constraints = toConstraints(constraints)
+	}
+}
+
+// WithAlgorithmVersion pins the algorithm version so a stored input always yields the same path.
+func WithAlgorithmVersion(version string) Option {
+	return func(o *options) {
+		o.Request.AlgorithmVersion = version
+	}
+}
+
+// WithMinLayover sets the minimum layover enforced when every ticket has a departure time.
+func WithMinLayover(d time.Duration) Option {
+	return func(o *options) {
+		o.Dispatcher = append(o.Dispatcher, dispatcher.WithMinLayover(d))
+	}
+}
+
+// WithDuplicates accepts repeated identical tickets and reports the multiplicity of every leg.
+func WithDuplicates() Option {
+	return func(o *options) {
+		o.Dispatcher = append(o.Dispatcher, dispatcher.WithDuplicateTolerance(true))
+	}
+}
+
+// WithKnownAirports rejects tickets whose airport codes are not known.
+func WithKnownAirports(known func(code string) bool) Option {
+	return func(o *options) {
+		o.Dispatcher = append(o.Dispatcher, dispatcher.WithAirportValidation(known, true))
+	}
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o
+}
+
+// NewDispatcher returns a Dispatcher configured by the options. The strategy picked by WithStrategy or
+// WithCustomStrategy and the policy of WithTieBreak become the defaults of requests not naming one; other
+// request options such as WithConstraints do not apply.
+func NewDispatcher(opts ...Option) *Dispatcher {
+	return &Dispatcher{dispatcher: newOptions(opts).NewDispatcher(), opts: opts}
+}
+
+// Options returns the options the dispatcher was created with, e.g. to create another one like it.
+func (d *Dispatcher) Options() []Option {
+	return slices.Clone(d.opts)
+}
+
+// Reconstruct orders the tickets into a single itinerary using every ticket exactly once.
+func Reconstruct(ctx context.Context, tickets []Ticket, opts ...Option) (*Result, error) {
+	o := newOptions(opts)
+	o.Request.Tickets = toTickets(tickets)
+
+	result, err := dispatcher.New(o.Dispatcher...).Reconstruct(ctx, &o.Request)
+
+	return fromResult(result), fromError(err)
+}
+
+// ReconstructPairs is Reconstruct for tickets given as [source, destination] pairs.
+func ReconstructPairs(ctx context.Context, pairs [][]string, opts ...Option) (*Result, error) {
+	tickets := make([]Ticket, 0, len(pairs))
+	for _, pair := range pairs {
+		tickets = append(tickets, TicketFromPair(pair))
+	}
+
+	return Reconstruct(ctx, tickets, opts...)
+}
+
+// Validate runs every check against the tickets without reconstructing the itinerary, reporting all problems
+// rather than the first one. The options apply as they do to Reconstruct, e.g. WithDuplicates or WithAliases;
+// it fails when they are invalid.
+func Validate(ctx context.Context, tickets []Ticket, opts ...Option) (*ValidationReport, error) {
+	o := newOptions(opts)
+	o.Request.Tickets = toTickets(tickets)
+
+	report, _, err := dispatcher.New(o.Dispatcher...).Validate(ctx, &o.Request)
+
+	return fromReport(report), fromError(err)
+}
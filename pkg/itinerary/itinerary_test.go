@@ -0,0 +1,205 @@
+package itinerary_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/dsha256/dispatcher/pkg/itinerary"
+)
+
+func ExampleReconstructPairs() {
+	result, err := itinerary.ReconstructPairs(context.Background(), [][]string{
+		{"LAX", "DXB"}, {"JFK", "LAX"}, {"SFO", "SJC"}, {"DXB", "SFO"},
+	})
+	if err != nil {
+		fmt.Println(err)
+
+		return
+	}
+
+	fmt.Println(result.Path)
+	// Output: [JFK LAX DXB SFO SJC]
+}
+
+func ExampleValidate() {
+	report, err := itinerary.Validate(context.Background(), []itinerary.Ticket{
+		{From: "JFK", To: "LAX"},
+		{From: "SFO", To: "SJC"},
+	})
+	if err != nil {
+		fmt.Println(err)
+
+		return
+	}
+
+	fmt.Println(report.Valid, report.Err())
+	// Output: false different starting points
+}
+
+func TestReconstructPairs(t *testing.T) {
+	t.Parallel()
+
+	maxStops := 0
+	tests := []struct {
+		err     error
+		name    string
+		tickets [][]string
+		opts    []itinerary.Option
+		want    []string
+	}{
+		{
+			name:    "Valid itinerary",
+			tickets: [][]string{{"LAX", "DXB"}, {"JFK", "LAX"}},
+			want:    []string{"JFK", "LAX", "DXB"},
+		},
+		{
+			name:    "Malformed ticket",
+			tickets: [][]string{{"JFK", "LAX", "DXB"}},
+			err:     itinerary.ErrMalformedTicket,
+		},
+		{
+			name:    "Duplicates rejected",
+			tickets: [][]string{{"JFK", "LAX"}, {"LAX", "JFK"}, {"JFK", "LAX"}},
+			err:     itinerary.ErrMultipleSameDestination,
+		},
+		{
+			name:    "Duplicates allowed",
+			tickets: [][]string{{"JFK", "LAX"}, {"LAX", "JFK"}, {"JFK", "LAX"}},
+			opts:    []itinerary.Option{itinerary.WithDuplicates()},
+			want:    []string{"JFK", "LAX", "JFK", "LAX"},
+		},
+		{
+			name:    "Constraint violated",
+			tickets: [][]string{{"LAX", "DXB"}, {"JFK", "LAX"}},
+			opts:    []itinerary.Option{itinerary.WithConstraints(&itinerary.Constraints{MaxStops: &maxStops})},
+			err:     itinerary.ErrConstraintViolated,
+		},
+		{
+			name:    "Normalized codes",
+			tickets: [][]string{{"lax", "DXB"}, {" jfk", "LAX"}},
+			opts:    []itinerary.Option{itinerary.WithNormalizedCodes()},
+			want:    []string{"JFK", "LAX", "DXB"},
+		},
+		{
+			name:    "Aliases",
+			tickets: [][]string{{"JFK", "LHR"}, {"SFO", "EWR"}},
+			opts:    []itinerary.Option{itinerary.WithAliases(itinerary.Aliases{"NYC": {"JFK", "EWR"}})},
+			want:    []string{"SFO", "NYC", "LHR"},
+		},
+		{
+			name:    "Surface transfer",
+			tickets: [][]string{{"JFK", "LAX"}, {"SFO", "BOS"}},
+			opts:    []itinerary.Option{itinerary.WithSurfaceTransfers(itinerary.SurfaceTransfer{From: "LAX", To: "SFO"})},
+			want:    []string{"JFK", "LAX", "SFO", "BOS"},
+		},
+		{
+			name:    "Unknown airport",
+			tickets: [][]string{{"JFK", "XXX"}},
+			opts:    []itinerary.Option{itinerary.WithKnownAirports(func(code string) bool { return code != "XXX" })},
+			err:     itinerary.ErrUnknownAirport,
+		},
+		{
+			name:    "Unsupported algorithm version",
+			tickets: [][]string{{"JFK", "LAX"}},
+			opts:    []itinerary.Option{itinerary.WithAlgorithmVersion("0")},
+			err:     itinerary.ErrUnsupportedAlgorithmVersion,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := itinerary.ReconstructPairs(context.Background(), tt.tickets, tt.opts...)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("ReconstructPairs() error = %v; want %v", err, tt.err)
+			}
+			if err == nil && !reflect.DeepEqual(result.Path, tt.want) {
+				t.Errorf("ReconstructPairs() path = %v; want %v", result.Path, tt.want)
+			}
+		})
+	}
+}
+
+func TestReconstructErrors(t *testing.T) {
+	t.Parallel()
+
+	maxStops := 0
+	ctx := context.Background()
+
+	_, err := itinerary.ReconstructPairs(ctx, [][]string{{"JFK", "XXX"}},
+		itinerary.WithKnownAirports(func(code string) bool { return code != "XXX" }))
+	var validationErr *itinerary.ValidationError
+	if !errors.As(err, &validationErr) || len(validationErr.Report.Issues) != 1 ||
+		validationErr.Report.Issues[0].Kind != itinerary.IssueUnknownAirport {
+		t.Errorf("ReconstructPairs() error = %v; want a validation error reporting the unknown airport", err)
+	}
+
+	_, err = itinerary.ReconstructPairs(ctx, [][]string{{"LAX", "DXB"}, {"JFK", "LAX"}},
+		itinerary.WithConstraints(&itinerary.Constraints{MaxStops: &maxStops}))
+	var constraintErr *itinerary.ConstraintError
+	if !errors.As(err, &constraintErr) || !errors.Is(err, itinerary.ErrConstraintViolated) || constraintErr.Constraint != "max_stops" {
+		t.Errorf("ReconstructPairs() error = %v; want a max_stops constraint error", err)
+	}
+}
+
+func TestTicketUnmarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		err  error
+		name string
+		json string
+		want []string
+	}{
+		{name: "Pairs", json: `[["LAX", "DXB"], ["JFK", "LAX"]]`, want: []string{"JFK", "LAX", "DXB"}},
+		{name: "Objects", json: `[{"from": "LAX", "to": "DXB"}, {"from": "JFK", "to": "LAX"}]`, want: []string{"JFK", "LAX", "DXB"}},
+		{name: "Malformed pair", json: `[["JFK", "LAX", "DXB"]]`, err: itinerary.ErrMalformedTicket},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var tickets []itinerary.Ticket
+			if err := json.Unmarshal([]byte(tt.json), &tickets); err != nil {
+				t.Fatalf("json.Unmarshal() error = %v", err)
+			}
+			result, err := itinerary.Reconstruct(context.Background(), tickets)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Reconstruct() error = %v; want %v", err, tt.err)
+			}
+			if err == nil && !reflect.DeepEqual(result.Path, tt.want) {
+				t.Errorf("Reconstruct() path = %v; want %v", result.Path, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithCustomStrategy(t *testing.T) {
+	t.Parallel()
+
+	reversed := itinerary.ReconstructorFunc(func(_ context.Context, tickets []itinerary.Ticket, _ bool) ([]string, []itinerary.Leg, error) {
+		path := make([]string, 0, len(tickets)+1)
+		legs := make([]itinerary.Leg, 0, len(tickets))
+		for i := len(tickets) - 1; i >= 0; i-- {
+			path = append(path, tickets[i].From)
+			legs = append(legs, itinerary.Leg{From: tickets[i].From, To: tickets[i].To, TicketIndex: i})
+		}
+
+		return append(path, tickets[0].To), legs, nil
+	})
+
+	result, err := itinerary.ReconstructPairs(context.Background(), [][]string{{"LAX", "DXB"}, {"JFK", "LAX"}},
+		itinerary.WithCustomStrategy("reversed", reversed))
+	if err != nil {
+		t.Fatalf("ReconstructPairs() error = %v", err)
+	}
+	if want := []string{"JFK", "LAX", "DXB"}; !reflect.DeepEqual(result.Path, want) || result.Legs[0].TicketIndex != 1 {
+		t.Errorf("ReconstructPairs() = %+v; want path %v starting with ticket 1", result, want)
+	}
+}
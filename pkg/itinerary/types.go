@@ -0,0 +1,234 @@
+package itinerary
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/dispatcher"
+)
+
+// Ticket is a flight ticket; only From and To are required. In JSON it can be written either as a
+// ["Source", "Destination"] pair or as an object. Currency is the ISO 4217 code of the price, e.g. "USD".
+type Ticket struct {
+	DepartsAt *time.Time     `json:"departs_at,omitempty" xml:"departs_at,omitempty"`
+	ArrivesAt *time.Time     `json:"arrives_at,omitempty" xml:"arrives_at,omitempty"`
+	Price     *float64       `json:"price,omitempty"      xml:"price,omitempty"`
+	Metadata  map[string]any `json:"metadata,omitempty"   xml:"-"`
+	From      string         `json:"from"                 xml:"from"`
+	To        string         `json:"to"                   xml:"to"`
+	FlightNo  string         `json:"flight_no,omitempty"  xml:"flight_no,omitempty"`
+	Currency  string         `json:"currency,omitempty"   xml:"currency,omitempty"`
+	// pair keeps a malformed pair, e.g. of 3 codes, so that it is rejected instead of silently truncated.
+	pair []string
+}
+
+// TicketFromPair returns the ticket of a [source, destination] pair. A malformed pair is kept as is, to be
+// rejected by Reconstruct and reported by Validate.
+func TicketFromPair(pair []string) Ticket {
+	return fromTicket(dispatcher.TicketFromPair(pair))
+}
+
+// UnmarshalJSON decodes a ticket of either form. Objects are decoded strictly: fields a Ticket does not have
+// are rejected, arbitrary data belonging in Metadata.
+func (t *Ticket) UnmarshalJSON(data []byte) error {
+	var ticket dispatcher.Ticket
+	if err := json.Unmarshal(data, &ticket); err != nil {
+		return err
+	}
+	*t = fromTicket(ticket)
+
+	return nil
+}
+
+// Leg is a step of the reconstructed itinerary, pointing back to the ticket it was made with.
+//
+// Multiplicity and Occurrence are only set WithDuplicates: Multiplicity is the number of identical tickets for
+// the segment and Occurrence the 1-based order in which this one is flown.
+type Leg struct {
+	From         string `json:"from"`
+	To           string `json:"to"`
+	TicketIndex  int    `json:"ticket_index"`
+	Multiplicity int    `json:"multiplicity,omitempty"`
+	Occurrence   int    `json:"occurrence,omitempty"`
+	// SurfaceTransfer marks a permitted gap of an open-jaw trip (see WithSurfaceTransfers), made without a
+	// ticket: its TicketIndex is -1.
+	SurfaceTransfer bool `json:"surface_transfer,omitempty"`
+}
+
+// Result is the reconstructed itinerary. Normalization lists the airport codes rewritten before reconstruction;
+// the path and legs use the rewritten codes.
+type Result struct {
+	AlgorithmVersion string
+	Path             []string
+	Legs             []Leg
+	Normalization    []CodeChange
+}
+
+// CodeChange is an airport code of a ticket rewritten before reconstruction.
+type CodeChange struct {
+	// Field is "from" or "to".
+	Field      string `json:"field"`
+	Original   string `json:"original"`
+	Normalized string `json:"normalized"`
+	// Reason is "alias" when the code was replaced by an alias, "format" when it was only reformatted.
+	Reason      string `json:"reason"`
+	TicketIndex int    `json:"ticket_index"`
+}
+
+// Constraints are optional rules the itinerary must satisfy.
+type Constraints struct {
+	// MaxStops is the maximum number of intermediate airports, i.e. excluding origin and final destination.
+	MaxStops *int `json:"max_stops,omitempty" xml:"max_stops,omitempty"`
+	// AvoidAirports must not appear anywhere in the itinerary.
+	AvoidAirports []string `json:"avoid_airports,omitempty" xml:"avoid_airports>airport,omitempty"`
+	// RequiredWaypoints must appear somewhere in the itinerary.
+	RequiredWaypoints []string `json:"required_waypoints,omitempty" xml:"required_waypoints>airport,omitempty"`
+}
+
+// Strategy picks between several valid orderings.
+type Strategy string
+
+// TieBreak decides which destination is taken first when an airport has several.
+type TieBreak string
+
+// HubWeights bias the choice between destinations towards preferred hub airports.
+type HubWeights map[string]float64
+
+// Aliases map a code to the codes it replaces before reconstruction.
+type Aliases map[string][]string
+
+// SurfaceTransfer is a gap an open-jaw trip is allowed to have: the traveller arrives at From and continues
+// from To by other means.
+type SurfaceTransfer struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Reconstructor is the algorithm behind a Strategy. It receives tickets that have already passed the checks
+// of the request and returns the path with the legs mapping every step back to a ticket. With allowDuplicates,
+// repeated identical tickets are distinct tickets of the itinerary.
+type Reconstructor interface {
+	Reconstruct(ctx context.Context, tickets []Ticket, allowDuplicates bool) ([]string, []Leg, error)
+}
+
+// ReconstructorFunc adapts a function to a Reconstructor.
+type ReconstructorFunc func(ctx context.Context, tickets []Ticket, allowDuplicates bool) ([]string, []Leg, error)
+
+func (f ReconstructorFunc) Reconstruct(ctx context.Context, tickets []Ticket, allowDuplicates bool) ([]string, []Leg, error) {
+	return f(ctx, tickets, allowDuplicates)
+}
+
+// IssueKind classifies a problem of a ticket set.
+type IssueKind string
+
+const (
+	IssueMalformedTicket  IssueKind = IssueKind(dispatcher.IssueMalformedTicket)
+	IssueUnknownAirport   IssueKind = IssueKind(dispatcher.IssueUnknownAirport)
+	IssueDuplicateTicket  IssueKind = IssueKind(dispatcher.IssueDuplicateTicket)
+	IssueUnbalancedDegree IssueKind = IssueKind(dispatcher.IssueUnbalancedDegree)
+	IssueNoStartingPoint  IssueKind = IssueKind(dispatcher.IssueNoStartingPoint)
+	IssueDisconnected     IssueKind = IssueKind(dispatcher.IssueDisconnected)
+)
+
+// ValidationIssue is a single problem of a ticket set and the tickets causing it. Indexes refer to positions
+// in the validated tickets.
+type ValidationIssue struct {
+	Kind    IssueKind  `json:"kind"`
+	Airport string     `json:"airport,omitempty"`
+	Detail  string     `json:"detail"`
+	Indexes []int      `json:"indexes"`
+	Tickets [][]string `json:"tickets"`
+}
+
+// AirportDegree is the number of departures and arrivals of a single airport.
+type AirportDegree struct {
+	Airport    string `json:"airport"`
+	Departures int    `json:"departures"`
+	Arrivals   int    `json:"arrivals"`
+}
+
+// ValidationReport lists every problem of a ticket set.
+type ValidationReport struct {
+	// StartCandidates are the airports with more departures than arrivals.
+	StartCandidates []string `json:"start_candidates"`
+	// EndCandidates are the airports with more arrivals than departures.
+	EndCandidates []string `json:"end_candidates"`
+	// Unbalanced are the airports whose degrees rule out a single path.
+	Unbalanced []AirportDegree   `json:"unbalanced"`
+	Issues     []ValidationIssue `json:"issues"`
+	Valid      bool              `json:"valid"`
+}
+
+// Err returns the sentinel error matching the most severe issue of the report, or nil when it is valid.
+func (r *ValidationReport) Err() error {
+	report := &dispatcher.ValidationReport{Valid: r.Valid, Issues: make([]dispatcher.ValidationIssue, 0, len(r.Issues))}
+	for _, issue := range r.Issues {
+		report.Issues = append(report.Issues, dispatcher.ValidationIssue{Kind: dispatcher.IssueKind(issue.Kind)})
+	}
+
+	return report.Err()
+}
+
+// ValidationError carries the ValidationReport of a rejected ticket set. It unwraps to the sentinel error of
+// the most severe issue.
+type ValidationError struct {
+	Err    error
+	Report *ValidationReport
+}
+
+func (e *ValidationError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// ConstraintError names the violated constraint and the airports involved. It unwraps to
+// ErrConstraintViolated.
+type ConstraintError struct {
+	Constraint string   `json:"constraint"`
+	Detail     string   `json:"detail"`
+	Airports   []string `json:"airports,omitempty"`
+	Indexes    []int    `json:"indexes,omitempty"`
+}
+
+func (e *ConstraintError) Error() string {
+	return (&dispatcher.ConstraintError{Constraint: e.Constraint, Detail: e.Detail}).Error()
+}
+
+func (e *ConstraintError) Unwrap() error {
+	return ErrConstraintViolated
+}
+
+// InfeasibleConnection describes two consecutive legs that cannot be flown one after another.
+type InfeasibleConnection struct {
+	ReadyAt         time.Time `json:"ready_at"`
+	DepartsAt       time.Time `json:"departs_at"`
+	Reason          string    `json:"reason"`
+	Airport         string    `json:"airport"`
+	Layover         string    `json:"layover"`
+	RequiredLayover string    `json:"required_layover"`
+	FromTicketIndex int       `json:"from_ticket_index"`
+	ToTicketIndex   int       `json:"to_ticket_index"`
+}
+
+// ConnectionError lists the infeasible connections of time-aware reconstruction. It unwraps to
+// ErrInfeasibleConnection.
+type ConnectionError struct {
+	Connections []InfeasibleConnection `json:"connections"`
+}
+
+func (e *ConnectionError) Error() string {
+	connections := make([]dispatcher.InfeasibleConnection, 0, len(e.Connections))
+	for _, c := range e.Connections {
+		connections = append(connections, dispatcher.InfeasibleConnection{Reason: c.Reason})
+	}
+
+	return (&dispatcher.ConnectionError{Connections: connections}).Error()
+}
+
+func (e *ConnectionError) Unwrap() error {
+	return ErrInfeasibleConnection
+}
@@ -0,0 +1,293 @@
+// Package server embeds the dispatcher API in other binaries. It serves the same routes as the dispatcher
+// service, with the features that need configuration (caching, storage, messaging, ...) disabled:
+//
+//	srv := server.New(
+//		server.WithAddr(":8080"),
+//		server.WithLogger(logger),
+//		server.WithMiddleware(auth),
+//	)
+//	if err := srv.Start(ctx); err != nil {
+//		log.Fatal(err)
+//	}
+//
+// Start serves until the context is canceled, then shuts down gracefully. Binaries with their own server
+// can mount Handler instead.
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dsha256/dispatcher/internal/clock"
+	"github.com/dsha256/dispatcher/internal/config"
+	"github.com/dsha256/dispatcher/internal/embedding"
+	"github.com/dsha256/dispatcher/internal/handler"
+	"github.com/dsha256/dispatcher/internal/health"
+	"github.com/dsha256/dispatcher/internal/logbuffer"
+	"github.com/dsha256/dispatcher/internal/middleware"
+	"github.com/dsha256/dispatcher/internal/responder"
+	"github.com/dsha256/dispatcher/internal/support"
+	"github.com/dsha256/dispatcher/pkg/itinerary"
+)
+
+var ErrAlreadyStarted = errors.New("server already started")
+
+const (
+	// DefaultAddr is the listen address unless WithAddr sets another one.
+	DefaultAddr = ":3000"
+	// DefaultShutdownTimeout bounds the graceful shutdown when the context passed to Start is canceled.
+	DefaultShutdownTimeout = 5 * time.Second
+
+	// recentLogLines is the number of log lines kept for support bundles, which only the service fills.
+	recentLogLines = 100
+	// readHeaderTimeout bounds reading request headers, as in the service's default configuration.
+	readHeaderTimeout = 5 * time.Second
+)
+
+type (
+	// Middleware wraps the routes, e.g. to authenticate requests.
+	Middleware = middleware.Middleware
+	// Responder writes the responses, choosing their envelope.
+	Responder = responder.Responder
+	// Success is a success response before the Responder puts it in an envelope.
+	Success = responder.Success
+	// Envelope is the default Responder, to embed in one adding fields or headers.
+	Envelope = responder.Envelope
+	// Bare is a Responder writing success payloads without an envelope.
+	Bare = responder.Bare
+	// PanicReporter sends the panics recovered while serving requests to an error tracker.
+	PanicReporter = middleware.PanicReporter
+	// Panic is a panic recovered while serving a request.
+	Panic = middleware.Panic
+)
+
+// Option configures a Server.
+type Option func(*options)
+
+type options struct {
+	logger          *slog.Logger
+	dispatcher      *itinerary.Dispatcher
+	tls             *tls.Config
+	addr            string
+	middleware      []Middleware
+	handler         []handler.Option
+	shutdownTimeout time.Duration
+}
+
+// WithAddr sets the listen address, DefaultAddr by default.
+func WithAddr(addr string) Option {
+	return func(o *options) {
+		o.addr = addr
+	}
+}
+
+// WithLogger sets the logger of the server and its routes, slog.Default() by default.
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithMiddleware wraps the routes in the middleware, the first one outermost. It may be given several times.
+func WithMiddleware(middleware ...Middleware) Option {
+	return func(o *options) {
+		o.middleware = append(o.middleware, middleware...)
+	}
+}
+
+// WithRouteMiddleware wraps the route with the pattern, e.g. "POST /api/v1/dispatcher/itinerary", in the
+// middleware, inside those given to WithMiddleware. It may be given several times.
+func WithRouteMiddleware(pattern string, middleware ...Middleware) Option {
+	return func(o *options) {
+		o.handler = append(o.handler, handler.WithRouteMiddleware(pattern, middleware...))
+	}
+}
+
+// WithResponder writes the responses with the responder instead of the default Envelope.
+func WithResponder(rsp Responder) Option {
+	return func(o *options) {
+		o.handler = append(o.handler, handler.WithResponder(rsp))
+	}
+}
+
+// WithPanicReporter reports the panics recovered while serving requests through the reporters, which are
+// always logged; 500 responses to them carry the panic ID.
+func WithPanicReporter(reporters ...PanicReporter) Option {
+	return func(o *options) {
+		o.handler = append(o.handler, handler.WithRecovery(middleware.NewRecovery(reporters...)))
+	}
+}
+
+// WithReadinessCheck makes readiness depend on the named check of a dependency, e.g. a ping of the
+// embedder's database. The server is not ready while a critical check fails, and degraded while another does.
+func WithReadinessCheck(name string, check func(ctx context.Context) error, critical bool) Option {
+	return func(o *options) {
+		var opts []health.Option
+		if !critical {
+			opts = append(opts, health.NonCritical())
+		}
+		o.handler = append(o.handler, handler.WithReadinessCheck(name, check, opts...))
+	}
+}
+
+// WithTLS serves HTTPS with the TLS configuration, which must have a certificate or GetCertificate.
+func WithTLS(cfg *tls.Config) Option {
+	return func(o *options) {
+		o.tls = cfg
+	}
+}
+
+// WithDispatcher reconstructs itineraries with the dispatcher, e.g. one with a custom strategy built with
+// itinerary.NewDispatcher, instead of the default one.
+func WithDispatcher(d *itinerary.Dispatcher) Option {
+	return func(o *options) {
+		o.dispatcher = d
+	}
+}
+
+// WithShutdownTimeout bounds the graceful shutdown when the context passed to Start is canceled,
+// DefaultShutdownTimeout by default.
+func WithShutdownTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.shutdownTimeout = timeout
+	}
+}
+
+// Server serves the dispatcher API.
+type Server struct {
+	srv      *http.Server
+	logger   *slog.Logger
+	tls      *tls.Config
+	listener net.Listener
+	handler  http.Handler
+	mu       sync.Mutex
+	timeout  time.Duration
+}
+
+// New returns a server configured by the options; it serves nothing until Start.
+func New(opts ...Option) *Server {
+	o := &options{addr: DefaultAddr, logger: slog.Default(), shutdownTimeout: DefaultShutdownTimeout}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	// The dispatcher is built anew from the options of the itinerary.Dispatcher, which keeps its own unexported.
+	var dispatcherOpts embedding.Options
+	if o.dispatcher != nil {
+		for _, opt := range o.dispatcher.Options() {
+			opt(&dispatcherOpts)
+		}
+	}
+
+	mux := http.NewServeMux()
+	bundler := support.NewBundler(clock.Real{}, &config.Config{}, logbuffer.New(recentLogLines))
+	handler.New(o.logger, dispatcherOpts.NewDispatcher(), bundler, o.handler...).RegisterRoutes(mux)
+
+	routes := middleware.NewChain(o.middleware...).Use(middleware.FormatMiddleware, func(next http.Handler) http.Handler {
+		return middleware.VersionMiddleware(middleware.Versioning{}, next)
+	}).Then(mux)
+
+	return &Server{
+		srv: &http.Server{
+			Addr:              o.addr,
+			Handler:           routes,
+			TLSConfig:         o.tls,
+			ReadHeaderTimeout: readHeaderTimeout,
+			ErrorLog:          slog.NewLogLogger(o.logger.Handler(), slog.LevelError),
+		},
+		logger:  o.logger,
+		tls:     o.tls,
+		handler: routes,
+		timeout: o.shutdownTimeout,
+	}
+}
+
+// Handler returns the routes with their middleware, for binaries serving them with their own server.
+func (s *Server) Handler() http.Handler {
+	return s.handler
+}
+
+// Addr returns the address the server listens on once started, e.g. the port picked for ":0", or "".
+func (s *Server) Addr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.listener == nil {
+		return ""
+	}
+
+	return s.listener.Addr().String()
+}
+
+// Start listens and serves until the context is canceled or Shutdown is called, shutting down gracefully
+// in the first case. It returns nil once shut down, and the error when listening or serving fails.
+func (s *Server) Start(ctx context.Context) error {
+	listener, err := s.listen(ctx)
+	if err != nil {
+		return err
+	}
+
+	shutDown := make(chan struct{})
+	stop := context.AfterFunc(ctx, func() {
+		defer close(shutDown)
+
+		shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), s.timeout)
+		defer cancel()
+
+		if shutdownErr := s.Shutdown(shutdownCtx); shutdownErr != nil {
+			s.logger.Error("Server forced to shutdown", "error", shutdownErr)
+		}
+	})
+	defer func() {
+		// Serve returns as soon as shutdown starts; wait for the ongoing requests.
+		if !stop() {
+			<-shutDown
+		}
+	}()
+
+	s.logger.Info("Server starting", "addr", listener.Addr().String(), "tls", s.tls != nil)
+	if s.tls != nil {
+		err = s.srv.ServeTLS(listener, "", "")
+	} else {
+		err = s.srv.Serve(listener)
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("serving: %w", err)
+	}
+
+	return nil
+}
+
+// listen opens the listener, once.
+func (s *Server) listen(ctx context.Context) (net.Listener, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.listener != nil {
+		return nil, ErrAlreadyStarted
+	}
+
+	listener, err := (&net.ListenConfig{}).Listen(ctx, "tcp", s.srv.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("listening on %s: %w", s.srv.Addr, err)
+	}
+	s.listener = listener
+
+	return listener, nil
+}
+
+// Shutdown stops accepting requests and waits for the ongoing ones until the context is done.
+func (s *Server) Shutdown(ctx context.Context) error {
+	if err := s.srv.Shutdown(ctx); err != nil {
+		return fmt.Errorf("shutting down: %w", err)
+	}
+
+	return nil
+}
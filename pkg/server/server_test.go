@@ -0,0 +1,137 @@
+package server_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/signal"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dsha256/dispatcher/pkg/itinerary"
+	"github.com/dsha256/dispatcher/pkg/server"
+)
+
+func ExampleNew() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	srv := server.New(
+		server.WithAddr(":8080"),
+		server.WithDispatcher(itinerary.NewDispatcher(itinerary.WithDuplicates())),
+		server.WithMiddleware(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Authorization") == "" {
+					http.Error(w, "unauthorized", http.StatusUnauthorized)
+
+					return
+				}
+				next.ServeHTTP(w, r)
+			})
+		}),
+	)
+	if err := srv.Start(ctx); err != nil {
+		slog.Error("Server failed", "error", err)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	t.Parallel()
+
+	trace := func(name string) server.Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Add("X-Trace", name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	duplicates := `{"tickets": [["JFK", "LAX"], ["LAX", "JFK"], ["JFK", "LAX"]]}`
+
+	tests := []struct {
+		name       string
+		opts       []server.Option
+		body       string
+		wantStatus int
+	}{
+		{
+			name:       "Default dispatcher",
+			opts:       []server.Option{server.WithMiddleware(trace("outer"), trace("inner"))},
+			body:       `{"tickets": [["LAX", "DXB"], ["JFK", "LAX"]]}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Duplicates rejected",
+			opts:       []server.Option{server.WithMiddleware(trace("outer")), server.WithMiddleware(trace("inner"))},
+			body:       duplicates,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Custom dispatcher",
+			opts: []server.Option{
+				server.WithMiddleware(trace("outer"), trace("inner")),
+				server.WithDispatcher(itinerary.NewDispatcher(itinerary.WithDuplicates())),
+			},
+			body:       duplicates,
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			handler := server.New(tt.opts...).Handler()
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/dispatcher/itinerary", strings.NewReader(tt.body)))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body)
+			}
+			if trace := strings.Join(rec.Header().Values("X-Trace"), ","); trace != "outer,inner" {
+				t.Errorf("Expected the middleware to run outer first, got %q", trace)
+			}
+		})
+	}
+}
+
+func TestStart(t *testing.T) {
+	t.Parallel()
+
+	srv := server.New(server.WithAddr("127.0.0.1:0"))
+	ctx, cancel := context.WithCancel(t.Context())
+	started := make(chan error, 1)
+	go func() {
+		started <- srv.Start(ctx)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for srv.Addr() == "" && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, "http://"+srv.Addr()+"/api/v2/liveness", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	cancel()
+	if err = <-started; err != nil {
+		t.Errorf("Expected a graceful shutdown, got %v", err)
+	}
+	if err = srv.Start(t.Context()); !errors.Is(err, server.ErrAlreadyStarted) {
+		t.Errorf("Expected %v when starting again, got %v", server.ErrAlreadyStarted, err)
+	}
+}